@@ -0,0 +1,205 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultCUDACompatDir is the directory containing the CUDA forward
+// compatibility libraries if no directory is configured. This is the default
+// install location of the cuda-compat packages.
+const DefaultCUDACompatDir = "/usr/local/cuda/compat"
+
+// ExclusiveAnnotation is the pod annotation that requests exclusive access to
+// the physical GPUs underlying the replicas of a shared resource that are
+// allocated to the pod. It is only honored for resources with AllowExclusive.
+const ExclusiveAnnotation = "nvidia.com/gpu-exclusive"
+
+// Allocation defines options that control how allocation requests are handled by the plugin.
+type Allocation struct {
+	// MaxConcurrent is the maximum number of Allocate calls that are processed
+	// concurrently across all resources. A value of 0 disables the limit.
+	MaxConcurrent int `json:"maxConcurrent,omitempty" yaml:"maxConcurrent,omitempty"`
+	// ScrubCommand is the command run to scrub the memory of a device for
+	// resources with ScrubMemory enabled. The UUID of the device is appended
+	// as the last argument. If unset, the memory is cleared through CUDA by
+	// the plugin executable.
+	ScrubCommand []string `json:"scrubCommand,omitempty"  yaml:"scrubCommand,omitempty"`
+	// CUDACompatDir is the directory on the host containing the CUDA forward
+	// compatibility libraries that are mounted for resources with CUDACompat
+	// enabled.
+	CUDACompatDir string `json:"cudaCompatDir,omitempty" yaml:"cudaCompatDir,omitempty"`
+	// CUDACompatPolicy selects the CUDA forward compatibility package that is
+	// mounted for resources with CUDACompat enabled based on the CUDA version
+	// required by each pod. If set, CUDACompatDir is ignored.
+	CUDACompatPolicy *CUDACompatPolicy `json:"cudaCompatPolicy,omitempty" yaml:"cudaCompatPolicy,omitempty"`
+	// RuntimeClasses declares the device list strategies supported by the
+	// runtimes of the listed runtime classes. Allocation requests of pods
+	// whose runtime class supports none of the device list strategies of the
+	// plugin are rejected. A runtime class with an empty name declares the
+	// strategies of the default runtime. Pods whose runtime class is not
+	// listed are not checked.
+	RuntimeClasses []RuntimeClass `json:"runtimeClasses,omitempty" yaml:"runtimeClasses,omitempty"`
+	// Resources defines per-resource allocation options.
+	Resources []AllocationResource `json:"resources,omitempty"     yaml:"resources,omitempty"`
+}
+
+// AllocationResource defines the allocation options for a specific resource.
+type AllocationResource struct {
+	Name ResourceName `json:"name"                     yaml:"name"`
+	// MaxConcurrent is the maximum number of Allocate calls that are processed
+	// concurrently for this resource. A value of 0 disables the limit.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"  yaml:"maxConcurrent,omitempty"`
+	// ScrubMemory enables scrubbing the memory of the allocated devices in
+	// PreStartContainer, before they are handed to a new container.
+	ScrubMemory bool `json:"scrubMemory,omitempty"    yaml:"scrubMemory,omitempty"`
+	// CUDACompat enables mounting the CUDA forward compatibility libraries
+	// into the containers that are allocated devices of this resource if the
+	// driver on the host is older than the libraries. With a CUDACompatPolicy,
+	// the libraries are only mounted for pods that require a newer CUDA
+	// version than the driver supports.
+	CUDACompat bool `json:"cudaCompat,omitempty"     yaml:"cudaCompat,omitempty"`
+	// BoostClocks enables raising the application clocks and power limit of
+	// the allocated devices to their maximum until the devices are released.
+	BoostClocks bool `json:"boostClocks,omitempty"    yaml:"boostClocks,omitempty"`
+	// AllowExclusive allows pods to request exclusive access to the physical
+	// GPUs of a shared resource through the ExclusiveAnnotation. No other
+	// replicas of these GPUs are handed out while such a pod is running.
+	AllowExclusive bool `json:"allowExclusive,omitempty" yaml:"allowExclusive,omitempty"`
+	// MaxClientsPerDevice is the maximum number of containers that are
+	// allocated replicas of the same physical GPU of a shared resource,
+	// independent of the number of replicas. A value of 0 disables the limit.
+	MaxClientsPerDevice int `json:"maxClientsPerDevice,omitempty" yaml:"maxClientsPerDevice,omitempty"`
+	// ReleaseCooldown is the time after a physical GPU of this resource is
+	// released by a container during which its devices are not preferred for
+	// new allocations, giving the driver time to reclaim the memory of the
+	// previous container. A value of 0 disables the cooldown.
+	ReleaseCooldown Duration `json:"releaseCooldown,omitempty"     yaml:"releaseCooldown,omitempty"`
+	// TopologyFile enables mounting a JSON file that describes the topology
+	// of the allocated devices into the containers that are allocated devices
+	// of this resource. The file is injected through CDI and only covers the
+	// allocated devices.
+	TopologyFile bool `json:"topologyFile,omitempty"   yaml:"topologyFile,omitempty"`
+}
+
+// GetMaxConcurrent returns the maximum number of concurrent Allocate calls across all resources.
+func (a *Allocation) GetMaxConcurrent() int {
+	if a == nil {
+		return 0
+	}
+	return a.MaxConcurrent
+}
+
+// GetScrubCommand returns the command used to scrub the memory of a device,
+// or nil if the memory is cleared by the plugin executable.
+func (a *Allocation) GetScrubCommand() []string {
+	if a == nil {
+		return nil
+	}
+	return a.ScrubCommand
+}
+
+// GetCUDACompatDir returns the directory containing the CUDA forward compatibility libraries.
+func (a *Allocation) GetCUDACompatDir() string {
+	if a == nil || a.CUDACompatDir == "" {
+		return DefaultCUDACompatDir
+	}
+	return a.CUDACompatDir
+}
+
+// GetCUDACompatPolicy returns the policy that selects the CUDA forward
+// compatibility package for each pod, or nil if no policy is configured.
+func (a *Allocation) GetCUDACompatPolicy() *CUDACompatPolicy {
+	if a == nil {
+		return nil
+	}
+	return a.CUDACompatPolicy
+}
+
+// GetRuntimeClasses returns the runtime classes whose device list strategies
+// are checked before devices are allocated to a pod.
+func (a *Allocation) GetRuntimeClasses() []RuntimeClass {
+	if a == nil {
+		return nil
+	}
+	return a.RuntimeClasses
+}
+
+// ForResource returns the allocation options for the specified resource.
+// If no options are defined for the resource, empty options are returned.
+func (a *Allocation) ForResource(name ResourceName) AllocationResource {
+	if a != nil {
+		for _, r := range a.Resources {
+			if r.Name == name {
+				return r
+			}
+		}
+	}
+	return AllocationResource{Name: name}
+}
+
+// UnmarshalJSON unmarshals raw bytes into an 'Allocation' struct.
+func (a *Allocation) UnmarshalJSON(b []byte) error {
+	type allocation Allocation
+	if err := json.Unmarshal(b, (*allocation)(a)); err != nil {
+		return err
+	}
+	if a.MaxConcurrent < 0 {
+		return fmt.Errorf("maxConcurrent must be >= 0")
+	}
+	seen := make(map[ResourceName]bool)
+	for _, r := range a.Resources {
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate allocation options for resource %q", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	runtimeClasses := make(map[string]bool)
+	for _, c := range a.RuntimeClasses {
+		if err := c.validate(); err != nil {
+			return err
+		}
+		if runtimeClasses[c.Name] {
+			return fmt.Errorf("duplicate runtime class %q", c.Name)
+		}
+		runtimeClasses[c.Name] = true
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into an 'AllocationResource' struct.
+func (r *AllocationResource) UnmarshalJSON(b []byte) error {
+	type allocationResource AllocationResource
+	if err := json.Unmarshal(b, (*allocationResource)(r)); err != nil {
+		return err
+	}
+	if r.Name == "" {
+		return fmt.Errorf("no resource name specified")
+	}
+	if r.MaxConcurrent < 0 {
+		return fmt.Errorf("maxConcurrent must be >= 0 for resource %q", r.Name)
+	}
+	if r.MaxClientsPerDevice < 0 {
+		return fmt.Errorf("maxClientsPerDevice must be >= 0 for resource %q", r.Name)
+	}
+	if r.ReleaseCooldown < 0 {
+		return fmt.Errorf("releaseCooldown must be >= 0 for resource %q", r.Name)
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocationConfig(t *testing.T) {
+	testCases := []struct {
+		description   string
+		input         string
+		expected      *Allocation
+		expectedError bool
+	}{
+		{
+			description: "no allocation section",
+			input:       `version: v1`,
+		},
+		{
+			description: "global limit only",
+			input: `
+version: v1
+allocation:
+  maxConcurrent: 8
+`,
+			expected: &Allocation{
+				MaxConcurrent: 8,
+			},
+		},
+		{
+			description: "per-resource limits are qualified",
+			input: `
+version: v1
+allocation:
+  maxConcurrent: 8
+  resources:
+  - name: gpu
+    maxConcurrent: 2
+`,
+			expected: &Allocation{
+				MaxConcurrent: 8,
+				Resources: []AllocationResource{
+					{Name: "nvidia.com/gpu", MaxConcurrent: 2},
+				},
+			},
+		},
+		{
+			description: "memory scrubbing with custom command",
+			input: `
+version: v1
+allocation:
+  scrubCommand: ["/usr/local/bin/scrub", "--device"]
+  resources:
+  - name: gpu
+    scrubMemory: true
+`,
+			expected: &Allocation{
+				ScrubCommand: []string{"/usr/local/bin/scrub", "--device"},
+				Resources: []AllocationResource{
+					{Name: "nvidia.com/gpu", ScrubMemory: true},
+				},
+			},
+		},
+		{
+			description: "cuda compat with custom directory",
+			input: `
+version: v1
+allocation:
+  cudaCompatDir: /opt/cuda-compat
+  resources:
+  - name: gpu
+    cudaCompat: true
+`,
+			expected: &Allocation{
+				CUDACompatDir: "/opt/cuda-compat",
+				Resources: []AllocationResource{
+					{Name: "nvidia.com/gpu", CUDACompat: true},
+				},
+			},
+		},
+		{
+			description: "cuda compat policy",
+			input: `
+version: v1
+allocation:
+  cudaCompatPolicy:
+    packagesDir: /opt
+    images:
+    - pattern: nvcr.io/nvidia/pytorch:24.*
+      cudaVersion: "12.4"
+  resources:
+  - name: gpu
+    cudaCompat: true
+`,
+			expected: &Allocation{
+				CUDACompatPolicy: &CUDACompatPolicy{
+					PackagesDir: "/opt",
+					Images: []CUDACompatImage{
+						{Pattern: "nvcr.io/nvidia/pytorch:24.*", CUDAVersion: "12.4"},
+					},
+				},
+				Resources: []AllocationResource{
+					{Name: "nvidia.com/gpu", CUDACompat: true},
+				},
+			},
+		},
+		{
+			description: "runtime classes",
+			input: `
+version: v1
+allocation:
+  runtimeClasses:
+  - name: nvidia
+    deviceListStrategies: [envvar, volume-mounts]
+  - name: nvidia-cdi
+    deviceListStrategies: [cdi-annotations]
+`,
+			expected: &Allocation{
+				RuntimeClasses: []RuntimeClass{
+					{Name: "nvidia", DeviceListStrategies: []string{"envvar", "volume-mounts"}},
+					{Name: "nvidia-cdi", DeviceListStrategies: []string{"cdi-annotations"}},
+				},
+			},
+		},
+		{
+			description: "default runtime",
+			input: `
+version: v1
+allocation:
+  runtimeClasses:
+  - name: ""
+    deviceListStrategies: [envvar]
+`,
+			expected: &Allocation{
+				RuntimeClasses: []RuntimeClass{
+					{Name: "", DeviceListStrategies: []string{"envvar"}},
+				},
+			},
+		},
+		{
+			description: "runtime class with invalid device list strategy",
+			input: `
+version: v1
+allocation:
+  runtimeClasses:
+  - name: nvidia
+    deviceListStrategies: [cdi]
+`,
+			expectedError: true,
+		},
+		{
+			description: "runtime class without device list strategies",
+			input: `
+version: v1
+allocation:
+  runtimeClasses:
+  - name: nvidia
+`,
+			expectedError: true,
+		},
+		{
+			description: "duplicate runtime classes",
+			input: `
+version: v1
+allocation:
+  runtimeClasses:
+  - name: nvidia
+    deviceListStrategies: [envvar]
+  - name: nvidia
+    deviceListStrategies: [cdi-cri]
+`,
+			expectedError: true,
+		},
+		{
+			description: "cuda compat policy with malformed CUDA version",
+			input: `
+version: v1
+allocation:
+  cudaCompatPolicy:
+    images:
+    - pattern: nvcr.io/nvidia/pytorch:24.*
+      cudaVersion: "12"
+`,
+			expectedError: true,
+		},
+		{
+			description: "cuda compat policy without image pattern",
+			input: `
+version: v1
+allocation:
+  cudaCompatPolicy:
+    images:
+    - cudaVersion: "12.4"
+`,
+			expectedError: true,
+		},
+		{
+			description: "max clients per device",
+			input: `
+version: v1
+allocation:
+  resources:
+  - name: gpu.shared
+    maxClientsPerDevice: 3
+`,
+			expected: &Allocation{
+				Resources: []AllocationResource{
+					{Name: "nvidia.com/gpu.shared", MaxClientsPerDevice: 3},
+				},
+			},
+		},
+		{
+			description: "negative max clients per device is an error",
+			input: `
+version: v1
+allocation:
+  resources:
+  - name: gpu.shared
+    maxClientsPerDevice: -1
+`,
+			expectedError: true,
+		},
+		{
+			description: "release cooldown",
+			input: `
+version: v1
+allocation:
+  resources:
+  - name: gpu.shared
+    releaseCooldown: 30s
+`,
+			expected: &Allocation{
+				Resources: []AllocationResource{
+					{Name: "nvidia.com/gpu.shared", ReleaseCooldown: Duration(30 * time.Second)},
+				},
+			},
+		},
+		{
+			description: "negative release cooldown is an error",
+			input: `
+version: v1
+allocation:
+  resources:
+  - name: gpu.shared
+    releaseCooldown: -1s
+`,
+			expectedError: true,
+		},
+		{
+			description: "negative global limit is an error",
+			input: `
+version: v1
+allocation:
+  maxConcurrent: -1
+`,
+			expectedError: true,
+		},
+		{
+			description: "missing resource name is an error",
+			input: `
+version: v1
+allocation:
+  resources:
+  - maxConcurrent: 2
+`,
+			expectedError: true,
+		},
+		{
+			description: "duplicate resource is an error",
+			input: `
+version: v1
+allocation:
+  resources:
+  - name: nvidia.com/gpu
+    maxConcurrent: 2
+  - name: gpu
+    maxConcurrent: 4
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expected, config.Allocation)
+		})
+	}
+}
+
+func TestAllocationForResource(t *testing.T) {
+	var nilAllocation *Allocation
+	require.Equal(t, 0, nilAllocation.GetMaxConcurrent())
+	require.Equal(t, AllocationResource{Name: "nvidia.com/gpu"}, nilAllocation.ForResource("nvidia.com/gpu"))
+	require.Nil(t, nilAllocation.GetScrubCommand())
+	require.Equal(t, DefaultCUDACompatDir, nilAllocation.GetCUDACompatDir())
+
+	allocation := &Allocation{
+		MaxConcurrent: 4,
+		Resources: []AllocationResource{
+			{Name: "nvidia.com/gpu.shared", MaxConcurrent: 1},
+		},
+	}
+	require.Equal(t, 4, allocation.GetMaxConcurrent())
+	require.Equal(t, 1, allocation.ForResource("nvidia.com/gpu.shared").MaxConcurrent)
+	require.Equal(t, 0, allocation.ForResource("nvidia.com/gpu").MaxConcurrent)
+}
+
+func TestCUDACompatPolicyRequiredCUDAVersion(t *testing.T) {
+	policy := &CUDACompatPolicy{
+		Images: []CUDACompatImage{
+			{Pattern: "nvcr.io/nvidia/pytorch:24.*", CUDAVersion: "12.4"},
+			{Pattern: "nvcr.io/nvidia/pytorch:24.10*", CUDAVersion: "12.6"},
+			{Pattern: "*/cuda:11.8*", CUDAVersion: "11.8"},
+		},
+	}
+
+	require.Equal(t, CUDAVersion("12.4"), policy.RequiredCUDAVersion([]string{"nvcr.io/nvidia/pytorch:24.03-py3"}))
+	require.Equal(t, CUDAVersion("12.6"), policy.RequiredCUDAVersion([]string{"nvcr.io/nvidia/pytorch:24.10-py3"}))
+	require.Equal(t, CUDAVersion("12.4"), policy.RequiredCUDAVersion([]string{"nvidia/cuda:11.8.0-base", "nvcr.io/nvidia/pytorch:24.03-py3"}))
+	require.Equal(t, CUDAVersion(""), policy.RequiredCUDAVersion([]string{"busybox"}))
+	require.Equal(t, DefaultCUDACompatPackagesDir, policy.GetPackagesDir())
+}
+
+func TestCUDAVersionCompare(t *testing.T) {
+	require.Zero(t, CUDAVersion("12.4").Compare("12.4"))
+	require.Positive(t, CUDAVersion("12.10").Compare("12.4"))
+	require.Negative(t, CUDAVersion("11.8").Compare("12.0"))
+}
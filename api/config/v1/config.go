@@ -17,6 +17,7 @@
 package v1
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -31,27 +32,61 @@ const Version = "v1"
 
 // Config is a versioned struct used to hold configuration information.
 type Config struct {
-	Version   string    `json:"version"             yaml:"version"`
-	Flags     Flags     `json:"flags,omitempty"     yaml:"flags,omitempty"`
-	Resources Resources `json:"resources,omitempty" yaml:"resources,omitempty"`
-	Sharing   Sharing   `json:"sharing,omitempty"   yaml:"sharing,omitempty"`
+	Version    string      `json:"version"              yaml:"version"`
+	Flags      Flags       `json:"flags,omitempty"      yaml:"flags,omitempty"`
+	Resources  Resources   `json:"resources,omitempty"  yaml:"resources,omitempty"`
+	Sharing    Sharing     `json:"sharing,omitempty"    yaml:"sharing,omitempty"`
+	Allocation *Allocation `json:"allocation,omitempty" yaml:"allocation,omitempty"`
+	Health     *Health     `json:"health,omitempty"     yaml:"health,omitempty"`
+	Devices    *Devices    `json:"devices,omitempty"    yaml:"devices,omitempty"`
+	// Profile selects a preset sharing config. It cannot be combined with
+	// the Sharing field.
+	Profile Profile `json:"profile,omitempty" yaml:"profile,omitempty"`
+	// Provenance records the sources that the values of the config were read from.
+	Provenance Provenance `json:"-" yaml:"-"`
 }
 
 // NewConfig builds out a Config struct from a config file (or command line flags).
 // The data stored in the config will be populated in order of precedence from
 // (1) command line, (2) environment variable, (3) config file.
 func NewConfig(c *cli.Context, flags []cli.Flag) (*Config, error) {
+	return NewConfigFromFile(c, flags, c.String("config-file"))
+}
+
+// NewConfigFromFile builds out a Config struct in the same way as NewConfig,
+// but reads the specified config file instead of the one set by the
+// 'config-file' flag. The config fragments set by the 'config-fragments' flag
+// are merged into the config file.
+func NewConfigFromFile(c *cli.Context, flags []cli.Flag, configFile string) (*Config, error) {
 	config := &Config{Version: Version}
+	provenance := make(Provenance)
 
-	if configFile := c.String("config-file"); configFile != "" {
+	var contents []byte
+	if configFile != "" {
 		var err error
-		config, err = parseConfig(configFile)
+		config, contents, err = parseConfig(configFile)
 		if err != nil {
 			return nil, fmt.Errorf("unable to parse config file: %v", err)
 		}
+		if err := provenance.addContents(contents); err != nil {
+			return nil, fmt.Errorf("unable to parse config file: %v", err)
+		}
+	}
+
+	if fragments := c.StringSlice("config-fragments"); len(fragments) > 0 {
+		merged, err := mergeFragments(contents, fragments, provenance)
+		if err != nil {
+			return nil, fmt.Errorf("unable to merge config fragments: %v", err)
+		}
+		config, err = parseConfigFrom(bytes.NewReader(merged))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse config with fragments: %v", err)
+		}
 	}
 
 	config.Flags.UpdateFromCLIFlags(c, flags)
+	provenance.addFlags(c, flags)
+	config.Provenance = provenance
 
 	// We explicitly set sharing.mps.failRequestsGreaterThanOne = true
 	// This can be relaxed in certain cases -- such as a single GPU -- but
@@ -80,26 +115,32 @@ func DisableResourceNamingInConfig(logger logger, config *Config) {
 	config.Resources.GPUs = nil
 	config.Resources.MIGs = nil
 
+	split := config.Sharing.splitResources()
 	// Disable renaming / device selection in Sharing.TimeSlicing.Resources
-	config.Sharing.TimeSlicing.disableResoureRenaming(logger, "timeSlicing")
+	config.Sharing.TimeSlicing.disableResoureRenaming(logger, "timeSlicing", split)
 	// Disable renaming / device selection in Sharing.MPS.Resources
-	config.Sharing.MPS.disableResoureRenaming(logger, "mps")
+	config.Sharing.MPS.disableResoureRenaming(logger, "mps", split)
 }
 
 // parseConfig parses a config file as either YAML of JSON and unmarshals it into a Config struct.
-func parseConfig(configFile string) (*Config, error) {
-	reader, err := os.Open(configFile)
+// The contents of the file are also returned.
+func parseConfig(configFile string) (*Config, []byte, error) {
+	contents, err := os.ReadFile(configFile)
 	if err != nil {
-		return nil, fmt.Errorf("error opening config file: %v", err)
+		return nil, nil, fmt.Errorf("error opening config file: %v", err)
 	}
-	defer reader.Close()
 
-	config, err := parseConfigFrom(reader)
+	config, err := parseConfigFrom(bytes.NewReader(contents))
 	if err != nil {
-		return nil, fmt.Errorf("error parsing config file: %v", err)
+		return nil, nil, fmt.Errorf("error parsing config file: %v", err)
 	}
 
-	return config, nil
+	return config, contents, nil
+}
+
+// ParseConfigFrom parses and validates a config read from the specified reader.
+func ParseConfigFrom(reader io.Reader) (*Config, error) {
+	return parseConfigFrom(reader)
 }
 
 func parseConfigFrom(reader io.Reader) (*Config, error) {
@@ -125,5 +166,13 @@ func parseConfigFrom(reader io.Reader) (*Config, error) {
 		return nil, fmt.Errorf("unknown version: %v", config.Version)
 	}
 
+	if err := config.expandProfile(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Sharing.validate(); err != nil {
+		return nil, fmt.Errorf("invalid sharing config: %v", err)
+	}
+
 	return &config, nil
 }
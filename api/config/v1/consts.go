@@ -22,9 +22,10 @@ import (
 
 // Constants related to resource names
 const (
-	ResourceNamePrefix              = "nvidia.com"
-	DefaultSharedResourceNameSuffix = ".shared"
-	MaxResourceNameLength           = 63
+	ResourceNamePrefix               = "nvidia.com"
+	DefaultSharedResourceNameSuffix  = ".shared"
+	DefaultFullGPUResourceNameSuffix = ".full"
+	MaxResourceNameLength            = 63
 )
 
 // Constants representing the various MIG strategies
@@ -48,9 +49,24 @@ const (
 	DeviceIDStrategyIndex = "index"
 )
 
+// Constants to represent the modes of the NVIDIA Container Runtime
+const (
+	ContainerRuntimeModeAuto   = "auto"
+	ContainerRuntimeModeLegacy = "legacy"
+	ContainerRuntimeModeCSV    = "csv"
+)
+
 // Constants related to generating CDI specifications
 const (
 	DefaultCDIAnnotationPrefix = cdiapi.AnnotationPrefix
 	DefaultNvidiaCTKPath       = "/usr/bin/nvidia-ctk"
 	DefaultContainerDriverRoot = "/driver-root"
+	DefaultCDISpecDir          = "/var/run/cdi"
+)
+
+// Constants related to MPS
+const (
+	// DefaultMpsContainerRoot is the path at which the MPS root on the host is
+	// mounted in the containers of the device plugin and MPS control daemon.
+	DefaultMpsContainerRoot = "/mps"
 )
@@ -0,0 +1,143 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCUDACompatPackagesDir is the directory containing the CUDA forward
+// compatibility packages of several CUDA versions if no directory is
+// configured. The cuda-compat packages install to cuda-<version>/compat
+// subdirectories of this directory.
+const DefaultCUDACompatPackagesDir = "/usr/local"
+
+// CUDAVersionAnnotation is the pod annotation that declares the CUDA version
+// required by the containers of the pod. It takes precedence over the images
+// of a CUDACompatPolicy.
+const CUDAVersionAnnotation = "nvidia.com/cuda-version"
+
+// CUDAVersion is a CUDA version of the form <major>.<minor>.
+type CUDAVersion string
+
+// CUDACompatPolicy selects the CUDA forward compatibility package that is
+// mounted into the containers of a pod based on the CUDA version that the pod
+// requires.
+type CUDACompatPolicy struct {
+	// PackagesDir is the directory on the host containing the CUDA forward
+	// compatibility packages in cuda-<major>.<minor>/compat subdirectories.
+	PackagesDir string `json:"packagesDir,omitempty" yaml:"packagesDir,omitempty"`
+	// Images declares the CUDA version required by the container images that
+	// match each pattern.
+	Images []CUDACompatImage `json:"images,omitempty"      yaml:"images,omitempty"`
+}
+
+// CUDACompatImage declares the CUDA version required by the container images
+// matching a pattern such as "nvcr.io/nvidia/pytorch:24.*".
+type CUDACompatImage struct {
+	Pattern     string      `json:"pattern"     yaml:"pattern"`
+	CUDAVersion CUDAVersion `json:"cudaVersion" yaml:"cudaVersion"`
+}
+
+// GetPackagesDir returns the directory containing the CUDA forward
+// compatibility packages.
+func (p *CUDACompatPolicy) GetPackagesDir() string {
+	if p == nil || p.PackagesDir == "" {
+		return DefaultCUDACompatPackagesDir
+	}
+	return p.PackagesDir
+}
+
+// RequiredCUDAVersion returns the newest CUDA version declared for any of the
+// specified images, or an empty version if none of the images match.
+func (p *CUDACompatPolicy) RequiredCUDAVersion(images []string) CUDAVersion {
+	if p == nil {
+		return ""
+	}
+	var required CUDAVersion
+	for _, image := range images {
+		for _, i := range p.Images {
+			if !ResourcePattern(i.Pattern).Matches(image) {
+				continue
+			}
+			if required == "" || i.CUDAVersion.Compare(required) > 0 {
+				required = i.CUDAVersion
+			}
+		}
+	}
+	return required
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'CUDACompatImage' struct.
+func (i *CUDACompatImage) UnmarshalJSON(b []byte) error {
+	type cudaCompatImage CUDACompatImage
+	if err := json.Unmarshal(b, (*cudaCompatImage)(i)); err != nil {
+		return err
+	}
+	if i.Pattern == "" {
+		return fmt.Errorf("no image pattern specified")
+	}
+	if i.CUDAVersion == "" {
+		return fmt.Errorf("no CUDA version specified for image pattern %q", i.Pattern)
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'CUDAVersion' type.
+func (v *CUDAVersion) UnmarshalJSON(b []byte) error {
+	var raw string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, _, err := CUDAVersion(raw).Parse(); err != nil {
+		return err
+	}
+	*v = CUDAVersion(raw)
+	return nil
+}
+
+// Compare compares two CUDA versions, returning a negative value if v is
+// older than other, a positive value if v is newer than other, and 0
+// otherwise. Versions that cannot be parsed are treated as 0.0.
+func (v CUDAVersion) Compare(other CUDAVersion) int {
+	major, minor, _ := v.Parse()
+	otherMajor, otherMinor, _ := other.Parse()
+	if major != otherMajor {
+		return major - otherMajor
+	}
+	return minor - otherMinor
+}
+
+// Parse returns the major and minor versions of the CUDA version.
+func (v CUDAVersion) Parse() (int, int, error) {
+	parts := strings.Split(string(v), ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("CUDA version %q must be of the form <major>.<minor>", v)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return 0, 0, fmt.Errorf("invalid major version in CUDA version %q", v)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return 0, 0, fmt.Errorf("invalid minor version in CUDA version %q", v)
+	}
+	return major, minor, nil
+}
@@ -0,0 +1,427 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ComputeCapability is a CUDA compute capability of the form <major>.<minor>.
+type ComputeCapability string
+
+// DriverVersion is a version of the NVIDIA driver, e.g. 550.54.15, or of the
+// CUDA version supported by the driver, e.g. 12.4.
+type DriverVersion string
+
+// Devices defines options that control which devices are advertised by the plugin.
+type Devices struct {
+	// ComputeCapability gates resources on a minimum CUDA compute capability.
+	ComputeCapability []ComputeCapabilityGate `json:"computeCapability,omitempty" yaml:"computeCapability,omitempty"`
+	// BAR1Memory gates resources on a minimum BAR1 memory size.
+	BAR1Memory []BAR1MemoryGate `json:"bar1Memory,omitempty"        yaml:"bar1Memory,omitempty"`
+	// DriverVersion gates resources on a minimum driver or CUDA version.
+	DriverVersion []DriverVersionGate `json:"driverVersion,omitempty"     yaml:"driverVersion,omitempty"`
+	// Include restricts the GPUs that are considered to the ones that match
+	// at least one of the filters. If empty, all GPUs are included.
+	Include []DeviceFilter `json:"include,omitempty"           yaml:"include,omitempty"`
+	// Exclude skips the GPUs that match any of the filters. Exclusion takes
+	// precedence over inclusion.
+	Exclude []DeviceFilter `json:"exclude,omitempty"           yaml:"exclude,omitempty"`
+}
+
+// DeviceFilter selects a GPU by its UUID, its index, its PCI bus ID, or a
+// glob of its model name such as "*A100*".
+type DeviceFilter string
+
+// DeviceIdentifiers holds the identifiers of a GPU that device filters are
+// matched against.
+type DeviceIdentifiers struct {
+	Index    int
+	UUID     string
+	PCIBusID string
+	Name     string
+}
+
+// ComputeCapabilityGate defines the minimum compute capability of the devices
+// that are advertised as a resource.
+type ComputeCapabilityGate struct {
+	Resource ResourceName      `json:"resource"         yaml:"resource"`
+	Min      ComputeCapability `json:"min"              yaml:"min"`
+	// Rename is the resource that devices below the minimum are advertised as.
+	// If unset, devices below the minimum are not advertised.
+	Rename ResourceName `json:"rename,omitempty" yaml:"rename,omitempty"`
+}
+
+// BAR1MemoryGate defines the minimum BAR1 memory size of the devices that are
+// advertised as a resource.
+type BAR1MemoryGate struct {
+	Resource ResourceName `json:"resource"         yaml:"resource"`
+	MinMB    uint64       `json:"minMB"            yaml:"minMB"`
+	// Rename is the resource that devices below the minimum are advertised as.
+	// If unset, devices below the minimum are not advertised.
+	Rename ResourceName `json:"rename,omitempty" yaml:"rename,omitempty"`
+}
+
+// DriverVersionGate defines the minimum driver and CUDA versions of the node
+// for the devices to be advertised as a resource. Since the driver is shared
+// by all devices on the node, either all or none of the devices of the
+// resource satisfy the gate.
+type DriverVersionGate struct {
+	Resource  ResourceName  `json:"resource"            yaml:"resource"`
+	MinDriver DriverVersion `json:"minDriver,omitempty" yaml:"minDriver,omitempty"`
+	MinCUDA   DriverVersion `json:"minCUDA,omitempty"   yaml:"minCUDA,omitempty"`
+	// Rename is the resource that the devices are advertised as if the
+	// driver does not satisfy the gate. If unset, the devices are not
+	// advertised.
+	Rename ResourceName `json:"rename,omitempty"    yaml:"rename,omitempty"`
+}
+
+// ComputeCapabilityGates returns the compute capability gates for all resources.
+func (d *Devices) ComputeCapabilityGates() []ComputeCapabilityGate {
+	if d == nil {
+		return nil
+	}
+	return d.ComputeCapability
+}
+
+// BAR1MemoryGates returns the BAR1 memory gates for all resources.
+func (d *Devices) BAR1MemoryGates() []BAR1MemoryGate {
+	if d == nil {
+		return nil
+	}
+	return d.BAR1Memory
+}
+
+// DriverVersionGates returns the driver version gates for all resources.
+func (d *Devices) DriverVersionGates() []DriverVersionGate {
+	if d == nil {
+		return nil
+	}
+	return d.DriverVersion
+}
+
+// HasFilters checks whether any include or exclude filters are configured.
+func (d *Devices) HasFilters() bool {
+	if d == nil {
+		return false
+	}
+	return len(d.Include) > 0 || len(d.Exclude) > 0
+}
+
+// Selects checks whether the GPU with the specified identifiers passes the
+// include and exclude filters.
+func (d *Devices) Selects(id DeviceIdentifiers) bool {
+	if !d.HasFilters() {
+		return true
+	}
+	for _, f := range d.Exclude {
+		if f.Matches(id) {
+			return false
+		}
+	}
+	if len(d.Include) == 0 {
+		return true
+	}
+	for _, f := range d.Include {
+		if f.Matches(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches checks whether the filter selects the GPU with the specified
+// identifiers. Filters consisting only of digits are matched against the
+// index, filters prefixed with "GPU-" against the UUID, and filters containing
+// a ':' against the PCI bus ID. All other filters are matched against the
+// model name.
+func (f DeviceFilter) Matches(id DeviceIdentifiers) bool {
+	s := string(f)
+	switch {
+	case isDigits(s):
+		index, err := strconv.Atoi(s)
+		return err == nil && index == id.Index
+	case strings.HasPrefix(s, "GPU-"):
+		return strings.EqualFold(s, id.UUID)
+	case strings.Contains(s, ":"):
+		return id.PCIBusID != "" && normalizePCIBusID(s) == normalizePCIBusID(id.PCIBusID)
+	default:
+		return ResourcePattern(s).Matches(id.Name)
+	}
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'DeviceFilter' type.
+func (f *DeviceFilter) UnmarshalJSON(b []byte) error {
+	var raw string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return fmt.Errorf("empty device filter")
+	}
+	if strings.Contains(raw, ":") && !isDigits(raw) && !strings.HasPrefix(raw, "GPU-") {
+		if _, err := parsePCIBusID(raw); err != nil {
+			return err
+		}
+	}
+	*f = DeviceFilter(raw)
+	return nil
+}
+
+// normalizePCIBusID returns the PCI bus ID in the canonical
+// <domain>:<bus>:<device>.<function> form with a 32-bit domain, as reported
+// by NVML. IDs that cannot be parsed are returned lowercased.
+func normalizePCIBusID(s string) string {
+	normalized, err := parsePCIBusID(s)
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return normalized
+}
+
+// parsePCIBusID parses a PCI bus ID with an optional domain.
+func parsePCIBusID(s string) (string, error) {
+	parts := strings.Split(strings.ToLower(s), ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+	if len(parts) != 3 {
+		return "", fmt.Errorf("PCI bus ID %q must be of the form [<domain>:]<bus>:<device>.<function>", s)
+	}
+	deviceFunction := strings.Split(parts[2], ".")
+	if len(deviceFunction) != 2 {
+		return "", fmt.Errorf("PCI bus ID %q must be of the form [<domain>:]<bus>:<device>.<function>", s)
+	}
+	var values [4]uint64
+	for i, part := range []string{parts[0], parts[1], deviceFunction[0], deviceFunction[1]} {
+		v, err := strconv.ParseUint(part, 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid PCI bus ID %q: %v", s, err)
+		}
+		values[i] = v
+	}
+	return fmt.Sprintf("%08x:%02x:%02x.%x", values[0], values[1], values[2], values[3]), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'Devices' struct.
+func (d *Devices) UnmarshalJSON(b []byte) error {
+	type devices Devices
+	if err := json.Unmarshal(b, (*devices)(d)); err != nil {
+		return err
+	}
+	seen := make(map[ResourceName]bool)
+	for _, g := range d.ComputeCapability {
+		if seen[g.Resource] {
+			return fmt.Errorf("duplicate compute capability gate for resource %q", g.Resource)
+		}
+		seen[g.Resource] = true
+	}
+	seen = make(map[ResourceName]bool)
+	for _, g := range d.BAR1Memory {
+		if seen[g.Resource] {
+			return fmt.Errorf("duplicate BAR1 memory gate for resource %q", g.Resource)
+		}
+		seen[g.Resource] = true
+	}
+	seen = make(map[ResourceName]bool)
+	for _, g := range d.DriverVersion {
+		if seen[g.Resource] {
+			return fmt.Errorf("duplicate driver version gate for resource %q", g.Resource)
+		}
+		seen[g.Resource] = true
+	}
+	for _, f := range d.Include {
+		for _, e := range d.Exclude {
+			if f == e {
+				return fmt.Errorf("device filter %q is both included and excluded", f)
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'ComputeCapabilityGate' struct.
+func (g *ComputeCapabilityGate) UnmarshalJSON(b []byte) error {
+	type computeCapabilityGate ComputeCapabilityGate
+	if err := json.Unmarshal(b, (*computeCapabilityGate)(g)); err != nil {
+		return err
+	}
+	if g.Resource == "" {
+		return fmt.Errorf("no resource name specified")
+	}
+	if g.Min == "" {
+		return fmt.Errorf("no minimum compute capability specified for resource %q", g.Resource)
+	}
+	if g.Rename == g.Resource {
+		return fmt.Errorf("resource %q cannot be renamed to itself", g.Resource)
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'BAR1MemoryGate' struct.
+func (g *BAR1MemoryGate) UnmarshalJSON(b []byte) error {
+	type bar1MemoryGate BAR1MemoryGate
+	if err := json.Unmarshal(b, (*bar1MemoryGate)(g)); err != nil {
+		return err
+	}
+	if g.Resource == "" {
+		return fmt.Errorf("no resource name specified")
+	}
+	if g.MinMB == 0 {
+		return fmt.Errorf("no minimum BAR1 memory specified for resource %q", g.Resource)
+	}
+	if g.Rename == g.Resource {
+		return fmt.Errorf("resource %q cannot be renamed to itself", g.Resource)
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'DriverVersionGate' struct.
+func (g *DriverVersionGate) UnmarshalJSON(b []byte) error {
+	type driverVersionGate DriverVersionGate
+	if err := json.Unmarshal(b, (*driverVersionGate)(g)); err != nil {
+		return err
+	}
+	if g.Resource == "" {
+		return fmt.Errorf("no resource name specified")
+	}
+	if g.MinDriver == "" && g.MinCUDA == "" {
+		return fmt.Errorf("no minimum driver or CUDA version specified for resource %q", g.Resource)
+	}
+	if g.Rename == g.Resource {
+		return fmt.Errorf("resource %q cannot be renamed to itself", g.Resource)
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'DriverVersion' type.
+func (v *DriverVersion) UnmarshalJSON(b []byte) error {
+	var raw string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, err := DriverVersion(raw).parse(); err != nil {
+		return err
+	}
+	*v = DriverVersion(raw)
+	return nil
+}
+
+// IsSatisfiedBy checks whether the specified version is at least the version
+// v. Missing components are treated as 0, so 12 is satisfied by 12.0.
+func (v DriverVersion) IsSatisfiedBy(version string) (bool, error) {
+	minimum, err := v.parse()
+	if err != nil {
+		return false, err
+	}
+	actual, err := DriverVersion(version).parse()
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < max(len(minimum), len(actual)); i++ {
+		var m, a int
+		if i < len(minimum) {
+			m = minimum[i]
+		}
+		if i < len(actual) {
+			a = actual[i]
+		}
+		if a != m {
+			return a > m, nil
+		}
+	}
+	return true, nil
+}
+
+// parse returns the numeric components of the version.
+func (v DriverVersion) parse() ([]int, error) {
+	if v == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+	var components []int
+	for _, part := range strings.Split(string(v), ".") {
+		c, err := strconv.Atoi(part)
+		if err != nil || c < 0 {
+			return nil, fmt.Errorf("version %q must be of the form <major>[.<minor>[.<patch>]]", v)
+		}
+		components = append(components, c)
+	}
+	return components, nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'ComputeCapability' type.
+func (c *ComputeCapability) UnmarshalJSON(b []byte) error {
+	var raw string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if _, _, err := ComputeCapability(raw).parse(); err != nil {
+		return err
+	}
+	*c = ComputeCapability(raw)
+	return nil
+}
+
+// IsSatisfiedBy checks whether the specified compute capability is at least
+// the compute capability c.
+func (c ComputeCapability) IsSatisfiedBy(computeCapability string) (bool, error) {
+	minMajor, minMinor, err := c.parse()
+	if err != nil {
+		return false, err
+	}
+	major, minor, err := ComputeCapability(computeCapability).parse()
+	if err != nil {
+		return false, err
+	}
+	if major != minMajor {
+		return major > minMajor, nil
+	}
+	return minor >= minMinor, nil
+}
+
+// parse returns the major and minor versions of the compute capability.
+func (c ComputeCapability) parse() (int, int, error) {
+	parts := strings.Split(string(c), ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("compute capability %q must be of the form <major>.<minor>", c)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return 0, 0, fmt.Errorf("invalid major version in compute capability %q", c)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return 0, 0, fmt.Errorf("invalid minor version in compute capability %q", c)
+	}
+	return major, minor, nil
+}
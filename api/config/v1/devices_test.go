@@ -0,0 +1,358 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevicesConfig(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		expected    *Devices
+		expectedErr bool
+	}{
+		{
+			description: "gates are parsed",
+			input: `
+version: v1
+devices:
+  computeCapability:
+  - resource: nvidia.com/gpu
+    min: "8.0"
+    rename: nvidia.com/gpu-legacy
+  - resource: nvidia.com/gpu.shared
+    min: "7.5"
+`,
+			expected: &Devices{
+				ComputeCapability: []ComputeCapabilityGate{
+					{Resource: "nvidia.com/gpu", Min: "8.0", Rename: "nvidia.com/gpu-legacy"},
+					{Resource: "nvidia.com/gpu.shared", Min: "7.5"},
+				},
+			},
+		},
+		{
+			description: "BAR1 memory gates are parsed",
+			input: `
+version: v1
+devices:
+  bar1Memory:
+  - resource: nvidia.com/gpu
+    minMB: 65536
+    rename: nvidia.com/gpu-small-bar1
+`,
+			expected: &Devices{
+				BAR1Memory: []BAR1MemoryGate{
+					{Resource: "nvidia.com/gpu", MinMB: 65536, Rename: "nvidia.com/gpu-small-bar1"},
+				},
+			},
+		},
+		{
+			description: "driver version gates are parsed",
+			input: `
+version: v1
+devices:
+  driverVersion:
+  - resource: nvidia.com/gpu
+    minDriver: "550.54.15"
+    rename: nvidia.com/gpu-old-driver
+  - resource: nvidia.com/gpu.shared
+    minCUDA: "12.4"
+`,
+			expected: &Devices{
+				DriverVersion: []DriverVersionGate{
+					{Resource: "nvidia.com/gpu", MinDriver: "550.54.15", Rename: "nvidia.com/gpu-old-driver"},
+					{Resource: "nvidia.com/gpu.shared", MinCUDA: "12.4"},
+				},
+			},
+		},
+		{
+			description: "driver version gate without a minimum is rejected",
+			input: `
+version: v1
+devices:
+  driverVersion:
+  - resource: nvidia.com/gpu
+    rename: nvidia.com/gpu-old-driver
+`,
+			expectedErr: true,
+		},
+		{
+			description: "invalid driver version is rejected",
+			input: `
+version: v1
+devices:
+  driverVersion:
+  - resource: nvidia.com/gpu
+    minDriver: "550.x"
+`,
+			expectedErr: true,
+		},
+		{
+			description: "device filters are parsed",
+			input: `
+version: v1
+devices:
+  include:
+  - "*A100*"
+  - "0000:3b:00.0"
+  exclude:
+  - "2"
+  - GPU-8a2b9ae5-4b8e-1f6a-0b3c-b4a6e7b1c2d3
+`,
+			expected: &Devices{
+				Include: []DeviceFilter{"*A100*", "0000:3b:00.0"},
+				Exclude: []DeviceFilter{"2", "GPU-8a2b9ae5-4b8e-1f6a-0b3c-b4a6e7b1c2d3"},
+			},
+		},
+		{
+			description: "empty device filter is invalid",
+			input: `
+version: v1
+devices:
+  exclude:
+  - ""
+`,
+			expectedErr: true,
+		},
+		{
+			description: "malformed PCI bus ID is invalid",
+			input: `
+version: v1
+devices:
+  exclude:
+  - "3b:00"
+`,
+			expectedErr: true,
+		},
+		{
+			description: "device filter that is both included and excluded is invalid",
+			input: `
+version: v1
+devices:
+  include:
+  - "1"
+  exclude:
+  - "1"
+`,
+			expectedErr: true,
+		},
+		{
+			description: "missing minimum BAR1 memory is invalid",
+			input: `
+version: v1
+devices:
+  bar1Memory:
+  - resource: nvidia.com/gpu
+`,
+			expectedErr: true,
+		},
+		{
+			description: "duplicate BAR1 memory gates are invalid",
+			input: `
+version: v1
+devices:
+  bar1Memory:
+  - resource: nvidia.com/gpu
+    minMB: 65536
+  - resource: nvidia.com/gpu
+    minMB: 1024
+`,
+			expectedErr: true,
+		},
+		{
+			description: "missing minimum is invalid",
+			input: `
+version: v1
+devices:
+  computeCapability:
+  - resource: nvidia.com/gpu
+`,
+			expectedErr: true,
+		},
+		{
+			description: "malformed minimum is invalid",
+			input: `
+version: v1
+devices:
+  computeCapability:
+  - resource: nvidia.com/gpu
+    min: "8"
+`,
+			expectedErr: true,
+		},
+		{
+			description: "renaming to the same resource is invalid",
+			input: `
+version: v1
+devices:
+  computeCapability:
+  - resource: nvidia.com/gpu
+    min: "8.0"
+    rename: nvidia.com/gpu
+`,
+			expectedErr: true,
+		},
+		{
+			description: "duplicate resources are invalid",
+			input: `
+version: v1
+devices:
+  computeCapability:
+  - resource: nvidia.com/gpu
+    min: "8.0"
+  - resource: nvidia.com/gpu
+    min: "7.0"
+`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, config.Devices)
+		})
+	}
+}
+
+func TestComputeCapabilityIsSatisfiedBy(t *testing.T) {
+	testCases := []struct {
+		minimum           ComputeCapability
+		computeCapability string
+		expected          bool
+	}{
+		{"8.0", "8.0", true},
+		{"8.0", "8.6", true},
+		{"8.0", "9.0", true},
+		{"8.0", "7.5", false},
+		{"8.6", "8.0", false},
+		{"7.5", "10.0", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.minimum)+"/"+tc.computeCapability, func(t *testing.T) {
+			satisfied, err := tc.minimum.IsSatisfiedBy(tc.computeCapability)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, satisfied)
+		})
+	}
+
+	_, err := ComputeCapability("8.0").IsSatisfiedBy("")
+	require.Error(t, err)
+}
+
+func TestDriverVersionIsSatisfiedBy(t *testing.T) {
+	testCases := []struct {
+		minimum  DriverVersion
+		version  string
+		expected bool
+	}{
+		{"550.54.15", "550.54.15", true},
+		{"550.54.15", "550.54.14", false},
+		{"550.54.15", "550.90.07", true},
+		{"550.54.15", "560.28.03", true},
+		{"550", "550.54.15", true},
+		{"12.4", "12.2", false},
+		{"12.4", "12.10", true},
+		{"12", "12.0", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.minimum)+"/"+tc.version, func(t *testing.T) {
+			satisfied, err := tc.minimum.IsSatisfiedBy(tc.version)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, satisfied)
+		})
+	}
+
+	_, err := DriverVersion("550").IsSatisfiedBy("")
+	require.Error(t, err)
+}
+
+func TestDevicesSelects(t *testing.T) {
+	gpu := DeviceIdentifiers{
+		Index:    1,
+		UUID:     "GPU-8a2b9ae5-4b8e-1f6a-0b3c-b4a6e7b1c2d3",
+		PCIBusID: "00000000:3B:00.0",
+		Name:     "NVIDIA A100-SXM4-40GB",
+	}
+
+	testCases := []struct {
+		description string
+		devices     *Devices
+		expected    bool
+	}{
+		{
+			description: "no filters select all GPUs",
+			expected:    true,
+		},
+		{
+			description: "included by index",
+			devices:     &Devices{Include: []DeviceFilter{"1"}},
+			expected:    true,
+		},
+		{
+			description: "not included by another index",
+			devices:     &Devices{Include: []DeviceFilter{"0"}},
+			expected:    false,
+		},
+		{
+			description: "excluded by UUID",
+			devices:     &Devices{Exclude: []DeviceFilter{"GPU-8A2B9AE5-4B8E-1F6A-0B3C-B4A6E7B1C2D3"}},
+			expected:    false,
+		},
+		{
+			description: "included by short PCI bus ID",
+			devices:     &Devices{Include: []DeviceFilter{"3b:00.0"}},
+			expected:    true,
+		},
+		{
+			description: "excluded by PCI bus ID with a 16-bit domain",
+			devices:     &Devices{Exclude: []DeviceFilter{"0000:3b:00.0"}},
+			expected:    false,
+		},
+		{
+			description: "included by model name glob",
+			devices:     &Devices{Include: []DeviceFilter{"*A100*"}},
+			expected:    true,
+		},
+		{
+			description: "exclusion takes precedence over inclusion",
+			devices: &Devices{
+				Include: []DeviceFilter{"*A100*"},
+				Exclude: []DeviceFilter{"1"},
+			},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.devices.Selects(gpu))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FeatureGates maps the names of feature gates to whether they are enabled.
+// It is specified as a comma-separated list of <name>=<bool> pairs on the
+// command line and as either such a list or a map in the config file.
+type FeatureGates map[string]bool
+
+// ParseFeatureGates parses a comma-separated list of <name>=<bool> pairs.
+func ParseFeatureGates(value string) (FeatureGates, error) {
+	gates := make(FeatureGates)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, enabled, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid feature gate %q: expected <name>=<bool>", pair)
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(enabled))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for feature gate %q: %w", name, err)
+		}
+		gates[name] = b
+	}
+	return gates, nil
+}
+
+// Set implements the cli.Generic interface. The feature gates parsed from the
+// value are added to the existing gates.
+func (g *FeatureGates) Set(value string) error {
+	gates, err := ParseFeatureGates(value)
+	if err != nil {
+		return err
+	}
+	if *g == nil {
+		*g = make(FeatureGates)
+	}
+	for name, enabled := range gates {
+		(*g)[name] = enabled
+	}
+	return nil
+}
+
+// String returns the feature gates as a comma-separated list of <name>=<bool>
+// pairs sorted by name.
+func (g *FeatureGates) String() string {
+	if g == nil {
+		return ""
+	}
+	var names []string
+	for name := range *g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, (*g)[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// UnmarshalJSON unmarshals either a map or a comma-separated list of
+// <name>=<bool> pairs into a 'FeatureGates' map.
+func (g *FeatureGates) UnmarshalJSON(b []byte) error {
+	var list string
+	if err := json.Unmarshal(b, &list); err == nil {
+		gates, err := ParseFeatureGates(list)
+		if err != nil {
+			return err
+		}
+		*g = gates
+		return nil
+	}
+
+	var gates map[string]bool
+	if err := json.Unmarshal(b, &gates); err != nil {
+		return fmt.Errorf("invalid featureGates: %v", string(b))
+	}
+	*g = gates
+	return nil
+}
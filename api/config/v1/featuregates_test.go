@@ -0,0 +1,127 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeatureGates(t *testing.T) {
+	testCases := []struct {
+		description   string
+		input         string
+		expected      FeatureGates
+		expectedError bool
+	}{
+		{
+			description: "empty string",
+			input:       "",
+			expected:    FeatureGates{},
+		},
+		{
+			description: "multiple gates",
+			input:       "MPSMIG=true, DRA=false",
+			expected:    FeatureGates{"MPSMIG": true, "DRA": false},
+		},
+		{
+			description: "last value wins",
+			input:       "DRA=false,DRA=true",
+			expected:    FeatureGates{"DRA": true},
+		},
+		{
+			description:   "missing value",
+			input:         "DRA",
+			expectedError: true,
+		},
+		{
+			description:   "missing name",
+			input:         "=true",
+			expectedError: true,
+		},
+		{
+			description:   "invalid value",
+			input:         "DRA=maybe",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			gates, err := ParseFeatureGates(tc.input)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expected, gates)
+		})
+	}
+}
+
+func TestUnmarshalFeatureGates(t *testing.T) {
+	testCases := []struct {
+		description   string
+		input         string
+		expected      FeatureGates
+		expectedError bool
+	}{
+		{
+			description: "map",
+			input:       `{"MPSMIG": true, "DRA": false}`,
+			expected:    FeatureGates{"MPSMIG": true, "DRA": false},
+		},
+		{
+			description: "list",
+			input:       `"MPSMIG=true,DRA=false"`,
+			expected:    FeatureGates{"MPSMIG": true, "DRA": false},
+		},
+		{
+			description:   "invalid list",
+			input:         `"MPSMIG"`,
+			expectedError: true,
+		},
+		{
+			description:   "invalid type",
+			input:         `["MPSMIG=true"]`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var gates FeatureGates
+			err := json.Unmarshal([]byte(tc.input), &gates)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expected, gates)
+		})
+	}
+}
+
+func TestFeatureGatesSet(t *testing.T) {
+	var gates FeatureGates
+	require.NoError(t, gates.Set("MPSMIG=true"))
+	require.NoError(t, gates.Set("DRA=false"))
+	require.Equal(t, "DRA=false,MPSMIG=true", gates.String())
+	require.Error(t, gates.Set("DRA"))
+}
@@ -19,6 +19,7 @@ package v1
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	cli "github.com/urfave/cli/v2"
 )
@@ -40,6 +41,14 @@ func updateFromCLIFlag[T any](pflag **T, c *cli.Context, flagName string) {
 			*flag = ptr(c.Bool(flagName))
 		case **Duration:
 			*flag = ptr(Duration(c.Duration(flagName)))
+		case **FeatureGates:
+			gates := make(FeatureGates)
+			if value, ok := c.Generic(flagName).(*FeatureGates); ok && value != nil {
+				for name, enabled := range *value {
+					gates[name] = enabled
+				}
+			}
+			*flag = &gates
 		case **deviceListStrategyFlag:
 			*flag = ptr((deviceListStrategyFlag)(c.StringSlice(flagName)))
 		default:
@@ -55,26 +64,126 @@ type Flags struct {
 
 // CommandLineFlags holds the list of command line flags used to configure the device plugin and GFD.
 type CommandLineFlags struct {
-	MigStrategy       *string                 `json:"migStrategy"                yaml:"migStrategy"`
-	FailOnInitError   *bool                   `json:"failOnInitError"            yaml:"failOnInitError"`
-	MpsRoot           *string                 `json:"mpsRoot,omitempty"          yaml:"mpsRoot,omitempty"`
-	NvidiaDriverRoot  *string                 `json:"nvidiaDriverRoot,omitempty" yaml:"nvidiaDriverRoot,omitempty"`
-	GDSEnabled        *bool                   `json:"gdsEnabled"                 yaml:"gdsEnabled"`
-	MOFEDEnabled      *bool                   `json:"mofedEnabled"               yaml:"mofedEnabled"`
-	UseNodeFeatureAPI *bool                   `json:"useNodeFeatureAPI"          yaml:"useNodeFeatureAPI"`
-	Mode              *string                 `json:"mode"                       yaml:"mode"`
-	Plugin            *PluginCommandLineFlags `json:"plugin,omitempty"           yaml:"plugin,omitempty"`
-	GFD               *GFDCommandLineFlags    `json:"gfd,omitempty"              yaml:"gfd,omitempty"`
+	MigStrategy        *string                 `json:"migStrategy"                  yaml:"migStrategy"`
+	FailOnInitError    *bool                   `json:"failOnInitError"              yaml:"failOnInitError"`
+	MpsRoot            *string                 `json:"mpsRoot,omitempty"            yaml:"mpsRoot,omitempty"`
+	MpsContainerRoot   *string                 `json:"mpsContainerRoot,omitempty"   yaml:"mpsContainerRoot,omitempty"`
+	NvidiaDriverRoot   *string                 `json:"nvidiaDriverRoot,omitempty"   yaml:"nvidiaDriverRoot,omitempty"`
+	GDSEnabled         *bool                   `json:"gdsEnabled"                   yaml:"gdsEnabled"`
+	MOFEDEnabled       *bool                   `json:"mofedEnabled"                 yaml:"mofedEnabled"`
+	UseNodeFeatureAPI  *bool                   `json:"useNodeFeatureAPI"            yaml:"useNodeFeatureAPI"`
+	Mode               *string                 `json:"mode"                         yaml:"mode"`
+	DeviceLocationFile *string                 `json:"deviceLocationFile,omitempty" yaml:"deviceLocationFile,omitempty"`
+	Plugin             *PluginCommandLineFlags `json:"plugin,omitempty"             yaml:"plugin,omitempty"`
+	GFD                *GFDCommandLineFlags    `json:"gfd,omitempty"                yaml:"gfd,omitempty"`
 }
 
 // PluginCommandLineFlags holds the list of command line flags specific to the device plugin.
 type PluginCommandLineFlags struct {
-	PassDeviceSpecs     *bool                   `json:"passDeviceSpecs"     yaml:"passDeviceSpecs"`
-	DeviceListStrategy  *deviceListStrategyFlag `json:"deviceListStrategy"  yaml:"deviceListStrategy"`
-	DeviceIDStrategy    *string                 `json:"deviceIDStrategy"    yaml:"deviceIDStrategy"`
-	CDIAnnotationPrefix *string                 `json:"cdiAnnotationPrefix" yaml:"cdiAnnotationPrefix"`
-	NvidiaCTKPath       *string                 `json:"nvidiaCTKPath"       yaml:"nvidiaCTKPath"`
-	ContainerDriverRoot *string                 `json:"containerDriverRoot" yaml:"containerDriverRoot"`
+	PassDeviceSpecs              *bool                   `json:"passDeviceSpecs"                        yaml:"passDeviceSpecs"`
+	DeviceListStrategy           *deviceListStrategyFlag `json:"deviceListStrategy"                     yaml:"deviceListStrategy"`
+	DeviceIDStrategy             *string                 `json:"deviceIDStrategy"                       yaml:"deviceIDStrategy"`
+	CDIAnnotationPrefix          *string                 `json:"cdiAnnotationPrefix"                    yaml:"cdiAnnotationPrefix"`
+	NvidiaCTKPath                *string                 `json:"nvidiaCTKPath"                          yaml:"nvidiaCTKPath"`
+	ContainerDriverRoot          *string                 `json:"containerDriverRoot"                    yaml:"containerDriverRoot"`
+	ContainerRuntimeMode         *string                 `json:"containerRuntimeMode"                   yaml:"containerRuntimeMode"`
+	FeatureGates                 *FeatureGates           `json:"featureGates,omitempty"                 yaml:"featureGates,omitempty"`
+	GRPCKeepaliveTime            *Duration               `json:"grpcKeepaliveTime,omitempty"            yaml:"grpcKeepaliveTime,omitempty"`
+	GRPCKeepaliveTimeout         *Duration               `json:"grpcKeepaliveTimeout,omitempty"         yaml:"grpcKeepaliveTimeout,omitempty"`
+	ListAndWatchLivenessInterval *Duration               `json:"listAndWatchLivenessInterval,omitempty" yaml:"listAndWatchLivenessInterval,omitempty"`
+	MigSingleAllowPartial        *bool                   `json:"migSingleAllowPartial,omitempty"        yaml:"migSingleAllowPartial,omitempty"`
+	CDISpecDir                   *string                 `json:"cdiSpecDir,omitempty"                   yaml:"cdiSpecDir,omitempty"`
+	CPUAffinityEnvvars           *bool                   `json:"cpuAffinityEnvvars,omitempty"           yaml:"cpuAffinityEnvvars,omitempty"`
+}
+
+// GetContainerRuntimeMode returns the mode of the NVIDIA Container Runtime
+// that the plugin generates allocate responses for.
+func (f *PluginCommandLineFlags) GetContainerRuntimeMode() string {
+	if f == nil || f.ContainerRuntimeMode == nil || *f.ContainerRuntimeMode == "" {
+		return ContainerRuntimeModeAuto
+	}
+	return *f.ContainerRuntimeMode
+}
+
+// GetGRPCKeepaliveTime returns the time after which idle kubelet connections
+// are pinged. A value of 0 disables the server-side keepalive pings.
+func (f *PluginCommandLineFlags) GetGRPCKeepaliveTime() time.Duration {
+	if f == nil || f.GRPCKeepaliveTime == nil {
+		return 0
+	}
+	return time.Duration(*f.GRPCKeepaliveTime)
+}
+
+// GetGRPCKeepaliveTimeout returns the time to wait for a keepalive ping to be
+// acknowledged. A value of 0 selects the gRPC default.
+func (f *PluginCommandLineFlags) GetGRPCKeepaliveTimeout() time.Duration {
+	if f == nil || f.GRPCKeepaliveTimeout == nil {
+		return 0
+	}
+	return time.Duration(*f.GRPCKeepaliveTimeout)
+}
+
+// GetListAndWatchLivenessInterval returns the interval at which the device
+// list is resent on idle ListAndWatch streams. A value of 0 disables the resends.
+func (f *PluginCommandLineFlags) GetListAndWatchLivenessInterval() time.Duration {
+	if f == nil || f.ListAndWatchLivenessInterval == nil {
+		return 0
+	}
+	return time.Duration(*f.ListAndWatchLivenessInterval)
+}
+
+// GetMigSingleAllowPartial returns whether the single MIG strategy is allowed
+// on nodes where MIG is only enabled on some of the GPUs.
+func (f *PluginCommandLineFlags) GetMigSingleAllowPartial() bool {
+	if f == nil || f.MigSingleAllowPartial == nil {
+		return false
+	}
+	return *f.MigSingleAllowPartial
+}
+
+// GetCPUAffinityEnvvars returns whether the CPUs and NUMA nodes that are
+// closest to the allocated devices are passed to the containers as envvars.
+func (f *PluginCommandLineFlags) GetCPUAffinityEnvvars() bool {
+	if f == nil || f.CPUAffinityEnvvars == nil {
+		return false
+	}
+	return *f.CPUAffinityEnvvars
+}
+
+// GetCDISpecDir returns the directory that the CDI specs are written to.
+// The directory must be mounted at the same path in the container and on the
+// host, since the specs refer to files in it by their host path.
+func (f *PluginCommandLineFlags) GetCDISpecDir() string {
+	if f == nil || f.CDISpecDir == nil || *f.CDISpecDir == "" {
+		return DefaultCDISpecDir
+	}
+	return *f.CDISpecDir
+}
+
+// GetDeviceLocationFile returns the path of the file that maps device UUIDs to
+// their physical location. An empty path is returned if no file is configured.
+func (f *CommandLineFlags) GetDeviceLocationFile() string {
+	if f == nil || f.DeviceLocationFile == nil {
+		return ""
+	}
+	return *f.DeviceLocationFile
+}
+
+// GetMpsContainerRoot returns the path at which the MPS root is mounted in
+// the containers of the device plugin and MPS control daemon.
+func (f *CommandLineFlags) GetMpsContainerRoot() string {
+	if f == nil || f.MpsContainerRoot == nil || *f.MpsContainerRoot == "" {
+		return DefaultMpsContainerRoot
+	}
+	return *f.MpsContainerRoot
+}
+
+// GetFeatureGates returns the feature gates that are explicitly set for the device plugin.
+func (f *PluginCommandLineFlags) GetFeatureGates() FeatureGates {
+	if f == nil || f.FeatureGates == nil {
+		return nil
+	}
+	return *f.FeatureGates
 }
 
 // deviceListStrategyFlag is a custom type for parsing the deviceListStrategy flag.
@@ -102,11 +211,20 @@ func (f *deviceListStrategyFlag) UnmarshalJSON(b []byte) error {
 
 // GFDCommandLineFlags holds the list of command line flags specific to GFD.
 type GFDCommandLineFlags struct {
-	Oneshot         *bool     `json:"oneshot"         yaml:"oneshot"`
-	NoTimestamp     *bool     `json:"noTimestamp"     yaml:"noTimestamp"`
-	SleepInterval   *Duration `json:"sleepInterval"   yaml:"sleepInterval"`
-	OutputFile      *string   `json:"outputFile"      yaml:"outputFile"`
-	MachineTypeFile *string   `json:"machineTypeFile" yaml:"machineTypeFile"`
+	Oneshot            *bool     `json:"oneshot"            yaml:"oneshot"`
+	NoTimestamp        *bool     `json:"noTimestamp"        yaml:"noTimestamp"`
+	SleepInterval      *Duration `json:"sleepInterval"      yaml:"sleepInterval"`
+	OutputFile         *string   `json:"outputFile"         yaml:"outputFile"`
+	MachineTypeFile    *string   `json:"machineTypeFile"    yaml:"machineTypeFile"`
+	CleanupWithoutGPUs *bool     `json:"cleanupWithoutGPUs" yaml:"cleanupWithoutGPUs"`
+}
+
+// GetCleanupWithoutGPUs returns whether labels are removed if no GPUs are detected.
+func (f *GFDCommandLineFlags) GetCleanupWithoutGPUs() bool {
+	if f == nil || f.CleanupWithoutGPUs == nil {
+		return false
+	}
+	return *f.CleanupWithoutGPUs
 }
 
 // UpdateFromCLIFlags updates Flags from settings in the cli Flags if they are set.
@@ -121,6 +239,8 @@ func (f *Flags) UpdateFromCLIFlags(c *cli.Context, flags []cli.Flag) {
 				updateFromCLIFlag(&f.FailOnInitError, c, n)
 			case "mps-root":
 				updateFromCLIFlag(&f.MpsRoot, c, n)
+			case "mps-container-root":
+				updateFromCLIFlag(&f.MpsContainerRoot, c, n)
 			case "nvidia-driver-root":
 				updateFromCLIFlag(&f.NvidiaDriverRoot, c, n)
 			case "gds-enabled":
@@ -131,6 +251,8 @@ func (f *Flags) UpdateFromCLIFlags(c *cli.Context, flags []cli.Flag) {
 				updateFromCLIFlag(&f.UseNodeFeatureAPI, c, n)
 			case "mode":
 				updateFromCLIFlag(&f.Mode, c, n)
+			case "device-location-file":
+				updateFromCLIFlag(&f.DeviceLocationFile, c, n)
 			}
 			// Plugin specific flags
 			if f.Plugin == nil {
@@ -149,6 +271,22 @@ func (f *Flags) UpdateFromCLIFlags(c *cli.Context, flags []cli.Flag) {
 				updateFromCLIFlag(&f.Plugin.NvidiaCTKPath, c, n)
 			case "container-driver-root":
 				updateFromCLIFlag(&f.Plugin.ContainerDriverRoot, c, n)
+			case "grpc-keepalive-time":
+				updateFromCLIFlag(&f.Plugin.GRPCKeepaliveTime, c, n)
+			case "grpc-keepalive-timeout":
+				updateFromCLIFlag(&f.Plugin.GRPCKeepaliveTimeout, c, n)
+			case "list-and-watch-liveness-interval":
+				updateFromCLIFlag(&f.Plugin.ListAndWatchLivenessInterval, c, n)
+			case "container-runtime-mode":
+				updateFromCLIFlag(&f.Plugin.ContainerRuntimeMode, c, n)
+			case "mig-single-allow-partial":
+				updateFromCLIFlag(&f.Plugin.MigSingleAllowPartial, c, n)
+			case "feature-gates":
+				updateFromCLIFlag(&f.Plugin.FeatureGates, c, n)
+			case "cdi-spec-dir":
+				updateFromCLIFlag(&f.Plugin.CDISpecDir, c, n)
+			case "cpu-affinity-envvars":
+				updateFromCLIFlag(&f.Plugin.CPUAffinityEnvvars, c, n)
 			}
 			// GFD specific flags
 			if f.GFD == nil {
@@ -165,6 +303,8 @@ func (f *Flags) UpdateFromCLIFlags(c *cli.Context, flags []cli.Flag) {
 				updateFromCLIFlag(&f.GFD.NoTimestamp, c, n)
 			case "machine-type-file":
 				updateFromCLIFlag(&f.GFD.MachineTypeFile, c, n)
+			case "cleanup-without-gpus":
+				updateFromCLIFlag(&f.GFD.CleanupWithoutGPUs, c, n)
 			}
 		}
 	}
@@ -0,0 +1,109 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fraction is a fraction of a resource of a device, such as its memory. It
+// is expressed as a ratio (e.g. "1/8"), as a percentage (e.g. "12.5%"), or as
+// a decimal number (e.g. 0.125) and must be greater than 0 and at most 1.
+type Fraction struct {
+	raw   string
+	value float64
+}
+
+// NewFraction parses the specified ratio, percentage, or decimal number.
+func NewFraction(s string) (Fraction, error) {
+	s = strings.TrimSpace(s)
+	var value float64
+	var err error
+	switch {
+	case strings.HasSuffix(s, "%"):
+		value, err = strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		value /= 100
+	case strings.Contains(s, "/"):
+		numerator, denominator, _ := strings.Cut(s, "/")
+		var n, d float64
+		n, err = strconv.ParseFloat(strings.TrimSpace(numerator), 64)
+		if err == nil {
+			d, err = strconv.ParseFloat(strings.TrimSpace(denominator), 64)
+		}
+		if err == nil && d == 0 {
+			err = fmt.Errorf("denominator must not be 0")
+		}
+		if err == nil {
+			value = n / d
+		}
+	default:
+		value, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return Fraction{}, fmt.Errorf("invalid fraction %q: %w", s, err)
+	}
+	if value <= 0 || value > 1 {
+		return Fraction{}, fmt.Errorf("invalid fraction %q: must be > 0 and <= 1", s)
+	}
+	return Fraction{raw: s, value: value}, nil
+}
+
+// String returns the fraction as it was specified.
+func (f Fraction) String() string {
+	return f.raw
+}
+
+// Value returns the fraction as a number between 0 and 1.
+func (f Fraction) Value() float64 {
+	return f.value
+}
+
+// Of returns the fraction of the specified total, rounded down.
+func (f Fraction) Of(total uint64) uint64 {
+	return uint64(float64(total) * f.value)
+}
+
+// MarshalJSON marshals 'Fraction' to its raw bytes representation
+func (f Fraction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.raw)
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'Fraction' type.
+func (f *Fraction) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var s string
+	switch value := v.(type) {
+	case float64:
+		s = strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		s = value
+	default:
+		return fmt.Errorf("invalid fraction")
+	}
+	fraction, err := NewFraction(s)
+	if err != nil {
+		return err
+	}
+	*f = fraction
+	return nil
+}
@@ -0,0 +1,70 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFraction(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+		err      bool
+	}{
+		{input: `"1/8"`, expected: 0.125},
+		{input: `"1 / 4"`, expected: 0.25},
+		{input: `"12.5%"`, expected: 0.125},
+		{input: `"100%"`, expected: 1},
+		{input: `0.5`, expected: 0.5},
+		{input: `"0.5"`, expected: 0.5},
+		{input: `"1/0"`, err: true},
+		{input: `"0%"`, err: true},
+		{input: `"3/2"`, err: true},
+		{input: `"half"`, err: true},
+		{input: `true`, err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var f Fraction
+			err := json.Unmarshal([]byte(tc.input), &f)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, f.Value())
+
+			output, err := json.Marshal(f)
+			require.NoError(t, err)
+			var roundTripped Fraction
+			require.NoError(t, json.Unmarshal(output, &roundTripped))
+			require.Equal(t, f, roundTripped)
+		})
+	}
+}
+
+func TestFractionOf(t *testing.T) {
+	f, err := NewFraction("1/8")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5120), f.Of(40960))
+	require.Equal(t, uint64(10240), f.Of(81920))
+}
@@ -0,0 +1,168 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// RedactedValue replaces the values read from config fragments in redacted configs.
+const RedactedValue = "<redacted>"
+
+// readFragmentFiles returns the files of the config fragments at the specified
+// paths. A path is either a file or a directory whose regular files are read
+// in lexical order. Hidden entries, such as the '..data' links of mounted
+// Secrets, are skipped.
+func readFragmentFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config fragment: %v", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config fragments: %v", err)
+		}
+		var names []string
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(path, e.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("error reading config fragment: %v", err)
+			}
+			if info.Mode().IsRegular() {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	return files, nil
+}
+
+// mergeFragments merges the config fragments at the specified paths into the
+// contents of a config file and returns the merged contents as JSON.
+//
+// Config fragments hold parts of a config, typically sensitive values mounted
+// from Secrets, so that these values do not have to be stored in a
+// world-readable ConfigMap. The fragments are merged in order and take
+// precedence over the config file at field level: maps are merged key by key,
+// while all other values, including lists, replace the value of the config
+// file or of a previous fragment. The values of the fragments are recorded in
+// the provenance of the config.
+func mergeFragments(contents []byte, paths []string, provenance Provenance) ([]byte, error) {
+	files, err := readFragmentFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(contents, &values); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %v", err)
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	for _, file := range files {
+		fragment, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config fragment: %v", err)
+		}
+		var fragmentValues map[string]interface{}
+		if err := yaml.Unmarshal(fragment, &fragmentValues); err != nil {
+			return nil, fmt.Errorf("error parsing config fragment %v: %v", file, err)
+		}
+		mergeValues(values, fragmentValues, "", provenance)
+	}
+	return json.Marshal(values)
+}
+
+// mergeValues merges the values of a config fragment into the values of a
+// config. Maps are merged key by key and all other values replace the
+// existing value, whose provenance is replaced accordingly.
+func mergeValues(dst map[string]interface{}, src map[string]interface{}, path string, provenance Provenance) {
+	for key, value := range src {
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap, childPath, provenance)
+			continue
+		}
+		provenance.remove(childPath)
+		provenance.addValues(childPath, value, SourceFragment)
+		dst[key] = value
+	}
+}
+
+// Redacted returns the config as generic JSON values in which the values that
+// were read from config fragments are replaced by RedactedValue, so that the
+// config can be logged or collected without exposing them.
+func (c *Config) Redacted() (interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var values interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return c.Provenance.redact("", values), nil
+}
+
+func (p Provenance) redact(path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			v[key] = p.redact(childPath, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = p.redact(fmt.Sprintf("%s[%d]", path, i), child)
+		}
+		return v
+	default:
+		if p.Source(path) == SourceFragment {
+			return RedactedValue
+		}
+		return v
+	}
+}
@@ -0,0 +1,164 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cli "github.com/urfave/cli/v2"
+)
+
+func TestNewConfigFromFileFragments(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`version: v1
+flags:
+  migStrategy: single
+  plugin:
+    deviceListStrategy: [envvar]
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+`), 0644))
+
+	// The fragments are laid out like a mounted Secret.
+	secret := filepath.Join(dir, "secret")
+	data := filepath.Join(secret, "..data")
+	require.NoError(t, os.MkdirAll(data, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(data, "a-sharing"), []byte(`
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(data, "b-flags"), []byte(`
+flags:
+  plugin:
+    deviceListStrategy: [volume-mounts]
+`), 0644))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "a-sharing"), filepath.Join(secret, "a-sharing")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "b-flags"), filepath.Join(secret, "b-flags")))
+
+	override := filepath.Join(dir, "override.yaml")
+	require.NoError(t, os.WriteFile(override, []byte(`
+sharing:
+  timeSlicing:
+    renameByDefault: true
+`), 0644))
+
+	testCases := []struct {
+		description        string
+		args               []string
+		expectedReplicas   int
+		expectedStrategies []string
+		expectedRename     bool
+		expectedProvenance Provenance
+		expectedError      bool
+	}{
+		{
+			description:        "no fragments",
+			expectedReplicas:   4,
+			expectedStrategies: []string{DeviceListStrategyEnvvar},
+			expectedProvenance: Provenance{
+				"version":                                   SourceFile,
+				"flags.migStrategy":                         SourceFile,
+				"flags.plugin.deviceListStrategy[0]":        SourceFile,
+				"sharing.timeSlicing.resources[0].name":     SourceFile,
+				"sharing.timeSlicing.resources[0].replicas": SourceFile,
+			},
+		},
+		{
+			description:        "fragments take precedence at field level",
+			args:               []string{"--config-fragments=" + secret, "--config-fragments=" + override},
+			expectedReplicas:   2,
+			expectedStrategies: []string{DeviceListStrategyVolumeMounts},
+			expectedRename:     true,
+			expectedProvenance: Provenance{
+				"version":                                   SourceFile,
+				"flags.migStrategy":                         SourceFile,
+				"flags.plugin.deviceListStrategy[0]":        SourceFragment,
+				"sharing.timeSlicing.resources[0].name":     SourceFragment,
+				"sharing.timeSlicing.resources[0].replicas": SourceFragment,
+				"sharing.timeSlicing.renameByDefault":       SourceFragment,
+				"--config-fragments":                        SourceFlag,
+			},
+		},
+		{
+			description:   "missing fragment is an error",
+			args:          []string{"--config-fragments=" + filepath.Join(dir, "missing")},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			flags := []cli.Flag{
+				&cli.StringSliceFlag{Name: "config-fragments"},
+			}
+			var config *Config
+			c := cli.NewApp()
+			c.Flags = flags
+			c.Action = func(c *cli.Context) error {
+				var err error
+				config, err = NewConfigFromFile(c, flags, configFile)
+				return err
+			}
+			err := c.Run(append([]string{"test"}, tc.args...))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedReplicas, config.Sharing.TimeSlicing.Resources[0].Replicas)
+			require.Equal(t, tc.expectedStrategies, []string(*config.Flags.Plugin.DeviceListStrategy))
+			require.Equal(t, tc.expectedRename, config.Sharing.TimeSlicing.RenameByDefault)
+			require.Equal(t, tc.expectedProvenance, config.Provenance)
+		})
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	config := &Config{
+		Version: Version,
+		Sharing: Sharing{
+			TimeSlicing: ReplicatedResources{
+				Resources: []ReplicatedResource{
+					{Name: "nvidia.com/gpu", Replicas: 2, Devices: ReplicatedDevices{All: true}},
+				},
+			},
+		},
+		Provenance: Provenance{
+			"version": SourceFile,
+			"sharing.timeSlicing.resources[0].replicas": SourceFragment,
+		},
+	}
+
+	redacted, err := config.Redacted()
+	require.NoError(t, err)
+
+	values := redacted.(map[string]interface{})
+	require.Equal(t, Version, values["version"])
+	resource := values["sharing"].(map[string]interface{})["timeSlicing"].(map[string]interface{})["resources"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, "nvidia.com/gpu", resource["name"])
+	require.Equal(t, RedactedValue, resource["replicas"])
+}
@@ -0,0 +1,713 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Health defines options that control how device health is tracked by the plugin.
+type Health struct {
+	// EventDecayWindow is the period after a health event on a device during
+	// which the device is deprioritized in preferred allocations. The device
+	// is still advertised to the kubelet. A value of 0 disables this.
+	EventDecayWindow *Duration `json:"eventDecayWindow,omitempty"    yaml:"eventDecayWindow,omitempty"`
+	// DCGM enables health checks based on the DCGM background health watches
+	// in addition to the NVML events. This requires a running DCGM host engine.
+	DCGM *DCGMHealth `json:"dcgm,omitempty"                yaml:"dcgm,omitempty"`
+	// Thermal enables health checks based on the temperature of the GPUs.
+	Thermal *ThermalHealth `json:"thermal,omitempty"             yaml:"thermal,omitempty"`
+	// ECC enables periodically checking the uncorrected ECC error counters
+	// of the GPUs in addition to the NVML ECC events.
+	ECC *ECCHealth `json:"ecc,omitempty"                 yaml:"ecc,omitempty"`
+	// IntervalJitter is the maximum fraction of its interval by which each
+	// run of a periodic health check is randomly delayed, so that the checks
+	// of different resources and nodes do not query the GPUs in lockstep. If
+	// unset, DefaultHealthIntervalJitter is used.
+	IntervalJitter *Fraction `json:"intervalJitter,omitempty"      yaml:"intervalJitter,omitempty"`
+	// Links enables sampling the error counters of the PCIe and NVLink
+	// interconnects of the GPUs to label nodes with degraded interconnects.
+	Links *LinkHealth `json:"links,omitempty"               yaml:"links,omitempty"`
+	// Performance enables sampling the performance states of the GPUs to
+	// label nodes with GPUs that do not reach their maximum performance state
+	// under load.
+	Performance *PerformanceHealth `json:"performance,omitempty"         yaml:"performance,omitempty"`
+	// Wear enables labeling nodes with the retired page and remapped row
+	// counters of the GPUs, so that worn GPUs can be identified.
+	Wear *WearHealth `json:"wear,omitempty"                yaml:"wear,omitempty"`
+	// StartupValidation enables validating each device when the plugins are
+	// started. Devices that fail the validation are advertised as unhealthy.
+	StartupValidation *StartupValidation `json:"startupValidation,omitempty"   yaml:"startupValidation,omitempty"`
+	// IgnoredXids lists the Xids that do not mark devices as unhealthy, in
+	// addition to the application errors that are skipped by default.
+	IgnoredXids []uint64 `json:"ignoredXids,omitempty"         yaml:"ignoredXids,omitempty"`
+	// FatalXids lists the Xids that mark devices as unhealthy even if they
+	// are application errors or are skipped by DP_DISABLE_HEALTHCHECKS.
+	FatalXids []uint64 `json:"fatalXids,omitempty"           yaml:"fatalXids,omitempty"`
+	// XidRecoveryCooldown is the period without further Xids after which a
+	// device that was marked unhealthy by an Xid is advertised as healthy
+	// again. A value of 0 disables this.
+	XidRecoveryCooldown *Duration `json:"xidRecoveryCooldown,omitempty" yaml:"xidRecoveryCooldown,omitempty"`
+	// Resources defines per-resource health check options.
+	Resources []HealthResource `json:"resources,omitempty"           yaml:"resources,omitempty"`
+}
+
+// HealthCheck is a health check that can be disabled for a resource.
+type HealthCheck string
+
+// The health checks that can be disabled for a resource.
+const (
+	HealthCheckAll     HealthCheck = "all"
+	HealthCheckXids    HealthCheck = "xids"
+	HealthCheckDCGM    HealthCheck = "dcgm"
+	HealthCheckThermal HealthCheck = "thermal"
+	HealthCheckECC     HealthCheck = "ecc"
+	// HealthCheckStartupValidation is the validation of the devices when the plugins are started.
+	HealthCheckStartupValidation HealthCheck = "startupValidation"
+)
+
+// HealthResource defines the health check options for a specific resource.
+type HealthResource struct {
+	Name ResourceName `json:"name"                     yaml:"name"`
+	// DisabledChecks lists the health checks that are disabled for the
+	// resource. The "xids" check covers the NVML Xid and ECC events.
+	DisabledChecks []HealthCheck `json:"disabledChecks,omitempty" yaml:"disabledChecks,omitempty"`
+	// SkippedXids lists the Xids that do not mark the devices of the resource
+	// as unhealthy, in addition to the application errors that are always
+	// skipped.
+	SkippedXids []uint64 `json:"skippedXids,omitempty"    yaml:"skippedXids,omitempty"`
+}
+
+// DCGMHealth defines the options for health checks performed through DCGM.
+type DCGMHealth struct {
+	// Address is the address of the DCGM host engine. A unix socket is
+	// specified as unix://<path>. If no address is specified, the default
+	// socket and TCP port are probed.
+	Address string `json:"address,omitempty"  yaml:"address,omitempty"`
+	// Interval is the interval at which the DCGM health watches are checked.
+	Interval *Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Checks defines the DCGM health watches that are enabled. If no checks
+	// are specified, DefaultDCGMHealthChecks are used.
+	Checks []DCGMHealthCheck `json:"checks,omitempty"   yaml:"checks,omitempty"`
+}
+
+// DCGMHealthCheck defines a single DCGM health watch.
+type DCGMHealthCheck struct {
+	// System is the DCGM health watch system to enable.
+	System DCGMHealthSystem `json:"system"             yaml:"system"`
+	// Severity is the minimum severity of an incident for the check that
+	// marks a device as unhealthy. Incidents of lower severity are only
+	// recorded as health events.
+	Severity DCGMHealthSeverity `json:"severity,omitempty" yaml:"severity,omitempty"`
+}
+
+// ThermalHealth defines the temperature thresholds beyond which GPUs are
+// considered degraded or unhealthy.
+type ThermalHealth struct {
+	// Interval is the interval at which the GPU temperatures are checked.
+	Interval *Duration `json:"interval,omitempty"   yaml:"interval,omitempty"`
+	// Thresholds defines the thresholds per GPU model. The first threshold
+	// whose pattern matches the product name of a GPU is used.
+	Thresholds []ThermalThreshold `json:"thresholds,omitempty" yaml:"thresholds,omitempty"`
+}
+
+// ECCHealth defines the options for periodically checking the uncorrected ECC
+// error counters of the GPUs. This catches errors for which no NVML event is
+// delivered.
+type ECCHealth struct {
+	// Interval is the interval at which the ECC error counters are checked.
+	Interval *Duration `json:"interval,omitempty"         yaml:"interval,omitempty"`
+	// RecoveryCooldown is the period without new uncorrected errors after
+	// which the devices of a GPU that were marked unhealthy by this check are
+	// advertised as healthy again. A value of 0 disables this; the devices
+	// still recover once the error counters of the GPU are reset.
+	RecoveryCooldown *Duration `json:"recoveryCooldown,omitempty" yaml:"recoveryCooldown,omitempty"`
+}
+
+// ThermalThreshold defines the temperature thresholds for GPU models matching a pattern.
+type ThermalThreshold struct {
+	// Pattern matches the product name of the GPU, as in resources.gpus.
+	Pattern ResourcePattern `json:"pattern"             yaml:"pattern"`
+	// Degraded is the temperature in degrees Celsius at or above which a GPU
+	// is deprioritized in preferred allocations. A value of 0 disables this.
+	Degraded int `json:"degraded,omitempty"  yaml:"degraded,omitempty"`
+	// Unhealthy is the temperature in degrees Celsius at or above which a GPU
+	// is marked unhealthy. A value of 0 disables this.
+	Unhealthy int `json:"unhealthy,omitempty" yaml:"unhealthy,omitempty"`
+}
+
+// ThermalClass classifies the temperature of a GPU relative to its thresholds.
+type ThermalClass string
+
+// These constants define the thermal classes of a GPU.
+const (
+	ThermalClassNormal    ThermalClass = "normal"
+	ThermalClassDegraded  ThermalClass = "degraded"
+	ThermalClassUnhealthy ThermalClass = "unhealthy"
+)
+
+// DCGMHealthSystem is a DCGM health watch system.
+type DCGMHealthSystem string
+
+// These constants define the supported DCGM health watch systems.
+const (
+	DCGMHealthSystemPCIe    DCGMHealthSystem = "pcie"
+	DCGMHealthSystemNVLink  DCGMHealthSystem = "nvlink"
+	DCGMHealthSystemSM      DCGMHealthSystem = "sm"
+	DCGMHealthSystemMemory  DCGMHealthSystem = "memory"
+	DCGMHealthSystemInforom DCGMHealthSystem = "inforom"
+	DCGMHealthSystemThermal DCGMHealthSystem = "thermal"
+	DCGMHealthSystemPower   DCGMHealthSystem = "power"
+)
+
+// DCGMHealthSeverity is the severity of a DCGM health incident.
+type DCGMHealthSeverity string
+
+// These constants define the supported DCGM health incident severities.
+const (
+	DCGMHealthSeverityWarning DCGMHealthSeverity = "warning"
+	DCGMHealthSeverityFailure DCGMHealthSeverity = "failure"
+)
+
+// DefaultDCGMHealthInterval is the interval at which DCGM health watches are
+// checked if no interval is configured.
+const DefaultDCGMHealthInterval = 30 * time.Second
+
+// LinkHealth defines the error rates of the interconnects of the GPUs beyond
+// which the interconnects are considered degraded.
+type LinkHealth struct {
+	// SampleInterval is the time between the two samples of the error
+	// counters from which the error rates are computed.
+	SampleInterval *Duration `json:"sampleInterval,omitempty"        yaml:"sampleInterval,omitempty"`
+	// MaxPCIeReplayRate is the number of PCIe replays per second beyond which
+	// the PCIe link of a GPU is considered degraded.
+	MaxPCIeReplayRate float64 `json:"maxPCIeReplayRate,omitempty"     yaml:"maxPCIeReplayRate,omitempty"`
+	// MaxNVLinkErrorRate is the number of NVLink CRC, replay, and recovery
+	// errors per second beyond which the NVLinks of a GPU are considered
+	// degraded.
+	MaxNVLinkErrorRate float64 `json:"maxNVLinkErrorRate,omitempty"    yaml:"maxNVLinkErrorRate,omitempty"`
+	// MaxNVLinkErrorsPerGiB is the number of NVLink CRC, replay, and recovery
+	// errors per GiB of data transferred over the NVLinks beyond which the
+	// NVLinks of a GPU are considered degraded. Unlike the error rate per
+	// second, it does not depend on the load of the links. If set, it is used
+	// instead of MaxNVLinkErrorRate for GPUs that report the NVLink throughput.
+	MaxNVLinkErrorsPerGiB float64 `json:"maxNVLinkErrorsPerGiB,omitempty" yaml:"maxNVLinkErrorsPerGiB,omitempty"`
+}
+
+// WearHealth defines the wear counters of the GPUs beyond which the GPUs are
+// considered worn.
+type WearHealth struct {
+	// MaxRetiredPages is the number of retired memory pages beyond which a
+	// GPU is considered worn. A value of 0 disables the threshold.
+	MaxRetiredPages uint64 `json:"maxRetiredPages,omitempty" yaml:"maxRetiredPages,omitempty"`
+	// MaxRemappedRows is the number of remapped memory rows beyond which a
+	// GPU is considered worn. A value of 0 disables the threshold.
+	MaxRemappedRows uint64 `json:"maxRemappedRows,omitempty" yaml:"maxRemappedRows,omitempty"`
+}
+
+// PerformanceHealth defines how the performance states (P-states) of the GPUs
+// are sampled.
+type PerformanceHealth struct {
+	// Samples is the number of samples of the performance state of each GPU.
+	Samples int `json:"samples,omitempty"        yaml:"samples,omitempty"`
+	// SampleInterval is the time between two samples.
+	SampleInterval *Duration `json:"sampleInterval,omitempty" yaml:"sampleInterval,omitempty"`
+	// MinUtilization is the GPU utilization in percent from which a GPU is
+	// considered to be under load and expected to run in P0.
+	MinUtilization int `json:"minUtilization,omitempty" yaml:"minUtilization,omitempty"`
+}
+
+// StartupValidation defines the checks that a device must pass when the
+// plugins are started before it is advertised as healthy. The memory of each
+// device is always queried through NVML.
+type StartupValidation struct {
+	// Command is an optional command, e.g. a bandwidth test, that is run for
+	// each device with the UUID of the device appended as the last argument.
+	// A device fails the validation if the command exits with a non-zero code.
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+	// Timeout is the maximum time the command may run for a device.
+	Timeout *Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// DefaultDCGMHealthChecks are the DCGM health watches enabled if no checks are configured.
+var DefaultDCGMHealthChecks = []DCGMHealthCheck{
+	{System: DCGMHealthSystemPCIe, Severity: DCGMHealthSeverityFailure},
+	{System: DCGMHealthSystemNVLink, Severity: DCGMHealthSeverityFailure},
+	{System: DCGMHealthSystemSM, Severity: DCGMHealthSeverityFailure},
+}
+
+// DefaultThermalHealthInterval is the interval at which GPU temperatures are
+// checked if no interval is configured.
+const DefaultThermalHealthInterval = 30 * time.Second
+
+// DefaultECCHealthInterval is the interval at which the ECC error counters are
+// checked if no interval is configured.
+const DefaultECCHealthInterval = 5 * time.Minute
+
+// DefaultHealthIntervalJitter is the maximum fraction of its interval by which
+// a periodic health check is delayed if no jitter is configured.
+const DefaultHealthIntervalJitter = 0.1
+
+// DefaultLinkHealthSampleInterval is the time between the two samples of the
+// link error counters if no interval is configured.
+const DefaultLinkHealthSampleInterval = 5 * time.Second
+
+// DefaultStartupValidationTimeout is the time the startup validation command
+// may run for a device if no timeout is configured.
+const DefaultStartupValidationTimeout = time.Minute
+
+// Defaults for sampling the performance states of the GPUs.
+const (
+	DefaultPerformanceHealthSamples        = 5
+	DefaultPerformanceHealthSampleInterval = time.Second
+	DefaultPerformanceHealthMinUtilization = 50
+)
+
+// GetEventDecayWindow returns the period during which a device with a recent health event is deprioritized.
+func (h *Health) GetEventDecayWindow() time.Duration {
+	if h == nil || h.EventDecayWindow == nil {
+		return 0
+	}
+	return time.Duration(*h.EventDecayWindow)
+}
+
+// GetXidRecoveryCooldown returns the period without further Xids after which
+// a device that was marked unhealthy by an Xid recovers.
+func (h *Health) GetXidRecoveryCooldown() time.Duration {
+	if h == nil || h.XidRecoveryCooldown == nil {
+		return 0
+	}
+	return time.Duration(*h.XidRecoveryCooldown)
+}
+
+// GetIgnoredXids returns the Xids that do not mark devices as unhealthy.
+func (h *Health) GetIgnoredXids() []uint64 {
+	if h == nil {
+		return nil
+	}
+	return h.IgnoredXids
+}
+
+// GetFatalXids returns the Xids that always mark devices as unhealthy.
+func (h *Health) GetFatalXids() []uint64 {
+	if h == nil {
+		return nil
+	}
+	return h.FatalXids
+}
+
+// GetDCGM returns the options for DCGM health checks.
+// If DCGM health checks are not enabled, nil is returned.
+func (h *Health) GetDCGM() *DCGMHealth {
+	if h == nil {
+		return nil
+	}
+	return h.DCGM
+}
+
+// GetThermal returns the options for thermal health checks.
+// If thermal health checks are not enabled, nil is returned.
+func (h *Health) GetThermal() *ThermalHealth {
+	if h == nil {
+		return nil
+	}
+	return h.Thermal
+}
+
+// GetECC returns the options for ECC health checks.
+// If ECC health checks are not enabled, nil is returned.
+func (h *Health) GetECC() *ECCHealth {
+	if h == nil {
+		return nil
+	}
+	return h.ECC
+}
+
+// GetIntervalJitter returns the maximum fraction of its interval by which a
+// periodic health check is delayed.
+func (h *Health) GetIntervalJitter() float64 {
+	if h == nil || h.IntervalJitter == nil {
+		return DefaultHealthIntervalJitter
+	}
+	return h.IntervalJitter.Value()
+}
+
+// GetLinks returns the options for link health checks.
+// If link health checks are not enabled, nil is returned.
+func (h *Health) GetLinks() *LinkHealth {
+	if h == nil {
+		return nil
+	}
+	return h.Links
+}
+
+// GetPerformance returns the options for sampling the performance states.
+// If performance state sampling is not enabled, nil is returned.
+func (h *Health) GetPerformance() *PerformanceHealth {
+	if h == nil {
+		return nil
+	}
+	return h.Performance
+}
+
+// GetWear returns the options for the wear labels.
+// If the wear labels are not enabled, nil is returned.
+func (h *Health) GetWear() *WearHealth {
+	if h == nil {
+		return nil
+	}
+	return h.Wear
+}
+
+// GetStartupValidation returns the startup validation options.
+// If startup validation is not enabled, nil is returned.
+func (h *Health) GetStartupValidation() *StartupValidation {
+	if h == nil {
+		return nil
+	}
+	return h.StartupValidation
+}
+
+// ForResource returns the health check options for the specified resource.
+// If no options are defined for the resource, empty options are returned.
+func (h *Health) ForResource(name ResourceName) HealthResource {
+	if h != nil {
+		for _, r := range h.Resources {
+			if r.Name == name {
+				return r
+			}
+		}
+	}
+	return HealthResource{Name: name}
+}
+
+// IsEnabled returns whether the specified health check is enabled for the resource.
+func (r HealthResource) IsEnabled(check HealthCheck) bool {
+	for _, c := range r.DisabledChecks {
+		if c == HealthCheckAll || c == check {
+			return false
+		}
+	}
+	return true
+}
+
+// GetSampleInterval returns the time between the two samples of the link error counters.
+func (l *LinkHealth) GetSampleInterval() time.Duration {
+	if l == nil || l.SampleInterval == nil || *l.SampleInterval == 0 {
+		return DefaultLinkHealthSampleInterval
+	}
+	return time.Duration(*l.SampleInterval)
+}
+
+// GetSamples returns the number of samples of the performance state of each GPU.
+func (p *PerformanceHealth) GetSamples() int {
+	if p == nil || p.Samples == 0 {
+		return DefaultPerformanceHealthSamples
+	}
+	return p.Samples
+}
+
+// GetSampleInterval returns the time between two samples of the performance states.
+func (p *PerformanceHealth) GetSampleInterval() time.Duration {
+	if p == nil || p.SampleInterval == nil || *p.SampleInterval == 0 {
+		return DefaultPerformanceHealthSampleInterval
+	}
+	return time.Duration(*p.SampleInterval)
+}
+
+// GetMinUtilization returns the GPU utilization from which a GPU is considered to be under load.
+func (p *PerformanceHealth) GetMinUtilization() int {
+	if p == nil || p.MinUtilization == 0 {
+		return DefaultPerformanceHealthMinUtilization
+	}
+	return p.MinUtilization
+}
+
+// GetTimeout returns the maximum time the startup validation command may run for a device.
+func (v *StartupValidation) GetTimeout() time.Duration {
+	if v == nil || v.Timeout == nil || *v.Timeout == 0 {
+		return DefaultStartupValidationTimeout
+	}
+	return time.Duration(*v.Timeout)
+}
+
+// GetInterval returns the interval at which the DCGM health watches are checked.
+func (d *DCGMHealth) GetInterval() time.Duration {
+	if d == nil || d.Interval == nil || *d.Interval == 0 {
+		return DefaultDCGMHealthInterval
+	}
+	return time.Duration(*d.Interval)
+}
+
+// GetChecks returns the DCGM health watches that are enabled.
+func (d *DCGMHealth) GetChecks() []DCGMHealthCheck {
+	if d == nil || len(d.Checks) == 0 {
+		return DefaultDCGMHealthChecks
+	}
+	return d.Checks
+}
+
+// GetInterval returns the interval at which the GPU temperatures are checked.
+func (t *ThermalHealth) GetInterval() time.Duration {
+	if t == nil || t.Interval == nil || *t.Interval == 0 {
+		return DefaultThermalHealthInterval
+	}
+	return time.Duration(*t.Interval)
+}
+
+// GetInterval returns the interval at which the ECC error counters are checked.
+func (e *ECCHealth) GetInterval() time.Duration {
+	if e == nil || e.Interval == nil || *e.Interval == 0 {
+		return DefaultECCHealthInterval
+	}
+	return time.Duration(*e.Interval)
+}
+
+// GetRecoveryCooldown returns the period without new uncorrected errors after
+// which the devices of a GPU recover.
+func (e *ECCHealth) GetRecoveryCooldown() time.Duration {
+	if e == nil || e.RecoveryCooldown == nil {
+		return 0
+	}
+	return time.Duration(*e.RecoveryCooldown)
+}
+
+// GetThreshold returns the thresholds for the GPU with the specified product
+// name. If no thresholds match, nil is returned.
+func (t *ThermalHealth) GetThreshold(name string) *ThermalThreshold {
+	if t == nil {
+		return nil
+	}
+	for i := range t.Thresholds {
+		if t.Thresholds[i].Pattern.Matches(name) {
+			return &t.Thresholds[i]
+		}
+	}
+	return nil
+}
+
+// Classify returns the thermal class of a GPU at the specified temperature.
+func (t *ThermalThreshold) Classify(temperature int) ThermalClass {
+	switch {
+	case t.Unhealthy > 0 && temperature >= t.Unhealthy:
+		return ThermalClassUnhealthy
+	case t.Degraded > 0 && temperature >= t.Degraded:
+		return ThermalClassDegraded
+	}
+	return ThermalClassNormal
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'Health' struct.
+func (h *Health) UnmarshalJSON(b []byte) error {
+	type health Health
+	if err := json.Unmarshal(b, (*health)(h)); err != nil {
+		return err
+	}
+	if h.GetEventDecayWindow() < 0 {
+		return fmt.Errorf("eventDecayWindow must be >= 0")
+	}
+	if h.GetXidRecoveryCooldown() < 0 {
+		return fmt.Errorf("xidRecoveryCooldown must be >= 0")
+	}
+	ignored := make(map[uint64]bool)
+	for _, xid := range h.IgnoredXids {
+		ignored[xid] = true
+	}
+	for _, xid := range h.FatalXids {
+		if ignored[xid] {
+			return fmt.Errorf("xid %d is both ignored and fatal", xid)
+		}
+	}
+	seen := make(map[ResourceName]bool)
+	for _, r := range h.Resources {
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate health options for resource %q", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'HealthResource' struct.
+func (r *HealthResource) UnmarshalJSON(b []byte) error {
+	type healthResource HealthResource
+	if err := json.Unmarshal(b, (*healthResource)(r)); err != nil {
+		return err
+	}
+	if r.Name == "" {
+		return fmt.Errorf("no resource name specified")
+	}
+	for _, c := range r.DisabledChecks {
+		switch c {
+		case HealthCheckAll, HealthCheckXids, HealthCheckDCGM, HealthCheckThermal, HealthCheckECC, HealthCheckStartupValidation:
+		default:
+			return fmt.Errorf("unknown health check %q for resource %q", c, r.Name)
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'DCGMHealth' struct.
+func (d *DCGMHealth) UnmarshalJSON(b []byte) error {
+	type dcgmHealth DCGMHealth
+	if err := json.Unmarshal(b, (*dcgmHealth)(d)); err != nil {
+		return err
+	}
+	if d.Interval != nil && *d.Interval < 0 {
+		return fmt.Errorf("interval must be >= 0")
+	}
+	seen := make(map[DCGMHealthSystem]bool)
+	for _, c := range d.Checks {
+		if seen[c.System] {
+			return fmt.Errorf("duplicate check for system %q", c.System)
+		}
+		seen[c.System] = true
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'DCGMHealthCheck' struct.
+// If no severity is specified, DCGMHealthSeverityFailure is used.
+func (c *DCGMHealthCheck) UnmarshalJSON(b []byte) error {
+	type dcgmHealthCheck DCGMHealthCheck
+	if err := json.Unmarshal(b, (*dcgmHealthCheck)(c)); err != nil {
+		return err
+	}
+	switch c.System {
+	case DCGMHealthSystemPCIe, DCGMHealthSystemNVLink, DCGMHealthSystemSM, DCGMHealthSystemMemory,
+		DCGMHealthSystemInforom, DCGMHealthSystemThermal, DCGMHealthSystemPower:
+	case "":
+		return fmt.Errorf("no system specified for DCGM health check")
+	default:
+		return fmt.Errorf("unknown DCGM health system %q", c.System)
+	}
+	switch c.Severity {
+	case "":
+		c.Severity = DCGMHealthSeverityFailure
+	case DCGMHealthSeverityWarning, DCGMHealthSeverityFailure:
+	default:
+		return fmt.Errorf("unknown severity %q for DCGM health system %q", c.Severity, c.System)
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'ThermalHealth' struct.
+func (t *ThermalHealth) UnmarshalJSON(b []byte) error {
+	type thermalHealth ThermalHealth
+	if err := json.Unmarshal(b, (*thermalHealth)(t)); err != nil {
+		return err
+	}
+	if t.Interval != nil && *t.Interval < 0 {
+		return fmt.Errorf("interval must be >= 0")
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'ECCHealth' struct.
+func (e *ECCHealth) UnmarshalJSON(b []byte) error {
+	type eccHealth ECCHealth
+	if err := json.Unmarshal(b, (*eccHealth)(e)); err != nil {
+		return err
+	}
+	if e.Interval != nil && *e.Interval < 0 {
+		return fmt.Errorf("interval must be >= 0")
+	}
+	if e.RecoveryCooldown != nil && *e.RecoveryCooldown < 0 {
+		return fmt.Errorf("recoveryCooldown must be >= 0")
+	}
+	return nil
+}
+
+// IsWorn checks whether a GPU with the specified wear counters is worn. A
+// counter that is not known does not exceed its threshold.
+func (w *WearHealth) IsWorn(retiredPages *uint64, remappedRows *uint64) bool {
+	if w == nil {
+		return false
+	}
+	if w.MaxRetiredPages > 0 && retiredPages != nil && *retiredPages > w.MaxRetiredPages {
+		return true
+	}
+	return w.MaxRemappedRows > 0 && remappedRows != nil && *remappedRows > w.MaxRemappedRows
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'LinkHealth' struct.
+func (l *LinkHealth) UnmarshalJSON(b []byte) error {
+	type linkHealth LinkHealth
+	if err := json.Unmarshal(b, (*linkHealth)(l)); err != nil {
+		return err
+	}
+	if l.SampleInterval != nil && *l.SampleInterval < 0 {
+		return fmt.Errorf("sampleInterval must be >= 0")
+	}
+	if l.MaxPCIeReplayRate < 0 || l.MaxNVLinkErrorRate < 0 {
+		return fmt.Errorf("maximum error rates must be >= 0")
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'PerformanceHealth' struct.
+func (p *PerformanceHealth) UnmarshalJSON(b []byte) error {
+	type performanceHealth PerformanceHealth
+	if err := json.Unmarshal(b, (*performanceHealth)(p)); err != nil {
+		return err
+	}
+	if p.Samples < 0 {
+		return fmt.Errorf("samples must be >= 0")
+	}
+	if p.SampleInterval != nil && *p.SampleInterval < 0 {
+		return fmt.Errorf("sampleInterval must be >= 0")
+	}
+	if p.MinUtilization < 0 || p.MinUtilization > 100 {
+		return fmt.Errorf("minUtilization must be between 0 and 100")
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'StartupValidation' struct.
+func (v *StartupValidation) UnmarshalJSON(b []byte) error {
+	type startupValidation StartupValidation
+	if err := json.Unmarshal(b, (*startupValidation)(v)); err != nil {
+		return err
+	}
+	if v.Timeout != nil && *v.Timeout < 0 {
+		return fmt.Errorf("timeout must be >= 0")
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'ThermalThreshold' struct.
+func (t *ThermalThreshold) UnmarshalJSON(b []byte) error {
+	type thermalThreshold ThermalThreshold
+	if err := json.Unmarshal(b, (*thermalThreshold)(t)); err != nil {
+		return err
+	}
+	if t.Pattern == "" {
+		return fmt.Errorf("no pattern specified for thermal threshold")
+	}
+	if t.Degraded < 0 || t.Unhealthy < 0 {
+		return fmt.Errorf("thermal thresholds for %q must be >= 0", t.Pattern)
+	}
+	if t.Degraded == 0 && t.Unhealthy == 0 {
+		return fmt.Errorf("no degraded or unhealthy threshold specified for %q", t.Pattern)
+	}
+	if t.Degraded > 0 && t.Unhealthy > 0 && t.Degraded >= t.Unhealthy {
+		return fmt.Errorf("degraded threshold must be below the unhealthy threshold for %q", t.Pattern)
+	}
+	return nil
+}
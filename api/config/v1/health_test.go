@@ -0,0 +1,694 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthConfig(t *testing.T) {
+	testCases := []struct {
+		description         string
+		input               string
+		expectedDecayWindow time.Duration
+		expectedError       bool
+	}{
+		{
+			description: "no health section",
+			input:       `version: v1`,
+		},
+		{
+			description: "decay window as string",
+			input: `
+version: v1
+health:
+  eventDecayWindow: 10m
+`,
+			expectedDecayWindow: 10 * time.Minute,
+		},
+		{
+			description: "negative decay window is an error",
+			input: `
+version: v1
+health:
+  eventDecayWindow: -1m
+`,
+			expectedError: true,
+		},
+		{
+			description: "invalid decay window is an error",
+			input: `
+version: v1
+health:
+  eventDecayWindow: soon
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedDecayWindow, config.Health.GetEventDecayWindow())
+		})
+	}
+}
+
+func TestXidHealthConfig(t *testing.T) {
+	testCases := []struct {
+		description      string
+		input            string
+		expectedIgnored  []uint64
+		expectedFatal    []uint64
+		expectedCooldown time.Duration
+		expectedError    bool
+	}{
+		{
+			description: "no xid options",
+			input: `
+version: v1
+health: {}
+`,
+		},
+		{
+			description: "ignored and fatal xids with a cool-down",
+			input: `
+version: v1
+health:
+  ignoredXids: [48, 63]
+  fatalXids: [13]
+  xidRecoveryCooldown: 15m
+`,
+			expectedIgnored:  []uint64{48, 63},
+			expectedFatal:    []uint64{13},
+			expectedCooldown: 15 * time.Minute,
+		},
+		{
+			description: "xid that is both ignored and fatal is an error",
+			input: `
+version: v1
+health:
+  ignoredXids: [48]
+  fatalXids: [48]
+`,
+			expectedError: true,
+		},
+		{
+			description: "negative cool-down is an error",
+			input: `
+version: v1
+health:
+  xidRecoveryCooldown: -1m
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedIgnored, config.Health.GetIgnoredXids())
+			require.Equal(t, tc.expectedFatal, config.Health.GetFatalXids())
+			require.Equal(t, tc.expectedCooldown, config.Health.GetXidRecoveryCooldown())
+		})
+	}
+}
+
+func TestDCGMHealthConfig(t *testing.T) {
+	testCases := []struct {
+		description      string
+		input            string
+		expectedInterval time.Duration
+		expectedChecks   []DCGMHealthCheck
+		expectedError    bool
+	}{
+		{
+			description: "defaults",
+			input: `
+version: v1
+health:
+  dcgm: {}
+`,
+			expectedInterval: DefaultDCGMHealthInterval,
+			expectedChecks:   DefaultDCGMHealthChecks,
+		},
+		{
+			description: "checks with default severity",
+			input: `
+version: v1
+health:
+  dcgm:
+    address: unix:///run/dcgm.sock
+    interval: 10s
+    checks:
+    - system: memory
+    - system: nvlink
+      severity: warning
+`,
+			expectedInterval: 10 * time.Second,
+			expectedChecks: []DCGMHealthCheck{
+				{System: DCGMHealthSystemMemory, Severity: DCGMHealthSeverityFailure},
+				{System: DCGMHealthSystemNVLink, Severity: DCGMHealthSeverityWarning},
+			},
+		},
+		{
+			description: "unknown system is an error",
+			input: `
+version: v1
+health:
+  dcgm:
+    checks:
+    - system: fans
+`,
+			expectedError: true,
+		},
+		{
+			description: "unknown severity is an error",
+			input: `
+version: v1
+health:
+  dcgm:
+    checks:
+    - system: pcie
+      severity: critical
+`,
+			expectedError: true,
+		},
+		{
+			description: "duplicate system is an error",
+			input: `
+version: v1
+health:
+  dcgm:
+    checks:
+    - system: pcie
+    - system: pcie
+      severity: warning
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, config.Health.GetDCGM())
+			require.Equal(t, tc.expectedInterval, config.Health.GetDCGM().GetInterval())
+			require.EqualValues(t, tc.expectedChecks, config.Health.GetDCGM().GetChecks())
+		})
+	}
+}
+
+func TestThermalHealthConfig(t *testing.T) {
+	testCases := []struct {
+		description      string
+		input            string
+		expectedInterval time.Duration
+		expectedClasses  map[string]ThermalClass
+		expectedError    bool
+	}{
+		{
+			description: "thresholds per model",
+			input: `
+version: v1
+health:
+  thermal:
+    interval: 10s
+    thresholds:
+    - pattern: "*T4*"
+      degraded: 80
+      unhealthy: 90
+    - pattern: "*"
+      unhealthy: 95
+`,
+			expectedInterval: 10 * time.Second,
+			expectedClasses: map[string]ThermalClass{
+				"Tesla T4/79":         ThermalClassNormal,
+				"Tesla T4/80":         ThermalClassDegraded,
+				"Tesla T4/90":         ThermalClassUnhealthy,
+				"NVIDIA A100-SXM4/90": ThermalClassNormal,
+				"NVIDIA A100-SXM4/95": ThermalClassUnhealthy,
+			},
+		},
+		{
+			description: "default interval",
+			input: `
+version: v1
+health:
+  thermal:
+    thresholds:
+    - pattern: "*"
+      degraded: 85
+`,
+			expectedInterval: DefaultThermalHealthInterval,
+			expectedClasses: map[string]ThermalClass{
+				"Tesla T4/99": ThermalClassDegraded,
+			},
+		},
+		{
+			description: "missing pattern is an error",
+			input: `
+version: v1
+health:
+  thermal:
+    thresholds:
+    - unhealthy: 90
+`,
+			expectedError: true,
+		},
+		{
+			description: "missing thresholds is an error",
+			input: `
+version: v1
+health:
+  thermal:
+    thresholds:
+    - pattern: "*"
+`,
+			expectedError: true,
+		},
+		{
+			description: "degraded above unhealthy is an error",
+			input: `
+version: v1
+health:
+  thermal:
+    thresholds:
+    - pattern: "*"
+      degraded: 90
+      unhealthy: 80
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			thermal := config.Health.GetThermal()
+			require.Equal(t, tc.expectedInterval, thermal.GetInterval())
+			for key, expected := range tc.expectedClasses {
+				name, temperature, _ := strings.Cut(key, "/")
+				threshold := thermal.GetThreshold(name)
+				require.NotNil(t, threshold)
+				value, err := strconv.Atoi(temperature)
+				require.NoError(t, err)
+				require.Equal(t, expected, threshold.Classify(value), key)
+			}
+		})
+	}
+
+	var nilThermal *ThermalHealth
+	require.Nil(t, nilThermal.GetThreshold("Tesla T4"))
+}
+
+func TestECCHealthConfig(t *testing.T) {
+	testCases := []struct {
+		description      string
+		input            string
+		expectedInterval time.Duration
+		expectedJitter   float64
+		expectedCooldown time.Duration
+		expectedError    bool
+	}{
+		{
+			description: "defaults",
+			input: `
+version: v1
+health:
+  ecc: {}
+`,
+			expectedInterval: DefaultECCHealthInterval,
+			expectedJitter:   DefaultHealthIntervalJitter,
+		},
+		{
+			description: "interval and jitter",
+			input: `
+version: v1
+health:
+  intervalJitter: 25%
+  ecc:
+    interval: 10m
+`,
+			expectedInterval: 10 * time.Minute,
+			expectedJitter:   0.25,
+		},
+		{
+			description: "recovery cool-down",
+			input: `
+version: v1
+health:
+  ecc:
+    recoveryCooldown: 1h
+`,
+			expectedInterval: DefaultECCHealthInterval,
+			expectedJitter:   DefaultHealthIntervalJitter,
+			expectedCooldown: time.Hour,
+		},
+		{
+			description: "negative interval is an error",
+			input: `
+version: v1
+health:
+  ecc:
+    interval: -1m
+`,
+			expectedError: true,
+		},
+		{
+			description: "negative recovery cool-down is an error",
+			input: `
+version: v1
+health:
+  ecc:
+    recoveryCooldown: -1m
+`,
+			expectedError: true,
+		},
+		{
+			description: "jitter above 100% is an error",
+			input: `
+version: v1
+health:
+  intervalJitter: 150%
+  ecc: {}
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedInterval, config.Health.GetECC().GetInterval())
+			require.Equal(t, tc.expectedJitter, config.Health.GetIntervalJitter())
+			require.Equal(t, tc.expectedCooldown, config.Health.GetECC().GetRecoveryCooldown())
+		})
+	}
+}
+
+func TestLinkHealthConfig(t *testing.T) {
+	testCases := []struct {
+		description            string
+		input                  string
+		expectedSampleInterval time.Duration
+		expectedLinks          *LinkHealth
+		expectedError          bool
+	}{
+		{
+			description:            "links disabled",
+			input:                  `version: v1`,
+			expectedSampleInterval: DefaultLinkHealthSampleInterval,
+		},
+		{
+			description: "default sample interval",
+			input: `
+version: v1
+health:
+  links: {}
+`,
+			expectedSampleInterval: DefaultLinkHealthSampleInterval,
+			expectedLinks:          &LinkHealth{},
+		},
+		{
+			description: "thresholds",
+			input: `
+version: v1
+health:
+  links:
+    sampleInterval: 10s
+    maxPCIeReplayRate: 0.5
+    maxNVLinkErrorRate: 2
+`,
+			expectedSampleInterval: 10 * time.Second,
+			expectedLinks: &LinkHealth{
+				SampleInterval:     ptr(Duration(10 * time.Second)),
+				MaxPCIeReplayRate:  0.5,
+				MaxNVLinkErrorRate: 2,
+			},
+		},
+		{
+			description: "negative rate is an error",
+			input: `
+version: v1
+health:
+  links:
+    maxPCIeReplayRate: -1
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedLinks, config.Health.GetLinks())
+			require.Equal(t, tc.expectedSampleInterval, config.Health.GetLinks().GetSampleInterval())
+		})
+	}
+}
+
+func TestPerformanceHealthConfig(t *testing.T) {
+	testCases := []struct {
+		description            string
+		input                  string
+		expectedPerformance    *PerformanceHealth
+		expectedSamples        int
+		expectedMinUtilization int
+		expectedError          bool
+	}{
+		{
+			description:            "performance disabled",
+			input:                  `version: v1`,
+			expectedSamples:        DefaultPerformanceHealthSamples,
+			expectedMinUtilization: DefaultPerformanceHealthMinUtilization,
+		},
+		{
+			description: "defaults",
+			input: `
+version: v1
+health:
+  performance: {}
+`,
+			expectedPerformance:    &PerformanceHealth{},
+			expectedSamples:        DefaultPerformanceHealthSamples,
+			expectedMinUtilization: DefaultPerformanceHealthMinUtilization,
+		},
+		{
+			description: "custom sampling",
+			input: `
+version: v1
+health:
+  performance:
+    samples: 10
+    sampleInterval: 2s
+    minUtilization: 80
+`,
+			expectedPerformance: &PerformanceHealth{
+				Samples:        10,
+				SampleInterval: ptr(Duration(2 * time.Second)),
+				MinUtilization: 80,
+			},
+			expectedSamples:        10,
+			expectedMinUtilization: 80,
+		},
+		{
+			description: "utilization above 100 is an error",
+			input: `
+version: v1
+health:
+  performance:
+    minUtilization: 101
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPerformance, config.Health.GetPerformance())
+			require.Equal(t, tc.expectedSamples, config.Health.GetPerformance().GetSamples())
+			require.Equal(t, tc.expectedMinUtilization, config.Health.GetPerformance().GetMinUtilization())
+		})
+	}
+}
+
+func TestWearHealthConfig(t *testing.T) {
+	config, err := parseConfigFrom(strings.NewReader(`
+version: v1
+health:
+  wear:
+    maxRetiredPages: 10
+    maxRemappedRows: 4
+`))
+	require.NoError(t, err)
+	wear := config.Health.GetWear()
+	require.Equal(t, &WearHealth{MaxRetiredPages: 10, MaxRemappedRows: 4}, wear)
+
+	count := func(c uint64) *uint64 { return &c }
+	require.False(t, wear.IsWorn(count(10), count(4)))
+	require.True(t, wear.IsWorn(count(11), nil))
+	require.True(t, wear.IsWorn(nil, count(5)))
+	require.False(t, (&WearHealth{}).IsWorn(count(100), count(100)))
+	require.False(t, (*WearHealth)(nil).IsWorn(count(100), count(100)))
+}
+
+func TestHealthResourceConfig(t *testing.T) {
+	testCases := []struct {
+		description     string
+		input           string
+		expectedEnabled map[HealthCheck]bool
+		expectedSkipped []uint64
+		expectedError   bool
+	}{
+		{
+			description: "all checks enabled by default",
+			input:       `version: v1`,
+			expectedEnabled: map[HealthCheck]bool{
+				HealthCheckAll:     true,
+				HealthCheckXids:    true,
+				HealthCheckDCGM:    true,
+				HealthCheckThermal: true,
+			},
+		},
+		{
+			description: "checks disabled for the resource",
+			input: `
+version: v1
+health:
+  resources:
+  - name: nvidia.com/gpu
+    disabledChecks: [xids, thermal]
+    skippedXids: [48, 79]
+  - name: nvidia.com/gpu.shared
+    disabledChecks: [all]
+`,
+			expectedEnabled: map[HealthCheck]bool{
+				HealthCheckAll:     true,
+				HealthCheckXids:    false,
+				HealthCheckDCGM:    true,
+				HealthCheckThermal: false,
+			},
+			expectedSkipped: []uint64{48, 79},
+		},
+		{
+			description: "all checks disabled for the resource",
+			input: `
+version: v1
+health:
+  resources:
+  - name: nvidia.com/gpu
+    disabledChecks: [all]
+`,
+			expectedEnabled: map[HealthCheck]bool{
+				HealthCheckAll:     false,
+				HealthCheckXids:    false,
+				HealthCheckDCGM:    false,
+				HealthCheckThermal: false,
+			},
+		},
+		{
+			description: "unknown check is an error",
+			input: `
+version: v1
+health:
+  resources:
+  - name: nvidia.com/gpu
+    disabledChecks: [fans]
+`,
+			expectedError: true,
+		},
+		{
+			description: "missing name is an error",
+			input: `
+version: v1
+health:
+  resources:
+  - disabledChecks: [all]
+`,
+			expectedError: true,
+		},
+		{
+			description: "duplicate resource is an error",
+			input: `
+version: v1
+health:
+  resources:
+  - name: nvidia.com/gpu
+  - name: nvidia.com/gpu
+`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			r := config.Health.ForResource("nvidia.com/gpu")
+			for check, enabled := range tc.expectedEnabled {
+				require.Equal(t, enabled, r.IsEnabled(check), check)
+			}
+			require.Equal(t, tc.expectedSkipped, r.SkippedXids)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Profile is the name of a preset sharing config.
+type Profile string
+
+// Preset profiles for common inference setups. The MPS profiles limit each
+// replica to an even share of the memory of its GPU and allow it to use more
+// than its share of the threads of the GPU. All profiles enable the lazy
+// loading of CUDA modules, which reduces the memory used by each client.
+const (
+	ProfileInferenceSmall       = Profile("inference-small")
+	ProfileInferenceMedium      = Profile("inference-medium")
+	ProfileInferenceLarge       = Profile("inference-large")
+	ProfileInferenceTimeSlicing = Profile("inference-time-slicing")
+)
+
+// profiles maps each preset profile to the sharing config that it expands to.
+var profiles = map[Profile]string{
+	ProfileInferenceSmall: `
+mps:
+  resources:
+  - name: nvidia.com/gpu
+    replicas: 8
+    memoryLimit: 1/8
+    threadPercentage: 25
+    envs:
+      CUDA_MODULE_LOADING: LAZY
+`,
+	ProfileInferenceMedium: `
+mps:
+  resources:
+  - name: nvidia.com/gpu
+    replicas: 4
+    memoryLimit: 1/4
+    threadPercentage: 50
+    envs:
+      CUDA_MODULE_LOADING: LAZY
+`,
+	ProfileInferenceLarge: `
+mps:
+  resources:
+  - name: nvidia.com/gpu
+    replicas: 2
+    memoryLimit: 1/2
+    threadPercentage: 75
+    envs:
+      CUDA_MODULE_LOADING: LAZY
+`,
+	ProfileInferenceTimeSlicing: `
+timeSlicing:
+  failRequestsGreaterThanOne: true
+  resources:
+  - name: nvidia.com/gpu
+    replicas: 4
+    envs:
+      CUDA_MODULE_LOADING: LAZY
+`,
+}
+
+// Profiles returns the names of the preset profiles.
+func Profiles() []Profile {
+	var names []Profile
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// expandProfile replaces the sharing config with the sharing config of the
+// selected profile. A profile cannot be combined with an explicit sharing
+// config.
+func (c *Config) expandProfile() error {
+	if c.Profile == "" {
+		return nil
+	}
+	preset, exists := profiles[c.Profile]
+	if !exists {
+		var names []string
+		for _, name := range Profiles() {
+			names = append(names, string(name))
+		}
+		return fmt.Errorf("unknown profile %q; supported profiles are %v", c.Profile, strings.Join(names, ", "))
+	}
+	if c.Sharing.MPS != nil || len(c.Sharing.TimeSlicing.Resources) > 0 {
+		return fmt.Errorf("profile %q cannot be combined with a sharing config", c.Profile)
+	}
+	var sharing Sharing
+	if err := yaml.Unmarshal([]byte(preset), &sharing); err != nil {
+		return fmt.Errorf("invalid profile %q: %v", c.Profile, err)
+	}
+	// The allocation policy is independent of the profile.
+	sharing.AllocationPolicy = c.Sharing.AllocationPolicy
+	c.Sharing = sharing
+	return nil
+}
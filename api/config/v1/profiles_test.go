@@ -0,0 +1,113 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	for _, profile := range Profiles() {
+		t.Run(string(profile), func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader("version: v1\nprofile: " + string(profile)))
+			require.NoError(t, err)
+			require.NotEqual(t, SharingStrategyNone, config.Sharing.SharingStrategy())
+			for _, r := range config.Sharing.ReplicatedResources().Resources {
+				require.Equal(t, ResourceName("nvidia.com/gpu"), r.Name)
+				require.True(t, r.Devices.All)
+				require.Equal(t, map[string]string{"CUDA_MODULE_LOADING": "LAZY"}, r.Envs)
+				if config.Sharing.SharingStrategy() == SharingStrategyMPS {
+					require.NotNil(t, r.MemoryLimit)
+					require.InDelta(t, 1/float64(r.Replicas), r.MemoryLimit.Value(), 1e-9)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandProfile(t *testing.T) {
+	testCases := []struct {
+		description      string
+		input            string
+		expectedStrategy SharingStrategy
+		expectedReplicas int
+		expectedError    string
+	}{
+		{
+			description:      "no profile",
+			input:            `version: v1`,
+			expectedStrategy: SharingStrategyNone,
+		},
+		{
+			description: "mps profile",
+			input: `
+version: v1
+profile: inference-small
+`,
+			expectedStrategy: SharingStrategyMPS,
+			expectedReplicas: 8,
+		},
+		{
+			description: "time-slicing profile",
+			input: `
+version: v1
+profile: inference-time-slicing
+`,
+			expectedStrategy: SharingStrategyTimeSlicing,
+			expectedReplicas: 4,
+		},
+		{
+			description: "unknown profile is an error",
+			input: `
+version: v1
+profile: training
+`,
+			expectedError: `unknown profile "training"; supported profiles are inference-large, inference-medium, inference-small, inference-time-slicing`,
+		},
+		{
+			description: "profile with sharing config is an error",
+			input: `
+version: v1
+profile: inference-medium
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+`,
+			expectedError: `profile "inference-medium" cannot be combined with a sharing config`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedStrategy, config.Sharing.SharingStrategy())
+			if tc.expectedReplicas > 0 {
+				require.Equal(t, tc.expectedReplicas, config.Sharing.ReplicatedResources().Resources[0].Replicas)
+			}
+		})
+	}
+}
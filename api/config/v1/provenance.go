@@ -0,0 +1,121 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	cli "github.com/urfave/cli/v2"
+
+	"sigs.k8s.io/yaml"
+)
+
+// These constants represent the sources that the values of a config can be read from.
+const (
+	SourceDefault = "default"
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceCRD     = "crd"
+	// SourceFragment is the source of the values read from config fragments.
+	SourceFragment = "fragment"
+)
+
+// CRDConfigHeader is the first line of the config files that the
+// config-manager generates from the device plugin config custom resources.
+const CRDConfigHeader = "# source: crd\n"
+
+// Provenance maps the values of a config to the source that they were read
+// from. Values read from the config file are keyed by their path in the file
+// (e.g. sharing.timeSlicing.resources[0].replicas) and command line flags are
+// keyed by their name (e.g. --mig-strategy). Flags override the corresponding
+// values in the config file. Values without an entry have their default value.
+type Provenance map[string]string
+
+// Source returns the source of the value with the specified key.
+func (p Provenance) Source(key string) string {
+	if source, ok := p[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// addContents records the values that are set in the specified config file
+// contents. Files generated from custom resources are recorded as such.
+func (p Provenance) addContents(contents []byte) error {
+	source := SourceFile
+	if bytes.HasPrefix(contents, []byte(CRDConfigHeader)) {
+		source = SourceCRD
+	}
+	var values interface{}
+	if err := yaml.Unmarshal(contents, &values); err != nil {
+		return err
+	}
+	p.addValues("", values, source)
+	return nil
+}
+
+func (p Provenance) addValues(path string, value interface{}, source string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path != "" {
+				key = path + "." + key
+			}
+			p.addValues(key, child, source)
+		}
+	case []interface{}:
+		for i, child := range v {
+			p.addValues(fmt.Sprintf("%s[%d]", path, i), child, source)
+		}
+	default:
+		if path != "" {
+			p[path] = source
+		}
+	}
+}
+
+// remove removes the values with the specified path and the values nested
+// below it.
+func (p Provenance) remove(path string) {
+	for key := range p {
+		if key == path || strings.HasPrefix(key, path+".") || strings.HasPrefix(key, path+"[") {
+			delete(p, key)
+		}
+	}
+}
+
+// addFlags records the command line flags that are set on the command line or
+// through their envvars.
+func (p Provenance) addFlags(c *cli.Context, flags []cli.Flag) {
+	for _, flag := range flags {
+		name := flag.Names()[0]
+		if !c.IsSet(name) {
+			continue
+		}
+		// A flag is only marked as set if its value was read from an envvar.
+		// If a flag is also passed on the command line, its value takes
+		// precedence but the flag is still attributed to the envvar.
+		source := SourceFlag
+		if flag.IsSet() {
+			source = SourceEnv
+		}
+		p["--"+name] = source
+	}
+}
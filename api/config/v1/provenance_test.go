@@ -0,0 +1,105 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cli "github.com/urfave/cli/v2"
+)
+
+func TestNewConfigFromFileProvenance(t *testing.T) {
+	testCases := []struct {
+		description        string
+		contents           string
+		args               []string
+		env                map[string]string
+		expectedProvenance Provenance
+	}{
+		{
+			description:        "defaults",
+			expectedProvenance: Provenance{},
+		},
+		{
+			description: "config file",
+			contents: `version: v1
+flags:
+  migStrategy: single
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+`,
+			expectedProvenance: Provenance{
+				"version":                                   SourceFile,
+				"flags.migStrategy":                         SourceFile,
+				"sharing.timeSlicing.resources[0].name":     SourceFile,
+				"sharing.timeSlicing.resources[0].replicas": SourceFile,
+			},
+		},
+		{
+			description: "config file generated from custom resources",
+			contents:    CRDConfigHeader + `{"version":"v1","sharing":{"timeSlicing":{"resources":[{"name":"nvidia.com/gpu","replicas":2}]}}}`,
+			expectedProvenance: Provenance{
+				"version":                                   SourceCRD,
+				"sharing.timeSlicing.resources[0].name":     SourceCRD,
+				"sharing.timeSlicing.resources[0].replicas": SourceCRD,
+			},
+		},
+		{
+			description: "flags and envvars",
+			args:        []string{"--mig-strategy=mixed"},
+			env:         map[string]string{"TEST_FAIL_ON_INIT_ERROR": "false"},
+			expectedProvenance: Provenance{
+				"--mig-strategy":       SourceFlag,
+				"--fail-on-init-error": SourceEnv,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			var configFile string
+			if tc.contents != "" {
+				configFile = filepath.Join(t.TempDir(), "config.yaml")
+				require.NoError(t, os.WriteFile(configFile, []byte(tc.contents), 0644))
+			}
+
+			flags := []cli.Flag{
+				&cli.StringFlag{Name: "mig-strategy", Value: MigStrategyNone, EnvVars: []string{"TEST_MIG_STRATEGY"}},
+				&cli.BoolFlag{Name: "fail-on-init-error", Value: true, EnvVars: []string{"TEST_FAIL_ON_INIT_ERROR"}},
+			}
+			var config *Config
+			c := cli.NewApp()
+			c.Flags = flags
+			c.Action = func(c *cli.Context) error {
+				var err error
+				config, err = NewConfigFromFile(c, flags, configFile)
+				return err
+			}
+			require.NoError(t, c.Run(append([]string{"test"}, tc.args...)))
+			require.Equal(t, tc.expectedProvenance, config.Provenance)
+		})
+	}
+}
@@ -19,20 +19,157 @@ package v1
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
 )
 
+// ReplicaUnit defines the unit in which the capacity of a replicated resource is expressed.
+type ReplicaUnit string
+
+// These constants define the supported replica units.
+const (
+	// ReplicaUnitReplicas advertises each device with the configured number of replicas.
+	ReplicaUnitReplicas = ReplicaUnit("replicas")
+	// ReplicaUnitMilli advertises each device with a capacity of MilliUnitsPerDevice
+	// units so that fractional devices can be requested.
+	ReplicaUnitMilli = ReplicaUnit("milli")
+	// ReplicaUnitMemory advertises each device with one replica per chunk of
+	// its memory, so that an amount of memory of any device can be requested.
+	ReplicaUnitMemory = ReplicaUnit("memory")
+)
+
+// MilliUnitsPerDevice is the capacity of a single device if the milli replica unit is used.
+const MilliUnitsPerDevice = 1000
+
+// DefaultMemoryChunkMB is the size in MB of each replica if the memory replica
+// unit is used and no chunk size is configured.
+const DefaultMemoryChunkMB = 1024
+
+// DefaultMPSServerMemoryOverheadMB is the memory in MB that is assumed to be
+// used by the context that the MPS server creates on each GPU if no overhead
+// is configured for a resource. No overhead is assumed by default so that the
+// memory limits of existing configs are unchanged.
+const DefaultMPSServerMemoryOverheadMB = 0
+
+// MPSThreadPercentageAnnotation is the pod annotation that requests the
+// active thread percentage of the MPS clients of a pod. It is only honored for
+// resources with MaxThreadPercentage.
+const MPSThreadPercentageAnnotation = "nvidia.com/mps-thread-percentage"
+
 // ReplicatedResources defines generic options for replicating devices.
 type ReplicatedResources struct {
-	RenameByDefault            bool                 `json:"renameByDefault,omitempty"            yaml:"renameByDefault,omitempty"`
-	FailRequestsGreaterThanOne bool                 `json:"failRequestsGreaterThanOne,omitempty" yaml:"failRequestsGreaterThanOne,omitempty"`
-	Resources                  []ReplicatedResource `json:"resources,omitempty"                  yaml:"resources,omitempty"`
+	RenameByDefault            bool `json:"renameByDefault,omitempty"            yaml:"renameByDefault,omitempty"`
+	FailRequestsGreaterThanOne bool `json:"failRequestsGreaterThanOne,omitempty" yaml:"failRequestsGreaterThanOne,omitempty"`
+	// Unit defines the unit in which the capacity of the replicated resources
+	// is advertised. If this is set to 'milli', each device is advertised with
+	// a capacity of 1000 and the replicas for the resources need not be set.
+	// If this is set to 'memory', the replicas are chunks of device memory and
+	// must not be set.
+	Unit ReplicaUnit `json:"unit,omitempty"                       yaml:"unit,omitempty"`
+	// MemoryChunkMB is the size in MB of each replica if the unit is set to
+	// 'memory'. Each device is advertised with as many replicas as chunks fit
+	// into the memory that remains after the MPS server memory overhead is
+	// subtracted, and the pinned memory limit of a client is the total size
+	// of the chunks it is allocated.
+	MemoryChunkMB *uint64              `json:"memoryChunkMB,omitempty"              yaml:"memoryChunkMB,omitempty"`
+	Resources     []ReplicatedResource `json:"resources,omitempty"                  yaml:"resources,omitempty"`
+	// UserID and GroupID set the identity under which the MPS control daemons
+	// and their servers are run, so that they do not run as root. They can be
+	// overridden per resource. By default the identity of the MPS control
+	// daemon container is used, also for the ID that is left unset when only
+	// one of them is set. Since an MPS server only serves clients of its own
+	// user, containers that are allocated the resource must run as UserID.
+	// This is only supported for resources shared using MPS.
+	UserID  *int64 `json:"userID,omitempty"                     yaml:"userID,omitempty"`
+	GroupID *int64 `json:"groupID,omitempty"                    yaml:"groupID,omitempty"`
+}
+
+// IsMilli checks whether the capacity of the replicated resources is expressed in milli-device units.
+func (rrs *ReplicatedResources) IsMilli() bool {
+	return rrs != nil && rrs.Unit == ReplicaUnitMilli
+}
+
+// IsMemory checks whether the capacity of the replicated resources is expressed in chunks of memory.
+func (rrs *ReplicatedResources) IsMemory() bool {
+	return rrs != nil && rrs.Unit == ReplicaUnitMemory
+}
+
+// GetMemoryChunkMB returns the size in MB of each replica if the capacity of
+// the replicated resources is expressed in chunks of memory, or 0 otherwise.
+func (rrs *ReplicatedResources) GetMemoryChunkMB() uint64 {
+	if !rrs.IsMemory() {
+		return 0
+	}
+	if rrs.MemoryChunkMB == nil {
+		return DefaultMemoryChunkMB
+	}
+	return *rrs.MemoryChunkMB
+}
+
+// MemoryChunks returns the number of memory chunks of the specified resource
+// that fit into a device with the specified total memory in bytes. The memory
+// used by the MPS server is subtracted first.
+func (rrs *ReplicatedResources) MemoryChunks(r *ReplicatedResource, totalMemory uint64) int {
+	chunkMB := rrs.GetMemoryChunkMB()
+	if chunkMB == 0 {
+		return 0
+	}
+	totalMemoryMB := totalMemory / 1024 / 1024
+	overhead := r.GetServerMemoryOverheadMB()
+	if totalMemoryMB <= overhead {
+		return 0
+	}
+	return int((totalMemoryMB - overhead) / chunkMB)
 }
 
-func (rrs *ReplicatedResources) disableResoureRenaming(logger logger, id string) {
+// GetUserID returns the user ID under which the MPS daemon of the specified
+// resource is run, or nil if the identity of the MPS control daemon container
+// is used.
+func (rrs *ReplicatedResources) GetUserID(r *ReplicatedResource) *int64 {
+	if r != nil && r.UserID != nil {
+		return r.UserID
+	}
+	if rrs == nil {
+		return nil
+	}
+	return rrs.UserID
+}
+
+// GetGroupID returns the group ID under which the MPS daemon of the specified
+// resource is run, or nil if the identity of the MPS control daemon container
+// is used.
+func (rrs *ReplicatedResources) GetGroupID(r *ReplicatedResource) *int64 {
+	if r != nil && r.GroupID != nil {
+		return r.GroupID
+	}
+	if rrs == nil {
+		return nil
+	}
+	return rrs.GroupID
+}
+
+// ForResource returns the replicated resource that results in the specified
+// (possibly renamed) resource name. If no such resource exists, nil is returned.
+func (rrs *ReplicatedResources) ForResource(name ResourceName) *ReplicatedResource {
+	if rrs == nil {
+		return nil
+	}
+	for i, r := range rrs.Resources {
+		if r.Rename == name || (r.Rename == "" && r.Name == name) {
+			return &rrs.Resources[i]
+		}
+	}
+	return nil
+}
+
+// disableResoureRenaming resets the renames and device selections of the
+// resources. Resources that are split between time-slicing and MPS are kept
+// as is, since their devices can only be told apart by name.
+func (rrs *ReplicatedResources) disableResoureRenaming(logger logger, id string, split map[ResourceName]bool) {
 	if rrs == nil {
 		return
 	}
@@ -40,6 +177,9 @@ func (rrs *ReplicatedResources) disableResoureRenaming(logger logger, id string)
 	setsNonDefaultRename := false
 	setsDevices := false
 	for i, r := range rrs.Resources {
+		if split[r.Name] {
+			continue
+		}
 		if !renameByDefault && r.Rename != "" {
 			setsNonDefaultRename = true
 			rrs.Resources[i].Rename = ""
@@ -64,10 +204,41 @@ func (rrs *ReplicatedResources) disableResoureRenaming(logger logger, id string)
 
 }
 
+// shares returns whether a resource with the specified name is shared or
+// renamed to the specified name.
+func (rrs *ReplicatedResources) shares(name ResourceName) bool {
+	if rrs == nil {
+		return false
+	}
+	for _, r := range rrs.Resources {
+		if r.Name == name || r.Rename == name {
+			return true
+		}
+	}
+	return false
+}
+
+// forName returns the resource with the specified (original) name, or nil if
+// no such resource exists.
+func (rrs *ReplicatedResources) forName(name ResourceName) *ReplicatedResource {
+	if rrs == nil {
+		return nil
+	}
+	for i, r := range rrs.Resources {
+		if r.Name == name {
+			return &rrs.Resources[i]
+		}
+	}
+	return nil
+}
+
 func (rrs *ReplicatedResources) isReplicated() bool {
 	if rrs == nil {
 		return false
 	}
+	if rrs.IsMemory() && len(rrs.Resources) > 0 {
+		return true
+	}
 	for _, rr := range rrs.Resources {
 		if rr.Replicas > 1 {
 			return true
@@ -78,12 +249,224 @@ func (rrs *ReplicatedResources) isReplicated() bool {
 
 // ReplicatedResource represents a resource to be replicated.
 type ReplicatedResource struct {
-	Name     ResourceName      `json:"name"             yaml:"name"`
-	Rename   ResourceName      `json:"rename,omitempty" yaml:"rename,omitempty"`
-	Devices  ReplicatedDevices `json:"devices"          yaml:"devices,flow"`
-	Replicas int               `json:"replicas"         yaml:"replicas"`
+	Name     ResourceName      `json:"name"                             yaml:"name"`
+	Rename   ResourceName      `json:"rename,omitempty"                 yaml:"rename,omitempty"`
+	Devices  ReplicatedDevices `json:"devices"                          yaml:"devices,flow"`
+	Replicas int               `json:"replicas"                         yaml:"replicas"`
+	// AdvertiseWhole advertises the shared GPUs under the original resource
+	// name in addition to the renamed shared resource. Once a GPU is
+	// allocated in one form, its devices of the other form are marked
+	// unhealthy until the GPU is released.
+	// This is only supported for resources shared using time-slicing.
+	AdvertiseWhole bool `json:"advertiseWhole,omitempty"         yaml:"advertiseWhole,omitempty"`
+	// BurstReplicas is the number of additional replicas of each GPU that
+	// are only advertised as healthy once all base replicas of the resource
+	// are allocated. This allows conservative sharing by default with
+	// headroom under pressure.
+	// This is only supported for resources shared using time-slicing.
+	BurstReplicas int `json:"burstReplicas,omitempty"          yaml:"burstReplicas,omitempty"`
+	// LogDirectory overrides the log directory of the MPS control daemon for
+	// this resource. A relative path is interpreted relative to the MPS root.
+	// This is only supported for resources shared using MPS.
+	LogDirectory string `json:"logDirectory,omitempty"           yaml:"logDirectory,omitempty"`
+	// PipeDirectory overrides the pipe directory of the MPS control daemon
+	// for this resource. It must be a path relative to the MPS root, since
+	// the pipe directory is mounted into the containers that are allocated
+	// the resource.
+	// This is only supported for resources shared using MPS.
+	PipeDirectory string `json:"pipeDirectory,omitempty"          yaml:"pipeDirectory,omitempty"`
+	// PipeDirectorySizeMB is the size in MB of a tmpfs that is mounted at the
+	// pipe directory of the resource before the MPS control daemon is
+	// started. It requires PipeDirectory to be set.
+	// This is only supported for resources shared using MPS.
+	PipeDirectorySizeMB *uint64 `json:"pipeDirectorySizeMB,omitempty"    yaml:"pipeDirectorySizeMB,omitempty"`
+	// ClientAffinity selects how MPS clients are assigned to the GPUs of a
+	// daemon that manages more than one GPU.
+	// This is only supported for resources shared using MPS.
+	ClientAffinity ClientAffinityPolicy `json:"clientAffinity,omitempty"         yaml:"clientAffinity,omitempty"`
+	// ServerMemoryOverheadMB is the memory in MB used by the context of the
+	// MPS server on each GPU. It is subtracted from the memory of each GPU
+	// before the memory is split between the replicas.
+	// This is only supported for resources shared using MPS.
+	ServerMemoryOverheadMB *uint64 `json:"serverMemoryOverheadMB,omitempty" yaml:"serverMemoryOverheadMB,omitempty"`
+	// MemoryLimit overrides the pinned memory limit of each replica as a
+	// fraction (e.g. 1/8) of the memory of its GPU that remains after the
+	// server memory overhead is subtracted. It is resolved per GPU, so that
+	// the same limit applies to GPUs with different memory sizes. By default
+	// the memory is split evenly between the replicas of a GPU.
+	// This is only supported for resources shared using MPS.
+	MemoryLimit *Fraction `json:"memoryLimit,omitempty"            yaml:"memoryLimit,omitempty"`
+	// MemoryLimitMB overrides the pinned memory limit of each replica with an
+	// explicit limit in MB. The limits of all replicas of a GPU must fit into
+	// the memory of the GPU that remains after the server memory overhead is
+	// subtracted. It cannot be combined with MemoryLimit.
+	// This is only supported for resources shared using MPS.
+	MemoryLimitMB *uint64 `json:"memoryLimitMB,omitempty"          yaml:"memoryLimitMB,omitempty"`
+	// ReplicaMemoryLimitsMB lists an explicit pinned memory limit in MB for
+	// each replica of a GPU, so that the replicas of a GPU can be sized
+	// differently. It must have one entry per replica and cannot be combined
+	// with MemoryLimit or MemoryLimitMB.
+	// This is only supported for resources shared using MPS.
+	ReplicaMemoryLimitsMB []uint64 `json:"replicaMemoryLimitsMB,omitempty"  yaml:"replicaMemoryLimitsMB,omitempty,flow"`
+	// ThreadLimit overrides the active thread percentage of each replica as a
+	// fraction (e.g. 25%) of the threads of its GPU. By default the threads are
+	// split evenly between the replicas of a GPU.
+	// This is only supported for resources shared using MPS.
+	ThreadLimit *Fraction `json:"threadLimit,omitempty"            yaml:"threadLimit,omitempty"`
+	// ThreadPercentage overrides the active thread percentage of each replica
+	// as a whole percentage between 1 and 100. It is a shorthand for a
+	// ThreadLimit given as a percentage and cannot be combined with it.
+	// This is only supported for resources shared using MPS.
+	ThreadPercentage *int `json:"threadPercentage,omitempty"       yaml:"threadPercentage,omitempty"`
+	// MaxThreadPercentage allows pods to request the active thread percentage
+	// of their MPS clients through the MPSThreadPercentageAnnotation, up to
+	// this whole percentage between 1 and 100.
+	// This is only supported for resources shared using MPS.
+	MaxThreadPercentage *int `json:"maxThreadPercentage,omitempty"    yaml:"maxThreadPercentage,omitempty"`
+	// TrustedWorkloads selects the pods that bypass the thread and memory
+	// limits of the replicas they are allocated, so that system agents such
+	// as profilers can use the whole GPU.
+	// This is only supported for resources shared using MPS.
+	TrustedWorkloads *TrustedWorkloads `json:"trustedWorkloads,omitempty"       yaml:"trustedWorkloads,omitempty"`
+	// PerMigDevice starts a separate MPS control daemon for each MIG device
+	// of this resource, so that MIG devices can be shared using MPS. Each
+	// daemon only makes its MIG device visible to its MPS server and clients.
+	// This is only supported for resources shared using MPS.
+	PerMigDevice bool `json:"perMigDevice,omitempty"           yaml:"perMigDevice,omitempty"`
+	// EnvPassthrough lists the environment variables that are propagated from
+	// the environment of the MPS control daemon container to the MPS daemons
+	// of this resource, e.g. proxy settings or
+	// CUDA_MPS_ENABLE_PER_CTX_DEVICE_MULTIPROCESSOR_PARTITIONING. Variables
+	// that are not set in the container are ignored.
+	// This is only supported for resources shared using MPS.
+	EnvPassthrough []string `json:"envPassthrough,omitempty"         yaml:"envPassthrough,omitempty"`
+	// Envs are set in the containers that are allocated the resource. The
+	// variables that are controlled by the device plugin, i.e. those with the
+	// NVIDIA_ or CUDA_MPS_ prefix and LD_LIBRARY_PATH, cannot be set.
+	Envs map[string]string `json:"envs,omitempty"                   yaml:"envs,omitempty"`
+	// UserID and GroupID override the identity under which the MPS daemon of
+	// this resource is run.
+	// This is only supported for resources shared using MPS.
+	UserID  *int64 `json:"userID,omitempty"                 yaml:"userID,omitempty"`
+	GroupID *int64 `json:"groupID,omitempty"                yaml:"groupID,omitempty"`
+}
+
+// TrustedWorkloads selects pods by their namespace or service account.
+type TrustedWorkloads struct {
+	// Namespaces lists the namespaces whose pods are trusted.
+	Namespaces []string `json:"namespaces,omitempty"      yaml:"namespaces,omitempty"`
+	// ServiceAccounts lists the service accounts, as namespace/name, whose
+	// pods are trusted.
+	ServiceAccounts []string `json:"serviceAccounts,omitempty" yaml:"serviceAccounts,omitempty"`
 }
 
+// Trusts checks whether a pod with the specified namespace and service
+// account is trusted.
+func (t *TrustedWorkloads) Trusts(namespace string, serviceAccount string) bool {
+	if t == nil {
+		return false
+	}
+	if slices.Contains(t.Namespaces, namespace) {
+		return true
+	}
+	return serviceAccount != "" && slices.Contains(t.ServiceAccounts, namespace+"/"+serviceAccount)
+}
+
+func (t *TrustedWorkloads) validate() error {
+	if len(t.Namespaces) == 0 && len(t.ServiceAccounts) == 0 {
+		return fmt.Errorf("at least one namespace or service account must be specified")
+	}
+	for _, namespace := range t.Namespaces {
+		if namespace == "" || strings.Contains(namespace, "/") {
+			return fmt.Errorf("%q is not a valid namespace", namespace)
+		}
+	}
+	for _, serviceAccount := range t.ServiceAccounts {
+		namespace, name, found := strings.Cut(serviceAccount, "/")
+		if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("service account %q must be given as namespace/name", serviceAccount)
+		}
+	}
+	return nil
+}
+
+// GetServerMemoryOverheadMB returns the memory in MB used by the context of
+// the MPS server on each GPU.
+func (r *ReplicatedResource) GetServerMemoryOverheadMB() uint64 {
+	if r == nil || r.ServerMemoryOverheadMB == nil {
+		return DefaultMPSServerMemoryOverheadMB
+	}
+	return *r.ServerMemoryOverheadMB
+}
+
+// GetThreadLimit returns the active thread percentage of each replica as a
+// fraction of the threads of its GPU, or nil if the threads are split evenly
+// between the replicas.
+func (r *ReplicatedResource) GetThreadLimit() *Fraction {
+	switch {
+	case r == nil:
+		return nil
+	case r.ThreadLimit != nil:
+		return r.ThreadLimit
+	case r.ThreadPercentage != nil:
+		return &Fraction{
+			raw:   fmt.Sprintf("%d%%", *r.ThreadPercentage),
+			value: float64(*r.ThreadPercentage) / 100,
+		}
+	}
+	return nil
+}
+
+// ReplicaMemoryLimitMB returns the explicit pinned memory limit in MB of the
+// specified replica of a GPU, or 0 if no explicit limit is configured.
+func (r *ReplicatedResource) ReplicaMemoryLimitMB(replica int) uint64 {
+	switch {
+	case r == nil:
+		return 0
+	case r.MemoryLimitMB != nil:
+		return *r.MemoryLimitMB
+	case replica >= 0 && replica < len(r.ReplicaMemoryLimitsMB):
+		return r.ReplicaMemoryLimitsMB[replica]
+	}
+	return 0
+}
+
+// ReplicaMemoryMB returns the memory in MB available to each replica of a GPU
+// with the specified total memory if the GPU is shared using MPS. The memory
+// used by the MPS server is subtracted before the memory is split between the
+// replicas, or before the configured memory limit is applied to it. If the
+// overhead exceeds the total memory, 0 is returned. If explicit limits are
+// configured per replica, the smallest limit is returned.
+func (r *ReplicatedResource) ReplicaMemoryMB(totalMemoryMB uint64) uint64 {
+	overhead := r.GetServerMemoryOverheadMB()
+	if totalMemoryMB <= overhead {
+		return 0
+	}
+	if r != nil && r.MemoryLimit != nil {
+		return r.MemoryLimit.Of(totalMemoryMB - overhead)
+	}
+	if r != nil && r.MemoryLimitMB != nil {
+		return *r.MemoryLimitMB
+	}
+	if r != nil && len(r.ReplicaMemoryLimitsMB) > 0 {
+		return slices.Min(r.ReplicaMemoryLimitsMB)
+	}
+	replicas := uint64(1)
+	if r != nil && r.Replicas > 1 {
+		replicas = uint64(r.Replicas)
+	}
+	return (totalMemoryMB - overhead) / replicas
+}
+
+// ClientAffinityPolicy defines how clients are assigned to the GPUs of an MPS daemon.
+type ClientAffinityPolicy string
+
+// Constants representing the supported client affinity policies.
+const (
+	ClientAffinityRoundRobin  ClientAffinityPolicy = "round-robin"
+	ClientAffinityLeastLoaded ClientAffinityPolicy = "least-loaded"
+)
+
 // ReplicatedDevices encapsulates the set of devices that should be replicated for a given resource.
 // This struct should be treated as a 'union' and only one of the fields in this struct should be set at any given time.
 type ReplicatedDevices struct {
@@ -189,16 +572,71 @@ func (s *ReplicatedResources) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	if unit, exists := ts["unit"]; exists {
+		err = json.Unmarshal(unit, &s.Unit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := unmarshalIdentity(ts, &s.UserID, &s.GroupID); err != nil {
+		return err
+	}
+
+	switch s.Unit {
+	case "", ReplicaUnitReplicas:
+	case ReplicaUnitMilli, ReplicaUnitMemory:
+		if s.FailRequestsGreaterThanOne {
+			return fmt.Errorf("failRequestsGreaterThanOne is not supported with unit %q", s.Unit)
+		}
+	default:
+		return fmt.Errorf("unknown unit %q", s.Unit)
+	}
+
+	if chunk, exists := ts["memoryChunkMB"]; exists {
+		if !s.IsMemory() {
+			return fmt.Errorf("memoryChunkMB is only supported with unit %q", ReplicaUnitMemory)
+		}
+		err = json.Unmarshal(chunk, &s.MemoryChunkMB)
+		if err != nil {
+			return err
+		}
+		if *s.MemoryChunkMB == 0 {
+			return fmt.Errorf("memoryChunkMB must be > 0")
+		}
+	}
+
 	resources, exists := ts["resources"]
 	if !exists {
 		return fmt.Errorf("no resources specified")
 	}
 
+	if s.IsMilli() {
+		resources, err = setMilliReplicas(resources)
+		if err != nil {
+			return err
+		}
+	}
+	if s.IsMemory() {
+		resources, err = setMemoryReplicas(resources)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = json.Unmarshal(resources, &s.Resources)
 	if err != nil {
 		return err
 	}
 
+	// The replicas of resources with the memory unit depend on the memory of
+	// each device and are determined when the devices are replicated.
+	if s.IsMemory() {
+		for i := range s.Resources {
+			s.Resources[i].Replicas = 0
+		}
+	}
+
 	if len(s.Resources) == 0 {
 		return fmt.Errorf("no resources specified")
 	}
@@ -212,6 +650,58 @@ func (s *ReplicatedResources) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// setMilliReplicas sets the replicas of each of the raw resources to
+// MilliUnitsPerDevice. An error is returned if a resource specifies a
+// different number of replicas.
+func setMilliReplicas(resources json.RawMessage) (json.RawMessage, error) {
+	var rrs []map[string]json.RawMessage
+	if err := json.Unmarshal(resources, &rrs); err != nil {
+		return nil, err
+	}
+	for _, rr := range rrs {
+		if replicas, exists := rr["replicas"]; exists {
+			var r int
+			if err := json.Unmarshal(replicas, &r); err != nil {
+				return nil, err
+			}
+			if r != MilliUnitsPerDevice {
+				return nil, fmt.Errorf("replicas must be %d or unset with unit %q", MilliUnitsPerDevice, ReplicaUnitMilli)
+			}
+		}
+		rr["replicas"] = json.RawMessage(strconv.Itoa(MilliUnitsPerDevice))
+	}
+	return json.Marshal(rrs)
+}
+
+// setMemoryReplicas sets the replicas of each of the raw resources to a
+// placeholder that passes the validation of the replicas. An error is returned
+// if a resource specifies replicas or memory limits, since these are derived
+// from the memory chunks.
+func setMemoryReplicas(resources json.RawMessage) (json.RawMessage, error) {
+	var rrs []map[string]json.RawMessage
+	if err := json.Unmarshal(resources, &rrs); err != nil {
+		return nil, err
+	}
+	for _, rr := range rrs {
+		if replicas, exists := rr["replicas"]; exists {
+			var r int
+			if err := json.Unmarshal(replicas, &r); err != nil {
+				return nil, err
+			}
+			if r != 0 {
+				return nil, fmt.Errorf("replicas must be unset with unit %q", ReplicaUnitMemory)
+			}
+		}
+		for _, key := range []string{"memoryLimit", "memoryLimitMB", "replicaMemoryLimitsMB"} {
+			if _, exists := rr[key]; exists {
+				return nil, fmt.Errorf("%v is not supported with unit %q", key, ReplicaUnitMemory)
+			}
+		}
+		rr["replicas"] = json.RawMessage(`2`)
+	}
+	return json.Marshal(rrs)
+}
+
 // UnmarshalJSON unmarshals raw bytes into a 'ReplicatedResource' struct.
 func (s *ReplicatedResource) UnmarshalJSON(b []byte) error {
 	rr := make(map[string]json.RawMessage)
@@ -254,6 +744,189 @@ func (s *ReplicatedResource) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("number of replicas must be >= 2")
 	}
 
+	if logDirectory, exists := rr["logDirectory"]; exists {
+		err = json.Unmarshal(logDirectory, &s.LogDirectory)
+		if err != nil {
+			return err
+		}
+		if err := validateLogDirectory(s.LogDirectory); err != nil {
+			return fmt.Errorf("invalid logDirectory for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if pipeDirectory, exists := rr["pipeDirectory"]; exists {
+		err = json.Unmarshal(pipeDirectory, &s.PipeDirectory)
+		if err != nil {
+			return err
+		}
+		if err := validatePipeDirectory(s.PipeDirectory); err != nil {
+			return fmt.Errorf("invalid pipeDirectory for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if size, exists := rr["pipeDirectorySizeMB"]; exists {
+		err = json.Unmarshal(size, &s.PipeDirectorySizeMB)
+		if err != nil {
+			return fmt.Errorf("invalid pipeDirectorySizeMB for resource %q: %w", s.Name, err)
+		}
+		if s.PipeDirectory == "" {
+			return fmt.Errorf("pipeDirectorySizeMB requires pipeDirectory for resource %q", s.Name)
+		}
+		if *s.PipeDirectorySizeMB == 0 {
+			return fmt.Errorf("pipeDirectorySizeMB must be > 0 for resource %q", s.Name)
+		}
+	}
+
+	if clientAffinity, exists := rr["clientAffinity"]; exists {
+		err = json.Unmarshal(clientAffinity, &s.ClientAffinity)
+		if err != nil {
+			return err
+		}
+		switch s.ClientAffinity {
+		case ClientAffinityRoundRobin, ClientAffinityLeastLoaded:
+		default:
+			return fmt.Errorf("unknown clientAffinity %q for resource %q", s.ClientAffinity, s.Name)
+		}
+	}
+
+	if overhead, exists := rr["serverMemoryOverheadMB"]; exists {
+		err = json.Unmarshal(overhead, &s.ServerMemoryOverheadMB)
+		if err != nil {
+			return fmt.Errorf("invalid serverMemoryOverheadMB for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if memoryLimit, exists := rr["memoryLimit"]; exists {
+		err = json.Unmarshal(memoryLimit, &s.MemoryLimit)
+		if err != nil {
+			return fmt.Errorf("invalid memoryLimit for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if memoryLimitMB, exists := rr["memoryLimitMB"]; exists {
+		err = json.Unmarshal(memoryLimitMB, &s.MemoryLimitMB)
+		if err != nil {
+			return fmt.Errorf("invalid memoryLimitMB for resource %q: %w", s.Name, err)
+		}
+		if *s.MemoryLimitMB == 0 {
+			return fmt.Errorf("memoryLimitMB must be > 0 for resource %q", s.Name)
+		}
+		if s.MemoryLimit != nil {
+			return fmt.Errorf("memoryLimit and memoryLimitMB are mutually exclusive for resource %q", s.Name)
+		}
+	}
+
+	if replicaMemoryLimitsMB, exists := rr["replicaMemoryLimitsMB"]; exists {
+		err = json.Unmarshal(replicaMemoryLimitsMB, &s.ReplicaMemoryLimitsMB)
+		if err != nil {
+			return fmt.Errorf("invalid replicaMemoryLimitsMB for resource %q: %w", s.Name, err)
+		}
+		if len(s.ReplicaMemoryLimitsMB) != s.Replicas {
+			return fmt.Errorf("replicaMemoryLimitsMB must have one entry for each of the %d replicas of resource %q", s.Replicas, s.Name)
+		}
+		if slices.Contains(s.ReplicaMemoryLimitsMB, 0) {
+			return fmt.Errorf("replicaMemoryLimitsMB must be > 0 for resource %q", s.Name)
+		}
+		if s.MemoryLimit != nil || s.MemoryLimitMB != nil {
+			return fmt.Errorf("replicaMemoryLimitsMB cannot be combined with memoryLimit or memoryLimitMB for resource %q", s.Name)
+		}
+	}
+
+	if threadLimit, exists := rr["threadLimit"]; exists {
+		err = json.Unmarshal(threadLimit, &s.ThreadLimit)
+		if err != nil {
+			return fmt.Errorf("invalid threadLimit for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if threadPercentage, exists := rr["threadPercentage"]; exists {
+		err = json.Unmarshal(threadPercentage, &s.ThreadPercentage)
+		if err != nil {
+			return fmt.Errorf("invalid threadPercentage for resource %q: %w", s.Name, err)
+		}
+		if *s.ThreadPercentage < 1 || *s.ThreadPercentage > 100 {
+			return fmt.Errorf("threadPercentage must be between 1 and 100 for resource %q", s.Name)
+		}
+		if s.ThreadLimit != nil {
+			return fmt.Errorf("threadLimit and threadPercentage are mutually exclusive for resource %q", s.Name)
+		}
+	}
+
+	if maxThreadPercentage, exists := rr["maxThreadPercentage"]; exists {
+		err = json.Unmarshal(maxThreadPercentage, &s.MaxThreadPercentage)
+		if err != nil {
+			return fmt.Errorf("invalid maxThreadPercentage for resource %q: %w", s.Name, err)
+		}
+		if *s.MaxThreadPercentage < 1 || *s.MaxThreadPercentage > 100 {
+			return fmt.Errorf("maxThreadPercentage must be between 1 and 100 for resource %q", s.Name)
+		}
+	}
+
+	if trustedWorkloads, exists := rr["trustedWorkloads"]; exists {
+		err = json.Unmarshal(trustedWorkloads, &s.TrustedWorkloads)
+		if err != nil {
+			return fmt.Errorf("invalid trustedWorkloads for resource %q: %w", s.Name, err)
+		}
+		if s.TrustedWorkloads == nil {
+			return fmt.Errorf("invalid trustedWorkloads for resource %q: must not be null", s.Name)
+		}
+		if err := s.TrustedWorkloads.validate(); err != nil {
+			return fmt.Errorf("invalid trustedWorkloads for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if perMigDevice, exists := rr["perMigDevice"]; exists {
+		err = json.Unmarshal(perMigDevice, &s.PerMigDevice)
+		if err != nil {
+			return fmt.Errorf("invalid perMigDevice for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if envPassthrough, exists := rr["envPassthrough"]; exists {
+		err = json.Unmarshal(envPassthrough, &s.EnvPassthrough)
+		if err != nil {
+			return fmt.Errorf("invalid envPassthrough for resource %q: %w", s.Name, err)
+		}
+		for _, name := range s.EnvPassthrough {
+			if err := validateEnvPassthrough(name); err != nil {
+				return fmt.Errorf("invalid envPassthrough for resource %q: %w", s.Name, err)
+			}
+		}
+	}
+
+	if envs, exists := rr["envs"]; exists {
+		err = json.Unmarshal(envs, &s.Envs)
+		if err != nil {
+			return fmt.Errorf("invalid envs for resource %q: %w", s.Name, err)
+		}
+		for name := range s.Envs {
+			if err := validateClientEnv(name); err != nil {
+				return fmt.Errorf("invalid envs for resource %q: %w", s.Name, err)
+			}
+		}
+	}
+
+	if advertiseWhole, exists := rr["advertiseWhole"]; exists {
+		err = json.Unmarshal(advertiseWhole, &s.AdvertiseWhole)
+		if err != nil {
+			return fmt.Errorf("invalid advertiseWhole for resource %q: %w", s.Name, err)
+		}
+	}
+
+	if burstReplicas, exists := rr["burstReplicas"]; exists {
+		err = json.Unmarshal(burstReplicas, &s.BurstReplicas)
+		if err != nil {
+			return fmt.Errorf("invalid burstReplicas for resource %q: %w", s.Name, err)
+		}
+		if s.BurstReplicas < 0 {
+			return fmt.Errorf("burstReplicas must be >= 0 for resource %q", s.Name)
+		}
+	}
+
+	if err := unmarshalIdentity(rr, &s.UserID, &s.GroupID); err != nil {
+		return fmt.Errorf("invalid identity for resource %q: %w", s.Name, err)
+	}
+
 	rename, exists := rr["rename"]
 	if !exists {
 		return nil
@@ -267,6 +940,105 @@ func (s *ReplicatedResource) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// validateLogDirectory checks that a log directory is a clean path that does
+// not refer to its parent directory.
+func validateLogDirectory(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("path must not be empty")
+	}
+	if filepath.Clean(dir) != dir {
+		return fmt.Errorf("path %q is not clean", dir)
+	}
+	for _, part := range strings.Split(dir, string(filepath.Separator)) {
+		if part == ".." {
+			return fmt.Errorf("path %q must not contain '..'", dir)
+		}
+	}
+	return nil
+}
+
+// validatePipeDirectory checks that a pipe directory is a clean relative path
+// that does not refer to its parent directory.
+func validatePipeDirectory(dir string) error {
+	if filepath.IsAbs(dir) {
+		return fmt.Errorf("path %q must be relative to the MPS root", dir)
+	}
+	if dir == "." {
+		return fmt.Errorf("path must not be the MPS root")
+	}
+	return validateLogDirectory(dir)
+}
+
+// unmarshalIdentity unmarshals the userID and groupID fields of a raw object.
+// The IDs must not be negative.
+func unmarshalIdentity(raw map[string]json.RawMessage, userID **int64, groupID **int64) error {
+	fields := []struct {
+		name string
+		id   **int64
+	}{
+		{"userID", userID},
+		{"groupID", groupID},
+	}
+	for _, field := range fields {
+		value, exists := raw[field.name]
+		if !exists {
+			continue
+		}
+		if err := json.Unmarshal(value, field.id); err != nil {
+			return fmt.Errorf("invalid %v: %w", field.name, err)
+		}
+		if *field.id != nil && **field.id < 0 {
+			return fmt.Errorf("%v must be >= 0", field.name)
+		}
+	}
+	return nil
+}
+
+// mpsDaemonEnvvars are the environment variables that are set for each MPS
+// daemon by the MPS control daemon and cannot be passed through.
+var mpsDaemonEnvvars = []string{
+	"CUDA_MPS_PIPE_DIRECTORY",
+	"CUDA_MPS_LOG_DIRECTORY",
+	"CUDA_VISIBLE_DEVICES",
+}
+
+// validateEnvPassthrough checks that an environment variable can be passed
+// through to the MPS daemons.
+func validateEnvPassthrough(name string) error {
+	if name == "" || strings.ContainsAny(name, "= ") {
+		return fmt.Errorf("%q is not a valid environment variable name", name)
+	}
+	if slices.Contains(mpsDaemonEnvvars, name) {
+		return fmt.Errorf("%v is set by the MPS control daemon and cannot be passed through", name)
+	}
+	return nil
+}
+
+// reservedClientEnvPrefixes are the prefixes of the environment variables that
+// are set in the containers by the device plugin or the NVIDIA Container
+// Toolkit and cannot be set through the envs of a resource.
+var reservedClientEnvPrefixes = []string{
+	"NVIDIA_",
+	"CUDA_MPS_",
+}
+
+// validateClientEnv checks that an environment variable can be set in the
+// containers that are allocated a resource.
+func validateClientEnv(name string) error {
+	if name == "" || strings.ContainsAny(name, "= ") {
+		return fmt.Errorf("%q is not a valid environment variable name", name)
+	}
+	for _, prefix := range reservedClientEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("%v is controlled by the device plugin and cannot be set", name)
+		}
+	}
+	if name == "LD_LIBRARY_PATH" {
+		return fmt.Errorf("%v is controlled by the device plugin and cannot be set", name)
+	}
+	return nil
+}
+
 // UnmarshalJSON unmarshals raw bytes into a 'ReplicatedDevices' struct.
 func (s *ReplicatedDevices) UnmarshalJSON(b []byte) error {
 	// Match the string 'all'
@@ -18,6 +18,7 @@ package v1
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -301,6 +302,242 @@ func TestUnmarshalReplicatedResource(t *testing.T) {
 				Rename:   NoErrorNewResourceName("valid-shared"),
 			},
 		},
+		{
+			input: `{
+				"name": "valid",
+				"devices": "all",
+				"replicas": 2,
+				"logDirectory": "logs/valid"
+			}`,
+			output: ReplicatedResource{
+				Name:         NoErrorNewResourceName("valid"),
+				Devices:      ReplicatedDevices{All: true},
+				Replicas:     2,
+				LogDirectory: "logs/valid",
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"logDirectory": "../valid"
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"logDirectory": "/var/log//mps"
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"devices": "all",
+				"replicas": 2,
+				"clientAffinity": "least-loaded"
+			}`,
+			output: ReplicatedResource{
+				Name:           NoErrorNewResourceName("valid"),
+				Devices:        ReplicatedDevices{All: true},
+				Replicas:       2,
+				ClientAffinity: ClientAffinityLeastLoaded,
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"clientAffinity": "random"
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"serverMemoryOverheadMB": 1024
+			}`,
+			output: ReplicatedResource{
+				Name:                   NoErrorNewResourceName("valid"),
+				Devices:                ReplicatedDevices{All: true},
+				Replicas:               2,
+				ServerMemoryOverheadMB: ptr[uint64](1024),
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"serverMemoryOverheadMB": -1
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"memoryLimit": "1/8",
+				"threadLimit": "25%"
+			}`,
+			output: ReplicatedResource{
+				Name:        NoErrorNewResourceName("valid"),
+				Devices:     ReplicatedDevices{All: true},
+				Replicas:    2,
+				MemoryLimit: &Fraction{raw: "1/8", value: 0.125},
+				ThreadLimit: &Fraction{raw: "25%", value: 0.25},
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"memoryLimit": "2/1"
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"memoryLimitMB": 4096
+			}`,
+			output: ReplicatedResource{
+				Name:          NoErrorNewResourceName("valid"),
+				Devices:       ReplicatedDevices{All: true},
+				Replicas:      2,
+				MemoryLimitMB: ptr[uint64](4096),
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"memoryLimit": "1/2",
+				"memoryLimitMB": 4096
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 3,
+				"replicaMemoryLimitsMB": [8192, 4096, 4096]
+			}`,
+			output: ReplicatedResource{
+				Name:                  NoErrorNewResourceName("valid"),
+				Devices:               ReplicatedDevices{All: true},
+				Replicas:              3,
+				ReplicaMemoryLimitsMB: []uint64{8192, 4096, 4096},
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 3,
+				"replicaMemoryLimitsMB": [8192, 4096]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"memoryLimitMB": 4096,
+				"replicaMemoryLimitsMB": [4096, 4096]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"threadPercentage": 30
+			}`,
+			output: ReplicatedResource{
+				Name:             NoErrorNewResourceName("valid"),
+				Devices:          ReplicatedDevices{All: true},
+				Replicas:         2,
+				ThreadPercentage: ptr(30),
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"threadPercentage": 101
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"threadLimit": "25%",
+				"threadPercentage": 25
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"maxThreadPercentage": 80
+			}`,
+			output: ReplicatedResource{
+				Name:                NoErrorNewResourceName("valid"),
+				Devices:             ReplicatedDevices{All: true},
+				Replicas:            2,
+				MaxThreadPercentage: ptr(80),
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"maxThreadPercentage": 0
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"trustedWorkloads": {
+					"namespaces": ["monitoring"],
+					"serviceAccounts": ["profiling/nsight"]
+				}
+			}`,
+			output: ReplicatedResource{
+				Name:     NoErrorNewResourceName("valid"),
+				Devices:  ReplicatedDevices{All: true},
+				Replicas: 2,
+				TrustedWorkloads: &TrustedWorkloads{
+					Namespaces:      []string{"monitoring"},
+					ServiceAccounts: []string{"profiling/nsight"},
+				},
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"trustedWorkloads": {}
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"trustedWorkloads": {
+					"serviceAccounts": ["nsight"]
+				}
+			}`,
+			err: true,
+		},
 		{
 			input: `{
 				"name": "valid",
@@ -449,6 +686,142 @@ func TestUnmarshalReplicatedResources(t *testing.T) {
 			}`,
 			err: true,
 		},
+		{
+			input: `{
+				"unit": "milli",
+				"resources": [
+					{
+						"name": "valid1"
+					},
+					{
+						"name": "valid2",
+						"replicas": 1000
+					}
+				]
+			}`,
+			output: ReplicatedResources{
+				Unit: ReplicaUnitMilli,
+				Resources: []ReplicatedResource{
+					{
+						Name:     NoErrorNewResourceName("valid1"),
+						Devices:  ReplicatedDevices{All: true},
+						Replicas: 1000,
+					},
+					{
+						Name:     NoErrorNewResourceName("valid2"),
+						Devices:  ReplicatedDevices{All: true},
+						Replicas: 1000,
+					},
+				},
+			},
+		},
+		{
+			input: `{
+				"unit": "milli",
+				"resources": [
+					{
+						"name": "valid",
+						"replicas": 2
+					}
+				]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"unit": "milli",
+				"failRequestsGreaterThanOne": true,
+				"resources": [
+					{
+						"name": "valid"
+					}
+				]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"unit": "memory",
+				"memoryChunkMB": 2048,
+				"resources": [
+					{
+						"name": "valid",
+						"rename": "valid-memory"
+					}
+				]
+			}`,
+			output: ReplicatedResources{
+				Unit:          ReplicaUnitMemory,
+				MemoryChunkMB: ptr[uint64](2048),
+				Resources: []ReplicatedResource{
+					{
+						Name:    NoErrorNewResourceName("valid"),
+						Rename:  NoErrorNewResourceName("valid-memory"),
+						Devices: ReplicatedDevices{All: true},
+					},
+				},
+			},
+		},
+		{
+			input: `{
+				"unit": "memory",
+				"resources": [
+					{
+						"name": "valid",
+						"replicas": 4
+					}
+				]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"unit": "memory",
+				"resources": [
+					{
+						"name": "valid",
+						"memoryLimitMB": 4096
+					}
+				]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"unit": "memory",
+				"memoryChunkMB": 0,
+				"resources": [
+					{
+						"name": "valid"
+					}
+				]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"memoryChunkMB": 1024,
+				"resources": [
+					{
+						"name": "valid",
+						"replicas": 2
+					}
+				]
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"unit": "percent",
+				"resources": [
+					{
+						"name": "valid",
+						"replicas": 2
+					}
+				]
+			}`,
+			err: true,
+		},
 	}
 
 	for i, tc := range testCases {
@@ -464,3 +837,938 @@ func TestUnmarshalReplicatedResources(t *testing.T) {
 		})
 	}
 }
+
+func TestSharingValidation(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		err         bool
+	}{
+		{
+			description: "log directory for MPS is valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      logDirectory: /var/log/mps/gpu
+`,
+		},
+		{
+			description: "log directory for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      logDirectory: /var/log/mps/gpu
+`,
+			err: true,
+		},
+		{
+			description: "client affinity for MPS is valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      clientAffinity: round-robin
+`,
+		},
+		{
+			description: "client affinity for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      clientAffinity: round-robin
+`,
+			err: true,
+		},
+		{
+			description: "server memory overhead for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      serverMemoryOverheadMB: 512
+`,
+			err: true,
+		},
+		{
+			description: "memory limit for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      memoryLimit: 1/8
+`,
+			err: true,
+		},
+		{
+			description: "memory and thread limits for MPS are valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 8
+      memoryLimit: 1/8
+      threadLimit: 12.5%
+`,
+		},
+		{
+			description: "explicit memory limits for time-slicing are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      replicaMemoryLimitsMB: [4096, 2048]
+`,
+			err: true,
+		},
+		{
+			description: "thread percentage for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      threadPercentage: 50
+`,
+			err: true,
+		},
+		{
+			description: "max thread percentage for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      maxThreadPercentage: 50
+`,
+			err: true,
+		},
+		{
+			description: "trusted workloads for MPS are valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+      trustedWorkloads:
+        namespaces: [monitoring]
+`,
+		},
+		{
+			description: "trusted workloads for time-slicing are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+      trustedWorkloads:
+        namespaces: [monitoring]
+`,
+			err: true,
+		},
+		{
+			description: "per-MIG-device daemons for time-slicing are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/mig-1g.5gb
+      replicas: 2
+      perMigDevice: true
+`,
+			err: true,
+		},
+		{
+			description: "per-MIG-device daemons for MPS are valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/mig-1g.5gb
+      replicas: 2
+      perMigDevice: true
+`,
+		},
+		{
+			description: "environment passthrough for MPS is valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      envPassthrough: [HTTPS_PROXY, CUDA_MPS_ENABLE_PER_CTX_DEVICE_MULTIPROCESSOR_PARTITIONING]
+`,
+		},
+		{
+			description: "environment passthrough for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      envPassthrough: [HTTPS_PROXY]
+`,
+			err: true,
+		},
+		{
+			description: "environment passthrough of the MPS pipe directory is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      envPassthrough: [CUDA_MPS_PIPE_DIRECTORY]
+`,
+			err: true,
+		},
+		{
+			description: "client environment variables are valid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      envs:
+        CUDA_MODULE_LOADING: LAZY
+`,
+		},
+		{
+			description: "client environment variables controlled by the device plugin are invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      envs:
+        CUDA_MPS_ACTIVE_THREAD_PERCENTAGE: "100"
+`,
+			err: true,
+		},
+		{
+			description: "milli units for MPS are invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    unit: milli
+    resources:
+    - name: nvidia.com/gpu
+`,
+			err: true,
+		},
+		{
+			description: "memory units for time-slicing are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    unit: memory
+    resources:
+    - name: nvidia.com/gpu
+`,
+			err: true,
+		},
+		{
+			description: "duplicate log directories are invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      logDirectory: logs
+    - name: nvidia.com/mig-1g.5gb
+      replicas: 2
+      logDirectory: logs
+`,
+			err: true,
+		},
+		{
+			description: "user and group for MPS are valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    userID: 1000
+    groupID: 1000
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      userID: 1001
+`,
+		},
+		{
+			description: "negative user ID is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    userID: -1
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+`,
+			err: true,
+		},
+		{
+			description: "user for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    userID: 1000
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+`,
+			err: true,
+		},
+		{
+			description: "group of time-sliced resource is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      groupID: 1000
+`,
+			err: true,
+		},
+		{
+			description: "pipe directory for MPS is valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: tmpfs/gpu
+`,
+		},
+		{
+			description: "absolute pipe directory is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: /tmpfs/gpu
+`,
+			err: true,
+		},
+		{
+			description: "pipe directory for time-slicing is invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: tmpfs/gpu
+`,
+			err: true,
+		},
+		{
+			description: "duplicate pipe directories are invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: pipes
+    - name: nvidia.com/mig-1g.5gb
+      replicas: 2
+      pipeDirectory: pipes
+`,
+			err: true,
+		},
+		{
+			description: "nested pipe directories are invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: pipes
+    - name: nvidia.com/mig-1g.5gb
+      replicas: 2
+      pipeDirectory: pipes/mig
+`,
+			err: true,
+		},
+		{
+			description: "pipe directory in the shm directory is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: shm/gpu
+`,
+			err: true,
+		},
+		{
+			description: "pipe directory in the directory of another resource is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: nvidia.com/mig-1g.5gb/pipe
+    - name: nvidia.com/mig-1g.5gb
+      replicas: 2
+`,
+			err: true,
+		},
+		{
+			description: "MPS root as pipe directory is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: .
+`,
+			err: true,
+		},
+		{
+			description: "pipe directory size is valid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: tmpfs/gpu
+      pipeDirectorySizeMB: 64
+`,
+		},
+		{
+			description: "pipe directory size without pipe directory is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectorySizeMB: 64
+`,
+			err: true,
+		},
+		{
+			description: "zero pipe directory size is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      pipeDirectory: tmpfs/gpu
+      pipeDirectorySizeMB: 0
+`,
+			err: true,
+		},
+		{
+			description: "time-slicing and MPS for different resources are valid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+  mps:
+    resources:
+    - name: nvidia.com/mig-1g.10gb
+      replicas: 2
+      perMigDevice: true
+`,
+		},
+		{
+			description: "advertising whole GPUs for time-slicing is valid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    renameByDefault: true
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+      advertiseWhole: true
+`,
+		},
+		{
+			description: "advertising whole GPUs for MPS is invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+      advertiseWhole: true
+`,
+			err: true,
+		},
+		{
+			description: "burst replicas for time-slicing are valid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      burstReplicas: 2
+`,
+		},
+		{
+			description: "negative burst replicas are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      burstReplicas: -1
+`,
+			err: true,
+		},
+		{
+			description: "burst replicas for MPS are invalid",
+			input: `
+version: v1
+sharing:
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+      burstReplicas: 2
+`,
+			err: true,
+		},
+		{
+			description: "weighted allocation policy is valid",
+			input: `
+version: v1
+sharing:
+  allocationPolicy: weighted
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+`,
+		},
+		{
+			description: "unknown allocation policy is invalid",
+			input: `
+version: v1
+sharing:
+  allocationPolicy: random
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+`,
+			err: true,
+		},
+		{
+			description: "spread allocation policy with milli units is invalid",
+			input: `
+version: v1
+sharing:
+  allocationPolicy: spread
+  timeSlicing:
+    unit: milli
+    resources:
+    - name: nvidia.com/gpu
+`,
+			err: true,
+		},
+		{
+			description: "spread allocation policy with memory units is invalid",
+			input: `
+version: v1
+sharing:
+  allocationPolicy: spread
+  mps:
+    unit: memory
+    resources:
+    - name: nvidia.com/gpu
+`,
+			err: true,
+		},
+		{
+			description: "time-slicing and MPS for the same resource are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 4
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+`,
+			err: true,
+		},
+		{
+			description: "time-slicing and MPS for the same devices are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.shared
+      devices: [0, 1]
+      replicas: 4
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.mps
+      devices: [1, 2]
+      replicas: 2
+`,
+			err: true,
+		},
+		{
+			description: "time-slicing and MPS for the same resource with the same rename are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.shared
+      devices: 2
+      replicas: 4
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.shared
+      replicas: 2
+`,
+			err: true,
+		},
+		{
+			description: "time-slicing of all devices and MPS for the same resource are invalid",
+			input: `
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.shared
+      replicas: 4
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.mps
+      replicas: 2
+`,
+			err: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			_, err := parseConfigFrom(strings.NewReader(tc.input))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestStrategyForResource(t *testing.T) {
+	sharing := Sharing{
+		TimeSlicing: ReplicatedResources{
+			Resources: []ReplicatedResource{
+				{Name: "nvidia.com/gpu", Rename: "nvidia.com/gpu.shared", Replicas: 4},
+			},
+		},
+		MPS: &ReplicatedResources{
+			Resources: []ReplicatedResource{
+				{Name: "nvidia.com/mig-1g.10gb", Rename: "nvidia.com/mig-1g.10gb.shared", Replicas: 2},
+			},
+		},
+	}
+
+	require.Equal(t, SharingStrategyTimeSlicing, sharing.StrategyForResource("nvidia.com/gpu"))
+	require.Equal(t, SharingStrategyTimeSlicing, sharing.StrategyForResource("nvidia.com/gpu.shared"))
+	require.Equal(t, SharingStrategyMPS, sharing.StrategyForResource("nvidia.com/mig-1g.10gb"))
+	require.Equal(t, SharingStrategyMPS, sharing.StrategyForResource("nvidia.com/mig-1g.10gb.shared"))
+	require.Equal(t, SharingStrategyNone, sharing.StrategyForResource("nvidia.com/mig-3g.40gb"))
+
+	require.Same(t, sharing.MPS, sharing.ReplicatedResourcesFor("nvidia.com/mig-1g.10gb.shared"))
+	require.Nil(t, sharing.ReplicatedResourcesFor("nvidia.com/mig-3g.40gb"))
+
+	require.Equal(t, AllocationPolicySpread, sharing.GetAllocationPolicy("nvidia.com/gpu"))
+	sharing.TimeSlicing.Unit = ReplicaUnitMilli
+	require.Equal(t, AllocationPolicyPacked, sharing.GetAllocationPolicy("nvidia.com/gpu"))
+	sharing.AllocationPolicy = AllocationPolicyWeighted
+	require.Equal(t, AllocationPolicyWeighted, sharing.GetAllocationPolicy("nvidia.com/gpu"))
+	require.Equal(t, []*ReplicatedResources{&sharing.TimeSlicing, sharing.MPS}, sharing.AllReplicatedResources())
+}
+
+func TestSplitResource(t *testing.T) {
+	config, err := parseConfigFrom(strings.NewReader(`
+version: v1
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.shared
+      devices: [0, 1]
+      replicas: 4
+  mps:
+    resources:
+    - name: nvidia.com/gpu
+      rename: nvidia.com/gpu.mps
+      devices: [2, 3]
+      replicas: 2
+`))
+	require.NoError(t, err)
+
+	DisableResourceNamingInConfig(testLogger{}, config)
+
+	require.Equal(t, ResourceName("nvidia.com/gpu.shared"), config.Sharing.TimeSlicing.Resources[0].Rename)
+	require.Equal(t, []ReplicatedDeviceRef{"0", "1"}, config.Sharing.TimeSlicing.Resources[0].Devices.List)
+	require.Equal(t, ResourceName("nvidia.com/gpu.mps"), config.Sharing.MPS.Resources[0].Rename)
+	require.Equal(t, []ReplicatedDeviceRef{"2", "3"}, config.Sharing.MPS.Resources[0].Devices.List)
+
+	require.Equal(t, SharingStrategyTimeSlicing, config.Sharing.StrategyForResource("nvidia.com/gpu.shared"))
+	require.Equal(t, SharingStrategyMPS, config.Sharing.StrategyForResource("nvidia.com/gpu.mps"))
+	require.Equal(t, SharingStrategyTimeSlicing, config.Sharing.StrategyForResource("nvidia.com/gpu"))
+}
+
+type testLogger struct{}
+
+func (testLogger) Warning(...interface{})          {}
+func (testLogger) Warningf(string, ...interface{}) {}
+
+func TestReplicaMemoryMB(t *testing.T) {
+	testCases := []struct {
+		description   string
+		resource      *ReplicatedResource
+		totalMemoryMB uint64
+		expected      uint64
+	}{
+		{
+			description:   "default overhead",
+			resource:      &ReplicatedResource{Replicas: 4},
+			totalMemoryMB: 40960,
+			expected:      10240,
+		},
+		{
+			description:   "configured overhead",
+			resource:      &ReplicatedResource{Replicas: 4, ServerMemoryOverheadMB: ptr[uint64](1024)},
+			totalMemoryMB: 40960,
+			expected:      9984,
+		},
+		{
+			description:   "no overhead",
+			resource:      &ReplicatedResource{Replicas: 2, ServerMemoryOverheadMB: ptr[uint64](0)},
+			totalMemoryMB: 40960,
+			expected:      20480,
+		},
+		{
+			description:   "overhead exceeds memory",
+			resource:      &ReplicatedResource{Replicas: 2, ServerMemoryOverheadMB: ptr[uint64](512)},
+			totalMemoryMB: 256,
+			expected:      0,
+		},
+		{
+			description:   "memory limit is a fraction of the available memory",
+			resource:      &ReplicatedResource{Replicas: 4, ServerMemoryOverheadMB: ptr[uint64](0), MemoryLimit: &Fraction{raw: "1/8", value: 0.125}},
+			totalMemoryMB: 81920,
+			expected:      10240,
+		},
+		{
+			description:   "explicit memory limit",
+			resource:      &ReplicatedResource{Replicas: 4, MemoryLimitMB: ptr[uint64](8192)},
+			totalMemoryMB: 81920,
+			expected:      8192,
+		},
+		{
+			description:   "per-replica memory limits return the smallest limit",
+			resource:      &ReplicatedResource{Replicas: 3, ReplicaMemoryLimitsMB: []uint64{8192, 2048, 4096}},
+			totalMemoryMB: 81920,
+			expected:      2048,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.resource.ReplicaMemoryMB(tc.totalMemoryMB))
+		})
+	}
+}
+
+func TestMemoryChunks(t *testing.T) {
+	testCases := []struct {
+		description string
+		resources   *ReplicatedResources
+		resource    *ReplicatedResource
+		totalMemory uint64
+		expected    int
+	}{
+		{
+			description: "replicas unit",
+			resources:   &ReplicatedResources{},
+			resource:    &ReplicatedResource{Replicas: 4},
+			totalMemory: 40960 << 20,
+			expected:    0,
+		},
+		{
+			description: "default chunk size",
+			resources:   &ReplicatedResources{Unit: ReplicaUnitMemory},
+			resource:    &ReplicatedResource{},
+			totalMemory: 40960 << 20,
+			expected:    40,
+		},
+		{
+			description: "configured chunk size without overhead",
+			resources:   &ReplicatedResources{Unit: ReplicaUnitMemory, MemoryChunkMB: ptr[uint64](4096)},
+			resource:    &ReplicatedResource{ServerMemoryOverheadMB: ptr[uint64](0)},
+			totalMemory: 40960 << 20,
+			expected:    10,
+		},
+		{
+			description: "overhead exceeds memory",
+			resources:   &ReplicatedResources{Unit: ReplicaUnitMemory},
+			resource:    &ReplicatedResource{ServerMemoryOverheadMB: ptr[uint64](512)},
+			totalMemory: 256 << 20,
+			expected:    0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.resources.MemoryChunks(tc.resource, tc.totalMemory))
+		})
+	}
+}
+
+func TestGetThreadLimit(t *testing.T) {
+	testCases := []struct {
+		description string
+		resource    *ReplicatedResource
+		expected    *Fraction
+	}{
+		{
+			description: "nil resource",
+		},
+		{
+			description: "no limit",
+			resource:    &ReplicatedResource{Replicas: 4},
+		},
+		{
+			description: "thread limit",
+			resource:    &ReplicatedResource{Replicas: 4, ThreadLimit: &Fraction{raw: "1/8", value: 0.125}},
+			expected:    &Fraction{raw: "1/8", value: 0.125},
+		},
+		{
+			description: "thread percentage",
+			resource:    &ReplicatedResource{Replicas: 4, ThreadPercentage: ptr(30)},
+			expected:    &Fraction{raw: "30%", value: 0.3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.resource.GetThreadLimit())
+		})
+	}
+}
+
+func TestGetUserAndGroupID(t *testing.T) {
+	rrs := &ReplicatedResources{
+		UserID:  ptr[int64](1000),
+		GroupID: ptr[int64](1000),
+		Resources: []ReplicatedResource{
+			{Name: "nvidia.com/gpu", UserID: ptr[int64](1001)},
+			{Name: "nvidia.com/mig-1g.5gb"},
+		},
+	}
+
+	require.Equal(t, ptr[int64](1001), rrs.GetUserID(&rrs.Resources[0]))
+	require.Equal(t, ptr[int64](1000), rrs.GetGroupID(&rrs.Resources[0]))
+	require.Equal(t, ptr[int64](1000), rrs.GetUserID(&rrs.Resources[1]))
+	require.Equal(t, ptr[int64](1000), rrs.GetUserID(nil))
+
+	var unset *ReplicatedResources
+	require.Nil(t, unset.GetUserID(nil))
+	require.Nil(t, unset.GetGroupID(nil))
+}
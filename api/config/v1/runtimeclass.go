@@ -0,0 +1,54 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package v1
+
+import "fmt"
+
+// RuntimeClass declares the device list strategies that are supported by the
+// container runtime that handles a Kubernetes runtime class. Allocation
+// requests of pods with the runtime class fail if the runtime supports none of
+// the device list strategies of the plugin, since the containers of the pod
+// would otherwise start without GPUs.
+//
+// An empty name declares the device list strategies supported by the default
+// runtime, which handles pods without a runtime class.
+type RuntimeClass struct {
+	Name                 string   `json:"name"                 yaml:"name"`
+	DeviceListStrategies []string `json:"deviceListStrategies" yaml:"deviceListStrategies"`
+}
+
+// Supports checks whether the runtime of the runtime class supports any of the
+// enabled device list strategies.
+func (c *RuntimeClass) Supports(strategies DeviceListStrategies) bool {
+	for _, s := range c.DeviceListStrategies {
+		if strategies.Includes(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that valid device list strategies are specified.
+func (c *RuntimeClass) validate() error {
+	if len(c.DeviceListStrategies) == 0 {
+		return fmt.Errorf("no device list strategies specified for runtime class %q", c.Name)
+	}
+	if _, err := NewDeviceListStrategies(c.DeviceListStrategies); err != nil {
+		return fmt.Errorf("runtime class %q: %w", c.Name, err)
+	}
+	return nil
+}
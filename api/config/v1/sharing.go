@@ -16,14 +16,38 @@
 
 package v1
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Sharing encapsulates the set of sharing strategies that are supported.
 type Sharing struct {
 	// TimeSlicing defines the set of replicas to be made for timeSlicing available resources.
 	TimeSlicing ReplicatedResources `json:"timeSlicing,omitempty" yaml:"timeSlicing,omitempty"`
 	// MPS defines the set of replicas to be shared using MPS
 	MPS *ReplicatedResources `json:"mps,omitempty"         yaml:"mps,omitempty"`
+	// AllocationPolicy selects how the replicas of shared resources are
+	// placed on the physical GPUs in preferred allocations. By default,
+	// replicas are spread across GPUs, and milli-GPU units are packed.
+	AllocationPolicy AllocationPolicy `json:"allocationPolicy,omitempty" yaml:"allocationPolicy,omitempty"`
 }
 
+// AllocationPolicy defines how the replicas of shared resources are placed
+// on the physical GPUs.
+type AllocationPolicy string
+
+const (
+	// AllocationPolicyPacked fills the replicas of a GPU before replicas of
+	// other GPUs are allocated.
+	AllocationPolicyPacked = AllocationPolicy("packed")
+	// AllocationPolicySpread balances the allocated replicas across GPUs.
+	AllocationPolicySpread = AllocationPolicy("spread")
+	// AllocationPolicyWeighted balances the allocated replicas across GPUs in
+	// proportion to the total memory of the GPUs.
+	AllocationPolicyWeighted = AllocationPolicy("weighted")
+)
+
 type SharingStrategy string
 
 const (
@@ -32,7 +56,9 @@ const (
 	SharingStrategyTimeSlicing = SharingStrategy("time-slicing")
 )
 
-// SharingStrategy returns the active sharing strategy.
+// SharingStrategy returns the active sharing strategy. If resources are
+// shared using both time-slicing and MPS, MPS is returned; use
+// StrategyForResource to get the strategy of a specific resource.
 func (s *Sharing) SharingStrategy() SharingStrategy {
 	if s.MPS != nil && s.MPS.isReplicated() {
 		return SharingStrategyMPS
@@ -51,3 +77,245 @@ func (s *Sharing) ReplicatedResources() *ReplicatedResources {
 	}
 	return &s.TimeSlicing
 }
+
+// StrategyForResource returns the sharing strategy of the specified resource.
+// Both the name of a shared resource and the name it is renamed to are
+// matched.
+func (s *Sharing) StrategyForResource(name ResourceName) SharingStrategy {
+	switch rrs := s.ReplicatedResourcesFor(name); {
+	case rrs == nil:
+		return SharingStrategyNone
+	case rrs == s.MPS:
+		return SharingStrategyMPS
+	default:
+		return SharingStrategyTimeSlicing
+	}
+}
+
+// ReplicatedResourcesFor returns the resources of the sharing strategy that
+// shares the specified resource, or nil if the resource is not shared. The
+// resources a shared resource is advertised as are matched first, since the
+// devices of a resource may be split between both strategies.
+func (s *Sharing) ReplicatedResourcesFor(name ResourceName) *ReplicatedResources {
+	if s.MPS.isReplicated() && s.MPS.ForResource(name) != nil {
+		return s.MPS
+	}
+	if s.TimeSlicing.isReplicated() && s.TimeSlicing.ForResource(name) != nil {
+		return &s.TimeSlicing
+	}
+	if s.TimeSlicing.isReplicated() && s.TimeSlicing.shares(name) {
+		return &s.TimeSlicing
+	}
+	if s.MPS.isReplicated() && s.MPS.shares(name) {
+		return s.MPS
+	}
+	return nil
+}
+
+// splitResources returns the names of the resources whose devices are split
+// between time-slicing and MPS.
+func (s *Sharing) splitResources() map[ResourceName]bool {
+	if s.MPS == nil {
+		return nil
+	}
+	split := make(map[ResourceName]bool)
+	for _, r := range s.MPS.Resources {
+		if s.TimeSlicing.forName(r.Name) != nil {
+			split[r.Name] = true
+		}
+	}
+	return split
+}
+
+// AllReplicatedResources returns the resources of each sharing strategy that
+// shares resources, with time-slicing first.
+func (s *Sharing) AllReplicatedResources() []*ReplicatedResources {
+	var all []*ReplicatedResources
+	if s.TimeSlicing.isReplicated() {
+		all = append(all, &s.TimeSlicing)
+	}
+	if s.MPS.isReplicated() {
+		all = append(all, s.MPS)
+	}
+	return all
+}
+
+// GetAllocationPolicy returns the allocation policy for the replicas of the
+// specified resource.
+func (s *Sharing) GetAllocationPolicy(name ResourceName) AllocationPolicy {
+	if s.AllocationPolicy != "" {
+		return s.AllocationPolicy
+	}
+	if rrs := s.ReplicatedResourcesFor(name); rrs.IsMilli() || rrs.IsMemory() {
+		return AllocationPolicyPacked
+	}
+	return AllocationPolicySpread
+}
+
+// validateSplit checks that a resource that is shared using both time-slicing
+// and MPS advertises each set of replicas under its own name and that the
+// strategies do not select the same devices. The time-slicing replicas are
+// created first, so the MPS devices are selected from the remaining devices.
+func validateSplit(timeSlicing *ReplicatedResource, mps *ReplicatedResource) error {
+	if timeSlicing.Rename == "" || mps.Rename == "" || timeSlicing.Rename == mps.Rename {
+		return fmt.Errorf("resource %v is shared using both time-slicing and MPS without a distinct rename for each", mps.Name)
+	}
+	if timeSlicing.Devices.All {
+		return fmt.Errorf("resource %v is shared using both time-slicing and MPS but time-slicing selects all devices", mps.Name)
+	}
+	if timeSlicing.AdvertiseWhole {
+		return fmt.Errorf("advertiseWhole is not supported for resource %v shared using both time-slicing and MPS", mps.Name)
+	}
+	selected := make(map[ReplicatedDeviceRef]bool)
+	for _, ref := range timeSlicing.Devices.List {
+		selected[ref] = true
+	}
+	for _, ref := range mps.Devices.List {
+		if selected[ref] {
+			return fmt.Errorf("device %v of resource %v is shared using both time-slicing and MPS", ref, mps.Name)
+		}
+	}
+	return nil
+}
+
+// validate checks that strategy-specific options are only set for the
+// sharing strategies that support them.
+func (s *Sharing) validate() error {
+	switch s.AllocationPolicy {
+	case "", AllocationPolicyPacked:
+	case AllocationPolicySpread, AllocationPolicyWeighted:
+		if s.TimeSlicing.IsMilli() {
+			return fmt.Errorf("allocationPolicy %q is not supported with unit %q", s.AllocationPolicy, s.TimeSlicing.Unit)
+		}
+		if s.MPS.IsMemory() {
+			return fmt.Errorf("allocationPolicy %q is not supported with unit %q", s.AllocationPolicy, s.MPS.Unit)
+		}
+	default:
+		return fmt.Errorf("unknown allocationPolicy %q", s.AllocationPolicy)
+	}
+	if s.TimeSlicing.IsMemory() {
+		return fmt.Errorf("unit %q is only supported for MPS", s.TimeSlicing.Unit)
+	}
+	for _, r := range s.TimeSlicing.Resources {
+		if r.BurstReplicas > 0 && s.TimeSlicing.IsMilli() {
+			return fmt.Errorf("burstReplicas is not supported with unit %q: %v", s.TimeSlicing.Unit, r.Name)
+		}
+		if r.LogDirectory != "" {
+			return fmt.Errorf("logDirectory is only supported for MPS: %v", r.Name)
+		}
+		if r.PipeDirectory != "" {
+			return fmt.Errorf("pipeDirectory is only supported for MPS: %v", r.Name)
+		}
+		if r.PipeDirectorySizeMB != nil {
+			return fmt.Errorf("pipeDirectorySizeMB is only supported for MPS: %v", r.Name)
+		}
+		if r.ClientAffinity != "" {
+			return fmt.Errorf("clientAffinity is only supported for MPS: %v", r.Name)
+		}
+		if r.ServerMemoryOverheadMB != nil {
+			return fmt.Errorf("serverMemoryOverheadMB is only supported for MPS: %v", r.Name)
+		}
+		if r.MemoryLimit != nil {
+			return fmt.Errorf("memoryLimit is only supported for MPS: %v", r.Name)
+		}
+		if r.MemoryLimitMB != nil {
+			return fmt.Errorf("memoryLimitMB is only supported for MPS: %v", r.Name)
+		}
+		if len(r.ReplicaMemoryLimitsMB) > 0 {
+			return fmt.Errorf("replicaMemoryLimitsMB is only supported for MPS: %v", r.Name)
+		}
+		if r.ThreadLimit != nil {
+			return fmt.Errorf("threadLimit is only supported for MPS: %v", r.Name)
+		}
+		if r.ThreadPercentage != nil {
+			return fmt.Errorf("threadPercentage is only supported for MPS: %v", r.Name)
+		}
+		if r.MaxThreadPercentage != nil {
+			return fmt.Errorf("maxThreadPercentage is only supported for MPS: %v", r.Name)
+		}
+		if r.TrustedWorkloads != nil {
+			return fmt.Errorf("trustedWorkloads is only supported for MPS: %v", r.Name)
+		}
+		if r.PerMigDevice {
+			return fmt.Errorf("perMigDevice is only supported for MPS: %v", r.Name)
+		}
+		if len(r.EnvPassthrough) > 0 {
+			return fmt.Errorf("envPassthrough is only supported for MPS: %v", r.Name)
+		}
+		if r.UserID != nil || r.GroupID != nil {
+			return fmt.Errorf("userID and groupID are only supported for MPS: %v", r.Name)
+		}
+	}
+	if s.TimeSlicing.UserID != nil || s.TimeSlicing.GroupID != nil {
+		return fmt.Errorf("userID and groupID are only supported for MPS")
+	}
+	if s.MPS == nil {
+		return nil
+	}
+	if s.MPS.IsMilli() {
+		return fmt.Errorf("unit %q is only supported for time-slicing", s.MPS.Unit)
+	}
+	for _, r := range s.MPS.Resources {
+		if r.AdvertiseWhole {
+			return fmt.Errorf("advertiseWhole is only supported for time-slicing: %v", r.Name)
+		}
+		if r.BurstReplicas > 0 {
+			return fmt.Errorf("burstReplicas is only supported for time-slicing: %v", r.Name)
+		}
+		if t := s.TimeSlicing.forName(r.Name); t != nil {
+			if err := validateSplit(t, &r); err != nil {
+				return err
+			}
+			continue
+		}
+		if s.TimeSlicing.shares(r.Name) || (r.Rename != "" && s.TimeSlicing.shares(r.Rename)) {
+			return fmt.Errorf("resource %v is shared using both time-slicing and MPS", r.Name)
+		}
+	}
+	logDirectories := make(map[string]ResourceName)
+	for _, r := range s.MPS.Resources {
+		if r.LogDirectory == "" {
+			continue
+		}
+		if other, exists := logDirectories[r.LogDirectory]; exists {
+			return fmt.Errorf("logDirectory %q used for both %v and %v", r.LogDirectory, other, r.Name)
+		}
+		logDirectories[r.LogDirectory] = r.Name
+	}
+	// The pipe directories must not overlap with each other, with the shared
+	// shm directory, or with the default directories of the MPS resources
+	// under the MPS root, since they may be mounted separately and are
+	// removed along with the directories of the resources.
+	reserved := map[string]string{"shm": "the shm directory"}
+	for _, r := range s.MPS.Resources {
+		for _, name := range []ResourceName{r.Name, r.Rename, r.Name.DefaultSharedRename()} {
+			if name != "" {
+				reserved[string(name)] = fmt.Sprintf("the directory of %v", name)
+			}
+		}
+	}
+	pipeDirectories := make(map[string]ResourceName)
+	for _, r := range s.MPS.Resources {
+		if r.PipeDirectory == "" {
+			continue
+		}
+		for dir, other := range pipeDirectories {
+			if pathsOverlap(r.PipeDirectory, dir) {
+				return fmt.Errorf("pipeDirectory %q of %v overlaps with pipeDirectory %q of %v", r.PipeDirectory, r.Name, dir, other)
+			}
+		}
+		for dir, description := range reserved {
+			if pathsOverlap(r.PipeDirectory, dir) {
+				return fmt.Errorf("pipeDirectory %q of %v overlaps with %v", r.PipeDirectory, r.Name, description)
+			}
+		}
+		pipeDirectories[r.PipeDirectory] = r.Name
+	}
+	return nil
+}
+
+// pathsOverlap returns whether the specified clean relative paths are equal
+// or one contains the other.
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
@@ -0,0 +1,470 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// These constants represent the sources that a config can be read from.
+const (
+	ConfigSourceFiles = "files"
+	ConfigSourceCRD   = "crd"
+)
+
+var (
+	// ClusterDevicePluginConfigResource is the cluster-scoped custom resource holding device plugin configs.
+	ClusterDevicePluginConfigResource = schema.GroupVersionResource{
+		Group:    "nvidia.com",
+		Version:  "v1alpha1",
+		Resource: "clusterdevicepluginconfigs",
+	}
+	// DevicePluginConfigResource is the namespaced custom resource holding device plugin configs.
+	DevicePluginConfigResource = schema.GroupVersionResource{
+		Group:    "nvidia.com",
+		Version:  "v1alpha1",
+		Resource: "devicepluginconfigs",
+	}
+)
+
+// devicePluginConfigSpec is the spec of a (Cluster)DevicePluginConfig custom resource.
+type devicePluginConfigSpec struct {
+	// NodeSelector selects the nodes that the config applies to. If no
+	// selector is specified, the config applies to all nodes.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// Priority defines the order in which the configs that apply to a node are
+	// merged. Configs with a higher priority override those with a lower priority.
+	Priority int `json:"priority,omitempty"`
+	// Config is the device plugin config.
+	Config json.RawMessage `json:"config"`
+}
+
+// ConditionValid is the type of the status condition that reports whether the
+// spec of a (Cluster)DevicePluginConfig custom resource is valid.
+const ConditionValid = "Valid"
+
+// These constants represent the reasons of the Valid condition.
+const (
+	ReasonValid         = "Valid"
+	ReasonInvalidSpec   = "InvalidSpec"
+	ReasonInvalidConfig = "InvalidConfig"
+)
+
+// statusUpdateTimeout bounds the time spent updating the status of a custom
+// resource.
+const statusUpdateTimeout = 10 * time.Second
+
+// statusFieldManager is the field manager with which the config manager of a
+// node applies its entry of the status of a custom resource. The name of the
+// node is appended, so that each node owns its own entry.
+const statusFieldManager = "config-manager-"
+
+// devicePluginConfigStatus is the status of a (Cluster)DevicePluginConfig custom resource.
+type devicePluginConfigStatus struct {
+	// Nodes holds the status reported by the config manager of each node.
+	Nodes []nodeConfigStatus `json:"nodes,omitempty"`
+}
+
+// nodeConfigStatus is the status of a custom resource as reported by the
+// config manager of a node. Each node only writes its own entry, so that the
+// config managers of all nodes do not race to write the same status.
+type nodeConfigStatus struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+	// ObservedGeneration is the generation of the spec that the status
+	// reports on.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions report whether the spec is valid.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// statusUpdater applies the status of a custom resource as a server-side
+// apply configuration.
+type statusUpdater func(ctx context.Context, obj *unstructured.Unstructured) error
+
+// devicePluginConfig is a parsed (Cluster)DevicePluginConfig custom resource.
+type devicePluginConfig struct {
+	name     string
+	selector labels.Selector
+	priority int
+	config   json.RawMessage
+}
+
+// crdConfigs tracks the device plugin configs defined as custom resources and
+// the labels of the node. Each time either changes, the effective config for
+// the node is rendered and set on the SyncableConfig.
+type crdConfigs struct {
+	sync.Mutex
+	// ready is set once the initial set of custom resources has been listed
+	// so that the node is not transiently updated to a partial config.
+	ready      bool
+	nodeLabels map[string]string
+	configs    map[string]devicePluginConfig
+	synced     *SyncableConfig
+	// generations holds the last generation of each custom resource that was
+	// processed. Updates that do not change the generation, such as updates
+	// of the status or the metadata, do not change the spec and are skipped.
+	generations map[string]int64
+	// updateStatus writes the status of a custom resource. If nil, the status
+	// is not reported.
+	updateStatus statusUpdater
+	// nodeName is the name of the node whose entry of the status is written.
+	nodeName string
+}
+
+func newCRDConfigs(synced *SyncableConfig) *crdConfigs {
+	return &crdConfigs{
+		configs:     make(map[string]devicePluginConfig),
+		synced:      synced,
+		generations: make(map[string]int64),
+	}
+}
+
+// setReady marks the initial set of custom resources as listed.
+func (c *crdConfigs) setReady() {
+	c.Lock()
+	defer c.Unlock()
+	c.ready = true
+	c.update()
+}
+
+// setNodeLabels updates the labels of the node.
+func (c *crdConfigs) setNodeLabels(nodeLabels map[string]string) {
+	c.Lock()
+	defer c.Unlock()
+	if nodeLabels == nil {
+		nodeLabels = make(map[string]string)
+	}
+	c.nodeLabels = nodeLabels
+	c.update()
+}
+
+// set adds or updates the config defined by the specified custom resource.
+func (c *crdConfigs) set(obj *unstructured.Unstructured) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Errorf("Error getting key for device plugin config: %v", err)
+		return
+	}
+	if !c.observe(key, obj.GetGeneration()) {
+		return
+	}
+	config, err := parseDevicePluginConfig(key, obj)
+	c.reportStatus(obj, err)
+	if err != nil {
+		klog.Errorf("Ignoring invalid device plugin config %v: %v", key, err)
+		c.remove(key)
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.configs[key] = config
+	c.update()
+}
+
+// observe records the generation of the specified custom resource and returns
+// whether its spec may have changed since it was last processed.
+func (c *crdConfigs) observe(key string, generation int64) bool {
+	c.Lock()
+	defer c.Unlock()
+	if last, exists := c.generations[key]; exists && generation != 0 && last == generation {
+		return false
+	}
+	c.generations[key] = generation
+	return true
+}
+
+// reportStatus sets the Valid condition in the entry of the node in the status
+// of the specified custom resource. The spec of the resource is valid if it
+// can be parsed and its config is valid on its own.
+func (c *crdConfigs) reportStatus(obj *unstructured.Unstructured, parseErr error) {
+	if c.updateStatus == nil {
+		return
+	}
+	condition := validCondition(obj, parseErr)
+	updated, changed, err := setStatusCondition(obj, c.nodeName, condition)
+	if err != nil {
+		klog.Errorf("Error setting status of device plugin config %v: %v", obj.GetName(), err)
+		return
+	}
+	if !changed {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), statusUpdateTimeout)
+	defer cancel()
+	if err := c.updateStatus(ctx, updated); err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("Error updating status of device plugin config %v: %v", obj.GetName(), err)
+	}
+}
+
+// validCondition returns the Valid condition for the specified custom resource.
+func validCondition(obj *unstructured.Unstructured, parseErr error) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               ConditionValid,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             ReasonValid,
+		Message:            "The config is valid",
+	}
+	if parseErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonInvalidSpec
+		condition.Message = parseErr.Error()
+		return condition
+	}
+	if err := validateDevicePluginConfig(obj); err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonInvalidConfig
+		condition.Message = err.Error()
+	}
+	return condition
+}
+
+// validateDevicePluginConfig validates the config of the specified custom
+// resource on its own. The merged config of a node is validated separately
+// since it depends on the other resources that apply to the node.
+func validateDevicePluginConfig(obj *unstructured.Unstructured) error {
+	raw, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "config")
+	config, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	_, err = spec.ParseConfigFrom(bytes.NewReader(config))
+	return err
+}
+
+// setStatusCondition returns the apply configuration of the specified custom
+// resource that sets the condition and the observed generation in the entry
+// of the specified node in its status, and whether the entry changed.
+func setStatusCondition(obj *unstructured.Unstructured, node string, condition metav1.Condition) (*unstructured.Unstructured, bool, error) {
+	var status devicePluginConfigStatus
+	if raw, exists := obj.Object["status"].(map[string]interface{}); exists {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &status); err != nil {
+			return nil, false, fmt.Errorf("error parsing status: %v", err)
+		}
+	}
+	entry := nodeConfigStatus{Name: node}
+	for _, s := range status.Nodes {
+		if s.Name == node {
+			entry = s
+			break
+		}
+	}
+	changed := meta.SetStatusCondition(&entry.Conditions, condition)
+	if entry.ObservedGeneration != obj.GetGeneration() {
+		entry.ObservedGeneration = obj.GetGeneration()
+		changed = true
+	}
+	if !changed {
+		return nil, false, nil
+	}
+
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&devicePluginConfigStatus{Nodes: []nodeConfigStatus{entry}})
+	if err != nil {
+		return nil, false, fmt.Errorf("error converting status: %v", err)
+	}
+	apply := &unstructured.Unstructured{}
+	apply.SetAPIVersion(obj.GetAPIVersion())
+	apply.SetKind(obj.GetKind())
+	apply.SetName(obj.GetName())
+	apply.SetNamespace(obj.GetNamespace())
+	apply.Object["status"] = raw
+	return apply, true, nil
+}
+
+// remove removes the config with the specified key.
+func (c *crdConfigs) remove(key string) {
+	c.Lock()
+	defer c.Unlock()
+	if _, exists := c.configs[key]; !exists {
+		return
+	}
+	delete(c.configs, key)
+	c.update()
+}
+
+// forget removes the config of a deleted custom resource.
+func (c *crdConfigs) forget(key string) {
+	c.Lock()
+	delete(c.generations, key)
+	c.Unlock()
+	c.remove(key)
+}
+
+// update renders the effective config for the node and sets it if it is valid.
+// The caller must hold the lock.
+func (c *crdConfigs) update() {
+	if !c.ready || c.nodeLabels == nil {
+		return
+	}
+	rendered, err := c.render()
+	if err != nil {
+		klog.Errorf("Error rendering device plugin config; skipping reconfiguration: %v", err)
+		return
+	}
+	c.synced.Set(rendered)
+}
+
+// render merges the configs that apply to the node in order of increasing
+// priority and returns the result. Configs with the same priority are merged
+// in the order of their names. An empty string is returned if no config
+// applies to the node.
+func (c *crdConfigs) render() (string, error) {
+	var matching []devicePluginConfig
+	for _, config := range c.configs {
+		if config.selector.Matches(labels.Set(c.nodeLabels)) {
+			matching = append(matching, config)
+		}
+	}
+	if len(matching) == 0 {
+		return "", nil
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		if matching[i].priority != matching[j].priority {
+			return matching[i].priority < matching[j].priority
+		}
+		return matching[i].name < matching[j].name
+	})
+
+	merged := []byte(`{}`)
+	var names []string
+	for _, config := range matching {
+		var err error
+		merged, err = jsonpatch.MergePatch(merged, config.config)
+		if err != nil {
+			return "", fmt.Errorf("error merging config %v: %v", config.name, err)
+		}
+		names = append(names, config.name)
+	}
+
+	if _, err := spec.ParseConfigFrom(bytes.NewReader(merged)); err != nil {
+		return "", fmt.Errorf("invalid config merged from %v: %v", names, err)
+	}
+	klog.Infof("Rendered config from %v", names)
+	return string(merged), nil
+}
+
+// parseDevicePluginConfig parses the spec of a (Cluster)DevicePluginConfig custom resource.
+func parseDevicePluginConfig(name string, obj *unstructured.Unstructured) (devicePluginConfig, error) {
+	raw, err := json.Marshal(obj.Object["spec"])
+	if err != nil {
+		return devicePluginConfig{}, fmt.Errorf("error marshaling spec: %v", err)
+	}
+	var configSpec devicePluginConfigSpec
+	if err := json.Unmarshal(raw, &configSpec); err != nil {
+		return devicePluginConfig{}, fmt.Errorf("error unmarshaling spec: %v", err)
+	}
+	if len(configSpec.Config) == 0 || string(configSpec.Config) == "null" {
+		return devicePluginConfig{}, fmt.Errorf("no config specified")
+	}
+
+	selector := labels.Everything()
+	if configSpec.NodeSelector != nil {
+		selector, err = metav1.LabelSelectorAsSelector(configSpec.NodeSelector)
+		if err != nil {
+			return devicePluginConfig{}, fmt.Errorf("invalid nodeSelector: %v", err)
+		}
+	}
+
+	return devicePluginConfig{
+		name:     name,
+		selector: selector,
+		priority: configSpec.Priority,
+		config:   configSpec.Config,
+	}, nil
+}
+
+// continuouslySyncCRDConfigChanges watches the device plugin config custom
+// resources. If a namespace is specified, the namespaced DevicePluginConfig
+// resources in that namespace are watched. Otherwise the cluster-scoped
+// ClusterDevicePluginConfig resources are watched.
+func continuouslySyncCRDConfigChanges(client dynamic.Interface, configs *crdConfigs, f *Flags) chan struct{} {
+	resource := ClusterDevicePluginConfigResource
+	if f.CRDNamespace != "" {
+		resource = DevicePluginConfigResource
+	}
+
+	// The status is applied server-side with a field manager per node, so
+	// that the entries of the nodes are merged rather than overwritten.
+	configs.nodeName = f.NodeName
+	options := metav1.ApplyOptions{
+		FieldManager: truncate(statusFieldManager+f.NodeName, 128),
+		Force:        true,
+	}
+	configs.updateStatus = func(ctx context.Context, obj *unstructured.Unstructured) error {
+		_, err := client.Resource(resource).Namespace(obj.GetNamespace()).ApplyStatus(ctx, obj.GetName(), obj, options)
+		return err
+	}
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, f.CRDNamespace, nil)
+	informer := factory.ForResource(resource).Informer()
+	_, _ = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			configs.set(obj.(*unstructured.Unstructured))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			configs.set(newObj.(*unstructured.Unstructured))
+		},
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				klog.Errorf("Error getting key for deleted device plugin config: %v", err)
+				return
+			}
+			configs.forget(key)
+		},
+	})
+
+	stop := make(chan struct{})
+	factory.Start(stop)
+	go func() {
+		if cache.WaitForCacheSync(stop, informer.HasSynced) {
+			configs.setReady()
+		}
+	}()
+	return stop
+}
+
+// truncate returns at most the first n bytes of the specified string.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
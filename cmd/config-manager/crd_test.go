@@ -0,0 +1,217 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newDevicePluginConfig(name string, priority int64, matchLabels map[string]interface{}, config map[string]interface{}) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"priority": priority,
+		"config":   config,
+	}
+	if matchLabels != nil {
+		spec["nodeSelector"] = map[string]interface{}{
+			"matchLabels": matchLabels,
+		}
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "nvidia.com/v1alpha1",
+			"kind":       "ClusterDevicePluginConfig",
+			"metadata": map[string]interface{}{
+				"name": name,
+			},
+			"spec": spec,
+		},
+	}
+}
+
+func TestCRDConfigsRender(t *testing.T) {
+	base := newDevicePluginConfig("base", 0, nil, map[string]interface{}{
+		"version": "v1",
+		"flags": map[string]interface{}{
+			"migStrategy": "none",
+		},
+		"sharing": map[string]interface{}{
+			"timeSlicing": map[string]interface{}{
+				"resources": []interface{}{
+					map[string]interface{}{"name": "nvidia.com/gpu", "replicas": int64(2)},
+				},
+			},
+		},
+	})
+	t4 := newDevicePluginConfig("t4", 10, map[string]interface{}{"nvidia.com/gpu.product": "TESLA-T4"}, map[string]interface{}{
+		"sharing": map[string]interface{}{
+			"timeSlicing": map[string]interface{}{
+				"resources": []interface{}{
+					map[string]interface{}{"name": "nvidia.com/gpu", "replicas": int64(4)},
+				},
+			},
+		},
+	})
+	invalid := newDevicePluginConfig("invalid", 20, map[string]interface{}{"pool": "broken"}, map[string]interface{}{
+		"version": "v2",
+	})
+
+	testCases := []struct {
+		description string
+		nodeLabels  map[string]string
+		configs     []*unstructured.Unstructured
+		expected    string
+		unchanged   bool
+	}{
+		{
+			description: "no configs",
+			nodeLabels:  map[string]string{},
+			expected:    "",
+		},
+		{
+			description: "base config applies to all nodes",
+			nodeLabels:  map[string]string{},
+			configs:     []*unstructured.Unstructured{base, t4},
+			expected:    `{"flags":{"migStrategy":"none"},"sharing":{"timeSlicing":{"resources":[{"name":"nvidia.com/gpu","replicas":2}]}},"version":"v1"}`,
+		},
+		{
+			description: "node pool config overrides base config",
+			nodeLabels:  map[string]string{"nvidia.com/gpu.product": "TESLA-T4"},
+			configs:     []*unstructured.Unstructured{t4, base},
+			expected:    `{"flags":{"migStrategy":"none"},"sharing":{"timeSlicing":{"resources":[{"name":"nvidia.com/gpu","replicas":4}]}},"version":"v1"}`,
+		},
+		{
+			description: "invalid config is not applied",
+			nodeLabels:  map[string]string{"pool": "broken"},
+			configs:     []*unstructured.Unstructured{base, invalid},
+			unchanged:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			synced := NewSyncableConfig(nil)
+			synced.Set("unchanged")
+			configs := newCRDConfigs(synced)
+			for _, c := range tc.configs {
+				configs.set(c)
+			}
+			configs.setNodeLabels(tc.nodeLabels)
+			configs.setReady()
+
+			expected := tc.expected
+			if tc.unchanged {
+				expected = "unchanged"
+			}
+			require.Equal(t, expected, synced.current)
+		})
+	}
+}
+
+func TestCRDConfigsRemove(t *testing.T) {
+	synced := NewSyncableConfig(nil)
+	configs := newCRDConfigs(synced)
+	configs.setReady()
+	configs.setNodeLabels(nil)
+
+	configs.set(newDevicePluginConfig("base", 0, nil, map[string]interface{}{"version": "v1"}))
+	require.Equal(t, `{"version":"v1"}`, synced.current)
+
+	configs.remove("base")
+	require.Equal(t, "", synced.current)
+}
+
+func TestCRDConfigsStatus(t *testing.T) {
+	var updates []*unstructured.Unstructured
+	configs := newCRDConfigs(NewSyncableConfig(nil))
+	configs.nodeName = "node-a"
+	configs.updateStatus = func(_ context.Context, obj *unstructured.Unstructured) error {
+		updates = append(updates, obj)
+		return nil
+	}
+	// validCondition returns the Valid condition of the single node entry
+	// of the applied status.
+	validCondition := func(obj *unstructured.Unstructured, generation int64) *metav1.Condition {
+		var status devicePluginConfigStatus
+		require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object["status"].(map[string]interface{}), &status))
+		require.Len(t, status.Nodes, 1)
+		require.Equal(t, "node-a", status.Nodes[0].Name)
+		require.Equal(t, generation, status.Nodes[0].ObservedGeneration)
+		return meta.FindStatusCondition(status.Nodes[0].Conditions, ConditionValid)
+	}
+
+	valid := newDevicePluginConfig("valid", 0, nil, map[string]interface{}{"version": "v1"})
+	valid.SetGeneration(1)
+	configs.set(valid)
+	require.Len(t, updates, 1)
+	require.Equal(t, "valid", updates[0].GetName())
+	require.Nil(t, updates[0].Object["spec"])
+	condition := validCondition(updates[0], 1)
+	require.Equal(t, metav1.ConditionTrue, condition.Status)
+	require.Equal(t, ReasonValid, condition.Reason)
+	require.Equal(t, int64(1), condition.ObservedGeneration)
+
+	// The entries of other nodes are kept by the server.
+	applied := valid.DeepCopy()
+	applied.Object["status"] = map[string]interface{}{
+		"nodes": append([]interface{}{
+			map[string]interface{}{"name": "node-b", "observedGeneration": int64(1)},
+		}, updates[0].Object["status"].(map[string]interface{})["nodes"].([]interface{})...),
+	}
+
+	// Updates of the status do not change the generation and are skipped.
+	configs.set(applied)
+	require.Len(t, updates, 1)
+
+	// A resource whose status is current for the node is not updated.
+	configs.forget("valid")
+	configs.set(applied)
+	require.Len(t, updates, 1)
+
+	// The status is only reported for the node itself.
+	configs.nodeName = "node-b"
+	configs.forget("valid")
+	configs.set(applied)
+	require.Len(t, updates, 2)
+	configs.nodeName = "node-a"
+
+	invalid := applied.DeepCopy()
+	invalid.Object["spec"].(map[string]interface{})["config"] = map[string]interface{}{"version": "v2"}
+	invalid.SetGeneration(2)
+	configs.set(invalid)
+	require.Len(t, updates, 3)
+	condition = validCondition(updates[2], 2)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, ReasonInvalidConfig, condition.Reason)
+	require.Contains(t, condition.Message, "unknown version")
+
+	noConfig := newDevicePluginConfig("no-config", 0, nil, nil)
+	delete(noConfig.Object["spec"].(map[string]interface{}), "config")
+	noConfig.SetGeneration(1)
+	configs.set(noConfig)
+	require.Len(t, updates, 4)
+	condition = validCondition(updates[3], 1)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, ReasonInvalidSpec, condition.Reason)
+	require.Equal(t, "no config specified", condition.Message)
+}
@@ -29,10 +29,14 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/writable"
 )
 
 const (
@@ -47,6 +51,7 @@ const (
 	DefaultSignal          = int(syscall.SIGHUP)
 	DefaultProcessToSignal = "nvidia-device-plugin"
 	DefaultConfigLabel     = "nvidia.com/device-plugin.config"
+	DefaultConfigSource    = ConfigSourceFiles
 )
 
 // These constants represent the various FallbackStrategies that are possible
@@ -72,6 +77,9 @@ type Flags struct {
 	SendSignal         bool
 	Signal             int
 	ProcessToSignal    string
+	ConfigSource       string
+	CRDNamespace       string
+	ConfigOverlaysFile string
 }
 
 // SyncableConfig is used to synchronize on changes to a configuration value
@@ -201,6 +209,27 @@ func main() {
 			Destination: &flags.ProcessToSignal,
 			EnvVars:     []string{"PROCESS_TO_SIGNAL"},
 		},
+		&cli.StringFlag{
+			Name:        "config-source",
+			Value:       DefaultConfigSource,
+			Usage:       "the source of the device configuration:\n\t\t[files | crd]",
+			Destination: &flags.ConfigSource,
+			EnvVars:     []string{"CONFIG_SOURCE"},
+		},
+		&cli.StringFlag{
+			Name:        "crd-namespace",
+			Value:       "",
+			Usage:       "the namespace of the DevicePluginConfig resources to read if <config-source> is 'crd'; if empty, the cluster-scoped ClusterDevicePluginConfig resources are read",
+			Destination: &flags.CRDNamespace,
+			EnvVars:     []string{"CRD_NAMESPACE"},
+		},
+		&cli.StringFlag{
+			Name:        "config-overlays-file",
+			Value:       "",
+			Usage:       "the name of a file in <config-file-srcdir> with partial configs that are merged into the selected config on the nodes matching their node selectors; the file cannot be selected as a config",
+			Destination: &flags.ConfigOverlaysFile,
+			EnvVars:     []string{"CONFIG_OVERLAYS_FILE"},
+		},
 	}
 
 	err := c.Run(os.Args)
@@ -217,8 +246,17 @@ func validateFlags(c *cli.Context, f *Flags) error {
 	if f.NodeLabel == "" {
 		return fmt.Errorf("invalid <node-label>: must not be empty string")
 	}
-	if f.ConfigFileSrcdir == "" {
-		return fmt.Errorf("invalid <config-file-srcdir>: must not be empty string")
+	switch f.ConfigSource {
+	case ConfigSourceFiles:
+		if f.ConfigFileSrcdir == "" {
+			return fmt.Errorf("invalid <config-file-srcdir>: must not be empty string")
+		}
+	case ConfigSourceCRD:
+		if f.ConfigOverlaysFile != "" {
+			return fmt.Errorf("invalid <config-overlays-file>: not supported with <config-source> %v", f.ConfigSource)
+		}
+	default:
+		return fmt.Errorf("invalid <config-source>: %v", f.ConfigSource)
 	}
 	if f.ConfigFileDst == "" {
 		return fmt.Errorf("invalid <config-file-dst>: must not be empty string")
@@ -227,6 +265,10 @@ func validateFlags(c *cli.Context, f *Flags) error {
 }
 
 func start(c *cli.Context, f *Flags) error {
+	if err := writable.File("the config file symlink", f.ConfigFileDst).Check(); err != nil {
+		return err
+	}
+
 	kubeconfig, err := clientcmd.BuildConfigFromFlags("", f.Kubeconfig)
 	if err != nil {
 		return fmt.Errorf("error building kubernetes clientcmd config: %s", err)
@@ -239,6 +281,22 @@ func start(c *cli.Context, f *Flags) error {
 
 	config := NewSyncableConfig(f)
 
+	if f.ConfigSource == ConfigSourceCRD {
+		dynamicClient, err := dynamic.NewForConfig(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("error building kubernetes dynamic client from config: %s", err)
+		}
+		return startCRD(clientset, dynamicClient, config, f)
+	}
+
+	if f.ConfigOverlaysFile != "" {
+		overlays, err := loadConfigOverlays(f)
+		if err != nil {
+			return err
+		}
+		return startOverlays(clientset, config, overlays, f)
+	}
+
 	stop := continuouslySyncConfigChanges(clientset, config, f)
 	defer close(stop)
 
@@ -253,16 +311,68 @@ func start(c *cli.Context, f *Flags) error {
 	}
 }
 
-func continuouslySyncConfigChanges(clientset *kubernetes.Clientset, config *SyncableConfig, f *Flags) chan struct{} {
-	listWatch := cache.NewListWatchFromClient(
-		clientset.CoreV1().RESTClient(),
-		ResourceNodes,
-		v1.NamespaceAll,
-		fields.OneTermEqualSelector("metadata.name", f.NodeName),
-	)
+// startCRD updates the config from the device plugin config custom resources
+// that apply to the node each time the resources or the node labels change.
+func startCRD(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, config *SyncableConfig, f *Flags) error {
+	configs := newCRDConfigs(config)
 
-	_, controller := cache.NewInformer(
-		listWatch, &v1.Node{}, 0,
+	stopCRDs := continuouslySyncCRDConfigChanges(dynamicClient, configs, f)
+	defer close(stopCRDs)
+
+	stopNode := watchNode(clientset, f, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			configs.setNodeLabels(obj.(*v1.Node).Labels)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			configs.setNodeLabels(newObj.(*v1.Node).Labels)
+		},
+		DeleteFunc: func(obj interface{}) {
+			configs.setNodeLabels(nil)
+		},
+	})
+	defer close(stopNode)
+
+	for {
+		klog.Infof("Waiting for change to device plugin config resources")
+		config := config.Get()
+		klog.Infof("Device plugin config change detected")
+		err := updateConfigContents(config, f)
+		if f.Oneshot || err != nil {
+			return err
+		}
+	}
+}
+
+// startOverlays updates the config from the selected config merged with the
+// overlays that apply to the node each time the node labels or the config
+// files change.
+func startOverlays(clientset *kubernetes.Clientset, config *SyncableConfig, overlays []configOverlay, f *Flags) error {
+	configs := newOverlayConfigs(config, overlays, f)
+
+	if !f.Oneshot {
+		stopReload, err := continuouslyReloadOverlays(configs, f)
+		if err != nil {
+			return err
+		}
+		defer close(stopReload)
+	}
+
+	stopNode := continuouslySyncOverlayChanges(clientset, configs, f)
+	defer close(stopNode)
+
+	for {
+		klog.Infof("Waiting for change to '%s' label or the matching overlays", f.NodeLabel)
+		selection := config.Get()
+		klog.Infof("Change detected: %s", selection)
+		err := updateConfigWithOverlays(selection, f)
+		if f.Oneshot || err != nil {
+			return err
+		}
+	}
+}
+
+func continuouslySyncConfigChanges(clientset *kubernetes.Clientset, config *SyncableConfig, f *Flags) chan struct{} {
+	return watchNode(clientset, f,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 				config.Set(obj.(*v1.Node).Labels[f.NodeLabel])
@@ -282,6 +392,18 @@ func continuouslySyncConfigChanges(clientset *kubernetes.Clientset, config *Sync
 			},
 		},
 	)
+}
+
+// watchNode runs an informer for the node with the specified event handlers.
+func watchNode(clientset *kubernetes.Clientset, f *Flags, handlers cache.ResourceEventHandlerFuncs) chan struct{} {
+	listWatch := cache.NewListWatchFromClient(
+		clientset.CoreV1().RESTClient(),
+		ResourceNodes,
+		v1.NamespaceAll,
+		fields.OneTermEqualSelector("metadata.name", f.NodeName),
+	)
+
+	_, controller := cache.NewInformer(listWatch, &v1.Node{}, 0, handlers)
 
 	stop := make(chan struct{})
 	go controller.Run(stop)
@@ -315,6 +437,37 @@ func updateConfig(config string, f *Flags) error {
 		klog.Infof("Successfully updated to config: %s", config)
 	}
 
+	return signalUpdate(f)
+}
+
+// updateConfigContents writes the specified config contents to the destination
+// config file. If the contents are empty, the empty config is used.
+func updateConfigContents(contents string, f *Flags) error {
+	var updated bool
+	var err error
+	if contents == "" {
+		klog.Infof("Updating to empty config")
+		updated, err = updateSymlink("", f)
+	} else {
+		klog.Infof("Updating to config:\n%s", contents)
+		// The header allows the plugins to attribute the values of the
+		// config to the custom resources.
+		updated, err = writeConfigFile(spec.CRDConfigHeader+contents, f)
+	}
+	if err != nil {
+		return err
+	}
+	if !updated {
+		klog.Infof("Already configured. Skipping update...")
+		return nil
+	}
+	klog.Infof("Successfully updated config")
+
+	return signalUpdate(f)
+}
+
+// signalUpdate signals the process to reload its config if required.
+func signalUpdate(f *Flags) error {
 	if f.SendSignal {
 		klog.Infof("Sending signal '%s' to '%s'", syscall.Signal(f.Signal), f.ProcessToSignal)
 		err := signalProcess(f)
@@ -426,6 +579,29 @@ func updateSymlink(config string, f *Flags) (bool, error) {
 	return true, nil
 }
 
+// writeConfigFile atomically replaces the destination config file with the
+// specified contents. No update is made if the contents are unchanged.
+func writeConfigFile(contents string, f *Flags) (bool, error) {
+	if info, err := os.Lstat(f.ConfigFileDst); err == nil && info.Mode().IsRegular() {
+		current, err := os.ReadFile(f.ConfigFileDst)
+		if err != nil {
+			return false, fmt.Errorf("error reading existing config: %v", err)
+		}
+		if string(current) == contents {
+			return false, nil
+		}
+	}
+
+	tmp := f.ConfigFileDst + ".tmp"
+	if err := os.WriteFile(tmp, []byte(contents), 0644); err != nil {
+		return false, fmt.Errorf("error writing config: %v", err)
+	}
+	if err := os.Rename(tmp, f.ConfigFileDst); err != nil {
+		return false, fmt.Errorf("error replacing existing config: %v", err)
+	}
+	return true, nil
+}
+
 func signalProcess(f *Flags) error {
 	pid, err := findPidToSignal(f)
 	if err != nil {
@@ -473,11 +649,11 @@ func getConfigFileNameMap(f *Flags) (map[string]bool, error) {
 	}
 
 	filemap := make(map[string]bool)
-	for _, f := range files {
+	for _, file := range files {
 		// ConfigMaps mounted as volumes have special files with the prefix
 		// "..". We want to explicitly exclude these as well as any directories.
-		if !f.IsDir() && !strings.HasPrefix(f.Name(), "..") {
-			filemap[f.Name()] = true
+		if !file.IsDir() && !strings.HasPrefix(file.Name(), "..") && file.Name() != f.ConfigOverlaysFile {
+			filemap[file.Name()] = true
 		}
 	}
 
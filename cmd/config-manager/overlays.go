@@ -0,0 +1,291 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/fsnotify/fsnotify"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
+)
+
+// configOverlay is a partial config that is merged into the selected config
+// on the nodes whose labels match its node selector.
+type configOverlay struct {
+	// NodeSelector selects the nodes that the overlay applies to using the
+	// label selector syntax, e.g. 'gpu.model=A100'. If it is empty, the
+	// overlay applies to all nodes.
+	NodeSelector string `json:"nodeSelector,omitempty"`
+	// Config is the partial device plugin config.
+	Config json.RawMessage `json:"config"`
+
+	selector labels.Selector
+}
+
+// configOverlaysFile is the contents of the config overlays file.
+type configOverlaysFile struct {
+	Overlays []configOverlay `json:"overlays"`
+}
+
+// overlaySelection identifies the config selected for the node and the
+// indices of the overlays that apply to the node. It is passed through the
+// SyncableConfig encoded as JSON so that a change of either triggers an
+// update. The configs of the overlays are included so that the selection can
+// be rendered after the overlays file was reloaded, and the revision of the
+// config file source directory so that a change of the selected config also
+// triggers an update.
+type overlaySelection struct {
+	Config   string            `json:"config"`
+	Overlays []int             `json:"overlays,omitempty"`
+	Configs  []json.RawMessage `json:"configs,omitempty"`
+	Revision int               `json:"revision,omitempty"`
+}
+
+// overlayConfigs tracks the config overlays and the labels of the node. Each
+// time either changes, the selection for the node is set on the
+// SyncableConfig.
+type overlayConfigs struct {
+	sync.Mutex
+	flags      *Flags
+	overlays   []configOverlay
+	revision   int
+	nodeLabels map[string]string
+	synced     *SyncableConfig
+}
+
+func newOverlayConfigs(synced *SyncableConfig, overlays []configOverlay, f *Flags) *overlayConfigs {
+	return &overlayConfigs{
+		flags:    f,
+		overlays: overlays,
+		synced:   synced,
+	}
+}
+
+// setNodeLabels updates the labels of the node.
+func (o *overlayConfigs) setNodeLabels(nodeLabels map[string]string) {
+	o.Lock()
+	defer o.Unlock()
+	if nodeLabels == nil {
+		nodeLabels = make(map[string]string)
+	}
+	o.nodeLabels = nodeLabels
+	o.update()
+}
+
+// reload reads the overlays file again after the config file source directory
+// changed. If the overlays file is invalid, the error is returned and the
+// previous overlays are kept.
+func (o *overlayConfigs) reload() error {
+	overlays, err := loadConfigOverlays(o.flags)
+	if err != nil {
+		return err
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.overlays = overlays
+	o.revision++
+	o.update()
+	return nil
+}
+
+// update sets the selection for the node once its labels are known.
+func (o *overlayConfigs) update() {
+	if o.nodeLabels == nil {
+		return
+	}
+	selection := selectOverlays(o.overlays, o.nodeLabels, o.flags)
+	selection.Revision = o.revision
+	encoded, _ := json.Marshal(selection)
+	o.synced.Set(string(encoded))
+}
+
+// loadConfigOverlays reads the config overlays file from the config file
+// source directory. No overlays are returned if no overlays file is set.
+func loadConfigOverlays(f *Flags) ([]configOverlay, error) {
+	if f.ConfigOverlaysFile == "" {
+		return nil, nil
+	}
+	contents, err := os.ReadFile(filepath.Join(f.ConfigFileSrcdir, f.ConfigOverlaysFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading config overlays: %v", err)
+	}
+	return parseConfigOverlays(contents)
+}
+
+// parseConfigOverlays parses the contents of a config overlays file.
+func parseConfigOverlays(contents []byte) ([]configOverlay, error) {
+	var file configOverlaysFile
+	if err := yaml.Unmarshal(contents, &file); err != nil {
+		return nil, fmt.Errorf("error unmarshaling config overlays: %v", err)
+	}
+	for i, overlay := range file.Overlays {
+		if len(overlay.Config) == 0 || string(overlay.Config) == "null" {
+			return nil, fmt.Errorf("no config specified for overlay %d", i)
+		}
+		selector, err := labels.Parse(overlay.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nodeSelector for overlay %d: %v", i, err)
+		}
+		file.Overlays[i].selector = selector
+	}
+	return file.Overlays, nil
+}
+
+// selectOverlays returns the selection for a node with the specified labels.
+func selectOverlays(overlays []configOverlay, nodeLabels map[string]string, f *Flags) overlaySelection {
+	selection := overlaySelection{
+		Config: nodeLabels[f.NodeLabel],
+	}
+	for i, overlay := range overlays {
+		if overlay.selector.Matches(labels.Set(nodeLabels)) {
+			selection.Overlays = append(selection.Overlays, i)
+			selection.Configs = append(selection.Configs, overlay.Config)
+		}
+	}
+	return selection
+}
+
+// continuouslySyncOverlayChanges watches the labels of the node and updates
+// the selection each time the config label or the matching overlays change.
+func continuouslySyncOverlayChanges(clientset *kubernetes.Clientset, configs *overlayConfigs, f *Flags) chan struct{} {
+	return watchNode(clientset, f,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				configs.setNodeLabels(obj.(*v1.Node).Labels)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				configs.setNodeLabels(newObj.(*v1.Node).Labels)
+			},
+			DeleteFunc: func(obj interface{}) {
+				configs.setNodeLabels(nil)
+			},
+		},
+	)
+}
+
+// continuouslyReloadOverlays watches the config file source directory and
+// reloads the overlays file each time it changes. Mounted ConfigMaps are
+// updated by replacing the ..data symlink that their files point to.
+func continuouslyReloadOverlays(configs *overlayConfigs, f *Flags) (chan struct{}, error) {
+	watcher, err := watch.Files(f.ConfigFileSrcdir)
+	if err != nil {
+		return nil, fmt.Errorf("error watching %v: %v", f.ConfigFileSrcdir, err)
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-stop:
+				return
+			case event := <-watcher.Events:
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				if name := filepath.Base(event.Name); name != f.ConfigOverlaysFile && name != "..data" {
+					continue
+				}
+				klog.Infof("Config file source directory changed (%v); reloading config overlays", event)
+				if err := configs.reload(); err != nil {
+					klog.Errorf("Error reloading config overlays; keeping the previous overlays: %v", err)
+				}
+			case err := <-watcher.Errors:
+				klog.Errorf("Error watching %v: %v", f.ConfigFileSrcdir, err)
+			}
+		}
+	}()
+	return stop, nil
+}
+
+// updateConfigWithOverlays updates the config to the selected config merged
+// with the overlays that apply to the node. If no overlay applies, the
+// selected config is used as is.
+func updateConfigWithOverlays(encoded string, f *Flags) error {
+	var selection overlaySelection
+	if err := json.Unmarshal([]byte(encoded), &selection); err != nil {
+		return fmt.Errorf("error decoding config selection: %v", err)
+	}
+	if len(selection.Overlays) == 0 {
+		return updateConfig(selection.Config, f)
+	}
+
+	config, err := updateConfigName(selection.Config, f)
+	if err != nil {
+		return err
+	}
+	rendered, err := renderConfigWithOverlays(config, selection, f)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("Updating to config %q with overlays %v:\n%s", config, selection.Overlays, rendered)
+	updated, err := writeConfigFile(rendered, f)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		klog.Infof("Already configured. Skipping update...")
+		return nil
+	}
+	klog.Infof("Successfully updated config")
+
+	return signalUpdate(f)
+}
+
+// renderConfigWithOverlays merges the selected overlays, in order, into the
+// named config as a JSON merge patch and validates the result. The overlays
+// are merged into an empty config if no config is named.
+func renderConfigWithOverlays(config string, selection overlaySelection, f *Flags) (string, error) {
+	merged := []byte(`{}`)
+	if config != "" {
+		contents, err := os.ReadFile(filepath.Join(f.ConfigFileSrcdir, config))
+		if err != nil {
+			return "", fmt.Errorf("error reading config %v: %v", config, err)
+		}
+		merged, err = yaml.YAMLToJSON(contents)
+		if err != nil {
+			return "", fmt.Errorf("error converting config %v: %v", config, err)
+		}
+	}
+	for i, patch := range selection.Configs {
+		var err error
+		merged, err = jsonpatch.MergePatch(merged, patch)
+		if err != nil {
+			return "", fmt.Errorf("error merging overlay %d: %v", selection.Overlays[i], err)
+		}
+	}
+
+	if _, err := spec.ParseConfigFrom(bytes.NewReader(merged)); err != nil {
+		return "", fmt.Errorf("invalid config merged from %q and overlays %v: %v", config, selection.Overlays, err)
+	}
+	return string(merged), nil
+}
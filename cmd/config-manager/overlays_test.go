@@ -0,0 +1,170 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigOverlays(t *testing.T) {
+	dir := t.TempDir()
+	base := `
+version: v1
+flags:
+  migStrategy: none
+sharing:
+  timeSlicing:
+    resources:
+    - name: nvidia.com/gpu
+      replicas: 2
+`
+	overlays := `
+overlays:
+- nodeSelector: gpu.model=A100
+  config:
+    sharing:
+      timeSlicing:
+        resources:
+        - name: nvidia.com/gpu
+          replicas: 4
+- nodeSelector: gpu.model in (A100,H100),mig=true
+  config:
+    flags:
+      migStrategy: mixed
+- nodeSelector: pool=broken
+  config:
+    version: v2
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "default"), []byte(base), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "overlays"), []byte(overlays), 0644))
+	f := &Flags{
+		NodeLabel:          DefaultConfigLabel,
+		ConfigFileSrcdir:   dir,
+		ConfigOverlaysFile: "overlays",
+	}
+
+	parsed, err := loadConfigOverlays(f)
+	require.NoError(t, err)
+	require.Len(t, parsed, 3)
+
+	// The overlays file cannot be selected as a config.
+	files, err := getConfigFileNameMap(f)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"default": true}, files)
+
+	testCases := []struct {
+		description       string
+		nodeLabels        map[string]string
+		expectedSelection overlaySelection
+		expectedConfig    string
+		expectedError     string
+	}{
+		{
+			description:       "no overlay applies",
+			nodeLabels:        map[string]string{DefaultConfigLabel: "default", "gpu.model": "T4"},
+			expectedSelection: overlaySelection{Config: "default"},
+			expectedConfig:    `{"flags":{"migStrategy":"none"},"sharing":{"timeSlicing":{"resources":[{"name":"nvidia.com/gpu","replicas":2}]}},"version":"v1"}`,
+		},
+		{
+			description:       "overlays are merged in order",
+			nodeLabels:        map[string]string{DefaultConfigLabel: "default", "gpu.model": "A100", "mig": "true"},
+			expectedSelection: overlaySelection{Config: "default", Overlays: []int{0, 1}},
+			expectedConfig:    `{"flags":{"migStrategy":"mixed"},"sharing":{"timeSlicing":{"resources":[{"name":"nvidia.com/gpu","replicas":4}]}},"version":"v1"}`,
+		},
+		{
+			description:       "overlays are merged into the empty config",
+			nodeLabels:        map[string]string{"mig": "true", "gpu.model": "H100"},
+			expectedSelection: overlaySelection{Overlays: []int{1}},
+			expectedConfig:    `{"flags":{"migStrategy":"mixed"}}`,
+		},
+		{
+			description:       "invalid merged config",
+			nodeLabels:        map[string]string{DefaultConfigLabel: "default", "pool": "broken"},
+			expectedSelection: overlaySelection{Config: "default", Overlays: []int{2}},
+			expectedError:     `invalid config merged from "default" and overlays [2]: unknown version: v2`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			selection := selectOverlays(parsed, tc.nodeLabels, f)
+			require.Equal(t, tc.expectedSelection.Config, selection.Config)
+			require.Equal(t, tc.expectedSelection.Overlays, selection.Overlays)
+			require.Len(t, selection.Configs, len(selection.Overlays))
+
+			rendered, err := renderConfigWithOverlays(selection.Config, selection, f)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expectedConfig, rendered)
+		})
+	}
+}
+
+func TestReloadConfigOverlays(t *testing.T) {
+	dir := t.TempDir()
+	overlays := func(replicas int) []byte {
+		return []byte(fmt.Sprintf("overlays:\n- config:\n    sharing:\n      timeSlicing:\n        resources:\n        - name: nvidia.com/gpu\n          replicas: %d\n", replicas))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "overlays"), overlays(2), 0644))
+	f := &Flags{
+		NodeLabel:          DefaultConfigLabel,
+		ConfigFileSrcdir:   dir,
+		ConfigOverlaysFile: "overlays",
+	}
+
+	parsed, err := loadConfigOverlays(f)
+	require.NoError(t, err)
+	synced := NewSyncableConfig(f)
+	configs := newOverlayConfigs(synced, parsed, f)
+
+	render := func() string {
+		var selection overlaySelection
+		require.NoError(t, json.Unmarshal([]byte(synced.Get()), &selection))
+		rendered, err := renderConfigWithOverlays(selection.Config, selection, f)
+		require.NoError(t, err)
+		return rendered
+	}
+
+	configs.setNodeLabels(nil)
+	require.JSONEq(t, `{"sharing":{"timeSlicing":{"resources":[{"name":"nvidia.com/gpu","replicas":2}]}}}`, render())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "overlays"), overlays(4), 0644))
+	require.NoError(t, configs.reload())
+	require.JSONEq(t, `{"sharing":{"timeSlicing":{"resources":[{"name":"nvidia.com/gpu","replicas":4}]}}}`, render())
+
+	// An invalid overlays file keeps the previous overlays.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "overlays"), []byte("overlays:\n- {}\n"), 0644))
+	require.Error(t, configs.reload())
+	require.Len(t, configs.overlays, 1)
+}
+
+func TestParseConfigOverlays(t *testing.T) {
+	_, err := parseConfigOverlays([]byte("overlays:\n- nodeSelector: gpu.model=A100\n"))
+	require.EqualError(t, err, "no config specified for overlay 0")
+
+	_, err = parseConfigOverlays([]byte("overlays:\n- nodeSelector: '!!'\n  config: {}\n"))
+	require.ErrorContains(t, err, "invalid nodeSelector for overlay 0")
+}
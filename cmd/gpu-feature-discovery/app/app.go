@@ -0,0 +1,470 @@
+// Copyright (c) 2019, NVIDIA CORPORATION. All rights reserved.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	nvinfo "github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	corev1 "k8s.io/api/core/v1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/flags"
+	"github.com/NVIDIA/k8s-device-plugin/internal/info"
+	"github.com/NVIDIA/k8s-device-plugin/internal/lm"
+	"github.com/NVIDIA/k8s-device-plugin/internal/logger"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rollback"
+	"github.com/NVIDIA/k8s-device-plugin/internal/vgpu"
+	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
+	"github.com/NVIDIA/k8s-device-plugin/internal/writable"
+)
+
+// Config represents a collection of config options for GFD.
+type Config struct {
+	configFile string
+
+	kubeClientConfig flags.KubeClientConfig
+	nodeConfig       flags.NodeConfig
+	startupConfig    flags.StartupConfig
+
+	// nvmllib is the NVML library used to query the devices. It is shared
+	// with the other applications if they run in the same process.
+	nvmllib nvml.Interface
+	// signals receives the OS signals if they are dispatched by the process
+	// running the application. Otherwise the signals are watched directly.
+	signals <-chan os.Signal
+
+	// flags stores the CLI flags for later processing.
+	flags []cli.Flag
+}
+
+// Option configures the GPU Feature Discovery application.
+type Option func(*Config)
+
+// WithNvmlLib sets the NVML library used by the application.
+func WithNvmlLib(nvmllib nvml.Interface) Option {
+	return func(c *Config) {
+		c.nvmllib = nvmllib
+	}
+}
+
+// WithSignals sets the channel on which the application receives OS signals.
+func WithSignals(signals <-chan os.Signal) Option {
+	return func(c *Config) {
+		c.signals = signals
+	}
+}
+
+// NewApp creates the GPU Feature Discovery application.
+func NewApp(opts ...Option) *cli.App {
+	config := &Config{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.nvmllib == nil {
+		config.nvmllib = nvml.New()
+	}
+
+	c := cli.NewApp()
+	c.Name = "GPU Feature Discovery"
+	c.Usage = "generate labels for NVIDIA devices"
+	c.Version = info.GetVersionString()
+	c.Action = func(ctx *cli.Context) error {
+		if err := config.startupConfig.Wait(ctx.Context); err != nil {
+			return fmt.Errorf("startup delay interrupted: %w", err)
+		}
+		return start(ctx, config)
+	}
+
+	config.flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:    "mig-strategy",
+			Value:   spec.MigStrategyNone,
+			Usage:   "the desired strategy for exposing MIG devices on GPUs that support it:\n\t\t[none | single | mixed]",
+			EnvVars: []string{"GFD_MIG_STRATEGY", "MIG_STRATEGY"},
+		},
+		&cli.BoolFlag{
+			Name:    "fail-on-init-error",
+			Value:   true,
+			Usage:   "fail the plugin if an error is encountered during initialization, otherwise block indefinitely",
+			EnvVars: []string{"GFD_FAIL_ON_INIT_ERROR", "FAIL_ON_INIT_ERROR"},
+		},
+		&cli.BoolFlag{
+			Name:    "oneshot",
+			Value:   false,
+			Usage:   "Label once and exit",
+			EnvVars: []string{"GFD_ONESHOT"},
+		},
+		&cli.BoolFlag{
+			Name:    "no-timestamp",
+			Value:   false,
+			Usage:   "Do not add the timestamp to the labels",
+			EnvVars: []string{"GFD_NO_TIMESTAMP"},
+		},
+		&cli.DurationFlag{
+			Name:    "sleep-interval",
+			Value:   60 * time.Second,
+			Usage:   "Time to sleep between labeling",
+			EnvVars: []string{"GFD_SLEEP_INTERVAL"},
+		},
+		&cli.StringFlag{
+			Name:    "output-file",
+			Aliases: []string{"output", "o"},
+			Value:   "/etc/kubernetes/node-feature-discovery/features.d/gfd",
+			EnvVars: []string{"GFD_OUTPUT_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "machine-type-file",
+			Value:   "/sys/class/dmi/id/product_name",
+			Usage:   "a path to a file that contains the DMI (SMBIOS) information for the node",
+			EnvVars: []string{"GFD_MACHINE_TYPE_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "device-location-file",
+			Usage:   "a path to a YAML file that maps GPU UUIDs to their physical rack, chassis, and slot; the nodes are labeled with the rack and chassis of their GPUs",
+			EnvVars: []string{"GFD_DEVICE_LOCATION_FILE", "DEVICE_LOCATION_FILE"},
+		},
+		&cli.StringFlag{
+			Name:        "config-file",
+			Usage:       "the path to a config file as an alternative to command line options or environment variables",
+			Destination: &config.configFile,
+			EnvVars:     []string{"GFD_CONFIG_FILE", "CONFIG_FILE"},
+		},
+		&cli.BoolFlag{
+			Name:    "use-node-feature-api",
+			Usage:   "Use NFD NodeFeature API to publish labels",
+			EnvVars: []string{"GFD_USE_NODE_FEATURE_API", "USE_NODE_FEATURE_API"},
+		},
+		&cli.StringFlag{
+			Name:    "mode",
+			Value:   "auto",
+			Usage:   "Select GFD mode between 'auto','nvml','tegra' or 'vfio'",
+			EnvVars: []string{"MODE", "GFD_MODE"},
+		},
+		&cli.BoolFlag{
+			Name:    "cleanup-without-gpus",
+			Usage:   "remove all labels instead of generating them if no GPUs are detected on the node",
+			EnvVars: []string{"GFD_CLEANUP_WITHOUT_GPUS"},
+		},
+	}
+
+	config.flags = append(config.flags, config.kubeClientConfig.Flags()...)
+	config.flags = append(config.flags, config.nodeConfig.Flags()...)
+	config.flags = append(config.flags, config.startupConfig.Flags()...)
+
+	c.Flags = config.flags
+
+	return c
+}
+
+func validateFlags(config *spec.Config) error {
+	validModes := []string{"auto", "nvml", "tegra", "vfio"}
+	if !slices.Contains(validModes, *config.Flags.Mode) {
+		return fmt.Errorf("%s invalid mode option must be 'auto','nvml','tegra' or 'vfio'", *config.Flags.Mode)
+	}
+	return nil
+}
+
+// loadConfig loads the config from the spec file.
+func (cfg *Config) loadConfig(c *cli.Context) (*spec.Config, error) {
+	config, err := spec.NewConfig(c, cfg.flags)
+	if err != nil {
+		return nil, fmt.Errorf("unable to finalize config: %v", err)
+	}
+	err = validateFlags(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate flags: %v", err)
+	}
+	config.Flags.Plugin = nil
+
+	return config, nil
+}
+
+func start(c *cli.Context, cfg *Config) error {
+	defer func() {
+		klog.Info("Exiting")
+	}()
+
+	sigs := cfg.signals
+	if sigs == nil {
+		klog.Info("Starting OS watcher.")
+		sigs = watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	}
+
+	for {
+		// Load the configuration file
+		klog.Info("Loading configuration.")
+		config, err := cfg.loadConfig(c)
+		if err != nil {
+			return fmt.Errorf("unable to load config: %v", err)
+		}
+		spec.DisableResourceNamingInConfig(logger.ToKlog, config)
+
+		if (config.Flags.UseNodeFeatureAPI == nil || !*config.Flags.UseNodeFeatureAPI) && config.Flags.GFD.OutputFile != nil {
+			if err := writable.File("the labels output file", *config.Flags.GFD.OutputFile).Check(); err != nil {
+				return err
+			}
+		}
+
+		// Print the config to the output.
+		configJSON, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal config to JSON: %v", err)
+		}
+		klog.Infof("\nRunning with config:\n%v", string(configJSON))
+
+		nvmllib := cfg.nvmllib
+		devicelib := device.New(nvmllib)
+		infolib := nvinfo.New(
+			nvinfo.WithNvmlLib(nvmllib),
+			nvinfo.WithDeviceLib(devicelib),
+		)
+
+		manager := resource.NewManager(infolib, nvmllib, devicelib, config)
+		vgpul := vgpu.NewVGPULib(vgpu.NewNvidiaPCILib())
+
+		var clientSets flags.ClientSets
+		if config.Flags.UseNodeFeatureAPI != nil && *config.Flags.UseNodeFeatureAPI {
+			cs, err := cfg.kubeClientConfig.NewClientSets()
+			if err != nil {
+				return fmt.Errorf("failed to create clientsets: %w", err)
+			}
+			clientSets = cs
+		}
+
+		labelOutputer, err := lm.NewOutputer(
+			config,
+			cfg.nodeConfig,
+			clientSets,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create label outputer: %w", err)
+		}
+
+		klog.Info("Start running")
+		d := &gfd{
+			manager:       manager,
+			vgpu:          vgpul,
+			config:        config,
+			labelOutputer: labelOutputer,
+			events:        cfg.newNodeEventRecorder(),
+		}
+		restart, err := d.run(sigs)
+		if err != nil {
+			return err
+		}
+
+		if !restart {
+			return nil
+		}
+	}
+}
+
+type gfd struct {
+	manager resource.Manager
+	vgpu    vgpu.Interface
+	config  *spec.Config
+
+	labelOutputer lm.Outputer
+	// labelsRemoved indicates whether the labels were removed because no
+	// GPUs were detected.
+	labelsRemoved bool
+
+	// events records the events of the node, or is nil if the node name is
+	// not known.
+	events rollback.EventRecorder
+	// eccRebootRequired indicates whether a reboot was required for a change
+	// of the ECC mode of a GPU when the labels were last output.
+	eccRebootRequired bool
+}
+
+// newNodeEventRecorder creates a recorder for the events of the node. A nil
+// recorder is returned if the node name is not known or no kube client can be
+// created since events are informational only.
+func (cfg *Config) newNodeEventRecorder() rollback.EventRecorder {
+	if cfg.nodeConfig.Name == "" {
+		return nil
+	}
+	clientSets, err := cfg.kubeClientConfig.NewClientSets()
+	if err != nil {
+		klog.Warningf("Failed to create clientsets for node events: %v", err)
+		return nil
+	}
+	return rollback.NewNodeEventRecorder(clientSets.Core, cfg.nodeConfig.Name, "gpu-feature-discovery")
+}
+
+// reportECCRebootRequired emits a node event once a change of the ECC mode of
+// a GPU on the node requires a reboot to take effect.
+func (d *gfd) reportECCRebootRequired(labels lm.Labels) {
+	required := labels[lm.ECCRebootRequiredLabel] == "true"
+	if required && !d.eccRebootRequired && d.events != nil {
+		d.events.Event(corev1.EventTypeWarning, "GPUECCRebootRequired",
+			"The pending ECC mode of a GPU differs from its current mode; the node must be rebooted for the change to take effect")
+	}
+	d.eccRebootRequired = required
+}
+
+func (d *gfd) run(sigs <-chan os.Signal) (bool, error) {
+	defer func() {
+		if d.config.Flags.UseNodeFeatureAPI != nil && *d.config.Flags.UseNodeFeatureAPI {
+			return
+		}
+		if d.config.Flags.GFD.Oneshot != nil && *d.config.Flags.GFD.Oneshot {
+			return
+		}
+		if d.config.Flags.GFD.OutputFile != nil && *d.config.Flags.GFD.OutputFile == "" {
+			return
+		}
+		if d.labelsRemoved {
+			return
+		}
+		err := removeOutputFile(*d.config.Flags.GFD.OutputFile)
+		if err != nil {
+			klog.Warningf("Error removing output file: %v", err)
+		}
+	}()
+
+	timestampLabeler := lm.NewTimestampLabeler(d.config)
+	// Labels that require sampling the devices over time are sampled in the
+	// background, except in oneshot mode.
+	samplingLabeler := lm.NewSamplingLabeler(d.manager, d.config, time.Duration(*d.config.Flags.GFD.SleepInterval))
+	if *d.config.Flags.GFD.Oneshot {
+		samplingLabeler.Update()
+	} else {
+		stopSampling := make(chan struct{})
+		defer close(stopSampling)
+		go samplingLabeler.Run(stopSampling)
+	}
+rerun:
+	if d.config.Flags.GFD.GetCleanupWithoutGPUs() && !d.hasGPUs() {
+		if err := d.removeLabels(); err != nil {
+			return false, err
+		}
+	} else if err := d.outputLabels(timestampLabeler, samplingLabeler); err != nil {
+		return false, err
+	}
+
+	if *d.config.Flags.GFD.Oneshot {
+		return false, nil
+	}
+
+	klog.Info("Sleeping for ", *d.config.Flags.GFD.SleepInterval)
+	rerunTimeout := time.After(time.Duration(*d.config.Flags.GFD.SleepInterval))
+
+	for {
+		select {
+		case <-rerunTimeout:
+			goto rerun
+
+		// Watch for any signals from the OS. On SIGHUP trigger a reload of the config.
+		// On all other signals, exit the loop and exit the program.
+		case s := <-sigs:
+			switch s {
+			case syscall.SIGHUP:
+				klog.Info("Received SIGHUP, restarting.")
+				return true, nil
+			default:
+				klog.Infof("Received signal %v, shutting down.", s)
+				return false, nil
+			}
+		}
+	}
+}
+
+// outputLabels generates the labels for the node and outputs them.
+func (d *gfd) outputLabels(timestampLabeler lm.Labeler, samplingLabeler lm.Labeler) error {
+	loopLabelers, err := lm.NewLabelers(d.manager, d.vgpu, d.config)
+	if err != nil {
+		return err
+	}
+
+	labelers := lm.Merge(
+		timestampLabeler,
+		loopLabelers,
+		samplingLabeler,
+	)
+
+	labels, err := labelers.Labels()
+	if err != nil {
+		return fmt.Errorf("error generating labels: %v", err)
+	}
+
+	if len(labels) <= 1 {
+		klog.Warning("No labels generated from any source")
+	}
+
+	klog.Info("Creating Labels")
+	if err := d.labelOutputer.Output(labels); err != nil {
+		return err
+	}
+	d.reportECCRebootRequired(labels)
+
+	d.labelsRemoved = false
+	return nil
+}
+
+// hasGPUs checks whether any GPUs are detected on the node. A failure to
+// initialize the resource manager is treated as the absence of GPUs.
+func (d *gfd) hasGPUs() bool {
+	if err := d.manager.Init(); err != nil {
+		klog.Warningf("Failed to initialize resource manager: %v", err)
+		return false
+	}
+	defer func() {
+		_ = d.manager.Shutdown()
+	}()
+	devices, err := d.manager.GetDevices()
+	if err != nil {
+		klog.Warningf("Failed to get devices: %v", err)
+		return false
+	}
+	return len(devices) > 0
+}
+
+// removeLabels removes all previously output labels. The labels are only
+// removed once until they are output again.
+func (d *gfd) removeLabels() error {
+	if d.labelsRemoved {
+		return nil
+	}
+	klog.Info("No GPUs detected; removing labels")
+	if err := d.labelOutputer.Remove(); err != nil {
+		return fmt.Errorf("error removing labels: %w", err)
+	}
+	d.labelsRemoved = true
+	return nil
+}
+
+func removeOutputFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve absolute path of output file: %v", err)
+	}
+
+	absDir := filepath.Dir(absPath)
+	tmpDir := filepath.Join(absDir, "gfd-tmp")
+
+	err = os.RemoveAll(tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed to remove temporary output directory: %v", err)
+	}
+
+	err = os.Remove(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to remove output file: %v", err)
+	}
+
+	return nil
+}
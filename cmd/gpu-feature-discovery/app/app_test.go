@@ -1,6 +1,6 @@
 // Copyright (c) 2019, NVIDIA CORPORATION. All rights reserved.
 
-package main
+package app
 
 import (
 	"fmt"
@@ -500,3 +500,67 @@ func removeMachineFile(t *testing.T) {
 	err := os.Remove(testMachineTypeFile)
 	require.NoError(t, err, "Removing machine type mock file")
 }
+
+func TestRunCleanupWithoutGPUs(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "gfd")
+	require.NoError(t, os.WriteFile(outputFile, []byte("nvidia.com/gpu.count=1\n"), 0644))
+
+	conf := &spec.Config{
+		Flags: spec.Flags{
+			CommandLineFlags: spec.CommandLineFlags{
+				MigStrategy:     ptr("none"),
+				FailOnInitError: ptr(true),
+				GFD: &spec.GFDCommandLineFlags{
+					Oneshot:            ptr(true),
+					OutputFile:         ptr(outputFile),
+					SleepInterval:      ptr(spec.Duration(time.Second)),
+					NoTimestamp:        ptr(false),
+					MachineTypeFile:    ptr(testMachineTypeFile),
+					CleanupWithoutGPUs: ptr(true),
+				},
+			},
+		},
+	}
+
+	labelOutputer, err := lm.NewOutputer(conf, flags.NodeConfig{}, flags.ClientSets{})
+	require.NoError(t, err)
+
+	d := gfd{
+		manager:       rt.NewManagerMockWithDevices(),
+		vgpu:          NewTestVGPUMock(),
+		config:        conf,
+		labelOutputer: labelOutputer,
+	}
+	restart, err := d.run(nil)
+	require.NoError(t, err)
+	require.False(t, restart)
+	require.True(t, d.labelsRemoved)
+
+	_, err = os.Stat(outputFile)
+	require.True(t, os.IsNotExist(err), "output file was not removed")
+}
+
+type eventRecorder struct {
+	reasons []string
+}
+
+func (r *eventRecorder) Event(eventType string, reason string, message string) {
+	r.reasons = append(r.reasons, reason)
+}
+
+func TestReportECCRebootRequired(t *testing.T) {
+	events := &eventRecorder{}
+	d := gfd{events: events}
+
+	d.reportECCRebootRequired(lm.Labels{lm.ECCRebootRequiredLabel: "false"})
+	require.Empty(t, events.reasons)
+
+	// The event is only emitted once until the reboot is no longer required.
+	d.reportECCRebootRequired(lm.Labels{lm.ECCRebootRequiredLabel: "true"})
+	d.reportECCRebootRequired(lm.Labels{lm.ECCRebootRequiredLabel: "true"})
+	require.Equal(t, []string{"GPUECCRebootRequired"}, events.reasons)
+
+	d.reportECCRebootRequired(lm.Labels{})
+	d.reportECCRebootRequired(lm.Labels{lm.ECCRebootRequiredLabel: "true"})
+	require.Equal(t, []string{"GPUECCRebootRequired", "GPUECCRebootRequired"}, events.reasons)
+}
@@ -1,6 +1,6 @@
 // Copyright (c) 2019, NVIDIA CORPORATION. All rights reserved.
 
-package main
+package app
 
 import (
 	"io"
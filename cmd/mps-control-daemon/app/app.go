@@ -0,0 +1,664 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	nvinfo "github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/fabric"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mount"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/selftest"
+	"github.com/NVIDIA/k8s-device-plugin/internal/flags"
+	"github.com/NVIDIA/k8s-device-plugin/internal/info"
+	"github.com/NVIDIA/k8s-device-plugin/internal/logger"
+	"github.com/NVIDIA/k8s-device-plugin/internal/metrics"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+	"github.com/NVIDIA/k8s-device-plugin/internal/tuning"
+	"github.com/NVIDIA/k8s-device-plugin/internal/validate"
+	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
+	"github.com/NVIDIA/k8s-device-plugin/internal/writable"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// Config represents a collection of config options for the device plugin.
+type Config struct {
+	configFile string
+
+	// waitForFabric indicates whether daemons are only started once the
+	// fabric partitions of their GPUs are active.
+	waitForFabric bool
+	// nodeGroup and nodeGroupSize define the group of nodes that share an
+	// NVSwitch fabric and coordinate the startup of their daemons.
+	nodeGroup     string
+	nodeGroupSize int
+	// coordinationTimeout is the time to wait for the fabric partitions and
+	// the node group before retrying.
+	coordinationTimeout time.Duration
+	// metricsAddress is the address on which Prometheus metrics are served.
+	metricsAddress string
+	// adminSocket is the unix socket on which the admin API is served.
+	adminSocket string
+	// procRoot is the path at which the proc filesystem of the host is
+	// mounted; it is used to determine the containers of MPS clients.
+	procRoot string
+	// probeAddress is the address on which the liveness and readiness probes
+	// are served.
+	probeAddress string
+	// selfTest indicates whether a CUDA probe is run as a client of each
+	// daemon before the daemon is considered started.
+	selfTest        bool
+	selfTestTimeout time.Duration
+	// maxRestarts is the number of consecutive restarts of an unresponsive
+	// daemon after which the daemon is marked as failed.
+	maxRestarts int
+	// root is the MPS root of the most recently loaded config.
+	root mps.Root
+
+	kubeClientConfig flags.KubeClientConfig
+	nodeConfig       flags.NodeConfig
+
+	// nvmllib is the NVML library used to manage the devices. It is shared
+	// with the other applications if they run in the same process.
+	nvmllib nvml.Interface
+	// signals receives the OS signals if they are dispatched by the process
+	// running the application. Otherwise the signals are watched directly.
+	signals <-chan os.Signal
+
+	// flags stores the CLI flags for later processing.
+	flags []cli.Flag
+}
+
+// Option configures the MPS control daemon application.
+type Option func(*Config)
+
+// WithNvmlLib sets the NVML library used by the application.
+func WithNvmlLib(nvmllib nvml.Interface) Option {
+	return func(c *Config) {
+		c.nvmllib = nvmllib
+	}
+}
+
+// WithSignals sets the channel on which the application receives OS signals.
+func WithSignals(signals <-chan os.Signal) Option {
+	return func(c *Config) {
+		c.signals = signals
+	}
+}
+
+// NewApp creates the MPS control daemon application.
+func NewApp(opts ...Option) *cli.App {
+	config := &Config{
+		root: mps.ContainerRoot,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.nvmllib == nil {
+		config.nvmllib = nvml.New()
+	}
+
+	c := cli.NewApp()
+	c.Name = "NVIDIA MPS Control Daemon"
+	c.Version = info.GetVersionString()
+	c.Action = func(ctx *cli.Context) error {
+		klog.InfoS("Starting "+ctx.App.Name, "version", ctx.App.Version)
+		return start(ctx, config)
+	}
+	c.Commands = []*cli.Command{
+		mount.NewCommand(),
+		selftest.NewCommand(),
+		validate.NewCommand(config.loadConfigFrom, validate.ResourceNames, validate.MPSReplicas),
+	}
+
+	config.flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:        "config-file",
+			Usage:       "the path to a config file as an alternative to command line options or environment variables",
+			Destination: &config.configFile,
+			EnvVars:     []string{"CONFIG_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "mig-strategy",
+			Value:   spec.MigStrategyNone,
+			Usage:   "the desired strategy for exposing MIG devices on GPUs that support it:\n\t\t[none | single | mixed]",
+			EnvVars: []string{"MIG_STRATEGY"},
+		},
+		&cli.BoolFlag{
+			Name:        "wait-for-fabric",
+			Usage:       "only start the MPS daemons once the NVSwitch fabric partitions of their GPUs are active",
+			Destination: &config.waitForFabric,
+			EnvVars:     []string{"WAIT_FOR_FABRIC"},
+		},
+		&cli.StringFlag{
+			Name:        "node-group",
+			Usage:       "the name of a group of nodes sharing an NVSwitch fabric that coordinate the startup of their MPS daemons through a ConfigMap",
+			Destination: &config.nodeGroup,
+			EnvVars:     []string{"NODE_GROUP"},
+		},
+		&cli.IntFlag{
+			Name:        "node-group-size",
+			Usage:       "the number of nodes in the node group",
+			Destination: &config.nodeGroupSize,
+			EnvVars:     []string{"NODE_GROUP_SIZE"},
+		},
+		&cli.DurationFlag{
+			Name:        "coordination-timeout",
+			Value:       10 * time.Minute,
+			Usage:       "the time to wait for the fabric partitions and the node group before retrying",
+			Destination: &config.coordinationTimeout,
+			EnvVars:     []string{"COORDINATION_TIMEOUT"},
+		},
+		&cli.StringFlag{
+			Name:        "metrics-address",
+			Usage:       "the address (e.g. :9401) on which Prometheus metrics are served on /metrics; an empty address disables the metrics",
+			Destination: &config.metricsAddress,
+			EnvVars:     []string{"METRICS_ADDRESS"},
+		},
+		&cli.BoolFlag{
+			Name:        "self-test",
+			Usage:       "run a CUDA probe as a client of each MPS daemon to verify that it is functional before it is considered started",
+			Destination: &config.selfTest,
+			EnvVars:     []string{"SELF_TEST"},
+		},
+		&cli.DurationFlag{
+			Name:        "self-test-timeout",
+			Value:       time.Minute,
+			Usage:       "the time after which the CUDA probe of the self-test fails",
+			Destination: &config.selfTestTimeout,
+			EnvVars:     []string{"SELF_TEST_TIMEOUT"},
+		},
+		&cli.IntFlag{
+			Name:        "daemon-max-restarts",
+			Value:       mps.DefaultMaxRestarts,
+			Usage:       "the number of consecutive restarts of an unresponsive MPS daemon after which its devices are marked unhealthy in the device plugin",
+			Destination: &config.maxRestarts,
+			EnvVars:     []string{"DAEMON_MAX_RESTARTS"},
+		},
+		&cli.StringFlag{
+			Name:        "admin-socket",
+			Usage:       "the path to a unix socket on which the admin API (health, stats, and client eviction) is served; an empty path disables the API",
+			Destination: &config.adminSocket,
+			EnvVars:     []string{"ADMIN_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:        "proc-root",
+			Value:       mps.DefaultProcRoot,
+			Usage:       "the path at which the proc filesystem of the host is mounted; it is used to determine the pod and container of MPS clients in the admin API",
+			Destination: &config.procRoot,
+			EnvVars:     []string{"PROC_ROOT"},
+		},
+		&cli.StringFlag{
+			Name:    "mps-container-root",
+			Value:   spec.DefaultMpsContainerRoot,
+			Usage:   "the path at which the MPS root on the host is mounted in the container; the pipe and log directories of the MPS daemons are created under it",
+			EnvVars: []string{"MPS_CONTAINER_ROOT"},
+		},
+		&cli.StringFlag{
+			Name:        "probe-address",
+			Usage:       "the address (e.g. :8081) on which the /healthz and /readyz probes are served; an empty address disables the probes",
+			Destination: &config.probeAddress,
+			EnvVars:     []string{"PROBE_ADDRESS"},
+		},
+	}
+	config.flags = append(config.flags, config.kubeClientConfig.Flags()...)
+	config.flags = append(config.flags, config.nodeConfig.Flags()...)
+	c.Flags = config.flags
+
+	return c
+}
+
+// TODO: This needs to do similar validation to the plugin.
+func validateFlags(config *spec.Config) error {
+	return nil
+}
+
+// loadConfig loads the config from the spec file.
+func (cfg *Config) loadConfig(c *cli.Context) (*spec.Config, error) {
+	return cfg.loadConfigFrom(c, c.String("config-file"))
+}
+
+// loadConfigFrom loads the config from the specified spec file.
+func (cfg *Config) loadConfigFrom(c *cli.Context, configFile string) (*spec.Config, error) {
+	config, err := spec.NewConfigFromFile(c, cfg.flags, configFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to finalize config: %w", err)
+	}
+	err = validateFlags(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate flags: %w", err)
+	}
+	config.Flags.GFD = nil
+
+	return config, nil
+}
+
+// newCoordinator creates a coordinator for the node group.
+// A nil coordinator is returned if no node group is configured.
+func (cfg *Config) newCoordinator() (*fabric.Coordinator, error) {
+	if cfg.nodeGroup == "" {
+		return nil, nil
+	}
+	if cfg.nodeConfig.Name == "" {
+		return nil, fmt.Errorf("--node-name must be specified when --node-group is set")
+	}
+	if cfg.nodeGroupSize < 1 {
+		return nil, fmt.Errorf("--node-group-size must be at least 1 when --node-group is set")
+	}
+	clientSets, err := cfg.kubeClientConfig.NewClientSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientsets: %w", err)
+	}
+	return fabric.NewCoordinator(clientSets.Core, cfg.nodeConfig.Namespace, cfg.nodeGroup, cfg.nodeConfig.Name, cfg.nodeGroupSize), nil
+}
+
+func start(c *cli.Context, cfg *Config) error {
+	if err := writable.File("the admin socket", cfg.adminSocket).Check(); err != nil {
+		return fmt.Errorf("required paths are not writable: %w", err)
+	}
+
+	coordinator, err := cfg.newCoordinator()
+	if err != nil {
+		return fmt.Errorf("unable to create node group coordinator: %w", err)
+	}
+
+	metricsServer := metrics.NewServer(cfg.metricsAddress)
+	settings := tuning.Tune(tuning.DefaultCgroupRoot)
+	collector := mps.NewCollector("nvidia_mps_control_daemon")
+	if err := metricsServer.Register(append(settings.Collectors("nvidia_mps_control_daemon"), collector)...); err != nil {
+		return fmt.Errorf("failed to register metrics: %w", err)
+	}
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+	go func() {
+		if err := metricsServer.ListenAndServe(ctx); err != nil {
+			klog.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+	adminServer := mps.NewAdminServer(cfg.adminSocket, cfg.nvmllib, cfg.procRoot)
+	go func() {
+		if err := adminServer.ListenAndServe(ctx); err != nil {
+			klog.Errorf("Admin server failed: %v", err)
+		}
+	}()
+	probeServer := mps.NewProbeServer(cfg.probeAddress)
+	go func() {
+		if err := probeServer.ListenAndServe(ctx); err != nil {
+			klog.Errorf("Probe server failed: %v", err)
+		}
+	}()
+
+	sigs := cfg.signals
+	if sigs == nil {
+		klog.Info("Starting OS watcher.")
+		sigs = watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	}
+
+	configEvents, err := watchConfigFile(cfg.configFile)
+	if err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	var started bool
+	var restartTimeout <-chan time.Time
+	var retryTimeout <-chan time.Time
+	var daemons []*mps.Daemon
+	var failed []*mps.Daemon
+	var appliedConfig string
+	stopSupervision := func() {}
+restart:
+	// If we are restarting, stop daemons from previous run.
+	if started {
+		stopSupervision()
+		err := stopDaemons(cfg.root, coordinator, daemons...)
+		if err != nil {
+			return fmt.Errorf("error stopping plugins from previous run: %v", err)
+		}
+	}
+
+	klog.Info("Starting Daemons.")
+	daemons, failed, appliedConfig, restartDaemons, err := startDaemons(c, cfg, coordinator)
+	if err != nil {
+		return fmt.Errorf("error starting plugins: %v", err)
+	}
+	adminServer.Update(daemons)
+	collector.Update(daemons)
+	probeServer.Update(daemons, failed, !restartDaemons)
+	started = true
+
+	restartTimeout = nil
+	retryTimeout = nil
+	stopSupervision = func() {}
+	if restartDaemons {
+		klog.Infof("Failed to coordinate the startup of the MPS deamons. Retrying in 30s...")
+		restartTimeout = time.After(30 * time.Second)
+	} else {
+		stopSupervision = superviseDaemons(daemons)
+		if len(failed) > 0 {
+			klog.Infof("Failed to start %d MPS daemon(s). Retrying in 30s...", len(failed))
+			retryTimeout = time.After(30 * time.Second)
+		}
+	}
+
+	// Start an infinite loop, waiting for several indicators to either log
+	// some messages, trigger a restart of the plugins, or exit the program.
+	for {
+		select {
+		// If the restart timeout has expired, then restart the plugins
+		case <-restartTimeout:
+			goto restart
+
+		// If the retry timeout has expired, then start the daemons that
+		// failed to start again. The running daemons are left untouched.
+		case <-retryTimeout:
+			stopSupervision()
+			var retried []*mps.Daemon
+			retried, failed, err = mps.StartDaemons(failed)
+			daemons = append(daemons, retried...)
+			adminServer.Update(daemons)
+			collector.Update(daemons)
+			probeServer.Update(daemons, failed, true)
+			stopSupervision = superviseDaemons(daemons)
+			retryTimeout = nil
+			if err != nil {
+				klog.Errorf("Failed to start %d MPS daemon(s): %v. Retrying in 30s...", len(failed), err)
+				retryTimeout = time.After(30 * time.Second)
+			}
+
+		// Reconcile the daemons with the config if the config file changes.
+		// The daemons are restarted instead if their startup is coordinated
+		// with the fabric or the node group, or if the reconciliation fails.
+		case <-configEvents:
+			if restartTimeout != nil || cfg.waitForFabric || coordinator != nil {
+				klog.Info("Config file changed, restarting.")
+				goto restart
+			}
+			klog.Info("Config file changed, reconciling MPS daemons.")
+			stopSupervision()
+			previousConfig := appliedConfig
+			daemons, appliedConfig, err = reconcileDaemons(c, cfg, daemons, appliedConfig)
+			// The daemons that failed to start are started again by a
+			// reconciliation with a changed config if they are still desired.
+			if appliedConfig != previousConfig {
+				failed = nil
+				retryTimeout = nil
+			}
+			adminServer.Update(daemons)
+			collector.Update(daemons)
+			probeServer.Update(daemons, failed, err == nil)
+			if err != nil {
+				klog.Errorf("Failed to reconcile MPS daemons: %v. Restarting in 30s...", err)
+				restartTimeout = time.After(30 * time.Second)
+				retryTimeout = nil
+				stopSupervision = func() {}
+				continue
+			}
+			stopSupervision = superviseDaemons(daemons)
+
+		// Watch for any signals from the OS. On SIGHUP, restart this loop,
+		// restarting all of the plugins in the process. On all other
+		// signals, exit the loop and exit the program.
+		case s := <-sigs:
+			switch s {
+			case syscall.SIGHUP:
+				klog.Info("Received SIGHUP, restarting.")
+				goto restart
+			default:
+				klog.Infof("Received signal \"%v\", shutting down.", s)
+				goto exit
+			}
+		}
+	}
+exit:
+	stopSupervision()
+	if err := stopDaemons(cfg.root, coordinator, daemons...); err != nil {
+		return fmt.Errorf("error stopping daemons: %v", err)
+	}
+	return nil
+}
+
+// newManager loads the config and creates an MPS manager for it.
+// The config is also returned as JSON to allow changes to it to be detected.
+func (cfg *Config) newManager(c *cli.Context, nvmllib nvml.Interface) (mps.Manager, string, error) {
+	// Load the configuration file
+	klog.Info("Loading configuration.")
+	config, err := cfg.loadConfig(c)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to load config: %v", err)
+	}
+	spec.DisableResourceNamingInConfig(logger.ToKlog, config)
+
+	devicelib := device.New(nvmllib)
+	infolib := nvinfo.New(
+		nvinfo.WithNvmlLib(nvmllib),
+		nvinfo.WithDeviceLib(devicelib),
+	)
+
+	// Update the configuration file with default resources.
+	klog.Info("Updating config with default resource matching patterns.")
+	err = rm.AddDefaultResourcesToConfig(infolib, nvmllib, devicelib, config)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to add default resources to config: %v", err)
+	}
+
+	// Print the config to the output.
+	configJSON, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal config to JSON: %v", err)
+	}
+	klog.Infof("\nRunning with config:\n%v", string(configJSON))
+
+	cfg.root = mps.Root(config.Flags.GetMpsContainerRoot())
+	if err := writable.Dir("MPS pipe and log directories", string(cfg.root)).Check(); err != nil {
+		return nil, "", fmt.Errorf("required paths are not writable: %w", err)
+	}
+
+	mpsOpts := []mps.Option{
+		mps.WithConfig(config),
+		mps.WithRoot(cfg.root),
+	}
+	if cfg.selfTest {
+		mpsOpts = append(mpsOpts, mps.WithSelfTest(cfg.selfTestTimeout))
+	}
+	mpsOpts = append(mpsOpts, mps.WithSupervision(cfg.maxRestarts))
+	manager, err := mps.New(infolib, nvmllib, devicelib, mpsOpts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create MPS manager: %w", err)
+	}
+	return manager, string(configJSON), nil
+}
+
+// reconcileDaemons reloads the config and reconciles the running daemons with
+// it if the config has changed. The daemons that are running afterwards and
+// the config that was applied are returned.
+func reconcileDaemons(c *cli.Context, cfg *Config, daemons []*mps.Daemon, appliedConfig string) ([]*mps.Daemon, string, error) {
+	manager, configJSON, err := cfg.newManager(c, cfg.nvmllib)
+	if err != nil {
+		return daemons, appliedConfig, err
+	}
+	if configJSON == appliedConfig {
+		klog.Info("Config is unchanged; keeping MPS daemons.")
+		return daemons, appliedConfig, nil
+	}
+	klog.Info("Reconciling MPS daemons with changed config.")
+	daemons, err = manager.Reconcile(daemons)
+	if err != nil {
+		return daemons, appliedConfig, fmt.Errorf("error reconciling daemons: %w", err)
+	}
+	return daemons, configJSON, nil
+}
+
+// startDaemons creates and starts the MPS daemons for the current config.
+// The daemons that were started and the daemons that failed to start are
+// returned along with the config as JSON. A failure of one daemon does not
+// affect the others; only a failure to coordinate the startup requires all
+// daemons to be started again.
+func startDaemons(c *cli.Context, cfg *Config, coordinator *fabric.Coordinator) ([]*mps.Daemon, []*mps.Daemon, string, bool, error) {
+	nvmllib := cfg.nvmllib
+	manager, configJSON, err := cfg.newManager(c, nvmllib)
+	if err != nil {
+		return nil, nil, "", false, err
+	}
+
+	// Get the set of daemons.
+	// Note that a daemon is only created for resources with at least one device.
+	klog.Info("Retrieving MPS daemons.")
+	mpsDaemons, err := manager.Daemons()
+	if err != nil {
+		return nil, nil, "", false, fmt.Errorf("error getting daemons: %v", err)
+	}
+
+	if len(mpsDaemons) == 0 {
+		klog.Info("No devices are configured for MPS sharing; Waiting indefinitely.")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, cfg.coordinationTimeout)
+	defer cancel()
+
+	// Ensure that the fabric partitions are active on all nodes in the node
+	// group before any daemons are started.
+	if cfg.waitForFabric {
+		klog.Info("Waiting for fabric partitions.")
+		if err := fabric.WaitForPartitions(ctx, nvmllib, getUUIDs(mpsDaemons), fabric.DefaultInterval); err != nil {
+			klog.Errorf("Failed to wait for fabric partitions: %v", err)
+			return nil, nil, "", true, nil
+		}
+	}
+	if err := announceAndWait(ctx, coordinator, fabric.StatePartitionActive); err != nil {
+		klog.Errorf("Failed to coordinate with node group: %v", err)
+		return nil, nil, "", true, nil
+	}
+
+	// Start all MPS daemons. The daemons that fail to start are marked as
+	// failed for their resource and are retried without affecting the others.
+	started, failed, err := mps.StartDaemons(mpsDaemons)
+	if err != nil {
+		klog.Errorf("Failed to start MPS daemons: %v", err)
+	}
+
+	// Only signal readiness to the clients once the daemons on all nodes in
+	// the node group are started.
+	if err := announceAndWait(ctx, coordinator, fabric.StateReady); err != nil {
+		klog.Errorf("Failed to coordinate with node group: %v", err)
+		return started, failed, "", true, nil
+	}
+	readyFile, err := os.Create(cfg.root.Path(".ready"))
+	if err != nil {
+		return started, failed, "", true, fmt.Errorf("failed to create .ready file")
+	}
+	defer readyFile.Close()
+
+	return started, failed, configJSON, false, nil
+}
+
+// watchConfigFile returns a channel that receives an event whenever the
+// specified config file changes. The directory containing the file is watched
+// since a mounted ConfigMap is updated by replacing a symlink. A nil channel
+// is returned if no config file is specified.
+func watchConfigFile(configFile string) (<-chan fsnotify.Event, error) {
+	if configFile == "" {
+		return nil, nil
+	}
+	watcher, err := watch.Files(filepath.Dir(configFile))
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for err := range watcher.Errors {
+			klog.Errorf("Config file watcher error: %v", err)
+		}
+	}()
+	return watcher.Events, nil
+}
+
+// announceAndWait records the state of this node for the node group and waits for the other nodes to reach it.
+func announceAndWait(ctx context.Context, coordinator *fabric.Coordinator, state fabric.State) error {
+	if err := coordinator.Announce(ctx, state); err != nil {
+		return err
+	}
+	return coordinator.Wait(ctx, state)
+}
+
+// getUUIDs returns the UUIDs of the GPUs managed by the specified daemons.
+func getUUIDs(mpsDaemons []*mps.Daemon) []string {
+	seen := make(map[string]bool)
+	var uuids []string
+	for _, d := range mpsDaemons {
+		for _, device := range d.Devices() {
+			uuid := device.GetUUID()
+			if seen[uuid] {
+				continue
+			}
+			seen[uuid] = true
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids
+}
+
+// superviseDaemons supervises each of the specified daemons until the returned
+// function is called. The function waits for the supervision to end so that
+// the daemons are not restarted while they are stopped or reconciled.
+func superviseDaemons(daemons []*mps.Daemon) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, d := range daemons {
+		wg.Add(1)
+		go func(d *mps.Daemon) {
+			defer wg.Done()
+			d.Supervise(stop)
+		}(d)
+	}
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+func stopDaemons(root mps.Root, coordinator *fabric.Coordinator, mpsDaemons ...*mps.Daemon) error {
+	if err := os.Remove(root.Path(".ready")); err != nil {
+		klog.Warningf("Failed to remove .ready file: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := coordinator.Withdraw(ctx); err != nil {
+		klog.Warningf("Failed to withdraw from node group: %v", err)
+	}
+	klog.Info("Stopping MPS daemons.")
+	var errs error
+	for _, p := range mpsDaemons {
+		errs = errors.Join(errs, p.Stop())
+	}
+	return errs
+}
@@ -0,0 +1,175 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package fabric
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	coreclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// State is the startup state of a node in a node group.
+type State string
+
+// These constants define the startup states of a node.
+// A node in a later state is also considered to be in all earlier states.
+const (
+	StatePartitionActive State = "partition-active"
+	StateReady           State = "ready"
+)
+
+var stateOrder = map[State]int{
+	StatePartitionActive: 1,
+	StateReady:           2,
+}
+
+// configMapPrefix is the prefix of the name of the ConfigMap used to coordinate a node group.
+const configMapPrefix = "nvidia-mps-node-group-"
+
+// Coordinator coordinates the startup of the MPS daemons across a group of
+// nodes that share an NVSwitch fabric. Each node records its state in a
+// ConfigMap for the group and waits until the expected number of nodes have
+// reached a state.
+type Coordinator struct {
+	client    coreclientset.Interface
+	namespace string
+	name      string
+	node      string
+	size      int
+	interval  time.Duration
+}
+
+// NewCoordinator creates a coordinator for the specified node in a node group of the specified size.
+func NewCoordinator(client coreclientset.Interface, namespace string, group string, node string, size int) *Coordinator {
+	return &Coordinator{
+		client:    client,
+		namespace: namespace,
+		name:      configMapPrefix + group,
+		node:      node,
+		size:      size,
+		interval:  DefaultInterval,
+	}
+}
+
+// Announce records the state of the node for the group.
+func (c *Coordinator) Announce(ctx context.Context, state State) error {
+	if c == nil {
+		return nil
+	}
+	err := c.patch(ctx, string(state))
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.name,
+			Namespace: c.namespace,
+		},
+		Data: map[string]string{
+			c.node: string(state),
+		},
+	}
+	_, err = c.client.CoreV1().ConfigMaps(c.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// Another node created the ConfigMap concurrently.
+		return c.patch(ctx, string(state))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create ConfigMap %v/%v: %w", c.namespace, c.name, err)
+	}
+	return nil
+}
+
+// Withdraw removes the state of the node from the group.
+func (c *Coordinator) Withdraw(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	err := c.patch(ctx, nil)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Wait waits until the expected number of nodes in the group have reached the specified state.
+func (c *Coordinator) Wait(ctx context.Context, state State) error {
+	if c == nil {
+		return nil
+	}
+	for {
+		count, err := c.count(ctx, state)
+		if err != nil {
+			klog.Warningf("Failed to get state of node group: %v", err)
+		}
+		if count >= c.size {
+			return nil
+		}
+
+		klog.Infof("Waiting for node group %v: %d of %d nodes are %v", c.name, count, c.size, state)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting for %d nodes to be %v: %w", c.size, state, ctx.Err())
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+// count returns the number of nodes in the group that have reached at least the specified state.
+func (c *Coordinator) count(ctx context.Context, state State) (int, error) {
+	configMap, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(ctx, c.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, s := range configMap.Data {
+		if stateOrder[State(s)] >= stateOrder[state] {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// patch sets the value for the node in the ConfigMap. A nil value removes the entry.
+func (c *Coordinator) patch(ctx context.Context, value any) error {
+	patch, err := json.Marshal(map[string]any{
+		"data": map[string]any{
+			c.node: value,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to construct patch: %w", err)
+	}
+	_, err = c.client.CoreV1().ConfigMaps(c.namespace).Patch(ctx, c.name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch ConfigMap %v/%v: %w", c.namespace, c.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package fabric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCoordinator(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	nodeA := NewCoordinator(client, "gpu-operator", "rack-1", "node-a", 2)
+	nodeB := NewCoordinator(client, "gpu-operator", "rack-1", "node-b", 2)
+
+	require.NoError(t, nodeA.Announce(ctx, StatePartitionActive))
+	count, err := nodeA.count(ctx, StatePartitionActive)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	nodeA.interval = time.Millisecond
+	require.Error(t, nodeA.Wait(timeout, StatePartitionActive))
+
+	require.NoError(t, nodeB.Announce(ctx, StateReady))
+	require.NoError(t, nodeA.Wait(ctx, StatePartitionActive))
+
+	count, err = nodeA.count(ctx, StateReady)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	require.NoError(t, nodeB.Withdraw(ctx))
+	configMap, err := client.CoreV1().ConfigMaps("gpu-operator").Get(ctx, "nvidia-mps-node-group-rack-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"node-a": string(StatePartitionActive)}, configMap.Data)
+
+	var disabled *Coordinator
+	require.NoError(t, disabled.Announce(ctx, StateReady))
+	require.NoError(t, disabled.Wait(ctx, StateReady))
+	require.NoError(t, disabled.Withdraw(ctx))
+}
+
+func TestWithdrawWithoutConfigMap(t *testing.T) {
+	c := NewCoordinator(fake.NewSimpleClientset(), "default", "rack-1", "node-a", 1)
+	require.NoError(t, c.Withdraw(context.Background()))
+}
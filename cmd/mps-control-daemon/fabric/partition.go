@@ -0,0 +1,97 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package fabric coordinates the startup of MPS daemons on systems where GPUs
+// are connected through a shared NVSwitch fabric. Clients are only started
+// once the fabric partitions of the local GPUs are active and, optionally,
+// once all nodes in a node group have reached the same state.
+package fabric
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// DefaultInterval is the interval at which the fabric and node group states are polled.
+const DefaultInterval = 5 * time.Second
+
+// WaitForPartitions waits until the fabric partitions of the GPUs with the specified UUIDs are active.
+// GPUs that are not connected to a fabric are considered active.
+// An error is returned if the context is done or if fabric registration of a GPU failed.
+func WaitForPartitions(ctx context.Context, nvmllib nvml.Interface, uuids []string, interval time.Duration) error {
+	if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to initialize NVML: %v", ret)
+	}
+	defer func() {
+		_ = nvmllib.Shutdown()
+	}()
+
+	pending := uuids
+	for {
+		var stillPending []string
+		for _, uuid := range pending {
+			active, err := isPartitionActive(nvmllib, uuid)
+			if err != nil {
+				return err
+			}
+			if !active {
+				stillPending = append(stillPending, uuid)
+			}
+		}
+		pending = stillPending
+		if len(pending) == 0 {
+			return nil
+		}
+
+		klog.Infof("Waiting for the fabric partitions of %v to become active", pending)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting for the fabric partitions of %v: %w", pending, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// isPartitionActive checks whether the fabric partition of the specified GPU is active.
+func isPartitionActive(nvmllib nvml.Interface, uuid string) (bool, error) {
+	device, ret := nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("failed to get device handle for %v: %v", uuid, ret)
+	}
+	fabricInfo, ret := device.GetGpuFabricInfo()
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return true, nil
+	}
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("failed to get fabric info for %v: %v", uuid, ret)
+	}
+
+	switch fabricInfo.State {
+	case nvml.GPU_FABRIC_STATE_NOT_SUPPORTED:
+		return true, nil
+	case nvml.GPU_FABRIC_STATE_COMPLETED:
+		if status := nvml.Return(fabricInfo.Status); status != nvml.SUCCESS {
+			return false, fmt.Errorf("fabric registration of %v failed: %v", uuid, status)
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
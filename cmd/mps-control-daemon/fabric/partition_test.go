@@ -0,0 +1,121 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package fabric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+type testNvml struct {
+	nvml.Interface
+	devices map[string]*testDevice
+}
+
+func (l *testNvml) Init() nvml.Return     { return nvml.SUCCESS }
+func (l *testNvml) Shutdown() nvml.Return { return nvml.SUCCESS }
+
+func (l *testNvml) DeviceGetHandleByUUID(uuid string) (nvml.Device, nvml.Return) {
+	d, exists := l.devices[uuid]
+	if !exists {
+		return nil, nvml.ERROR_NOT_FOUND
+	}
+	return d, nvml.SUCCESS
+}
+
+type testDevice struct {
+	nvml.Device
+	// states are the fabric states returned by consecutive calls to GetGpuFabricInfo.
+	states []uint8
+	status nvml.Return
+	ret    nvml.Return
+}
+
+func (d *testDevice) GetGpuFabricInfo() (nvml.GpuFabricInfo, nvml.Return) {
+	if d.ret != nvml.SUCCESS {
+		return nvml.GpuFabricInfo{}, d.ret
+	}
+	state := d.states[0]
+	if len(d.states) > 1 {
+		d.states = d.states[1:]
+	}
+	return nvml.GpuFabricInfo{State: state, Status: uint32(d.status)}, nvml.SUCCESS
+}
+
+func TestWaitForPartitions(t *testing.T) {
+	testCases := []struct {
+		description   string
+		devices       map[string]*testDevice
+		expectedError bool
+	}{
+		{
+			description: "fabric not supported",
+			devices: map[string]*testDevice{
+				"GPU-0": {ret: nvml.ERROR_NOT_SUPPORTED},
+				"GPU-1": {states: []uint8{nvml.GPU_FABRIC_STATE_NOT_SUPPORTED}},
+			},
+		},
+		{
+			description: "partition becomes active",
+			devices: map[string]*testDevice{
+				"GPU-0": {states: []uint8{nvml.GPU_FABRIC_STATE_NOT_STARTED, nvml.GPU_FABRIC_STATE_IN_PROGRESS, nvml.GPU_FABRIC_STATE_COMPLETED}},
+				"GPU-1": {states: []uint8{nvml.GPU_FABRIC_STATE_COMPLETED}},
+			},
+		},
+		{
+			description: "partition never becomes active",
+			devices: map[string]*testDevice{
+				"GPU-0": {states: []uint8{nvml.GPU_FABRIC_STATE_IN_PROGRESS}},
+				"GPU-1": {states: []uint8{nvml.GPU_FABRIC_STATE_COMPLETED}},
+			},
+			expectedError: true,
+		},
+		{
+			description: "fabric registration failed",
+			devices: map[string]*testDevice{
+				"GPU-0": {states: []uint8{nvml.GPU_FABRIC_STATE_COMPLETED}, status: nvml.ERROR_UNKNOWN},
+				"GPU-1": {states: []uint8{nvml.GPU_FABRIC_STATE_COMPLETED}},
+			},
+			expectedError: true,
+		},
+		{
+			description: "unknown device",
+			devices: map[string]*testDevice{
+				"GPU-0": {states: []uint8{nvml.GPU_FABRIC_STATE_COMPLETED}},
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			err := WaitForPartitions(ctx, &testNvml{devices: tc.devices}, []string{"GPU-0", "GPU-1"}, time.Millisecond)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
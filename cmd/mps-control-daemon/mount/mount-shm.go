@@ -20,9 +20,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	"github.com/urfave/cli/v2"
 	"k8s.io/mount-utils"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 )
 
 // NewCommand constructs a mount command.
@@ -30,35 +33,69 @@ func NewCommand() *cli.Command {
 	// Create the 'generate-cdi' command
 	return &cli.Command{
 		Name:   "mount-shm",
-		Usage:  "Set up the /dev/shm mount required by the MPS daemon",
+		Usage:  "Set up the /dev/shm mount required by the MPS daemon and the tmpfs mounts of the configured pipe directories",
 		Action: mountShm,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "mps-container-root",
+				Value:   spec.DefaultMpsContainerRoot,
+				Usage:   "the path at which the MPS root on the host is mounted in the container",
+				EnvVars: []string{"MPS_CONTAINER_ROOT"},
+			},
+		},
 	}
 }
 
-// mountShm creates a tmpfs mount at /mps/shm to be used by the mps control daemon.
+// mountShm creates a tmpfs mount at the shm dir of the MPS root to be used by
+// the mps control daemon. A tmpfs is also mounted at the pipe directory of
+// each MPS resource in the config that sets pipeDirectorySizeMB.
 func mountShm(c *cli.Context) error {
+	config, err := spec.NewConfig(c, c.App.Flags)
+	if err != nil {
+		return fmt.Errorf("unable to load config: %v", err)
+	}
+
 	mountExecutable, err := exec.LookPath("mount")
 	if err != nil {
 		return fmt.Errorf("error finding 'mount' executable: %w", err)
 	}
 	mounter := mount.New(mountExecutable)
 
-	// TODO: /mps should be configurable.
-	shmDir := "/mps/shm"
-	err = mount.CleanupMountPoint(shmDir, mounter, true)
-	if err != nil {
-		return fmt.Errorf("error unmounting %v: %w", shmDir, err)
+	root := c.String("mps-container-root")
+	//  TODO: What should the size of the shm be
+	if err := mountTmpfs(mounter, "shm", filepath.Join(root, "shm"), 65536); err != nil {
+		return err
+	}
+
+	if config.Sharing.MPS == nil {
+		return nil
+	}
+	for _, r := range config.Sharing.MPS.Resources {
+		if r.PipeDirectorySizeMB == nil {
+			continue
+		}
+		if err := mountTmpfs(mounter, "mps-pipes", filepath.Join(root, r.PipeDirectory), *r.PipeDirectorySizeMB*1024); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if err := os.MkdirAll(shmDir, 0755); err != nil {
-		return fmt.Errorf("error creating directory %v: %w", shmDir, err)
+// mountTmpfs replaces any existing mount at the specified directory with a
+// tmpfs of the specified size in KB.
+func mountTmpfs(mounter mount.Interface, source string, dir string, sizeKB uint64) error {
+	err := mount.CleanupMountPoint(dir, mounter, true)
+	if err != nil {
+		return fmt.Errorf("error unmounting %v: %w", dir, err)
 	}
 
-	//  TODO: What should the size of the shm be
-	mountOptions := []string{"rw", "nosuid", "nodev", "noexec", "relatime", "size=65536k"}
-	if err := mounter.Mount("shm", shmDir, "tmpfs", mountOptions); err != nil {
-		return fmt.Errorf("error mounting %v as tmpfs: %w", shmDir, err)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory %v: %w", dir, err)
 	}
 
+	mountOptions := []string{"rw", "nosuid", "nodev", "noexec", "relatime", fmt.Sprintf("size=%dk", sizeKB)}
+	if err := mounter.Mount(source, dir, "tmpfs", mountOptions); err != nil {
+		return fmt.Errorf("error mounting %v as tmpfs: %w", dir, err)
+	}
 	return nil
 }
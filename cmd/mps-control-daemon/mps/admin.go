@@ -0,0 +1,285 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/pkg/mpsclient"
+)
+
+// AdminServer serves the admin API of the MPS control daemons on a unix
+// socket:
+//
+//	GET  /v1/health     get the health of all daemons
+//	GET  /v1/stats      get the state of all daemons and their servers
+//	GET  /v1/clients    get the clients running on each GPU of the daemons
+//	POST /v1/evictions  terminate a client; the body is an mpsclient.Eviction
+//
+// The types of the API are defined in the mpsclient package.
+type AdminServer struct {
+	socket string
+
+	sync.Mutex
+	daemons []*Daemon
+
+	stats      func(*Daemon) mpsclient.DaemonStats
+	usedMemory func(uuid string) (map[int]uint64, error)
+	procRoot   string
+}
+
+// NewAdminServer creates an admin server that listens on the specified socket.
+// The GPU memory used by the MPS clients is queried through NVML, and their
+// containers are determined from the proc filesystem of the host mounted at
+// procRoot. A nil server is returned if the socket is empty.
+func NewAdminServer(socket string, nvmllib nvml.Interface, procRoot string) *AdminServer {
+	if socket == "" {
+		return nil
+	}
+	return &AdminServer{
+		socket: socket,
+		stats:  (*Daemon).Stats,
+		usedMemory: func(uuid string) (map[int]uint64, error) {
+			return usedMemory(nvmllib, uuid)
+		},
+		procRoot: procRoot,
+	}
+}
+
+// Update sets the daemons that are exposed by the server.
+// This is called every time the daemons are (re)started.
+func (s *AdminServer) Update(daemons []*Daemon) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.daemons = daemons
+}
+
+// Handler returns the HTTP handler for the admin API.
+func (s *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, r *http.Request) {
+		health := mpsclient.Health{
+			Healthy: true,
+			Daemons: []mpsclient.DaemonHealth{},
+		}
+		for _, d := range s.getDaemons() {
+			dh := mpsclient.DaemonHealth{
+				Resource:  string(d.rm.Resource()),
+				MigDevice: d.migDevice,
+				Healthy:   true,
+			}
+			if err := d.AssertHealthy(); err != nil {
+				dh.Healthy = false
+				dh.Error = err.Error()
+				health.Healthy = false
+			}
+			health.Daemons = append(health.Daemons, dh)
+		}
+		status := http.StatusOK
+		if !health.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, health)
+	})
+	mux.HandleFunc("GET /v1/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := []mpsclient.DaemonStats{}
+		for _, d := range s.getDaemons() {
+			stats = append(stats, s.stats(d))
+		}
+		writeJSON(w, http.StatusOK, stats)
+	})
+	mux.HandleFunc("GET /v1/clients", func(w http.ResponseWriter, r *http.Request) {
+		clients := []mpsclient.DeviceClients{}
+		for _, d := range s.getDaemons() {
+			clients = append(clients, s.clients(d)...)
+		}
+		writeJSON(w, http.StatusOK, clients)
+	})
+	mux.HandleFunc("POST /v1/evictions", func(w http.ResponseWriter, r *http.Request) {
+		var eviction mpsclient.Eviction
+		if err := json.NewDecoder(r.Body).Decode(&eviction); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+			return
+		}
+		daemons := s.getDaemonsFor(eviction.Resource)
+		if len(daemons) == 0 {
+			writeError(w, http.StatusNotFound, fmt.Errorf("%w: resource %q", ErrNotFound, eviction.Resource))
+			return
+		}
+		// A resource with per-MIG-device daemons has multiple daemons. The
+		// client is terminated by the daemon that started its server.
+		var err error
+		for _, d := range daemons {
+			err = d.TerminateClient(eviction.ServerPID, eviction.ClientPID)
+			if !errors.Is(err, ErrNotFound) {
+				break
+			}
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// ListenAndServe serves the admin API until the context is cancelled.
+func (s *AdminServer) ListenAndServe(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	if err := os.Remove(s.socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket %v: %w", s.socket, err)
+	}
+	listener, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", s.socket, err)
+	}
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	klog.Infof("Serving admin API on %v", s.socket)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *AdminServer) getDaemons() []*Daemon {
+	s.Lock()
+	defer s.Unlock()
+	return s.daemons
+}
+
+// clients returns the clients of the MPS servers of a daemon for each GPU
+// managed by the daemon. A client is listed for a GPU if NVML reports a
+// process with its PID on the GPU.
+func (s *AdminServer) clients(d *Daemon) []mpsclient.DeviceClients {
+	stats := s.stats(d)
+	var clients []mpsclient.DeviceClients
+	for _, uuid := range stats.Devices {
+		dc := mpsclient.DeviceClients{
+			Resource:  stats.Resource,
+			MigDevice: stats.MigDevice,
+			Device:    uuid,
+			Clients:   []mpsclient.ClientProcess{},
+		}
+		if stats.Error != "" {
+			dc.Error = stats.Error
+			clients = append(clients, dc)
+			continue
+		}
+		memory, err := s.usedMemory(uuid)
+		if err != nil {
+			dc.Error = err.Error()
+			clients = append(clients, dc)
+			continue
+		}
+		for _, server := range stats.Servers {
+			for _, pid := range server.Clients {
+				used, ok := memory[pid]
+				if !ok {
+					continue
+				}
+				client := mpsclient.ClientProcess{
+					PID:             pid,
+					ServerPID:       server.PID,
+					UsedMemoryBytes: used,
+				}
+				c, err := containerOf(s.procRoot, pid)
+				if err != nil {
+					klog.V(4).InfoS("Failed to determine the container of MPS client", "pid", pid, "err", err)
+				}
+				client.PodUID = c.podUID
+				client.ContainerID = c.containerID
+				dc.Clients = append(dc.Clients, client)
+			}
+		}
+		clients = append(clients, dc)
+	}
+	return clients
+}
+
+func (s *AdminServer) getDaemonsFor(resource string) []*Daemon {
+	var daemons []*Daemon
+	for _, d := range s.getDaemons() {
+		if string(d.rm.Resource()) == resource {
+			daemons = append(daemons, d)
+		}
+	}
+	return daemons
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Warningf("Failed to write admin API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// usedMemory returns the GPU memory used by each MPS client process on the
+// specified device, keyed by PID.
+func usedMemory(nvmllib nvml.Interface, uuid string) (map[int]uint64, error) {
+	if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML: %v", ret)
+	}
+	defer func() {
+		_ = nvmllib.Shutdown()
+	}()
+	device, ret := nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get device %v: %v", uuid, ret)
+	}
+	processes, ret := device.GetMPSComputeRunningProcesses()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get MPS processes of device %v: %v", uuid, ret)
+	}
+	memory := make(map[int]uint64)
+	for _, p := range processes {
+		memory[int(p.Pid)] += p.UsedGpuMemory
+	}
+	return memory, nil
+}
@@ -0,0 +1,106 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/pkg/mpsclient"
+)
+
+func TestAdminServerClients(t *testing.T) {
+	require.Nil(t, NewAdminServer("", nil, DefaultProcRoot))
+
+	gpu := NewDaemon(testResourceManager{resource: "nvidia.com/gpu"}, ContainerRoot)
+	shared := NewDaemon(testResourceManager{resource: "nvidia.com/gpu.shared"}, ContainerRoot)
+
+	procRoot := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, "101"), 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(procRoot, "101", "cgroup"),
+		[]byte("0::/kubepods/burstable/pod0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b/"+testContainerID+"\n"),
+		0644,
+	))
+
+	s := NewAdminServer(filepath.Join(t.TempDir(), "admin.sock"), nil, DefaultProcRoot)
+	s.procRoot = procRoot
+	s.stats = func(d *Daemon) mpsclient.DaemonStats {
+		if d == shared {
+			return mpsclient.DaemonStats{
+				Resource: "nvidia.com/gpu.shared",
+				Devices:  []string{"GPU-2"},
+				Error:    "error getting server list",
+			}
+		}
+		return mpsclient.DaemonStats{
+			Resource: "nvidia.com/gpu",
+			Devices:  []string{"GPU-0", "GPU-1"},
+			Servers:  []mpsclient.Server{{PID: 100, Clients: []int{101, 102}}},
+		}
+	}
+	s.usedMemory = func(uuid string) (map[int]uint64, error) {
+		if uuid == "GPU-1" {
+			return nil, errors.New("NVML not available")
+		}
+		return map[int]uint64{101: 1024, 102: 2048, 300: 4096}, nil
+	}
+	s.Update([]*Daemon{gpu, shared})
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/clients", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var clients []mpsclient.DeviceClients
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&clients))
+	expected := []mpsclient.DeviceClients{
+		{
+			Resource: "nvidia.com/gpu",
+			Device:   "GPU-0",
+			Clients: []mpsclient.ClientProcess{
+				{
+					PID:             101,
+					ServerPID:       100,
+					UsedMemoryBytes: 1024,
+					PodUID:          "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b",
+					ContainerID:     testContainerID,
+				},
+				{PID: 102, ServerPID: 100, UsedMemoryBytes: 2048},
+			},
+		},
+		{
+			Resource: "nvidia.com/gpu",
+			Device:   "GPU-1",
+			Clients:  []mpsclient.ClientProcess{},
+			Error:    "NVML not available",
+		},
+		{
+			Resource: "nvidia.com/gpu.shared",
+			Device:   "GPU-2",
+			Clients:  []mpsclient.ClientProcess{},
+			Error:    "error getting server list",
+		},
+	}
+	require.Equal(t, expected, clients)
+}
@@ -0,0 +1,230 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// clientAffinity assigns the clients of an MPS daemon that manages multiple
+// GPUs to the underlying physical GPUs. The GPUs are ordered by their index so
+// that the assignment of replicas to GPUs is deterministic.
+type clientAffinity struct {
+	sync.Mutex
+	policy spec.ClientAffinityPolicy
+	// gpus is the ordered list of GPUs managed by the daemon.
+	gpus []*rm.Device
+	// replicas maps each GPU ID to the replicas of that GPU.
+	replicas map[string][]string
+	// next is the position in gpus at which the next round-robin assignment
+	// starts.
+	next int
+	// assignments records the position of the GPU that each allocated replica
+	// was assigned to. Replicas are removed once they are released.
+	assignments map[string]int
+}
+
+// newClientAffinity creates a client affinity for the specified devices. If no
+// policy is set or the devices span fewer than two GPUs, nil is returned.
+func newClientAffinity(policy spec.ClientAffinityPolicy, devices rm.Devices) *clientAffinity {
+	if policy == "" {
+		return nil
+	}
+	a := &clientAffinity{
+		policy:      policy,
+		replicas:    make(map[string][]string),
+		assignments: make(map[string]int),
+	}
+	for _, d := range devices {
+		id := d.GetUUID()
+		if _, exists := a.replicas[id]; !exists {
+			a.gpus = append(a.gpus, d)
+		}
+		a.replicas[id] = append(a.replicas[id], d.ID)
+	}
+	if len(a.gpus) < 2 {
+		return nil
+	}
+	sort.Slice(a.gpus, func(i, j int) bool {
+		return indexLess(a.gpus[i].Index, a.gpus[j].Index)
+	})
+	return a
+}
+
+// indexLess compares two device indices numerically where possible.
+func indexLess(i, j string) bool {
+	ii, erri := strconv.Atoi(i)
+	ij, errj := strconv.Atoi(j)
+	if erri != nil || errj != nil {
+		return i < j
+	}
+	return ii < ij
+}
+
+// preferredAllocation selects size replicas from the available replicas
+// according to the client affinity policy. The required replicas are always
+// included in the allocation.
+func (a *clientAffinity) preferredAllocation(available, required []string, size int) ([]string, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	isRequired := make(map[string]bool)
+	for _, id := range required {
+		isRequired[id] = true
+	}
+	a.release(available, isRequired)
+	candidates := make(map[string][]string)
+	var numCandidates int
+	for _, id := range available {
+		if isRequired[id] {
+			continue
+		}
+		gpu := rm.AnnotatedID(id).GetID()
+		if _, exists := a.replicas[gpu]; !exists {
+			continue
+		}
+		candidates[gpu] = append(candidates[gpu], id)
+		numCandidates++
+	}
+	needed := size - len(required)
+	if numCandidates < needed {
+		return nil, fmt.Errorf("not enough available devices to satisfy allocation")
+	}
+	for gpu := range candidates {
+		sort.Strings(candidates[gpu])
+	}
+
+	devices := append([]string{}, required...)
+	next := a.next
+	load := a.load()
+	for i := 0; i < needed; i++ {
+		pos := a.selectGPU(candidates, next, load)
+		gpu := a.gpus[pos].GetUUID()
+		devices = append(devices, candidates[gpu][0])
+		candidates[gpu] = candidates[gpu][1:]
+		next = (pos + 1) % len(a.gpus)
+		load[pos]++
+	}
+	return devices, nil
+}
+
+// release removes the assignments of the replicas that are available again
+// since the kubelet only offers replicas that are not allocated. The required
+// replicas are kept since they are part of the allocation.
+func (a *clientAffinity) release(available []string, isRequired map[string]bool) {
+	for _, id := range available {
+		if isRequired[id] {
+			continue
+		}
+		if _, exists := a.assignments[id]; exists {
+			klog.V(4).InfoS("Released MPS client", "replica", id)
+			delete(a.assignments, id)
+		}
+	}
+}
+
+// selectGPU returns the position of the GPU that the next client is assigned
+// to. Only GPUs that have candidate replicas are considered. For the
+// round-robin policy, this is the first such GPU at or after next. For the
+// least-loaded policy, this is the GPU with the fewest replicas assigned to
+// clients, with ties broken by GPU order. Replicas that are unavailable for
+// other reasons, e.g. since they are unhealthy or drained, are not counted.
+func (a *clientAffinity) selectGPU(candidates map[string][]string, next int, load []int) int {
+	selected := -1
+	for i := range a.gpus {
+		pos := i
+		if a.policy == spec.ClientAffinityRoundRobin {
+			pos = (next + i) % len(a.gpus)
+		}
+		gpu := a.gpus[pos].GetUUID()
+		if len(candidates[gpu]) == 0 {
+			continue
+		}
+		if a.policy == spec.ClientAffinityRoundRobin {
+			return pos
+		}
+		if selected == -1 || load[pos] < load[selected] {
+			selected = pos
+		}
+	}
+	return selected
+}
+
+// load returns the number of replicas assigned to clients for each GPU
+// position.
+func (a *clientAffinity) load() []int {
+	load := make([]int, len(a.gpus))
+	for _, pos := range a.assignments {
+		load[pos]++
+	}
+	return load
+}
+
+// assign records the assignment of the specified replicas to their GPUs and
+// returns the environment variables that expose the assignment to the client.
+func (a *clientAffinity) assign(ids []string) envvars {
+	a.Lock()
+	defer a.Unlock()
+
+	var uuids, indices []string
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		pos, exists := a.assignments[id]
+		if !exists {
+			pos = a.position(rm.AnnotatedID(id).GetID())
+			if pos == -1 {
+				continue
+			}
+			a.assignments[id] = pos
+			a.next = (pos + 1) % len(a.gpus)
+			klog.InfoS("Assigned MPS client", "replica", id, "gpu", a.gpus[pos].GetUUID(), "policy", a.policy)
+		}
+		if seen[pos] {
+			continue
+		}
+		seen[pos] = true
+		uuids = append(uuids, a.gpus[pos].GetUUID())
+		indices = append(indices, a.gpus[pos].Index)
+	}
+	if len(uuids) == 0 {
+		return nil
+	}
+	return envvars{
+		"NVIDIA_MPS_CLIENT_GPU":       strings.Join(uuids, ","),
+		"NVIDIA_MPS_CLIENT_GPU_INDEX": strings.Join(indices, ","),
+	}
+}
+
+// position returns the position of the specified GPU or -1 if the GPU is not
+// managed by the daemon.
+func (a *clientAffinity) position(gpu string) int {
+	for pos, d := range a.gpus {
+		if d.GetUUID() == gpu {
+			return pos
+		}
+	}
+	return -1
+}
@@ -0,0 +1,135 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func newReplicatedDevices(gpus map[string]string, replicas int) rm.Devices {
+	devices := make(rm.Devices)
+	for index, uuid := range gpus {
+		for i := 0; i < replicas; i++ {
+			id := string(rm.NewAnnotatedID(uuid, i))
+			devices[id] = &rm.Device{
+				Device:   pluginapi.Device{ID: id},
+				Index:    index,
+				Replicas: replicas,
+			}
+		}
+	}
+	return devices
+}
+
+func TestClientAffinity(t *testing.T) {
+	devices := newReplicatedDevices(map[string]string{"0": "GPU-0", "1": "GPU-1", "10": "GPU-10"}, 2)
+
+	testCases := []struct {
+		description string
+		policy      spec.ClientAffinityPolicy
+		devices     rm.Devices
+		assigned    []string
+		available   []string
+		expected    [][]string
+		envs        []envvars
+	}{
+		{
+			description: "no policy disables affinity",
+			devices:     devices,
+		},
+		{
+			description: "single GPU disables affinity",
+			policy:      spec.ClientAffinityRoundRobin,
+			devices:     newReplicatedDevices(map[string]string{"0": "GPU-0"}, 2),
+		},
+		{
+			description: "round-robin cycles through GPUs in index order",
+			policy:      spec.ClientAffinityRoundRobin,
+			devices:     devices,
+			available:   []string{"GPU-0::0", "GPU-0::1", "GPU-1::0", "GPU-1::1", "GPU-10::0", "GPU-10::1"},
+			expected:    [][]string{{"GPU-0::0"}, {"GPU-1::0"}, {"GPU-10::0"}, {"GPU-0::1"}},
+			envs: []envvars{
+				{"NVIDIA_MPS_CLIENT_GPU": "GPU-0", "NVIDIA_MPS_CLIENT_GPU_INDEX": "0"},
+				{"NVIDIA_MPS_CLIENT_GPU": "GPU-1", "NVIDIA_MPS_CLIENT_GPU_INDEX": "1"},
+				{"NVIDIA_MPS_CLIENT_GPU": "GPU-10", "NVIDIA_MPS_CLIENT_GPU_INDEX": "10"},
+				{"NVIDIA_MPS_CLIENT_GPU": "GPU-0", "NVIDIA_MPS_CLIENT_GPU_INDEX": "0"},
+			},
+		},
+		{
+			description: "round-robin skips GPUs without available replicas",
+			policy:      spec.ClientAffinityRoundRobin,
+			devices:     devices,
+			available:   []string{"GPU-0::0", "GPU-10::0"},
+			expected:    [][]string{{"GPU-0::0"}, {"GPU-10::0"}},
+		},
+		{
+			description: "least-loaded selects the GPU with the fewest replicas in use",
+			policy:      spec.ClientAffinityLeastLoaded,
+			devices:     devices,
+			assigned:    []string{"GPU-0::0", "GPU-10::0"},
+			available:   []string{"GPU-0::1", "GPU-1::0", "GPU-1::1", "GPU-10::1"},
+			expected:    [][]string{{"GPU-1::0"}, {"GPU-0::1"}},
+		},
+		{
+			description: "least-loaded does not count unhealthy or drained replicas as in use",
+			policy:      spec.ClientAffinityLeastLoaded,
+			devices:     devices,
+			assigned:    []string{"GPU-1::0"},
+			available:   []string{"GPU-0::1", "GPU-1::1", "GPU-10::1"},
+			expected:    [][]string{{"GPU-0::1"}, {"GPU-10::1"}, {"GPU-1::1"}},
+		},
+		{
+			description: "least-loaded does not count released replicas as in use",
+			policy:      spec.ClientAffinityLeastLoaded,
+			devices:     devices,
+			assigned:    []string{"GPU-0::0", "GPU-1::0"},
+			available:   []string{"GPU-0::0", "GPU-0::1", "GPU-1::1", "GPU-10::0"},
+			expected:    [][]string{{"GPU-0::0"}, {"GPU-10::0"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			a := newClientAffinity(tc.policy, tc.devices)
+			if tc.expected == nil {
+				require.Nil(t, a)
+				return
+			}
+			require.NotNil(t, a)
+			a.assign(tc.assigned)
+
+			available := tc.available
+			for i, expected := range tc.expected {
+				allocated, err := a.preferredAllocation(available, nil, 1)
+				require.NoError(t, err)
+				require.Equal(t, expected, allocated)
+
+				envs := a.assign(allocated)
+				if tc.envs != nil {
+					require.Equal(t, tc.envs[i], envs)
+				}
+				available = tc.devices.Subset(available).Difference(tc.devices.Subset(allocated)).GetIDs()
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultProcRoot is the path at which the proc filesystem is mounted. It
+// only lists the processes of the host if the container runs in the host PID
+// namespace.
+const DefaultProcRoot = "/proc"
+
+var (
+	// podUIDPattern matches the pod component of a kubepods cgroup path. The
+	// systemd cgroup driver replaces the dashes of the UID with underscores.
+	podUIDPattern = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+	// containerIDPattern matches the container component of a kubepods
+	// cgroup path, such as <id> or cri-containerd-<id>.scope.
+	containerIDPattern = regexp.MustCompile(`([0-9a-f]{64})(\.scope)?$`)
+)
+
+// container identifies the container of a process.
+type container struct {
+	podUID      string
+	containerID string
+}
+
+// containerOf determines the container of a process from its cgroup. An empty
+// container is returned if the process does not belong to a pod.
+func containerOf(procRoot string, pid int) (container, error) {
+	path := filepath.Join(procRoot, strconv.Itoa(pid), "cgroup")
+	file, err := os.Open(path)
+	if err != nil {
+		return container{}, fmt.Errorf("failed to open %v: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Each line is of the form hierarchy-ID:controllers:path.
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if c := parseCgroupPath(parts[2]); c.podUID != "" {
+			return c, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return container{}, fmt.Errorf("failed to read %v: %w", path, err)
+	}
+	return container{}, nil
+}
+
+// parseCgroupPath extracts the pod UID and container ID from a kubepods
+// cgroup path for both the cgroupfs and the systemd cgroup drivers.
+func parseCgroupPath(path string) container {
+	match := podUIDPattern.FindStringSubmatch(path)
+	if match == nil {
+		return container{}
+	}
+	c := container{podUID: strings.ReplaceAll(match[1], "_", "-")}
+	if match := containerIDPattern.FindStringSubmatch(path); match != nil {
+		c.containerID = match[1]
+	}
+	return c
+}
@@ -0,0 +1,59 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCgroupPath(t *testing.T) {
+	testCases := []struct {
+		description string
+		path        string
+		expected    container
+	}{
+		{
+			description: "cgroupfs driver",
+			path:        "/kubepods/burstable/pod0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b/" + testContainerID,
+			expected:    container{podUID: "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b", containerID: testContainerID},
+		},
+		{
+			description: "systemd driver",
+			path:        "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0f1e2d3c_4b5a_6978_8a9b_0c1d2e3f4a5b.slice/cri-containerd-" + testContainerID + ".scope",
+			expected:    container{podUID: "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b", containerID: testContainerID},
+		},
+		{
+			description: "pod cgroup without container",
+			path:        "/kubepods/pod0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b",
+			expected:    container{podUID: "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b"},
+		},
+		{
+			description: "process outside of a pod",
+			path:        "/system.slice/containerd.service",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, parseCgroupPath(tc.path))
+		})
+	}
+}
+
+const testContainerID = "4f2d8c1b9a7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c"
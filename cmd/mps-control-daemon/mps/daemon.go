@@ -18,17 +18,28 @@ package mps
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 
 	"k8s.io/klog/v2"
 
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+	"github.com/NVIDIA/k8s-device-plugin/pkg/mpsclient"
 )
 
+// ErrNotFound is returned if an MPS server or client does not exist.
+var ErrNotFound = errors.New("not found")
+
 type computeMode string
 
 const (
@@ -47,21 +58,109 @@ type Daemon struct {
 	// root represents the root at which the files and folders controlled by the
 	// daemon are created. These include the log and pipe directories.
 	root Root
+	// logDir overrides the log directory under the root if set.
+	logDir string
+	// pipeDir overrides the pipe directory under the root if set. It is
+	// relative to the root so that the pipe directory on the host can be
+	// determined.
+	pipeDir string
 	// logTailer tails the MPS control daemon logs.
 	logTailer *tailer
+	// affinity assigns clients to GPUs if the daemon manages multiple GPUs.
+	affinity *clientAffinity
+	// selfTest verifies that the daemon is functional once it is started.
+	selfTest *selfTest
+	// supervisor restarts the daemon if it stops responding.
+	supervisor *supervisor
+	// restarts counts the number of times the daemon was restarted by its
+	// supervisor.
+	restarts atomic.Uint64
+	// supervisedUntil is the time, in nanoseconds since the epoch, by which
+	// the supervisor is expected to check the daemon again. It is 0 while the
+	// daemon is not being supervised.
+	supervisedUntil atomic.Int64
+	// memoryOverheadMB is the memory used by the MPS server on each device
+	// that is not available to the replicas.
+	memoryOverheadMB uint64
+	// memoryLimit overrides the pinned memory limit of each client as a
+	// fraction of the memory of a device if set.
+	memoryLimit *spec.Fraction
+	// memoryLimitMB overrides the pinned memory limit of each client with an
+	// explicit limit in MB if set.
+	memoryLimitMB *uint64
+	// replicaMemoryLimitsMB lists the explicit pinned memory limit in MB of
+	// each replica of a device if set.
+	replicaMemoryLimitsMB []uint64
+	// memoryChunkMB is the size in MB of each replica if the replicas are
+	// chunks of the memory of a device. The pinned memory limit of a client
+	// is the total size of the chunks that it is allocated.
+	memoryChunkMB uint64
+	// threadLimit overrides the active thread percentage of each client as a
+	// fraction of the threads of a device if set.
+	threadLimit *spec.Fraction
+	// migDevice is the UUID of the MIG device that the daemon is dedicated to.
+	// It is empty if the daemon manages all devices of its resource.
+	migDevice string
+	// envPassthrough lists the environment variables that are propagated from
+	// the environment of the process to the daemon.
+	envPassthrough []string
+	// userID and groupID set the identity under which the MPS control process
+	// and its servers are run if set.
+	userID  *int64
+	groupID *int64
 }
 
 // NewDaemon creates an MPS daemon instance.
-func NewDaemon(rm rm.ResourceManager, root Root) *Daemon {
-	return &Daemon{
+func NewDaemon(rm rm.ResourceManager, root Root, opts ...DaemonOption) *Daemon {
+	d := &Daemon{
 		rm:   rm,
 		root: root,
 	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// NewMigDaemons creates an MPS daemon instance for each MIG device of the
+// resource. The daemons are ordered by the UUID of their MIG device.
+func NewMigDaemons(rm rm.ResourceManager, root Root, opts ...DaemonOption) []*Daemon {
+	uuids := rm.Devices().GetUUIDs()
+	slices.Sort(uuids)
+
+	var daemons []*Daemon
+	for _, uuid := range uuids {
+		d := &Daemon{
+			rm:        rm,
+			root:      root,
+			migDevice: uuid,
+		}
+		for _, opt := range opts {
+			opt(d)
+		}
+		daemons = append(daemons, d)
+	}
+	return daemons
 }
 
 // Devices returns the list of devices under the control of this MPS daemon.
 func (d *Daemon) Devices() rm.Devices {
-	return d.rm.Devices()
+	if d.migDevice == "" {
+		return d.rm.Devices()
+	}
+	devices := make(rm.Devices)
+	for id, device := range d.rm.Devices() {
+		if device.GetUUID() == d.migDevice {
+			devices[id] = device
+		}
+	}
+	return devices
+}
+
+// MigDevice returns the UUID of the MIG device that the daemon is dedicated
+// to, or an empty string if the daemon manages all devices of its resource.
+func (d *Daemon) MigDevice() string {
+	return d.migDevice
 }
 
 type envvars map[string]string
@@ -74,14 +173,75 @@ func (e envvars) toSlice() []string {
 	return envs
 }
 
+// HasClientAffinity returns whether clients of the daemon are assigned to GPUs
+// using a client affinity policy.
+func (d *Daemon) HasClientAffinity() bool {
+	return d != nil && d.affinity != nil
+}
+
+// GetPreferredAllocation returns the preferred allocation of replicas based on
+// the client affinity policy of the daemon.
+func (d *Daemon) GetPreferredAllocation(available, required []string, size int) ([]string, error) {
+	if !d.HasClientAffinity() {
+		return nil, fmt.Errorf("no client affinity configured")
+	}
+	return d.affinity.preferredAllocation(available, required, size)
+}
+
+// ClientEnvvars records the assignment of the specified replicas to GPUs and
+// returns the environment variables that expose this assignment to a client.
+// If explicit memory limits are configured per replica, or if the replicas are
+// chunks of memory, the pinned memory limits of the specified replicas are
+// also returned.
+func (d *Daemon) ClientEnvvars(ids []string) envvars {
+	envs := make(envvars)
+	if d.HasClientAffinity() {
+		for k, v := range d.affinity.assign(ids) {
+			envs[k] = v
+		}
+	}
+	if limits := d.clientPinnedDeviceMemoryLimits(ids); limits != "" {
+		envs["CUDA_MPS_PINNED_DEVICE_MEM_LIMIT"] = limits
+	}
+	if len(envs) == 0 {
+		return nil
+	}
+	return envs
+}
+
+// UnconstrainedClientEnvvars returns the environment variables that raise the
+// active thread percentage and the pinned memory limits of a client that is
+// allocated the specified replicas, so that it can use the whole devices.
+// They override the limits returned by ClientEnvvars.
+func (d *Daemon) UnconstrainedClientEnvvars(ids []string) envvars {
+	envs := envvars{
+		"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE": "100",
+	}
+	if limits := d.unconstrainedPinnedDeviceMemoryLimits(ids); limits != "" {
+		envs["CUDA_MPS_PINNED_DEVICE_MEM_LIMIT"] = limits
+	}
+	return envs
+}
+
 // Envvars returns the environment variables required for the daemon.
 // These should be passed to clients consuming the device shared using MPS.
+// The environment variables that are passed through are included if they are
+// set in the environment of the process.
 // TODO: Set CUDA_VISIBLE_DEVICES to include only the devices for this resource type.
 func (d *Daemon) Envvars() envvars {
-	return map[string]string{
-		"CUDA_MPS_PIPE_DIRECTORY": d.PipeDir(),
-		"CUDA_MPS_LOG_DIRECTORY":  d.LogDir(),
+	envs := make(envvars)
+	for _, name := range d.envPassthrough {
+		if value, exists := os.LookupEnv(name); exists {
+			envs[name] = value
+		}
+	}
+	envs["CUDA_MPS_PIPE_DIRECTORY"] = d.PipeDir()
+	envs["CUDA_MPS_LOG_DIRECTORY"] = d.LogDir()
+	// The MPS server of a MIG device must only see that MIG device.
+	if d.migDevice != "" {
+		envs["CUDA_VISIBLE_DEVICES"] = d.migDevice
 	}
+	return envs
 }
 
 // Start starts the MPS deamon as a background process.
@@ -90,7 +250,7 @@ func (d *Daemon) Start() error {
 		return fmt.Errorf("error setting compute mode %v: %w", computeModeExclusiveProcess, err)
 	}
 
-	klog.InfoS("Staring MPS daemon", "resource", d.rm.Resource())
+	klog.InfoS("Staring MPS daemon", "resource", d.rm.Resource(), "migDevice", d.migDevice)
 
 	pipeDir := d.PipeDir()
 	if err := os.MkdirAll(pipeDir, 0755); err != nil {
@@ -102,25 +262,34 @@ func (d *Daemon) Start() error {
 		return fmt.Errorf("error creating directory %v: %w", logDir, err)
 	}
 
+	for _, dir := range []string{pipeDir, logDir} {
+		if err := d.chown(dir); err != nil {
+			return fmt.Errorf("error changing owner of directory %v: %w", dir, err)
+		}
+	}
+
 	mpsDaemon := exec.Command(mpsControlBin, "-d")
 	mpsDaemon.Env = append(mpsDaemon.Env, d.Envvars().toSlice()...)
+	mpsDaemon.SysProcAttr = &syscall.SysProcAttr{Credential: d.credential()}
 	if err := mpsDaemon.Run(); err != nil {
 		return err
 	}
 
-	for index, limit := range d.perDevicePinnedDeviceMemoryLimits() {
-		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_device_pinned_mem_limit %s %s", index, limit))
-		if err != nil {
-			return fmt.Errorf("error setting pinned memory limit for device %v: %w", index, err)
+	if err := d.initialize(); err != nil {
+		// The control daemon is quit so that clients are not served by a
+		// daemon that is not functional and so that it can be started again.
+		if _, quitErr := d.EchoPipeToControl("quit"); quitErr != nil {
+			klog.ErrorS(quitErr, "Failed to quit MPS control daemon", "resource", d.rm.Resource(), "migDevice", d.migDevice)
 		}
-	}
-	if threadPercentage := d.activeThreadPercentage(); threadPercentage != "" {
-		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_active_thread_percentage %s", threadPercentage))
-		if err != nil {
-			return fmt.Errorf("error setting active thread percentage: %w", err)
+		if modeErr := d.setComputeMode(computeModeDefault); modeErr != nil {
+			klog.ErrorS(modeErr, "Failed to reset compute mode", "resource", d.rm.Resource(), "migDevice", d.migDevice)
 		}
+		return err
 	}
 
+	if err := os.Remove(d.failedFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove failed file: %w", err)
+	}
 	statusFile, err := os.Create(d.startedFile())
 	if err != nil {
 		return err
@@ -136,13 +305,35 @@ func (d *Daemon) Start() error {
 	return nil
 }
 
+// initialize applies the default limits to the started control daemon and
+// runs the self-test.
+func (d *Daemon) initialize() error {
+	for index, limit := range d.perDevicePinnedDeviceMemoryLimits() {
+		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_device_pinned_mem_limit %s %s", index, limit))
+		if err != nil {
+			return fmt.Errorf("error setting pinned memory limit for device %v: %w", index, err)
+		}
+	}
+	if threadPercentage := d.activeThreadPercentage(); threadPercentage != "" {
+		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_active_thread_percentage %s", threadPercentage))
+		if err != nil {
+			return fmt.Errorf("error setting active thread percentage: %w", err)
+		}
+	}
+
+	if err := d.selfTest.run(d); err != nil {
+		return fmt.Errorf("MPS self-test failed: %w", err)
+	}
+	return nil
+}
+
 // Stop ensures that the MPS daemon is quit.
 func (d *Daemon) Stop() error {
 	_, err := d.EchoPipeToControl("quit")
 	if err != nil {
 		return fmt.Errorf("error sending quit message: %w", err)
 	}
-	klog.InfoS("Stopped MPS control daemon", "resource", d.rm.Resource())
+	klog.InfoS("Stopped MPS control daemon", "resource", d.rm.Resource(), "migDevice", d.migDevice)
 
 	err = d.logTailer.Stop()
 	klog.InfoS("Stopped log tailer", "resource", d.rm.Resource(), "error", err)
@@ -154,21 +345,125 @@ func (d *Daemon) Stop() error {
 	if err := os.Remove(d.startedFile()); err != nil && err != os.ErrNotExist {
 		return fmt.Errorf("failed to remove started file: %w", err)
 	}
+	if err := os.Remove(d.failedFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove failed file: %w", err)
+	}
 
-	logDir := d.LogDir()
-	if err := os.RemoveAll(logDir); err != nil {
-		klog.ErrorS(err, "Failed to remove pipe directory", "path", logDir)
+	// Custom log directories are kept to allow the logs to be collected.
+	if d.logDir == "" {
+		logDir := d.LogDir()
+		if err := os.RemoveAll(logDir); err != nil {
+			klog.ErrorS(err, "Failed to remove pipe directory", "path", logDir)
+		}
 	}
 
 	return nil
 }
 
+// StartDaemons starts each of the specified daemons. A daemon that fails to
+// start does not prevent the remaining daemons from being started. Instead it
+// is marked as failed so that the device plugin does not advertise its devices
+// as healthy. The daemons that were started and the daemons that failed to
+// start are returned, along with the errors of the failed daemons.
+func StartDaemons(daemons []*Daemon) ([]*Daemon, []*Daemon, error) {
+	var started, failed []*Daemon
+	var errs []error
+	for _, d := range daemons {
+		err := d.Start()
+		if err == nil {
+			started = append(started, d)
+			continue
+		}
+		klog.ErrorS(err, "Failed to start MPS daemon", "resource", d.rm.Resource(), "migDevice", d.migDevice)
+		if err := d.markFailed(err); err != nil {
+			klog.ErrorS(err, "Failed to mark MPS control daemon as failed", "resource", d.rm.Resource(), "migDevice", d.migDevice)
+		}
+		failed = append(failed, d)
+		errs = append(errs, fmt.Errorf("failed to start MPS daemon for %v: %w", d.key(), err))
+	}
+	return started, failed, errors.Join(errs...)
+}
+
 func (d *Daemon) LogDir() string {
+	if d.logDir != "" {
+		if d.migDevice != "" {
+			return filepath.Join(d.logDir, d.migDevice)
+		}
+		return d.logDir
+	}
+	if d.migDevice != "" {
+		return d.root.MigLogDir(d.rm.Resource(), d.migDevice)
+	}
 	return d.root.LogDir(d.rm.Resource())
 }
 
 func (d *Daemon) PipeDir() string {
-	return d.root.PipeDir(d.rm.Resource())
+	return d.HostPipeDir(d.root)
+}
+
+// HostPipeDir returns the pipe dir of the daemon under the specified root.
+// This allows the pipe dir on the host to be determined from within a
+// container that mounts the host root elsewhere.
+func (d *Daemon) HostPipeDir(root Root) string {
+	if d.pipeDir != "" {
+		if d.migDevice != "" {
+			return root.Path(d.pipeDir, d.migDevice)
+		}
+		return root.Path(d.pipeDir)
+	}
+	if d.migDevice != "" {
+		return root.MigPipeDir(d.rm.Resource(), d.migDevice)
+	}
+	return root.PipeDir(d.rm.Resource())
+}
+
+// credential returns the credential under which the processes of the daemon
+// are run, or nil if they are run under the identity of the current process.
+func (d *Daemon) credential() *syscall.Credential {
+	if d.userID == nil && d.groupID == nil {
+		return nil
+	}
+	credential := &syscall.Credential{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}
+	if d.userID != nil {
+		credential.Uid = uint32(*d.userID)
+	}
+	if d.groupID != nil {
+		credential.Gid = uint32(*d.groupID)
+	}
+	return credential
+}
+
+// chown changes the owner of the specified directory and its contents to the
+// identity under which the processes of the daemon are run. The contents are
+// included so that files left by a daemon that ran under a different identity,
+// such as its control log, remain writable.
+func (d *Daemon) chown(dir string) error {
+	credential := d.credential()
+	if credential == nil {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, _ os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, int(credential.Uid), int(credential.Gid))
+	})
+}
+
+// HostPipeRoot returns the directory under the specified root that contains
+// the pipe dirs of all daemons of the resource. For a daemon that is not
+// dedicated to a MIG device, this is its pipe dir.
+func (d *Daemon) HostPipeRoot(root Root) string {
+	switch {
+	case d.migDevice == "":
+		return d.HostPipeDir(root)
+	case d.pipeDir != "":
+		return root.Path(d.pipeDir)
+	}
+	return root.Path(string(d.rm.Resource()))
 }
 
 func (d *Daemon) ShmDir() string {
@@ -176,6 +471,9 @@ func (d *Daemon) ShmDir() string {
 }
 
 func (d *Daemon) startedFile() string {
+	if d.migDevice != "" {
+		return d.root.migStartedFile(d.rm.Resource(), d.migDevice)
+	}
 	return d.root.startedFile(d.rm.Resource())
 }
 
@@ -185,6 +483,94 @@ func (d *Daemon) AssertHealthy() error {
 	return err
 }
 
+// Stats returns the state of the MPS control daemon and its servers.
+// If the servers cannot be queried, the error is recorded in the stats.
+func (d *Daemon) Stats() mpsclient.DaemonStats {
+	stats := mpsclient.DaemonStats{
+		Resource:                 string(d.rm.Resource()),
+		MigDevice:                d.migDevice,
+		Devices:                  d.Devices().GetUUIDs(),
+		Replicas:                 len(d.Devices()),
+		ActiveThreadPercentage:   d.activeThreadPercentage(),
+		PinnedDeviceMemoryLimits: d.perDevicePinnedDeviceMemoryLimits(),
+		Servers:                  []mpsclient.Server{},
+	}
+	servers, err := d.getServerList()
+	if err != nil {
+		stats.Error = err.Error()
+		return stats
+	}
+	for _, pid := range servers {
+		clients, err := d.getClientList(pid)
+		if err != nil {
+			stats.Error = err.Error()
+			return stats
+		}
+		stats.Servers = append(stats.Servers, mpsclient.Server{PID: pid, Clients: clients})
+	}
+	return stats
+}
+
+// TerminateClient terminates the specified client of an MPS server.
+func (d *Daemon) TerminateClient(serverPID int, clientPID int) error {
+	clients, err := d.getClientList(serverPID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(clients, clientPID) {
+		return fmt.Errorf("%w: client %d of server %d", ErrNotFound, clientPID, serverPID)
+	}
+	_, err = d.EchoPipeToControl(fmt.Sprintf("terminate_client %d %d", serverPID, clientPID))
+	if err != nil {
+		return fmt.Errorf("error terminating client %d of server %d: %w", clientPID, serverPID, err)
+	}
+	klog.InfoS("Terminated MPS client", "resource", d.rm.Resource(), "server", serverPID, "client", clientPID)
+	return nil
+}
+
+// getServerList returns the PIDs of the MPS servers started by the daemon.
+func (d *Daemon) getServerList() ([]int, error) {
+	output, err := d.EchoPipeToControl("get_server_list")
+	if err != nil {
+		return nil, fmt.Errorf("error getting server list: %w", err)
+	}
+	return parsePIDs(output)
+}
+
+// getClientList returns the PIDs of the clients connected to an MPS server.
+func (d *Daemon) getClientList(serverPID int) ([]int, error) {
+	servers, err := d.getServerList()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(servers, serverPID) {
+		return nil, fmt.Errorf("%w: server %d", ErrNotFound, serverPID)
+	}
+	output, err := d.EchoPipeToControl(fmt.Sprintf("get_client_list %d", serverPID))
+	if err != nil {
+		return nil, fmt.Errorf("error getting client list of server %d: %w", serverPID, err)
+	}
+	return parsePIDs(output)
+}
+
+// parsePIDs parses the newline-separated list of PIDs returned by the MPS
+// control daemon.
+func parsePIDs(output string) ([]int, error) {
+	pids := []int{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected PID %q", line)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
 // EchoPipeToControl sends the specified command to the MPS control daemon.
 func (d *Daemon) EchoPipeToControl(command string) (string, error) {
 	var out bytes.Buffer
@@ -194,6 +580,7 @@ func (d *Daemon) EchoPipeToControl(command string) (string, error) {
 
 	mpsDaemon := exec.Command(mpsControlBin)
 	mpsDaemon.Env = append(mpsDaemon.Env, d.Envvars().toSlice()...)
+	mpsDaemon.SysProcAttr = &syscall.SysProcAttr{Credential: d.credential()}
 
 	mpsDaemon.Stdin = reader
 	mpsDaemon.Stdout = &out
@@ -214,6 +601,11 @@ func (d *Daemon) EchoPipeToControl(command string) (string, error) {
 }
 
 func (d *Daemon) setComputeMode(mode computeMode) error {
+	// The compute mode cannot be set for MIG devices. Clients of a MIG device
+	// are confined to it through CUDA_VISIBLE_DEVICES instead.
+	if d.migDevice != "" {
+		return nil
+	}
 	for _, uuid := range d.Devices().GetUUIDs() {
 		cmd := exec.Command(
 			"nvidia-smi",
@@ -229,11 +621,19 @@ func (d *Daemon) setComputeMode(mode computeMode) error {
 }
 
 // perDevicePinnedMemoryLimits returns the pinned memory limits for each device.
+// The memory used by the MPS server on a device is subtracted before the
+// memory of the device is split between its replicas, or before the configured
+// memory limit is applied to it.
 func (m *Daemon) perDevicePinnedDeviceMemoryLimits() map[string]string {
 	totalMemoryInBytesPerDevice := make(map[string]uint64)
 	replicasPerDevice := make(map[string]uint64)
 	for _, device := range m.Devices() {
 		index := device.Index
+		// The MIG device of a dedicated daemon is the only device visible to
+		// its MPS server and is therefore addressed as device 0.
+		if m.migDevice != "" {
+			index = "0"
+		}
 		totalMemoryInBytesPerDevice[index] = device.TotalMemory
 		replicasPerDevice[index] += 1
 	}
@@ -243,16 +643,131 @@ func (m *Daemon) perDevicePinnedDeviceMemoryLimits() map[string]string {
 		if totalMemory == 0 {
 			continue
 		}
+		totalMemoryMB := totalMemory / 1024 / 1024
+		if totalMemoryMB <= m.memoryOverheadMB {
+			continue
+		}
+		available := totalMemoryMB - m.memoryOverheadMB
+		if m.memoryLimit != nil {
+			limits[index] = fmt.Sprintf("%vM", m.memoryLimit.Of(available))
+			continue
+		}
+		if m.memoryLimitMB != nil {
+			limits[index] = fmt.Sprintf("%vM", *m.memoryLimitMB)
+			continue
+		}
+		// Clients are allocated at least one chunk of memory and are given
+		// the limit of their chunks when they are allocated.
+		if m.memoryChunkMB > 0 {
+			limits[index] = fmt.Sprintf("%vM", m.memoryChunkMB)
+			continue
+		}
+		// Clients with a per-replica limit override the default limit, which
+		// is therefore set to the smallest limit for any other clients.
+		if len(m.replicaMemoryLimitsMB) > 0 {
+			limits[index] = fmt.Sprintf("%vM", slices.Min(m.replicaMemoryLimitsMB))
+			continue
+		}
 		replicas := replicasPerDevice[index]
-		limits[index] = fmt.Sprintf("%vM", totalMemory/replicas/1024/1024)
+		limits[index] = fmt.Sprintf("%vM", available/replicas)
 	}
 	return limits
 }
 
+// clientPinnedDeviceMemoryLimits returns the pinned memory limits of a client
+// that is allocated the specified replicas if explicit memory limits are
+// configured per replica or if the replicas are chunks of memory. The limits
+// of multiple replicas of the same device are added up. The devices are addressed by their position in the order of
+// their indices, which matches the order in which they are visible to the
+// client.
+func (m *Daemon) clientPinnedDeviceMemoryLimits(ids []string) string {
+	if len(m.replicaMemoryLimitsMB) == 0 && m.memoryChunkMB == 0 {
+		return ""
+	}
+	limitsPerDevice := make(map[string]uint64)
+	var devices []*rm.Device
+	for _, id := range ids {
+		device := m.Devices().GetByID(id)
+		if device == nil {
+			continue
+		}
+		limit := m.memoryChunkMB
+		if limit == 0 {
+			_, replica := rm.AnnotatedID(id).Split()
+			if replica < 0 || replica >= len(m.replicaMemoryLimitsMB) {
+				continue
+			}
+			limit = m.replicaMemoryLimitsMB[replica]
+		}
+		uuid := device.GetUUID()
+		if _, exists := limitsPerDevice[uuid]; !exists {
+			devices = append(devices, device)
+		}
+		limitsPerDevice[uuid] += limit
+	}
+	sortByIndex(devices)
+
+	var limits []string
+	for ordinal, device := range devices {
+		limits = append(limits, fmt.Sprintf("%d=%vM", ordinal, limitsPerDevice[device.GetUUID()]))
+	}
+	return strings.Join(limits, ",")
+}
+
+// unconstrainedPinnedDeviceMemoryLimits returns the pinned memory limits of a
+// client that may use all the memory of the devices of the specified replicas
+// that is not used by the MPS server.
+func (m *Daemon) unconstrainedPinnedDeviceMemoryLimits(ids []string) string {
+	seen := make(map[string]bool)
+	var devices []*rm.Device
+	for _, id := range ids {
+		device := m.Devices().GetByID(id)
+		if device == nil || seen[device.GetUUID()] {
+			continue
+		}
+		seen[device.GetUUID()] = true
+		devices = append(devices, device)
+	}
+	sortByIndex(devices)
+
+	var limits []string
+	for ordinal, device := range devices {
+		totalMemoryMB := device.TotalMemory / 1024 / 1024
+		if totalMemoryMB <= m.memoryOverheadMB {
+			continue
+		}
+		limits = append(limits, fmt.Sprintf("%d=%vM", ordinal, totalMemoryMB-m.memoryOverheadMB))
+	}
+	return strings.Join(limits, ",")
+}
+
+// sortByIndex sorts devices in the order of their indices.
+func sortByIndex(devices []*rm.Device) {
+	slices.SortFunc(devices, func(a, b *rm.Device) int {
+		if indexLess(a.Index, b.Index) {
+			return -1
+		}
+		if indexLess(b.Index, a.Index) {
+			return 1
+		}
+		return 0
+	})
+}
+
+// activeThreadPercentage returns the active thread percentage of each client.
+// The configured thread limit is rounded down to a whole percentage. If the
+// replicas are chunks of memory, the threads are not split between them unless
+// a thread limit is configured.
 func (m *Daemon) activeThreadPercentage() string {
 	if len(m.Devices()) == 0 {
 		return ""
 	}
+	if m.threadLimit != nil {
+		return fmt.Sprintf("%d", max(m.threadLimit.Of(100), 1))
+	}
+	if m.memoryChunkMB > 0 {
+		return ""
+	}
 	replicasPerDevice := len(m.Devices()) / len(m.Devices().GetUUIDs())
 
 	return fmt.Sprintf("%d", 100/replicasPerDevice)
@@ -0,0 +1,516 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testResourceManager struct {
+	rm.ResourceManager
+	resource spec.ResourceName
+	devices  rm.Devices
+}
+
+func (m testResourceManager) Resource() spec.ResourceName {
+	if m.resource == "" {
+		return "nvidia.com/gpu"
+	}
+	return m.resource
+}
+
+func (m testResourceManager) Devices() rm.Devices {
+	return m.devices
+}
+
+func TestDaemonLogDir(t *testing.T) {
+	testCases := []struct {
+		description string
+		logDir      string
+		expected    string
+	}{
+		{
+			description: "default log directory",
+			expected:    "/mps/nvidia.com/gpu/log",
+		},
+		{
+			description: "relative log directory is under root",
+			logDir:      "logs/gpu",
+			expected:    "/mps/logs/gpu",
+		},
+		{
+			description: "absolute log directory is used as is",
+			logDir:      "/var/log/mps/gpu",
+			expected:    "/var/log/mps/gpu",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			d := NewDaemon(testResourceManager{}, ContainerRoot, WithLogDirectory(tc.logDir))
+			require.Equal(t, tc.expected, d.LogDir())
+			require.Equal(t, tc.expected, d.Envvars()["CUDA_MPS_LOG_DIRECTORY"])
+		})
+	}
+}
+
+func TestDaemonPipeDir(t *testing.T) {
+	devices := rm.Devices{"MIG-a::0": &rm.Device{}}
+	devices["MIG-a::0"].ID = "MIG-a::0"
+
+	testCases := []struct {
+		description      string
+		pipeDir          string
+		daemon           func(opts ...DaemonOption) *Daemon
+		expected         string
+		expectedHost     string
+		expectedHostRoot string
+	}{
+		{
+			description: "default pipe directory",
+			daemon: func(opts ...DaemonOption) *Daemon {
+				return NewDaemon(testResourceManager{}, Root("/run/mps"), opts...)
+			},
+			expected:         "/run/mps/nvidia.com/gpu/pipe",
+			expectedHost:     "/host/nvidia.com/gpu/pipe",
+			expectedHostRoot: "/host/nvidia.com/gpu/pipe",
+		},
+		{
+			description: "pipe directory is relative to root",
+			pipeDir:     "tmpfs/gpu",
+			daemon: func(opts ...DaemonOption) *Daemon {
+				return NewDaemon(testResourceManager{}, Root("/run/mps"), opts...)
+			},
+			expected:         "/run/mps/tmpfs/gpu",
+			expectedHost:     "/host/tmpfs/gpu",
+			expectedHostRoot: "/host/tmpfs/gpu",
+		},
+		{
+			description: "default pipe directory of MIG device",
+			daemon: func(opts ...DaemonOption) *Daemon {
+				return NewMigDaemons(testResourceManager{devices: devices}, Root("/run/mps"), opts...)[0]
+			},
+			expected:         "/run/mps/nvidia.com/gpu/MIG-a/pipe",
+			expectedHost:     "/host/nvidia.com/gpu/MIG-a/pipe",
+			expectedHostRoot: "/host/nvidia.com/gpu",
+		},
+		{
+			description: "pipe directory of MIG device is per MIG device",
+			pipeDir:     "tmpfs/gpu",
+			daemon: func(opts ...DaemonOption) *Daemon {
+				return NewMigDaemons(testResourceManager{devices: devices}, Root("/run/mps"), opts...)[0]
+			},
+			expected:         "/run/mps/tmpfs/gpu/MIG-a",
+			expectedHost:     "/host/tmpfs/gpu/MIG-a",
+			expectedHostRoot: "/host/tmpfs/gpu",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			d := tc.daemon(WithPipeDirectory(tc.pipeDir))
+			require.Equal(t, tc.expected, d.PipeDir())
+			require.Equal(t, tc.expected, d.Envvars()["CUDA_MPS_PIPE_DIRECTORY"])
+			require.Equal(t, tc.expectedHost, d.HostPipeDir(Root("/host")))
+			require.Equal(t, tc.expectedHostRoot, d.HostPipeRoot(Root("/host")))
+		})
+	}
+}
+
+func TestDaemonCredential(t *testing.T) {
+	uid := int64(1000)
+	gid := int64(2000)
+
+	require.Nil(t, NewDaemon(testResourceManager{}, ContainerRoot).credential())
+
+	credential := NewDaemon(testResourceManager{}, ContainerRoot, WithIdentity(&uid, &gid)).credential()
+	require.Equal(t, &syscall.Credential{Uid: 1000, Gid: 2000}, credential)
+
+	credential = NewDaemon(testResourceManager{}, ContainerRoot, WithIdentity(&uid, nil)).credential()
+	require.Equal(t, &syscall.Credential{Uid: 1000, Gid: uint32(os.Getgid())}, credential)
+}
+
+func TestDaemonChown(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner of files requires root")
+	}
+	uid := int64(1000)
+	gid := int64(2000)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "control.log"), nil, 0644))
+
+	require.NoError(t, NewDaemon(testResourceManager{}, ContainerRoot, WithIdentity(&uid, &gid)).chown(dir))
+	for _, path := range []string{dir, filepath.Join(dir, "control.log")} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		stat := info.Sys().(*syscall.Stat_t)
+		require.Equal(t, uint32(1000), stat.Uid, path)
+		require.Equal(t, uint32(2000), stat.Gid, path)
+	}
+}
+
+func TestNewMigDaemons(t *testing.T) {
+	indices := map[string]string{"MIG-a": "0:1", "MIG-b": "0:0"}
+	devices := make(rm.Devices)
+	for i, mig := range []string{"MIG-b", "MIG-b", "MIG-a", "MIG-a", "MIG-a", "MIG-a"} {
+		id := fmt.Sprintf("%v::%v", mig, i)
+		devices[id] = &rm.Device{Index: indices[mig], TotalMemory: 10240 * 1024 * 1024}
+		devices[id].ID = id
+	}
+
+	daemons := NewMigDaemons(testResourceManager{devices: devices}, ContainerRoot, WithLogDirectory("logs"))
+	require.Len(t, daemons, 2)
+
+	testCases := []struct {
+		migDevice      string
+		replicas       int
+		pipeDir        string
+		logDir         string
+		memoryLimits   map[string]string
+		threadFraction string
+	}{
+		{
+			migDevice:      "MIG-a",
+			replicas:       4,
+			pipeDir:        "/mps/nvidia.com/gpu/MIG-a/pipe",
+			logDir:         "/mps/logs/MIG-a",
+			memoryLimits:   map[string]string{"0": "2560M"},
+			threadFraction: "25",
+		},
+		{
+			migDevice:      "MIG-b",
+			replicas:       2,
+			pipeDir:        "/mps/nvidia.com/gpu/MIG-b/pipe",
+			logDir:         "/mps/logs/MIG-b",
+			memoryLimits:   map[string]string{"0": "5120M"},
+			threadFraction: "50",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.migDevice, func(t *testing.T) {
+			d := daemons[i]
+			require.Equal(t, tc.migDevice, d.MigDevice())
+			require.Len(t, d.Devices(), tc.replicas)
+			require.Equal(t, tc.pipeDir, d.PipeDir())
+			require.Equal(t, "/host"+tc.pipeDir[len("/mps"):], d.HostPipeDir(Root("/host")))
+			require.Equal(t, tc.logDir, d.LogDir())
+			require.Equal(t, tc.migDevice, d.Envvars()["CUDA_VISIBLE_DEVICES"])
+			require.Equal(t, tc.memoryLimits, d.perDevicePinnedDeviceMemoryLimits())
+			require.Equal(t, tc.threadFraction, d.activeThreadPercentage())
+		})
+	}
+}
+
+func TestDaemonEnvPassthrough(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy:3128")
+	t.Setenv("CUDA_MPS_ENABLE_PER_CTX_DEVICE_MULTIPROCESSOR_PARTITIONING", "1")
+	t.Setenv("NOT_PASSED_THROUGH", "1")
+
+	d := NewDaemon(testResourceManager{}, ContainerRoot,
+		WithEnvPassthrough([]string{"HTTPS_PROXY", "CUDA_MPS_ENABLE_PER_CTX_DEVICE_MULTIPROCESSOR_PARTITIONING", "UNSET"}),
+	)
+
+	expected := envvars{
+		"HTTPS_PROXY": "http://proxy:3128",
+		"CUDA_MPS_ENABLE_PER_CTX_DEVICE_MULTIPROCESSOR_PARTITIONING": "1",
+		"CUDA_MPS_PIPE_DIRECTORY":                                    "/mps/nvidia.com/gpu/pipe",
+		"CUDA_MPS_LOG_DIRECTORY":                                     "/mps/nvidia.com/gpu/log",
+	}
+	require.Equal(t, expected, d.Envvars())
+}
+
+func TestPerDevicePinnedDeviceMemoryLimits(t *testing.T) {
+	devices := make(rm.Devices)
+	for i, index := range []string{"0", "0", "0", "0", "1", "1"} {
+		id := fmt.Sprintf("GPU-%v::%v", index, i)
+		devices[id] = &rm.Device{Index: index, TotalMemory: 40960 * 1024 * 1024}
+	}
+
+	eighth, err := spec.NewFraction("1/8")
+	require.NoError(t, err)
+	limitMB := uint64(4096)
+
+	testCases := []struct {
+		description     string
+		overheadMB      uint64
+		memoryLimit     *spec.Fraction
+		memoryLimitMB   *uint64
+		replicaLimitsMB []uint64
+		memoryChunkMB   uint64
+		expected        map[string]string
+	}{
+		{
+			description: "no overhead",
+			expected:    map[string]string{"0": "10240M", "1": "20480M"},
+		},
+		{
+			description: "overhead is subtracted before splitting memory",
+			overheadMB:  512,
+			expected:    map[string]string{"0": "10112M", "1": "20224M"},
+		},
+		{
+			description: "no limit if overhead exceeds memory",
+			overheadMB:  40960,
+			expected:    map[string]string{},
+		},
+		{
+			description: "memory limit is a fraction of each device",
+			overheadMB:  512,
+			memoryLimit: &eighth,
+			expected:    map[string]string{"0": "5056M", "1": "5056M"},
+		},
+		{
+			description:   "explicit memory limit",
+			overheadMB:    512,
+			memoryLimitMB: &limitMB,
+			expected:      map[string]string{"0": "4096M", "1": "4096M"},
+		},
+		{
+			description:     "per-replica limits default to the smallest limit",
+			replicaLimitsMB: []uint64{8192, 2048, 4096, 4096},
+			expected:        map[string]string{"0": "2048M", "1": "2048M"},
+		},
+		{
+			description:   "memory chunks default to the size of a chunk",
+			overheadMB:    512,
+			memoryChunkMB: 1024,
+			expected:      map[string]string{"0": "1024M", "1": "1024M"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			d := NewDaemon(testResourceManager{devices: devices}, ContainerRoot,
+				WithServerMemoryOverhead(tc.overheadMB),
+				WithMemoryLimit(tc.memoryLimit),
+				WithMemoryLimitMB(tc.memoryLimitMB),
+				WithReplicaMemoryLimits(tc.replicaLimitsMB),
+				WithMemoryChunk(tc.memoryChunkMB),
+			)
+			require.Equal(t, tc.expected, d.perDevicePinnedDeviceMemoryLimits())
+		})
+	}
+}
+
+func TestClientPinnedDeviceMemoryLimits(t *testing.T) {
+	devices := make(rm.Devices)
+	for _, gpu := range []string{"0", "1"} {
+		for replica := 0; replica < 3; replica++ {
+			id := fmt.Sprintf("GPU-%v::%v", gpu, replica)
+			devices[id] = &rm.Device{Index: gpu, TotalMemory: 40960 * 1024 * 1024}
+			devices[id].ID = id
+		}
+	}
+
+	testCases := []struct {
+		description     string
+		replicaLimitsMB []uint64
+		memoryChunkMB   uint64
+		ids             []string
+		expected        envvars
+	}{
+		{
+			description: "no per-replica limits",
+			ids:         []string{"GPU-0::0"},
+		},
+		{
+			description:     "limit of a single replica",
+			replicaLimitsMB: []uint64{8192, 4096, 2048},
+			ids:             []string{"GPU-1::1"},
+			expected:        envvars{"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT": "0=4096M"},
+		},
+		{
+			description:     "limits of replicas of the same device are added up",
+			replicaLimitsMB: []uint64{8192, 4096, 2048},
+			ids:             []string{"GPU-0::0", "GPU-0::2"},
+			expected:        envvars{"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT": "0=10240M"},
+		},
+		{
+			description:     "devices are ordered by index",
+			replicaLimitsMB: []uint64{8192, 4096, 2048},
+			ids:             []string{"GPU-1::2", "GPU-0::1"},
+			expected:        envvars{"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT": "0=4096M,1=2048M"},
+		},
+		{
+			description:   "limit is the total size of the memory chunks",
+			memoryChunkMB: 1024,
+			ids:           []string{"GPU-1::0", "GPU-1::1", "GPU-1::2"},
+			expected:      envvars{"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT": "0=3072M"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			d := NewDaemon(testResourceManager{devices: devices}, ContainerRoot,
+				WithReplicaMemoryLimits(tc.replicaLimitsMB),
+				WithMemoryChunk(tc.memoryChunkMB),
+			)
+			require.Equal(t, tc.expected, d.ClientEnvvars(tc.ids))
+		})
+	}
+}
+
+func TestUnconstrainedClientEnvvars(t *testing.T) {
+	devices := make(rm.Devices)
+	for _, gpu := range []string{"0", "1"} {
+		for replica := 0; replica < 2; replica++ {
+			id := fmt.Sprintf("GPU-%v::%v", gpu, replica)
+			devices[id] = &rm.Device{Index: gpu, TotalMemory: 40960 * 1024 * 1024}
+			devices[id].ID = id
+		}
+	}
+
+	testCases := []struct {
+		description     string
+		replicaLimitsMB []uint64
+		ids             []string
+		expected        envvars
+	}{
+		{
+			description: "client may use the memory of the device not used by the server",
+			ids:         []string{"GPU-0::0"},
+			expected: envvars{
+				"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE": "100",
+				"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT":  "0=40448M",
+			},
+		},
+		{
+			description:     "per-replica limits are ignored",
+			replicaLimitsMB: []uint64{8192, 4096},
+			ids:             []string{"GPU-1::0", "GPU-1::1", "GPU-0::1"},
+			expected: envvars{
+				"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE": "100",
+				"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT":  "0=40448M,1=40448M",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			d := NewDaemon(testResourceManager{devices: devices}, ContainerRoot,
+				WithServerMemoryOverhead(512),
+				WithReplicaMemoryLimits(tc.replicaLimitsMB),
+			)
+			require.Equal(t, tc.expected, d.UnconstrainedClientEnvvars(tc.ids))
+		})
+	}
+}
+
+func TestActiveThreadPercentage(t *testing.T) {
+	devices := make(rm.Devices)
+	for i, index := range []string{"0", "0", "0", "0"} {
+		id := fmt.Sprintf("GPU-%v::%v", index, i)
+		devices[id] = &rm.Device{Index: index, TotalMemory: 40960 * 1024 * 1024}
+		devices[id].ID = id
+	}
+
+	testCases := []struct {
+		description   string
+		threadLimit   string
+		memoryChunkMB uint64
+		expected      string
+	}{
+		{
+			description: "threads are split between replicas",
+			expected:    "25",
+		},
+		{
+			description: "thread limit is rounded down",
+			threadLimit: "1/3",
+			expected:    "33",
+		},
+		{
+			description: "thread limit is at least one percent",
+			threadLimit: "0.1%",
+			expected:    "1",
+		},
+		{
+			description:   "threads are not split between memory chunks",
+			memoryChunkMB: 1024,
+			expected:      "",
+		},
+		{
+			description:   "thread limit applies to memory chunks",
+			threadLimit:   "1/2",
+			memoryChunkMB: 1024,
+			expected:      "50",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var threadLimit *spec.Fraction
+			if tc.threadLimit != "" {
+				f, err := spec.NewFraction(tc.threadLimit)
+				require.NoError(t, err)
+				threadLimit = &f
+			}
+			d := NewDaemon(testResourceManager{devices: devices}, ContainerRoot,
+				WithThreadLimit(threadLimit),
+				WithMemoryChunk(tc.memoryChunkMB),
+			)
+			require.Equal(t, tc.expected, d.activeThreadPercentage())
+		})
+	}
+}
+
+func TestParsePIDs(t *testing.T) {
+	testCases := []struct {
+		description string
+		output      string
+		expected    []int
+		err         bool
+	}{
+		{
+			description: "empty output",
+			expected:    []int{},
+		},
+		{
+			description: "one PID per line",
+			output:      "123\n456\n",
+			expected:    []int{123, 456},
+		},
+		{
+			description: "invalid PID",
+			output:      "123\nerror\n",
+			err:         true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pids, err := parsePIDs(tc.output)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, pids)
+		})
+	}
+}
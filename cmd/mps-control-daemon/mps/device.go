@@ -23,11 +23,38 @@ import (
 
 	"golang.org/x/mod/semver"
 
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
 var errInvalidDevice = errors.New("invalid device")
 
+// MaxClients is the maximum number of clients supported by the MPS server of
+// a Volta or newer device. Older devices support fewer clients.
+const MaxClients = 48
+
+// ValidateDevices checks whether the MPS configuration of a resource can be
+// applied to its devices. If the replicas are chunks of memory, a device must
+// not have more chunks than clients supported by its MPS server, since each
+// chunk can be allocated to a separate client.
+func ValidateDevices(rrs *spec.ReplicatedResources, r *spec.ReplicatedResource, devices rm.Devices) error {
+	for _, d := range devices {
+		if rrs.IsMemory() {
+			if err := (*mpsDevice)(d).assertMemoryChunks(); err != nil {
+				return fmt.Errorf("device %v: %w", d.ID, err)
+			}
+		} else {
+			if err := (*mpsDevice)(d).assertReplicas(); err != nil {
+				return fmt.Errorf("device %v: %w", d.ID, err)
+			}
+		}
+		if err := (*mpsDevice)(d).assertMemoryLimits(r); err != nil {
+			return fmt.Errorf("device %v: %w", d.ID, err)
+		}
+	}
+	return nil
+}
+
 // mpsDevice represents an MPS-specific alias for an rm.Device.
 type mpsDevice rm.Device
 
@@ -40,10 +67,41 @@ func (d *mpsDevice) assertReplicas() error {
 	return nil
 }
 
+// assertMemoryChunks checks whether the number of memory chunks of the device
+// is supported by its MPS server.
+func (d *mpsDevice) assertMemoryChunks() error {
+	maxClients := d.maxClients()
+	if d.Replicas > maxClients {
+		return fmt.Errorf("%w maximum allowed memory chunks exceeded: %d > %d; increase memoryChunkMB", errInvalidDevice, d.Replicas, maxClients)
+	}
+	return nil
+}
+
+// assertMemoryLimits checks whether the explicit pinned memory limits of all
+// replicas of the device fit into the memory of the device that remains after
+// the memory used by the MPS server is subtracted.
+func (d *mpsDevice) assertMemoryLimits(r *spec.ReplicatedResource) error {
+	var requestedMB uint64
+	for replica := 0; replica < d.Replicas; replica++ {
+		requestedMB += r.ReplicaMemoryLimitMB(replica)
+	}
+	if requestedMB == 0 || d.TotalMemory == 0 {
+		return nil
+	}
+	var availableMB uint64
+	if totalMemoryMB := d.TotalMemory / 1024 / 1024; totalMemoryMB > r.GetServerMemoryOverheadMB() {
+		availableMB = totalMemoryMB - r.GetServerMemoryOverheadMB()
+	}
+	if requestedMB > availableMB {
+		return fmt.Errorf("%w pinned memory limits exceed the available memory: %d MB > %d MB", errInvalidDevice, requestedMB, availableMB)
+	}
+	return nil
+}
+
 // maxClients returns the maximum number of clients supported by an MPS server.
 func (d *mpsDevice) maxClients() int {
 	if d.isAtLeastVolta() {
-		return 48
+		return MaxClients
 	}
 	return 16
 }
@@ -20,6 +20,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
 func TestDevice(t *testing.T) {
@@ -110,3 +114,55 @@ func TestDevice(t *testing.T) {
 		})
 	}
 }
+
+func TestAssertMemoryLimits(t *testing.T) {
+	limitMB := uint64(10240)
+	overheadMB := uint64(512)
+	device := &mpsDevice{TotalMemory: 40960 * 1024 * 1024, Replicas: 4}
+
+	testCases := []struct {
+		description string
+		resource    *spec.ReplicatedResource
+		expectedErr error
+	}{
+		{
+			description: "no explicit limits",
+		},
+		{
+			description: "limits fit into the available memory",
+			resource:    &spec.ReplicatedResource{Replicas: 4, ReplicaMemoryLimitsMB: []uint64{20480, 8192, 4096, 4096}},
+		},
+		{
+			description: "limits exceed the memory remaining after the overhead",
+			resource:    &spec.ReplicatedResource{Replicas: 4, MemoryLimitMB: &limitMB, ServerMemoryOverheadMB: &overheadMB},
+			expectedErr: errInvalidDevice,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.ErrorIs(t, device.assertMemoryLimits(tc.resource), tc.expectedErr)
+		})
+	}
+}
+
+func TestValidateDevicesMemoryChunks(t *testing.T) {
+	rrs := &spec.ReplicatedResources{Unit: spec.ReplicaUnitMemory}
+	r := &spec.ReplicatedResource{Name: "nvidia.com/gpu"}
+	devices := func(chunks int) rm.Devices {
+		return rm.Devices{
+			"GPU-0::0": {
+				Device:            pluginapi.Device{ID: "GPU-0::0"},
+				ComputeCapability: "9.0",
+				Replicas:          chunks,
+			},
+		}
+	}
+
+	require.NoError(t, ValidateDevices(rrs, r, devices(48)))
+	// An 80 GB GPU with 1 GB chunks would serve more clients than its MPS
+	// server supports.
+	err := ValidateDevices(rrs, r, devices(79))
+	require.ErrorIs(t, err, errInvalidDevice)
+	require.ErrorContains(t, err, "maximum allowed memory chunks exceeded: 79 > 48")
+}
@@ -18,6 +18,7 @@ package mps
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
@@ -30,6 +31,7 @@ import (
 
 type Manager interface {
 	Daemons() ([]*Daemon, error)
+	Reconcile([]*Daemon) ([]*Daemon, error)
 }
 
 type manager struct {
@@ -37,6 +39,15 @@ type manager struct {
 	nvmllib   nvml.Interface
 	devicelib device.Interface
 	config    *spec.Config
+	// root is the MPS root under which the daemons are created.
+	root Root
+	// selfTestTimeout is the timeout of the self-test of each daemon. The
+	// self-test is disabled if the timeout is 0.
+	selfTestTimeout time.Duration
+	// supervise indicates whether the daemons are restarted if they stop
+	// responding, up to maxRestarts times in a row.
+	supervise   bool
+	maxRestarts int
 }
 
 type nullManager struct{}
@@ -57,6 +68,7 @@ func New(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interf
 		infolib:   infolib,
 		nvmllib:   nvmllib,
 		devicelib: devicelib,
+		root:      ContainerRoot,
 	}
 	for _, opt := range opts {
 		opt(m)
@@ -75,6 +87,17 @@ func (m *manager) Daemons() ([]*Daemon, error) {
 	if err != nil {
 		return nil, err
 	}
+	var selfTest *selfTest
+	if m.selfTestTimeout > 0 {
+		selfTest, err = newSelfTest(m.selfTestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create MPS self-test: %w", err)
+		}
+	}
+	var supervisor *supervisor
+	if m.supervise {
+		supervisor = newSupervisor(m.maxRestarts)
+	}
 	var daemons []*Daemon
 	for _, resourceManager := range resourceManagers {
 		// We don't create daemons if there are no devices associated with the resource manager.
@@ -82,23 +105,54 @@ func (m *manager) Daemons() ([]*Daemon, error) {
 			klog.InfoS("No devices associated with resource", "resource", resourceManager.Resource())
 			continue
 		}
-		// Check if the resources are shared.
-		// TODO: We should add a more explicit check for MPS specifically
+		// Resources that are time-sliced or not shared at all do not require
+		// an MPS daemon.
+		if strategy := m.config.Sharing.StrategyForResource(resourceManager.Resource()); strategy != spec.SharingStrategyMPS {
+			klog.InfoS("Resource is not shared using MPS", "resource", resourceManager.Resource(), "strategy", strategy)
+			continue
+		}
 		if !rm.AnnotatedIDs(resourceManager.Devices().GetIDs()).AnyHasAnnotations() {
 			klog.InfoS("Resource is not shared", "resource", "resource", resourceManager.Resource())
 			continue
 		}
+		r := m.config.Sharing.MPS.ForResource(resourceManager.Resource())
+		if err := ValidateDevices(m.config.Sharing.MPS, r, resourceManager.Devices()); err != nil {
+			return nil, fmt.Errorf("invalid MPS configuration: %w", err)
+		}
 		// Check if MIG devices are included.
+		var hasMigDevices bool
 		for _, rmDevice := range resourceManager.Devices() {
 			if rmDevice.IsMigDevice() {
-				klog.Warning("MPS sharing is not supported for MIG devices; skipping daemon creation")
-				continue
-			}
-			if err := (*mpsDevice)(rmDevice).assertReplicas(); err != nil {
-				return nil, fmt.Errorf("invalid MPS configuration: %w", err)
+				hasMigDevices = true
 			}
 		}
-		daemon := NewDaemon(resourceManager, ContainerRoot)
+		if hasMigDevices && (r == nil || !r.PerMigDevice) {
+			klog.Warningf("MPS sharing of MIG devices requires perMigDevice; skipping daemon creation for %v", resourceManager.Resource())
+			continue
+		}
+		daemonOpts := []DaemonOption{
+			withSelfTest(selfTest),
+			withSupervisor(supervisor),
+			WithServerMemoryOverhead(r.GetServerMemoryOverheadMB()),
+			WithIdentity(m.config.Sharing.MPS.GetUserID(r), m.config.Sharing.MPS.GetGroupID(r)),
+		}
+		if r != nil {
+			daemonOpts = append(daemonOpts,
+				WithLogDirectory(r.LogDirectory),
+				WithPipeDirectory(r.PipeDirectory),
+				WithMemoryLimit(r.MemoryLimit),
+				WithMemoryLimitMB(r.MemoryLimitMB),
+				WithReplicaMemoryLimits(r.ReplicaMemoryLimitsMB),
+				WithMemoryChunk(m.config.Sharing.MPS.GetMemoryChunkMB()),
+				WithThreadLimit(r.GetThreadLimit()),
+				WithEnvPassthrough(r.EnvPassthrough),
+			)
+		}
+		if hasMigDevices {
+			daemons = append(daemons, NewMigDaemons(resourceManager, m.root, daemonOpts...)...)
+			continue
+		}
+		daemon := NewDaemon(resourceManager, m.root, daemonOpts...)
 		daemons = append(daemons, daemon)
 	}
 
@@ -0,0 +1,230 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/NVIDIA/k8s-device-plugin/pkg/mpsclient"
+)
+
+// Collector exposes the state of the MPS daemons as Prometheus metrics. The
+// daemons are queried through their control pipes when the metrics are
+// collected.
+type Collector struct {
+	sync.Mutex
+	daemons []*Daemon
+	// starts counts the number of times a daemon was started for each
+	// resource and MIG device, including the restarts by the supervisors of
+	// daemons that were replaced.
+	starts map[daemonKey]uint64
+
+	stats func(*Daemon) mpsclient.DaemonStats
+	alive func(pid int) bool
+
+	up                     *prometheus.Desc
+	failed                 *prometheus.Desc
+	restarts               *prometheus.Desc
+	servers                *prometheus.Desc
+	serverUp               *prometheus.Desc
+	clients                *prometheus.Desc
+	pinnedMemoryLimit      *prometheus.Desc
+	activeThreadPercentage *prometheus.Desc
+}
+
+type daemonKey struct {
+	resource  string
+	migDevice string
+}
+
+// NewCollector creates a collector for metrics with the specified namespace.
+func NewCollector(namespace string) *Collector {
+	labels := []string{"resource", "mig_device"}
+	return &Collector{
+		starts: make(map[daemonKey]uint64),
+		stats:  (*Daemon).Stats,
+		alive:  processAlive,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "daemon_up"),
+			"Whether the MPS control daemon responds to commands.",
+			labels, nil,
+		),
+		failed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "daemon_failed"),
+			"Whether the MPS control daemon was marked as failed after exceeding the maximum number of restarts.",
+			labels, nil,
+		),
+		restarts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "daemon_restarts_total"),
+			"Number of times the MPS control daemon was restarted.",
+			labels, nil,
+		),
+		servers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "servers"),
+			"Number of MPS servers started by the MPS control daemon.",
+			labels, nil,
+		),
+		serverUp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "server_up"),
+			"Whether the process of an MPS server is running.",
+			append(labels, "pid"), nil,
+		),
+		clients: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "clients"),
+			"Number of clients connected to the MPS servers of the MPS control daemon.",
+			labels, nil,
+		),
+		pinnedMemoryLimit: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pinned_device_memory_limit_bytes"),
+			"Default pinned device memory limit of the clients on a device.",
+			append(labels, "device"), nil,
+		),
+		activeThreadPercentage: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "active_thread_percentage"),
+			"Default active thread percentage of the clients.",
+			labels, nil,
+		),
+	}
+}
+
+// Update sets the daemons that are exposed by the collector.
+// This is called every time the daemons are (re)started or reconciled. A
+// daemon that replaces a previous daemon of the same resource and MIG device
+// is counted as a restart, in addition to the restarts by the supervisor of
+// each daemon.
+func (c *Collector) Update(daemons []*Daemon) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	previous := make(map[*Daemon]bool)
+	for _, d := range c.daemons {
+		previous[d] = true
+	}
+	current := make(map[*Daemon]bool)
+	for _, d := range daemons {
+		current[d] = true
+		if previous[d] {
+			continue
+		}
+		c.starts[keyOf(d)]++
+	}
+	for _, d := range c.daemons {
+		if !current[d] {
+			c.starts[keyOf(d)] += d.Restarts()
+		}
+	}
+	c.daemons = daemons
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.failed
+	ch <- c.restarts
+	ch <- c.servers
+	ch <- c.serverUp
+	ch <- c.clients
+	ch <- c.pinnedMemoryLimit
+	ch <- c.activeThreadPercentage
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	daemons := c.daemons
+	starts := make(map[daemonKey]uint64, len(c.starts))
+	for k, v := range c.starts {
+		starts[k] = v
+	}
+	c.Unlock()
+
+	for _, d := range daemons {
+		key := keyOf(d)
+		labels := []string{key.resource, key.migDevice}
+		ch <- prometheus.MustNewConstMetric(c.restarts, prometheus.CounterValue, float64(starts[key]-1+d.Restarts()), labels...)
+		var failed float64
+		if d.Failed() != nil {
+			failed = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.failed, prometheus.GaugeValue, failed, labels...)
+
+		stats := c.stats(d)
+		for _, index := range sortedKeys(stats.PinnedDeviceMemoryLimits) {
+			if limit, ok := parseMemoryLimit(stats.PinnedDeviceMemoryLimits[index]); ok {
+				ch <- prometheus.MustNewConstMetric(c.pinnedMemoryLimit, prometheus.GaugeValue, float64(limit), append(labels, index)...)
+			}
+		}
+		if percentage, err := strconv.Atoi(stats.ActiveThreadPercentage); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.activeThreadPercentage, prometheus.GaugeValue, float64(percentage), labels...)
+		}
+
+		// The servers and clients are unknown if the daemon does not respond.
+		if stats.Error != "" {
+			ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0, labels...)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1, labels...)
+		ch <- prometheus.MustNewConstMetric(c.servers, prometheus.GaugeValue, float64(len(stats.Servers)), labels...)
+		var clients int
+		for _, server := range stats.Servers {
+			clients += len(server.Clients)
+			var up float64
+			if c.alive(server.PID) {
+				up = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.serverUp, prometheus.GaugeValue, up, append(labels, strconv.Itoa(server.PID))...)
+		}
+		ch <- prometheus.MustNewConstMetric(c.clients, prometheus.GaugeValue, float64(clients), labels...)
+	}
+}
+
+func keyOf(d *Daemon) daemonKey {
+	return daemonKey{resource: string(d.rm.Resource()), migDevice: d.migDevice}
+}
+
+// parseMemoryLimit parses a pinned memory limit such as 1024M into bytes.
+func parseMemoryLimit(limit string) (uint64, bool) {
+	mb, err := strconv.ParseUint(strings.TrimSuffix(limit, "M"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return mb * 1024 * 1024, true
+}
+
+// processAlive returns whether a process with the specified PID exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/metrics"
+	"github.com/NVIDIA/k8s-device-plugin/pkg/mpsclient"
+)
+
+func TestCollector(t *testing.T) {
+	(*Collector)(nil).Update(nil)
+
+	gpu := NewDaemon(testResourceManager{resource: "nvidia.com/gpu"}, ContainerRoot)
+	shared := NewDaemon(testResourceManager{resource: "nvidia.com/gpu.shared"}, ContainerRoot)
+
+	collector := NewCollector("test")
+	collector.stats = func(d *Daemon) mpsclient.DaemonStats {
+		if d.rm.Resource() == "nvidia.com/gpu.shared" {
+			return mpsclient.DaemonStats{
+				ActiveThreadPercentage: "50",
+				Error:                  "error getting server list",
+			}
+		}
+		return mpsclient.DaemonStats{
+			ActiveThreadPercentage:   "25",
+			PinnedDeviceMemoryLimits: map[string]string{"0": "2560M", "1": "1024M"},
+			Servers: []mpsclient.Server{
+				{PID: 100, Clients: []int{101, 102}},
+				{PID: 200, Clients: []int{201}},
+			},
+		}
+	}
+	collector.alive = func(pid int) bool { return pid == 100 }
+
+	collector.Update([]*Daemon{gpu, shared})
+	// Restarts by the supervisor are counted for both the kept and the
+	// replaced daemon.
+	gpu.restarts.Add(2)
+	shared.restarts.Add(1)
+	// The unchanged daemon is kept while the other daemon is restarted.
+	restarted := NewDaemon(testResourceManager{resource: "nvidia.com/gpu.shared"}, ContainerRoot)
+	collector.Update([]*Daemon{gpu, restarted})
+
+	s := metrics.NewServer("localhost:0")
+	require.NoError(t, s.Register(collector))
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	expected := []string{
+		`test_daemon_up{mig_device="",resource="nvidia.com/gpu"} 1`,
+		`test_daemon_up{mig_device="",resource="nvidia.com/gpu.shared"} 0`,
+		`test_daemon_failed{mig_device="",resource="nvidia.com/gpu"} 0`,
+		`test_daemon_restarts_total{mig_device="",resource="nvidia.com/gpu"} 2`,
+		`test_daemon_restarts_total{mig_device="",resource="nvidia.com/gpu.shared"} 2`,
+		`test_servers{mig_device="",resource="nvidia.com/gpu"} 2`,
+		`test_server_up{mig_device="",pid="100",resource="nvidia.com/gpu"} 1`,
+		`test_server_up{mig_device="",pid="200",resource="nvidia.com/gpu"} 0`,
+		`test_clients{mig_device="",resource="nvidia.com/gpu"} 3`,
+		`test_pinned_device_memory_limit_bytes{device="0",mig_device="",resource="nvidia.com/gpu"} 2.68435456e+09`,
+		`test_pinned_device_memory_limit_bytes{device="1",mig_device="",resource="nvidia.com/gpu"} 1.073741824e+09`,
+		`test_active_thread_percentage{mig_device="",resource="nvidia.com/gpu"} 25`,
+		`test_active_thread_percentage{mig_device="",resource="nvidia.com/gpu.shared"} 50`,
+	}
+	for _, e := range expected {
+		require.Contains(t, w.Body.String(), e)
+	}
+	require.NotContains(t, w.Body.String(), `test_clients{mig_device="",resource="nvidia.com/gpu.shared"}`)
+}
@@ -17,6 +17,9 @@
 package mps
 
 import (
+	"path/filepath"
+	"time"
+
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 )
 
@@ -29,3 +32,144 @@ func WithConfig(config *spec.Config) Option {
 		m.config = config
 	}
 }
+
+// WithRoot sets the MPS root under which the daemons create their pipe and
+// log directories. By default ContainerRoot is used.
+func WithRoot(root Root) Option {
+	return func(m *manager) {
+		m.root = root
+	}
+}
+
+// DaemonOption defines a functional option for configuring an MPS daemon.
+type DaemonOption func(*Daemon)
+
+// WithLogDirectory overrides the log directory of the daemon.
+// A relative path is interpreted relative to the root of the daemon.
+func WithLogDirectory(dir string) DaemonOption {
+	return func(d *Daemon) {
+		if dir == "" {
+			return
+		}
+		if !filepath.IsAbs(dir) {
+			dir = d.root.Path(dir)
+		}
+		d.logDir = dir
+	}
+}
+
+// WithPipeDirectory overrides the pipe directory of the daemon. The directory
+// is relative to the root of the daemon.
+func WithPipeDirectory(dir string) DaemonOption {
+	return func(d *Daemon) {
+		d.pipeDir = dir
+	}
+}
+
+// WithServerMemoryOverhead sets the memory in MB used by the MPS server on
+// each device, which is excluded from the pinned memory limits of the clients.
+func WithServerMemoryOverhead(overheadMB uint64) DaemonOption {
+	return func(d *Daemon) {
+		d.memoryOverheadMB = overheadMB
+	}
+}
+
+// WithMemoryLimit sets the pinned memory limit of each client as a fraction of
+// the memory of a device. A nil limit splits the memory between the replicas.
+func WithMemoryLimit(limit *spec.Fraction) DaemonOption {
+	return func(d *Daemon) {
+		d.memoryLimit = limit
+	}
+}
+
+// WithMemoryLimitMB sets an explicit pinned memory limit in MB for each
+// client. A nil limit leaves the limit to WithMemoryLimit.
+func WithMemoryLimitMB(limitMB *uint64) DaemonOption {
+	return func(d *Daemon) {
+		d.memoryLimitMB = limitMB
+	}
+}
+
+// WithReplicaMemoryLimits sets an explicit pinned memory limit in MB for each
+// replica of a device. The limits are exposed to the clients that are
+// allocated the replicas.
+func WithReplicaMemoryLimits(limitsMB []uint64) DaemonOption {
+	return func(d *Daemon) {
+		d.replicaMemoryLimitsMB = limitsMB
+	}
+}
+
+// WithMemoryChunk sets the size in MB of each replica if the replicas are
+// chunks of the memory of a device. A size of 0 disables memory chunks.
+func WithMemoryChunk(chunkMB uint64) DaemonOption {
+	return func(d *Daemon) {
+		d.memoryChunkMB = chunkMB
+	}
+}
+
+// WithThreadLimit sets the active thread percentage of each client as a
+// fraction of the threads of a device. A nil limit splits the threads between
+// the replicas.
+func WithThreadLimit(limit *spec.Fraction) DaemonOption {
+	return func(d *Daemon) {
+		d.threadLimit = limit
+	}
+}
+
+// WithEnvPassthrough sets the environment variables that are propagated from
+// the environment of the process to the daemon.
+func WithEnvPassthrough(names []string) DaemonOption {
+	return func(d *Daemon) {
+		d.envPassthrough = names
+	}
+}
+
+// WithIdentity sets the user and group ID under which the MPS control process
+// and its servers are run. A nil ID keeps the ID of the current process.
+func WithIdentity(userID *int64, groupID *int64) DaemonOption {
+	return func(d *Daemon) {
+		d.userID = userID
+		d.groupID = groupID
+	}
+}
+
+// WithClientAffinity sets the policy used to assign clients to GPUs if the
+// daemon manages more than one GPU.
+func WithClientAffinity(policy spec.ClientAffinityPolicy) DaemonOption {
+	return func(d *Daemon) {
+		d.affinity = newClientAffinity(policy, d.rm.Devices())
+	}
+}
+
+// WithSelfTest enables running the bundled CUDA probe as a client of each
+// daemon before the daemon is considered started. A probe that does not
+// complete within the specified timeout fails.
+func WithSelfTest(timeout time.Duration) Option {
+	return func(m *manager) {
+		m.selfTestTimeout = timeout
+	}
+}
+
+// withSelfTest sets the self-test that is run once the daemon is started.
+func withSelfTest(s *selfTest) DaemonOption {
+	return func(d *Daemon) {
+		d.selfTest = s
+	}
+}
+
+// WithSupervision enables the supervision of each daemon. A daemon that stops
+// responding is restarted with an exponential backoff, and is marked as failed
+// once it was restarted more than maxRestarts times in a row.
+func WithSupervision(maxRestarts int) Option {
+	return func(m *manager) {
+		m.supervise = true
+		m.maxRestarts = maxRestarts
+	}
+}
+
+// withSupervisor sets the supervisor that restarts the daemon.
+func withSupervisor(s *supervisor) DaemonOption {
+	return func(d *Daemon) {
+		d.supervisor = s
+	}
+}
@@ -0,0 +1,185 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ProbeServer serves the liveness and readiness probes of the MPS control
+// daemons over HTTP:
+//
+//	GET /healthz  the supervisor of every supervised daemon checks it in time,
+//	              and the control pipe of every other daemon responds
+//	GET /readyz   the daemons are started and their control pipes respond
+//
+// A failing probe is reported with a 503 and the errors of the daemons. The
+// daemons that failed to start are only reported by the readiness probe, since
+// restarting the container does not help them and would disrupt the clients
+// of the other daemons. For the same reason, a supervised daemon that does
+// not respond is left to its supervisor and only fails the readiness probe.
+type ProbeServer struct {
+	address string
+
+	sync.Mutex
+	daemons []*Daemon
+	failed  []*Daemon
+	ready   bool
+
+	check func(*Daemon) error
+}
+
+// NewProbeServer creates a probe server that listens on the specified address.
+// A nil server is returned if the address is empty.
+func NewProbeServer(address string) *ProbeServer {
+	if address == "" {
+		return nil
+	}
+	return &ProbeServer{
+		address: address,
+		check: func(d *Daemon) error {
+			_, err := d.getServerList()
+			return err
+		},
+	}
+}
+
+// Update sets the daemons that are probed, the daemons that failed to start,
+// and whether the daemons are ready to accept clients. This is called every
+// time the daemons are (re)started or reconciled.
+func (s *ProbeServer) Update(daemons []*Daemon, failed []*Daemon, ready bool) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.daemons = daemons
+	s.failed = failed
+	s.ready = ready
+}
+
+// Handler returns the HTTP handler for the probes.
+func (s *ProbeServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		daemons, _, _ := s.get()
+		writeProbe(w, s.checkLive(daemons))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		daemons, failed, ready := s.get()
+		if !ready {
+			writeProbe(w, []string{"MPS daemons are not started"})
+			return
+		}
+		writeProbe(w, append(failedErrors(failed), s.checkAll(daemons)...))
+	})
+	return mux
+}
+
+// ListenAndServe serves the probes until the context is cancelled.
+func (s *ProbeServer) ListenAndServe(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", s.address, err)
+	}
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	klog.Infof("Serving probes on %v", s.address)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *ProbeServer) get() ([]*Daemon, []*Daemon, bool) {
+	s.Lock()
+	defer s.Unlock()
+	return s.daemons, s.failed, s.ready
+}
+
+// failedErrors returns the errors of the daemons that failed to start as
+// recorded when they were marked as failed.
+func failedErrors(failed []*Daemon) []string {
+	var errs []string
+	for _, d := range failed {
+		err := d.Failed()
+		if err == nil {
+			err = errors.New("failed to start")
+		}
+		errs = append(errs, fmt.Sprintf("%v: %v", d.key(), err))
+	}
+	return errs
+}
+
+// checkLive returns the errors of the supervised daemons whose supervisors
+// did not check them in time, and of the other daemons whose control pipes do
+// not respond.
+func (s *ProbeServer) checkLive(daemons []*Daemon) []string {
+	var errs []string
+	now := time.Now()
+	for _, d := range daemons {
+		err := d.supervisionError(now)
+		if d.supervisor == nil {
+			err = s.check(d)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", d.key(), err))
+		}
+	}
+	return errs
+}
+
+// checkAll returns the errors of the daemons whose control pipes do not
+// respond.
+func (s *ProbeServer) checkAll(daemons []*Daemon) []string {
+	var errs []string
+	for _, d := range daemons {
+		if err := s.check(d); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", d.key(), err))
+		}
+	}
+	return errs
+}
+
+func writeProbe(w http.ResponseWriter, errs []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(errs, "\n"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,139 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeServer(t *testing.T) {
+	require.Nil(t, NewProbeServer(""))
+
+	gpu := NewDaemon(testResourceManager{resource: "nvidia.com/gpu"}, ContainerRoot)
+	shared := NewDaemon(testResourceManager{resource: "nvidia.com/gpu.shared"}, ContainerRoot)
+	supervised := NewDaemon(testResourceManager{resource: "nvidia.com/gpu.supervised"}, ContainerRoot, withSupervisor(newSupervisor(DefaultMaxRestarts)))
+	supervised.expectSupervision(time.Hour)
+	stuck := NewDaemon(testResourceManager{resource: "nvidia.com/gpu.stuck"}, ContainerRoot, withSupervisor(newSupervisor(DefaultMaxRestarts)))
+	stuck.supervisedUntil.Store(time.Now().Add(-time.Minute).UnixNano())
+	unsupervised := NewDaemon(testResourceManager{resource: "nvidia.com/gpu.unsupervised"}, ContainerRoot, withSupervisor(newSupervisor(DefaultMaxRestarts)))
+
+	testCases := []struct {
+		description    string
+		daemons        []*Daemon
+		failed         []*Daemon
+		ready          bool
+		failing        *Daemon
+		expectedHealth int
+		expectedReady  int
+		expectedBody   string
+		// expectedHealthBody is only checked if set.
+		expectedHealthBody string
+	}{
+		{
+			description:    "no daemons are started yet",
+			expectedHealth: http.StatusOK,
+			expectedReady:  http.StatusServiceUnavailable,
+			expectedBody:   "MPS daemons are not started\n",
+		},
+		{
+			description:    "all daemons respond",
+			daemons:        []*Daemon{gpu, shared},
+			ready:          true,
+			expectedHealth: http.StatusOK,
+			expectedReady:  http.StatusOK,
+			expectedBody:   "ok\n",
+		},
+		{
+			description:    "a daemon does not respond",
+			daemons:        []*Daemon{gpu, shared},
+			ready:          true,
+			failing:        shared,
+			expectedHealth: http.StatusServiceUnavailable,
+			expectedReady:  http.StatusServiceUnavailable,
+			expectedBody:   "nvidia.com/gpu.shared: error getting server list\n",
+		},
+		{
+			description:    "a daemon failed to start",
+			daemons:        []*Daemon{gpu},
+			failed:         []*Daemon{shared},
+			ready:          true,
+			expectedHealth: http.StatusOK,
+			expectedReady:  http.StatusServiceUnavailable,
+			expectedBody:   "nvidia.com/gpu.shared: failed to start\n",
+		},
+		{
+			description:    "a supervised daemon does not respond",
+			daemons:        []*Daemon{gpu, supervised},
+			ready:          true,
+			failing:        supervised,
+			expectedHealth: http.StatusOK,
+			expectedReady:  http.StatusServiceUnavailable,
+			expectedBody:   "nvidia.com/gpu.supervised: error getting server list\n",
+		},
+		{
+			description:    "the supervisor of a daemon is stuck",
+			daemons:        []*Daemon{gpu, stuck},
+			ready:          true,
+			failing:        stuck,
+			expectedHealth: http.StatusServiceUnavailable,
+			expectedReady:  http.StatusServiceUnavailable,
+			expectedBody:   "nvidia.com/gpu.stuck: error getting server list\n",
+			expectedHealthBody: "nvidia.com/gpu.stuck: supervisor has not checked the daemon since " +
+				time.Unix(0, stuck.supervisedUntil.Load()).Add(-DefaultSupervisionInterval).Format(time.RFC3339) + "\n",
+		},
+		{
+			description:    "a supervised daemon is not being supervised",
+			daemons:        []*Daemon{gpu, unsupervised},
+			ready:          true,
+			failing:        unsupervised,
+			expectedHealth: http.StatusOK,
+			expectedReady:  http.StatusServiceUnavailable,
+			expectedBody:   "nvidia.com/gpu.unsupervised: error getting server list\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			s := NewProbeServer("localhost:0")
+			s.check = func(d *Daemon) error {
+				if d == tc.failing {
+					return errors.New("error getting server list")
+				}
+				return nil
+			}
+			s.Update(tc.daemons, tc.failed, tc.ready)
+
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			require.Equal(t, tc.expectedHealth, w.Code)
+			if tc.expectedHealthBody != "" {
+				require.Equal(t, tc.expectedHealthBody, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			require.Equal(t, tc.expectedReady, w.Code)
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,153 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"k8s.io/klog/v2"
+)
+
+// Reconcile starts and stops MPS daemons so that the running daemons match
+// the daemons required for the current config of the manager. Daemons that
+// are no longer required are stopped, missing daemons are started, and
+// daemons whose devices or limits have changed are restarted. Daemons that
+// are unchanged are kept running. The daemons that are running once the
+// reconciliation completes are returned, also if an error occurs.
+func (m *manager) Reconcile(running []*Daemon) ([]*Daemon, error) {
+	desired, err := m.Daemons()
+	if err != nil {
+		return running, err
+	}
+	return reconcileDaemons(running, desired)
+}
+
+// Reconcile stops all running daemons for a nullManager.
+func (m *nullManager) Reconcile(running []*Daemon) ([]*Daemon, error) {
+	return reconcileDaemons(running, nil)
+}
+
+// reconcileDaemons transitions the running daemons to the desired daemons.
+// All obsolete daemons are stopped before any daemons are started so that
+// devices that move between resources are released first.
+func reconcileDaemons(running []*Daemon, desired []*Daemon) ([]*Daemon, error) {
+	keep, stop, start := diffDaemons(running, desired)
+
+	var errs []error
+	for _, d := range stop {
+		klog.InfoS("Stopping MPS daemon", "resource", d.rm.Resource(), "migDevice", d.migDevice, "reason", transition(d, desired, "removed"))
+		if err := d.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop MPS daemon for %v: %w", d.key(), err))
+			keep = append(keep, d)
+		}
+	}
+	if len(errs) > 0 {
+		return keep, errors.Join(errs...)
+	}
+	for _, d := range start {
+		klog.InfoS("Starting MPS daemon", "resource", d.rm.Resource(), "migDevice", d.migDevice, "reason", transition(d, running, "added"))
+		if err := d.Start(); err != nil {
+			return keep, fmt.Errorf("failed to start MPS daemon for %v: %w", d.key(), err)
+		}
+		keep = append(keep, d)
+	}
+	return keep, nil
+}
+
+// diffDaemons compares the running and desired daemons. It returns the
+// running daemons to keep, the running daemons to stop, and the desired
+// daemons to start.
+func diffDaemons(running []*Daemon, desired []*Daemon) ([]*Daemon, []*Daemon, []*Daemon) {
+	byKey := make(map[string]*Daemon)
+	for _, d := range desired {
+		byKey[d.key()] = d
+	}
+
+	var keep, stop []*Daemon
+	unchanged := make(map[string]bool)
+	for _, d := range running {
+		if other, exists := byKey[d.key()]; exists && d.equivalent(other) {
+			klog.V(4).InfoS("MPS daemon is unchanged", "resource", d.rm.Resource(), "migDevice", d.migDevice)
+			keep = append(keep, d)
+			unchanged[d.key()] = true
+			continue
+		}
+		stop = append(stop, d)
+	}
+
+	var start []*Daemon
+	for _, d := range desired {
+		if !unchanged[d.key()] {
+			start = append(start, d)
+		}
+	}
+	return keep, stop, start
+}
+
+// key identifies the daemon across reconciliations.
+func (d *Daemon) key() string {
+	if d.migDevice != "" {
+		return string(d.rm.Resource()) + "/" + d.migDevice
+	}
+	return string(d.rm.Resource())
+}
+
+// transition describes why the daemon is started or stopped. A daemon that
+// also exists on the other side of the reconciliation is reshaped, otherwise
+// the specified reason applies.
+func transition(d *Daemon, others []*Daemon, reason string) string {
+	for _, other := range others {
+		if other.key() == d.key() {
+			return "reshaped"
+		}
+	}
+	return reason
+}
+
+// equivalent returns whether the daemons manage the same replicas with the
+// same settings, in which case a running daemon does not need to be restarted.
+func (d *Daemon) equivalent(other *Daemon) bool {
+	ids := d.Devices().GetIDs()
+	otherIDs := other.Devices().GetIDs()
+	slices.Sort(ids)
+	slices.Sort(otherIDs)
+
+	return d.key() == other.key() &&
+		slices.Equal(ids, otherIDs) &&
+		d.LogDir() == other.LogDir() &&
+		d.pipeDir == other.pipeDir &&
+		slices.Equal(d.envPassthrough, other.envPassthrough) &&
+		equalIDs(d.userID, other.userID) &&
+		equalIDs(d.groupID, other.groupID) &&
+		d.memoryOverheadMB == other.memoryOverheadMB &&
+		d.activeThreadPercentage() == other.activeThreadPercentage() &&
+		maps.Equal(d.perDevicePinnedDeviceMemoryLimits(), other.perDevicePinnedDeviceMemoryLimits()) &&
+		(d.selfTest == nil) == (other.selfTest == nil) &&
+		d.supervisor.equivalent(other.supervisor)
+}
+
+// equalIDs returns whether the specified user or group IDs are both unset or
+// are set to the same value.
+func equalIDs(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
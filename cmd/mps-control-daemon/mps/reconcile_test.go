@@ -0,0 +1,182 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestDiffDaemons(t *testing.T) {
+	newTestDaemon := func(resource spec.ResourceName, replicas int, opts ...DaemonOption) *Daemon {
+		devices := newReplicatedDevices(map[string]string{"0": "GPU-0", "1": "GPU-1"}, replicas)
+		for _, device := range devices {
+			device.TotalMemory = 40960 * 1024 * 1024
+		}
+		return NewDaemon(testResourceManager{resource: resource, devices: devices}, ContainerRoot, opts...)
+	}
+
+	gpu := newTestDaemon("nvidia.com/gpu", 2)
+	shared := newTestDaemon("nvidia.com/gpu.shared", 4)
+	threadLimit, err := spec.NewFraction("25%")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		description   string
+		running       []*Daemon
+		desired       []*Daemon
+		expectedKeep  []*Daemon
+		expectedStop  []*Daemon
+		expectedStart []*Daemon
+	}{
+		{
+			description:  "unchanged daemons are kept",
+			running:      []*Daemon{gpu, shared},
+			desired:      []*Daemon{newTestDaemon("nvidia.com/gpu", 2), newTestDaemon("nvidia.com/gpu.shared", 4)},
+			expectedKeep: []*Daemon{gpu, shared},
+		},
+		{
+			description:   "added resources are started",
+			running:       []*Daemon{gpu},
+			desired:       []*Daemon{newTestDaemon("nvidia.com/gpu", 2), shared},
+			expectedKeep:  []*Daemon{gpu},
+			expectedStart: []*Daemon{shared},
+		},
+		{
+			description:  "removed resources are stopped",
+			running:      []*Daemon{gpu, shared},
+			desired:      []*Daemon{newTestDaemon("nvidia.com/gpu", 2)},
+			expectedKeep: []*Daemon{gpu},
+			expectedStop: []*Daemon{shared},
+		},
+		{
+			description:   "changed replica counts are reshaped",
+			running:       []*Daemon{gpu},
+			desired:       []*Daemon{shared, newTestDaemon("nvidia.com/gpu", 3)},
+			expectedStop:  []*Daemon{gpu},
+			expectedStart: []*Daemon{shared, newTestDaemon("nvidia.com/gpu", 3)},
+		},
+		{
+			description:   "changed limits are reshaped",
+			running:       []*Daemon{gpu},
+			desired:       []*Daemon{newTestDaemon("nvidia.com/gpu", 2, WithThreadLimit(&threadLimit))},
+			expectedStop:  []*Daemon{gpu},
+			expectedStart: []*Daemon{newTestDaemon("nvidia.com/gpu", 2, WithThreadLimit(&threadLimit))},
+		},
+		{
+			description:  "all daemons are stopped if none are desired",
+			running:      []*Daemon{gpu, shared},
+			expectedStop: []*Daemon{gpu, shared},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			keep, stop, start := diffDaemons(tc.running, tc.desired)
+			require.Equal(t, tc.expectedKeep, keep)
+			require.Equal(t, tc.expectedStop, stop)
+			require.Equal(t, tc.expectedStart, start)
+		})
+	}
+}
+
+func TestDaemonEquivalent(t *testing.T) {
+	newTestDaemon := func(opts ...DaemonOption) *Daemon {
+		devices := newReplicatedDevices(map[string]string{"0": "GPU-0"}, 2)
+		for _, device := range devices {
+			device.TotalMemory = 40960 * 1024 * 1024
+		}
+		return NewDaemon(testResourceManager{resource: "nvidia.com/gpu", devices: devices}, ContainerRoot, opts...)
+	}
+	id := func(id int64) *int64 {
+		return &id
+	}
+	memoryLimit, err := spec.NewFraction("25%")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		description string
+		running     []DaemonOption
+		desired     []DaemonOption
+		expected    bool
+	}{
+		{
+			description: "same settings",
+			running:     []DaemonOption{WithEnvPassthrough([]string{"FOO"}), WithIdentity(id(1000), id(1000))},
+			desired:     []DaemonOption{WithEnvPassthrough([]string{"FOO"}), WithIdentity(id(1000), id(1000))},
+			expected:    true,
+		},
+		{
+			description: "log directory",
+			desired:     []DaemonOption{WithLogDirectory("/var/log/mps")},
+		},
+		{
+			description: "pipe directory",
+			desired:     []DaemonOption{WithPipeDirectory("pipes")},
+		},
+		{
+			description: "env passthrough",
+			running:     []DaemonOption{WithEnvPassthrough([]string{"FOO"})},
+			desired:     []DaemonOption{WithEnvPassthrough([]string{"FOO", "BAR"})},
+		},
+		{
+			description: "user ID",
+			running:     []DaemonOption{WithIdentity(id(1000), nil)},
+			desired:     []DaemonOption{WithIdentity(id(1001), nil)},
+		},
+		{
+			description: "group ID",
+			desired:     []DaemonOption{WithIdentity(nil, id(1000))},
+		},
+		{
+			description: "memory overhead",
+			running:     []DaemonOption{WithServerMemoryOverhead(0)},
+			desired:     []DaemonOption{WithServerMemoryOverhead(512)},
+		},
+		{
+			description: "memory limit",
+			desired:     []DaemonOption{WithMemoryLimit(&memoryLimit)},
+		},
+		{
+			description: "supervision enabled",
+			desired:     []DaemonOption{withSupervisor(newSupervisor(3))},
+		},
+		{
+			description: "supervisor max restarts",
+			running:     []DaemonOption{withSupervisor(newSupervisor(3))},
+			desired:     []DaemonOption{withSupervisor(newSupervisor(5))},
+		},
+		{
+			description: "same supervisor settings",
+			running:     []DaemonOption{withSupervisor(newSupervisor(3))},
+			desired:     []DaemonOption{withSupervisor(newSupervisor(3))},
+			expected:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			running := newTestDaemon(tc.running...)
+			desired := newTestDaemon(tc.desired...)
+			require.Equal(t, tc.expected, running.equivalent(desired))
+			require.Equal(t, tc.expected, desired.equivalent(running))
+		})
+	}
+}
@@ -23,7 +23,9 @@ import (
 )
 
 const (
-	ContainerRoot = Root("/mps")
+	// ContainerRoot is the default path at which the MPS root on the host is
+	// mounted in the containers of the device plugin and MPS control daemon.
+	ContainerRoot = Root(spec.DefaultMpsContainerRoot)
 )
 
 // Root represents an MPS root.
@@ -41,6 +43,16 @@ func (r Root) PipeDir(resourceName spec.ResourceName) string {
 	return r.Path(string(resourceName), "pipe")
 }
 
+// MigLogDir returns the log dir of the daemon for the specified MIG device of a resource.
+func (r Root) MigLogDir(resourceName spec.ResourceName, uuid string) string {
+	return r.Path(string(resourceName), uuid, "log")
+}
+
+// MigPipeDir returns the pipe dir of the daemon for the specified MIG device of a resource.
+func (r Root) MigPipeDir(resourceName spec.ResourceName, uuid string) string {
+	return r.Path(string(resourceName), uuid, "pipe")
+}
+
 // ShmDir returns the shm dir associated with the root.
 // Note that the shm dir is the same for all resources.
 func (r Root) ShmDir(resourceName spec.ResourceName) string {
@@ -52,6 +64,21 @@ func (r Root) startedFile(resourceName spec.ResourceName) string {
 	return r.Path(string(resourceName), ".started")
 }
 
+// migStartedFile returns the .started file name of the daemon for the specified MIG device of a resource.
+func (r Root) migStartedFile(resourceName spec.ResourceName, uuid string) string {
+	return r.Path(string(resourceName), uuid, ".started")
+}
+
+// failedFile returns the per-resource .failed file name for the specified root.
+func (r Root) failedFile(resourceName spec.ResourceName) string {
+	return r.Path(string(resourceName), ".failed")
+}
+
+// migFailedFile returns the .failed file name of the daemon for the specified MIG device of a resource.
+func (r Root) migFailedFile(resourceName spec.ResourceName, uuid string) string {
+	return r.Path(string(resourceName), uuid, ".failed")
+}
+
 // Path returns a path relative to the MPS root.
 func (r Root) Path(parts ...string) string {
 	pathparts := append([]string{string(r)}, parts...)
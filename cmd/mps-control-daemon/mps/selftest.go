@@ -0,0 +1,99 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// SelfTestCommand is the name of the command that runs the bundled CUDA probe.
+const SelfTestCommand = "mps-self-test"
+
+// selfTest runs a CUDA probe as a client of an MPS daemon to verify that the
+// daemon is functional before it is considered started.
+type selfTest struct {
+	command []string
+	timeout time.Duration
+}
+
+// newSelfTest creates a self-test that runs the bundled probe of the current
+// executable.
+func newSelfTest(timeout time.Duration) (*selfTest, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine executable: %w", err)
+	}
+	return &selfTest{
+		command: []string{executable, SelfTestCommand},
+		timeout: timeout,
+	}, nil
+}
+
+// run runs the probe on each device of the daemon and checks that an MPS
+// server was started to serve the probe.
+func (s *selfTest) run(d *Daemon) error {
+	if s == nil {
+		return nil
+	}
+	for _, uuid := range d.Devices().GetUUIDs() {
+		if err := s.probe(d.Envvars(), d.credential(), uuid); err != nil {
+			return err
+		}
+	}
+	servers, err := d.getServerList()
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("no MPS server was started for the probe")
+	}
+	klog.InfoS("MPS self-test succeeded", "resource", d.rm.Resource(), "servers", servers)
+	return nil
+}
+
+// probe runs the probe as an MPS client on the specified device. The probe is
+// run under the specified credential, since an MPS server only serves clients
+// of the same user.
+func (s *selfTest) probe(env envvars, credential *syscall.Credential, uuid string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Env = append(os.Environ(), env.toSlice()...)
+	cmd.Env = append(cmd.Env, "CUDA_VISIBLE_DEVICES="+uuid)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	// Do not wait for processes started by the probe to release its output
+	// once it has been killed.
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("probe on device %v timed out after %v", uuid, s.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("probe on device %v failed: %w: %s", uuid, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTestProbe(t *testing.T) {
+	testCases := []struct {
+		description   string
+		script        string
+		timeout       time.Duration
+		expectedError string
+	}{
+		{
+			description: "probe runs with the daemon environment",
+			script:      `test "$CUDA_MPS_PIPE_DIRECTORY" = /mps/pipe && test "$CUDA_VISIBLE_DEVICES" = GPU-0`,
+			timeout:     time.Minute,
+		},
+		{
+			description:   "probe failure includes the output",
+			script:        `echo "failed to create context" >&2; exit 1`,
+			timeout:       time.Minute,
+			expectedError: "probe on device GPU-0 failed: exit status 1: failed to create context",
+		},
+		{
+			description:   "probe times out",
+			script:        `sleep 10`,
+			timeout:       100 * time.Millisecond,
+			expectedError: "probe on device GPU-0 timed out after 100ms",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			s := &selfTest{
+				command: []string{"sh", "-c", tc.script},
+				timeout: tc.timeout,
+			}
+			err := s.probe(envvars{"CUDA_MPS_PIPE_DIRECTORY": "/mps/pipe"}, nil, "GPU-0")
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSelfTestDisabled(t *testing.T) {
+	var s *selfTest
+	require.NoError(t, s.run(nil))
+}
@@ -0,0 +1,195 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultSupervisionInterval is the interval at which supervised daemons
+	// are checked.
+	DefaultSupervisionInterval = 10 * time.Second
+	// DefaultMaxRestarts is the default number of consecutive restarts after
+	// which a daemon is considered failed.
+	DefaultMaxRestarts = 5
+
+	initialRestartBackoff = time.Second
+	maxRestartBackoff     = 5 * time.Minute
+)
+
+// supervisor restarts a daemon whose MPS control process no longer responds.
+// A daemon that is restarted more than maxRestarts times in a row without
+// becoming healthy is marked as failed, which causes the device plugin to
+// mark its devices as unhealthy.
+type supervisor struct {
+	interval       time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxRestarts    int
+
+	check   func(*Daemon) error
+	restart func(*Daemon) error
+}
+
+func newSupervisor(maxRestarts int) *supervisor {
+	return &supervisor{
+		interval:       DefaultSupervisionInterval,
+		initialBackoff: initialRestartBackoff,
+		maxBackoff:     maxRestartBackoff,
+		maxRestarts:    maxRestarts,
+		check:          (*Daemon).AssertHealthy,
+		restart:        (*Daemon).restart,
+	}
+}
+
+// equivalent returns whether the supervisors restart daemons in the same way.
+// Supervisors that are both nil are equivalent.
+func (s *supervisor) equivalent(other *supervisor) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.interval == other.interval &&
+		s.initialBackoff == other.initialBackoff &&
+		s.maxBackoff == other.maxBackoff &&
+		s.maxRestarts == other.maxRestarts
+}
+
+// Supervise checks the daemon periodically and restarts it with an
+// exponential backoff if it does not respond, until the stop channel is
+// closed or the daemon is marked as failed. It returns immediately if the
+// daemon is not supervised.
+func (d *Daemon) Supervise(stop <-chan struct{}) {
+	s := d.supervisor
+	if s == nil {
+		return
+	}
+
+	defer d.supervisedUntil.Store(0)
+	restarts := 0
+	backoff := s.initialBackoff
+	for {
+		d.expectSupervision(s.interval)
+		select {
+		case <-stop:
+			return
+		case <-time.After(s.interval):
+		}
+
+		err := s.check(d)
+		if err == nil {
+			restarts = 0
+			backoff = s.initialBackoff
+			continue
+		}
+		if restarts >= s.maxRestarts {
+			klog.ErrorS(err, "MPS control daemon exceeded the maximum number of restarts; marking it as failed", "resource", d.rm.Resource(), "migDevice", d.migDevice, "restarts", restarts)
+			if err := d.markFailed(err); err != nil {
+				klog.ErrorS(err, "Failed to mark MPS control daemon as failed", "resource", d.rm.Resource(), "migDevice", d.migDevice)
+			}
+			return
+		}
+
+		klog.ErrorS(err, "MPS control daemon is not responding; restarting", "resource", d.rm.Resource(), "migDevice", d.migDevice, "backoff", backoff)
+		d.expectSupervision(backoff)
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		restarts++
+		d.restarts.Add(1)
+		if err := s.restart(d); err != nil {
+			klog.ErrorS(err, "Failed to restart MPS control daemon", "resource", d.rm.Resource(), "migDevice", d.migDevice)
+		}
+		backoff = min(2*backoff, s.maxBackoff)
+	}
+}
+
+// expectSupervision records that the supervisor checks the daemon again after
+// the specified wait. The supervisor is given an additional interval, and the
+// time of the self-test if any, to check or restart the daemon.
+func (d *Daemon) expectSupervision(wait time.Duration) {
+	grace := d.supervisor.interval
+	if d.selfTest != nil {
+		grace += d.selfTest.timeout
+	}
+	d.supervisedUntil.Store(time.Now().Add(wait + grace).UnixNano())
+}
+
+// supervisionError returns an error if the supervisor of the daemon did not
+// check the daemon in time, e.g. because it is stuck checking or restarting
+// it. No error is returned while the daemon is not being supervised, e.g.
+// while it is reconciled or after it was marked as failed.
+func (d *Daemon) supervisionError(now time.Time) error {
+	until := d.supervisedUntil.Load()
+	if until == 0 || now.UnixNano() <= until {
+		return nil
+	}
+	return fmt.Errorf("supervisor has not checked the daemon since %v", time.Unix(0, until).Add(-d.supervisor.interval).Format(time.RFC3339))
+}
+
+// Restarts returns the number of times the daemon was restarted by its
+// supervisor.
+func (d *Daemon) Restarts() uint64 {
+	return d.restarts.Load()
+}
+
+// restart starts the MPS control process of the daemon again. The previous
+// process is asked to quit in case it is still running but unresponsive.
+func (d *Daemon) restart() error {
+	_, _ = d.EchoPipeToControl("quit")
+	if d.logTailer != nil {
+		_ = d.logTailer.Stop()
+	}
+	return d.Start()
+}
+
+// markFailed records that the daemon failed so that the device plugin stops
+// advertising its devices as healthy.
+func (d *Daemon) markFailed(cause error) error {
+	if err := os.Remove(d.startedFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove started file: %w", err)
+	}
+	return os.WriteFile(d.failedFile(), []byte(cause.Error()), 0644)
+}
+
+// Failed returns the error that caused the daemon to be marked as failed by
+// its supervisor, or nil if the daemon has not failed.
+func (d *Daemon) Failed() error {
+	contents, err := os.ReadFile(d.failedFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read failed file: %w", err)
+	}
+	return errors.New(strings.TrimSpace(string(contents)))
+}
+
+func (d *Daemon) failedFile() string {
+	if d.migDevice != "" {
+		return d.root.migFailedFile(d.rm.Resource(), d.migDevice)
+	}
+	return d.root.failedFile(d.rm.Resource())
+}
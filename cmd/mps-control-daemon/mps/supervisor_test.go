@@ -0,0 +1,103 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervise(t *testing.T) {
+	testCases := []struct {
+		description      string
+		maxRestarts      int
+		checks           []error
+		expectedRestarts uint64
+		expectedFailed   bool
+	}{
+		{
+			description:      "daemon recovers after a restart",
+			maxRestarts:      1,
+			checks:           []error{errors.New("no pipe"), nil, errors.New("no pipe"), nil},
+			expectedRestarts: 2,
+		},
+		{
+			description:      "daemon fails after the maximum number of restarts",
+			maxRestarts:      2,
+			checks:           []error{errors.New("no pipe"), errors.New("no pipe"), errors.New("no pipe")},
+			expectedRestarts: 2,
+			expectedFailed:   true,
+		},
+		{
+			description:    "daemon fails without restarts",
+			checks:         []error{errors.New("no pipe")},
+			expectedFailed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			root := Root(t.TempDir())
+			require.NoError(t, os.MkdirAll(root.Path("nvidia.com/gpu"), 0755))
+			d := NewDaemon(testResourceManager{}, root)
+
+			stop := make(chan struct{})
+			checks := tc.checks
+			d.supervisor = &supervisor{
+				interval:       time.Millisecond,
+				initialBackoff: time.Millisecond,
+				maxBackoff:     3 * time.Millisecond,
+				maxRestarts:    tc.maxRestarts,
+				check: func(d *Daemon) error {
+					require.NotZero(t, d.supervisedUntil.Load())
+					if len(checks) == 0 {
+						close(stop)
+						return nil
+					}
+					err := checks[0]
+					checks = checks[1:]
+					return err
+				},
+				restart: func(*Daemon) error { return nil },
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				d.Supervise(stop)
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("supervision did not end")
+			}
+
+			require.Equal(t, tc.expectedRestarts, d.Restarts())
+			require.Zero(t, d.supervisedUntil.Load())
+			require.NoError(t, d.supervisionError(time.Now()))
+			if tc.expectedFailed {
+				require.EqualError(t, d.Failed(), "no pipe")
+			} else {
+				require.NoError(t, d.Failed())
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package selftest
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/cuda"
+)
+
+// NewCommand constructs the command that runs the bundled CUDA probe.
+// The command is run by the MPS control daemon as an MPS client with the
+// environment of the daemon under test and is not intended to be run directly.
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:   mps.SelfTestCommand,
+		Usage:  "Run a CUDA probe as a client of the MPS daemon specified by CUDA_MPS_PIPE_DIRECTORY",
+		Hidden: true,
+		Action: func(c *cli.Context) error {
+			return probe()
+		},
+	}
+}
+
+// probe initializes CUDA and creates a context on the first visible device.
+// For an MPS client, creating the context connects to the MPS control daemon
+// through its pipe and causes it to spawn an MPS server.
+func probe() error {
+	// A CUDA context is bound to the thread that created it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if r := cuda.Init(); r != cuda.SUCCESS {
+		return fmt.Errorf("failed to initialize CUDA: %v", r)
+	}
+	defer cuda.Shutdown()
+
+	count, r := cuda.DeviceGetCount()
+	if r != cuda.SUCCESS {
+		return fmt.Errorf("failed to get device count: %v", r)
+	}
+	if count == 0 {
+		return fmt.Errorf("no CUDA devices are visible")
+	}
+
+	device, r := cuda.DeviceGet(0)
+	if r != cuda.SUCCESS {
+		return fmt.Errorf("failed to get device: %v", r)
+	}
+	ctx, r := cuda.CtxCreate(0, device)
+	if r != cuda.SUCCESS {
+		return fmt.Errorf("failed to create context: %v", r)
+	}
+	if r := ctx.Destroy(); r != cuda.SUCCESS {
+		return fmt.Errorf("failed to destroy context: %v", r)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	gfd "github.com/NVIDIA/k8s-device-plugin/cmd/gpu-feature-discovery/app"
+	mps "github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/app"
+	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
+)
+
+const (
+	subsystemDevicePlugin     = "device-plugin"
+	subsystemFeatureDiscovery = "gpu-feature-discovery"
+	subsystemMPSControlDaemon = "mps-control-daemon"
+
+	// subsystemRestartDelay is the time to wait before restarting a failed subsystem.
+	subsystemRestartDelay = 30 * time.Second
+)
+
+// subsystem is a component that is run by the all-in-one command. Each
+// subsystem is run as a separate application in its own goroutine.
+type subsystem struct {
+	name   string
+	newApp func() *cli.App
+	args   []string
+	// signals receives the OS signals dispatched to the subsystem.
+	signals chan os.Signal
+}
+
+// newAllInOneCommand constructs the all-in-one command.
+//
+// The subsystems are configured through the same environment variables (and
+// config file) as their standalone binaries and thus share their config. As
+// the subsystems run in the same process, they share a single NVML library
+// instance, and the OS signals are watched once and dispatched to each
+// subsystem.
+func newAllInOneCommand() *cli.Command {
+	var subsystems cli.StringSlice
+	var mpsMetricsAddress string
+	return &cli.Command{
+		Name:  "all-in-one",
+		Usage: "Run the device plugin, GPU Feature Discovery, and the MPS control daemon in a single process",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:        "subsystems",
+				Value:       cli.NewStringSlice(subsystemDevicePlugin, subsystemFeatureDiscovery, subsystemMPSControlDaemon),
+				Usage:       "the subsystems to run:\n\t\t[device-plugin | gpu-feature-discovery | mps-control-daemon]",
+				Destination: &subsystems,
+				EnvVars:     []string{"ALL_IN_ONE_SUBSYSTEMS"},
+			},
+			&cli.StringFlag{
+				Name:        "mps-metrics-address",
+				Usage:       "the address on which the Prometheus metrics of the MPS control daemon are served; an empty address disables the metrics",
+				Destination: &mpsMetricsAddress,
+				EnvVars:     []string{"MPS_METRICS_ADDRESS"},
+			},
+		},
+		Action: func(c *cli.Context) error {
+			selected, err := newSubsystems(subsystems.Value(), mpsMetricsAddress, nvml.New())
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithCancel(c.Context)
+			defer cancel()
+			sigs := watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+			go dispatchSignals(ctx, sigs, selected, cancel)
+			runSubsystems(ctx, selected, subsystemRestartDelay)
+			return nil
+		},
+	}
+}
+
+// newSubsystems returns the subsystems with the specified names. The
+// subsystems share the specified NVML library.
+func newSubsystems(names []string, mpsMetricsAddress string, nvmllib nvml.Interface) ([]subsystem, error) {
+	var subsystems []subsystem
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		sigs := make(chan os.Signal, 1)
+		switch name {
+		case subsystemDevicePlugin:
+			subsystems = append(subsystems, subsystem{
+				name:    name,
+				newApp:  func() *cli.App { return newApp(withNvmlLib(nvmllib), withSignals(sigs)) },
+				signals: sigs,
+			})
+		case subsystemFeatureDiscovery:
+			subsystems = append(subsystems, subsystem{
+				name:    name,
+				newApp:  func() *cli.App { return gfd.NewApp(gfd.WithNvmlLib(nvmllib), gfd.WithSignals(sigs)) },
+				signals: sigs,
+			})
+		case subsystemMPSControlDaemon:
+			// The metrics address is always passed since METRICS_ADDRESS
+			// configures the metrics of the device plugin and the daemon
+			// would otherwise fail to listen on the same address.
+			subsystems = append(subsystems, subsystem{
+				name:    name,
+				newApp:  func() *cli.App { return mps.NewApp(mps.WithNvmlLib(nvmllib), mps.WithSignals(sigs)) },
+				args:    []string{"--metrics-address=" + mpsMetricsAddress},
+				signals: sigs,
+			})
+		default:
+			return nil, fmt.Errorf("unknown subsystem: %v", name)
+		}
+	}
+	if len(subsystems) == 0 {
+		return nil, fmt.Errorf("no subsystems specified")
+	}
+	return subsystems, nil
+}
+
+// dispatchSignals forwards the OS signals to each subsystem until the context
+// is cancelled. A signal is dropped for a subsystem that has not processed the
+// previous signal yet. Signals other than SIGHUP shut down the subsystems, so
+// the context is cancelled to prevent them from being restarted.
+func dispatchSignals(ctx context.Context, sigs <-chan os.Signal, subsystems []subsystem, cancel func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-sigs:
+			for _, ss := range subsystems {
+				select {
+				case ss.signals <- s:
+				default:
+					klog.Warningf("Dropping signal %v for subsystem %v", s, ss.name)
+				}
+			}
+			if s != syscall.SIGHUP {
+				cancel()
+			}
+		}
+	}
+}
+
+// runSubsystems runs the subsystems until they have all exited. A subsystem
+// that fails is restarted after the restart delay until the context is
+// cancelled; a subsystem that exits without an error is not restarted.
+func runSubsystems(ctx context.Context, subsystems []subsystem, restartDelay time.Duration) {
+	var wg sync.WaitGroup
+	for _, s := range subsystems {
+		wg.Add(1)
+		go func(s subsystem) {
+			defer wg.Done()
+			s.supervise(ctx, restartDelay)
+		}(s)
+	}
+	wg.Wait()
+}
+
+func (s subsystem) supervise(ctx context.Context, restartDelay time.Duration) {
+	for {
+		klog.Infof("Starting subsystem %v", s.name)
+		err := s.run(ctx)
+		if err == nil {
+			klog.Infof("Subsystem %v exited", s.name)
+			return
+		}
+		klog.Errorf("Subsystem %v failed: %v; restarting in %v", s.name, err, restartDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+// run runs the subsystem once. A panic of the subsystem is returned as an error
+// so that it does not bring down the other subsystems.
+func (s subsystem) run(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.newApp().RunContext(ctx, append([]string{s.name}, s.args...))
+}
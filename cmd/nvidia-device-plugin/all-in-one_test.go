@@ -0,0 +1,148 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestNewSubsystems(t *testing.T) {
+	testCases := []struct {
+		description   string
+		names         []string
+		expectedNames []string
+		expectedError bool
+	}{
+		{
+			description:   "all subsystems",
+			names:         []string{subsystemDevicePlugin, subsystemFeatureDiscovery, subsystemMPSControlDaemon},
+			expectedNames: []string{subsystemDevicePlugin, subsystemFeatureDiscovery, subsystemMPSControlDaemon},
+		},
+		{
+			description:   "duplicate subsystems are ignored",
+			names:         []string{subsystemFeatureDiscovery, subsystemFeatureDiscovery},
+			expectedNames: []string{subsystemFeatureDiscovery},
+		},
+		{
+			description:   "unknown subsystem",
+			names:         []string{"unknown"},
+			expectedError: true,
+		},
+		{
+			description:   "no subsystems",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			subsystems, err := newSubsystems(tc.names, ":9401", nil)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, s := range subsystems {
+				names = append(names, s.name)
+			}
+			require.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestRunSubsystems(t *testing.T) {
+	runs := make(map[string]int)
+	newTestApp := func(name string, action func(run int) error) func() *cli.App {
+		return func() *cli.App {
+			c := cli.NewApp()
+			c.Action = func(*cli.Context) error {
+				runs[name]++
+				return action(runs[name])
+			}
+			return c
+		}
+	}
+
+	subsystems := []subsystem{
+		{
+			name: "fails-once",
+			newApp: newTestApp("fails-once", func(run int) error {
+				if run == 1 {
+					return errors.New("failed")
+				}
+				return nil
+			}),
+		},
+		{
+			name: "panics-once",
+			newApp: newTestApp("panics-once", func(run int) error {
+				if run == 1 {
+					panic("panicked")
+				}
+				return nil
+			}),
+		},
+	}
+	// The subsystems are only run sequentially to avoid racing on runs.
+	for _, s := range subsystems {
+		runSubsystems(context.Background(), []subsystem{s}, time.Millisecond)
+	}
+	require.Equal(t, map[string]int{"fails-once": 2, "panics-once": 2}, runs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failing := subsystem{name: "failing", newApp: newTestApp("failing", func(int) error { return errors.New("failed") })}
+	runSubsystems(ctx, []subsystem{failing}, time.Hour)
+	require.Equal(t, 1, runs["failing"])
+}
+
+func TestDispatchSignals(t *testing.T) {
+	subsystems := []subsystem{
+		{name: "a", signals: make(chan os.Signal, 1)},
+		{name: "b", signals: make(chan os.Signal, 1)},
+	}
+	sigs := make(chan os.Signal)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatchSignals(ctx, sigs, subsystems, cancel)
+	}()
+
+	sigs <- syscall.SIGHUP
+	for _, s := range subsystems {
+		require.Equal(t, syscall.SIGHUP, <-s.signals)
+	}
+	require.NoError(t, ctx.Err())
+
+	sigs <- syscall.SIGTERM
+	for _, s := range subsystems {
+		require.Equal(t, syscall.SIGTERM, <-s.signals)
+	}
+	<-done
+	require.Error(t, ctx.Err())
+}
@@ -0,0 +1,73 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package broker
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+)
+
+// CommandName is the name of the broker subcommand.
+const CommandName = "nvml-broker"
+
+// NewCommand constructs the nvml-broker command.
+// The command is started by the device plugin itself when the NVML broker is enabled.
+func NewCommand() *cli.Command {
+	var socket string
+	return &cli.Command{
+		Name:   CommandName,
+		Usage:  "Serve NVML requests for the device plugin over a local socket",
+		Hidden: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "socket",
+				Usage:       "the path of the unix socket to serve on",
+				Required:    true,
+				Destination: &socket,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return run(c, socket)
+		},
+	}
+}
+
+func run(c *cli.Context, socket string) error {
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket %v: %w", socket, err)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", socket, err)
+	}
+	defer os.Remove(socket)
+
+	ctx, cancel := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	klog.Infof("Serving NVML on %v", socket)
+	return nvcaps.Serve(ctx, l, nvcaps.New(nvml.New()))
+}
@@ -0,0 +1,262 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cleanup
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/logger"
+)
+
+// CommandName is the name of the cleanup subcommand.
+const CommandName = "cleanup"
+
+// dialTimeout is the time to wait for a connection to a plugin socket before
+// the socket is considered stale.
+const dialTimeout = time.Second
+
+type options struct {
+	dryRun           bool
+	devicePluginPath string
+	mpsRoot          string
+	cdiSpecDir       string
+
+	// config is the config of the device plugin. It determines the
+	// resources that are shared using MPS.
+	config *spec.Config
+}
+
+// artifact is a file or directory that was left behind by a previous
+// incarnation of the device plugin or the MPS control daemon.
+type artifact struct {
+	path   string
+	reason string
+}
+
+// NewCommand constructs the cleanup command.
+// The command is intended to be run as an init container before the device
+// plugin is started. The config is loaded from the same flags, environment
+// variables and config file as the device plugin.
+func NewCommand() *cli.Command {
+	o := &options{}
+	return &cli.Command{
+		Name:  CommandName,
+		Usage: "Remove stale plugin sockets, orphaned MPS directories, and leftover CDI specs",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "only log the artifacts that would be removed",
+				Destination: &o.dryRun,
+				EnvVars:     []string{"CLEANUP_DRY_RUN"},
+			},
+			&cli.StringFlag{
+				Name:        "device-plugin-path",
+				Value:       pluginapi.DevicePluginPath,
+				Usage:       "the directory containing the sockets of the device plugins",
+				Destination: &o.devicePluginPath,
+				EnvVars:     []string{"DEVICE_PLUGIN_PATH"},
+			},
+			&cli.StringFlag{
+				Name:        "mps-root",
+				Usage:       "the path on the host where MPS-specific mounts and files are created; an empty path skips the MPS cleanup",
+				Destination: &o.mpsRoot,
+				EnvVars:     []string{"MPS_ROOT"},
+			},
+			&cli.StringFlag{
+				Name:        "cdi-spec-dir",
+				Value:       cdi.SpecDir,
+				Usage:       "the directory containing the generated CDI specs",
+				Destination: &o.cdiSpecDir,
+				EnvVars:     []string{"CDI_SPEC_DIR"},
+			},
+		},
+		Action: func(c *cli.Context) error {
+			config, err := spec.NewConfig(c, c.App.Flags)
+			if err != nil {
+				return fmt.Errorf("unable to load config: %v", err)
+			}
+			spec.DisableResourceNamingInConfig(logger.ToKlog, config)
+			o.config = config
+			return o.run()
+		},
+	}
+}
+
+func (o *options) run() error {
+	artifacts, err := o.staleArtifacts()
+	if err != nil {
+		return err
+	}
+	for _, a := range artifacts {
+		if o.dryRun {
+			klog.Infof("Would remove %v: %v", a.path, a.reason)
+			continue
+		}
+		if err := os.RemoveAll(a.path); err != nil {
+			return fmt.Errorf("failed to remove %v: %w", a.path, err)
+		}
+		klog.Infof("Removed %v: %v", a.path, a.reason)
+	}
+	if len(artifacts) == 0 {
+		klog.Info("No stale artifacts found")
+	}
+	return nil
+}
+
+// staleArtifacts returns the artifacts that are no longer in use.
+func (o *options) staleArtifacts() ([]artifact, error) {
+	sockets, running, err := staleSockets(o.devicePluginPath)
+	if err != nil {
+		return nil, err
+	}
+	mpsDirs, err := orphanedMPSDirs(o.mpsRoot, o.config)
+	if err != nil {
+		return nil, err
+	}
+	var specs []artifact
+	// The CDI specs are regenerated when the plugin starts. They are only
+	// removed if no plugin is running since allocated containers that are
+	// started later would otherwise fail to resolve their devices.
+	if running {
+		klog.Infof("Keeping CDI specs in %v since a device plugin is running", o.cdiSpecDir)
+	} else {
+		specs, err = leftoverCDISpecs(o.cdiSpecDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var artifacts []artifact
+	artifacts = append(artifacts, sockets...)
+	artifacts = append(artifacts, mpsDirs...)
+	artifacts = append(artifacts, specs...)
+	return artifacts, nil
+}
+
+// staleSockets returns the plugin sockets in the specified directory that no
+// plugin is listening on. The returned bool indicates whether a plugin is
+// listening on any of the sockets.
+func staleSockets(dir string) ([]artifact, bool, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "nvidia-*.sock"))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list plugin sockets: %w", err)
+	}
+	var stale []artifact
+	var running bool
+	for _, path := range paths {
+		conn, err := net.DialTimeout("unix", path, dialTimeout)
+		if err == nil {
+			conn.Close()
+			running = true
+			continue
+		}
+		stale = append(stale, artifact{path: path, reason: "no plugin is listening on the socket"})
+	}
+	return stale, running, nil
+}
+
+// orphanedMPSDirs returns the per-resource directories in the specified MPS
+// root of resources that are no longer shared using MPS in the config. The
+// directories of resources that are still configured are kept, including the
+// per-MIG-device directories below them, since the MPS control daemon may be
+// running for them. The shared shm directory and the pipe and log directories
+// that are configured for MPS resources under the root are also kept.
+func orphanedMPSDirs(root string, config *spec.Config) ([]artifact, error) {
+	if root == "" {
+		return nil, nil
+	}
+	var mps *spec.ReplicatedResources
+	var configured []string
+	if config != nil && config.Sharing.MPS != nil {
+		mps = config.Sharing.MPS
+		for _, r := range mps.Resources {
+			for _, dir := range []string{r.PipeDirectory, r.LogDirectory} {
+				if dir != "" && !filepath.IsAbs(dir) {
+					configured = append(configured, dir)
+				}
+			}
+		}
+	}
+	// The directories of resources are named after the resource, e.g.
+	// <root>/nvidia.com/gpu.
+	dirs, err := filepath.Glob(filepath.Join(root, "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MPS directories: %w", err)
+	}
+	var orphaned []artifact
+	for _, dir := range dirs {
+		if filepath.Base(filepath.Dir(dir)) == "shm" {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		name, err := filepath.Rel(root, dir)
+		if err != nil {
+			continue
+		}
+		if mps.ForResource(spec.ResourceName(filepath.ToSlash(name))) != nil {
+			continue
+		}
+		if slices.ContainsFunc(configured, func(dir string) bool { return pathsOverlap(name, dir) }) {
+			continue
+		}
+		orphaned = append(orphaned, artifact{path: dir, reason: "the resource is not shared using MPS in the config"})
+	}
+	return orphaned, nil
+}
+
+// pathsOverlap returns whether the specified clean relative paths are equal
+// or one contains the other.
+func pathsOverlap(a, b string) bool {
+	sep := string(filepath.Separator)
+	return a == b || strings.HasPrefix(a, b+sep) || strings.HasPrefix(b, a+sep)
+}
+
+// leftoverCDISpecs returns the CDI specs and topology files in the specified
+// directory that were generated by the device plugin.
+func leftoverCDISpecs(dir string) ([]artifact, error) {
+	var leftover []artifact
+	for _, ext := range []string{".json", ".yaml"} {
+		paths, err := filepath.Glob(filepath.Join(dir, cdi.Vendor+"-*"+ext))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list CDI specs: %w", err)
+		}
+		for _, path := range paths {
+			leftover = append(leftover, artifact{path: path, reason: "CDI spec is regenerated when the plugin starts"})
+		}
+	}
+	// The topology files are referenced by the transient CDI specs of
+	// allocations and are regenerated along with them.
+	topologyDir := filepath.Join(dir, cdi.TopologyClass)
+	if info, err := os.Stat(topologyDir); err == nil && info.IsDir() {
+		leftover = append(leftover, artifact{path: topologyDir, reason: "topology files are regenerated on allocation"})
+	}
+	return leftover, nil
+}
@@ -0,0 +1,132 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cleanup
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		description      string
+		dryRun           bool
+		pluginRunning    bool
+		expectedRemoved  []string
+		expectedRetained []string
+	}{
+		{
+			description: "stale artifacts are removed",
+			expectedRemoved: []string{
+				"device-plugins/nvidia-gpu.sock",
+				"mps/nvidia.com/gpu",
+				"cdi/k8s.device-plugin.nvidia.com-gpu.json",
+				"cdi/topology",
+			},
+			expectedRetained: []string{
+				"device-plugins/kubelet.sock",
+				"mps/nvidia.com/gpu.shared",
+				"mps/nvidia.com/mig-1g.5gb/MIG-1/pipe",
+				"mps/pipes/gpu",
+				"mps/logs/gpu",
+				"mps/shm",
+				"cdi/other-vendor.json",
+			},
+		},
+		{
+			description: "dry run removes nothing",
+			dryRun:      true,
+			expectedRetained: []string{
+				"device-plugins/nvidia-gpu.sock",
+				"mps/nvidia.com/gpu",
+				"cdi/k8s.device-plugin.nvidia.com-gpu.json",
+			},
+		},
+		{
+			description:   "CDI specs are kept while a plugin is running",
+			pluginRunning: true,
+			expectedRemoved: []string{
+				"device-plugins/nvidia-gpu.sock",
+			},
+			expectedRetained: []string{
+				"device-plugins/nvidia-gpu.shared.sock",
+				"cdi/k8s.device-plugin.nvidia.com-gpu.json",
+				"cdi/topology",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			// The temporary directory of the test is not used since its path
+			// may exceed the maximum length of a socket path.
+			root, err := os.MkdirTemp("", "cleanup")
+			require.NoError(t, err)
+			defer os.RemoveAll(root)
+
+			for _, dir := range []string{"device-plugins", "mps/nvidia.com/gpu/pipe", "mps/nvidia.com/gpu.shared/pipe", "mps/nvidia.com/mig-1g.5gb/MIG-1/pipe", "mps/pipes/gpu", "mps/logs/gpu", "mps/shm", "cdi", "cdi/topology"} {
+				require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+			}
+			for _, file := range []string{"device-plugins/kubelet.sock", "cdi/k8s.device-plugin.nvidia.com-gpu.json", "cdi/other-vendor.json"} {
+				require.NoError(t, os.WriteFile(filepath.Join(root, file), nil, 0644))
+			}
+
+			stale, err := net.Listen("unix", filepath.Join(root, "device-plugins/nvidia-gpu.sock"))
+			require.NoError(t, err)
+			stale.(*net.UnixListener).SetUnlinkOnClose(false)
+			require.NoError(t, stale.Close())
+			if tc.pluginRunning {
+				l, err := net.Listen("unix", filepath.Join(root, "device-plugins/nvidia-gpu.shared.sock"))
+				require.NoError(t, err)
+				defer l.Close()
+			}
+
+			o := &options{
+				dryRun:           tc.dryRun,
+				devicePluginPath: filepath.Join(root, "device-plugins"),
+				mpsRoot:          filepath.Join(root, "mps"),
+				cdiSpecDir:       filepath.Join(root, "cdi"),
+				config: &spec.Config{
+					Sharing: spec.Sharing{
+						MPS: &spec.ReplicatedResources{
+							Resources: []spec.ReplicatedResource{
+								{Name: "nvidia.com/gpu", Rename: "nvidia.com/gpu.shared", PipeDirectory: "pipes/gpu", LogDirectory: "logs/gpu"},
+								{Name: "nvidia.com/mig-1g.5gb"},
+							},
+						},
+					},
+				},
+			}
+			require.NoError(t, o.run())
+
+			for _, path := range tc.expectedRemoved {
+				require.NoFileExists(t, filepath.Join(root, path))
+				require.NoDirExists(t, filepath.Join(root, path))
+			}
+			for _, path := range tc.expectedRetained {
+				_, err := os.Stat(filepath.Join(root, path))
+				require.NoError(t, err, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// SocketCollector removes the plugin sockets that were left behind by crashed
+// incarnations of the device plugin. A socket is considered stale if no
+// plugin accepts connections on it, so the sockets of running plugins, e.g.
+// of another incarnation that is still terminating, are kept.
+//
+// Since a plugin that is binding or restarting does not accept connections
+// for a short time, the periodic collection only removes the sockets that
+// failed to accept connections in staleSocketCollections consecutive
+// collections.
+type SocketCollector struct {
+	dir      string
+	interval time.Duration
+
+	mutex sync.Mutex
+	// failures holds the number of consecutive periodic collections in which
+	// no plugin accepted connections on a socket.
+	failures map[string]int
+}
+
+// staleSocketCollections is the number of consecutive periodic collections in
+// which a socket must fail to accept connections before it is removed.
+const staleSocketCollections = 3
+
+// NewSocketCollector creates a collector for the plugin sockets in the
+// specified directory that runs at the specified interval. A nil collector is
+// returned if the interval is 0.
+func NewSocketCollector(dir string, interval time.Duration) *SocketCollector {
+	if interval == 0 {
+		return nil
+	}
+	return &SocketCollector{
+		dir:      dir,
+		interval: interval,
+		failures: make(map[string]int),
+	}
+}
+
+// Run removes the stale plugin sockets at the interval of the collector until
+// the context is cancelled.
+func (s *SocketCollector) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.collect(staleSocketCollections); err != nil {
+			klog.Warningf("Failed to remove stale plugin sockets: %v", err)
+		}
+	}
+}
+
+// Collect removes the stale plugin sockets once, without waiting for repeated
+// failures. It is called before the plugins bind their sockets, so that the kubelet does not keep dialing the
+// sockets of crashed plugins for resources that are no longer served.
+func (s *SocketCollector) Collect() error {
+	if s == nil {
+		return nil
+	}
+	return s.collect(1)
+}
+
+// collect removes the plugin sockets that failed to accept connections in the
+// specified number of consecutive collections, including this one.
+func (s *SocketCollector) collect(collections int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sockets, _, err := staleSockets(s.dir)
+	if err != nil {
+		return err
+	}
+	failures := make(map[string]int)
+	for _, a := range sockets {
+		failures[a.path] = s.failures[a.path] + 1
+	}
+	// Sockets that accepted connections or no longer exist are forgotten.
+	s.failures = failures
+
+	for _, a := range sockets {
+		if failures[a.path] < collections {
+			klog.V(4).Infof("Keeping %v until %d consecutive collections fail: %v", a.path, collections, a.reason)
+			continue
+		}
+		if err := os.Remove(a.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %v: %w", a.path, err)
+		}
+		delete(s.failures, a.path)
+		klog.Infof("Removed %v: %v", a.path, a.reason)
+	}
+	return nil
+}
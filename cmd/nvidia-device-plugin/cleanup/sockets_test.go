@@ -0,0 +1,87 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cleanup
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocketCollector(t *testing.T) {
+	var nilCollector *SocketCollector
+	require.Nil(t, NewSocketCollector("", 0))
+	require.NoError(t, nilCollector.Collect())
+
+	// The temporary directory of the test is not used since its path may
+	// exceed the maximum length of a socket path.
+	dir, err := os.MkdirTemp("", "sockets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kubelet.sock"), nil, 0644))
+	stale, err := net.Listen("unix", filepath.Join(dir, "nvidia-gpu.sock"))
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+	running, err := net.Listen("unix", filepath.Join(dir, "nvidia-gpu.shared.sock"))
+	require.NoError(t, err)
+	defer running.Close()
+
+	require.NoError(t, NewSocketCollector(dir, time.Minute).Collect())
+	require.NoFileExists(t, filepath.Join(dir, "nvidia-gpu.sock"))
+	require.FileExists(t, filepath.Join(dir, "kubelet.sock"))
+	_, err = os.Stat(filepath.Join(dir, "nvidia-gpu.shared.sock"))
+	require.NoError(t, err)
+}
+
+func TestSocketCollectorRepeatedFailures(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sockets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nvidia-gpu.sock")
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	s := NewSocketCollector(dir, time.Minute)
+	for i := 1; i < staleSocketCollections; i++ {
+		require.NoError(t, s.collect(staleSocketCollections))
+		require.FileExists(t, path)
+	}
+
+	// A plugin that binds the socket again resets its failures.
+	require.NoError(t, os.Remove(path))
+	running, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	running.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, s.collect(staleSocketCollections))
+	require.NoError(t, running.Close())
+	for i := 1; i < staleSocketCollections; i++ {
+		require.NoError(t, s.collect(staleSocketCollections))
+		require.FileExists(t, path)
+	}
+
+	require.NoError(t, s.collect(staleSocketCollections))
+	require.NoFileExists(t, path)
+}
@@ -0,0 +1,157 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	nvinfo "github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/logger"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// CommandName is the name of the explain subcommand.
+const CommandName = "explain"
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+type options struct {
+	output string
+}
+
+// NewCommand constructs the explain command.
+// The command loads the effective config from the same flags, environment
+// variables and config file as the device plugin and prints how each GPU and
+// MIG device on the node maps to the advertised resources.
+func NewCommand() *cli.Command {
+	o := &options{}
+	return &cli.Command{
+		Name:  CommandName,
+		Usage: "Explain how the GPUs and MIG devices on the node map to the advertised resources",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       outputText,
+				Usage:       "the output format:\n\t\t[text | json]",
+				Destination: &o.output,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if o.output != outputText && o.output != outputJSON {
+				return fmt.Errorf("unsupported output format: %v", o.output)
+			}
+			explanations, err := explain(c)
+			if err != nil {
+				return err
+			}
+			if o.output == outputJSON {
+				return writeJSON(c.App.Writer, explanations)
+			}
+			writeText(c.App.Writer, explanations)
+			return nil
+		},
+	}
+}
+
+// explain builds the device map in the same way as the device plugin and
+// explains each physical device on the node.
+func explain(c *cli.Context) ([]rm.Explanation, error) {
+	config, err := spec.NewConfig(c, c.App.Flags)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load config: %v", err)
+	}
+	config.Flags.GFD = nil
+	spec.DisableResourceNamingInConfig(logger.ToKlog, config)
+
+	nvmllib := nvml.New()
+	devicelib := device.New(nvmllib)
+	infolib := nvinfo.New(
+		nvinfo.WithNvmlLib(nvmllib),
+		nvinfo.WithDeviceLib(devicelib),
+	)
+
+	if err := rm.AddDefaultResourcesToConfig(infolib, nvmllib, devicelib, config); err != nil {
+		return nil, fmt.Errorf("unable to add default resources to config: %v", err)
+	}
+
+	if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML: %v", ret)
+	}
+	defer func() {
+		_ = nvmllib.Shutdown()
+	}()
+
+	physical, err := rm.GetPhysicalDevices(devicelib)
+	if err != nil {
+		return nil, fmt.Errorf("unable to enumerate devices: %v", err)
+	}
+
+	// The plugin fails to start if the device map cannot be built, which is
+	// the explanation for every device on the node.
+	deviceMap, err := rm.NewDeviceMap(infolib, nvmllib, devicelib, config)
+	if err != nil {
+		return nil, fmt.Errorf("the plugin would fail to start: unable to build device map: %v", err)
+	}
+
+	return rm.Explain(physical, deviceMap, config), nil
+}
+
+func writeJSON(w io.Writer, explanations []rm.Explanation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(explanations)
+}
+
+func writeText(w io.Writer, explanations []rm.Explanation) {
+	for _, e := range explanations {
+		kind := "GPU"
+		if e.Parent != "" {
+			kind = "MIG"
+		}
+		fmt.Fprintf(w, "%v %v: %v (%v)\n", kind, e.Index, e.Name, e.UUID)
+		if e.Reason != "" {
+			fmt.Fprintf(w, "  not advertised: %v\n", e.Reason)
+			continue
+		}
+		for _, r := range e.Resources {
+			var details []string
+			if r.Sharing != "" {
+				details = append(details, string(r.Sharing))
+			}
+			if r.RenamedFrom != "" {
+				details = append(details, fmt.Sprintf("renamed from %v", r.RenamedFrom))
+			}
+			name := string(r.Name)
+			if len(details) > 0 {
+				name += " (" + strings.Join(details, ", ") + ")"
+			}
+			fmt.Fprintf(w, "  %v: %v\n", name, strings.Join(r.IDs, ", "))
+		}
+	}
+}
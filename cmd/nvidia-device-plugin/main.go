@@ -17,10 +17,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -29,26 +31,305 @@ import (
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/fsnotify/fsnotify"
 	"github.com/urfave/cli/v2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/selftest"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/nvidia-device-plugin/broker"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/nvidia-device-plugin/cleanup"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/nvidia-device-plugin/explain"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/nvidia-device-plugin/planmig"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/nvidia-device-plugin/refresh"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/nvidia-device-plugin/scrub"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/nvidia-device-plugin/supportbundle"
+	"github.com/NVIDIA/k8s-device-plugin/internal/admin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/attribution"
+	"github.com/NVIDIA/k8s-device-plugin/internal/conflict"
+	"github.com/NVIDIA/k8s-device-plugin/internal/debug"
+	"github.com/NVIDIA/k8s-device-plugin/internal/drain"
+	"github.com/NVIDIA/k8s-device-plugin/internal/featuregates"
+	"github.com/NVIDIA/k8s-device-plugin/internal/flags"
 	"github.com/NVIDIA/k8s-device-plugin/internal/info"
+	"github.com/NVIDIA/k8s-device-plugin/internal/inventory"
 	"github.com/NVIDIA/k8s-device-plugin/internal/logger"
+	"github.com/NVIDIA/k8s-device-plugin/internal/metrics"
+	"github.com/NVIDIA/k8s-device-plugin/internal/mig"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nodestatus"
+	"github.com/NVIDIA/k8s-device-plugin/internal/npd"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
 	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin/manager"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rollback"
+	"github.com/NVIDIA/k8s-device-plugin/internal/tuning"
 	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
+	"github.com/NVIDIA/k8s-device-plugin/internal/writable"
 )
 
 func main() {
+	err := newApp().Run(os.Args)
+	if err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+}
+
+// appConfig holds the state that the device plugin application shares with
+// the other applications when they run in the same process.
+type appConfig struct {
+	// nvmllib is the NVML library used to manage the devices.
+	nvmllib nvml.Interface
+	// signals receives the OS signals if they are dispatched by the process
+	// running the application. Otherwise the signals are watched directly.
+	signals <-chan os.Signal
+}
+
+// appOption configures the device plugin application.
+type appOption func(*appConfig)
+
+// withNvmlLib sets the NVML library used by the application.
+func withNvmlLib(nvmllib nvml.Interface) appOption {
+	return func(c *appConfig) {
+		c.nvmllib = nvmllib
+	}
+}
+
+// withSignals sets the channel on which the application receives OS signals.
+func withSignals(signals <-chan os.Signal) appOption {
+	return func(c *appConfig) {
+		c.signals = signals
+	}
+}
+
+// newApp creates the device plugin application.
+func newApp(opts ...appOption) *cli.App {
+	shared := &appConfig{}
+	for _, opt := range opts {
+		opt(shared)
+	}
+	if shared.nvmllib == nil {
+		shared.nvmllib = nvml.New()
+	}
+
 	var configFile string
+	var kubeClientConfig flags.KubeClientConfig
+	var nodeConfig flags.NodeConfig
+	var startupConfig flags.StartupConfig
+	var nodeStatusInterval time.Duration
+	var useNVMLBroker bool
+	var nvmlBrokerSocket string
+	var drainSocket string
+	var pluginAdminSocket string
+	var podResourcesSocket string
+	var debugAddress string
+	var metricsAddress string
+	var inventoryAddress string
+	var npdSocket string
+	var npdListenSocket string
+	var configRollbackWindow time.Duration
+	var configRollbackFile string
+	var watchConfigFile bool
+	var sharingTopologyAnnotation bool
+	var buildInfoLabels bool
+	var migLayoutCheckInterval time.Duration
+	var migReconfigurationGracePeriod time.Duration
+	var migReconfigurationAnnotation bool
+	var staleSocketGCInterval time.Duration
+	var pluginConflictPolicy string
+	var markUnhealthyOnShutdown bool
+	var shutdownGracePeriod time.Duration
+	var xidHistorySize int
+	var wearMetrics bool
+	var allocationStatusFile string
+	var allocationAttributionInterval time.Duration
 
 	c := cli.NewApp()
 	c.Name = "NVIDIA Device Plugin"
 	c.Usage = "NVIDIA device plugin for Kubernetes"
 	c.Version = info.GetVersionString()
 	c.Action = func(ctx *cli.Context) error {
-		return start(ctx, c.Flags)
+		if err := startupConfig.Wait(ctx.Context); err != nil {
+			return fmt.Errorf("startup delay interrupted: %w", err)
+		}
+
+		paths := []writable.Path{
+			writable.Dir("plugin sockets", pluginapi.DevicePluginPath),
+			writable.File("the plugin admin socket", pluginAdminSocket),
+			writable.File("the drain socket", drainSocket),
+		}
+		if useNVMLBroker {
+			paths = append(paths, writable.File("the NVML broker socket", nvmlBrokerSocket))
+		}
+		if configRollbackWindow > 0 {
+			paths = append(paths, writable.File("the last-known-good config", configRollbackFile))
+		}
+		paths = append(paths, writable.File("the allocation status file", allocationStatusFile))
+		if err := writable.Check(paths...); err != nil {
+			return fmt.Errorf("required paths are not writable: %w", err)
+		}
+
+		o := &options{
+			flags:         c.Flags,
+			nvmllib:       shared.nvmllib,
+			signals:       shared.signals,
+			metricsServer: metrics.NewServer(metricsAddress),
+			healthTracker: metrics.NewHealthTracker("nvidia_device_plugin"),
+			pluginTracker: metrics.NewPluginTracker("nvidia_device_plugin"),
+			configTracker: metrics.NewConfigTracker("nvidia_device_plugin"),
+			npdForwarder:  npd.NewForwarder(npdSocket, npdListenSocket),
+			adminServer:   admin.NewServer(pluginAdminSocket),
+			inventory:     inventory.NewServer(inventoryAddress),
+			featureGates:  featuregates.NewCollector("nvidia_device_plugin"),
+			buildInfo:     metrics.NewBuildInfo("nvidia_device_plugin", info.GetVersion(), info.GetGitCommit()),
+			validations:   rm.NewValidationCache(),
+		}
+
+		policy, err := conflict.NewPolicy(pluginConflictPolicy)
+		if err != nil {
+			return err
+		}
+		o.conflictPolicy = policy
+
+		if shutdownGracePeriod < 0 {
+			return fmt.Errorf("invalid --shutdown-grace-period: must be >= 0")
+		}
+		o.markUnhealthyOnShutdown = markUnhealthyOnShutdown
+		o.shutdownGracePeriod = shutdownGracePeriod
+
+		if xidHistorySize < 0 {
+			return fmt.Errorf("invalid --xid-history-size: must be >= 0")
+		}
+
+		nvmllib := o.nvmllib
+		o.socketCollector = cleanup.NewSocketCollector(pluginapi.DevicePluginPath, staleSocketGCInterval)
+
+		settings := tuning.Tune(tuning.DefaultCgroupRoot)
+		if err := o.metricsServer.Register(append(settings.Collectors("nvidia_device_plugin"), o.healthTracker, o.pluginTracker, o.configTracker, o.featureGates, o.buildInfo)...); err != nil {
+			return fmt.Errorf("failed to register metrics: %w", err)
+		}
+
+		reporter, err := newNodeStatusReporter(&kubeClientConfig, &nodeConfig, nodeStatusInterval)
+		if err != nil {
+			return fmt.Errorf("failed to create node status reporter: %w", err)
+		}
+		o.nodeStatusReporter = reporter
+
+		o.topologyPublisher, err = newTopologyPublisher(&kubeClientConfig, &nodeConfig, sharingTopologyAnnotation)
+		if err != nil {
+			return fmt.Errorf("failed to create sharing topology publisher: %w", err)
+		}
+
+		o.buildInfoPublisher, err = newBuildInfoPublisher(&kubeClientConfig, &nodeConfig, buildInfoLabels)
+		if err != nil {
+			return fmt.Errorf("failed to create build info publisher: %w", err)
+		}
+
+		o.rollback, err = newRollbackManager(&kubeClientConfig, &nodeConfig, configFile, configRollbackFile, configRollbackWindow)
+		if err != nil {
+			return fmt.Errorf("failed to create config rollback manager: %w", err)
+		}
+		if watchConfigFile {
+			o.watchConfigFile = configFile
+		}
+		o.nodeEvents = newNodeEventRecorder(&kubeClientConfig, &nodeConfig)
+
+		// The connection to the kubelet is only established once the
+		// PodResources API is used by the drain API or clock boosting.
+		podResources, err := podresources.NewClient(podResourcesSocket)
+		if err != nil {
+			return fmt.Errorf("failed to create pod resources client: %w", err)
+		}
+		defer podResources.Close()
+		o.podResources = podResources
+
+		// The Xid history attributes events to the pods that the devices
+		// are allocated to and is exposed on the debug endpoints.
+		o.xidHistory = metrics.NewXidHistory("nvidia_device_plugin", xidHistorySize, podResources)
+		if o.xidHistory != nil {
+			if err := o.metricsServer.Register(o.xidHistory); err != nil {
+				return fmt.Errorf("failed to register metrics: %w", err)
+			}
+		}
+		o.debugServer = debug.NewServer(debugAddress, o.xidHistory)
+
+		// The allocated devices are only attributed to containers if the
+		// attribution is exposed as metrics or in the status file.
+		if metricsAddress != "" || allocationStatusFile != "" {
+			o.allocations = attribution.NewTracker("nvidia_device_plugin", podResources, allocationStatusFile, allocationAttributionInterval)
+			if err := o.metricsServer.Register(o.allocations); err != nil {
+				return fmt.Errorf("failed to register metrics: %w", err)
+			}
+		}
+
+		// Pod annotations are only read for resources that allow exclusive
+		// access or per-pod MPS thread percentages, so the getter is only
+		// created once a config requires it and a missing kube client is not
+		// fatal.
+		o.newPodAnnotations = func() (*podresources.AnnotationGetter, error) {
+			return newPodAnnotationGetter(&kubeClientConfig, nodeConfig.Name)
+		}
+
+		// The drain manager is also used to drain the devices affected by a
+		// change of the MIG layout, even if the drain API is disabled.
+		if migReconfigurationGracePeriod < 0 {
+			return fmt.Errorf("invalid --mig-reconfiguration-grace-period: must be >= 0")
+		}
+		if drainSocket != "" || migReconfigurationGracePeriod > 0 {
+			o.drainSocket = drainSocket
+			o.drainManager = drain.NewManager(podResources, drain.DefaultInterval)
+		}
+		reconfigurationPublisher, err := newReconfigurationPublisher(&kubeClientConfig, &nodeConfig, migReconfigurationAnnotation)
+		if err != nil {
+			return fmt.Errorf("failed to create MIG reconfiguration publisher: %w", err)
+		}
+		o.migReconfiguration = newMIGReconfiguration(migReconfigurationGracePeriod, o.drainManager, reconfigurationPublisher)
+
+		if useNVMLBroker {
+			nvmlBroker, err := newNVMLBroker(nvmlBrokerSocket)
+			if err != nil {
+				return fmt.Errorf("failed to create NVML broker: %w", err)
+			}
+			if err := nvmlBroker.Start(); err != nil {
+				return fmt.Errorf("failed to start NVML broker: %w", err)
+			}
+			defer func() {
+				_ = nvmlBroker.Stop()
+			}()
+			o.nvcaps = nvmlBroker.Client()
+		}
+
+		// The MIG layout and the wear counters of the GPUs of the plugins are
+		// queried through the NVML broker if it is used.
+		nvcapslib := o.nvcaps
+		if nvcapslib == nil {
+			nvcapslib = nvcaps.New(nvmllib)
+		}
+		o.migWatcher = mig.NewWatcher(nvcapslib, migLayoutCheckInterval)
+		if wearMetrics {
+			o.wear = metrics.NewWearCollector("nvidia_device_plugin", nvcapslib)
+			if err := o.metricsServer.Register(o.wear); err != nil {
+				return fmt.Errorf("failed to register metrics: %w", err)
+			}
+		}
+
+		return start(ctx, o)
+	}
+	c.Commands = []*cli.Command{
+		broker.NewCommand(),
+		cleanup.NewCommand(),
+		explain.NewCommand(),
+		planmig.NewCommand(),
+		refresh.NewCommand(),
+		scrub.NewCommand(),
+		supportbundle.NewCommand(),
+		newAllInOneCommand(),
+		// The MPS self-test runs the probe of the executable, which is the
+		// device plugin if the MPS control daemon runs in all-in-one mode.
+		selftest.NewCommand(),
+		newValidateCommand(),
 	}
 
 	c.Flags = []cli.Flag{
@@ -58,6 +339,11 @@ func main() {
 			Usage:   "the desired strategy for exposing MIG devices on GPUs that support it:\n\t\t[none | single | mixed]",
 			EnvVars: []string{"MIG_STRATEGY"},
 		},
+		&cli.BoolFlag{
+			Name:    "mig-single-allow-partial",
+			Usage:   "allow the single MIG strategy on nodes where MIG is only enabled on some GPUs; the full GPUs are advertised under a separate resource name with a '" + spec.DefaultFullGPUResourceNameSuffix + "' suffix",
+			EnvVars: []string{"MIG_SINGLE_ALLOW_PARTIAL"},
+		},
 		&cli.BoolFlag{
 			Name:    "fail-on-init-error",
 			Value:   true,
@@ -76,6 +362,11 @@ func main() {
 			Usage:   "pass the list of DeviceSpecs to the kubelet on Allocate()",
 			EnvVars: []string{"PASS_DEVICE_SPECS"},
 		},
+		&cli.BoolFlag{
+			Name:    "cpu-affinity-envvars",
+			Usage:   "pass the CPUs and NUMA nodes that are closest to the allocated devices to the containers as envvars on Allocate()",
+			EnvVars: []string{"CPU_AFFINITY_ENVVARS"},
+		},
 		&cli.StringSliceFlag{
 			Name:    "device-list-strategy",
 			Value:   cli.NewStringSlice(string(spec.DeviceListStrategyEnvvar)),
@@ -104,6 +395,11 @@ func main() {
 			Destination: &configFile,
 			EnvVars:     []string{"CONFIG_FILE"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "config-fragments",
+			Usage:   "the paths to config fragments, e.g. mounted from Secrets, that are merged into the config file. A directory includes all its files in lexical order",
+			EnvVars: []string{"CONFIG_FRAGMENTS"},
+		},
 		&cli.StringFlag{
 			Name:    "cdi-annotation-prefix",
 			Value:   spec.DefaultCDIAnnotationPrefix,
@@ -116,6 +412,12 @@ func main() {
 			Usage:   "the path to use for the nvidia-ctk in the generated CDI specification",
 			EnvVars: []string{"NVIDIA_CTK_PATH"},
 		},
+		&cli.StringFlag{
+			Name:    "cdi-spec-dir",
+			Value:   spec.DefaultCDISpecDir,
+			Usage:   "the directory that CDI specifications are written to; must be mounted at the same path as on the host",
+			EnvVars: []string{"CDI_SPEC_DIR"},
+		},
 		&cli.StringFlag{
 			Name:    "driver-root-ctr-path",
 			Aliases: []string{"container-driver-root"},
@@ -123,18 +425,227 @@ func main() {
 			Usage:   "the path where the NVIDIA driver root is mounted in the container; used for generating CDI specifications",
 			EnvVars: []string{"DRIVER_ROOT_CTR_PATH", "CONTAINER_DRIVER_ROOT"},
 		},
+		&cli.StringFlag{
+			Name:    "container-runtime-mode",
+			Value:   spec.ContainerRuntimeModeAuto,
+			Usage:   "the mode of the NVIDIA Container Runtime on the node; csv is used on Tegra-based systems such as Jetson and IGX:\n\t\t[auto | legacy | csv]",
+			EnvVars: []string{"CONTAINER_RUNTIME_MODE"},
+		},
+		&cli.GenericFlag{
+			Name:    "feature-gates",
+			Value:   &spec.FeatureGates{},
+			Usage:   "a comma-separated list of <name>=<bool> pairs that enable or disable experimental features:\n\t\t" + featuregates.Default.Usage(),
+			EnvVars: []string{"FEATURE_GATES"},
+		},
+		&cli.DurationFlag{
+			Name:    "grpc-keepalive-time",
+			Value:   30 * time.Second,
+			Usage:   "the time after which the gRPC server of each plugin pings an idle kubelet connection to detect connections that were not closed; 0 disables the pings",
+			EnvVars: []string{"GRPC_KEEPALIVE_TIME"},
+		},
+		&cli.DurationFlag{
+			Name:    "grpc-keepalive-timeout",
+			Value:   10 * time.Second,
+			Usage:   "the time to wait for a keepalive ping to be acknowledged before the kubelet connection is closed",
+			EnvVars: []string{"GRPC_KEEPALIVE_TIMEOUT"},
+		},
+		&cli.DurationFlag{
+			Name:    "list-and-watch-liveness-interval",
+			Usage:   "the interval at which the current device list is resent on an idle ListAndWatch stream; a failed send resets the stream and re-registers the plugin with the kubelet; 0 disables the resends",
+			EnvVars: []string{"LIST_AND_WATCH_LIVENESS_INTERVAL"},
+		},
+		&cli.StringFlag{
+			Name:    "device-location-file",
+			Usage:   "a path to a YAML file that maps device UUIDs to their physical rack, chassis, and slot; the locations are included in the debug status page and the forwarded health events",
+			EnvVars: []string{"DEVICE_LOCATION_FILE"},
+		},
 		&cli.StringFlag{
 			Name:    "mps-root",
 			Usage:   "the path on the host where MPS-specific mounts and files are created by the MPS control daemon manager",
 			EnvVars: []string{"MPS_ROOT"},
 		},
+		&cli.StringFlag{
+			Name:    "mps-container-root",
+			Value:   spec.DefaultMpsContainerRoot,
+			Usage:   "the path at which the MPS root is mounted in the container of the MPS control daemon; this is also where the MPS pipe directories are mounted in containers that use MPS",
+			EnvVars: []string{"MPS_CONTAINER_ROOT"},
+		},
+		&cli.DurationFlag{
+			Name:        "node-status-interval",
+			Usage:       "the interval at which a JSON summary of the plugin status is written to the nvidia.com/device-plugin.status node annotation; 0 disables the annotation",
+			Destination: &nodeStatusInterval,
+			EnvVars:     []string{"NODE_STATUS_INTERVAL"},
+		},
+		&cli.BoolFlag{
+			Name:        "sharing-topology-annotation",
+			Usage:       "write a JSON description of the physical GPU backing each advertised device to the nvidia.com/device-plugin.topology node annotation",
+			Destination: &sharingTopologyAnnotation,
+			EnvVars:     []string{"SHARING_TOPOLOGY_ANNOTATION"},
+		},
+		&cli.BoolFlag{
+			Name:        "build-info-labels",
+			Usage:       "write the plugin version, git commit, enabled feature gates and config hash to the nvidia.com/device-plugin.* node labels",
+			Destination: &buildInfoLabels,
+			EnvVars:     []string{"BUILD_INFO_LABELS"},
+		},
+		&cli.BoolFlag{
+			Name:        "nvml-broker",
+			Usage:       "run the NVML calls of the running plugins, such as health checks, in a separate broker process that is restarted if it exits",
+			Destination: &useNVMLBroker,
+			EnvVars:     []string{"NVML_BROKER"},
+		},
+		&cli.StringFlag{
+			Name:        "nvml-broker-socket",
+			Value:       filepath.Join(os.TempDir(), "nvidia-device-plugin-nvml-broker.sock"),
+			Usage:       "the path of the unix socket used to communicate with the NVML broker if --nvml-broker is set",
+			Destination: &nvmlBrokerSocket,
+			EnvVars:     []string{"NVML_BROKER_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:        "drain-socket",
+			Usage:       "the path of a unix socket on which an API to drain the replicas of devices is served; an empty path disables the API",
+			Destination: &drainSocket,
+			EnvVars:     []string{"DRAIN_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:        "plugin-admin-socket",
+			Usage:       "the path of a unix socket on which a local admin API is served, e.g. to force the device lists to be resent to the kubelet; an empty path disables the API",
+			Destination: &pluginAdminSocket,
+			EnvVars:     []string{"PLUGIN_ADMIN_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:        "plugin-conflict-policy",
+			Value:       string(conflict.PolicyWarn),
+			Usage:       "how other device plugins advertising the same GPUs on the node are handled: [warn | fail]",
+			Destination: &pluginConflictPolicy,
+			EnvVars:     []string{"PLUGIN_CONFLICT_POLICY"},
+		},
+		&cli.StringFlag{
+			Name:        "pod-resources-socket",
+			Value:       podresources.DefaultSocket,
+			Usage:       "the path of the kubelet PodResources socket used to wait for pods to release drained devices",
+			Destination: &podResourcesSocket,
+			EnvVars:     []string{"POD_RESOURCES_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:        "debug-address",
+			Usage:       "the address (e.g. localhost:6060) on which debug endpoints are served over HTTP; an empty address disables the endpoints",
+			Destination: &debugAddress,
+			EnvVars:     []string{"DEBUG_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:        "metrics-address",
+			Usage:       "the address (e.g. :9400) on which Prometheus metrics are served on /metrics; an empty address disables the metrics",
+			Destination: &metricsAddress,
+			EnvVars:     []string{"METRICS_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:        "inventory-address",
+			Usage:       "the address (e.g. localhost:8081) on which the device inventory API for clients that cannot dial the plugin sockets, such as virtual-kubelet providers, is served over HTTP; an empty address disables the API",
+			Destination: &inventoryAddress,
+			EnvVars:     []string{"INVENTORY_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:        "node-problem-detector-socket",
+			Usage:       "the path to the unix socket of node-problem-detector to which GPU health events are forwarded; an empty path disables forwarding",
+			Destination: &npdSocket,
+			EnvVars:     []string{"NODE_PROBLEM_DETECTOR_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:        "node-problem-detector-listen-socket",
+			Usage:       "the path to a unix socket on which GPU health events are served to node-problem-detector plugins that connect to it; an empty path disables the socket",
+			Destination: &npdListenSocket,
+			EnvVars:     []string{"NODE_PROBLEM_DETECTOR_LISTEN_SOCKET"},
+		},
+		&cli.DurationFlag{
+			Name:        "config-rollback-window",
+			Usage:       "the period after applying a new config file within which the plugins must start and have healthy devices; otherwise the last-known-good config is restored. 0 disables rollbacks",
+			Destination: &configRollbackWindow,
+			EnvVars:     []string{"CONFIG_ROLLBACK_WINDOW"},
+		},
+		&cli.StringFlag{
+			Name:        "config-rollback-file",
+			Value:       filepath.Join(pluginapi.DevicePluginPath, "nvidia-device-plugin-last-known-good.yaml"),
+			Usage:       "the path at which the last-known-good config is persisted if --config-rollback-window is set",
+			Destination: &configRollbackFile,
+			EnvVars:     []string{"CONFIG_ROLLBACK_FILE"},
+		},
+		&cli.BoolFlag{
+			Name:        "watch-config-file",
+			Usage:       "reload the plugins when the config file changes instead of requiring a restart; devices that are no longer configured but are still allocated to running pods keep being advertised as unhealthy until they are released",
+			Destination: &watchConfigFile,
+			EnvVars:     []string{"WATCH_CONFIG_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:        "mig-layout-check-interval",
+			Value:       30 * time.Second,
+			Usage:       "the interval at which the GPUs and MIG layout of the node are checked for external changes, e.g. by mig-parted or PCIe hotplug; only the plugins of resources with changed devices are restarted. 0 disables the checks",
+			Destination: &migLayoutCheckInterval,
+			EnvVars:     []string{"MIG_LAYOUT_CHECK_INTERVAL"},
+		},
+		&cli.DurationFlag{
+			Name:        "mig-reconfiguration-grace-period",
+			Usage:       "the period for which the devices of the resources affected by a change of the MIG layout are drained before their plugins are restarted; 0 restarts the plugins immediately",
+			Destination: &migReconfigurationGracePeriod,
+			EnvVars:     []string{"MIG_RECONFIGURATION_GRACE_PERIOD"},
+		},
+		&cli.BoolFlag{
+			Name:        "mig-reconfiguration-annotation",
+			Usage:       "write the state of MIG reconfigurations (draining, done or failed) to the nvidia.com/device-plugin.mig-reconfiguration node annotation",
+			Destination: &migReconfigurationAnnotation,
+			EnvVars:     []string{"MIG_RECONFIGURATION_ANNOTATION"},
+		},
+		&cli.DurationFlag{
+			Name:        "stale-socket-gc-interval",
+			Value:       time.Minute,
+			Usage:       "the interval at which the sockets left behind by crashed device plugins are removed; a socket is only removed if no plugin accepts connections on it in consecutive collections. 0 disables the removal",
+			Destination: &staleSocketGCInterval,
+			EnvVars:     []string{"STALE_SOCKET_GC_INTERVAL"},
+		},
+		&cli.BoolFlag{
+			Name:        "mark-unhealthy-on-shutdown",
+			Usage:       "advertise all devices as unhealthy to the kubelet when the plugin is terminated, e.g. before a node is decommissioned",
+			Destination: &markUnhealthyOnShutdown,
+			EnvVars:     []string{"MARK_UNHEALTHY_ON_SHUTDOWN"},
+		},
+		&cli.DurationFlag{
+			Name:        "shutdown-grace-period",
+			Usage:       "the period for which the plugins keep serving after the plugin is terminated, e.g. to allow the kubelet to process the final device update; must be shorter than the termination grace period of the pod",
+			Destination: &shutdownGracePeriod,
+			EnvVars:     []string{"SHUTDOWN_GRACE_PERIOD"},
+		},
+		&cli.IntFlag{
+			Name:        "xid-history-size",
+			Usage:       "the number of Xid events kept per device for the debug endpoints and metrics; 0 disables the history",
+			Value:       metrics.DefaultXidHistorySize,
+			Destination: &xidHistorySize,
+			EnvVars:     []string{"XID_HISTORY_SIZE"},
+		},
+		&cli.BoolFlag{
+			Name:        "wear-metrics",
+			Usage:       "expose the retired pages, remapped rows and energy consumption of the GPUs as metrics, which are queried through NVML on each scrape",
+			Destination: &wearMetrics,
+			EnvVars:     []string{"WEAR_METRICS"},
+		},
+		&cli.StringFlag{
+			Name:        "allocation-status-file",
+			Usage:       "the path of a JSON file to which the pods and containers that each device or replica is allocated to are written; an empty path disables the file",
+			Destination: &allocationStatusFile,
+			EnvVars:     []string{"ALLOCATION_STATUS_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:        "allocation-attribution-interval",
+			Value:       attribution.DefaultInterval,
+			Usage:       "the interval at which the pods and containers that the devices are allocated to are queried from the kubelet for the metrics and the allocation status file",
+			Destination: &allocationAttributionInterval,
+			EnvVars:     []string{"ALLOCATION_ATTRIBUTION_INTERVAL"},
+		},
 	}
+	c.Flags = append(c.Flags, kubeClientConfig.Flags()...)
+	c.Flags = append(c.Flags, nodeConfig.Flags()...)
+	c.Flags = append(c.Flags, startupConfig.Flags()...)
 
-	err := c.Run(os.Args)
-	if err != nil {
-		klog.Error(err)
-		os.Exit(1)
-	}
+	return c
 }
 
 func validateFlags(infolib nvinfo.Interface, config *spec.Config) error {
@@ -143,18 +654,36 @@ func validateFlags(infolib nvinfo.Interface, config *spec.Config) error {
 		return fmt.Errorf("invalid --device-list-strategy option: %v", err)
 	}
 
+	containerRuntimeMode, err := resolveContainerRuntimeMode(infolib, config.Flags.Plugin.GetContainerRuntimeMode())
+	if err != nil {
+		return fmt.Errorf("invalid --container-runtime-mode option: %v", err)
+	}
+
 	hasNvml, _ := infolib.HasNvml()
-	if deviceListStrategies.IsCDIEnabled() && !hasNvml {
-		return fmt.Errorf("CDI --device-list-strategy options are only supported on NVML-based systems")
+	if deviceListStrategies.IsCDIEnabled() && !hasNvml && containerRuntimeMode != spec.ContainerRuntimeModeCSV {
+		return fmt.Errorf("CDI --device-list-strategy options are only supported on NVML-based systems or with --container-runtime-mode=csv")
 	}
 
 	if *config.Flags.Plugin.DeviceIDStrategy != spec.DeviceIDStrategyUUID && *config.Flags.Plugin.DeviceIDStrategy != spec.DeviceIDStrategyIndex {
 		return fmt.Errorf("invalid --device-id-strategy option: %v", *config.Flags.Plugin.DeviceIDStrategy)
 	}
 
+	if config.Flags.Plugin.GetGRPCKeepaliveTime() < 0 || config.Flags.Plugin.GetGRPCKeepaliveTimeout() < 0 {
+		return fmt.Errorf("invalid --grpc-keepalive-time or --grpc-keepalive-timeout option: must not be negative")
+	}
+	if config.Flags.Plugin.GetListAndWatchLivenessInterval() < 0 {
+		return fmt.Errorf("invalid --list-and-watch-liveness-interval option: must not be negative")
+	}
+
 	if config.Sharing.SharingStrategy() == spec.SharingStrategyMPS {
+		// With the mixed strategy, only MIG devices can be shared using MPS
+		// and these require a daemon per MIG device.
 		if *config.Flags.MigStrategy == spec.MigStrategyMixed {
-			return fmt.Errorf("using --mig-strategy=mixed is not supported with MPS")
+			for _, r := range config.Sharing.MPS.Resources {
+				if !r.PerMigDevice {
+					return fmt.Errorf("using --mig-strategy=mixed is not supported with MPS without perMigDevice: %v", r.Name)
+				}
+			}
 		}
 		if config.Flags.MpsRoot == nil || *config.Flags.MpsRoot == "" {
 			return fmt.Errorf("using MPS requires --mps-root to be specified")
@@ -164,8 +693,8 @@ func validateFlags(infolib nvinfo.Interface, config *spec.Config) error {
 	return nil
 }
 
-func loadConfig(c *cli.Context, flags []cli.Flag) (*spec.Config, error) {
-	config, err := spec.NewConfig(c, flags)
+func loadConfig(c *cli.Context, flags []cli.Flag, configFile string) (*spec.Config, error) {
+	config, err := spec.NewConfigFromFile(c, flags, configFile)
 	if err != nil {
 		return nil, fmt.Errorf("unable to finalize config: %v", err)
 	}
@@ -173,7 +702,168 @@ func loadConfig(c *cli.Context, flags []cli.Flag) (*spec.Config, error) {
 	return config, nil
 }
 
-func start(c *cli.Context, flags []cli.Flag) error {
+// options holds the state that is shared across plugin restarts.
+type options struct {
+	flags              []cli.Flag
+	nvmllib            nvml.Interface
+	signals            <-chan os.Signal
+	nodeStatusReporter *nodestatus.Reporter
+	topologyPublisher  *nodestatus.TopologyPublisher
+	buildInfoPublisher *nodestatus.BuildInfoPublisher
+	nvcaps             nvcaps.Interface
+	drainManager       *drain.Manager
+	drainSocket        string
+	debugServer        *debug.Server
+	adminServer        *admin.Server
+	inventory          *inventory.Server
+	metricsServer      *metrics.Server
+	healthTracker      *metrics.HealthTracker
+	pluginTracker      *metrics.PluginTracker
+	configTracker      *metrics.ConfigTracker
+	npdForwarder       *npd.Forwarder
+	xidHistory         *metrics.XidHistory
+	validations        *rm.ValidationCache
+	allocations        *attribution.Tracker
+	wear               *metrics.WearCollector
+	podResources       *podresources.Client
+	podAnnotations     *podresources.AnnotationGetter
+	newPodAnnotations  func() (*podresources.AnnotationGetter, error)
+	featureGates       *featuregates.Collector
+	buildInfo          *metrics.BuildInfo
+	rollback           *rollback.Manager
+	nodeEvents         rollback.EventRecorder
+	watchConfigFile    string
+	migWatcher         *mig.Watcher
+	migReconfiguration *migReconfiguration
+	socketCollector    *cleanup.SocketCollector
+	conflictPolicy     conflict.Policy
+
+	markUnhealthyOnShutdown bool
+	shutdownGracePeriod     time.Duration
+
+	// unmetDriverVersionGates holds the reasons of the driver version gates
+	// that were last reported as unmet so that events are not repeated on
+	// every restart of the plugins.
+	unmetDriverVersionGates map[spec.ResourceName]string
+}
+
+// drainer returns the drainer passed to the plugins, or nil if the drain API is disabled.
+func (o *options) drainer() plugin.Drainer {
+	if o.drainManager == nil {
+		return nil
+	}
+	return o.drainManager
+}
+
+// healthEventReporter returns the reporter passed to the resource managers, or
+// nil if health events are neither forwarded to node-problem-detector, nor
+// recorded in the Xid history, nor used to detect removed GPUs.
+func (o *options) healthEventReporter() rm.HealthEventReporter {
+	var reporters rm.HealthEventReporters
+	if o.migWatcher != nil {
+		reporters = append(reporters, gpuRemovalDetector{o.migWatcher})
+	}
+	if o.npdForwarder != nil {
+		reporters = append(reporters, o.npdForwarder)
+	}
+	if o.xidHistory != nil {
+		reporters = append(reporters, o.xidHistory)
+	}
+	if len(reporters) == 0 {
+		return nil
+	}
+	return reporters
+}
+
+// xidFallenOffTheBus is the Xid that NVML reports when a GPU is removed or
+// has fallen off the bus.
+const xidFallenOffTheBus = 79
+
+// gpuRemovalDetector triggers an immediate check of the devices of the node
+// when a GPU is reported to have fallen off the bus, so that the plugins of
+// the affected resources are reconciled without waiting for the next check.
+type gpuRemovalDetector struct {
+	watcher *mig.Watcher
+}
+
+func (d gpuRemovalDetector) ReportHealthEvent(e rm.HealthEvent) {
+	if e.Xid == xidFallenOffTheBus {
+		d.watcher.Trigger()
+	}
+}
+
+// podResourcesLister returns the PodResources lister passed to the plugins, or
+// nil if no PodResources client was created.
+func (o *options) podResourcesLister() plugin.PodResourcesLister {
+	if o.podResources == nil {
+		return nil
+	}
+	return o.podResources
+}
+
+// podAnnotationGetter returns the getter for pod annotations passed to the
+// plugins, or nil if the config does not require pod annotations or no kube
+// client could be created. The getter is created on first use.
+func (o *options) podAnnotationGetter(config *spec.Config) plugin.PodAnnotationGetter {
+	if !requiresPodAnnotations(config) {
+		return nil
+	}
+	if o.podAnnotations == nil && o.newPodAnnotations != nil {
+		getter, err := o.newPodAnnotations()
+		if err != nil {
+			klog.Warningf("Pod annotations are unavailable: %v", err)
+			return nil
+		}
+		o.podAnnotations = getter
+	}
+	if o.podAnnotations == nil {
+		return nil
+	}
+	return o.podAnnotations
+}
+
+// requiresPodAnnotations returns whether any resource of the config uses a
+// feature that reads the annotations or specs of the pods from the API server.
+func requiresPodAnnotations(config *spec.Config) bool {
+	if config.Allocation.GetCUDACompatPolicy() != nil || len(config.Allocation.GetRuntimeClasses()) > 0 {
+		return true
+	}
+	if config.Allocation != nil {
+		for _, r := range config.Allocation.Resources {
+			if r.AllowExclusive {
+				return true
+			}
+		}
+	}
+	if config.Sharing.MPS != nil {
+		for _, r := range config.Sharing.MPS.Resources {
+			if r.MaxThreadPercentage != nil || r.TrustedWorkloads != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// managerOptions returns the options passed to the plugin manager to share
+// the state held by the options with the plugins.
+func (o *options) managerOptions(config *spec.Config) []manager.Option {
+	return []manager.Option{
+		manager.WithNVCaps(o.nvcaps),
+		manager.WithDrainer(o.drainer()),
+		manager.WithHealthRecorder(o.healthTracker),
+		manager.WithMetricsRecorder(o.pluginTracker),
+		manager.WithHealthEventReporter(o.healthEventReporter()),
+		manager.WithValidationCache(o.validations),
+		manager.WithPodResources(o.podResourcesLister()),
+		manager.WithPodAnnotations(o.podAnnotationGetter(config)),
+		// Devices only need to be retained if the config can change while
+		// pods are running.
+		manager.WithRetainedDevices(o.watchConfigFile != ""),
+	}
+}
+
+func start(c *cli.Context, o *options) error {
 	klog.Info("Starting FS watcher.")
 	watcher, err := watch.Files(pluginapi.DevicePluginPath)
 	if err != nil {
@@ -181,11 +871,60 @@ func start(c *cli.Context, flags []cli.Flag) error {
 	}
 	defer watcher.Close()
 
-	klog.Info("Starting OS watcher.")
-	sigs := watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	configWatcher, err := newConfigWatcher(o.watchConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to create FS watcher for %s: %v", o.watchConfigFile, err)
+	}
+	defer configWatcher.Close()
+
+	sigs := o.signals
+	if sigs == nil {
+		klog.Info("Starting OS watcher.")
+		sigs = watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	}
+
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+	go o.nodeStatusReporter.Run(ctx)
+	go o.npdForwarder.Run(ctx)
+	go o.migWatcher.Run(ctx)
+	go o.socketCollector.Run(ctx)
+	go o.allocations.Run(ctx)
+	go o.xidHistory.Run(ctx)
+	go func() {
+		if err := o.debugServer.ListenAndServe(ctx); err != nil {
+			klog.Errorf("Debug server failed: %v", err)
+		}
+	}()
+	go func() {
+		if err := o.metricsServer.ListenAndServe(ctx); err != nil {
+			klog.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+	go func() {
+		if err := o.adminServer.ListenAndServe(ctx); err != nil {
+			klog.Errorf("Admin API failed: %v", err)
+		}
+	}()
+	go func() {
+		if err := o.inventory.ListenAndServe(ctx); err != nil {
+			klog.Errorf("Inventory API failed: %v", err)
+		}
+	}()
+	if o.drainManager != nil {
+		go o.drainManager.Run(ctx)
+	}
+	if o.drainSocket != "" {
+		go func() {
+			if err := o.drainManager.ListenAndServe(ctx, o.drainSocket); err != nil {
+				klog.Errorf("Drain API failed: %v", err)
+			}
+		}()
+	}
 
 	var started bool
 	var restartTimeout <-chan time.Time
+	var configReload <-chan time.Time
 	var plugins []plugin.Interface
 restart:
 	// If we are restarting, stop plugins from previous run.
@@ -197,8 +936,11 @@ restart:
 	}
 
 	klog.Info("Starting Plugins.")
-	plugins, restartPlugins, err := startPlugins(c, flags)
+	plugins, restartPlugins, err := startPlugins(c, o)
 	if err != nil {
+		if o.rollback.Reject(err) {
+			goto restart
+		}
 		return fmt.Errorf("error starting plugins: %v", err)
 	}
 	started = true
@@ -207,6 +949,7 @@ restart:
 		klog.Infof("Failed to start one or more plugins. Retrying in 30s...")
 		restartTimeout = time.After(30 * time.Second)
 	}
+	rollbackDeadline := o.rollback.Deadline()
 
 	// Start an infinite loop, waiting for several indicators to either log
 	// some messages, trigger a restart of the plugins, or exit the program.
@@ -216,6 +959,90 @@ restart:
 		case <-restartTimeout:
 			goto restart
 
+		// If the evaluation window of a new config has ended, either persist
+		// it as the last-known-good config or roll it back if the plugins
+		// are not running with healthy devices.
+		case <-rollbackDeadline:
+			rollbackDeadline = nil
+			if err := checkPlugins(plugins, restartPlugins); err != nil {
+				if o.rollback.Reject(err) {
+					goto restart
+				}
+				continue
+			}
+			if err := o.rollback.Confirm(); err != nil {
+				klog.Warningf("Failed to persist last-known-good config: %v", err)
+			}
+
+		// If GPUs were attached or removed, or the MIG layout of the node
+		// was changed externally, only restart the plugins of the resources
+		// whose devices changed. With a drain grace period, the devices of
+		// these resources are drained first.
+		case changed := <-o.migWatcher.Changes():
+			klog.Infof("GPUs %v were attached, removed or had their MIG layout changed, reconciling plugins.", changed)
+			if beginMIGReconfiguration(c, o, plugins) {
+				continue
+			}
+			plugins, restartPlugins, err = reconcilePlugins(c, o, plugins)
+			if err != nil {
+				klog.Errorf("Failed to reconcile plugins, restarting: %v", err)
+				goto restart
+			}
+			if restartPlugins {
+				klog.Infof("Failed to start one or more plugins. Retrying in 30s...")
+				restartTimeout = time.After(30 * time.Second)
+			}
+
+		// If the grace period for draining the devices affected by a change
+		// of the MIG layout ended, restart the plugins of these resources.
+		case <-o.migReconfiguration.Deadline():
+			klog.Infof("MIG reconfiguration grace period ended, reconciling plugins.")
+			plugins, restartPlugins, err = reconcilePlugins(c, o, plugins)
+			o.migReconfiguration.End(err)
+			if err != nil {
+				klog.Errorf("Failed to reconcile plugins, restarting: %v", err)
+				goto restart
+			}
+			if restartPlugins {
+				klog.Infof("Failed to start one or more plugins. Retrying in 30s...")
+				restartTimeout = time.After(30 * time.Second)
+			}
+
+		// If the config file changed, reload the plugins once the changes
+		// settled. A config that fails to load is rejected and the running
+		// plugins are kept.
+		case event := <-configWatcher.Events():
+			if configWatcher.Changed(event) {
+				configReload = time.After(configReloadDelay)
+			}
+
+		case err := <-configWatcher.Errors():
+			klog.Infof("inotify: %s", err)
+
+		case <-configReload:
+			configReload = nil
+			klog.Infof("Config file %s changed, reloading plugins.", o.watchConfigFile)
+			reloaded, failed, err := reloadPlugins(c, o, plugins)
+			if err != nil {
+				if o.rollback.Reject(err) {
+					plugins = reloaded
+					goto restart
+				}
+				if reloaded == nil {
+					klog.Errorf("Failed to reload plugins, restarting: %v", err)
+					plugins = nil
+					goto restart
+				}
+				klog.Errorf("Failed to reload config, keeping the running plugins: %v", err)
+				continue
+			}
+			plugins, restartPlugins = reloaded, failed
+			if restartPlugins {
+				klog.Infof("Failed to start one or more plugins. Retrying in 30s...")
+				restartTimeout = time.After(30 * time.Second)
+			}
+			rollbackDeadline = o.rollback.Deadline()
+
 		// Detect a kubelet restart by watching for a newly created
 		// 'pluginapi.KubeletSocket' file. When this occurs, restart this loop,
 		// restarting all of the plugins in the process.
@@ -239,6 +1066,7 @@ restart:
 				goto restart
 			default:
 				klog.Infof("Received signal \"%v\", shutting down.", s)
+				o.terminate(plugins, sigs)
 				goto exit
 			}
 		}
@@ -251,16 +1079,135 @@ exit:
 	return nil
 }
 
-func startPlugins(c *cli.Context, flags []cli.Flag) ([]plugin.Interface, bool, error) {
+func startPlugins(c *cli.Context, o *options) ([]plugin.Interface, bool, error) {
+	config, plugins, err := getPlugins(c, o)
+	if err != nil {
+		return nil, false, err
+	}
+	return runPlugins(c, o, config, plugins)
+}
+
+// runPlugins starts the specified plugins that have devices to serve. The
+// returned bool indicates whether one or more of the plugins failed to start.
+func runPlugins(c *cli.Context, o *options, config *spec.Config, plugins []plugin.Interface) ([]plugin.Interface, bool, error) {
+	o.updateSources(c, config, plugins)
+
+	// The sockets left behind by crashed plugins are removed before the
+	// plugins bind their sockets.
+	if err := o.socketCollector.Collect(); err != nil {
+		klog.Warningf("Failed to remove stale plugin sockets: %v", err)
+	}
+
+	if err := o.checkConflicts(plugins); err != nil {
+		return nil, false, err
+	}
+
+	// Loop through all plugins, starting them if they have any devices
+	// to serve. If even one plugin fails to start properly, try
+	// starting them all again.
+	started := 0
+	for _, p := range plugins {
+		// Just continue if there are no devices to serve for plugin p.
+		if len(p.Devices()) == 0 {
+			continue
+		}
+
+		// Start the gRPC server for plugin p and connect it with the kubelet.
+		if err := p.Start(); err != nil {
+			klog.Errorf("Failed to start plugin: %v", err)
+			return plugins, true, nil
+		}
+		started++
+	}
+
+	if started == 0 {
+		klog.Info("No devices found. Waiting indefinitely.")
+	}
+
+	return plugins, false, nil
+}
+
+// checkConflicts detects other device plugins that advertise the GPUs of the
+// plugins that are about to be started. Depending on the conflict policy, the
+// conflicts are logged or the plugins are not started.
+func (o *options) checkConflicts(plugins []plugin.Interface) error {
+	resources := make(map[spec.ResourceName]rm.Devices)
+	for _, p := range plugins {
+		if len(p.Devices()) > 0 {
+			resources[p.Resource()] = p.Devices()
+		}
+	}
+	conflicts, err := conflict.Detect(pluginapi.DevicePluginPath, resources)
+	if err != nil {
+		klog.Warningf("Failed to detect conflicting device plugins: %v", err)
+		return nil
+	}
+	for _, c := range conflicts {
+		klog.Warningf("Detected a conflicting device plugin at %v", c)
+	}
+	if len(conflicts) > 0 && o.conflictPolicy == conflict.PolicyFail {
+		return fmt.Errorf("refusing to start: %d conflicting device plugins detected", len(conflicts))
+	}
+	return nil
+}
+
+// reconcilePlugins re-enumerates the plugins after GPUs were attached or
+// removed, or the MIG layout of the node changed. Only the plugins whose
+// devices changed are restarted; the running plugins of the other resources
+// are kept.
+func reconcilePlugins(c *cli.Context, o *options, running []plugin.Interface) ([]plugin.Interface, bool, error) {
+	config, plugins, err := getPlugins(c, o)
+	if err != nil {
+		return running, false, err
+	}
+	plugins, restartPlugins := reconcile(running, plugins)
+	o.updateSources(c, config, plugins)
+	return plugins, restartPlugins, nil
+}
+
+// beginMIGReconfiguration drains the devices of the running plugins that are
+// affected by a change of the MIG layout. It returns true if the plugins are
+// reconciled once the drain grace period ended rather than immediately.
+func beginMIGReconfiguration(c *cli.Context, o *options, running []plugin.Interface) bool {
+	if o.migReconfiguration == nil {
+		return false
+	}
+	_, plugins, err := getPlugins(c, o)
+	if err != nil {
+		klog.Warningf("Failed to determine the resources affected by the MIG layout change: %v", err)
+		return false
+	}
+	return o.migReconfiguration.Begin(affectedPlugins(running, plugins))
+}
+
+// getPlugins loads the config file and creates the plugins for the devices
+// on the node without starting them. The config with the default resources
+// added is returned alongside the plugins.
+func getPlugins(c *cli.Context, o *options) (*spec.Config, []plugin.Interface, error) {
 	// Load the configuration file
 	klog.Info("Loading configuration.")
-	config, err := loadConfig(c, flags)
+	configFile := c.String("config-file")
+	if o.rollback != nil {
+		var err error
+		configFile, err = o.rollback.ConfigFile()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to determine config file: %v", err)
+		}
+	}
+	config, err := loadConfig(c, o.flags, configFile)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to load config: %v", err)
+		return nil, nil, fmt.Errorf("unable to load config: %v", err)
 	}
 	spec.DisableResourceNamingInConfig(logger.ToKlog, config)
 
-	nvmllib := nvml.New()
+	featureGates, err := featuregates.Default.Gates(config.Flags.Plugin.GetFeatureGates())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid feature gates: %v", err)
+	}
+	klog.Infof("Feature gates: %v", featureGates)
+	o.featureGates.Record(featureGates)
+
+	nvmllib := o.nvmllib
 	devicelib := device.New(nvmllib)
 	infolib := nvinfo.New(
 		nvinfo.WithNvmlLib(nvmllib),
@@ -269,57 +1216,307 @@ func startPlugins(c *cli.Context, flags []cli.Flag) ([]plugin.Interface, bool, e
 
 	err = validateFlags(infolib, config)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to validate flags: %v", err)
+		return nil, nil, fmt.Errorf("unable to validate flags: %v", err)
 	}
 
 	// Update the configuration file with default resources.
 	klog.Info("Updating config with default resource matching patterns.")
 	err = rm.AddDefaultResourcesToConfig(infolib, nvmllib, devicelib, config)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to add default resources to config: %v", err)
+		return nil, nil, fmt.Errorf("unable to add default resources to config: %v", err)
 	}
 
-	// Print the config to the output.
-	configJSON, err := json.MarshalIndent(config, "", "  ")
+	o.reportDriverVersionGates(nvmllib, config)
+
+	// Print the config to the output without the values of config fragments.
+	redacted, err := config.Redacted()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to redact config: %v", err)
+	}
+	configJSON, err := json.MarshalIndent(redacted, "", "  ")
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to marshal config to JSON: %v", err)
+		return nil, nil, fmt.Errorf("failed to marshal config to JSON: %v", err)
 	}
 	klog.Infof("\nRunning with config:\n%v", string(configJSON))
 
 	// Get the set of plugins.
 	klog.Info("Retrieving plugins.")
-	pluginManager, err := NewPluginManager(infolib, nvmllib, devicelib, config)
+	pluginManager, err := NewPluginManager(infolib, nvmllib, devicelib, config, append(o.managerOptions(config), manager.WithFeatureGates(featureGates))...)
 	if err != nil {
-		return nil, false, fmt.Errorf("error creating plugin manager: %v", err)
+		return nil, nil, fmt.Errorf("error creating plugin manager: %v", err)
 	}
 	plugins, err := pluginManager.GetPlugins()
 	if err != nil {
-		return nil, false, fmt.Errorf("error getting plugins: %v", err)
+		return nil, nil, fmt.Errorf("error getting plugins: %v", err)
 	}
+	return config, plugins, nil
+}
 
-	// Loop through all plugins, starting them if they have any devices
-	// to serve. If even one plugin fails to start properly, try
-	// starting them all again.
-	started := 0
-	for _, p := range plugins {
-		// Just continue if there are no devices to serve for plugin p.
-		if len(p.Devices()) == 0 {
+// reportDriverVersionGates emits a node event for each driver version gate of
+// the config that is not met by the driver on the node. The devices of these
+// resources are moved or removed when the device map is built.
+func (o *options) reportDriverVersionGates(nvmllib nvml.Interface, config *spec.Config) {
+	gates := config.Devices.DriverVersionGates()
+	if len(gates) == 0 {
+		o.unmetDriverVersionGates = nil
+		return
+	}
+	if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+		klog.Warningf("Failed to initialize NVML to check driver version gates: %v", ret)
+		return
+	}
+	defer func() {
+		_ = nvmllib.Shutdown()
+	}()
+
+	versions, err := rm.GetDriverVersions(nvmllib)
+	if err != nil {
+		klog.Warningf("Failed to check driver version gates: %v", err)
+		return
+	}
+	unmet, err := rm.UnmetDriverVersionGates(gates, versions)
+	if err != nil {
+		klog.Warningf("Failed to check driver version gates: %v", err)
+		return
+	}
+	for resource, reason := range unmet {
+		if o.unmetDriverVersionGates[resource] == reason || o.nodeEvents == nil {
 			continue
 		}
+		o.nodeEvents.Event(corev1.EventTypeWarning, "DriverVersionGateUnmet",
+			fmt.Sprintf("Devices are not advertised as %v: the %v", resource, reason))
+	}
+	o.unmetDriverVersionGates = unmet
+}
 
-		// Start the gRPC server for plugin p and connect it with the kubelet.
-		if err := p.Start(); err != nil {
-			klog.Errorf("Failed to start plugin: %v", err)
-			return plugins, true, nil
+// updateSources updates the components that report on the plugins with the
+// specified plugins.
+func (o *options) updateSources(c *cli.Context, config *spec.Config, plugins []plugin.Interface) {
+	var sources []nodestatus.Source
+	for _, p := range plugins {
+		sources = append(sources, p)
+	}
+	if err := o.nodeStatusReporter.Update(config, sources); err != nil {
+		klog.Warningf("Failed to update node status reporter: %v", err)
+	}
+	if err := o.topologyPublisher.Publish(c.Context, sources); err != nil {
+		klog.Warningf("Failed to publish sharing topology: %v", err)
+	}
+	var drainSources []drain.Source
+	for _, p := range plugins {
+		drainSources = append(drainSources, p)
+	}
+	o.drainManager.Update(drainSources)
+	var debugSources []debug.Source
+	for _, p := range plugins {
+		debugSources = append(debugSources, p)
+	}
+	o.debugServer.Update(config, debugSources)
+	var adminSources []admin.Source
+	for _, p := range plugins {
+		adminSources = append(adminSources, p)
+	}
+	o.adminServer.Update(adminSources)
+	var inventorySources []inventory.Source
+	for _, p := range plugins {
+		inventorySources = append(inventorySources, p)
+	}
+	o.inventory.Update(inventorySources)
+	var attributionSources []attribution.Source
+	for _, p := range plugins {
+		attributionSources = append(attributionSources, p)
+	}
+	o.allocations.Update(attributionSources)
+	var wearSources []metrics.WearSource
+	for _, p := range plugins {
+		wearSources = append(wearSources, p)
+	}
+	o.wear.Update(wearSources)
+	o.configTracker.Update(config.Provenance)
+	o.updateBuildInfo(c, config)
+}
+
+// updateBuildInfo updates the enabled feature gates and the config hash that
+// are exposed in the build info metric and node labels.
+func (o *options) updateBuildInfo(c *cli.Context, config *spec.Config) {
+	// The feature gates were validated when the plugins were created.
+	gates, _ := featuregates.Default.Gates(config.Flags.Plugin.GetFeatureGates())
+	var enabled []string
+	for _, f := range gates.EnabledFeatures() {
+		enabled = append(enabled, string(f))
+	}
+	hash, err := nodestatus.HashConfig(config)
+	if err != nil {
+		klog.Warningf("Failed to hash config: %v", err)
+	}
+	o.buildInfo.Update(enabled, hash)
+	if err := o.buildInfoPublisher.Publish(c.Context, enabled, hash); err != nil {
+		klog.Warningf("Failed to publish build info labels: %v", err)
+	}
+}
+
+// newNodeStatusReporter creates a reporter for the node status annotation.
+// A nil reporter is returned if the annotation is disabled.
+func newNodeStatusReporter(kubeClientConfig *flags.KubeClientConfig, nodeConfig *flags.NodeConfig, interval time.Duration) (*nodestatus.Reporter, error) {
+	if interval <= 0 {
+		return nil, nil
+	}
+	if nodeConfig.Name == "" {
+		return nil, fmt.Errorf("--node-name must be specified when --node-status-interval is set")
+	}
+	clientSets, err := kubeClientConfig.NewClientSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientsets: %w", err)
+	}
+	return nodestatus.NewReporter(clientSets.Core, nodeConfig.Name, interval, info.GetVersionParts()[0]), nil
+}
+
+// newTopologyPublisher creates a publisher for the sharing topology annotation.
+// A nil publisher is returned if the annotation is disabled.
+func newTopologyPublisher(kubeClientConfig *flags.KubeClientConfig, nodeConfig *flags.NodeConfig, enabled bool) (*nodestatus.TopologyPublisher, error) {
+	if !enabled {
+		return nil, nil
+	}
+	if nodeConfig.Name == "" {
+		return nil, fmt.Errorf("--node-name must be specified when --sharing-topology-annotation is set")
+	}
+	clientSets, err := kubeClientConfig.NewClientSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientsets: %w", err)
+	}
+	return nodestatus.NewTopologyPublisher(clientSets.Core, nodeConfig.Name), nil
+}
+
+// newReconfigurationPublisher creates a publisher for the MIG reconfiguration
+// annotation. A nil publisher is returned if the annotation is disabled.
+func newReconfigurationPublisher(kubeClientConfig *flags.KubeClientConfig, nodeConfig *flags.NodeConfig, enabled bool) (*nodestatus.ReconfigurationPublisher, error) {
+	if !enabled {
+		return nil, nil
+	}
+	if nodeConfig.Name == "" {
+		return nil, fmt.Errorf("--node-name must be specified when --mig-reconfiguration-annotation is set")
+	}
+	clientSets, err := kubeClientConfig.NewClientSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientsets: %w", err)
+	}
+	return nodestatus.NewReconfigurationPublisher(clientSets.Core, nodeConfig.Name), nil
+}
+
+// newBuildInfoPublisher creates a publisher for the build info node labels.
+// A nil publisher is returned if the labels are disabled.
+func newBuildInfoPublisher(kubeClientConfig *flags.KubeClientConfig, nodeConfig *flags.NodeConfig, enabled bool) (*nodestatus.BuildInfoPublisher, error) {
+	if !enabled {
+		return nil, nil
+	}
+	if nodeConfig.Name == "" {
+		return nil, fmt.Errorf("--node-name must be specified when --build-info-labels is set")
+	}
+	clientSets, err := kubeClientConfig.NewClientSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientsets: %w", err)
+	}
+	return nodestatus.NewBuildInfoPublisher(clientSets.Core, nodeConfig.Name, info.GetVersion(), info.GetGitCommit()), nil
+}
+
+// newRollbackManager creates a manager that rolls back failed config files.
+// A nil manager is returned if rollbacks are disabled. Events are only emitted
+// if the node name is known.
+func newRollbackManager(kubeClientConfig *flags.KubeClientConfig, nodeConfig *flags.NodeConfig, configFile string, lastKnownGoodFile string, window time.Duration) (*rollback.Manager, error) {
+	if window <= 0 || configFile == "" {
+		return nil, nil
+	}
+	var recorder rollback.EventRecorder
+	if nodeConfig.Name != "" {
+		clientSets, err := kubeClientConfig.NewClientSets()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create clientsets: %w", err)
 		}
-		started++
+		recorder = rollback.NewNodeEventRecorder(clientSets.Core, nodeConfig.Name, "nvidia-device-plugin")
 	}
+	return rollback.NewManager(configFile, lastKnownGoodFile, window, recorder), nil
+}
 
-	if started == 0 {
-		klog.Info("No devices found. Waiting indefinitely.")
+// newNodeEventRecorder creates a recorder for the events of the node. A nil
+// recorder is returned if the node name is not known or no kube client can be
+// created since events are informational only.
+func newNodeEventRecorder(kubeClientConfig *flags.KubeClientConfig, nodeConfig *flags.NodeConfig) rollback.EventRecorder {
+	if nodeConfig.Name == "" {
+		return nil
+	}
+	clientSets, err := kubeClientConfig.NewClientSets()
+	if err != nil {
+		klog.Warningf("Failed to create clientsets for node events: %v", err)
+		return nil
 	}
+	return rollback.NewNodeEventRecorder(clientSets.Core, nodeConfig.Name, "nvidia-device-plugin")
+}
 
-	return plugins, false, nil
+// newPodAnnotationGetter creates a getter for the annotations of the pods
+// that request exclusive access to their GPUs.
+func newPodAnnotationGetter(kubeClientConfig *flags.KubeClientConfig, nodeName string) (*podresources.AnnotationGetter, error) {
+	clientSets, err := kubeClientConfig.NewClientSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientsets: %w", err)
+	}
+	return podresources.NewAnnotationGetter(clientSets.Core, nodeName), nil
+}
+
+// checkPlugins checks that all plugins have started and that each started
+// plugin has at least one healthy device.
+func checkPlugins(plugins []plugin.Interface, restartPlugins bool) error {
+	if restartPlugins {
+		return fmt.Errorf("one or more plugins failed to start")
+	}
+	for _, p := range plugins {
+		devices := p.Devices()
+		if len(devices) == 0 {
+			continue
+		}
+		var healthy int
+		for _, d := range devices {
+			if d.Health == pluginapi.Healthy {
+				healthy++
+			}
+		}
+		if healthy == 0 {
+			return fmt.Errorf("no healthy devices for resource %v", p.Resource())
+		}
+	}
+	return nil
+}
+
+// newNVMLBroker creates a broker that runs this executable's nvml-broker
+// subcommand as a subprocess that listens on the specified socket.
+func newNVMLBroker(socket string) (*nvcaps.Broker, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine executable path: %w", err)
+	}
+	return nvcaps.NewBroker(socket, executable, broker.CommandName, "--socket", socket), nil
+}
+
+// terminate prepares the plugins for a shutdown of the plugin. If requested,
+// all devices are advertised as unhealthy, so that the kubelet stops allocating
+// them. By default the devices are kept healthy, since the kubelet keeps them
+// allocatable for a while after the plugin is gone; this avoids failing pods
+// during routine upgrades of the plugin. The plugins keep serving for the
+// shutdown grace period or until another signal is received.
+func (o *options) terminate(plugins []plugin.Interface, sigs <-chan os.Signal) {
+	if o.markUnhealthyOnShutdown {
+		for _, p := range plugins {
+			p.Terminate()
+		}
+	}
+	if o.shutdownGracePeriod <= 0 {
+		return
+	}
+	klog.Infof("Waiting %v before stopping plugins.", o.shutdownGracePeriod)
+	select {
+	case <-time.After(o.shutdownGracePeriod):
+	case s := <-sigs:
+		klog.Infof("Received signal \"%v\", stopping plugins.", s)
+	}
 }
 
 func stopPlugins(plugins []plugin.Interface) error {
@@ -0,0 +1,92 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+func TestPodAnnotationGetter(t *testing.T) {
+	percentage := 50
+	testCases := []struct {
+		description string
+		config      spec.Config
+		expected    bool
+	}{
+		{
+			description: "no feature requires pod annotations",
+			config:      spec.Config{},
+		},
+		{
+			description: "exclusive access",
+			config: spec.Config{
+				Allocation: &spec.Allocation{
+					Resources: []spec.AllocationResource{{Name: "nvidia.com/gpu", AllowExclusive: true}},
+				},
+			},
+			expected: true,
+		},
+		{
+			description: "runtime classes",
+			config: spec.Config{
+				Allocation: &spec.Allocation{
+					RuntimeClasses: []spec.RuntimeClass{{Name: "nvidia"}},
+				},
+			},
+			expected: true,
+		},
+		{
+			description: "MPS thread percentage",
+			config: spec.Config{
+				Sharing: spec.Sharing{
+					MPS: &spec.ReplicatedResources{
+						Resources: []spec.ReplicatedResource{{Name: "nvidia.com/gpu", MaxThreadPercentage: &percentage}},
+					},
+				},
+			},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var created int
+			o := &options{
+				newPodAnnotations: func() (*podresources.AnnotationGetter, error) {
+					created++
+					return podresources.NewAnnotationGetter(fake.NewSimpleClientset(), "node"), nil
+				},
+			}
+
+			getter := o.podAnnotationGetter(&tc.config)
+			o.podAnnotationGetter(&tc.config)
+
+			require.Equal(t, tc.expected, getter != nil)
+			if tc.expected {
+				require.Equal(t, 1, created)
+			} else {
+				require.Zero(t, created)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package planmig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/mig"
+)
+
+// CommandName is the name of the plan-mig subcommand.
+const CommandName = "plan-mig"
+
+const (
+	emitMigParted = "mig-parted"
+	emitConfig    = "config"
+)
+
+type options struct {
+	requests   string
+	emit       string
+	configName string
+}
+
+// requestsFile is the file with the desired mix of MIG devices.
+type requestsFile struct {
+	Requests map[string]int `json:"requests"`
+}
+
+// NewCommand constructs the plan-mig command.
+// The command computes a MIG layout of the GPUs on the node that provides the
+// requested MIG devices and prints the mig-parted config that applies the
+// layout, or the device plugin config that advertises it.
+func NewCommand() *cli.Command {
+	o := &options{}
+	return &cli.Command{
+		Name:  CommandName,
+		Usage: "Plan a MIG layout of the GPUs on the node for a mix of MIG device requests",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "requests",
+				Usage:       "the YAML file with the number of MIG devices requested per profile",
+				Required:    true,
+				Destination: &o.requests,
+			},
+			&cli.StringFlag{
+				Name:        "emit",
+				Value:       emitMigParted,
+				Usage:       "the config to emit for the planned layout:\n\t\t[mig-parted | config]",
+				Destination: &o.emit,
+			},
+			&cli.StringFlag{
+				Name:        "config-name",
+				Value:       "planned",
+				Usage:       "the name of the MIG config in the emitted mig-parted config",
+				Destination: &o.configName,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if o.emit != emitMigParted && o.emit != emitConfig {
+				return fmt.Errorf("unsupported config to emit: %v", o.emit)
+			}
+			requests, err := loadRequests(o.requests)
+			if err != nil {
+				return err
+			}
+
+			nvmllib := nvml.New()
+			gpus, err := mig.GetPlanGPUs(nvmllib, device.New(nvmllib))
+			if err != nil {
+				return fmt.Errorf("unable to get MIG-capable GPUs: %v", err)
+			}
+			plan, err := mig.NewPlan(gpus, requests)
+			if err != nil {
+				return fmt.Errorf("unable to plan MIG layout: %v", err)
+			}
+
+			var output []byte
+			switch o.emit {
+			case emitMigParted:
+				output, err = plan.MigPartedConfig(o.configName)
+			case emitConfig:
+				output, err = plan.DevicePluginConfig()
+			}
+			if err != nil {
+				return fmt.Errorf("unable to emit %v config: %v", o.emit, err)
+			}
+			_, err = c.App.Writer.Write(output)
+			return err
+		},
+	}
+}
+
+// loadRequests loads the requested MIG devices from the specified file. The
+// devices are requested by profile, e.g. 1g.5gb, or by their resource name,
+// e.g. nvidia.com/mig-1g.5gb.
+func loadRequests(filename string) (map[string]int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read requests file: %v", err)
+	}
+	var f requestsFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse requests file: %v", err)
+	}
+
+	requests := make(map[string]int)
+	for name, count := range f.Requests {
+		if count <= 0 {
+			return nil, fmt.Errorf("the number of %v devices must be > 0: %d", name, count)
+		}
+		profile := strings.TrimPrefix(strings.TrimPrefix(name, "nvidia.com/"), "mig-")
+		requests[profile] += count
+	}
+	return requests, nil
+}
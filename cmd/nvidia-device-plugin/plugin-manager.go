@@ -22,14 +22,17 @@ import (
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
 	"github.com/NVIDIA/k8s-device-plugin/internal/plugin/manager"
+	"github.com/NVIDIA/k8s-device-plugin/internal/writable"
 )
 
-// NewPluginManager creates an NVML-based plugin manager
-func NewPluginManager(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interface, config *spec.Config) (manager.Interface, error) {
+// NewPluginManager creates an NVML-based plugin manager.
+// The specified options are applied in addition to the options derived from the config.
+func NewPluginManager(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interface, config *spec.Config, opts ...manager.Option) (manager.Interface, error) {
 	var err error
 	switch *config.Flags.MigStrategy {
 	case spec.MigStrategyNone:
@@ -43,6 +46,20 @@ func NewPluginManager(infolib info.Interface, nvmllib nvml.Interface, devicelib
 	if err != nil {
 		return nil, fmt.Errorf("invalid device list strategy: %v", err)
 	}
+	if deviceListStrategies.IsCDIEnabled() {
+		if err := writable.Dir("CDI specifications", config.Flags.Plugin.GetCDISpecDir()).Check(); err != nil {
+			return nil, err
+		}
+	}
+
+	containerRuntimeMode, err := resolveContainerRuntimeMode(infolib, config.Flags.Plugin.GetContainerRuntimeMode())
+	if err != nil {
+		return nil, fmt.Errorf("invalid container runtime mode: %v", err)
+	}
+	klog.Infof("Generating allocate responses for the %q mode of the NVIDIA Container Runtime", containerRuntimeMode)
+	// The resolved mode is stored in the config so that the resource managers
+	// and plugins do not need to detect the platform again.
+	config.Flags.Plugin.ContainerRuntimeMode = &containerRuntimeMode
 
 	cdiHandler, err := cdi.New(infolib, nvmllib, devicelib,
 		cdi.WithDeviceListStrategies(deviceListStrategies),
@@ -50,20 +67,23 @@ func NewPluginManager(infolib info.Interface, nvmllib nvml.Interface, devicelib
 		cdi.WithTargetDriverRoot(*config.Flags.NvidiaDriverRoot),
 		cdi.WithNvidiaCTKPath(*config.Flags.Plugin.NvidiaCTKPath),
 		cdi.WithDeviceIDStrategy(*config.Flags.Plugin.DeviceIDStrategy),
-		cdi.WithVendor("k8s.device-plugin.nvidia.com"),
+		cdi.WithVendor(cdi.Vendor),
 		cdi.WithGdsEnabled(*config.Flags.GDSEnabled),
 		cdi.WithMofedEnabled(*config.Flags.MOFEDEnabled),
+		cdi.WithContainerRuntimeMode(containerRuntimeMode),
+		cdi.WithSpecDir(config.Flags.Plugin.GetCDISpecDir()),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create cdi handler: %v", err)
 	}
 
-	m, err := manager.New(infolib, nvmllib, devicelib,
+	opts = append([]manager.Option{
 		manager.WithCDIHandler(cdiHandler),
 		manager.WithConfig(config),
 		manager.WithFailOnInitError(*config.Flags.FailOnInitError),
 		manager.WithMigStrategy(*config.Flags.MigStrategy),
-	)
+	}, opts...)
+	m, err := manager.New(infolib, nvmllib, devicelib, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create plugin manager: %v", err)
 	}
@@ -74,3 +94,26 @@ func NewPluginManager(infolib info.Interface, nvmllib nvml.Interface, devicelib
 
 	return m, nil
 }
+
+// resolveContainerRuntimeMode resolves the mode of the NVIDIA Container Runtime
+// on the node. In auto mode, the csv mode is selected for Tegra-based systems
+// where NVML is not available, matching the platform detection of the runtime.
+func resolveContainerRuntimeMode(infolib info.Interface, mode string) (string, error) {
+	isTegra, _ := infolib.HasTegraFiles()
+	switch mode {
+	case spec.ContainerRuntimeModeAuto:
+		hasNVML, _ := infolib.HasNvml()
+		if isTegra && !hasNVML {
+			return spec.ContainerRuntimeModeCSV, nil
+		}
+		return spec.ContainerRuntimeModeLegacy, nil
+	case spec.ContainerRuntimeModeLegacy:
+		return mode, nil
+	case spec.ContainerRuntimeModeCSV:
+		if !isTegra {
+			return "", fmt.Errorf("the %q mode is only supported on Tegra-based systems", mode)
+		}
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown mode %q", mode)
+}
@@ -0,0 +1,108 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"slices"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// reconcile replaces the running plugins with the newly created plugins of
+// the resources whose devices changed. The running plugins of resources with
+// unchanged devices are kept and the new plugins for them are discarded. The
+// plugins of resources that no longer exist are stopped. The returned bool
+// indicates whether one or more of the new plugins failed to start.
+func reconcile(running []plugin.Interface, plugins []plugin.Interface) ([]plugin.Interface, bool) {
+	current := make(map[spec.ResourceName]plugin.Interface)
+	for _, p := range running {
+		current[p.Resource()] = p
+	}
+
+	var reconciled []plugin.Interface
+	var restartPlugins bool
+	for _, p := range plugins {
+		old, exists := current[p.Resource()]
+		delete(current, p.Resource())
+		if exists && sameDevices(old.Devices(), p.Devices()) {
+			reconciled = append(reconciled, old)
+			continue
+		}
+		if exists {
+			klog.Infof("Devices of resource %v changed, restarting its plugin.", p.Resource())
+			if err := old.Stop(); err != nil {
+				klog.Errorf("Failed to stop plugin for resource %v: %v", p.Resource(), err)
+			}
+		}
+		reconciled = append(reconciled, p)
+		if len(p.Devices()) == 0 {
+			continue
+		}
+		if err := p.Start(); err != nil {
+			klog.Errorf("Failed to start plugin: %v", err)
+			restartPlugins = true
+		}
+	}
+
+	for resource, old := range current {
+		klog.Infof("Resource %v no longer exists, stopping its plugin.", resource)
+		if err := old.Stop(); err != nil {
+			klog.Errorf("Failed to stop plugin for resource %v: %v", resource, err)
+		}
+	}
+
+	return reconciled, restartPlugins
+}
+
+// sameDevices checks whether two sets of devices have the same IDs and
+// device nodes. The health of the devices is not compared.
+func sameDevices(devices rm.Devices, other rm.Devices) bool {
+	if len(devices) != len(other) {
+		return false
+	}
+	for id, d := range devices {
+		o, exists := other[id]
+		if !exists {
+			return false
+		}
+		if d.Index != o.Index || !slices.Equal(d.Paths, o.Paths) {
+			return false
+		}
+	}
+	return true
+}
+
+// affectedPlugins returns the running plugins whose devices would be changed
+// or removed by reconciling them with the newly created plugins.
+func affectedPlugins(running []plugin.Interface, plugins []plugin.Interface) []plugin.Interface {
+	updated := make(map[spec.ResourceName]plugin.Interface)
+	for _, p := range plugins {
+		updated[p.Resource()] = p
+	}
+	var affected []plugin.Interface
+	for _, p := range running {
+		if u, exists := updated[p.Resource()]; exists && sameDevices(p.Devices(), u.Devices()) {
+			continue
+		}
+		affected = append(affected, p)
+	}
+	return affected
+}
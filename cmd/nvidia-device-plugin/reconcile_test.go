@@ -0,0 +1,115 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testPlugin struct {
+	resource spec.ResourceName
+	devices  rm.Devices
+	started  bool
+	stopped  bool
+}
+
+var _ plugin.Interface = (*testPlugin)(nil)
+
+func (p *testPlugin) Resource() spec.ResourceName  { return p.resource }
+func (p *testPlugin) Devices() rm.Devices          { return p.devices }
+func (p *testPlugin) Start() error                 { p.started = true; return nil }
+func (p *testPlugin) Stop() error                  { p.stopped = true; return nil }
+func (p *testPlugin) Terminate()                   {}
+func (p *testPlugin) RefreshListAndWatch()         {}
+func (p *testPlugin) RecentEvents() []plugin.Event { return nil }
+func (p *testPlugin) ListAndWatchSnapshot() *plugin.ListAndWatchSnapshot {
+	return nil
+}
+func (p *testPlugin) Inventory() *plugin.ListAndWatchSnapshot {
+	return nil
+}
+func (p *testPlugin) SubscribeInventory() (<-chan struct{}, func()) {
+	return nil, func() {}
+}
+func (p *testPlugin) MPSDaemonStatus() *plugin.MPSDaemonStatus {
+	return nil
+}
+func (p *testPlugin) GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	return nil, nil
+}
+func (p *testPlugin) Allocate(context.Context, *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	return nil, nil
+}
+
+func testDevices(ids ...string) rm.Devices {
+	devices := make(rm.Devices)
+	for _, id := range ids {
+		devices[id] = &rm.Device{Paths: []string{"/dev/nvidia0"}, Index: "0:" + id}
+		devices[id].ID = id
+	}
+	return devices
+}
+
+func TestReconcile(t *testing.T) {
+	unchanged := &testPlugin{resource: "nvidia.com/mig-1g.10gb", devices: testDevices("MIG-0")}
+	changed := &testPlugin{resource: "nvidia.com/mig-2g.20gb", devices: testDevices("MIG-1")}
+	removed := &testPlugin{resource: "nvidia.com/mig-3g.40gb", devices: testDevices("MIG-2")}
+
+	newUnchanged := &testPlugin{resource: "nvidia.com/mig-1g.10gb", devices: testDevices("MIG-0")}
+	newChanged := &testPlugin{resource: "nvidia.com/mig-2g.20gb", devices: testDevices("MIG-1", "MIG-3")}
+	added := &testPlugin{resource: "nvidia.com/mig-7g.80gb", devices: testDevices("MIG-4")}
+	empty := &testPlugin{resource: "nvidia.com/gpu"}
+
+	plugins, restartPlugins := reconcile(
+		[]plugin.Interface{unchanged, changed, removed},
+		[]plugin.Interface{newUnchanged, newChanged, added, empty},
+	)
+	require.False(t, restartPlugins)
+	require.Equal(t, []plugin.Interface{unchanged, newChanged, added, empty}, plugins)
+
+	require.False(t, unchanged.stopped)
+	require.False(t, newUnchanged.started)
+	require.True(t, changed.stopped)
+	require.True(t, newChanged.started)
+	require.True(t, removed.stopped)
+	require.True(t, added.started)
+	require.False(t, empty.started)
+}
+
+func TestAffectedPlugins(t *testing.T) {
+	unchanged := &testPlugin{resource: "nvidia.com/mig-1g.10gb", devices: testDevices("MIG-0")}
+	changed := &testPlugin{resource: "nvidia.com/mig-2g.20gb", devices: testDevices("MIG-1")}
+	removed := &testPlugin{resource: "nvidia.com/mig-3g.40gb", devices: testDevices("MIG-2")}
+
+	affected := affectedPlugins(
+		[]plugin.Interface{unchanged, changed, removed},
+		[]plugin.Interface{
+			&testPlugin{resource: "nvidia.com/mig-1g.10gb", devices: testDevices("MIG-0")},
+			&testPlugin{resource: "nvidia.com/mig-2g.20gb", devices: testDevices("MIG-3")},
+			&testPlugin{resource: "nvidia.com/mig-7g.80gb", devices: testDevices("MIG-4")},
+		},
+	)
+	require.Equal(t, []plugin.Interface{changed, removed}, affected)
+}
@@ -0,0 +1,144 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/drain"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nodestatus"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// migReconfiguration drains the devices of the resources affected by a change
+// of the MIG layout for a grace period before their plugins are reconciled.
+// The drained devices are advertised as unhealthy, so that no new pods are
+// scheduled to them while the running pods finish.
+type migReconfiguration struct {
+	gracePeriod time.Duration
+	drains      *drain.Manager
+	publisher   *nodestatus.ReconfigurationPublisher
+
+	drainIDs []string
+	deadline <-chan time.Time
+}
+
+// newMIGReconfiguration creates a reconfiguration that drains devices with the
+// specified drain manager. A nil reconfiguration is returned if the grace
+// period is 0, in which case the plugins are reconciled immediately.
+func newMIGReconfiguration(gracePeriod time.Duration, drains *drain.Manager, publisher *nodestatus.ReconfigurationPublisher) *migReconfiguration {
+	if gracePeriod <= 0 {
+		return nil
+	}
+	return &migReconfiguration{
+		gracePeriod: gracePeriod,
+		drains:      drains,
+		publisher:   publisher,
+	}
+}
+
+// Deadline returns a channel that receives once the grace period of a pending
+// reconfiguration ended. If no reconfiguration is pending, the channel never
+// receives.
+func (r *migReconfiguration) Deadline() <-chan time.Time {
+	if r == nil {
+		return nil
+	}
+	return r.deadline
+}
+
+// Begin drains the devices of the affected plugins and starts the grace
+// period unless a reconfiguration is already pending. It returns true if the
+// plugins must be reconciled once the grace period ends rather than
+// immediately.
+func (r *migReconfiguration) Begin(affected []plugin.Interface) bool {
+	if r == nil {
+		return false
+	}
+	for _, p := range affected {
+		for _, id := range deviceIDs(p.Devices()) {
+			d, err := r.drains.Drain(drain.Request{Resource: p.Resource(), Device: id})
+			if errors.Is(err, drain.ErrConflict) {
+				continue
+			}
+			if err != nil {
+				klog.Warningf("Failed to drain device %q of resource %q: %v", id, p.Resource(), err)
+				continue
+			}
+			r.drainIDs = append(r.drainIDs, d.ID)
+		}
+	}
+	if r.deadline != nil {
+		return true
+	}
+	if len(r.drainIDs) == 0 {
+		return false
+	}
+	klog.Infof("Draining the devices of %d resources for %v before reconciling plugins.", len(affected), r.gracePeriod)
+	r.publish(nodestatus.ReconfigurationDraining)
+	r.deadline = time.After(r.gracePeriod)
+	return true
+}
+
+// End cancels the drains of a pending reconfiguration and publishes whether
+// the plugins were reconciled successfully.
+func (r *migReconfiguration) End(err error) {
+	if r == nil {
+		return
+	}
+	for _, id := range r.drainIDs {
+		if err := r.drains.Cancel(id); err != nil && !errors.Is(err, drain.ErrNotFound) {
+			klog.Warningf("Failed to cancel drain %q: %v", id, err)
+		}
+	}
+	r.drainIDs = nil
+	r.deadline = nil
+	if err != nil {
+		r.publish(nodestatus.ReconfigurationFailed)
+		return
+	}
+	r.publish(nodestatus.ReconfigurationDone)
+}
+
+func (r *migReconfiguration) publish(state nodestatus.ReconfigurationState) {
+	if err := r.publisher.Publish(context.Background(), state); err != nil {
+		klog.Warningf("Failed to publish MIG reconfiguration state %q: %v", state, err)
+	}
+}
+
+// deviceIDs returns the sorted IDs of the devices without their replica
+// annotations.
+func deviceIDs(devices rm.Devices) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range devices.GetIDs() {
+		id = rm.AnnotatedID(id).GetID()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,61 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/drain"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+)
+
+func TestMIGReconfiguration(t *testing.T) {
+	require.Nil(t, newMIGReconfiguration(0, nil, nil))
+
+	affected := &testPlugin{resource: "nvidia.com/mig-1g.10gb", devices: testDevices("MIG-0", "MIG-1")}
+	shared := &testPlugin{resource: "nvidia.com/gpu.shared", devices: testDevices("GPU-0::0", "GPU-0::1")}
+	unaffected := &testPlugin{resource: "nvidia.com/mig-2g.20gb", devices: testDevices("MIG-2")}
+
+	drains := drain.NewManager(nil, time.Hour)
+	drains.Update([]drain.Source{affected, shared, unaffected})
+	r := newMIGReconfiguration(time.Millisecond, drains, nil)
+
+	require.False(t, r.Begin(nil))
+	require.Nil(t, r.Deadline())
+
+	require.True(t, r.Begin([]plugin.Interface{affected}))
+	require.Equal(t, map[string]bool{"MIG-0": true, "MIG-1": true}, drains.Draining(affected.resource))
+	require.Empty(t, drains.Draining(unaffected.resource))
+
+	// Further changes while the devices drain do not extend the grace period.
+	deadline := r.Deadline()
+	require.True(t, r.Begin([]plugin.Interface{affected, shared}))
+	require.Equal(t, deadline, r.Deadline())
+	require.Equal(t, map[string]bool{"GPU-0::0": true, "GPU-0::1": true}, drains.Draining(shared.resource))
+
+	select {
+	case <-r.Deadline():
+	case <-time.After(5 * time.Second):
+		t.Fatal("grace period did not end")
+	}
+	r.End(nil)
+	require.Nil(t, r.Deadline())
+	require.Empty(t, drains.List())
+}
@@ -0,0 +1,68 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package refresh
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/admin"
+)
+
+// CommandName is the name of the refresh subcommand.
+const CommandName = "refresh"
+
+type options struct {
+	adminSocket string
+	resource    string
+}
+
+// NewCommand constructs the refresh command.
+// The command forces a running device plugin to resend its device lists to
+// the kubelet through the admin API, without restarting either of them.
+func NewCommand() *cli.Command {
+	o := &options{}
+	return &cli.Command{
+		Name:  CommandName,
+		Usage: "Force a running plugin to resend the full device list to the kubelet",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "plugin-admin-socket",
+				Usage:       "the path of the unix socket on which the admin API of the running plugin is served",
+				Destination: &o.adminSocket,
+				EnvVars:     []string{"PLUGIN_ADMIN_SOCKET"},
+				Required:    true,
+			},
+			&cli.StringFlag{
+				Name:        "resource",
+				Usage:       "the resource whose device list is resent; the device lists of all resources are resent if empty",
+				Destination: &o.resource,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			resources, err := admin.Refresh(c.Context, o.adminSocket, spec.ResourceName(o.resource))
+			if err != nil {
+				return fmt.Errorf("failed to refresh device lists: %w", err)
+			}
+			klog.Infof("Resending the device lists of %v", resources)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,150 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
+)
+
+// configReloadDelay is the time for which changes to the config file must
+// settle before the plugins are reloaded, since a single update of a mounted
+// ConfigMap generates several events.
+const configReloadDelay = time.Second
+
+// configWatcher watches the config file for changes. The directory of the
+// file is watched, since files that are replaced atomically, such as the
+// files of mounted ConfigMaps, are not tracked by a watch on the file itself.
+type configWatcher struct {
+	file    string
+	watcher *fsnotify.Watcher
+}
+
+// newConfigWatcher creates a watcher for the specified config file. A nil
+// watcher is returned if the file is empty.
+func newConfigWatcher(file string) (*configWatcher, error) {
+	if file == "" {
+		return nil, nil
+	}
+	watcher, err := watch.Files(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	return &configWatcher{file: file, watcher: watcher}, nil
+}
+
+// Events returns the events in the directory of the config file. If the
+// watcher is nil, the channel never receives.
+func (w *configWatcher) Events() <-chan fsnotify.Event {
+	if w == nil {
+		return nil
+	}
+	return w.watcher.Events
+}
+
+// Errors returns the errors of the watcher. If the watcher is nil, the
+// channel never receives.
+func (w *configWatcher) Errors() <-chan error {
+	if w == nil {
+		return nil
+	}
+	return w.watcher.Errors
+}
+
+// Changed checks whether the specified event may have changed the config
+// file. Mounted ConfigMaps are updated by replacing the ..data symlink that
+// the file points to.
+func (w *configWatcher) Changed(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Base(event.Name)
+	return name == filepath.Base(w.file) || name == "..data"
+}
+
+// Close stops the watcher.
+func (w *configWatcher) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.watcher.Close()
+}
+
+// reloadPlugins replaces the running plugins with plugins created from the
+// changed config file. The new config is loaded and validated before the
+// running plugins are stopped, so that an invalid config leaves them running
+// and is returned as an error alongside them. The new plugins re-register
+// with the kubelet and keep advertising the devices that are no longer
+// configured but are still allocated to running pods until they are released.
+// The same holds for the resources that the new config renamed or removed,
+// which are served by plugins that only advertise their allocated devices.
+// If the new plugins cannot be started, the error is returned with no plugins.
+func reloadPlugins(c *cli.Context, o *options, running []plugin.Interface) ([]plugin.Interface, bool, error) {
+	config, plugins, err := getPlugins(c, o)
+	if err != nil {
+		return running, false, fmt.Errorf("invalid config: %w", err)
+	}
+	retaining := retainPlugins(running, plugins)
+	if err := stopPlugins(running); err != nil {
+		return nil, false, fmt.Errorf("error stopping plugins: %w", err)
+	}
+	klog.Info("Starting reloaded plugins.")
+	plugins, failed, err := runPlugins(c, o, config, plugins)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, p := range retaining {
+		if err := p.Start(); err != nil {
+			klog.Errorf("Failed to start plugin retaining the allocated devices of %v: %v", p.Resource(), err)
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, failed, nil
+}
+
+// retainPlugins creates plugins for the resources of the running plugins that
+// are not served by the reloaded plugins, so that the devices of these
+// resources that are still allocated to running pods are not orphaned.
+func retainPlugins(running []plugin.Interface, reloaded []plugin.Interface) []plugin.Interface {
+	served := make(map[spec.ResourceName]bool)
+	for _, p := range reloaded {
+		if len(p.Devices()) > 0 {
+			served[p.Resource()] = true
+		}
+	}
+	var retaining []plugin.Interface
+	for _, p := range running {
+		if served[p.Resource()] {
+			continue
+		}
+		if r := plugin.Retain(p); r != nil {
+			klog.Infof("Resource %v is no longer configured; advertising its devices until they are released by running pods", p.Resource())
+			retaining = append(retaining, r)
+		}
+	}
+	return retaining
+}
@@ -0,0 +1,41 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigWatcherChanged(t *testing.T) {
+	w := &configWatcher{file: "/config/config.yaml"}
+
+	require.True(t, w.Changed(fsnotify.Event{Name: "/config/config.yaml", Op: fsnotify.Write}))
+	require.True(t, w.Changed(fsnotify.Event{Name: "/config/config.yaml", Op: fsnotify.Create}))
+	require.True(t, w.Changed(fsnotify.Event{Name: "/config/..data", Op: fsnotify.Create}), "ConfigMap updates replace the ..data symlink")
+	require.False(t, w.Changed(fsnotify.Event{Name: "/config/config.yaml", Op: fsnotify.Chmod}))
+	require.False(t, w.Changed(fsnotify.Event{Name: "/config/other.yaml", Op: fsnotify.Write}))
+
+	watcher, err := newConfigWatcher("")
+	require.NoError(t, err)
+	require.Nil(t, watcher)
+	require.Nil(t, watcher.Events())
+	require.Nil(t, watcher.Errors())
+	require.NoError(t, watcher.Close())
+}
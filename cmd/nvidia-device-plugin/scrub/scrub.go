@@ -0,0 +1,112 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package scrub
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/cuda"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+)
+
+const (
+	// chunkSize is the size of the first allocations used to clear the
+	// memory of a device. Smaller allocations are used once it no longer fits.
+	chunkSize = 256 << 20
+	// minChunkSize is the size of the smallest allocation.
+	minChunkSize = 2 << 20
+)
+
+// NewCommand constructs the command that clears the memory of a device. The
+// command is run by the device plugin before a device is handed to a new
+// tenant and is not intended to be run directly.
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:      plugin.ScrubMemoryCommand,
+		Usage:     "Clear the memory of the device with the specified UUID",
+		ArgsUsage: "<uuid>",
+		Hidden:    true,
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("expected the UUID of a device")
+			}
+			return scrub(c.Args().First())
+		},
+	}
+}
+
+// scrub clears the device memory that is not in use by other processes by
+// allocating it in chunks and setting it to zero.
+func scrub(uuid string) error {
+	// CUDA enumerates only the specified device.
+	if err := os.Setenv("CUDA_VISIBLE_DEVICES", uuid); err != nil {
+		return fmt.Errorf("failed to select device: %w", err)
+	}
+
+	// A CUDA context is bound to the thread that created it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if r := cuda.Init(); r != cuda.SUCCESS {
+		return fmt.Errorf("failed to initialize CUDA: %v", r)
+	}
+	defer cuda.Shutdown()
+
+	device, r := cuda.DeviceGet(0)
+	if r != cuda.SUCCESS {
+		return fmt.Errorf("failed to get device %v: %v", uuid, r)
+	}
+	ctx, r := cuda.CtxCreate(0, device)
+	if r != cuda.SUCCESS {
+		return fmt.Errorf("failed to create context: %v", r)
+	}
+	defer ctx.Destroy()
+
+	var allocated []cuda.DevicePtr
+	defer func() {
+		for _, ptr := range allocated {
+			cuda.MemFree(ptr)
+		}
+	}()
+
+	var cleared uint64
+	for size := uint64(chunkSize); size >= minChunkSize; {
+		ptr, r := cuda.MemAlloc(size)
+		if r == cuda.ERROR_OUT_OF_MEMORY {
+			size /= 2
+			continue
+		}
+		if r != cuda.SUCCESS {
+			return fmt.Errorf("failed to allocate memory: %v", r)
+		}
+		allocated = append(allocated, ptr)
+		if r := cuda.MemsetD8(ptr, 0, size); r != cuda.SUCCESS {
+			return fmt.Errorf("failed to clear memory: %v", r)
+		}
+		cleared += size
+	}
+	if r := cuda.CtxSynchronize(); r != cuda.SUCCESS {
+		return fmt.Errorf("failed to clear memory: %v", r)
+	}
+	klog.Infof("Cleared %d MiB of memory of device %v", cleared>>20, uuid)
+	return nil
+}
@@ -0,0 +1,358 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/info"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+	"github.com/NVIDIA/k8s-device-plugin/pkg/mpsclient"
+)
+
+const (
+	// archiveRoot is the directory in the archive that holds all files.
+	archiveRoot = "nvidia-support-bundle"
+	// redacted replaces the serial numbers of the GPUs if requested.
+	redacted = "REDACTED"
+
+	requestTimeout = 10 * time.Second
+)
+
+// debugEndpoints lists the debug endpoints of the plugin and the files in the
+// bundle that their responses are written to.
+var debugEndpoints = []struct {
+	path string
+	name string
+}{
+	{"/debug/status", "plugin/status.html"},
+	{"/debug/config", "plugin/config.json"},
+	{"/debug/listandwatch", "plugin/listandwatch.json"},
+	{"/debug/xids", "plugin/xids.json"},
+}
+
+// bundle holds the files of a support bundle until it is written.
+type bundle struct {
+	files   []file
+	errors  []string
+	serials []string
+	// serialsErr is set if the serial numbers of the GPUs could not all be
+	// determined, in which case they cannot be redacted.
+	serialsErr error
+
+	// nvmllib is the NVML library used to query the inventory.
+	nvmllib nvml.Interface
+	client  *http.Client
+}
+
+type file struct {
+	name string
+	data []byte
+}
+
+// inventory is the NVML inventory of the node.
+type inventory struct {
+	DriverVersion string            `json:"driverVersion"`
+	Devices       []inventoryDevice `json:"devices"`
+}
+
+// inventoryDevice is a GPU or MIG device in the NVML inventory.
+type inventoryDevice struct {
+	rm.PhysicalDevice
+	Serial string `json:"serial,omitempty"`
+}
+
+func newBundle() *bundle {
+	return &bundle{
+		nvmllib: nvml.New(),
+		client:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (b *bundle) add(name string, data []byte) {
+	b.files = append(b.files, file{name: name, data: data})
+}
+
+func (b *bundle) addJSON(name string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.addError(name, err)
+		return
+	}
+	b.add(name, append(data, '\n'))
+}
+
+// addError records that the specified source could not be collected.
+func (b *bundle) addError(source string, err error) {
+	b.errors = append(b.errors, fmt.Sprintf("%v: %v", source, err))
+}
+
+func (b *bundle) addVersion() {
+	b.add("version.txt", []byte(info.GetVersionString()+"\n"))
+}
+
+// addInventory adds the GPUs and MIG devices reported by NVML, including the
+// serial numbers of the GPUs.
+func (b *bundle) addInventory() {
+	if ret := b.nvmllib.Init(); ret != nvml.SUCCESS {
+		b.serialsErr = fmt.Errorf("failed to initialize NVML: %v", ret)
+		b.addError("nvml", b.serialsErr)
+		return
+	}
+	defer func() {
+		_ = b.nvmllib.Shutdown()
+	}()
+
+	var inv inventory
+	driverVersion, ret := b.nvmllib.SystemGetDriverVersion()
+	if ret != nvml.SUCCESS {
+		b.addError("nvml", fmt.Errorf("failed to get driver version: %v", ret))
+	}
+	inv.DriverVersion = driverVersion
+
+	physical, err := rm.GetPhysicalDevices(device.New(b.nvmllib))
+	if err != nil {
+		b.serialsErr = fmt.Errorf("failed to enumerate devices: %w", err)
+		b.addError("nvml", b.serialsErr)
+		return
+	}
+	for _, p := range physical {
+		d := inventoryDevice{PhysicalDevice: p}
+		if p.Parent == "" {
+			if serial, err := b.serial(p.UUID); err != nil {
+				b.serialsErr = err
+				b.addError("nvml", err)
+			} else {
+				d.Serial = serial
+				b.serials = append(b.serials, serial)
+			}
+		}
+		inv.Devices = append(inv.Devices, d)
+	}
+	b.addJSON("nvml/inventory.json", inv)
+}
+
+func (b *bundle) serial(uuid string) (string, error) {
+	gpu, ret := b.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return "", fmt.Errorf("failed to get device %v: %v", uuid, ret)
+	}
+	serial, ret := gpu.GetSerial()
+	if ret != nvml.SUCCESS {
+		return "", fmt.Errorf("failed to get serial of device %v: %v", uuid, ret)
+	}
+	return serial, nil
+}
+
+// addPluginState adds the responses of the debug endpoints of the running
+// plugin, which include its recent events.
+func (b *bundle) addPluginState(ctx context.Context, address string) {
+	if address == "" {
+		b.addError("plugin", fmt.Errorf("no debug address specified"))
+		return
+	}
+	for _, endpoint := range debugEndpoints {
+		data, err := b.get(ctx, "http://"+address+endpoint.path)
+		if err != nil {
+			b.addError(endpoint.name, err)
+			continue
+		}
+		b.add(endpoint.name, data)
+	}
+}
+
+func (b *bundle) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// addMPSState adds the health, stats, and clients of the MPS control daemons
+// reported by the admin API of the MPS control daemon.
+func (b *bundle) addMPSState(ctx context.Context, socket string) {
+	if socket == "" {
+		b.addError("mps", fmt.Errorf("no admin socket specified"))
+		return
+	}
+	client := mpsclient.New(socket)
+	if health, err := client.Health(ctx); err != nil {
+		b.addError("mps/health.json", err)
+	} else {
+		b.addJSON("mps/health.json", health)
+	}
+	if stats, err := client.Stats(ctx); err != nil {
+		b.addError("mps/stats.json", err)
+	} else {
+		b.addJSON("mps/stats.json", stats)
+	}
+	if clients, err := client.Clients(ctx); err != nil {
+		b.addError("mps/clients.json", err)
+	} else {
+		b.addJSON("mps/clients.json", clients)
+	}
+}
+
+// addFile adds the file at the specified path under the specified name.
+func (b *bundle) addFile(name string, filename string) {
+	data, err := readTail(filename, maxFileBytes)
+	if err != nil {
+		b.addError(name, err)
+		return
+	}
+	b.add(name, data)
+}
+
+// addDir adds the regular files in the specified directory that match the
+// filter. The files are added under the prefix followed by their path.
+func (b *bundle) addDir(prefix string, dir string, filter func(path string) bool) {
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !filter(p) {
+			return nil
+		}
+		b.addFile(path.Join(prefix, filepath.ToSlash(p)), p)
+		return nil
+	})
+	if err != nil {
+		b.addError(prefix, err)
+	}
+}
+
+// addMPSLogs adds the control and server logs of the MPS daemons under the
+// specified MPS root.
+func (b *bundle) addMPSLogs(root string) {
+	b.addDir("mps/logs", root, func(p string) bool {
+		return filepath.Base(filepath.Dir(p)) == "log"
+	})
+}
+
+// write writes the bundle to a gzipped tar archive at the specified path. If
+// requested, the serial numbers of the GPUs are redacted in all files; this
+// fails if the serial numbers could not be queried from NVML.
+func (b *bundle) write(filename string, redactSerials bool) error {
+	// Serial numbers that could not be determined would end up unredacted,
+	// so no bundle is written at all.
+	if redactSerials && b.serialsErr != nil {
+		return fmt.Errorf("unable to redact serial numbers: %w", b.serialsErr)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := b.writeTo(f, redactSerials); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (b *bundle) writeTo(w io.Writer, redactSerials bool) error {
+	files := b.files
+	if len(b.errors) > 0 {
+		files = append(files, file{name: "errors.txt", data: []byte(strings.Join(b.errors, "\n") + "\n")})
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		data := f.data
+		if redactSerials {
+			data = b.redact(data)
+		}
+		header := &tar.Header{
+			Name:    path.Join(archiveRoot, f.name),
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// redact replaces the serial numbers of the GPUs in the specified data.
+func (b *bundle) redact(data []byte) []byte {
+	for _, serial := range b.serials {
+		if serial == "" {
+			continue
+		}
+		data = bytes.ReplaceAll(data, []byte(serial), []byte(redacted))
+	}
+	return data
+}
+
+// readTail reads at most max bytes from the end of the specified file.
+func readTail(filename string, max int64) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if offset := stat.Size() - max; offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(f)
+}
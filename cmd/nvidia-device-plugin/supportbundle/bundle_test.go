@@ -0,0 +1,123 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/debug/status":
+			_, _ = w.Write([]byte("<html>GPU serial 1324</html>"))
+		case "/debug/config":
+			_, _ = w.Write([]byte(`{"config":null}`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "nvidia.com/gpu", "log"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "nvidia.com/gpu", "pipe"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "nvidia.com/gpu", "log", "control.log"), []byte("started"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "nvidia.com/gpu", "pipe", "control"), nil, 0644))
+
+	b := &bundle{client: server.Client(), serials: []string{"1324"}}
+	b.addPluginState(context.Background(), strings.TrimPrefix(server.URL, "http://"))
+	b.addMPSState(context.Background(), "")
+	b.addMPSLogs(root)
+
+	var buf bytes.Buffer
+	require.NoError(t, b.writeTo(&buf, true))
+
+	files := readArchive(t, &buf)
+	logName := "nvidia-support-bundle/mps/logs" + filepath.ToSlash(filepath.Join(root, "nvidia.com/gpu", "log", "control.log"))
+	require.Equal(t, map[string]string{
+		"nvidia-support-bundle/plugin/status.html": "<html>GPU serial REDACTED</html>",
+		"nvidia-support-bundle/plugin/config.json": `{"config":null}`,
+		logName: "started",
+		"nvidia-support-bundle/errors.txt": "plugin/listandwatch.json: unexpected status 404: not found\n" +
+			"plugin/xids.json: unexpected status 404: not found\n" +
+			"mps: no admin socket specified\n",
+	}, files)
+}
+
+type failingNvml struct {
+	nvml.Interface
+}
+
+func (l *failingNvml) Init() nvml.Return { return nvml.ERROR_LIBRARY_NOT_FOUND }
+
+func TestBundleRedactWithoutNvml(t *testing.T) {
+	b := &bundle{nvmllib: &failingNvml{}}
+	b.addInventory()
+
+	filename := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.Error(t, b.write(filename, true))
+	require.NoFileExists(t, filename)
+
+	require.NoError(t, b.write(filename, false))
+	require.FileExists(t, filename)
+}
+
+func TestReadTail(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(filename, []byte("0123456789"), 0644))
+
+	data, err := readTail(filename, 4)
+	require.NoError(t, err)
+	require.Equal(t, "6789", string(data))
+
+	data, err = readTail(filename, 20)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", string(data))
+}
+
+func readArchive(t *testing.T, r io.Reader) map[string]string {
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+	return files
+}
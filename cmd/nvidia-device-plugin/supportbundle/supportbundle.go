@@ -0,0 +1,143 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package supportbundle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/logger"
+)
+
+// CommandName is the name of the support-bundle subcommand.
+const CommandName = "support-bundle"
+
+const (
+	defaultGFDOutputFile = "/etc/kubernetes/node-feature-discovery/features.d/gfd"
+	// maxFileBytes is the number of bytes kept from the end of each collected
+	// file, so that large logs do not bloat the bundle.
+	maxFileBytes = 1 << 20
+)
+
+var defaultCDISpecDirs = []string{"/etc/cdi", "/var/run/cdi"}
+
+type options struct {
+	output         string
+	debugAddress   string
+	mpsAdminSocket string
+	gfdOutputFile  string
+	cdiSpecDirs    cli.StringSlice
+	redactSerials  bool
+}
+
+// NewCommand constructs the support-bundle command.
+// The command gathers the state of the device plugin, GPU Feature Discovery
+// and the MPS control daemon on the node into a single archive that can be
+// attached to issues. Sources that are unavailable are listed in the archive
+// instead of failing the command.
+func NewCommand() *cli.Command {
+	o := &options{}
+	return &cli.Command{
+		Name:  CommandName,
+		Usage: "Gather the state of the plugin, GFD, and MPS on the node into a support bundle",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "the path of the gzipped tar archive that is written; defaults to nvidia-support-bundle-<timestamp>.tar.gz in the current directory",
+				Destination: &o.output,
+			},
+			&cli.StringFlag{
+				Name:        "plugin-debug-address",
+				Usage:       "the address of the debug endpoints of the running plugin (see --debug-address); the plugin state is skipped if empty",
+				Destination: &o.debugAddress,
+				EnvVars:     []string{"DEBUG_ADDRESS"},
+			},
+			&cli.StringFlag{
+				Name:        "mps-admin-socket",
+				Usage:       "the path of the unix socket of the admin API of the MPS control daemon; the MPS state is skipped if empty",
+				Destination: &o.mpsAdminSocket,
+			},
+			&cli.StringFlag{
+				Name:        "gfd-output-file",
+				Value:       defaultGFDOutputFile,
+				Usage:       "the path of the labels file written by GPU Feature Discovery",
+				Destination: &o.gfdOutputFile,
+			},
+			&cli.StringSliceFlag{
+				Name:        "cdi-spec-dir",
+				Value:       cli.NewStringSlice(defaultCDISpecDirs...),
+				Usage:       "the directories from which CDI specs are collected",
+				Destination: &o.cdiSpecDirs,
+			},
+			&cli.BoolFlag{
+				Name:        "redact-serials",
+				Usage:       "replace the serial numbers of the GPUs with a placeholder in all collected files; fails if the serial numbers cannot be queried from NVML",
+				Destination: &o.redactSerials,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			output := o.output
+			if output == "" {
+				output = fmt.Sprintf("nvidia-support-bundle-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+			}
+			b := o.collect(c)
+			if err := b.write(output, o.redactSerials); err != nil {
+				return fmt.Errorf("failed to write support bundle: %w", err)
+			}
+			klog.Infof("Wrote support bundle to %v (%d files, %d errors)", output, len(b.files), len(b.errors))
+			return nil
+		},
+	}
+}
+
+// collect gathers all sources of the bundle. Sources that fail are recorded
+// as errors in the bundle.
+func (o *options) collect(c *cli.Context) *bundle {
+	b := newBundle()
+	b.addVersion()
+
+	config, err := spec.NewConfig(c, c.App.Flags)
+	if err != nil {
+		b.addError("config", err)
+	} else {
+		spec.DisableResourceNamingInConfig(logger.ToKlog, config)
+		if redacted, err := config.Redacted(); err != nil {
+			b.addError("config", err)
+		} else {
+			b.addJSON("config/effective.json", redacted)
+		}
+	}
+	mpsRoot := spec.DefaultMpsContainerRoot
+	if config != nil {
+		mpsRoot = config.Flags.GetMpsContainerRoot()
+	}
+
+	b.addInventory()
+	b.addPluginState(c.Context, o.debugAddress)
+	b.addMPSState(c.Context, o.mpsAdminSocket)
+	b.addFile("gfd/labels", o.gfdOutputFile)
+	for _, dir := range o.cdiSpecDirs.Value() {
+		b.addDir("cdi", dir, func(path string) bool { return true })
+	}
+	b.addMPSLogs(mpsRoot)
+	return b
+}
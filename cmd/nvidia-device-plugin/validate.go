@@ -0,0 +1,83 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	nvinfo "github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/validate"
+)
+
+// newValidateCommand constructs the validate command.
+// The config is loaded from the same flags, environment variables and config
+// file as the device plugin and is checked with the same flag validation that
+// the plugin runs on startup. The flags are only checked against the platform
+// of the node if the config is checked against the node with --nvml, so that
+// a config can be validated on hosts without NVML, e.g. in CI pipelines.
+func newValidateCommand() *cli.Command {
+	load := func(c *cli.Context, configFile string) (*spec.Config, error) {
+		return loadConfig(c, c.App.Flags, configFile)
+	}
+	checkFlags := func(infolib nvinfo.Interface) func(*spec.Config) []error {
+		return func(config *spec.Config) []error {
+			if err := validateFlags(infolib, config); err != nil {
+				return []error{err}
+			}
+			return nil
+		}
+	}
+	nvmllib := nvml.New()
+	flags := validate.Check{
+		Name: "flags",
+		Run:  checkFlags(nvinfo.New(nvinfo.WithPropertyExtractor(anyPlatform{}))),
+		RunOnNode: checkFlags(nvinfo.New(
+			nvinfo.WithNvmlLib(nvmllib),
+			nvinfo.WithDeviceLib(device.New(nvmllib)),
+		)),
+	}
+	return validate.NewCommand(load, flags, validate.ResourceNames, validate.MPSReplicas)
+}
+
+// anyPlatform reports the capabilities of any platform that the device plugin
+// supports, so that the flags are accepted if they are valid on some node.
+type anyPlatform struct{}
+
+var _ nvinfo.PropertyExtractor = anyPlatform{}
+
+func (anyPlatform) HasDXCore() (bool, string) {
+	return false, "the platform is not checked"
+}
+
+func (anyPlatform) HasNvml() (bool, string) {
+	return true, "the platform is not checked"
+}
+
+func (anyPlatform) HasTegraFiles() (bool, string) {
+	return true, "the platform is not checked"
+}
+
+func (p anyPlatform) IsTegraSystem() (bool, string) {
+	return p.HasTegraFiles()
+}
+
+func (anyPlatform) UsesOnlyNVGPUModule() (bool, string) {
+	return false, "the platform is not checked"
+}
@@ -0,0 +1,57 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFlagsWithoutNVML(t *testing.T) {
+	testCases := []struct {
+		description string
+		config      string
+	}{
+		{
+			description: "CDI device list strategy",
+			config:      "version: v1\nflags:\n  plugin:\n    deviceListStrategy: cdi-cri\n",
+		},
+		{
+			description: "CSV container runtime mode",
+			config:      "version: v1\nflags:\n  plugin:\n    containerRuntimeMode: csv\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(configFile, []byte(tc.config), 0644))
+
+			// The flags that depend on the platform are not checked against
+			// the host without --nvml.
+			app := newApp()
+			var output bytes.Buffer
+			app.Writer = &output
+			require.NoError(t, app.Run([]string{"nvidia-device-plugin", "validate", "--config", configFile}))
+			require.Equal(t, "The config is valid\n", output.String())
+		})
+	}
+}
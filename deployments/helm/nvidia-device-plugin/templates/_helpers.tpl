@@ -227,5 +227,7 @@ We convert this to JSON so that it can be included and converted to an object us
 {{- define "nvidia-device-plugin.options" -}}
 {{- $options := dict "" "" -}}
 {{- $_ := set $options "hasConfigMap" ( eq ( (include "nvidia-device-plugin.hasConfigMap" . ) | trim ) "true" ) -}}
+{{- $_ := set $options "useCRDs" ( eq .Values.config.source "crd" ) -}}
+{{- $_ := set $options "hasConfigManager" ( or $options.hasConfigMap $options.useCRDs ) -}}
 {{- mustToJson $options -}}
 {{- end -}}
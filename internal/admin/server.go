@@ -0,0 +1,158 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// ErrNotFound is returned if no plugin serves the requested resource.
+var ErrNotFound = errors.New("not found")
+
+// Source is a plugin that can be managed through the admin API.
+type Source interface {
+	Resource() spec.ResourceName
+	RefreshListAndWatch()
+}
+
+// RefreshResponse is the response of a ListAndWatch refresh. It lists the
+// resources whose device lists are resent to the kubelet.
+type RefreshResponse struct {
+	Resources []spec.ResourceName `json:"resources"`
+}
+
+// Server serves a local API to manage the running plugins on a unix socket.
+type Server struct {
+	socket string
+
+	sync.Mutex
+	sources []Source
+}
+
+// NewServer creates an admin server that listens on the specified unix
+// socket. A nil server is returned if the socket is empty.
+func NewServer(socket string) *Server {
+	if socket == "" {
+		return nil
+	}
+	return &Server{socket: socket}
+}
+
+// Update sets the plugins that are managed by the server.
+// This is called every time the plugins are (re)started.
+func (s *Server) Update(sources []Source) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.sources = sources
+}
+
+// Refresh resends the full device list of the plugin for the specified
+// resource to the kubelet. The device lists of all plugins are resent if the
+// resource is empty.
+func (s *Server) Refresh(resource spec.ResourceName) ([]spec.ResourceName, error) {
+	s.Lock()
+	sources := s.sources
+	s.Unlock()
+
+	resources := []spec.ResourceName{}
+	for _, source := range sources {
+		if resource != "" && source.Resource() != resource {
+			continue
+		}
+		source.RefreshListAndWatch()
+		resources = append(resources, source.Resource())
+	}
+	if resource != "" && len(resources) == 0 {
+		return nil, fmt.Errorf("%w: resource %q", ErrNotFound, resource)
+	}
+	return resources, nil
+}
+
+// Handler returns the HTTP handler for the admin API:
+//
+//	POST /v1/listandwatch/refresh  resend the device lists of all plugins, or
+//	                               of the plugin of the resource query parameter
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/listandwatch/refresh", func(w http.ResponseWriter, r *http.Request) {
+		resources, err := s.Refresh(spec.ResourceName(r.URL.Query().Get("resource")))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, RefreshResponse{Resources: resources})
+	})
+	return mux
+}
+
+// ListenAndServe serves the admin API until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	if err := os.Remove(s.socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket %v: %w", s.socket, err)
+	}
+	listener, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", s.socket, err)
+	}
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	klog.Infof("Serving admin API on %v", s.socket)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Warningf("Failed to write admin API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
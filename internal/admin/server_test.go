@@ -0,0 +1,73 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package admin
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+type testSource struct {
+	resource  spec.ResourceName
+	refreshed int
+}
+
+func (s *testSource) Resource() spec.ResourceName { return s.resource }
+func (s *testSource) RefreshListAndWatch()        { s.refreshed++ }
+
+func TestRefresh(t *testing.T) {
+	gpu := &testSource{resource: "nvidia.com/gpu"}
+	shared := &testSource{resource: "nvidia.com/gpu.shared"}
+
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	s := NewServer(socket)
+	s.Update([]Source{gpu, shared})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		_, err := Refresh(ctx, socket, "nvidia.com/gpu")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, gpu.refreshed)
+	require.Equal(t, 0, shared.refreshed)
+
+	resources, err := Refresh(ctx, socket, "")
+	require.NoError(t, err)
+	require.Equal(t, []spec.ResourceName{"nvidia.com/gpu", "nvidia.com/gpu.shared"}, resources)
+	require.Equal(t, 2, gpu.refreshed)
+	require.Equal(t, 1, shared.refreshed)
+
+	_, err = Refresh(ctx, socket, "nvidia.com/unknown")
+	require.ErrorContains(t, err, "404")
+}
+
+func TestNilServer(t *testing.T) {
+	s := NewServer("")
+	require.Nil(t, s)
+	s.Update(nil)
+	require.NoError(t, s.ListenAndServe(context.Background()))
+}
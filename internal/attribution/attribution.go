@@ -0,0 +1,243 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package attribution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// DefaultInterval is the interval at which the allocated devices are queried
+// if no interval is configured.
+const DefaultInterval = 30 * time.Second
+
+// Source is a plugin whose allocated devices are attributed to containers.
+type Source interface {
+	Resource() spec.ResourceName
+}
+
+// ContainerDevicesLister defines the API used to query the devices that are
+// allocated to each container.
+type ContainerDevicesLister interface {
+	AllocatedContainerDevices(ctx context.Context, resource string) ([]podresources.ContainerDevices, error)
+}
+
+// Allocation is a device, or a replica of a shared device, that is allocated
+// to a container.
+type Allocation struct {
+	Resource string `json:"resource"`
+	// DeviceID is the ID of the device as it is advertised to the kubelet,
+	// including the replica number of a shared device.
+	DeviceID string `json:"deviceID"`
+	// GPU is the ID of the underlying GPU or MIG device.
+	GPU       string `json:"gpu"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+}
+
+// Status is the content of the status file.
+type Status struct {
+	Timestamp   time.Time    `json:"timestamp"`
+	Allocations []Allocation `json:"allocations"`
+}
+
+// Tracker periodically queries the kubelet PodResources API for the containers
+// that the devices of the running plugins are allocated to. The allocations
+// are exposed as Prometheus metrics and optionally written to a status file.
+type Tracker struct {
+	lister   ContainerDevicesLister
+	file     string
+	interval time.Duration
+	now      func() time.Time
+
+	sync.Mutex
+	resources   []string
+	allocations []Allocation
+
+	allocation *prometheus.Desc
+	allocated  *prometheus.Desc
+}
+
+// NewTracker creates a tracker for metrics with the specified namespace. If
+// the file is not empty, the allocations are written to it as JSON every time
+// they are queried.
+func NewTracker(namespace string, lister ContainerDevicesLister, file string, interval time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Tracker{
+		lister:   lister,
+		file:     file,
+		interval: interval,
+		now:      time.Now,
+		allocation: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_allocation_info"),
+			"A device or replica of a shared device that is allocated to a container.",
+			[]string{"resource", "device", "gpu", "namespace", "pod", "container"}, nil,
+		),
+		allocated: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "resource_allocated_devices"),
+			"Number of devices or replicas of a resource that are allocated to containers.",
+			[]string{"resource"}, nil,
+		),
+	}
+}
+
+// Update sets the plugins whose allocated devices are attributed. This is
+// called every time the plugins are (re)started.
+func (t *Tracker) Update(sources []Source) {
+	if t == nil {
+		return
+	}
+	var resources []string
+	for _, s := range sources {
+		resources = append(resources, string(s.Resource()))
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.resources = resources
+}
+
+// Run queries the allocated devices at the configured interval until the
+// context is cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		if err := t.sync(ctx); err != nil {
+			klog.Warningf("Failed to attribute allocated devices: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync queries the containers that the devices of each resource are
+// allocated to and writes the status file.
+func (t *Tracker) sync(ctx context.Context) error {
+	t.Lock()
+	resources := t.resources
+	t.Unlock()
+
+	var allocations []Allocation
+	for _, resource := range resources {
+		containers, err := t.lister.AllocatedContainerDevices(ctx, resource)
+		if err != nil {
+			return fmt.Errorf("failed to query devices allocated for %v: %w", resource, err)
+		}
+		for _, c := range containers {
+			for _, id := range c.DeviceIDs {
+				allocations = append(allocations, Allocation{
+					Resource:  resource,
+					DeviceID:  id,
+					GPU:       rm.AnnotatedID(id).GetID(),
+					Namespace: c.Namespace,
+					Pod:       c.Pod,
+					Container: c.Container,
+				})
+			}
+		}
+	}
+	sort.SliceStable(allocations, func(i, j int) bool {
+		if allocations[i].Resource != allocations[j].Resource {
+			return allocations[i].Resource < allocations[j].Resource
+		}
+		return allocations[i].DeviceID < allocations[j].DeviceID
+	})
+
+	t.Lock()
+	t.allocations = allocations
+	t.Unlock()
+
+	return t.writeStatus(Status{Timestamp: t.now(), Allocations: allocations})
+}
+
+// writeStatus atomically replaces the status file with the specified status.
+func (t *Tracker) writeStatus(status Status) error {
+	if t.file == "" {
+		return nil
+	}
+	if status.Allocations == nil {
+		status.Allocations = []Allocation{}
+	}
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(t.file), filepath.Base(t.file)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), t.file); err != nil {
+		return fmt.Errorf("failed to replace status file: %w", err)
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.allocation
+	ch <- t.allocated
+}
+
+// Collect implements prometheus.Collector.
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	t.Lock()
+	defer t.Unlock()
+
+	counts := make(map[string]int)
+	for _, resource := range t.resources {
+		counts[resource] = 0
+	}
+	for _, a := range t.allocations {
+		counts[a.Resource]++
+		ch <- prometheus.MustNewConstMetric(t.allocation, prometheus.GaugeValue, 1, a.Resource, a.DeviceID, a.GPU, a.Namespace, a.Pod, a.Container)
+	}
+	for _, resource := range t.resources {
+		ch <- prometheus.MustNewConstMetric(t.allocated, prometheus.GaugeValue, float64(counts[resource]), resource)
+	}
+}
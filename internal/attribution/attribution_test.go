@@ -0,0 +1,97 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package attribution
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/metrics"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+type fakeLister map[string][]podresources.ContainerDevices
+
+func (l fakeLister) AllocatedContainerDevices(_ context.Context, resource string) ([]podresources.ContainerDevices, error) {
+	return l[resource], nil
+}
+
+type fakeSource spec.ResourceName
+
+func (s fakeSource) Resource() spec.ResourceName {
+	return spec.ResourceName(s)
+}
+
+func TestTracker(t *testing.T) {
+	(*Tracker)(nil).Update(nil)
+
+	lister := fakeLister{
+		"nvidia.com/gpu.shared": {
+			{Namespace: "default", Pod: "a", Container: "main", DeviceIDs: []string{"GPU-0::1", "GPU-0::0"}},
+			{Namespace: "default", Pod: "b", Container: "main", DeviceIDs: []string{"GPU-0::2"}},
+		},
+		"nvidia.com/other": {
+			{Namespace: "default", Pod: "c", Container: "main", DeviceIDs: []string{"OTHER-0"}},
+		},
+	}
+	file := filepath.Join(t.TempDir(), "allocations.json")
+	timestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tracker := NewTracker("test", lister, file, 0)
+	tracker.now = func() time.Time { return timestamp }
+	tracker.Update([]Source{fakeSource("nvidia.com/gpu.shared"), fakeSource("nvidia.com/gpu")})
+	require.NoError(t, tracker.sync(context.Background()))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var status Status
+	require.NoError(t, json.Unmarshal(data, &status))
+	require.Equal(t, Status{
+		Timestamp: timestamp,
+		Allocations: []Allocation{
+			{Resource: "nvidia.com/gpu.shared", DeviceID: "GPU-0::0", GPU: "GPU-0", Namespace: "default", Pod: "a", Container: "main"},
+			{Resource: "nvidia.com/gpu.shared", DeviceID: "GPU-0::1", GPU: "GPU-0", Namespace: "default", Pod: "a", Container: "main"},
+			{Resource: "nvidia.com/gpu.shared", DeviceID: "GPU-0::2", GPU: "GPU-0", Namespace: "default", Pod: "b", Container: "main"},
+		},
+	}, status)
+
+	s := metrics.NewServer("localhost:0")
+	require.NoError(t, s.Register(tracker))
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	expected := []string{
+		`test_device_allocation_info{container="main",device="GPU-0::2",gpu="GPU-0",namespace="default",pod="b",resource="nvidia.com/gpu.shared"} 1`,
+		`test_resource_allocated_devices{resource="nvidia.com/gpu"} 0`,
+		`test_resource_allocated_devices{resource="nvidia.com/gpu.shared"} 3`,
+	}
+	for _, e := range expected {
+		require.Contains(t, w.Body.String(), e)
+	}
+	require.NotContains(t, w.Body.String(), `nvidia.com/other`)
+}
@@ -21,5 +21,8 @@ package cdi
 //go:generate moq -stub -out api_mock.go . Interface
 type Interface interface {
 	CreateSpecFile() error
+	CreateTopologySpecFile([]byte) (string, error)
+	CreateCUDACompatSpecFile(string) (string, error)
+	RemoveStaleCUDACompatSpecFiles() error
 	QualifiedName(string, string) string
 }
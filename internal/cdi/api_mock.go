@@ -17,12 +17,21 @@ var _ Interface = &InterfaceMock{}
 //
 //		// make and configure a mocked Interface
 //		mockedInterface := &InterfaceMock{
+//			CreateCUDACompatSpecFileFunc: func(s string) (string, error) {
+//				panic("mock out the CreateCUDACompatSpecFile method")
+//			},
 //			CreateSpecFileFunc: func() error {
 //				panic("mock out the CreateSpecFile method")
 //			},
+//			CreateTopologySpecFileFunc: func(bytes []byte) (string, error) {
+//				panic("mock out the CreateTopologySpecFile method")
+//			},
 //			QualifiedNameFunc: func(s1 string, s2 string) string {
 //				panic("mock out the QualifiedName method")
 //			},
+//			RemoveStaleCUDACompatSpecFilesFunc: func() error {
+//				panic("mock out the RemoveStaleCUDACompatSpecFiles method")
+//			},
 //		}
 //
 //		// use mockedInterface in code that requires Interface
@@ -30,17 +39,36 @@ var _ Interface = &InterfaceMock{}
 //
 //	}
 type InterfaceMock struct {
+	// CreateCUDACompatSpecFileFunc mocks the CreateCUDACompatSpecFile method.
+	CreateCUDACompatSpecFileFunc func(s string) (string, error)
+
 	// CreateSpecFileFunc mocks the CreateSpecFile method.
 	CreateSpecFileFunc func() error
 
+	// CreateTopologySpecFileFunc mocks the CreateTopologySpecFile method.
+	CreateTopologySpecFileFunc func(bytes []byte) (string, error)
+
 	// QualifiedNameFunc mocks the QualifiedName method.
 	QualifiedNameFunc func(s1 string, s2 string) string
 
+	// RemoveStaleCUDACompatSpecFilesFunc mocks the RemoveStaleCUDACompatSpecFiles method.
+	RemoveStaleCUDACompatSpecFilesFunc func() error
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// CreateCUDACompatSpecFile holds details about calls to the CreateCUDACompatSpecFile method.
+		CreateCUDACompatSpecFile []struct {
+			// S is the s argument value.
+			S string
+		}
 		// CreateSpecFile holds details about calls to the CreateSpecFile method.
 		CreateSpecFile []struct {
 		}
+		// CreateTopologySpecFile holds details about calls to the CreateTopologySpecFile method.
+		CreateTopologySpecFile []struct {
+			// Bytes is the bytes argument value.
+			Bytes []byte
+		}
 		// QualifiedName holds details about calls to the QualifiedName method.
 		QualifiedName []struct {
 			// S1 is the s1 argument value.
@@ -48,9 +76,51 @@ type InterfaceMock struct {
 			// S2 is the s2 argument value.
 			S2 string
 		}
+		// RemoveStaleCUDACompatSpecFiles holds details about calls to the RemoveStaleCUDACompatSpecFiles method.
+		RemoveStaleCUDACompatSpecFiles []struct {
+		}
+	}
+	lockCreateCUDACompatSpecFile       sync.RWMutex
+	lockCreateSpecFile                 sync.RWMutex
+	lockCreateTopologySpecFile         sync.RWMutex
+	lockQualifiedName                  sync.RWMutex
+	lockRemoveStaleCUDACompatSpecFiles sync.RWMutex
+}
+
+// CreateCUDACompatSpecFile calls CreateCUDACompatSpecFileFunc.
+func (mock *InterfaceMock) CreateCUDACompatSpecFile(s string) (string, error) {
+	callInfo := struct {
+		S string
+	}{
+		S: s,
+	}
+	mock.lockCreateCUDACompatSpecFile.Lock()
+	mock.calls.CreateCUDACompatSpecFile = append(mock.calls.CreateCUDACompatSpecFile, callInfo)
+	mock.lockCreateCUDACompatSpecFile.Unlock()
+	if mock.CreateCUDACompatSpecFileFunc == nil {
+		var (
+			sOut   string
+			errOut error
+		)
+		return sOut, errOut
 	}
-	lockCreateSpecFile sync.RWMutex
-	lockQualifiedName  sync.RWMutex
+	return mock.CreateCUDACompatSpecFileFunc(s)
+}
+
+// CreateCUDACompatSpecFileCalls gets all the calls that were made to CreateCUDACompatSpecFile.
+// Check the length with:
+//
+//	len(mockedInterface.CreateCUDACompatSpecFileCalls())
+func (mock *InterfaceMock) CreateCUDACompatSpecFileCalls() []struct {
+	S string
+} {
+	var calls []struct {
+		S string
+	}
+	mock.lockCreateCUDACompatSpecFile.RLock()
+	calls = mock.calls.CreateCUDACompatSpecFile
+	mock.lockCreateCUDACompatSpecFile.RUnlock()
+	return calls
 }
 
 // CreateSpecFile calls CreateSpecFileFunc.
@@ -83,6 +153,42 @@ func (mock *InterfaceMock) CreateSpecFileCalls() []struct {
 	return calls
 }
 
+// CreateTopologySpecFile calls CreateTopologySpecFileFunc.
+func (mock *InterfaceMock) CreateTopologySpecFile(bytes []byte) (string, error) {
+	callInfo := struct {
+		Bytes []byte
+	}{
+		Bytes: bytes,
+	}
+	mock.lockCreateTopologySpecFile.Lock()
+	mock.calls.CreateTopologySpecFile = append(mock.calls.CreateTopologySpecFile, callInfo)
+	mock.lockCreateTopologySpecFile.Unlock()
+	if mock.CreateTopologySpecFileFunc == nil {
+		var (
+			sOut   string
+			errOut error
+		)
+		return sOut, errOut
+	}
+	return mock.CreateTopologySpecFileFunc(bytes)
+}
+
+// CreateTopologySpecFileCalls gets all the calls that were made to CreateTopologySpecFile.
+// Check the length with:
+//
+//	len(mockedInterface.CreateTopologySpecFileCalls())
+func (mock *InterfaceMock) CreateTopologySpecFileCalls() []struct {
+	Bytes []byte
+} {
+	var calls []struct {
+		Bytes []byte
+	}
+	mock.lockCreateTopologySpecFile.RLock()
+	calls = mock.calls.CreateTopologySpecFile
+	mock.lockCreateTopologySpecFile.RUnlock()
+	return calls
+}
+
 // QualifiedName calls QualifiedNameFunc.
 func (mock *InterfaceMock) QualifiedName(s1 string, s2 string) string {
 	callInfo := struct {
@@ -121,3 +227,33 @@ func (mock *InterfaceMock) QualifiedNameCalls() []struct {
 	mock.lockQualifiedName.RUnlock()
 	return calls
 }
+
+// RemoveStaleCUDACompatSpecFiles calls RemoveStaleCUDACompatSpecFilesFunc.
+func (mock *InterfaceMock) RemoveStaleCUDACompatSpecFiles() error {
+	callInfo := struct {
+	}{}
+	mock.lockRemoveStaleCUDACompatSpecFiles.Lock()
+	mock.calls.RemoveStaleCUDACompatSpecFiles = append(mock.calls.RemoveStaleCUDACompatSpecFiles, callInfo)
+	mock.lockRemoveStaleCUDACompatSpecFiles.Unlock()
+	if mock.RemoveStaleCUDACompatSpecFilesFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.RemoveStaleCUDACompatSpecFilesFunc()
+}
+
+// RemoveStaleCUDACompatSpecFilesCalls gets all the calls that were made to RemoveStaleCUDACompatSpecFiles.
+// Check the length with:
+//
+//	len(mockedInterface.RemoveStaleCUDACompatSpecFilesCalls())
+func (mock *InterfaceMock) RemoveStaleCUDACompatSpecFilesCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockRemoveStaleCUDACompatSpecFiles.RLock()
+	calls = mock.calls.RemoveStaleCUDACompatSpecFiles
+	mock.lockRemoveStaleCUDACompatSpecFiles.RUnlock()
+	return calls
+}
@@ -24,8 +24,8 @@ import (
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi"
-	transformroot "github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/transform/root"
 	"github.com/sirupsen/logrus"
+	"k8s.io/klog/v2"
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
 	cdiparser "tags.cncf.io/container-device-interface/pkg/parser"
 
@@ -33,7 +33,12 @@ import (
 )
 
 const (
-	cdiRoot = "/var/run/cdi"
+	// SpecDir is the default directory that the CDI specs are written to.
+	SpecDir = spec.DefaultCDISpecDir
+	// Vendor is the vendor of the CDI specs that are generated by the device plugin.
+	Vendor = "k8s.device-plugin.nvidia.com"
+	// tegraDeviceUUID is the UUID reported for the Tegra device by the resource manager.
+	tegraDeviceUUID = "tegra"
 )
 
 // cdiHandler creates CDI specs for devices assocatied with the device plugin
@@ -48,11 +53,13 @@ type cdiHandler struct {
 	nvidiaCTKPath    string
 	vendor           string
 	deviceIDStrategy string
+	specDir          string
 
 	deviceListStrategies spec.DeviceListStrategies
 
-	gdsEnabled   bool
-	mofedEnabled bool
+	gdsEnabled           bool
+	mofedEnabled         bool
+	containerRuntimeMode string
 
 	cdilibs map[string]nvcdi.Interface
 }
@@ -74,37 +81,62 @@ func newHandler(infolib info.Interface, nvmllib nvml.Interface, devicelib device
 		return &null{}, nil
 	}
 
+	if hasNVML, _ := c.infolib.HasNvml(); !hasNVML && !c.isCSVMode() {
+		klog.Warning("No valid resources detected, creating a null CDI handler")
+		return &null{}, nil
+	}
+
 	if c.logger == nil {
 		c.logger = logrus.StandardLogger()
 	}
 	if c.deviceIDStrategy == "" {
 		c.deviceIDStrategy = "uuid"
 	}
-	if c.driverRoot == "" {
-		c.driverRoot = "/"
+	if c.specDir == "" {
+		c.specDir = SpecDir
+	}
+	driverRoot, err := normalizeRoot(c.driverRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid driver root: %w", err)
 	}
 	if c.targetDriverRoot == "" {
-		c.targetDriverRoot = c.driverRoot
+		c.targetDriverRoot = driverRoot
 	}
+	targetDriverRoot, err := normalizeRoot(c.targetDriverRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target driver root: %w", err)
+	}
+	c.driverRoot, c.targetDriverRoot = driverRoot, targetDriverRoot
 
-	deviceNamer, err := nvcdi.NewDeviceNamer(c.deviceIDStrategy)
+	deviceNamer, err := c.newDeviceNamer()
 	if err != nil {
 		return nil, err
 	}
 
 	c.cdilibs = make(map[string]nvcdi.Interface)
 
-	c.cdilibs["gpu"], err = nvcdi.New(
+	gpuOpts := []nvcdi.Option{
 		nvcdi.WithInfoLib(c.infolib),
-		nvcdi.WithNvmlLib(c.nvmllib),
-		nvcdi.WithDeviceLib(c.devicelib),
 		nvcdi.WithLogger(c.logger),
 		nvcdi.WithNVIDIACDIHookPath(c.nvidiaCTKPath),
 		nvcdi.WithDriverRoot(c.driverRoot),
 		nvcdi.WithDeviceNamers(deviceNamer),
 		nvcdi.WithVendor(c.vendor),
 		nvcdi.WithClass("gpu"),
-	)
+	}
+	if c.isCSVMode() {
+		// In csv mode, the device nodes and libraries to inject are read from
+		// the CSV files of the NVIDIA Container Runtime instead of being
+		// discovered through NVML.
+		gpuOpts = append(gpuOpts, nvcdi.WithMode(nvcdi.ModeCSV))
+	} else {
+		gpuOpts = append(gpuOpts,
+			nvcdi.WithNvmlLib(c.nvmllib),
+			nvcdi.WithDeviceLib(c.devicelib),
+		)
+	}
+
+	c.cdilibs["gpu"], err = nvcdi.New(gpuOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create nvcdi library: %v", err)
 	}
@@ -140,7 +172,7 @@ func (cdi *cdiHandler) CreateSpecFile() error {
 	for class, cdilib := range cdi.cdilibs {
 		cdi.logger.Infof("Generating CDI spec for resource: %s/%s", cdi.vendor, class)
 
-		if class == "gpu" {
+		if class == "gpu" && !cdi.isCSVMode() {
 			ret := cdi.nvmllib.Init()
 			if ret != nvml.SUCCESS {
 				return fmt.Errorf("failed to initialize NVML: %v", ret)
@@ -155,11 +187,10 @@ func (cdi *cdiHandler) CreateSpecFile() error {
 			return fmt.Errorf("failed to get CDI spec: %v", err)
 		}
 
-		err = transformroot.New(
-			transformroot.WithRoot(cdi.driverRoot),
-			transformroot.WithTargetRoot(cdi.targetDriverRoot),
-			transformroot.WithRelativeTo("host"),
-		).Transform(spec.Raw())
+		err = hostPathTransformer{
+			root:       cdi.driverRoot,
+			targetRoot: cdi.targetDriverRoot,
+		}.Transform(spec.Raw())
 		if err != nil {
 			return fmt.Errorf("failed to transform driver root in CDI spec: %v", err)
 		}
@@ -169,7 +200,7 @@ func (cdi *cdiHandler) CreateSpecFile() error {
 			return fmt.Errorf("failed to generate spec name: %v", err)
 		}
 
-		err = spec.Save(filepath.Join(cdiRoot, specName+".json"))
+		err = spec.Save(filepath.Join(cdi.specDir, specName+".json"))
 		if err != nil {
 			return fmt.Errorf("failed to save CDI spec: %v", err)
 		}
@@ -178,8 +209,37 @@ func (cdi *cdiHandler) CreateSpecFile() error {
 	return nil
 }
 
+// isCSVMode checks whether CDI specs are generated for the csv mode of the NVIDIA Container Runtime.
+func (cdi *cdiHandler) isCSVMode() bool {
+	return cdi.containerRuntimeMode == spec.ContainerRuntimeModeCSV
+}
+
+// newDeviceNamer creates the namer for the devices in the generated CDI spec.
+// In csv mode, the spec contains a single device whose name must match the ID
+// of the Tegra device advertised by the plugin.
+func (cdi *cdiHandler) newDeviceNamer() (nvcdi.DeviceNamer, error) {
+	if cdi.isCSVMode() && cdi.deviceIDStrategy == spec.DeviceIDStrategyUUID {
+		return tegraDeviceNamer{}, nil
+	}
+	return nvcdi.NewDeviceNamer(cdi.deviceIDStrategy)
+}
+
 // QualifiedName constructs a CDI qualified device name for the specified resources.
 // Note: This assumes that the specified id matches the device name returned by the naming strategy.
 func (cdi *cdiHandler) QualifiedName(class string, id string) string {
 	return cdiparser.QualifiedName(cdi.vendor, class, id)
 }
+
+// tegraDeviceNamer names the device in a CDI spec generated from CSV files
+// after the UUID reported for the Tegra device by the resource manager.
+type tegraDeviceNamer struct{}
+
+// GetDeviceName returns the name of the Tegra device.
+func (n tegraDeviceNamer) GetDeviceName(int, nvcdi.UUIDer) (string, error) {
+	return tegraDeviceUUID, nil
+}
+
+// GetMigDeviceName returns an error since Tegra devices do not support MIG.
+func (n tegraDeviceNamer) GetMigDeviceName(int, nvcdi.UUIDer, int, nvcdi.UUIDer) (string, error) {
+	return "", fmt.Errorf("MIG devices are not supported in csv mode")
+}
@@ -0,0 +1,151 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cdi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	nvcdispec "github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/spec"
+	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
+const (
+	// CUDACompatClass is the class of the CDI devices that mount CUDA forward
+	// compatibility libraries.
+	CUDACompatClass = "cuda-compat"
+	// CUDACompatContainerPath is the path at which the CUDA forward
+	// compatibility libraries are mounted into containers.
+	CUDACompatContainerPath = "/usr/local/nvidia/cuda-compat"
+)
+
+// CreateCUDACompatSpecFile writes a transient CDI spec for a device that
+// mounts the CUDA forward compatibility libraries in the specified host
+// directory into containers and adds them to the ld cache of the container.
+// Since the ld cache is searched after the LD_LIBRARY_PATH, the library search
+// path of the container image is preserved. The device is named after the hash
+// of the directory so that allocations of the same package share a single
+// spec. An existing spec is rewritten if its contents differ, for example
+// after an upgrade of the plugin or of the NVIDIA Container Toolkit. The
+// qualified name of the device is returned.
+func (cdi *cdiHandler) CreateCUDACompatSpecFile(hostDir string) (string, error) {
+	sum := sha256.Sum256([]byte(hostDir))
+	id := hex.EncodeToString(sum[:8])
+	name := cdi.QualifiedName(CUDACompatClass, id)
+
+	specPath := filepath.Join(cdi.specDir, cdiapi.GenerateTransientSpecName(cdi.vendor, CUDACompatClass, id)+".json")
+
+	spec, err := nvcdispec.New(
+		nvcdispec.WithVendor(cdi.vendor),
+		nvcdispec.WithClass(CUDACompatClass),
+		nvcdispec.WithFormat(nvcdispec.FormatJSON),
+		nvcdispec.WithDeviceSpecs([]specs.Device{
+			{
+				Name: id,
+				ContainerEdits: specs.ContainerEdits{
+					Hooks: []*specs.Hook{cdi.ldcacheUpdateHook(CUDACompatContainerPath)},
+					Mounts: []*specs.Mount{
+						{
+							HostPath:      hostDir,
+							ContainerPath: CUDACompatContainerPath,
+							Options:       []string{"ro", "nosuid", "nodev", "bind"},
+						},
+					},
+				},
+			},
+		}),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create CUDA compat CDI spec: %w", err)
+	}
+	contents, err := json.Marshal(spec.Raw())
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal CUDA compat CDI spec: %w", err)
+	}
+	if existing, err := os.ReadFile(specPath); err == nil && bytes.Equal(existing, contents) {
+		return name, nil
+	}
+	if err := writeFileAtomic(specPath, contents); err != nil {
+		return "", fmt.Errorf("failed to save CUDA compat CDI spec: %w", err)
+	}
+	return name, nil
+}
+
+// ldcacheUpdateHook returns a createContainer hook that adds the specified
+// container directory to the ld cache of the container.
+func (cdi *cdiHandler) ldcacheUpdateHook(folder string) *specs.Hook {
+	args := []string{filepath.Base(cdi.nvidiaCTKPath)}
+	if args[0] == "nvidia-ctk" {
+		args = append(args, "hook")
+	}
+	return &specs.Hook{
+		HookName: cdiapi.CreateContainerHook,
+		Path:     cdi.nvidiaCTKPath,
+		Args:     append(args, "update-ldcache", "--folder", folder),
+	}
+}
+
+// RemoveStaleCUDACompatSpecFiles removes the transient CDI specs of CUDA
+// forward compatibility libraries whose host directory no longer exists, for
+// example because the cuda-compat package was uninstalled from the host.
+func (cdi *cdiHandler) RemoveStaleCUDACompatSpecFiles() error {
+	paths, err := filepath.Glob(filepath.Join(cdi.specDir, cdiapi.GenerateTransientSpecName(cdi.vendor, CUDACompatClass, "*")+".json"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var spec specs.Spec
+		if err := json.Unmarshal(contents, &spec); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %v: %w", path, err))
+			continue
+		}
+		if !hasMissingHostPath(spec) {
+			continue
+		}
+		cdi.logger.Infof("Removing stale CUDA compat CDI spec %v", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hasMissingHostPath checks whether any host path mounted by the devices of
+// the specified spec does not exist.
+func hasMissingHostPath(spec specs.Spec) bool {
+	for _, device := range spec.Devices {
+		for _, mount := range device.ContainerEdits.Mounts {
+			if _, err := os.Stat(mount.HostPath); errors.Is(err, os.ErrNotExist) {
+				return true
+			}
+		}
+	}
+	return false
+}
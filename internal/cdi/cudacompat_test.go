@@ -0,0 +1,115 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cdi
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
+func TestCreateCUDACompatSpecFile(t *testing.T) {
+	specDir := t.TempDir()
+	hostDir := t.TempDir()
+	handler := &cdiHandler{
+		nvidiaCTKPath: "/usr/bin/nvidia-ctk",
+		vendor:        "k8s.device-plugin.nvidia.com",
+		specDir:       specDir,
+	}
+
+	name, err := handler.CreateCUDACompatSpecFile(hostDir)
+	require.NoError(t, err)
+
+	paths, err := filepath.Glob(filepath.Join(specDir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	readSpec := func() specs.Spec {
+		contents, err := os.ReadFile(paths[0])
+		require.NoError(t, err)
+		var spec specs.Spec
+		require.NoError(t, json.Unmarshal(contents, &spec))
+		return spec
+	}
+	spec := readSpec()
+	require.Len(t, spec.Devices, 1)
+	require.Equal(t, name, handler.QualifiedName(CUDACompatClass, spec.Devices[0].Name))
+	edits := spec.Devices[0].ContainerEdits
+	require.Empty(t, edits.Env)
+	require.Equal(t, []*specs.Hook{
+		{
+			HookName: "createContainer",
+			Path:     "/usr/bin/nvidia-ctk",
+			Args:     []string{"nvidia-ctk", "hook", "update-ldcache", "--folder", CUDACompatContainerPath},
+		},
+	}, edits.Hooks)
+	require.Len(t, edits.Mounts, 1)
+	require.Equal(t, hostDir, edits.Mounts[0].HostPath)
+
+	// A spec written by an older version of the plugin is replaced.
+	spec.Devices[0].ContainerEdits.Hooks = nil
+	spec.Devices[0].ContainerEdits.Env = []string{"LD_LIBRARY_PATH=" + CUDACompatContainerPath}
+	contents, err := json.Marshal(spec)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(paths[0], contents, 0600))
+
+	_, err = handler.CreateCUDACompatSpecFile(hostDir)
+	require.NoError(t, err)
+	spec = readSpec()
+	require.Empty(t, spec.Devices[0].ContainerEdits.Env)
+	require.Len(t, spec.Devices[0].ContainerEdits.Hooks, 1)
+}
+
+func TestCreateCUDACompatSpecFileConcurrently(t *testing.T) {
+	specDir := t.TempDir()
+	handler := &cdiHandler{
+		nvidiaCTKPath: "/usr/bin/nvidia-ctk",
+		vendor:        "k8s.device-plugin.nvidia.com",
+		specDir:       specDir,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(hostDir string) {
+			defer wg.Done()
+			_, err := handler.CreateCUDACompatSpecFile(hostDir)
+			require.NoError(t, err)
+		}(filepath.Join("/compat", strconv.Itoa(i%2)))
+	}
+	wg.Wait()
+
+	// Only the complete specs are left in the spec directory and they are
+	// readable by the container runtime.
+	entries, err := os.ReadDir(specDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+		contents, err := os.ReadFile(filepath.Join(specDir, entry.Name()))
+		require.NoError(t, err)
+		require.True(t, json.Valid(contents))
+	}
+}
@@ -17,6 +17,8 @@
 package cdi
 
 import (
+	"fmt"
+
 	"k8s.io/klog/v2"
 )
 
@@ -35,6 +37,24 @@ func (n *null) CreateSpecFile() error {
 	return nil
 }
 
+// CreateTopologySpecFile returns an error for the null handler since topology
+// files are injected through CDI.
+func (n *null) CreateTopologySpecFile([]byte) (string, error) {
+	return "", fmt.Errorf("cannot inject a topology file with the null CDI handler")
+}
+
+// CreateCUDACompatSpecFile returns an error for the null handler since CUDA
+// forward compatibility libraries are only injected through CDI with a CDI
+// device list strategy.
+func (n *null) CreateCUDACompatSpecFile(string) (string, error) {
+	return "", fmt.Errorf("cannot inject CUDA forward compatibility libraries with the null CDI handler")
+}
+
+// RemoveStaleCUDACompatSpecFiles is a no-op for the null handler.
+func (n *null) RemoveStaleCUDACompatSpecFiles() error {
+	return nil
+}
+
 // QualifiedName is a no-op for the null handler. A error message is logged
 // inidicating this should never be called for the null handler.
 func (n *null) QualifiedName(class string, id string) string {
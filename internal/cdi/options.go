@@ -78,3 +78,17 @@ func WithMofedEnabled(enabled bool) Option {
 		c.mofedEnabled = enabled
 	}
 }
+
+// WithContainerRuntimeMode provides an option to set the mode of the NVIDIA Container Runtime that CDI specs are generated for
+func WithContainerRuntimeMode(mode string) Option {
+	return func(c *cdiHandler) {
+		c.containerRuntimeMode = mode
+	}
+}
+
+// WithSpecDir provides an option to set the directory that the CDI specs are written to
+func WithSpecDir(dir string) Option {
+	return func(c *cdiHandler) {
+		c.specDir = dir
+	}
+}
@@ -0,0 +1,224 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cdi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
+// maxSymlinks is the maximum number of symlinks that are followed when
+// resolving a path in the driver root. This matches the limit of the kernel.
+const maxSymlinks = 40
+
+// normalizeRoot validates the specified driver root and returns it in its
+// cleaned, absolute form. An empty root is the filesystem root. Relative and
+// Windows-style roots are rejected, since they cannot be rebased reliably.
+func normalizeRoot(root string) (string, error) {
+	if root == "" {
+		return "/", nil
+	}
+	if strings.Contains(root, `\`) || (len(root) > 1 && root[1] == ':') {
+		return "", fmt.Errorf("invalid root %q: Windows-style paths are not supported", root)
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("invalid root %q: path must be absolute", root)
+	}
+	return filepath.Clean(root), nil
+}
+
+// hostPathTransformer normalizes the host paths in a CDI spec and rebases the
+// paths in the driver root onto the target driver root. Symlinks in the
+// driver root are resolved relative to the driver root, so that absolute
+// symlinks in a driver that is not installed at / do not resolve to files of
+// the host. Host paths are cleaned and resolved paths never escape the root.
+type hostPathTransformer struct {
+	root       string
+	targetRoot string
+}
+
+// Transform applies the transformation to all container edits in the spec.
+func (t hostPathTransformer) Transform(spec *specs.Spec) error {
+	if spec == nil {
+		return nil
+	}
+	for _, d := range spec.Devices {
+		if err := t.applyToEdits(&d.ContainerEdits); err != nil {
+			return fmt.Errorf("failed to transform host paths of device %s: %w", d.Name, err)
+		}
+	}
+	if err := t.applyToEdits(&spec.ContainerEdits); err != nil {
+		return fmt.Errorf("failed to transform host paths of spec: %w", err)
+	}
+	return nil
+}
+
+func (t hostPathTransformer) applyToEdits(edits *specs.ContainerEdits) error {
+	var err error
+	for _, dn := range edits.DeviceNodes {
+		if dn.HostPath == "" {
+			dn.HostPath = dn.Path
+		}
+		if dn.HostPath, err = t.transformPath(dn.HostPath); err != nil {
+			return fmt.Errorf("device node %q: %w", dn.Path, err)
+		}
+	}
+	for _, m := range edits.Mounts {
+		if m.HostPath, err = t.transformPath(m.HostPath); err != nil {
+			return fmt.Errorf("mount %q: %w", m.ContainerPath, err)
+		}
+	}
+	for _, h := range edits.Hooks {
+		if err := t.transformHook(h); err != nil {
+			return fmt.Errorf("%s hook %q: %w", h.HookName, h.Path, err)
+		}
+	}
+	return nil
+}
+
+// transformHook transforms the paths of a hook that runs in the host
+// namespace. The path of a startContainer hook must resolve in the container
+// and the arguments of createContainer and startContainer hooks are
+// interpreted in the container, so these are left untouched. The arguments
+// are rebased without resolving symlinks, since the create-symlinks hook
+// takes the targets of the links to create as <target>::<link>.
+func (t hostPathTransformer) transformHook(hook *specs.Hook) error {
+	if hook.HookName != "startContainer" {
+		path, err := t.transformPath(hook.Path)
+		if err != nil {
+			return err
+		}
+		hook.Path = path
+	}
+	if hook.HookName == "createContainer" || hook.HookName == "startContainer" {
+		return nil
+	}
+	for i, arg := range hook.Args {
+		parts := strings.SplitN(arg, "::", 2)
+		for j := range parts {
+			parts[j] = t.rebase(parts[j])
+		}
+		hook.Args[i] = strings.Join(parts, "::")
+	}
+	return nil
+}
+
+// transformPath validates and cleans the specified host path. Host paths in
+// the driver root are resolved in the driver root and rebased onto the target
+// driver root.
+func (t hostPathTransformer) transformPath(path string) (string, error) {
+	if strings.Contains(path, `\`) {
+		return "", fmt.Errorf("invalid host path %q: Windows-style paths are not supported", path)
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("invalid host path %q: path must be absolute", path)
+	}
+	path = filepath.Clean(path)
+	rel, ok := t.relative(path)
+	if !ok {
+		return path, nil
+	}
+	if t.root != "/" {
+		resolved, err := resolveInRoot(t.root, rel)
+		if err != nil {
+			return "", err
+		}
+		rel = resolved
+	}
+	return filepath.Join(t.targetRoot, rel), nil
+}
+
+// rebase moves the specified path from the driver root to the target driver
+// root. Paths outside the driver root are returned unchanged.
+func (t hostPathTransformer) rebase(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	rel, ok := t.relative(filepath.Clean(path))
+	if !ok {
+		return path
+	}
+	return filepath.Join(t.targetRoot, rel)
+}
+
+// relative returns the specified cleaned path relative to the driver root, as
+// an absolute path. Unlike a plain prefix match, a path is only considered to
+// be in the driver root if the root is followed by a path separator, so that
+// e.g. /driver-root-old is not rebased for the driver root /driver-root.
+func (t hostPathTransformer) relative(path string) (string, bool) {
+	if t.root == "/" {
+		return path, true
+	}
+	if path == t.root {
+		return "/", true
+	}
+	rel, ok := strings.CutPrefix(path, t.root+"/")
+	if !ok {
+		return "", false
+	}
+	return "/" + rel, true
+}
+
+// resolveInRoot resolves the symlinks in the specified path as if root were
+// the filesystem root: absolute symlink targets are interpreted relative to
+// root and ".." never leads out of root. The resolved path is returned
+// relative to root, as an absolute path. Components that do not exist are
+// kept as they are.
+func resolveInRoot(root string, path string) (string, error) {
+	resolved := "/"
+	remaining := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	links := 0
+	for len(remaining) > 0 {
+		component := remaining[0]
+		remaining = remaining[1:]
+		if component == "" || component == "." {
+			continue
+		}
+		next := filepath.Join(resolved, component)
+
+		info, err := os.Lstat(filepath.Join(root, next))
+		if errors.Is(err, os.ErrNotExist) {
+			return filepath.Join(append([]string{next}, remaining...)...), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %q in %q: %w", path, root, err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("failed to resolve %q in %q: too many symlinks", path, root)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %q in %q: %w", path, root, err)
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return resolved, nil
+}
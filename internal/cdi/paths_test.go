@@ -0,0 +1,180 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cdi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
+func TestNormalizeRoot(t *testing.T) {
+	testCases := []struct {
+		root          string
+		expected      string
+		expectedError bool
+	}{
+		{root: "", expected: "/"},
+		{root: "/", expected: "/"},
+		{root: "/run/nvidia/driver/", expected: "/run/nvidia/driver"},
+		{root: "/run//nvidia/./driver", expected: "/run/nvidia/driver"},
+		{root: "run/nvidia/driver", expectedError: true},
+		{root: `C:\nvidia\driver`, expectedError: true},
+		{root: "C:/nvidia/driver", expectedError: true},
+		{root: `/run\nvidia`, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.root, func(t *testing.T) {
+			root, err := normalizeRoot(tc.root)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, root)
+		})
+	}
+}
+
+func TestResolveInRoot(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/lib64"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/lib64/libcuda.so.550.54.15"), nil, 0644))
+	require.NoError(t, os.Symlink("libcuda.so.550.54.15", filepath.Join(root, "usr/lib64/libcuda.so.1")))
+	require.NoError(t, os.Symlink("/usr/lib64", filepath.Join(root, "lib64")))
+	require.NoError(t, os.Symlink("../../../../usr", filepath.Join(root, "usr/lib64/escape")))
+	require.NoError(t, os.Symlink("loop", filepath.Join(root, "loop")))
+
+	testCases := []struct {
+		description   string
+		path          string
+		expected      string
+		expectedError bool
+	}{
+		{
+			description: "regular file",
+			path:        "/usr/lib64/libcuda.so.550.54.15",
+			expected:    "/usr/lib64/libcuda.so.550.54.15",
+		},
+		{
+			description: "relative symlink",
+			path:        "/usr/lib64/libcuda.so.1",
+			expected:    "/usr/lib64/libcuda.so.550.54.15",
+		},
+		{
+			description: "absolute symlink is resolved in root",
+			path:        "/lib64/libcuda.so.1",
+			expected:    "/usr/lib64/libcuda.so.550.54.15",
+		},
+		{
+			description: "symlink does not escape root",
+			path:        "/usr/lib64/escape/lib64",
+			expected:    "/usr/lib64",
+		},
+		{
+			description: "missing components are kept",
+			path:        "/lib64/missing/file",
+			expected:    "/usr/lib64/missing/file",
+		},
+		{
+			description:   "symlink loop",
+			path:          "/loop",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			resolved, err := resolveInRoot(root, tc.path)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, resolved)
+		})
+	}
+}
+
+func TestHostPathTransformer(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/lib64"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/lib64/libcuda.so.550.54.15"), nil, 0644))
+	require.NoError(t, os.Symlink("/usr/lib64", filepath.Join(root, "lib64")))
+
+	spec := &specs.Spec{
+		Devices: []specs.Device{
+			{
+				Name: "0",
+				ContainerEdits: specs.ContainerEdits{
+					DeviceNodes: []*specs.DeviceNode{
+						{Path: "/dev/nvidia0", HostPath: root + "/dev//nvidia0"},
+					},
+				},
+			},
+		},
+		ContainerEdits: specs.ContainerEdits{
+			DeviceNodes: []*specs.DeviceNode{
+				{Path: "/dev/nvidiactl"},
+			},
+			Mounts: []*specs.Mount{
+				{HostPath: root + "/lib64/libcuda.so.550.54.15", ContainerPath: "/lib64/libcuda.so.550.54.15"},
+				{HostPath: root + "-old/lib64/libcuda.so", ContainerPath: "/lib64/libcuda.so"},
+			},
+			Hooks: []*specs.Hook{
+				{
+					HookName: "createContainer",
+					Path:     "/usr/bin/nvidia-ctk",
+					Args:     []string{"nvidia-ctk", "hook", "--link", root + "/lib64::/lib64/libcuda.so"},
+				},
+				{
+					HookName: "prestart",
+					Path:     root + "/usr/bin/nvidia-ctk",
+					Args:     []string{"--folder", root + "/lib64", "--link", root + "/a::" + root + "/b"},
+				},
+			},
+		},
+	}
+
+	transformer := hostPathTransformer{root: root, targetRoot: "/run/nvidia/driver"}
+	require.NoError(t, transformer.Transform(spec))
+
+	require.Equal(t, "/run/nvidia/driver/dev/nvidia0", spec.Devices[0].ContainerEdits.DeviceNodes[0].HostPath)
+	require.Equal(t, "/dev/nvidiactl", spec.ContainerEdits.DeviceNodes[0].HostPath)
+	require.Equal(t, "/run/nvidia/driver/usr/lib64/libcuda.so.550.54.15", spec.ContainerEdits.Mounts[0].HostPath)
+	require.Equal(t, root+"-old/lib64/libcuda.so", spec.ContainerEdits.Mounts[1].HostPath)
+	require.Equal(t, []string{"nvidia-ctk", "hook", "--link", root + "/lib64::/lib64/libcuda.so"}, spec.ContainerEdits.Hooks[0].Args)
+	require.Equal(t, "/run/nvidia/driver/usr/bin/nvidia-ctk", spec.ContainerEdits.Hooks[1].Path)
+	require.Equal(t, []string{"--folder", "/run/nvidia/driver/lib64", "--link", "/run/nvidia/driver/a::/run/nvidia/driver/b"}, spec.ContainerEdits.Hooks[1].Args)
+}
+
+func TestHostPathTransformerInvalidPath(t *testing.T) {
+	spec := &specs.Spec{
+		ContainerEdits: specs.ContainerEdits{
+			Mounts: []*specs.Mount{
+				{HostPath: "lib64/libcuda.so", ContainerPath: "/lib64/libcuda.so"},
+			},
+		},
+	}
+
+	transformer := hostPathTransformer{root: "/driver-root", targetRoot: "/"}
+	require.Error(t, transformer.Transform(spec))
+}
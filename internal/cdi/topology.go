@@ -0,0 +1,124 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cdi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	nvcdispec "github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/spec"
+	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
+const (
+	// TopologyClass is the class of the CDI devices that mount topology files.
+	TopologyClass = "topology"
+	// TopologyContainerPath is the path at which the topology file of the
+	// allocated devices is mounted into containers.
+	TopologyContainerPath = "/etc/nvidia/topology.json"
+)
+
+// CreateTopologySpecFile writes the specified topology file and a transient
+// CDI spec for a device that mounts the file into containers. The device is
+// named after the hash of the topology so that allocations of the same
+// devices share a single spec. The qualified name of the device is returned.
+func (cdi *cdiHandler) CreateTopologySpecFile(topology []byte) (string, error) {
+	sum := sha256.Sum256(topology)
+	id := hex.EncodeToString(sum[:8])
+	name := cdi.QualifiedName(TopologyClass, id)
+
+	specPath := filepath.Join(cdi.specDir, cdiapi.GenerateTransientSpecName(cdi.vendor, TopologyClass, id)+".json")
+	if _, err := os.Stat(specPath); err == nil {
+		return name, nil
+	}
+
+	// The topology files are written to a subdirectory of the spec directory,
+	// since subdirectories are not scanned for CDI specs.
+	hostPath := filepath.Join(cdi.specDir, TopologyClass, id+".json")
+	if err := writeFileAtomic(hostPath, topology); err != nil {
+		return "", fmt.Errorf("failed to write topology file: %w", err)
+	}
+
+	spec, err := nvcdispec.New(
+		nvcdispec.WithVendor(cdi.vendor),
+		nvcdispec.WithClass(TopologyClass),
+		nvcdispec.WithFormat(nvcdispec.FormatJSON),
+		nvcdispec.WithDeviceSpecs([]specs.Device{
+			{
+				Name: id,
+				ContainerEdits: specs.ContainerEdits{
+					Mounts: []*specs.Mount{
+						{
+							HostPath:      hostPath,
+							ContainerPath: TopologyContainerPath,
+							Options:       []string{"ro", "nosuid", "nodev", "bind"},
+						},
+					},
+				},
+			},
+		}),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create topology CDI spec: %w", err)
+	}
+	if err := saveSpecAtomic(spec, specPath); err != nil {
+		return "", fmt.Errorf("failed to save topology CDI spec: %w", err)
+	}
+	return name, nil
+}
+
+// saveSpecAtomic writes the specified CDI spec as JSON through writeFileAtomic.
+// In contrast to spec.Save, the permissions of the spec are set before it is
+// renamed into the spec directory, so that the specs written by concurrent
+// allocations are always complete and readable.
+func saveSpecAtomic(spec nvcdispec.Interface, path string) error {
+	contents, err := json.Marshal(spec.Raw())
+	if err != nil {
+		return fmt.Errorf("failed to marshal spec: %w", err)
+	}
+	return writeFileAtomic(path, contents)
+}
+
+// writeFileAtomic writes the specified contents to a temporary file that is
+// then renamed so that concurrent allocations never observe a partial file.
+func writeFileAtomic(path string, contents []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,233 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package conflict
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// CheckpointFile is the name of the checkpoint in which the kubelet records
+// the devices registered by each device plugin.
+const CheckpointFile = "kubelet_internal_checkpoint"
+
+// probeTimeout is the time allowed to connect to another device plugin and
+// receive the devices it advertises.
+const probeTimeout = 2 * time.Second
+
+// Policy defines how conflicts with other device plugins are handled.
+type Policy string
+
+// Constants representing the supported conflict policies.
+const (
+	PolicyWarn Policy = "warn"
+	PolicyFail Policy = "fail"
+)
+
+// NewPolicy validates and returns the specified conflict policy.
+func NewPolicy(policy string) (Policy, error) {
+	switch p := Policy(policy); p {
+	case PolicyWarn, PolicyFail:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown plugin conflict policy: %v", policy)
+}
+
+// Conflict describes another device plugin that advertises the same NVIDIA
+// devices on the node.
+type Conflict struct {
+	Socket   string
+	Resource spec.ResourceName
+	Reason   string
+}
+
+func (c Conflict) String() string {
+	resource := string(c.Resource)
+	if resource == "" {
+		resource = "an unknown resource"
+	}
+	return fmt.Sprintf("%v serving %v: %v", c.Socket, resource, c.Reason)
+}
+
+// Detect scans the device plugin directory for other device plugins that
+// advertise the devices of the specified resources, which are about to be
+// served by this plugin. A live socket with the name of one of these
+// resources is a conflict, as is any other live socket that advertises one
+// of their GPUs. The resource of such a socket is looked up in the kubelet's
+// checkpoint of registered devices.
+func Detect(dir string, resources map[spec.ResourceName]rm.Devices) ([]Conflict, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device plugin directory: %w", err)
+	}
+
+	own := make(map[string]spec.ResourceName)
+	uuids := make(map[string]spec.ResourceName)
+	for resource, devices := range resources {
+		_, name := resource.Split()
+		own["nvidia-"+name+".sock"] = resource
+		for id := range devices {
+			uuids[rm.AnnotatedID(id).GetID()] = resource
+		}
+	}
+
+	var registered map[spec.ResourceName][]string
+	var conflicts []Conflict
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSocket == 0 || entry.Name() == filepath.Base(pluginapi.KubeletSocket) {
+			continue
+		}
+		socket := filepath.Join(dir, entry.Name())
+		ids, err := advertisedDevices(socket)
+		if err != nil {
+			// Sockets that are not served are stale and do not conflict.
+			continue
+		}
+		if resource, ok := own[entry.Name()]; ok {
+			conflicts = append(conflicts, Conflict{
+				Socket:   socket,
+				Resource: resource,
+				Reason:   "socket is already served by another process",
+			})
+			continue
+		}
+		for _, id := range ids {
+			resource, ok := uuids[rm.AnnotatedID(id).GetID()]
+			if !ok {
+				continue
+			}
+			if registered == nil {
+				registered, err = registeredDevices(filepath.Join(dir, CheckpointFile))
+				if err != nil {
+					return nil, err
+				}
+			}
+			conflicts = append(conflicts, Conflict{
+				Socket:   socket,
+				Resource: registeredResource(registered, ids),
+				Reason:   fmt.Sprintf("device %v is also advertised as %v", id, resource),
+			})
+			break
+		}
+	}
+	return conflicts, nil
+}
+
+// advertisedDevices returns the IDs of the devices advertised by the device
+// plugin serving the specified socket.
+func advertisedDevices(socket string) ([]string, error) {
+	// Fail fast on stale sockets, which gRPC would keep redialing.
+	probe, err := net.DialTimeout("unix", socket, probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	probe.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	stream, err := pluginapi.NewDevicePluginClient(conn).ListAndWatch(ctx, &pluginapi.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	response, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range response.Devices {
+		ids = append(ids, d.ID)
+	}
+	return ids, nil
+}
+
+// checkpoint is the subset of the kubelet's device manager checkpoint that
+// records the devices registered for each resource.
+type checkpoint struct {
+	Data struct {
+		RegisteredDevices map[spec.ResourceName][]string `json:"RegisteredDevices"`
+	} `json:"Data"`
+}
+
+// registeredDevices returns the devices registered for each resource from the
+// kubelet's checkpoint. A missing checkpoint has no registered devices.
+func registeredDevices(path string) (map[spec.ResourceName][]string, error) {
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[spec.ResourceName][]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubelet checkpoint: %w", err)
+	}
+	var c checkpoint
+	if err := json.Unmarshal(contents, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse kubelet checkpoint: %w", err)
+	}
+	if c.Data.RegisteredDevices == nil {
+		return map[spec.ResourceName][]string{}, nil
+	}
+	return c.Data.RegisteredDevices, nil
+}
+
+// registeredResource returns the resource for which the specified devices are
+// registered, or an empty name if they are not registered.
+func registeredResource(registered map[spec.ResourceName][]string, ids []string) spec.ResourceName {
+	advertised := make(map[string]bool)
+	for _, id := range ids {
+		advertised[id] = true
+	}
+	var resources []spec.ResourceName
+	for resource, devices := range registered {
+		for _, id := range devices {
+			if advertised[id] {
+				resources = append(resources, resource)
+				break
+			}
+		}
+	}
+	if len(resources) == 0 {
+		return ""
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i] < resources[j] })
+	return resources[0]
+}
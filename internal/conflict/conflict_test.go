@@ -0,0 +1,152 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package conflict
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testDevicePlugin struct {
+	pluginapi.UnimplementedDevicePluginServer
+	ids []string
+}
+
+func (p *testDevicePlugin) ListAndWatch(_ *pluginapi.Empty, s pluginapi.DevicePlugin_ListAndWatchServer) error {
+	response := &pluginapi.ListAndWatchResponse{}
+	for _, id := range p.ids {
+		response.Devices = append(response.Devices, &pluginapi.Device{ID: id, Health: pluginapi.Healthy})
+	}
+	if err := s.Send(response); err != nil {
+		return err
+	}
+	<-s.Context().Done()
+	return nil
+}
+
+// serve serves a device plugin advertising the specified devices on the socket.
+func serve(t *testing.T, socket string, ids ...string) {
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pluginapi.RegisterDevicePluginServer(server, &testDevicePlugin{ids: ids})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+}
+
+func TestDetect(t *testing.T) {
+	resources := map[spec.ResourceName]rm.Devices{
+		"nvidia.com/gpu": {
+			"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0"}},
+			"GPU-1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1"}},
+		},
+	}
+	checkpoint := `{"Data":{"PodDeviceEntries":null,"RegisteredDevices":{"nvidia.com/gpu.fork":["GPU-1::0","GPU-1::1"]}},"Checksum":1}`
+
+	testCases := []struct {
+		description       string
+		setup             func(t *testing.T, dir string)
+		expectedConflicts []Conflict
+	}{
+		{
+			description: "no other plugins",
+		},
+		{
+			description: "stale sockets are ignored",
+			setup: func(t *testing.T, dir string) {
+				listener, err := net.Listen("unix", filepath.Join(dir, "nvidia-gpu.sock"))
+				require.NoError(t, err)
+				listener.(*net.UnixListener).SetUnlinkOnClose(false)
+				require.NoError(t, listener.Close())
+			},
+		},
+		{
+			description: "plugins advertising other devices are ignored",
+			setup: func(t *testing.T, dir string) {
+				serve(t, filepath.Join(dir, "other.sock"), "0000:3b:00.1")
+			},
+		},
+		{
+			description: "socket of a resource is already served",
+			setup: func(t *testing.T, dir string) {
+				serve(t, filepath.Join(dir, "nvidia-gpu.sock"), "GPU-0")
+			},
+			expectedConflicts: []Conflict{
+				{Resource: "nvidia.com/gpu", Socket: "nvidia-gpu.sock", Reason: "socket is already served by another process"},
+			},
+		},
+		{
+			description: "plugin advertising the same GPUs is detected",
+			setup: func(t *testing.T, dir string) {
+				serve(t, filepath.Join(dir, "fork.sock"), "GPU-1::0", "GPU-1::1")
+				require.NoError(t, os.WriteFile(filepath.Join(dir, CheckpointFile), []byte(checkpoint), 0600))
+			},
+			expectedConflicts: []Conflict{
+				{Resource: "nvidia.com/gpu.fork", Socket: "fork.sock", Reason: "device GPU-1::0 is also advertised as nvidia.com/gpu"},
+			},
+		},
+		{
+			description: "resource is unknown without a checkpoint",
+			setup: func(t *testing.T, dir string) {
+				serve(t, filepath.Join(dir, "fork.sock"), "GPU-0")
+			},
+			expectedConflicts: []Conflict{
+				{Socket: "fork.sock", Reason: "device GPU-0 is also advertised as nvidia.com/gpu"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			// The socket paths must not exceed the maximum length of a unix
+			// socket path, which t.TempDir() may exceed.
+			dir, err := os.MkdirTemp("", "conflict")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			if tc.setup != nil {
+				tc.setup(t, dir)
+			}
+
+			conflicts, err := Detect(dir, resources)
+			require.NoError(t, err)
+			for i := range conflicts {
+				conflicts[i].Socket = filepath.Base(conflicts[i].Socket)
+			}
+			require.Equal(t, tc.expectedConflicts, conflicts)
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	policy, err := NewPolicy("fail")
+	require.NoError(t, err)
+	require.Equal(t, PolicyFail, policy)
+
+	_, err = NewPolicy("ignore")
+	require.Error(t, err)
+}
@@ -116,3 +116,54 @@ func DeviceTotalMem(device Device) (uint64, Result) {
 func (device Device) TotalMem() (uint64, Result) {
 	return DeviceTotalMem(device)
 }
+
+// CtxCreate creates a context on the specified device and makes it current
+// for the calling thread.
+func CtxCreate(flags uint32, device Device) (Context, Result) {
+	var ctx Context
+	r := cuCtxCreate(&ctx, flags, device)
+
+	return ctx, r
+}
+
+// CtxDestroy destroys the specified context.
+func CtxDestroy(ctx Context) Result {
+	return cuCtxDestroy(ctx)
+}
+
+// Destroy converts the CtxDestroy function to a context method
+func (ctx Context) Destroy() Result {
+	return CtxDestroy(ctx)
+}
+
+// CtxSynchronize blocks until all work of the current context has completed.
+func CtxSynchronize() Result {
+	return cuCtxSynchronize()
+}
+
+// MemGetInfo returns the free and total memory of the device of the current
+// context.
+func MemGetInfo() (uint64, uint64, Result) {
+	var free, total uint64
+	r := cuMemGetInfo(&free, &total)
+
+	return free, total, r
+}
+
+// MemAlloc allocates the specified number of bytes of device memory.
+func MemAlloc(size uint64) (DevicePtr, Result) {
+	var ptr DevicePtr
+	r := cuMemAlloc(&ptr, size)
+
+	return ptr, r
+}
+
+// MemFree frees the specified device memory.
+func MemFree(ptr DevicePtr) Result {
+	return cuMemFree(ptr)
+}
+
+// MemsetD8 sets the specified number of bytes of device memory to a value.
+func MemsetD8(ptr DevicePtr, value uint8, count uint64) Result {
+	return cuMemsetD8(ptr, value, count)
+}
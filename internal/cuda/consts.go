@@ -93,3 +93,9 @@ const (
 
 // Device represents a CUDA device handle
 type Device int32
+
+// Context represents a CUDA context handle
+type Context uintptr
+
+// DevicePtr represents a CUDA device memory address
+type DevicePtr uint64
@@ -31,6 +31,8 @@ import (
 #endif
 
 typedef int CUdevice;
+typedef struct CUctx_st *CUcontext;
+typedef unsigned long long CUdeviceptr;
 
 typedef enum CUdevice_attribute_enum {
     CU_DEVICE_ATTRIBUTE_COMPUTE_CAPABILITY_MAJOR = 75,
@@ -108,6 +110,13 @@ CUresult CUDAAPI cuDeviceGetAttribute(int *pi, CUdevice_attribute attrib, CUdevi
 CUresult CUDAAPI cuDeviceGetCount(int *count);
 CUresult CUDAAPI cuDeviceTotalMem(size_t *bytes, CUdevice dev);
 CUresult CUDAAPI cuDeviceGetName(char *name, int len, CUdevice dev);
+CUresult CUDAAPI cuCtxCreate_v2(CUcontext *pctx, unsigned int flags, CUdevice dev);
+CUresult CUDAAPI cuCtxDestroy_v2(CUcontext ctx);
+CUresult CUDAAPI cuCtxSynchronize(void);
+CUresult CUDAAPI cuMemGetInfo_v2(size_t *free, size_t *total);
+CUresult CUDAAPI cuMemAlloc_v2(CUdeviceptr *dptr, size_t bytesize);
+CUresult CUDAAPI cuMemFree_v2(CUdeviceptr dptr);
+CUresult CUDAAPI cuMemsetD8_v2(CUdeviceptr dstDevice, unsigned char uc, size_t N);
 */
 import "C"
 
@@ -174,3 +183,64 @@ func cuDeviceGetName(name *byte, len int32, dev Device) Result {
 
 	return Result(_ret)
 }
+
+// cuCtxCreate function as declared in cuda.h
+func cuCtxCreate(ctx *Context, flags uint32, dev Device) Result {
+	cCtx := (*C.CUcontext)(unsafe.Pointer(ctx))
+	cFlags := (C.uint)(flags)
+	cDev := (C.CUdevice)(dev)
+	_ret := C.cuCtxCreate_v2(cCtx, cFlags, cDev)
+
+	return Result(_ret)
+}
+
+// cuCtxDestroy function as declared in cuda.h
+func cuCtxDestroy(ctx Context) Result {
+	cCtx := *(*C.CUcontext)(unsafe.Pointer(&ctx))
+	_ret := C.cuCtxDestroy_v2(cCtx)
+
+	return Result(_ret)
+}
+
+// cuCtxSynchronize function as declared in cuda.h
+func cuCtxSynchronize() Result {
+	_ret := C.cuCtxSynchronize()
+
+	return Result(_ret)
+}
+
+// cuMemGetInfo function as declared in cuda.h
+func cuMemGetInfo(free *uint64, total *uint64) Result {
+	cFree := (*C.size_t)(unsafe.Pointer(free))
+	cTotal := (*C.size_t)(unsafe.Pointer(total))
+	_ret := C.cuMemGetInfo_v2(cFree, cTotal)
+
+	return Result(_ret)
+}
+
+// cuMemAlloc function as declared in cuda.h
+func cuMemAlloc(ptr *DevicePtr, size uint64) Result {
+	cPtr := (*C.CUdeviceptr)(unsafe.Pointer(ptr))
+	cSize := (C.size_t)(size)
+	_ret := C.cuMemAlloc_v2(cPtr, cSize)
+
+	return Result(_ret)
+}
+
+// cuMemFree function as declared in cuda.h
+func cuMemFree(ptr DevicePtr) Result {
+	cPtr := (C.CUdeviceptr)(ptr)
+	_ret := C.cuMemFree_v2(cPtr)
+
+	return Result(_ret)
+}
+
+// cuMemsetD8 function as declared in cuda.h
+func cuMemsetD8(ptr DevicePtr, value uint8, count uint64) Result {
+	cPtr := (C.CUdeviceptr)(ptr)
+	cValue := (C.uchar)(value)
+	cCount := (C.size_t)(count)
+	_ret := C.cuMemsetD8_v2(cPtr, cValue, cCount)
+
+	return Result(_ret)
+}
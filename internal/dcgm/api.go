@@ -0,0 +1,68 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package dcgm provides a client for the background health watches of a DCGM
+// host engine.
+package dcgm
+
+import (
+	"errors"
+)
+
+// System is a DCGM health watch system.
+type System string
+
+// These constants define the DCGM health watch systems.
+const (
+	SystemPCIe    System = "pcie"
+	SystemNVLink  System = "nvlink"
+	SystemSM      System = "sm"
+	SystemMemory  System = "memory"
+	SystemInforom System = "inforom"
+	SystemThermal System = "thermal"
+	SystemPower   System = "power"
+)
+
+// Severity is the severity of a health incident.
+type Severity int
+
+// These constants define the severities of a health incident.
+const (
+	SeverityWarning Severity = iota + 1
+	SeverityFailure
+)
+
+// ErrUnavailable is returned if no DCGM host engine can be reached.
+var ErrUnavailable = errors.New("dcgm unavailable")
+
+// Interface defines the DCGM operations used by the device plugin.
+//
+//go:generate moq -rm -out api_mock.go . Interface
+type Interface interface {
+	// SetWatches enables the background health watches for the specified systems on all GPUs.
+	SetWatches(systems []System) error
+	// Check returns the incidents reported by the enabled health watches.
+	Check() ([]Incident, error)
+}
+
+// Incident is a health incident reported by DCGM for a GPU.
+type Incident struct {
+	// GPU is the DCGM ID of the GPU. This matches the NVML index of the GPU.
+	GPU      int
+	System   System
+	Severity Severity
+	Message  string
+}
@@ -0,0 +1,228 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package dcgm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSocket is the unix socket probed for a host engine if no address is specified.
+	DefaultSocket = "/run/nvidia/dcgm.sock"
+	// DefaultAddress is the TCP address probed for a host engine if no address is specified.
+	DefaultAddress = "localhost:5555"
+
+	unixPrefix  = "unix://"
+	dialTimeout = time.Second
+
+	// allGPUsGroup is the DCGM group containing all GPUs.
+	allGPUsGroup = "0"
+)
+
+// systemFlags maps a system to the flag used by 'dcgmi health --set'.
+var systemFlags = map[System]string{
+	SystemPCIe:    "p",
+	SystemNVLink:  "n",
+	SystemSM:      "s",
+	SystemMemory:  "m",
+	SystemInforom: "i",
+	SystemThermal: "t",
+	SystemPower:   "t",
+}
+
+// systemNames maps the (lower case) names of the systems in the 'dcgmi health --check' output to a system.
+var systemNames = map[string]System{
+	"pcie":    SystemPCIe,
+	"nvlink":  SystemNVLink,
+	"sm":      SystemSM,
+	"memory":  SystemMemory,
+	"inforom": SystemInforom,
+	"thermal": SystemThermal,
+	"power":   SystemPower,
+}
+
+type runner func(args ...string) ([]byte, error)
+
+type dcgmi struct {
+	address string
+	run     runner
+}
+
+var _ Interface = (*dcgmi)(nil)
+
+// New creates a DCGM client for the host engine at the specified address.
+// The client uses the dcgmi command line tool to communicate with the host engine.
+// If no address is specified, DefaultSocket and DefaultAddress are probed.
+// An error wrapping ErrUnavailable is returned if no host engine can be reached.
+func New(address string) (Interface, error) {
+	if _, err := exec.LookPath("dcgmi"); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	address, err := resolveAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return &dcgmi{
+		address: address,
+		run: func(args ...string) ([]byte, error) {
+			return exec.Command("dcgmi", args...).Output()
+		},
+	}, nil
+}
+
+// resolveAddress returns the address of a reachable host engine.
+func resolveAddress(address string) (string, error) {
+	candidates := []string{address}
+	if address == "" {
+		candidates = []string{unixPrefix + DefaultSocket, DefaultAddress}
+	}
+	for _, candidate := range candidates {
+		if isReachable(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no host engine found at %v", ErrUnavailable, strings.Join(candidates, ", "))
+}
+
+func isReachable(address string) bool {
+	if socket, ok := strings.CutPrefix(address, unixPrefix); ok {
+		_, err := os.Stat(socket)
+		return err == nil
+	}
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// SetWatches enables the health watches for the specified systems.
+func (d *dcgmi) SetWatches(systems []System) error {
+	flags := make(map[string]bool)
+	for _, s := range systems {
+		flag, ok := systemFlags[s]
+		if !ok {
+			return fmt.Errorf("unknown system %q", s)
+		}
+		flags[flag] = true
+	}
+	var set []string
+	for flag := range flags {
+		set = append(set, flag)
+	}
+	sort.Strings(set)
+
+	if _, err := d.run("health", "--host", d.address, "-g", allGPUsGroup, "-s", strings.Join(set, "")); err != nil {
+		return fmt.Errorf("failed to set health watches: %w", err)
+	}
+	return nil
+}
+
+// Check returns the incidents reported by the health watches.
+func (d *dcgmi) Check() ([]Incident, error) {
+	output, err := d.run("health", "--host", d.address, "-g", allGPUsGroup, "-c", "-j")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check health: %w", err)
+	}
+	return parseHealthCheck(output)
+}
+
+// healthNode is a node in the JSON output of 'dcgmi health --check'.
+type healthNode struct {
+	Value    string                `json:"value"`
+	Children map[string]healthNode `json:"children"`
+}
+
+// parseHealthCheck parses the JSON output of 'dcgmi health --check'.
+// The output has the form:
+//
+//	{"body": {"GPU": {"children": {"<id>": {"children": {"<system>": {"value": "<health>", "children": {...}}}}}}}}
+//
+// where the nested children of a system contain the messages for the incidents.
+func parseHealthCheck(output []byte) ([]Incident, error) {
+	var report struct {
+		Body map[string]healthNode `json:"body"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse health check output: %w", err)
+	}
+
+	var incidents []Incident
+	for id, gpu := range report.Body["GPU"].Children {
+		index, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPU id %q: %w", id, err)
+		}
+		for name, status := range gpu.Children {
+			system, ok := systemNames[strings.ToLower(name)]
+			if !ok {
+				continue
+			}
+			var severity Severity
+			switch strings.ToLower(status.Value) {
+			case "warning":
+				severity = SeverityWarning
+			case "failure":
+				severity = SeverityFailure
+			default:
+				continue
+			}
+			incidents = append(incidents, Incident{
+				GPU:      index,
+				System:   system,
+				Severity: severity,
+				Message:  strings.Join(status.messages(), "; "),
+			})
+		}
+	}
+
+	sort.Slice(incidents, func(i, j int) bool {
+		if incidents[i].GPU != incidents[j].GPU {
+			return incidents[i].GPU < incidents[j].GPU
+		}
+		return incidents[i].System < incidents[j].System
+	})
+	return incidents, nil
+}
+
+// messages returns the values of all nested children of the node.
+func (n healthNode) messages() []string {
+	var keys []string
+	for k := range n.Children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var messages []string
+	for _, k := range keys {
+		child := n.Children[k]
+		if child.Value != "" {
+			messages = append(messages, child.Value)
+		}
+		messages = append(messages, child.messages()...)
+	}
+	return messages
+}
@@ -0,0 +1,118 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package dcgm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetWatches(t *testing.T) {
+	var calls [][]string
+	d := &dcgmi{
+		address: "unix:///run/nvidia/dcgm.sock",
+		run: func(args ...string) ([]byte, error) {
+			calls = append(calls, args)
+			return nil, nil
+		},
+	}
+
+	err := d.SetWatches([]System{SystemSM, SystemPCIe, SystemThermal, SystemPower})
+	require.NoError(t, err)
+	require.EqualValues(t, [][]string{
+		{"health", "--host", "unix:///run/nvidia/dcgm.sock", "-g", "0", "-s", "pst"},
+	}, calls)
+
+	require.Error(t, d.SetWatches([]System{"unknown"}))
+}
+
+func TestParseHealthCheck(t *testing.T) {
+	testCases := []struct {
+		description   string
+		output        string
+		expected      []Incident
+		expectedError bool
+	}{
+		{
+			description: "healthy",
+			output: `{
+  "body": {
+    "Overall Health": {"value": "Healthy"}
+  },
+  "header": ["Health Monitor Report"]
+}`,
+		},
+		{
+			description: "incidents for multiple gpus",
+			output: `{
+  "body": {
+    "GPU": {
+      "children": {
+        "1": {
+          "children": {
+            "NVLink": {
+              "children": {"Error": {"value": "Detected more than 0 CRC errors"}},
+              "value": "Warning"
+            }
+          },
+          "value": "Warning"
+        },
+        "0": {
+          "children": {
+            "PCIe": {
+              "children": {"Error": {"value": "Detected more than 8 PCIe replays"}},
+              "value": "Failure"
+            },
+            "Memory": {"value": "Healthy"}
+          },
+          "value": "Failure"
+        }
+      }
+    },
+    "Overall Health": {"value": "Failure"}
+  }
+}`,
+			expected: []Incident{
+				{GPU: 0, System: SystemPCIe, Severity: SeverityFailure, Message: "Detected more than 8 PCIe replays"},
+				{GPU: 1, System: SystemNVLink, Severity: SeverityWarning, Message: "Detected more than 0 CRC errors"},
+			},
+		},
+		{
+			description:   "invalid gpu id",
+			output:        `{"body": {"GPU": {"children": {"GPU-0": {}}}}}`,
+			expectedError: true,
+		},
+		{
+			description:   "invalid output",
+			output:        `Error: unable to connect to host engine`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			incidents, err := parseHealthCheck([]byte(tc.output))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expected, incidents)
+		})
+	}
+}
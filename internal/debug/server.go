@@ -0,0 +1,168 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/metrics"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// Source is a plugin whose state is exposed on the debug endpoint.
+type Source interface {
+	Resource() spec.ResourceName
+	Devices() rm.Devices
+	ListAndWatchSnapshot() *plugin.ListAndWatchSnapshot
+	RecentEvents() []plugin.Event
+	MPSDaemonStatus() *plugin.MPSDaemonStatus
+}
+
+// Server serves debug information about the running plugins over HTTP.
+type Server struct {
+	address string
+	mux     *http.ServeMux
+	xids    *metrics.XidHistory
+
+	sync.Mutex
+	config  *spec.Config
+	sources []Source
+}
+
+// NewServer creates a debug server that listens on the specified address and
+// exposes the specified Xid history, if any. A nil server is returned if the
+// address is empty.
+func NewServer(address string, xids *metrics.XidHistory) *Server {
+	if address == "" {
+		return nil
+	}
+	s := &Server{
+		address: address,
+		mux:     http.NewServeMux(),
+		xids:    xids,
+	}
+	s.mux.HandleFunc("GET /debug/listandwatch", s.handleListAndWatch)
+	s.mux.HandleFunc("GET /debug/status", s.handleStatus)
+	s.mux.HandleFunc("GET /debug/config", s.handleConfig)
+	s.mux.HandleFunc("GET /debug/xids", s.handleXids)
+	return s
+}
+
+// Update sets the config and the plugins that are exposed by the server.
+// This is called every time the plugins are (re)started.
+func (s *Server) Update(config *spec.Config, sources []Source) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.config = config
+	s.sources = sources
+}
+
+// ListenAndServe serves the debug endpoints until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", s.address, err)
+	}
+	server := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	klog.Infof("Serving debug endpoints on %v", s.address)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handleListAndWatch writes the device list that was last sent to the
+// kubelet for each resource. A resource maps to null if no list was sent yet.
+func (s *Server) handleListAndWatch(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	snapshots := make(map[spec.ResourceName]*plugin.ListAndWatchSnapshot)
+	for _, source := range s.sources {
+		snapshots[source.Resource()] = source.ListAndWatchSnapshot()
+	}
+	s.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshots); err != nil {
+		klog.Warningf("Failed to write debug response: %v", err)
+	}
+}
+
+// configResponse is the response of the config endpoint.
+type configResponse struct {
+	Config     *spec.Config    `json:"config"`
+	Provenance spec.Provenance `json:"provenance"`
+}
+
+// handleConfig writes the effective config of the plugins together with the
+// sources that its values were read from. The config maps to null if the
+// plugins were not started yet.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	response := configResponse{Config: s.config}
+	if s.config != nil {
+		response.Provenance = s.config.Provenance
+	}
+	s.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		klog.Warningf("Failed to write debug response: %v", err)
+	}
+}
+
+// handleXids writes the Xid history of each device, keyed by the ID of the
+// device. The history can be restricted to a single device with the device
+// query parameter.
+func (s *Server) handleXids(w http.ResponseWriter, r *http.Request) {
+	devices := s.xids.Devices()
+	if id := r.URL.Query().Get("device"); id != "" {
+		filtered := make(map[string]metrics.DeviceXids)
+		if d, exists := devices[id]; exists {
+			filtered[id] = d
+		}
+		devices = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(devices); err != nil {
+		klog.Warningf("Failed to write debug response: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/location"
+	"github.com/NVIDIA/k8s-device-plugin/internal/metrics"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testSource struct {
+	resource spec.ResourceName
+	snapshot *plugin.ListAndWatchSnapshot
+	devices  rm.Devices
+	events   []plugin.Event
+	mps      *plugin.MPSDaemonStatus
+}
+
+func (s testSource) Resource() spec.ResourceName                        { return s.resource }
+func (s testSource) Devices() rm.Devices                                { return s.devices }
+func (s testSource) ListAndWatchSnapshot() *plugin.ListAndWatchSnapshot { return s.snapshot }
+func (s testSource) RecentEvents() []plugin.Event                       { return s.events }
+func (s testSource) MPSDaemonStatus() *plugin.MPSDaemonStatus           { return s.mps }
+
+func TestHandleListAndWatch(t *testing.T) {
+	snapshot := &plugin.ListAndWatchSnapshot{
+		Timestamp: time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC),
+		Devices: []plugin.SnapshotDevice{
+			{ID: "GPU-0", Health: "Healthy", NUMANodes: []int64{0}},
+		},
+	}
+
+	s := NewServer("localhost:0", nil)
+	s.Update(nil, []Source{
+		testSource{resource: "nvidia.com/gpu", snapshot: snapshot},
+		testSource{resource: "nvidia.com/mig-1g.5gb"},
+	})
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/listandwatch", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response map[spec.ResourceName]*plugin.ListAndWatchSnapshot
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, map[spec.ResourceName]*plugin.ListAndWatchSnapshot{
+		"nvidia.com/gpu":        snapshot,
+		"nvidia.com/mig-1g.5gb": nil,
+	}, response)
+}
+
+func TestHandleStatus(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}, Index: "0"},
+		"GPU-1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy}, Index: "1"},
+	}
+	snapshot := &plugin.ListAndWatchSnapshot{
+		Timestamp: time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC),
+		Devices: []plugin.SnapshotDevice{
+			{ID: "GPU-0", Health: pluginapi.Healthy},
+			{ID: "GPU-1", Health: pluginapi.Unhealthy},
+		},
+	}
+	events := []plugin.Event{
+		{Timestamp: time.Date(2024, 4, 1, 11, 0, 0, 0, time.UTC), Message: "Registered with the kubelet"},
+		{Timestamp: time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC), Message: "Device GPU-1 marked unhealthy"},
+	}
+
+	s := NewServer("localhost:0", nil)
+	s.Update(&spec.Config{}, []Source{
+		testSource{
+			resource: "nvidia.com/gpu",
+			snapshot: snapshot,
+			devices:  devices,
+			events:   events,
+			mps:      &plugin.MPSDaemonStatus{PipeDir: "/run/nvidia/mps/nvidia.com/gpu/pipe", Error: "<closed>"},
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/status", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+
+	body := recorder.Body.String()
+	require.Contains(t, body, "<h2>nvidia.com/gpu</h2>")
+	require.Contains(t, body, `<tr><td>GPU-1</td><td>1</td><td class="Unhealthy">Unhealthy</td></tr>`)
+	require.Contains(t, body, "Pipe directory: /run/nvidia/mps/nvidia.com/gpu/pipe")
+	require.Contains(t, body, "&lt;closed&gt;")
+	require.Less(t, strings.Index(body, "Device GPU-1 marked unhealthy"), strings.Index(body, "Registered with the kubelet"))
+	require.NotContains(t, body, "<th>Location</th>")
+}
+
+func TestHandleStatusWithLocations(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}, Index: "0", Location: &location.Location{Rack: "r12", Slot: "4"}},
+		"GPU-1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy}, Index: "1"},
+	}
+
+	s := NewServer("localhost:0", nil)
+	s.Update(&spec.Config{}, []Source{
+		testSource{resource: "nvidia.com/gpu", devices: devices},
+	})
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/status", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body := recorder.Body.String()
+	require.Contains(t, body, "<th>Location</th>")
+	require.Contains(t, body, `<tr><td>GPU-0</td><td>0</td><td class="Healthy">Healthy</td><td>rack=r12,slot=4</td></tr>`)
+	require.Contains(t, body, `<tr><td>GPU-1</td><td>1</td><td class="Healthy">Healthy</td><td></td></tr>`)
+}
+
+func TestHandleConfig(t *testing.T) {
+	s := NewServer("localhost:0", nil)
+	s.Update(&spec.Config{
+		Version: spec.Version,
+		Provenance: spec.Provenance{
+			"--mig-strategy": spec.SourceEnv,
+			"sharing.timeSlicing.resources[0].replicas": spec.SourceCRD,
+		},
+	}, nil)
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response struct {
+		Config     map[string]interface{} `json:"config"`
+		Provenance spec.Provenance        `json:"provenance"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, spec.Version, response.Config["version"])
+	require.Equal(t, spec.Provenance{
+		"--mig-strategy": spec.SourceEnv,
+		"sharing.timeSlicing.resources[0].replicas": spec.SourceCRD,
+	}, response.Provenance)
+}
+
+func TestHandleXids(t *testing.T) {
+	xids := metrics.NewXidHistory("test", 10, nil)
+	timestamp := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	xids.ReportHealthEvent(rm.HealthEvent{Timestamp: timestamp, Resource: "nvidia.com/gpu", DeviceID: "GPU-0", Reason: rm.HealthEventReasonXid, Xid: 79})
+	xids.ReportHealthEvent(rm.HealthEvent{Timestamp: timestamp, Resource: "nvidia.com/gpu", DeviceID: "GPU-1", Reason: rm.HealthEventReasonXid, Xid: 48})
+
+	s := NewServer("localhost:0", xids)
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/xids?device=GPU-0", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response map[string]metrics.DeviceXids
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, map[string]metrics.DeviceXids{
+		"GPU-0": {
+			Counts: map[uint64]uint64{79: 1},
+			Events: []metrics.XidEvent{{Xid: 79, Timestamp: timestamp, Resource: "nvidia.com/gpu"}},
+		},
+	}, response)
+}
+
+func TestNewServerDisabled(t *testing.T) {
+	s := NewServer("", nil)
+	require.Nil(t, s)
+	s.Update(nil, nil)
+	require.NoError(t, s.ListenAndServe(context.Background()))
+}
@@ -0,0 +1,202 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package debug
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/info"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+)
+
+// statusTemplate renders the status page. The page is intentionally plain so
+// that it is readable with text-based browsers.
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>NVIDIA Device Plugin Status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+.Unhealthy, .unhealthy { color: #b00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>NVIDIA Device Plugin Status</h1>
+<p>Version: {{ .Version }}<br>Generated: {{ .Timestamp.Format "2006-01-02T15:04:05Z07:00" }}</p>
+
+<h2>Sharing</h2>
+<p>Strategy: {{ .SharingStrategy }}</p>
+{{- if .Sharing }}
+<pre>{{ .Sharing }}</pre>
+{{- end }}
+
+{{- range .Resources }}
+<h2>{{ .Name }}</h2>
+{{- if .Advertised }}
+<p>Last advertised to the kubelet: {{ .Advertised.Format "2006-01-02T15:04:05Z07:00" }}</p>
+{{- else }}
+<p>Not advertised to the kubelet.</p>
+{{- end }}
+{{- $located := .Located }}
+<table>
+<tr><th>ID</th><th>Index</th><th>Health</th>{{ if $located }}<th>Location</th>{{ end }}</tr>
+{{- range .Devices }}
+<tr><td>{{ .ID }}</td><td>{{ .Index }}</td><td class="{{ .Health }}">{{ .Health }}</td>{{ if $located }}<td>{{ .Location }}</td>{{ end }}</tr>
+{{- end }}
+</table>
+{{- with .MPS }}
+<h3>MPS daemon</h3>
+<p>Pipe directory: {{ .PipeDir }}<br>
+{{- if .Healthy }}
+Healthy
+{{- else }}
+<span class="unhealthy">Unhealthy</span>: {{ .Error }}
+{{- end }}</p>
+{{- end }}
+<h3>Recent events</h3>
+{{- if .Events }}
+<table>
+<tr><th>Time</th><th>Event</th></tr>
+{{- range .Events }}
+<tr><td>{{ .Timestamp.Format "2006-01-02T15:04:05Z07:00" }}</td><td>{{ .Message }}</td></tr>
+{{- end }}
+</table>
+{{- else }}
+<p>No events.</p>
+{{- end }}
+{{- else }}
+<p>No resources.</p>
+{{- end }}
+</body>
+</html>
+`))
+
+// statusPage is the data rendered on the status page.
+type statusPage struct {
+	Version         string
+	Timestamp       time.Time
+	SharingStrategy spec.SharingStrategy
+	Sharing         string
+	Resources       []resourceStatus
+}
+
+// resourceStatus is the status of a single resource on the status page.
+type resourceStatus struct {
+	Name       spec.ResourceName
+	Advertised *time.Time
+	Devices    []deviceStatus
+	// Located indicates whether the location of any of the devices is known.
+	Located bool
+	MPS     *plugin.MPSDaemonStatus
+	Events  []plugin.Event
+}
+
+// deviceStatus is the status of a single device on the status page.
+type deviceStatus struct {
+	ID       string
+	Index    string
+	Health   string
+	Location string
+}
+
+// handleStatus renders a read-only HTML page summarizing the devices, their
+// health, the sharing config, the MPS daemons, and the recent events of each
+// resource.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	page := s.status()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, page); err != nil {
+		klog.Warningf("Failed to write status page: %v", err)
+	}
+}
+
+// status collects the data rendered on the status page.
+func (s *Server) status() *statusPage {
+	s.Lock()
+	config := s.config
+	sources := s.sources
+	s.Unlock()
+
+	page := &statusPage{
+		Version:   info.GetVersionString(),
+		Timestamp: time.Now(),
+	}
+	if config != nil {
+		page.SharingStrategy = config.Sharing.SharingStrategy()
+		if sharing, err := json.MarshalIndent(config.Sharing, "", "  "); err == nil {
+			page.Sharing = string(sharing)
+		}
+	}
+	for _, source := range sources {
+		page.Resources = append(page.Resources, newResourceStatus(source))
+	}
+	return page
+}
+
+// newResourceStatus collects the status of the specified source. If the
+// devices were advertised to the kubelet, the advertised health is shown.
+func newResourceStatus(source Source) resourceStatus {
+	status := resourceStatus{
+		Name: source.Resource(),
+		MPS:  source.MPSDaemonStatus(),
+	}
+
+	advertised := make(map[string]string)
+	if snapshot := source.ListAndWatchSnapshot(); snapshot != nil {
+		timestamp := snapshot.Timestamp
+		status.Advertised = &timestamp
+		for _, d := range snapshot.Devices {
+			advertised[d.ID] = d.Health
+		}
+	}
+	for _, d := range source.Devices() {
+		health := d.Health
+		if h, exists := advertised[d.ID]; exists {
+			health = h
+		}
+		status.Devices = append(status.Devices, deviceStatus{
+			ID:       d.ID,
+			Index:    d.Index,
+			Health:   health,
+			Location: d.Location.String(),
+		})
+		if d.Location != nil {
+			status.Located = true
+		}
+	}
+	sort.Slice(status.Devices, func(i, j int) bool {
+		return status.Devices[i].ID < status.Devices[j].ID
+	})
+
+	// Show the newest events first.
+	status.Events = source.RecentEvents()
+	slices.Reverse(status.Events)
+
+	return status
+}
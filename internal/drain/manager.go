@@ -0,0 +1,379 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package drain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// DefaultInterval is the interval at which draining devices are checked for running pods.
+const DefaultInterval = 5 * time.Second
+
+// State is the state of a drain.
+type State string
+
+// Constants for the supported drain states.
+const (
+	StateDraining = State("draining")
+	StateDrained  = State("drained")
+)
+
+var (
+	// ErrNotFound is returned if a drain or the device to drain does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned if the device is already being drained.
+	ErrConflict = errors.New("already draining")
+)
+
+// Request is a request to drain all replicas of a device of a resource.
+type Request struct {
+	// Resource is the name of the resource, e.g. nvidia.com/gpu.
+	Resource spec.ResourceName `json:"resource"`
+	// Device is the ID of the device whose replicas are drained. For shared
+	// resources this is the ID of the underlying GPU without the replica suffix.
+	Device string `json:"device"`
+	// CallbackURL is an optional URL that the drain is POSTed to once no pods
+	// are using the replicas of the device anymore.
+	CallbackURL string `json:"callbackURL,omitempty"`
+}
+
+// Drain is the status of a drain request.
+type Drain struct {
+	ID string `json:"id"`
+	Request
+	// DeviceIDs are the IDs of the replicas that are advertised as unavailable.
+	DeviceIDs []string   `json:"deviceIDs"`
+	State     State      `json:"state"`
+	CreatedAt time.Time  `json:"createdAt"`
+	DrainedAt *time.Time `json:"drainedAt,omitempty"`
+}
+
+// Lister lists the devices of a resource that are allocated to pods.
+type Lister interface {
+	AllocatedDevices(ctx context.Context, resource string) (map[string]bool, error)
+}
+
+// Source is a source of devices that can be drained.
+type Source interface {
+	Resource() spec.ResourceName
+	Devices() rm.Devices
+}
+
+// Manager tracks drain requests and reports the devices that must not be
+// advertised to the kubelet while they drain.
+type Manager struct {
+	lister   Lister
+	interval time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	sync.Mutex
+	sources     map[spec.ResourceName]Source
+	drains      map[string]*Drain
+	subscribers map[spec.ResourceName]map[chan struct{}]bool
+}
+
+// NewManager creates a drain manager that uses the specified lister to wait
+// for pods to release drained devices.
+func NewManager(lister Lister, interval time.Duration) *Manager {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Manager{
+		lister:      lister,
+		interval:    interval,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		sources:     make(map[spec.ResourceName]Source),
+		drains:      make(map[string]*Drain),
+		subscribers: make(map[spec.ResourceName]map[chan struct{}]bool),
+	}
+}
+
+// Update sets the sources of devices that can be drained.
+// This is called every time the plugins are (re)started.
+func (m *Manager) Update(sources []Source) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.sources = make(map[spec.ResourceName]Source)
+	for _, s := range sources {
+		m.sources[s.Resource()] = s
+	}
+}
+
+// Drain starts draining the replicas of the requested device.
+func (m *Manager) Drain(req Request) (*Drain, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	source, exists := m.sources[req.Resource]
+	if !exists {
+		return nil, fmt.Errorf("resource %q: %w", req.Resource, ErrNotFound)
+	}
+	var ids []string
+	for _, id := range source.Devices().GetIDs() {
+		if rm.AnnotatedID(id).GetID() == req.Device {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("device %q of resource %q: %w", req.Device, req.Resource, ErrNotFound)
+	}
+	for _, d := range m.drains {
+		if d.Resource == req.Resource && d.Device == req.Device {
+			return nil, fmt.Errorf("device %q of resource %q: %w", req.Device, req.Resource, ErrConflict)
+		}
+	}
+	sort.Strings(ids)
+
+	d := &Drain{
+		ID:        uuid.New().String(),
+		Request:   req,
+		DeviceIDs: ids,
+		State:     StateDraining,
+		CreatedAt: m.now(),
+	}
+	m.drains[d.ID] = d
+	m.notify(req.Resource)
+	klog.Infof("Draining device %q of resource %q (%d replicas)", req.Device, req.Resource, len(ids))
+
+	drain := *d
+	return &drain, nil
+}
+
+// Cancel removes a drain, making its devices available again.
+func (m *Manager) Cancel(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	d, exists := m.drains[id]
+	if !exists {
+		return fmt.Errorf("drain %q: %w", id, ErrNotFound)
+	}
+	delete(m.drains, id)
+	m.notify(d.Resource)
+	klog.Infof("Cancelled drain of device %q of resource %q", d.Device, d.Resource)
+	return nil
+}
+
+// Get returns the drain with the specified ID.
+func (m *Manager) Get(id string) (*Drain, error) {
+	m.Lock()
+	defer m.Unlock()
+	d, exists := m.drains[id]
+	if !exists {
+		return nil, fmt.Errorf("drain %q: %w", id, ErrNotFound)
+	}
+	drain := *d
+	return &drain, nil
+}
+
+// List returns all drains ordered by creation time.
+func (m *Manager) List() []Drain {
+	m.Lock()
+	defer m.Unlock()
+	drains := []Drain{}
+	for _, d := range m.drains {
+		drains = append(drains, *d)
+	}
+	sort.Slice(drains, func(i, j int) bool {
+		if drains[i].CreatedAt.Equal(drains[j].CreatedAt) {
+			return drains[i].ID < drains[j].ID
+		}
+		return drains[i].CreatedAt.Before(drains[j].CreatedAt)
+	})
+	return drains
+}
+
+// Draining returns the set of device IDs of the specified resource that are
+// being drained and must be advertised as unavailable.
+func (m *Manager) Draining(resource spec.ResourceName) map[string]bool {
+	if m == nil {
+		return nil
+	}
+	m.Lock()
+	defer m.Unlock()
+	draining := make(map[string]bool)
+	for _, d := range m.drains {
+		if d.Resource != resource {
+			continue
+		}
+		for _, id := range d.DeviceIDs {
+			draining[id] = true
+		}
+	}
+	return draining
+}
+
+// Subscribe returns a channel that receives a notification whenever the set
+// of drained devices of the specified resource changes. The returned function
+// must be called to release the subscription.
+func (m *Manager) Subscribe(resource spec.ResourceName) (<-chan struct{}, func()) {
+	if m == nil {
+		return nil, func() {}
+	}
+	m.Lock()
+	defer m.Unlock()
+	ch := make(chan struct{}, 1)
+	if m.subscribers[resource] == nil {
+		m.subscribers[resource] = make(map[chan struct{}]bool)
+	}
+	m.subscribers[resource][ch] = true
+	unsubscribe := func() {
+		m.Lock()
+		defer m.Unlock()
+		delete(m.subscribers[resource], ch)
+	}
+	return ch, unsubscribe
+}
+
+// notify signals the subscribers of a resource without blocking.
+// The caller must hold the lock.
+func (m *Manager) notify(resource spec.ResourceName) {
+	for ch := range m.subscribers[resource] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run periodically checks whether draining devices are still in use until
+// the context is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, d := range m.check(ctx) {
+				go m.callback(ctx, d)
+			}
+		}
+	}
+}
+
+// check marks draining devices that are no longer allocated to any pod as
+// drained and returns the drains that completed.
+func (m *Manager) check(ctx context.Context) []Drain {
+	allocated := make(map[spec.ResourceName]map[string]bool)
+	for _, d := range m.List() {
+		if d.State != StateDraining || allocated[d.Resource] != nil {
+			continue
+		}
+		devices, err := m.lister.AllocatedDevices(ctx, string(d.Resource))
+		if err != nil {
+			klog.Warningf("Failed to list devices allocated for %q: %v", d.Resource, err)
+			continue
+		}
+		allocated[d.Resource] = devices
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	var completed []Drain
+	for _, d := range m.drains {
+		devices, checked := allocated[d.Resource]
+		if d.State != StateDraining || !checked {
+			continue
+		}
+		if inUse(d.DeviceIDs, devices) {
+			continue
+		}
+		now := m.now()
+		d.State = StateDrained
+		d.DrainedAt = &now
+		klog.Infof("Device %q of resource %q is drained", d.Device, d.Resource)
+		completed = append(completed, *d)
+	}
+	return completed
+}
+
+func inUse(ids []string, allocated map[string]bool) bool {
+	for _, id := range ids {
+		if allocated[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// callback POSTs a completed drain to its callback URL, retrying with a
+// backoff on failure.
+func (m *Manager) callback(ctx context.Context, d Drain) {
+	if d.CallbackURL == "" {
+		return
+	}
+	body, err := json.Marshal(d)
+	if err != nil {
+		klog.Errorf("Failed to marshal drain %q: %v", d.ID, err)
+		return
+	}
+	backoff := time.Second
+	for attempt := 1; attempt <= 5; attempt++ {
+		err = m.post(ctx, d.CallbackURL, body)
+		if err == nil {
+			return
+		}
+		klog.Warningf("Failed to call back %v for drain %q (attempt %d): %v", d.CallbackURL, d.ID, attempt, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	klog.Errorf("Giving up calling back %v for drain %q", d.CallbackURL, d.ID)
+}
+
+func (m *Manager) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testSource struct {
+	resource spec.ResourceName
+	devices  rm.Devices
+}
+
+func (s testSource) Resource() spec.ResourceName { return s.resource }
+func (s testSource) Devices() rm.Devices         { return s.devices }
+
+type testLister map[string]bool
+
+func (l testLister) AllocatedDevices(context.Context, string) (map[string]bool, error) {
+	return l, nil
+}
+
+func newTestManager(lister Lister) *Manager {
+	devices := make(rm.Devices)
+	for _, id := range []string{"GPU-0::0", "GPU-0::1", "GPU-1::0", "GPU-1::1"} {
+		devices[id] = &rm.Device{Device: pluginapi.Device{ID: id, Health: pluginapi.Healthy}}
+	}
+	m := NewManager(lister, time.Second)
+	m.Update([]Source{testSource{"nvidia.com/gpu", devices}})
+	return m
+}
+
+func TestDrain(t *testing.T) {
+	testCases := []struct {
+		description   string
+		request       Request
+		expectedError error
+		expectedIDs   []string
+	}{
+		{
+			description: "all replicas of the device are drained",
+			request:     Request{Resource: "nvidia.com/gpu", Device: "GPU-0"},
+			expectedIDs: []string{"GPU-0::0", "GPU-0::1"},
+		},
+		{
+			description:   "unknown resource",
+			request:       Request{Resource: "nvidia.com/mig-1g.5gb", Device: "GPU-0"},
+			expectedError: ErrNotFound,
+		},
+		{
+			description:   "unknown device",
+			request:       Request{Resource: "nvidia.com/gpu", Device: "GPU-2"},
+			expectedError: ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			m := newTestManager(testLister{})
+			d, err := m.Drain(tc.request)
+			require.ErrorIs(t, err, tc.expectedError)
+			if tc.expectedError != nil {
+				return
+			}
+			require.Equal(t, tc.expectedIDs, d.DeviceIDs)
+			require.Equal(t, StateDraining, d.State)
+
+			expected := make(map[string]bool)
+			for _, id := range tc.expectedIDs {
+				expected[id] = true
+			}
+			require.Equal(t, expected, m.Draining(tc.request.Resource))
+		})
+	}
+}
+
+func TestDrainConflictAndCancel(t *testing.T) {
+	m := newTestManager(testLister{})
+	updates, unsubscribe := m.Subscribe("nvidia.com/gpu")
+	defer unsubscribe()
+
+	d, err := m.Drain(Request{Resource: "nvidia.com/gpu", Device: "GPU-1"})
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	<-updates
+
+	_, err = m.Drain(Request{Resource: "nvidia.com/gpu", Device: "GPU-1"})
+	require.ErrorIs(t, err, ErrConflict)
+
+	require.NoError(t, m.Cancel(d.ID))
+	require.Len(t, updates, 1)
+	require.Empty(t, m.Draining("nvidia.com/gpu"))
+	require.ErrorIs(t, m.Cancel(d.ID), ErrNotFound)
+}
+
+func TestCheck(t *testing.T) {
+	lister := testLister{"GPU-0::1": true}
+	m := newTestManager(lister)
+
+	d, err := m.Drain(Request{Resource: "nvidia.com/gpu", Device: "GPU-0"})
+	require.NoError(t, err)
+
+	require.Empty(t, m.check(context.Background()))
+	d, err = m.Get(d.ID)
+	require.NoError(t, err)
+	require.Equal(t, StateDraining, d.State)
+
+	delete(lister, "GPU-0::1")
+	completed := m.check(context.Background())
+	require.Len(t, completed, 1)
+	require.Equal(t, StateDrained, completed[0].State)
+	require.NotNil(t, completed[0].DrainedAt)
+
+	// Drained devices remain unavailable until the drain is cancelled.
+	require.Len(t, m.Draining("nvidia.com/gpu"), 2)
+	require.Empty(t, m.check(context.Background()))
+}
+
+func TestCallback(t *testing.T) {
+	received := make(chan Drain, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var d Drain
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&d))
+		received <- d
+	}))
+	defer server.Close()
+
+	m := newTestManager(testLister{})
+	d := Drain{ID: "1", Request: Request{Resource: "nvidia.com/gpu", Device: "GPU-0", CallbackURL: server.URL}, State: StateDrained}
+	m.callback(context.Background(), d)
+	require.Equal(t, d.ID, (<-received).ID)
+}
+
+func TestHandler(t *testing.T) {
+	m := newTestManager(testLister{})
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/drains", "application/json", strings.NewReader(`{"resource": "nvidia.com/gpu", "device": "GPU-0"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var d Drain
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&d))
+
+	resp, err = http.Post(server.URL+"/v1/drains", "application/json", strings.NewReader(`{"resource": "nvidia.com/gpu", "device": "GPU-0"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, err = http.Get(fmt.Sprintf("%s/v1/drains/%s", server.URL, d.ID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/v1/drains/%s", server.URL, d.ID), nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = http.Get(fmt.Sprintf("%s/v1/drains/%s", server.URL, d.ID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,154 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package featuregates
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Feature is the name of a feature gate.
+type Feature string
+
+// Stage is the maturity of a feature.
+type Stage string
+
+// The stages of a feature.
+const (
+	Alpha Stage = "ALPHA"
+	Beta  Stage = "BETA"
+	GA    Stage = "GA"
+)
+
+// The feature gates of the device plugin.
+const (
+	// ClockBoost allows the boostClocks allocation option to be enabled for
+	// resources.
+	ClockBoost Feature = "ClockBoost"
+)
+
+// Spec defines the default state and the stage of a feature.
+type Spec struct {
+	Default bool
+	Stage   Stage
+	// LockToDefault prevents the feature from being set to a value other
+	// than its default. This is used for features that have graduated to GA.
+	LockToDefault bool
+}
+
+// Registry holds the specs of the known features.
+type Registry struct {
+	specs map[Feature]Spec
+}
+
+// Default is the registry of the features of the device plugin.
+var Default = NewRegistry(map[Feature]Spec{
+	ClockBoost: {Default: false, Stage: Alpha},
+})
+
+// NewRegistry creates a registry for the specified features.
+func NewRegistry(specs map[Feature]Spec) *Registry {
+	return &Registry{specs: specs}
+}
+
+// Features returns the known features sorted by name.
+func (r *Registry) Features() []Feature {
+	var features []Feature
+	for f := range r.specs {
+		features = append(features, f)
+	}
+	sort.Slice(features, func(i, j int) bool {
+		return features[i] < features[j]
+	})
+	return features
+}
+
+// Usage returns a description of the known features for use in the usage
+// string of a flag.
+func (r *Registry) Usage() string {
+	var lines []string
+	for _, f := range r.Features() {
+		spec := r.specs[f]
+		lines = append(lines, fmt.Sprintf("%s=true|false (%s - default=%t)", f, spec.Stage, spec.Default))
+	}
+	return strings.Join(lines, "\n\t\t")
+}
+
+// Gates returns the feature gates with the specified overrides applied to
+// the defaults. An error is returned if an override refers to an unknown
+// feature or changes a feature that is locked to its default.
+func (r *Registry) Gates(overrides map[string]bool) (*Gates, error) {
+	enabled := make(map[Feature]bool)
+	for f, spec := range r.specs {
+		enabled[f] = spec.Default
+	}
+	for name, value := range overrides {
+		f := Feature(name)
+		spec, exists := r.specs[f]
+		if !exists {
+			return nil, fmt.Errorf("unknown feature gate %q", name)
+		}
+		if spec.LockToDefault && value != spec.Default {
+			return nil, fmt.Errorf("feature gate %q is locked to %t", name, spec.Default)
+		}
+		enabled[f] = value
+	}
+	return &Gates{registry: r, enabled: enabled}, nil
+}
+
+// Gates holds the state of the known features.
+type Gates struct {
+	registry *Registry
+	enabled  map[Feature]bool
+}
+
+// Enabled returns whether the specified feature is enabled. If the gates are
+// nil, the default of the feature in the Default registry is returned.
+func (g *Gates) Enabled(f Feature) bool {
+	if g == nil {
+		return Default.specs[f].Default
+	}
+	return g.enabled[f]
+}
+
+// EnabledFeatures returns the enabled features sorted by name.
+func (g *Gates) EnabledFeatures() []Feature {
+	if g == nil {
+		return nil
+	}
+	var features []Feature
+	for _, f := range g.registry.Features() {
+		if g.enabled[f] {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// String returns the state of the known features as a comma-separated list
+// of <name>=<bool> pairs.
+func (g *Gates) String() string {
+	if g == nil {
+		return ""
+	}
+	var pairs []string
+	for _, f := range g.registry.Features() {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", f, g.enabled[f]))
+	}
+	return strings.Join(pairs, ",")
+}
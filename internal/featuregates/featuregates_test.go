@@ -0,0 +1,126 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package featuregates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/require"
+)
+
+var testRegistry = NewRegistry(map[Feature]Spec{
+	"AlphaFeature": {Default: false, Stage: Alpha},
+	"BetaFeature":  {Default: true, Stage: Beta},
+	"GAFeature":    {Default: true, Stage: GA, LockToDefault: true},
+})
+
+func TestGates(t *testing.T) {
+	testCases := []struct {
+		description   string
+		overrides     map[string]bool
+		expected      map[Feature]bool
+		expectedError bool
+	}{
+		{
+			description: "defaults",
+			expected: map[Feature]bool{
+				"AlphaFeature": false,
+				"BetaFeature":  true,
+				"GAFeature":    true,
+			},
+		},
+		{
+			description: "overrides are applied",
+			overrides:   map[string]bool{"AlphaFeature": true, "BetaFeature": false},
+			expected: map[Feature]bool{
+				"AlphaFeature": true,
+				"BetaFeature":  false,
+				"GAFeature":    true,
+			},
+		},
+		{
+			description: "locked feature can be set to its default",
+			overrides:   map[string]bool{"GAFeature": true},
+			expected: map[Feature]bool{
+				"AlphaFeature": false,
+				"BetaFeature":  true,
+				"GAFeature":    true,
+			},
+		},
+		{
+			description:   "locked feature cannot be changed",
+			overrides:     map[string]bool{"GAFeature": false},
+			expectedError: true,
+		},
+		{
+			description:   "unknown feature",
+			overrides:     map[string]bool{"UnknownFeature": true},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			gates, err := testRegistry.Gates(tc.overrides)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			for f, enabled := range tc.expected {
+				require.Equal(t, enabled, gates.Enabled(f), f)
+			}
+			require.False(t, gates.Enabled("UnknownFeature"))
+		})
+	}
+}
+
+func TestEnabledFeatures(t *testing.T) {
+	gates, err := testRegistry.Gates(map[string]bool{"AlphaFeature": true, "BetaFeature": false})
+	require.NoError(t, err)
+	require.Equal(t, []Feature{"AlphaFeature", "GAFeature"}, gates.EnabledFeatures())
+	require.Nil(t, (*Gates)(nil).EnabledFeatures())
+}
+
+func TestNilGatesUseDefaults(t *testing.T) {
+	var gates *Gates
+	for _, f := range Default.Features() {
+		require.Equal(t, Default.specs[f].Default, gates.Enabled(f), f)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("test")
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(c))
+
+	gates, err := testRegistry.Gates(map[string]bool{"AlphaFeature": true})
+	require.NoError(t, err)
+	c.Record(gates)
+	require.Equal(t, "AlphaFeature=true,BetaFeature=true,GAFeature=true", gates.String())
+
+	r := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(r, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := r.Body.String()
+	require.Contains(t, body, `test_feature_enabled{name="AlphaFeature",stage="ALPHA"} 1`)
+	require.Contains(t, body, `test_feature_enabled{name="BetaFeature",stage="BETA"} 1`)
+	require.Contains(t, body, `test_feature_enabled{name="GAFeature",stage="GA"} 1`)
+}
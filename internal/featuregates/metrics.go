@@ -0,0 +1,73 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package featuregates
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector exposes the state of the feature gates as Prometheus metrics.
+// The gates are updated each time the config is (re)loaded.
+type Collector struct {
+	sync.Mutex
+	gates   *Gates
+	enabled *prometheus.Desc
+}
+
+// NewCollector creates a collector for metrics with the specified namespace.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		enabled: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "feature_enabled"),
+			"Whether a feature gate is enabled (1) or disabled (0).",
+			[]string{"name", "stage"}, nil,
+		),
+	}
+}
+
+// Record sets the feature gates exposed by the collector.
+func (c *Collector) Record(gates *Gates) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.gates = gates
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.enabled
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+	if c.gates == nil {
+		return
+	}
+	for _, f := range c.gates.registry.Features() {
+		value := 0.0
+		if c.gates.enabled[f] {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.enabled, prometheus.GaugeValue, value, string(f), string(c.gates.registry.specs[f].Stage))
+	}
+}
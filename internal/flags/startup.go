@@ -0,0 +1,81 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package flags
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+// StartupConfig holds the options that delay the startup of a component.
+// When the pods of a DaemonSet are restarted at once (e.g. in an upgrade),
+// a random delay prevents them from initializing NVML and sending requests to
+// the API server at the same time.
+type StartupConfig struct {
+	Delay  time.Duration
+	Jitter time.Duration
+}
+
+func (s *StartupConfig) Flags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.DurationFlag{
+			Name:        "startup-delay",
+			Usage:       "The fixed delay before starting up.",
+			Destination: &s.Delay,
+			EnvVars:     []string{"STARTUP_DELAY"},
+		},
+		&cli.DurationFlag{
+			Name:        "startup-jitter",
+			Usage:       "The maximum random delay that is added to the startup delay.",
+			Destination: &s.Jitter,
+			EnvVars:     []string{"STARTUP_JITTER"},
+		},
+	}
+	return flags
+}
+
+// Wait waits for the startup delay plus a random duration of up to the jitter.
+// An error is returned if the context is cancelled while waiting.
+func (s *StartupConfig) Wait(ctx context.Context) error {
+	delay := s.duration(rand.Int63n)
+	if delay <= 0 {
+		return nil
+	}
+	klog.Infof("Delaying startup by %v", delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// duration returns the startup delay with a random jitter added. The random
+// jitter is generated by int63n, which returns a value in [0, n).
+func (s *StartupConfig) duration(int63n func(int64) int64) time.Duration {
+	delay := s.Delay
+	if s.Jitter > 0 {
+		delay += time.Duration(int63n(int64(s.Jitter)))
+	}
+	return delay
+}
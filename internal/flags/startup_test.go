@@ -0,0 +1,68 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package flags
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartupDuration(t *testing.T) {
+	testCases := []struct {
+		description string
+		config      StartupConfig
+		expected    time.Duration
+	}{
+		{
+			description: "no delay",
+			expected:    0,
+		},
+		{
+			description: "delay only",
+			config:      StartupConfig{Delay: time.Minute},
+			expected:    time.Minute,
+		},
+		{
+			description: "jitter only",
+			config:      StartupConfig{Jitter: time.Minute},
+			expected:    30 * time.Second,
+		},
+		{
+			description: "delay and jitter",
+			config:      StartupConfig{Delay: time.Minute, Jitter: time.Minute},
+			expected:    90 * time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			half := func(n int64) int64 { return n / 2 }
+			require.Equal(t, tc.expected, tc.config.duration(half))
+		})
+	}
+}
+
+func TestStartupWait(t *testing.T) {
+	require.NoError(t, (&StartupConfig{}).Wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, (&StartupConfig{Delay: time.Hour}).Wait(ctx), context.Canceled)
+}
@@ -25,6 +25,17 @@ var version = "unknown"
 // and will be populated by the Makefile
 var gitCommit = ""
 
+// GetVersion returns the version that the binary was built for.
+func GetVersion() string {
+	return version
+}
+
+// GetGitCommit returns the hash of the commit that the binary was built from,
+// or an empty string if it is not known.
+func GetGitCommit() string {
+	return gitCommit
+}
+
 // GetVersionParts returns the different version components
 func GetVersionParts() []string {
 	v := []string{version}
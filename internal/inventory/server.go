@@ -0,0 +1,222 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+)
+
+// Source is a plugin whose device inventory is exposed by the server.
+type Source interface {
+	Resource() spec.ResourceName
+	Inventory() *plugin.ListAndWatchSnapshot
+	SubscribeInventory() (<-chan struct{}, func())
+	GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error)
+}
+
+// Server exposes the device inventory of the running plugins over HTTP for
+// consumers, such as virtual-kubelet providers, that cannot dial the unix
+// sockets of the device plugin API:
+//
+//	GET  /v1/resources                      the device list of every resource
+//	GET  /v1/devices?resource=<name>        the device list of a resource
+//	GET  /v1/devices?resource=<name>&watch  a stream of device lists
+//	POST /v1/preferredallocation?resource=<name>
+//
+// The device lists are built from the resource managers of the plugins in the
+// same way as the ones sent to the kubelet in ListAndWatch responses, so they
+// reflect the same health checks, but do not depend on a kubelet stream. A
+// watch writes one JSON document per line, starting with the current list,
+// and ends when the plugins are restarted. The preferred allocation endpoint
+// accepts and returns the JSON encoding of the corresponding device plugin API
+// messages. Allocations are deliberately not served, as the server is not
+// authenticated and an allocation changes the state of the node.
+type Server struct {
+	address string
+	mux     *http.ServeMux
+
+	sync.Mutex
+	sources map[spec.ResourceName]Source
+	// updated is closed when the sources are replaced to end open watches.
+	updated chan struct{}
+}
+
+// NewServer creates an inventory server that listens on the specified
+// address. A nil server is returned if the address is empty.
+func NewServer(address string) *Server {
+	if address == "" {
+		return nil
+	}
+	s := &Server{
+		address: address,
+		mux:     http.NewServeMux(),
+		sources: make(map[spec.ResourceName]Source),
+		updated: make(chan struct{}),
+	}
+	s.mux.HandleFunc("GET /v1/resources", s.handleResources)
+	s.mux.HandleFunc("GET /v1/devices", s.handleDevices)
+	s.mux.HandleFunc("POST /v1/preferredallocation", s.handlePreferredAllocation)
+	return s
+}
+
+// Update sets the plugins whose inventory is exposed by the server. This is
+// called every time the plugins are (re)started.
+func (s *Server) Update(sources []Source) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.sources = make(map[spec.ResourceName]Source)
+	for _, source := range sources {
+		s.sources[source.Resource()] = source
+	}
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// ListenAndServe serves the inventory until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", s.address, err)
+	}
+	server := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	klog.Infof("Serving device inventory on %v", s.address)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handleResources writes the current device list of each resource.
+func (s *Server) handleResources(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	snapshots := make(map[spec.ResourceName]*plugin.ListAndWatchSnapshot)
+	for resource, source := range s.sources {
+		snapshots[resource] = source.Inventory()
+	}
+	s.Unlock()
+
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+// handleDevices writes the device list of a single resource, or streams the
+// device lists of the resource if the watch query parameter is set.
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	source, updated := s.get(r.URL.Query().Get("resource"))
+	if source == nil {
+		http.Error(w, "unknown resource", http.StatusNotFound)
+		return
+	}
+	if !r.URL.Query().Has("watch") {
+		writeJSON(w, http.StatusOK, source.Inventory())
+		return
+	}
+
+	notifications, unsubscribe := source.SubscribeInventory()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	send := func() bool {
+		if err := encoder.Encode(source.Inventory()); err != nil {
+			return false
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	if !send() {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updated:
+			return
+		case <-notifications:
+			if !send() {
+				return
+			}
+		}
+	}
+}
+
+// handlePreferredAllocation forwards a GetPreferredAllocation request to the
+// plugin of a resource.
+func (s *Server) handlePreferredAllocation(w http.ResponseWriter, r *http.Request) {
+	source, _ := s.get(r.URL.Query().Get("resource"))
+	if source == nil {
+		http.Error(w, "unknown resource", http.StatusNotFound)
+		return
+	}
+	var request pluginapi.PreferredAllocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	response, err := source.GetPreferredAllocation(r.Context(), &request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// get returns the source of the specified resource, or nil if there is none,
+// together with the channel that is closed when the sources are replaced.
+func (s *Server) get(resource string) (Source, <-chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+	return s.sources[spec.ResourceName(resource)], s.updated
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Warningf("Failed to write inventory response: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package inventory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+)
+
+type testSource struct {
+	resource spec.ResourceName
+
+	sync.Mutex
+	snapshot      *plugin.ListAndWatchSnapshot
+	notifications chan struct{}
+}
+
+func newTestSource(resource spec.ResourceName, snapshot *plugin.ListAndWatchSnapshot) *testSource {
+	return &testSource{
+		resource:      resource,
+		snapshot:      snapshot,
+		notifications: make(chan struct{}, 1),
+	}
+}
+
+func (s *testSource) Resource() spec.ResourceName { return s.resource }
+
+func (s *testSource) Inventory() *plugin.ListAndWatchSnapshot {
+	s.Lock()
+	defer s.Unlock()
+	return s.snapshot
+}
+
+func (s *testSource) SubscribeInventory() (<-chan struct{}, func()) {
+	return s.notifications, func() {}
+}
+
+func (s *testSource) GetPreferredAllocation(_ context.Context, r *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	response := &pluginapi.PreferredAllocationResponse{}
+	for _, req := range r.ContainerRequests {
+		response.ContainerResponses = append(response.ContainerResponses, &pluginapi.ContainerPreferredAllocationResponse{
+			DeviceIDs: req.AvailableDeviceIDs[:req.AllocationSize],
+		})
+	}
+	return response, nil
+}
+
+// send records a new snapshot and notifies the subscribers.
+func (s *testSource) send(snapshot *plugin.ListAndWatchSnapshot) {
+	s.Lock()
+	s.snapshot = snapshot
+	s.Unlock()
+	s.notifications <- struct{}{}
+}
+
+func newSnapshot(health string) *plugin.ListAndWatchSnapshot {
+	return &plugin.ListAndWatchSnapshot{
+		Timestamp: time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC),
+		Devices: []plugin.SnapshotDevice{
+			{ID: "GPU-0", Health: health, NUMANodes: []int64{0}},
+		},
+	}
+}
+
+func TestHandleResources(t *testing.T) {
+	snapshot := newSnapshot(pluginapi.Healthy)
+
+	s := NewServer("localhost:0")
+	s.Update([]Source{
+		newTestSource("nvidia.com/gpu", snapshot),
+		newTestSource("nvidia.com/mig-1g.5gb", &plugin.ListAndWatchSnapshot{}),
+	})
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/resources", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response map[spec.ResourceName]*plugin.ListAndWatchSnapshot
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, map[spec.ResourceName]*plugin.ListAndWatchSnapshot{
+		"nvidia.com/gpu":        snapshot,
+		"nvidia.com/mig-1g.5gb": {},
+	}, response)
+}
+
+func TestHandleDevices(t *testing.T) {
+	snapshot := newSnapshot(pluginapi.Healthy)
+
+	s := NewServer("localhost:0")
+	s.Update([]Source{newTestSource("nvidia.com/gpu", snapshot)})
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/devices?resource=nvidia.com/gpu", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response *plugin.ListAndWatchSnapshot
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, snapshot, response)
+
+	recorder = httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/devices?resource=nvidia.com/mig-1g.5gb", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestWatchDevices(t *testing.T) {
+	source := newTestSource("nvidia.com/gpu", newSnapshot(pluginapi.Healthy))
+
+	s := NewServer("localhost:0")
+	s.Update([]Source{source})
+
+	server := httptest.NewServer(s.mux)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/v1/devices?resource=nvidia.com/gpu&watch")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	lines := bufio.NewScanner(response.Body)
+	next := func() *plugin.ListAndWatchSnapshot {
+		require.True(t, lines.Scan())
+		var snapshot *plugin.ListAndWatchSnapshot
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &snapshot))
+		return snapshot
+	}
+
+	require.Equal(t, newSnapshot(pluginapi.Healthy), next())
+
+	source.send(newSnapshot(pluginapi.Unhealthy))
+	require.Equal(t, newSnapshot(pluginapi.Unhealthy), next())
+
+	// The watch ends when the plugins are restarted.
+	s.Update(nil)
+	require.False(t, lines.Scan())
+}
+
+func TestAllocateNotServed(t *testing.T) {
+	s := NewServer("localhost:0")
+	s.Update([]Source{newTestSource("nvidia.com/gpu", nil)})
+
+	body := `{"container_requests":[{"devices_ids":["GPU-0"]}]}`
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/allocate?resource=nvidia.com/gpu", strings.NewReader(body)))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHandlePreferredAllocation(t *testing.T) {
+	s := NewServer("localhost:0")
+	s.Update([]Source{newTestSource("nvidia.com/gpu", nil)})
+
+	body := `{"container_requests":[{"available_deviceIDs":["GPU-0","GPU-1","GPU-2"],"allocation_size":2}]}`
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/preferredallocation?resource=nvidia.com/gpu", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response pluginapi.PreferredAllocationResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Len(t, response.ContainerResponses, 1)
+	require.Equal(t, []string{"GPU-0", "GPU-1"}, response.ContainerResponses[0].DeviceIDs)
+}
@@ -0,0 +1,74 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+)
+
+// ECCRebootRequiredLabel is the label that indicates whether the ECC mode of
+// any GPU on the node only takes effect after a reboot.
+const ECCRebootRequiredLabel = "nvidia.com/gpu.ecc.reboot-required"
+
+// newECCLabeler creates a labeler that generates the current and pending ECC
+// mode labels of each GPU, keyed by the index of the GPU, and whether a
+// reboot is required for a change of the ECC mode to take effect.
+func newECCLabeler(manager resource.Manager) (Labeler, error) {
+	devices, err := manager.GetDevices()
+	if err != nil {
+		return nil, fmt.Errorf("error getting devices: %v", err)
+	}
+	return getECCLabels(devices)
+}
+
+// getECCLabels returns the ECC mode labels of the specified devices. No labels
+// are generated if no device supports ECC.
+func getECCLabels(devices []resource.Device) (Labeler, error) {
+	labels := make(Labels)
+	rebootRequired := false
+	for i, d := range devices {
+		mode, err := d.GetEccMode()
+		if errors.Is(err, resource.ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting ECC mode: %w", err)
+		}
+		prefix := "nvidia.com/gpu." + strconv.Itoa(i) + ".ecc"
+		labels[prefix+".current"] = eccModeLabel(mode.Current)
+		labels[prefix+".pending"] = eccModeLabel(mode.Pending)
+		if mode.Current != mode.Pending {
+			rebootRequired = true
+		}
+	}
+	if len(labels) == 0 {
+		return empty{}, nil
+	}
+	labels[ECCRebootRequiredLabel] = strconv.FormatBool(rebootRequired)
+	return labels, nil
+}
+
+func eccModeLabel(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
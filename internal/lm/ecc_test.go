@@ -0,0 +1,82 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
+)
+
+// newECCDevice creates a device with the specified current and pending ECC mode.
+func newECCDevice(current bool, pending bool) resource.Device {
+	d := rt.NewDeviceMock(false)
+	d.GetEccModeFunc = func() (*resource.EccMode, error) {
+		return &resource.EccMode{Current: current, Pending: pending}, nil
+	}
+	return d
+}
+
+func TestECCLabeler(t *testing.T) {
+	testCases := []struct {
+		description    string
+		devices        []resource.Device
+		expectedLabels Labels
+	}{
+		{
+			description: "no labels without ECC support",
+			devices:     []resource.Device{rt.NewDeviceMock(false)},
+		},
+		{
+			description: "modes of all GPUs are labeled",
+			devices:     []resource.Device{newECCDevice(true, true), newECCDevice(false, false)},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.0.ecc.current":       "enabled",
+				"nvidia.com/gpu.0.ecc.pending":       "enabled",
+				"nvidia.com/gpu.1.ecc.current":       "disabled",
+				"nvidia.com/gpu.1.ecc.pending":       "disabled",
+				"nvidia.com/gpu.ecc.reboot-required": "false",
+			},
+		},
+		{
+			description: "pending mode change requires a reboot",
+			devices:     []resource.Device{rt.NewDeviceMock(false), newECCDevice(true, false)},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.1.ecc.current":       "enabled",
+				"nvidia.com/gpu.1.ecc.pending":       "disabled",
+				"nvidia.com/gpu.ecc.reboot-required": "true",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			l, err := newECCLabeler(rt.NewManagerMockWithDevices(tc.devices...))
+			require.NoError(t, err)
+			labels, err := l.Labels()
+			require.NoError(t, err)
+			if tc.expectedLabels == nil {
+				require.Empty(t, labels)
+				return
+			}
+			require.EqualValues(t, tc.expectedLabels, labels)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+)
+
+const (
+	linkHealthy  = "healthy"
+	linkDegraded = "degraded"
+)
+
+// sampleLinkHealth samples the link counters of the specified devices and
+// returns the resulting link health labels. The error and throughput counters
+// of the PCIe and NVLink interconnects of all GPUs are sampled twice and an
+// interconnect is labeled as degraded if the error rate between the samples
+// exceeds the configured maximum on any GPU. The sleep function is called
+// between the two samples.
+func sampleLinkHealth(devices []resource.Device, links *spec.LinkHealth, sleep func(time.Duration)) (Labeler, error) {
+	first, err := getLinkCounters(devices)
+	if errors.Is(err, resource.ErrNotSupported) {
+		return empty{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	interval := links.GetSampleInterval()
+	sleep(interval)
+
+	second, err := getLinkCounters(devices)
+	if err != nil {
+		return nil, err
+	}
+
+	pcie := linkHealthy
+	var nvlink string
+	for i := range devices {
+		if rate(first[i].PCIeReplays, second[i].PCIeReplays, interval) > links.MaxPCIeReplayRate {
+			pcie = linkDegraded
+		}
+		if first[i].NVLinkErrors == nil || second[i].NVLinkErrors == nil {
+			continue
+		}
+		if nvlink == "" {
+			nvlink = linkHealthy
+		}
+		if nvlinkDegraded(first[i], second[i], interval, links) {
+			nvlink = linkDegraded
+		}
+	}
+
+	overall := pcie
+	if nvlink == linkDegraded {
+		overall = linkDegraded
+	}
+	labels := Labels{
+		"nvidia.com/gpu.link-health":      overall,
+		"nvidia.com/gpu.link-health.pcie": pcie,
+	}
+	if nvlink != "" {
+		labels["nvidia.com/gpu.link-health.nvlink"] = nvlink
+	}
+	return labels, nil
+}
+
+// getLinkCounters returns the link error counters of the specified devices.
+func getLinkCounters(devices []resource.Device) ([]*resource.LinkCounters, error) {
+	var counters []*resource.LinkCounters
+	for _, d := range devices {
+		c, err := d.GetLinkCounters()
+		if err != nil {
+			return nil, fmt.Errorf("error getting link counters: %w", err)
+		}
+		counters = append(counters, c)
+	}
+	return counters, nil
+}
+
+// nvlinkDegraded checks whether the NVLink error rate between the specified
+// samples exceeds the configured maximum. The errors per GiB of data
+// transferred are used if configured and supported by the device.
+func nvlinkDegraded(first, second *resource.LinkCounters, interval time.Duration, links *spec.LinkHealth) bool {
+	count := delta(*first.NVLinkErrors, *second.NVLinkErrors)
+	if links.MaxNVLinkErrorsPerGiB == 0 || first.NVLinkThroughputKiB == nil || second.NVLinkThroughputKiB == nil {
+		return rate(*first.NVLinkErrors, *second.NVLinkErrors, interval) > links.MaxNVLinkErrorRate
+	}
+	if count == 0 {
+		return false
+	}
+	gib := float64(delta(*first.NVLinkThroughputKiB, *second.NVLinkThroughputKiB)) / (1 << 20)
+	if gib == 0 {
+		return true
+	}
+	return float64(count)/gib > links.MaxNVLinkErrorsPerGiB
+}
+
+// delta returns the increase of a counter between two samples. A counter that
+// was reset between the samples is treated as unchanged.
+func delta(first uint64, second uint64) uint64 {
+	if second <= first {
+		return 0
+	}
+	return second - first
+}
+
+// rate returns the rate per second at which a counter increased between two
+// samples. A counter that was reset between the samples is treated as unchanged.
+func rate(first uint64, second uint64, interval time.Duration) float64 {
+	if interval <= 0 {
+		return 0
+	}
+	return float64(delta(first, second)) / interval.Seconds()
+}
@@ -0,0 +1,232 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
+)
+
+// newLinkDevice creates a device whose link error counters increase by the
+// specified number of errors between consecutive samples. A negative number
+// of NVLink errors indicates that the device has no NVLinks.
+func newLinkDevice(pcieReplays int, nvlinkErrors int) resource.Device {
+	var samples uint64
+	d := rt.NewDeviceMock(false)
+	d.GetLinkCountersFunc = func() (*resource.LinkCounters, error) {
+		samples++
+		counters := &resource.LinkCounters{
+			PCIeReplays: 100 + samples*uint64(pcieReplays),
+		}
+		if nvlinkErrors >= 0 {
+			errors := 100 + samples*uint64(nvlinkErrors)
+			counters.NVLinkErrors = &errors
+		}
+		return counters, nil
+	}
+	return d
+}
+
+func TestLinkHealthLabeler(t *testing.T) {
+	testCases := []struct {
+		description    string
+		devices        []resource.Device
+		links          *spec.LinkHealth
+		expectedLabels Labels
+	}{
+		{
+			description: "link health disabled",
+			devices:     []resource.Device{newLinkDevice(10, 10)},
+		},
+		{
+			description: "links without errors are healthy",
+			devices:     []resource.Device{newLinkDevice(0, 0), newLinkDevice(0, 0)},
+			links:       &spec.LinkHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.link-health":        "healthy",
+				"nvidia.com/gpu.link-health.pcie":   "healthy",
+				"nvidia.com/gpu.link-health.nvlink": "healthy",
+			},
+		},
+		{
+			description: "nvlink label is omitted without NVLinks",
+			devices:     []resource.Device{newLinkDevice(0, -1)},
+			links:       &spec.LinkHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.link-health":      "healthy",
+				"nvidia.com/gpu.link-health.pcie": "healthy",
+			},
+		},
+		{
+			description: "errors on any device degrade the link",
+			devices:     []resource.Device{newLinkDevice(0, 0), newLinkDevice(0, 1)},
+			links:       &spec.LinkHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.link-health":        "degraded",
+				"nvidia.com/gpu.link-health.pcie":   "healthy",
+				"nvidia.com/gpu.link-health.nvlink": "degraded",
+			},
+		},
+		{
+			description: "error rates below the maximum are healthy",
+			devices:     []resource.Device{newLinkDevice(5, 10)},
+			links: &spec.LinkHealth{
+				MaxPCIeReplayRate:  1,
+				MaxNVLinkErrorRate: 2,
+			},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.link-health":        "healthy",
+				"nvidia.com/gpu.link-health.pcie":   "healthy",
+				"nvidia.com/gpu.link-health.nvlink": "healthy",
+			},
+		},
+		{
+			description: "error rates above the maximum are degraded",
+			devices:     []resource.Device{newLinkDevice(10, 20)},
+			links: &spec.LinkHealth{
+				MaxPCIeReplayRate:  1,
+				MaxNVLinkErrorRate: 2,
+			},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.link-health":        "degraded",
+				"nvidia.com/gpu.link-health.pcie":   "degraded",
+				"nvidia.com/gpu.link-health.nvlink": "degraded",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.links == nil {
+				config := &spec.Config{Health: &spec.Health{}}
+				l := NewSamplingLabeler(rt.NewManagerMockWithDevices(tc.devices...), config, time.Minute)
+				l.Update()
+				labels, err := l.Labels()
+				require.NoError(t, err)
+				require.Empty(t, labels)
+				return
+			}
+
+			var slept time.Duration
+			l, err := sampleLinkHealth(tc.devices, tc.links, func(d time.Duration) { slept += d })
+			require.NoError(t, err)
+			require.Equal(t, tc.links.GetSampleInterval(), slept)
+
+			labels, err := l.Labels()
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedLabels, labels)
+		})
+	}
+}
+
+// newThroughputDevice creates a device with NVLinks whose error and
+// throughput counters increase by the specified amounts between consecutive
+// samples.
+func newThroughputDevice(nvlinkErrors int, throughputKiB uint64) resource.Device {
+	var samples uint64
+	d := rt.NewDeviceMock(false)
+	d.GetLinkCountersFunc = func() (*resource.LinkCounters, error) {
+		samples++
+		errors := samples * uint64(nvlinkErrors)
+		throughput := samples * throughputKiB
+		return &resource.LinkCounters{NVLinkErrors: &errors, NVLinkThroughputKiB: &throughput}, nil
+	}
+	return d
+}
+
+func TestLinkHealthErrorsPerGiB(t *testing.T) {
+	links := &spec.LinkHealth{MaxNVLinkErrorsPerGiB: 1}
+	testCases := []struct {
+		description    string
+		device         resource.Device
+		expectedNVLink string
+	}{
+		{
+			description:    "errors below the maximum per GiB are healthy",
+			device:         newThroughputDevice(10, 20<<20),
+			expectedNVLink: "healthy",
+		},
+		{
+			description:    "errors above the maximum per GiB are degraded",
+			device:         newThroughputDevice(10, 5<<20),
+			expectedNVLink: "degraded",
+		},
+		{
+			description:    "errors without traffic are degraded",
+			device:         newThroughputDevice(1, 0),
+			expectedNVLink: "degraded",
+		},
+		{
+			description:    "error rate is used without throughput counters",
+			device:         newLinkDevice(0, 1),
+			expectedNVLink: "degraded",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			l, err := sampleLinkHealth([]resource.Device{tc.device}, links, func(time.Duration) {})
+			require.NoError(t, err)
+			labels, err := l.Labels()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedNVLink, labels["nvidia.com/gpu.link-health.nvlink"])
+		})
+	}
+}
+
+func TestSamplingLabelerRun(t *testing.T) {
+	interval := spec.Duration(time.Millisecond)
+	config := &spec.Config{Health: &spec.Health{Links: &spec.LinkHealth{SampleInterval: &interval}}}
+	l := NewSamplingLabeler(rt.NewManagerMockWithDevices(newLinkDevice(0, 1)), config, time.Millisecond)
+
+	labels, err := l.Labels()
+	require.NoError(t, err)
+	require.Empty(t, labels, "no labels before the first samples")
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Run(stop)
+	}()
+	require.Eventually(t, func() bool {
+		labels, _ := l.Labels()
+		return labels["nvidia.com/gpu.link-health.nvlink"] == "degraded"
+	}, 5*time.Second, time.Millisecond)
+	close(stop)
+	<-done
+}
+
+func TestLinkHealthNotSupported(t *testing.T) {
+	d := rt.NewDeviceMock(false)
+	d.GetLinkCountersFunc = func() (*resource.LinkCounters, error) {
+		return nil, resource.ErrNotSupported
+	}
+
+	l, err := sampleLinkHealth([]resource.Device{d}, &spec.LinkHealth{}, func(time.Duration) {})
+	require.NoError(t, err)
+
+	labels, err := l.Labels()
+	require.NoError(t, err)
+	require.Empty(t, labels)
+}
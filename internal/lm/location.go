@@ -0,0 +1,96 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/location"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+)
+
+// newLocationLabeler creates a labeler that generates the rack and chassis
+// labels from the device location file. Since the labels apply to the whole
+// node, a label is only generated if all GPUs with a known location agree on
+// its value.
+func newLocationLabeler(manager resource.Manager, config *spec.Config) (Labeler, error) {
+	var path string
+	if config != nil {
+		path = config.Flags.GetDeviceLocationFile()
+	}
+	locations, err := location.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return empty{}, nil
+	}
+
+	devices, err := manager.GetDevices()
+	if err != nil {
+		return nil, fmt.Errorf("error getting devices: %v", err)
+	}
+
+	racks := make(map[string]bool)
+	chassis := make(map[string]bool)
+	for _, d := range devices {
+		uuid, err := d.GetUUID()
+		if err != nil {
+			return nil, fmt.Errorf("error getting device UUID: %w", err)
+		}
+		l := locations.Get(uuid)
+		if l == nil {
+			continue
+		}
+		if l.Rack != "" {
+			racks[l.Rack] = true
+		}
+		if l.Chassis != "" {
+			chassis[l.Chassis] = true
+		}
+	}
+
+	labels := make(Labels)
+	if rack, ok := single("rack", racks); ok {
+		labels["nvidia.com/gpu.rack"] = sanitise(rack)
+	}
+	if chassis, ok := single("chassis", chassis); ok {
+		labels["nvidia.com/gpu.chassis"] = sanitise(chassis)
+	}
+	return labels, nil
+}
+
+// single returns the only value in the set. A warning is logged if the set
+// contains more than one value.
+func single(name string, values map[string]bool) (string, bool) {
+	var list []string
+	for v := range values {
+		list = append(list, v)
+	}
+	sort.Strings(list)
+	if len(list) > 1 {
+		klog.Warningf("Not labeling node with %v: GPUs have different values %v", name, list)
+	}
+	if len(list) != 1 {
+		return "", false
+	}
+	return list[0], true
+}
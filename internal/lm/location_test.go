@@ -0,0 +1,98 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
+)
+
+func newLocatedDevice(uuid string) resource.Device {
+	d := rt.NewDeviceMock(false)
+	d.GetUUIDFunc = func() (string, error) { return uuid, nil }
+	return d
+}
+
+func TestLocationLabeler(t *testing.T) {
+	testCases := []struct {
+		description    string
+		locations      string
+		devices        []resource.Device
+		expectedLabels Labels
+	}{
+		{
+			description: "no location file",
+			devices:     []resource.Device{newLocatedDevice("GPU-0")},
+		},
+		{
+			description: "all GPUs in the same chassis",
+			locations: `GPU-0: {rack: r12, chassis: c3, slot: 1}
+GPU-1: {rack: r12, chassis: c3, slot: 2}
+`,
+			devices: []resource.Device{newLocatedDevice("GPU-0"), newLocatedDevice("GPU-1"), newLocatedDevice("GPU-2")},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.rack":    "r12",
+				"nvidia.com/gpu.chassis": "c3",
+			},
+		},
+		{
+			description: "GPUs in different chassis",
+			locations: `GPU-0: {rack: r12, chassis: c3}
+GPU-1: {rack: r12, chassis: c4}
+`,
+			devices: []resource.Device{newLocatedDevice("GPU-0"), newLocatedDevice("GPU-1")},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.rack": "r12",
+			},
+		},
+		{
+			description:    "no GPUs with a known location",
+			locations:      `GPU-0: {rack: r12}`,
+			devices:        []resource.Device{newLocatedDevice("GPU-1")},
+			expectedLabels: Labels{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := &spec.Config{}
+			if tc.locations != "" {
+				path := filepath.Join(t.TempDir(), "locations.yaml")
+				require.NoError(t, os.WriteFile(path, []byte(tc.locations), 0600))
+				config.Flags.DeviceLocationFile = &path
+			}
+
+			l, err := newLocationLabeler(rt.NewManagerMockWithDevices(tc.devices...), config)
+			require.NoError(t, err)
+
+			labels, err := l.Labels()
+			require.NoError(t, err)
+			if tc.expectedLabels == nil {
+				require.Empty(t, labels)
+				return
+			}
+			require.Equal(t, tc.expectedLabels, labels)
+		})
+	}
+}
@@ -71,12 +71,36 @@ func NewDeviceLabeler(manager resource.Manager, config *spec.Config) (Labeler, e
 		return nil, fmt.Errorf("error creating resource labeler: %v", err)
 	}
 
+	thermalLabeler, err := newThermalLabeler(manager, config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating thermal labeler: %w", err)
+	}
+
+	wearLabeler, err := newWearLabeler(manager, config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating wear labeler: %w", err)
+	}
+
+	locationLabeler, err := newLocationLabeler(manager, config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating location labeler: %w", err)
+	}
+
+	eccLabeler, err := newECCLabeler(manager)
+	if err != nil {
+		return nil, fmt.Errorf("error creating ECC labeler: %w", err)
+	}
+
 	l := Merge(
 		machineTypeLabeler,
 		versionLabeler,
 		migCapabilityLabeler,
 		sharingLabeler,
 		resourceLabeler,
+		thermalLabeler,
+		wearLabeler,
+		locationLabeler,
+		eccLabeler,
 	)
 
 	return l, nil
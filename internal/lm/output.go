@@ -19,15 +19,20 @@ package lm
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/csaupgrade"
 	"k8s.io/klog/v2"
 	nfdv1alpha1 "sigs.k8s.io/node-feature-discovery/pkg/apis/nfd/v1alpha1"
 	nfdclientset "sigs.k8s.io/node-feature-discovery/pkg/generated/clientset/versioned"
@@ -39,6 +44,8 @@ import (
 // Outputer defines a mechanism to output labels.
 type Outputer interface {
 	Output(Labels) error
+	// Remove removes all previously output labels.
+	Remove() error
 }
 
 // TODO: Replace this with functional options.
@@ -92,6 +99,21 @@ func (path *toFile) Output(labels Labels) error {
 	return nil
 }
 
+// Remove removes the output file. A missing file is not an error.
+func (path *toFile) Remove() error {
+	klog.Infof("Removing output file %v", *path)
+	err := os.Remove(string(*path))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove output file: %w", err)
+	}
+	return nil
+}
+
+// Remove is a no-op since labels written to a writer cannot be removed.
+func (output *toWriter) Remove() error {
+	return nil
+}
+
 func (output *toWriter) Output(labels Labels) error {
 	for k, v := range labels {
 		_, err := fmt.Fprintf(output, "%s=%s\n", k, v)
@@ -152,12 +174,36 @@ func writeFileAtomically(path string, contents []byte, perm os.FileMode) error {
 
 const nodeFeatureVendorPrefix = "nvidia-features-for"
 
+// nodeFeatureFieldManager is the field manager with which the NodeFeature
+// object is applied. Labels that are no longer output are removed from the
+// object, since they were previously applied by the same field manager.
+const nodeFeatureFieldManager = "gpu-feature-discovery"
+
+// nodeFeatureUpdateManagers are the field managers with which the NodeFeature
+// object was created and updated before it was applied. They are derived from
+// the user agent of the executable that ran GFD.
+var nodeFeatureUpdateManagers = sets.New("gpu-feature-discovery", "nvidia-device-plugin")
+
+// nodeFeatureApplyBackoff is the backoff with which applying the NodeFeature
+// object is retried if it fails with a transient error.
+var nodeFeatureApplyBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 100 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
 type nodeFeatureObject struct {
 	nodeConfig   flags.NodeConfig
 	nfdClientset nfdclientset.Interface
+	// upgraded indicates whether the managed fields of the NodeFeature object
+	// were upgraded to server-side apply.
+	upgraded bool
 }
 
-// UpdateNodeFeatureObject creates/updates the node-specific NodeFeature custom resource.
+// Output creates or updates the node-specific NodeFeature custom resource.
+// All labels are applied in a single server-side apply patch, so that the
+// object never contains a partial set of labels.
 func (n *nodeFeatureObject) Output(labels Labels) error {
 	nodename := n.nodeConfig.Name
 	if nodename == "" {
@@ -166,37 +212,102 @@ func (n *nodeFeatureObject) Output(labels Labels) error {
 	namespace := n.nodeConfig.Namespace
 	nodeFeatureName := strings.Join([]string{nodeFeatureVendorPrefix, nodename}, "-")
 
-	if nfr, err := n.nfdClientset.NfdV1alpha1().NodeFeatures(namespace).Get(context.TODO(), nodeFeatureName, metav1.GetOptions{}); errors.IsNotFound(err) {
-		klog.Infof("creating NodeFeature object %s", nodeFeatureName)
-		nfr = &nfdv1alpha1.NodeFeature{
-			TypeMeta:   metav1.TypeMeta{},
-			ObjectMeta: metav1.ObjectMeta{Name: nodeFeatureName, Labels: map[string]string{nfdv1alpha1.NodeFeatureObjNodeNameLabel: nodename}},
-			Spec:       nfdv1alpha1.NodeFeatureSpec{Features: *nfdv1alpha1.NewFeatures(), Labels: labels},
-		}
+	nfr := &nfdv1alpha1.NodeFeature{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: nfdv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "NodeFeature",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodeFeatureName,
+			Namespace: namespace,
+			Labels:    map[string]string{nfdv1alpha1.NodeFeatureObjNodeNameLabel: nodename},
+		},
+		Spec: nfdv1alpha1.NodeFeatureSpec{Features: *nfdv1alpha1.NewFeatures(), Labels: labels},
+	}
+	patch, err := json.Marshal(nfr)
+	if err != nil {
+		return fmt.Errorf("failed to construct patch: %w", err)
+	}
 
-		nfrCreated, err := n.nfdClientset.NfdV1alpha1().NodeFeatures(namespace).Create(context.TODO(), nfr, metav1.CreateOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to create NodeFeature object %q: %w", nfr.Name, err)
+	if !n.upgraded {
+		if err := n.upgradeManagedFields(namespace, nodeFeatureName); err != nil {
+			return err
 		}
+		n.upgraded = true
+	}
+
+	// The labels are owned by GFD, so conflicting changes by other managers
+	// are overwritten.
+	force := true
 
-		klog.Infof("NodeFeature object created: %v", nfrCreated)
-	} else if err != nil {
-		return fmt.Errorf("failed to get NodeFeature object: %w", err)
-	} else {
-		nfrUpdated := nfr.DeepCopy()
-		nfrUpdated.Labels = map[string]string{nfdv1alpha1.NodeFeatureObjNodeNameLabel: nodename}
-		nfrUpdated.Spec = nfdv1alpha1.NodeFeatureSpec{Features: *nfdv1alpha1.NewFeatures(), Labels: labels}
-
-		if !apiequality.Semantic.DeepEqual(nfr, nfrUpdated) {
-			klog.Infof("updating NodeFeature object %s", nodeFeatureName)
-			nfrUpdated, err = n.nfdClientset.NfdV1alpha1().NodeFeatures(namespace).Update(context.TODO(), nfrUpdated, metav1.UpdateOptions{})
-			if err != nil {
-				return fmt.Errorf("failed to update NodeFeature object %q: %w", nfr.Name, err)
-			}
-			klog.Infof("NodeFeature object updated: %v", nfrUpdated)
-		} else {
-			klog.Infof("no changes in NodeFeature object, not updating")
+	klog.Infof("applying NodeFeature object %s", nodeFeatureName)
+	var applyErr error
+	err = wait.ExponentialBackoff(nodeFeatureApplyBackoff, func() (bool, error) {
+		_, applyErr = n.nfdClientset.NfdV1alpha1().NodeFeatures(namespace).Patch(
+			context.TODO(),
+			nodeFeatureName,
+			types.ApplyPatchType,
+			patch,
+			metav1.PatchOptions{FieldManager: nodeFeatureFieldManager, Force: &force},
+		)
+		switch {
+		case applyErr == nil:
+			return true, nil
+		case isRetriable(applyErr):
+			klog.Warningf("Retrying to apply NodeFeature object %s: %v", nodeFeatureName, applyErr)
+			return false, nil
 		}
+		return false, applyErr
+	})
+	if wait.Interrupted(err) {
+		err = applyErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply NodeFeature object %q: %w", nodeFeatureName, err)
+	}
+	return nil
+}
+
+// upgradeManagedFields transfers the ownership of the fields of an existing
+// NodeFeature object that were set by updates to the field manager with which
+// the object is applied. Otherwise, labels that were set by an update before
+// the object was first applied would never be removed by later applies.
+func (n *nodeFeatureObject) upgradeManagedFields(namespace string, name string) error {
+	nfr, err := n.nfdClientset.NfdV1alpha1().NodeFeatures(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get NodeFeature object %q: %w", name, err)
+	}
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(nfr, nodeFeatureUpdateManagers, nodeFeatureFieldManager)
+	if err != nil {
+		return fmt.Errorf("failed to construct managed fields patch for NodeFeature object %q: %w", name, err)
+	}
+	if patch == nil {
+		return nil
+	}
+	klog.Infof("upgrading managed fields of NodeFeature object %s", name)
+	_, err = n.nfdClientset.NfdV1alpha1().NodeFeatures(namespace).Patch(context.TODO(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to upgrade managed fields of NodeFeature object %q: %w", name, err)
+	}
+	return nil
+}
+
+// isRetriable returns whether a request that failed with the specified error
+// may succeed if it is retried.
+func isRetriable(err error) bool {
+	return errors.IsConflict(err) || errors.IsServerTimeout(err) || errors.IsTimeout(err) || errors.IsTooManyRequests(err)
+}
+
+// Remove deletes the node-specific NodeFeature custom resource.
+func (n *nodeFeatureObject) Remove() error {
+	nodeFeatureName := strings.Join([]string{nodeFeatureVendorPrefix, n.nodeConfig.Name}, "-")
+	err := n.nfdClientset.NfdV1alpha1().NodeFeatures(n.nodeConfig.Namespace).Delete(context.TODO(), nodeFeatureName, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete NodeFeature object %q: %w", nodeFeatureName, err)
 	}
+	klog.Infof("NodeFeature object %s deleted", nodeFeatureName)
 	return nil
 }
@@ -0,0 +1,233 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	nfdv1alpha1 "sigs.k8s.io/node-feature-discovery/pkg/apis/nfd/v1alpha1"
+	nfdclientset "sigs.k8s.io/node-feature-discovery/pkg/generated/clientset/versioned"
+	nfdv1alpha1client "sigs.k8s.io/node-feature-discovery/pkg/generated/clientset/versioned/typed/nfd/v1alpha1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/flags"
+)
+
+type patch struct {
+	namespace string
+	name      string
+	pt        types.PatchType
+	data      []byte
+	opts      metav1.PatchOptions
+}
+
+// fakeNFDClientset records the patches of NodeFeature objects and fails them
+// with the specified errors in turn. The existing object is returned by Get.
+type fakeNFDClientset struct {
+	nfdclientset.Interface
+	existing *nfdv1alpha1.NodeFeature
+	gets     int
+	errs     []error
+	patches  []patch
+}
+
+type fakeNFDV1alpha1 struct {
+	nfdv1alpha1client.NfdV1alpha1Interface
+	clientset *fakeNFDClientset
+}
+
+type fakeNodeFeatures struct {
+	nfdv1alpha1client.NodeFeatureInterface
+	clientset *fakeNFDClientset
+	namespace string
+}
+
+func (c *fakeNFDClientset) NfdV1alpha1() nfdv1alpha1client.NfdV1alpha1Interface {
+	return &fakeNFDV1alpha1{clientset: c}
+}
+
+func (c *fakeNFDV1alpha1) NodeFeatures(namespace string) nfdv1alpha1client.NodeFeatureInterface {
+	return &fakeNodeFeatures{clientset: c.clientset, namespace: namespace}
+}
+
+func (c *fakeNodeFeatures) Get(ctx context.Context, name string, opts metav1.GetOptions) (*nfdv1alpha1.NodeFeature, error) {
+	c.clientset.gets++
+	if c.clientset.existing == nil {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: "nfd.k8s-sigs.io", Resource: "nodefeatures"}, name)
+	}
+	return c.clientset.existing, nil
+}
+
+func (c *fakeNodeFeatures) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*nfdv1alpha1.NodeFeature, error) {
+	c.clientset.patches = append(c.clientset.patches, patch{c.namespace, name, pt, data, opts})
+	if len(c.clientset.errs) > 0 {
+		err := c.clientset.errs[0]
+		c.clientset.errs = c.clientset.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &nfdv1alpha1.NodeFeature{}, nil
+}
+
+func TestNodeFeatureObjectOutput(t *testing.T) {
+	defer func(backoff wait.Backoff) {
+		nodeFeatureApplyBackoff = backoff
+	}(nodeFeatureApplyBackoff)
+	nodeFeatureApplyBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1}
+
+	resource := schema.GroupResource{Group: "nfd.k8s-sigs.io", Resource: "nodefeatures"}
+	conflict := errors.NewConflict(resource, "nvidia-features-for-node", nil)
+
+	testCases := []struct {
+		description     string
+		errs            []error
+		expectedPatches int
+		expectedError   bool
+	}{
+		{
+			description:     "labels are applied in a single patch",
+			expectedPatches: 1,
+		},
+		{
+			description:     "conflicts are retried",
+			errs:            []error{conflict, nil},
+			expectedPatches: 2,
+		},
+		{
+			description:     "retries are limited",
+			errs:            []error{conflict, conflict, conflict},
+			expectedPatches: 3,
+			expectedError:   true,
+		},
+		{
+			description:     "other errors are not retried",
+			errs:            []error{errors.NewForbidden(resource, "nvidia-features-for-node", nil)},
+			expectedPatches: 1,
+			expectedError:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			clientset := &fakeNFDClientset{errs: tc.errs}
+			o := &nodeFeatureObject{
+				nodeConfig:   flags.NodeConfig{Name: "node", Namespace: "gpu-operator"},
+				nfdClientset: clientset,
+			}
+
+			err := o.Output(Labels{"nvidia.com/gpu.count": "2", "nvidia.com/gpu.product": "A100"})
+			if tc.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, clientset.patches, tc.expectedPatches)
+
+			p := clientset.patches[0]
+			require.Equal(t, "gpu-operator", p.namespace)
+			require.Equal(t, "nvidia-features-for-node", p.name)
+			require.Equal(t, types.ApplyPatchType, p.pt)
+			require.Equal(t, nodeFeatureFieldManager, p.opts.FieldManager)
+			require.True(t, *p.opts.Force)
+
+			var applied nfdv1alpha1.NodeFeature
+			require.NoError(t, json.Unmarshal(p.data, &applied))
+			require.Equal(t, "NodeFeature", applied.Kind)
+			require.Equal(t, "node", applied.Labels[nfdv1alpha1.NodeFeatureObjNodeNameLabel])
+			require.Equal(t, map[string]string{"nvidia.com/gpu.count": "2", "nvidia.com/gpu.product": "A100"}, applied.Spec.Labels)
+		})
+	}
+}
+
+func TestNodeFeatureObjectUpgradeManagedFields(t *testing.T) {
+	updated := &nfdv1alpha1.NodeFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "nvidia-features-for-node",
+			Namespace:       "gpu-operator",
+			ResourceVersion: "42",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					Manager:    "gpu-feature-discovery",
+					Operation:  metav1.ManagedFieldsOperationUpdate,
+					APIVersion: "nfd.k8s-sigs.io/v1alpha1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:labels":{"f:nvidia.com/gpu.count":{}}}}`)},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		description     string
+		existing        *nfdv1alpha1.NodeFeature
+		expectedPatches []types.PatchType
+	}{
+		{
+			description:     "missing object is applied",
+			expectedPatches: []types.PatchType{types.ApplyPatchType, types.ApplyPatchType},
+		},
+		{
+			description:     "updated object is upgraded before the first apply",
+			existing:        updated,
+			expectedPatches: []types.PatchType{types.JSONPatchType, types.ApplyPatchType, types.ApplyPatchType},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			clientset := &fakeNFDClientset{existing: tc.existing}
+			o := &nodeFeatureObject{
+				nodeConfig:   flags.NodeConfig{Name: "node", Namespace: "gpu-operator"},
+				nfdClientset: clientset,
+			}
+
+			require.NoError(t, o.Output(Labels{"nvidia.com/gpu.count": "2"}))
+			require.NoError(t, o.Output(Labels{"nvidia.com/gpu.count": "2"}))
+			require.Equal(t, 1, clientset.gets)
+
+			var patchTypes []types.PatchType
+			for _, p := range clientset.patches {
+				patchTypes = append(patchTypes, p.pt)
+			}
+			require.Equal(t, tc.expectedPatches, patchTypes)
+			if tc.existing == nil {
+				return
+			}
+
+			var ops []struct {
+				Path  string          `json:"path"`
+				Value json.RawMessage `json:"value"`
+			}
+			require.NoError(t, json.Unmarshal(clientset.patches[0].data, &ops))
+			require.Equal(t, "/metadata/managedFields", ops[0].Path)
+			var managedFields []metav1.ManagedFieldsEntry
+			require.NoError(t, json.Unmarshal(ops[0].Value, &managedFields))
+			require.Len(t, managedFields, 1)
+			require.Equal(t, nodeFeatureFieldManager, managedFields[0].Manager)
+			require.Equal(t, metav1.ManagedFieldsOperationApply, managedFields[0].Operation)
+		})
+	}
+}
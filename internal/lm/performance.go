@@ -0,0 +1,120 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+)
+
+const (
+	performanceNormal   = "normal"
+	performanceDegraded = "degraded"
+	// performanceUnknownReason is the reason reported for GPUs that do not
+	// reach P0 under load although NVML reports no reason for it.
+	performanceUnknownReason = "unknown"
+	// maxLabelValueLength is the maximum length of a Kubernetes label value.
+	maxLabelValueLength = 63
+)
+
+// samplePerformance samples the performance states of the specified devices
+// and returns the resulting performance labels. The performance states of all
+// GPUs are sampled repeatedly and the node is labeled with the dominant
+// performance state and whether any GPU failed to reach P0 while under load,
+// along with the reasons reported for it. The sleep function is called between
+// consecutive samples.
+func samplePerformance(devices []resource.Device, performance *spec.PerformanceHealth, sleep func(time.Duration)) (Labeler, error) {
+	if len(devices) == 0 {
+		return empty{}, nil
+	}
+
+	pstates := make(map[int]int)
+	reasons := make(map[string]bool)
+	degraded := false
+	for i := 0; i < performance.GetSamples(); i++ {
+		if i > 0 {
+			sleep(performance.GetSampleInterval())
+		}
+		for _, d := range devices {
+			state, err := d.GetPerformanceState()
+			if errors.Is(err, resource.ErrNotSupported) {
+				return empty{}, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error getting performance state: %w", err)
+			}
+			pstates[state.PState]++
+			if state.Utilization < performance.GetMinUtilization() || state.PState == 0 {
+				continue
+			}
+			degraded = true
+			for _, r := range state.Reasons {
+				reasons[r] = true
+			}
+		}
+	}
+
+	labels := Labels{
+		"nvidia.com/gpu.pstate":      fmt.Sprintf("P%d", dominantPState(pstates)),
+		"nvidia.com/gpu.performance": performanceNormal,
+	}
+	if degraded {
+		labels["nvidia.com/gpu.performance"] = performanceDegraded
+		labels["nvidia.com/gpu.performance.reasons"] = joinReasons(reasons)
+	}
+	return labels, nil
+}
+
+// dominantPState returns the performance state that was sampled most often.
+// Ties are resolved in favor of the lower performance state, i.e. the higher
+// P-state number.
+func dominantPState(pstates map[int]int) int {
+	dominant := -1
+	for pstate, count := range pstates {
+		if dominant == -1 || count > pstates[dominant] || (count == pstates[dominant] && pstate > dominant) {
+			dominant = pstate
+		}
+	}
+	return dominant
+}
+
+// joinReasons joins the sorted reasons into a valid label value. Reasons that
+// would exceed the maximum length of a label value are dropped.
+func joinReasons(reasons map[string]bool) string {
+	if len(reasons) == 0 {
+		return performanceUnknownReason
+	}
+	var sorted []string
+	for r := range reasons {
+		sorted = append(sorted, r)
+	}
+	sort.Strings(sorted)
+
+	value := sorted[0]
+	for _, r := range sorted[1:] {
+		if len(value)+1+len(r) > maxLabelValueLength {
+			break
+		}
+		value += "." + r
+	}
+	return value
+}
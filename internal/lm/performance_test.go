@@ -0,0 +1,175 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
+)
+
+// newPerformanceDevice creates a device that returns the specified
+// performance states in consecutive samples, repeating the last one.
+func newPerformanceDevice(states ...resource.PerformanceState) resource.Device {
+	var samples int
+	d := rt.NewDeviceMock(false)
+	d.GetPerformanceStateFunc = func() (*resource.PerformanceState, error) {
+		state := states[min(samples, len(states)-1)]
+		samples++
+		return &state, nil
+	}
+	return d
+}
+
+func TestPerformanceLabeler(t *testing.T) {
+	idle := resource.PerformanceState{PState: 8}
+	busy := resource.PerformanceState{PState: 0, Utilization: 100}
+
+	testCases := []struct {
+		description    string
+		devices        []resource.Device
+		performance    *spec.PerformanceHealth
+		expectedLabels Labels
+	}{
+		{
+			description: "performance sampling disabled",
+			devices:     []resource.Device{newPerformanceDevice(busy)},
+		},
+		{
+			description: "gpus in P0 under load are normal",
+			devices:     []resource.Device{newPerformanceDevice(busy), newPerformanceDevice(busy)},
+			performance: &spec.PerformanceHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.pstate":      "P0",
+				"nvidia.com/gpu.performance": "normal",
+			},
+		},
+		{
+			description: "idle gpus below P0 are normal",
+			devices:     []resource.Device{newPerformanceDevice(idle)},
+			performance: &spec.PerformanceHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.pstate":      "P8",
+				"nvidia.com/gpu.performance": "normal",
+			},
+		},
+		{
+			description: "dominant pstate is sampled most often",
+			devices: []resource.Device{
+				newPerformanceDevice(idle, busy),
+			},
+			performance: &spec.PerformanceHealth{Samples: 3},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.pstate":      "P0",
+				"nvidia.com/gpu.performance": "normal",
+			},
+		},
+		{
+			description: "gpu below P0 under load is degraded",
+			devices: []resource.Device{
+				newPerformanceDevice(busy),
+				newPerformanceDevice(resource.PerformanceState{PState: 2, Utilization: 90, Reasons: []string{"sw-power-cap", "pcie-downtrained"}}),
+			},
+			performance: &spec.PerformanceHealth{Samples: 2},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.pstate":              "P2",
+				"nvidia.com/gpu.performance":         "degraded",
+				"nvidia.com/gpu.performance.reasons": "pcie-downtrained.sw-power-cap",
+			},
+		},
+		{
+			description: "degraded without reasons is unknown",
+			devices: []resource.Device{
+				newPerformanceDevice(resource.PerformanceState{PState: 2, Utilization: 60}),
+			},
+			performance: &spec.PerformanceHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.pstate":              "P2",
+				"nvidia.com/gpu.performance":         "degraded",
+				"nvidia.com/gpu.performance.reasons": "unknown",
+			},
+		},
+		{
+			description: "utilization below the minimum is not under load",
+			devices: []resource.Device{
+				newPerformanceDevice(resource.PerformanceState{PState: 2, Utilization: 60}),
+			},
+			performance: &spec.PerformanceHealth{MinUtilization: 80},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.pstate":      "P2",
+				"nvidia.com/gpu.performance": "normal",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.performance == nil {
+				config := &spec.Config{Health: &spec.Health{}}
+				l := NewSamplingLabeler(rt.NewManagerMockWithDevices(tc.devices...), config, time.Minute)
+				l.Update()
+				labels, err := l.Labels()
+				require.NoError(t, err)
+				require.Empty(t, labels)
+				return
+			}
+
+			var slept time.Duration
+			l, err := samplePerformance(tc.devices, tc.performance, func(d time.Duration) { slept += d })
+			require.NoError(t, err)
+			require.Equal(t, time.Duration(tc.performance.GetSamples()-1)*tc.performance.GetSampleInterval(), slept)
+
+			labels, err := l.Labels()
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedLabels, labels)
+		})
+	}
+}
+
+func TestPerformanceNotSupported(t *testing.T) {
+	d := rt.NewDeviceMock(false)
+	d.GetPerformanceStateFunc = func() (*resource.PerformanceState, error) {
+		return nil, resource.ErrNotSupported
+	}
+
+	l, err := samplePerformance([]resource.Device{d}, &spec.PerformanceHealth{}, func(time.Duration) {})
+	require.NoError(t, err)
+
+	labels, err := l.Labels()
+	require.NoError(t, err)
+	require.Empty(t, labels)
+}
+
+func TestSamplingLabelerPerformance(t *testing.T) {
+	config := &spec.Config{Health: &spec.Health{Performance: &spec.PerformanceHealth{Samples: 1}}}
+	busy := resource.PerformanceState{PState: 0, Utilization: 100}
+	l := NewSamplingLabeler(rt.NewManagerMockWithDevices(newPerformanceDevice(busy)), config, time.Minute)
+
+	l.Update()
+
+	labels, err := l.Labels()
+	require.NoError(t, err)
+	require.EqualValues(t, Labels{
+		"nvidia.com/gpu.pstate":      "P0",
+		"nvidia.com/gpu.performance": "normal",
+	}, labels)
+}
@@ -17,6 +17,7 @@
 package lm
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -49,7 +50,13 @@ func NewGPUResourceLabeler(config *spec.Config, device resource.Device, count in
 		return nil, fmt.Errorf("failed to get memory info for device: %v", err)
 	}
 
+	bar1MemoryMB, err := device.GetBAR1MemoryMB()
+	if err != nil && !errors.Is(err, resource.ErrNotSupported) {
+		return nil, fmt.Errorf("failed to get BAR1 memory info for device: %v", err)
+	}
+
 	resourceLabeler := newResourceLabeler(fullGPUResourceName, config)
+	resourceLabeler.totalMemoryMB = totalMemoryMB
 
 	architectureLabels, err := newArchitectureLabels(resourceLabeler, device)
 	if err != nil {
@@ -58,12 +65,21 @@ func NewGPUResourceLabeler(config *spec.Config, device resource.Device, count in
 
 	memoryLabeler := (Labeler)(&empty{})
 	if totalMemoryMB != 0 {
-		memoryLabeler = resourceLabeler.single("memory", totalMemoryMB)
+		memoryLabeler = Merge(
+			resourceLabeler.single("memory", totalMemoryMB),
+			resourceLabeler.replicaMemoryLabels(totalMemoryMB),
+		)
+	}
+
+	bar1MemoryLabels := make(Labels)
+	if bar1MemoryMB != 0 {
+		bar1MemoryLabels = resourceLabeler.single("bar1.memory", bar1MemoryMB)
 	}
 
 	labelers := Merge(
 		resourceLabeler.baseLabeler(count, model),
 		memoryLabeler,
+		bar1MemoryLabels,
 		architectureLabels,
 	)
 
@@ -120,6 +136,9 @@ func newResourceLabeler(resourceName spec.ResourceName, config *spec.Config) res
 type resourceLabeler struct {
 	resourceName spec.ResourceName
 	sharing      *spec.Sharing
+	// totalMemoryMB is the memory of the device, which determines the number
+	// of replicas if the resource is shared in chunks of memory.
+	totalMemoryMB uint64
 }
 
 // single creates a single label for the resource. The label key is
@@ -159,7 +178,7 @@ func (rl resourceLabeler) baseLabeler(count int, parts ...string) Labeler {
 	replicas := rl.getReplicas()
 	strategy := spec.SharingStrategyNone
 	if rl.sharing != nil && replicas > 1 {
-		strategy = rl.sharing.SharingStrategy()
+		strategy = rl.sharing.StrategyForResource(rl.resourceName)
 	}
 	rawLabels := map[string]interface{}{
 		"product":          rl.getProductName(parts...),
@@ -175,6 +194,30 @@ func (rl resourceLabeler) baseLabeler(count int, parts ...string) Labeler {
 	return labels
 }
 
+// replicaMemoryLabels generates the label with the memory in MB available to
+// each replica of a GPU with the specified total memory if the resource is
+// shared using MPS. For replicas, the label is only generated if a server
+// memory overhead is configured; the memory used by the MPS server on the GPU
+// is not available to the replicas and is subtracted first. The label is
+// omitted if no memory remains.
+func (rl resourceLabeler) replicaMemoryLabels(totalMemoryMB uint64) Labels {
+	if rl.sharingDisabled() || rl.sharing.StrategyForResource(rl.resourceName) != spec.SharingStrategyMPS {
+		return make(Labels)
+	}
+	r := rl.replicationInfo()
+	if r != nil && rl.isMemory() {
+		return rl.single("replica.memory", rl.sharing.MPS.GetMemoryChunkMB())
+	}
+	if r == nil || r.Replicas <= 1 || r.ServerMemoryOverheadMB == nil {
+		return make(Labels)
+	}
+	memoryMB := r.ReplicaMemoryMB(totalMemoryMB)
+	if memoryMB == 0 {
+		return make(Labels)
+	}
+	return rl.single("replica.memory", memoryMB)
+}
+
 // Deprecated
 func (rl resourceLabeler) productLabel(parts ...string) Labels {
 	name := rl.getProductName(parts...)
@@ -206,7 +249,13 @@ func (rl resourceLabeler) getProductName(parts ...string) string {
 func (rl resourceLabeler) getReplicas() int {
 	if rl.sharingDisabled() {
 		return 0
-	} else if r := rl.replicationInfo(); r != nil && r.Replicas > 0 {
+	}
+	r := rl.replicationInfo()
+	if r != nil && rl.isMemory() {
+		if chunks := rl.sharing.MPS.MemoryChunks(r, rl.totalMemoryMB*1024*1024); chunks > 0 {
+			return chunks
+		}
+	} else if r != nil && r.Replicas > 0 {
 		return r.Replicas
 	}
 	return 1
@@ -220,12 +269,20 @@ func (rl resourceLabeler) sharingDisabled() bool {
 
 // isShared checks whether the resource is shared.
 func (rl resourceLabeler) isShared() bool {
-	if r := rl.replicationInfo(); r != nil && r.Replicas > 1 {
+	if r := rl.replicationInfo(); r != nil && (r.Replicas > 1 || rl.isMemory()) {
 		return true
 	}
 	return false
 }
 
+// isMemory checks whether the resource is shared in chunks of memory.
+func (rl resourceLabeler) isMemory() bool {
+	if rl.sharingDisabled() {
+		return false
+	}
+	return rl.sharing.ReplicatedResourcesFor(rl.resourceName).IsMemory()
+}
+
 // isRenamed checks whether the resource is renamed.
 func (rl resourceLabeler) isRenamed() bool {
 	if r := rl.replicationInfo(); r != nil && r.Rename != "" {
@@ -239,7 +296,11 @@ func (rl resourceLabeler) replicationInfo() *spec.ReplicatedResource {
 	if rl.sharingDisabled() {
 		return nil
 	}
-	for _, r := range rl.sharing.ReplicatedResources().Resources {
+	rrs := rl.sharing.ReplicatedResourcesFor(rl.resourceName)
+	if rrs == nil {
+		return nil
+	}
+	for _, r := range rrs.Resources {
 		if r.Name == rl.resourceName {
 			return &r
 		}
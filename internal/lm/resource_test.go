@@ -22,14 +22,19 @@ import (
 	"github.com/stretchr/testify/require"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
 	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
 )
 
 func TestGPUResourceLabeler(t *testing.T) {
 	device := rt.NewFullGPU()
 
+	bar1Device := rt.NewDeviceMock(false)
+	bar1Device.GetBAR1MemoryMBFunc = func() (uint64, error) { return 32768, nil }
+
 	testCases := []struct {
 		description    string
+		device         resource.Device
 		count          int
 		sharing        spec.Sharing
 		expectedLabels Labels
@@ -37,6 +42,22 @@ func TestGPUResourceLabeler(t *testing.T) {
 		{
 			description: "zero count returns empty",
 		},
+		{
+			description: "BAR1 memory is labeled",
+			device:      bar1Device,
+			count:       1,
+			expectedLabels: Labels{
+				"nvidia.com/gpu.count":            "1",
+				"nvidia.com/gpu.replicas":         "1",
+				"nvidia.com/gpu.sharing-strategy": "none",
+				"nvidia.com/gpu.memory":           "300",
+				"nvidia.com/gpu.bar1.memory":      "32768",
+				"nvidia.com/gpu.product":          "MOCKMODEL",
+				"nvidia.com/gpu.family":           "ampere",
+				"nvidia.com/gpu.compute.major":    "8",
+				"nvidia.com/gpu.compute.minor":    "0",
+			},
+		},
 		{
 			description: "no sharing",
 			count:       1,
@@ -197,6 +218,87 @@ func TestGPUResourceLabeler(t *testing.T) {
 				"nvidia.com/gpu.compute.minor":    "0",
 			},
 		},
+		{
+			description: "mps replica memory excludes the server memory overhead",
+			count:       1,
+			sharing: spec.Sharing{
+				MPS: &spec.ReplicatedResources{
+					Resources: []spec.ReplicatedResource{
+						{
+							Name:                   "nvidia.com/gpu",
+							Rename:                 "nvidia.com/gpu.shared",
+							Replicas:               2,
+							ServerMemoryOverheadMB: ptr[uint64](100),
+						},
+					},
+				},
+			},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.count":            "1",
+				"nvidia.com/gpu.replicas":         "2",
+				"nvidia.com/gpu.sharing-strategy": "mps",
+				"nvidia.com/gpu.memory":           "300",
+				"nvidia.com/gpu.replica.memory":   "100",
+				"nvidia.com/gpu.product":          "MOCKMODEL",
+				"nvidia.com/gpu.family":           "ampere",
+				"nvidia.com/gpu.compute.major":    "8",
+				"nvidia.com/gpu.compute.minor":    "0",
+			},
+		},
+		{
+			description: "mps replica memory is omitted if the overhead exceeds the memory",
+			count:       1,
+			sharing: spec.Sharing{
+				MPS: &spec.ReplicatedResources{
+					Resources: []spec.ReplicatedResource{
+						{
+							Name:                   "nvidia.com/gpu",
+							Rename:                 "nvidia.com/gpu.shared",
+							Replicas:               2,
+							ServerMemoryOverheadMB: ptr[uint64](512),
+						},
+					},
+				},
+			},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.count":            "1",
+				"nvidia.com/gpu.replicas":         "2",
+				"nvidia.com/gpu.sharing-strategy": "mps",
+				"nvidia.com/gpu.memory":           "300",
+				"nvidia.com/gpu.product":          "MOCKMODEL",
+				"nvidia.com/gpu.family":           "ampere",
+				"nvidia.com/gpu.compute.major":    "8",
+				"nvidia.com/gpu.compute.minor":    "0",
+			},
+		},
+		{
+			description: "mps memory chunks are counted per device",
+			count:       1,
+			sharing: spec.Sharing{
+				MPS: &spec.ReplicatedResources{
+					Unit:          spec.ReplicaUnitMemory,
+					MemoryChunkMB: ptr[uint64](50),
+					Resources: []spec.ReplicatedResource{
+						{
+							Name:                   "nvidia.com/gpu",
+							Rename:                 "nvidia.com/gpu-memory",
+							ServerMemoryOverheadMB: ptr[uint64](100),
+						},
+					},
+				},
+			},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.count":            "1",
+				"nvidia.com/gpu.replicas":         "4",
+				"nvidia.com/gpu.sharing-strategy": "mps",
+				"nvidia.com/gpu.memory":           "300",
+				"nvidia.com/gpu.replica.memory":   "50",
+				"nvidia.com/gpu.product":          "MOCKMODEL",
+				"nvidia.com/gpu.family":           "ampere",
+				"nvidia.com/gpu.compute.major":    "8",
+				"nvidia.com/gpu.compute.minor":    "0",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -204,7 +306,11 @@ func TestGPUResourceLabeler(t *testing.T) {
 			config := &spec.Config{
 				Sharing: tc.sharing,
 			}
-			l, err := NewGPUResourceLabeler(config, device, tc.count)
+			d := tc.device
+			if d == nil {
+				d = device
+			}
+			l, err := NewGPUResourceLabeler(config, d, tc.count)
 			require.NoError(t, err)
 
 			labels, err := l.Labels()
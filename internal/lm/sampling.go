@@ -0,0 +1,177 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+)
+
+// SamplingLabeler generates the labels that require sampling the devices over
+// a period of time, i.e. the link health and performance state labels. The devices are sampled in
+// the background by Run so that the generation of the other labels is not
+// delayed by the sampling; Labels returns the labels of the most recent
+// samples.
+type SamplingLabeler struct {
+	sync.Mutex
+	manager  resource.Manager
+	samplers []sampler
+	interval time.Duration
+	labels   Labels
+}
+
+// sampler samples the specified devices and returns the resulting labels. The
+// sleep function is called between consecutive samples.
+type sampler struct {
+	name   string
+	sample func(devices []resource.Device, sleep func(time.Duration)) (Labeler, error)
+}
+
+var _ Labeler = (*SamplingLabeler)(nil)
+
+// NewSamplingLabeler creates a labeler for the samplers enabled in the
+// specified config. The devices are sampled again every interval.
+func NewSamplingLabeler(manager resource.Manager, config *spec.Config, interval time.Duration) *SamplingLabeler {
+	l := &SamplingLabeler{
+		manager:  manager,
+		interval: interval,
+	}
+	if config == nil {
+		return l
+	}
+	if links := config.Health.GetLinks(); links != nil {
+		l.samplers = append(l.samplers, sampler{
+			name: "link health",
+			sample: func(devices []resource.Device, sleep func(time.Duration)) (Labeler, error) {
+				return sampleLinkHealth(devices, links, sleep)
+			},
+		})
+	}
+	if performance := config.Health.GetPerformance(); performance != nil {
+		l.samplers = append(l.samplers, sampler{
+			name: "performance states",
+			sample: func(devices []resource.Device, sleep func(time.Duration)) (Labeler, error) {
+				return samplePerformance(devices, performance, sleep)
+			},
+		})
+	}
+	return l
+}
+
+// Run samples the devices until stop is closed.
+func (l *SamplingLabeler) Run(stop <-chan struct{}) {
+	if len(l.samplers) == 0 {
+		return
+	}
+	sleep := func(d time.Duration) {
+		select {
+		case <-stop:
+		case <-time.After(d):
+		}
+	}
+	for {
+		labels := l.sample(sleep)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		l.setLabels(labels)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(l.interval):
+		}
+	}
+}
+
+// Update samples the devices once and blocks until the sampling is complete.
+func (l *SamplingLabeler) Update() {
+	if len(l.samplers) == 0 {
+		return
+	}
+	l.setLabels(l.sample(time.Sleep))
+}
+
+// Labels returns the labels of the most recent samples.
+func (l *SamplingLabeler) Labels() (Labels, error) {
+	l.Lock()
+	defer l.Unlock()
+	labels := make(Labels)
+	for k, v := range l.labels {
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+// sample runs all samplers. Samplers that fail are logged and do not
+// contribute any labels.
+func (l *SamplingLabeler) sample(sleep func(time.Duration)) Labels {
+	if err := l.manager.Init(); err != nil {
+		klog.Warningf("Failed to initialize resource manager for sampling: %v", err)
+		return nil
+	}
+	defer func() {
+		_ = l.manager.Shutdown()
+	}()
+	devices, err := l.manager.GetDevices()
+	if err != nil {
+		klog.Warningf("Failed to get devices for sampling: %v", err)
+		return nil
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+
+	labels := make(Labels)
+	for _, s := range l.samplers {
+		sampled, err := s.labels(devices, sleep)
+		if err != nil {
+			klog.Warningf("Failed to sample %v: %v", s.name, err)
+			continue
+		}
+		for k, v := range sampled {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+func (s sampler) labels(devices []resource.Device, sleep func(time.Duration)) (Labels, error) {
+	labeler, err := s.sample(devices, sleep)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := labeler.Labels()
+	if err != nil {
+		return nil, fmt.Errorf("error generating labels: %w", err)
+	}
+	return labels, nil
+}
+
+func (l *SamplingLabeler) setLabels(labels Labels) {
+	l.Lock()
+	defer l.Unlock()
+	l.labels = labels
+}
@@ -0,0 +1,88 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"errors"
+	"fmt"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+)
+
+// thermalClassSeverity orders the thermal classes so that the worst class of
+// all devices can be determined.
+var thermalClassSeverity = map[spec.ThermalClass]int{
+	spec.ThermalClassNormal:    0,
+	spec.ThermalClassDegraded:  1,
+	spec.ThermalClassUnhealthy: 2,
+}
+
+// newThermalLabeler creates a labeler that generates the cooling and
+// temperature class labels. The cooling label is "passive" if none of the
+// GPUs have fans. The temperature class label is the worst thermal class of
+// all GPUs with thresholds in the health.thermal config and is omitted if no
+// thresholds match.
+func newThermalLabeler(manager resource.Manager, config *spec.Config) (Labeler, error) {
+	devices, err := manager.GetDevices()
+	if err != nil {
+		return nil, fmt.Errorf("error getting devices: %v", err)
+	}
+
+	var thermal *spec.ThermalHealth
+	if config != nil {
+		thermal = config.Health.GetThermal()
+	}
+
+	labels := make(Labels)
+	cooling := "passive"
+	var class spec.ThermalClass
+	for _, d := range devices {
+		fans, err := d.GetNumFans()
+		if errors.Is(err, resource.ErrNotSupported) {
+			return empty{}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting number of fans: %w", err)
+		}
+		if fans > 0 {
+			cooling = "active"
+		}
+
+		name, err := d.GetName()
+		if err != nil {
+			return nil, fmt.Errorf("error getting device name: %w", err)
+		}
+		threshold := thermal.GetThreshold(name)
+		if threshold == nil {
+			continue
+		}
+		temperature, err := d.GetTemperature()
+		if err != nil {
+			return nil, fmt.Errorf("error getting device temperature: %w", err)
+		}
+		if c := threshold.Classify(temperature); class == "" || thermalClassSeverity[c] > thermalClassSeverity[class] {
+			class = c
+		}
+	}
+
+	labels["nvidia.com/gpu.cooling"] = cooling
+	if class != "" {
+		labels["nvidia.com/gpu.temperature-class"] = string(class)
+	}
+	return labels, nil
+}
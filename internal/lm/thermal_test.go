@@ -0,0 +1,110 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
+)
+
+func newThermalDevice(fans int, temperature int) resource.Device {
+	d := rt.NewDeviceMock(false)
+	d.GetNumFansFunc = func() (int, error) { return fans, nil }
+	d.GetTemperatureFunc = func() (int, error) { return temperature, nil }
+	return d
+}
+
+func TestThermalLabeler(t *testing.T) {
+	thermal := &spec.ThermalHealth{
+		Thresholds: []spec.ThermalThreshold{
+			{Pattern: "MOCKMODEL", Degraded: 80, Unhealthy: 90},
+		},
+	}
+
+	testCases := []struct {
+		description    string
+		devices        []resource.Device
+		thermal        *spec.ThermalHealth
+		expectedLabels Labels
+	}{
+		{
+			description: "devices with fans are actively cooled",
+			devices:     []resource.Device{newThermalDevice(0, 40), newThermalDevice(2, 40)},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.cooling": "active",
+			},
+		},
+		{
+			description: "devices without fans are passively cooled",
+			devices:     []resource.Device{newThermalDevice(0, 40), newThermalDevice(0, 40)},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.cooling": "passive",
+			},
+		},
+		{
+			description: "temperature class is normal below thresholds",
+			devices:     []resource.Device{newThermalDevice(0, 40)},
+			thermal:     thermal,
+			expectedLabels: Labels{
+				"nvidia.com/gpu.cooling":           "passive",
+				"nvidia.com/gpu.temperature-class": "normal",
+			},
+		},
+		{
+			description: "temperature class is the worst of all devices",
+			devices:     []resource.Device{newThermalDevice(0, 85), newThermalDevice(0, 95), newThermalDevice(0, 40)},
+			thermal:     thermal,
+			expectedLabels: Labels{
+				"nvidia.com/gpu.cooling":           "passive",
+				"nvidia.com/gpu.temperature-class": "unhealthy",
+			},
+		},
+		{
+			description: "temperature class is omitted without matching thresholds",
+			devices:     []resource.Device{newThermalDevice(0, 95)},
+			thermal: &spec.ThermalHealth{
+				Thresholds: []spec.ThermalThreshold{
+					{Pattern: "*T4*", Unhealthy: 90},
+				},
+			},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.cooling": "passive",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := &spec.Config{
+				Health: &spec.Health{Thermal: tc.thermal},
+			}
+			manager := rt.NewManagerMockWithDevices(tc.devices...)
+
+			l, err := newThermalLabeler(manager, config)
+			require.NoError(t, err)
+
+			labels, err := l.Labels()
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedLabels, labels)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+)
+
+const (
+	wearNormal = "normal"
+	wearWorn   = "worn"
+)
+
+// newWearLabeler creates a labeler that generates the wear labels if they are
+// enabled in the health.wear config. The node is labeled with the highest
+// retired page and remapped row counts of its GPUs and whether any GPU exceeds
+// the configured thresholds. The energy consumption of the GPUs is not
+// labeled, since it changes continuously; it is exposed as a metric of the
+// device plugin instead.
+func newWearLabeler(manager resource.Manager, config *spec.Config) (Labeler, error) {
+	var wear *spec.WearHealth
+	if config != nil {
+		wear = config.Health.GetWear()
+	}
+	if wear == nil {
+		return empty{}, nil
+	}
+
+	devices, err := manager.GetDevices()
+	if err != nil {
+		return nil, fmt.Errorf("error getting devices: %v", err)
+	}
+	return getWearLabels(devices, wear)
+}
+
+// getWearLabels returns the wear labels of the specified devices.
+func getWearLabels(devices []resource.Device, wear *spec.WearHealth) (Labeler, error) {
+	var retiredPages, remappedRows *uint64
+	worn := false
+	for _, d := range devices {
+		w, err := d.GetWear()
+		if errors.Is(err, resource.ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting wear counters: %w", err)
+		}
+		retiredPages = maxCounter(retiredPages, w.RetiredPages)
+		remappedRows = maxCounter(remappedRows, w.RemappedRows)
+		if wear.IsWorn(w.RetiredPages, w.RemappedRows) {
+			worn = true
+		}
+	}
+	if retiredPages == nil && remappedRows == nil {
+		return empty{}, nil
+	}
+
+	labels := Labels{
+		"nvidia.com/gpu.wear": wearNormal,
+	}
+	if worn {
+		labels["nvidia.com/gpu.wear"] = wearWorn
+	}
+	if retiredPages != nil {
+		labels["nvidia.com/gpu.wear.retired-pages"] = strconv.FormatUint(*retiredPages, 10)
+	}
+	if remappedRows != nil {
+		labels["nvidia.com/gpu.wear.remapped-rows"] = strconv.FormatUint(*remappedRows, 10)
+	}
+	return labels, nil
+}
+
+// maxCounter returns the larger of two counters that may be unknown.
+func maxCounter(a *uint64, b *uint64) *uint64 {
+	if a == nil {
+		return b
+	}
+	if b == nil || *a >= *b {
+		return a
+	}
+	return b
+}
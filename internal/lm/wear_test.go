@@ -0,0 +1,115 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package lm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
+	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
+)
+
+// newWearDevice creates a device with the specified wear counters. A negative
+// count indicates that the counter is not supported.
+func newWearDevice(retiredPages int, remappedRows int) resource.Device {
+	counter := func(count int) *uint64 {
+		if count < 0 {
+			return nil
+		}
+		c := uint64(count)
+		return &c
+	}
+	d := rt.NewDeviceMock(false)
+	d.GetWearFunc = func() (*resource.Wear, error) {
+		return &resource.Wear{
+			RetiredPages: counter(retiredPages),
+			RemappedRows: counter(remappedRows),
+		}, nil
+	}
+	return d
+}
+
+func TestWearLabeler(t *testing.T) {
+	notSupported := rt.NewDeviceMock(false)
+	notSupported.GetWearFunc = func() (*resource.Wear, error) {
+		return nil, resource.ErrNotSupported
+	}
+
+	testCases := []struct {
+		description    string
+		devices        []resource.Device
+		wear           *spec.WearHealth
+		expectedLabels Labels
+	}{
+		{
+			description: "wear labels disabled",
+			devices:     []resource.Device{newWearDevice(10, 10)},
+		},
+		{
+			description: "highest counts of all GPUs are labeled",
+			devices:     []resource.Device{newWearDevice(2, 0), newWearDevice(1, 3)},
+			wear:        &spec.WearHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.wear":               "normal",
+				"nvidia.com/gpu.wear.retired-pages": "2",
+				"nvidia.com/gpu.wear.remapped-rows": "3",
+			},
+		},
+		{
+			description: "unsupported counters are omitted",
+			devices:     []resource.Device{newWearDevice(-1, 4), notSupported},
+			wear:        &spec.WearHealth{},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.wear":               "normal",
+				"nvidia.com/gpu.wear.remapped-rows": "4",
+			},
+		},
+		{
+			description: "counts above a threshold are worn",
+			devices:     []resource.Device{newWearDevice(0, 0), newWearDevice(0, 9)},
+			wear:        &spec.WearHealth{MaxRetiredPages: 5, MaxRemappedRows: 8},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.wear":               "worn",
+				"nvidia.com/gpu.wear.retired-pages": "0",
+				"nvidia.com/gpu.wear.remapped-rows": "9",
+			},
+		},
+		{
+			description: "no labels without supported counters",
+			devices:     []resource.Device{notSupported},
+			wear:        &spec.WearHealth{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := &spec.Config{Health: &spec.Health{Wear: tc.wear}}
+			l, err := newWearLabeler(rt.NewManagerMockWithDevices(tc.devices...), config)
+			require.NoError(t, err)
+			labels, err := l.Labels()
+			require.NoError(t, err)
+			if tc.expectedLabels == nil {
+				require.Empty(t, labels)
+				return
+			}
+			require.EqualValues(t, tc.expectedLabels, labels)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package location maps devices to their physical location in the data
+// center as described by a node-local file.
+package location
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Location is the physical location of a device.
+type Location struct {
+	Rack    string `json:"rack,omitempty"`
+	Chassis string `json:"chassis,omitempty"`
+	Slot    string `json:"slot,omitempty"`
+}
+
+// Map maps the UUIDs of devices to their physical location.
+type Map map[string]Location
+
+// Load reads the map from the specified YAML or JSON file. A nil map is
+// returned if the path is empty.
+func Load(path string) (Map, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device location file: %w", err)
+	}
+	var m Map
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse device location file %v: %w", path, err)
+	}
+	for uuid, l := range m {
+		if l.IsEmpty() {
+			return nil, fmt.Errorf("no location specified for device %v in %v", uuid, path)
+		}
+	}
+	return m, nil
+}
+
+// UnmarshalJSON unmarshals raw bytes into a 'Location' struct. Numeric values
+// are accepted for all fields, so that e.g. slots do not have to be quoted.
+func (l *Location) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for key, raw := range fields {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			var number json.Number
+			if err := json.Unmarshal(raw, &number); err != nil {
+				return fmt.Errorf("invalid value for %v: %s", key, raw)
+			}
+			value = number.String()
+		}
+		switch key {
+		case "rack":
+			l.Rack = value
+		case "chassis":
+			l.Chassis = value
+		case "slot":
+			l.Slot = value
+		default:
+			return fmt.Errorf("unknown field %q", key)
+		}
+	}
+	return nil
+}
+
+// Get returns the location of the device with the specified UUID, or nil if
+// the location of the device is unknown.
+func (m Map) Get(uuid string) *Location {
+	l, exists := m[uuid]
+	if !exists {
+		return nil
+	}
+	return &l
+}
+
+// IsEmpty checks whether none of the fields of the location are set.
+func (l Location) IsEmpty() bool {
+	return l == Location{}
+}
+
+// String returns the location as a comma-separated list of key=value pairs,
+// e.g. rack=r12,chassis=c3,slot=4.
+func (l *Location) String() string {
+	if l == nil {
+		return ""
+	}
+	var parts []string
+	if l.Rack != "" {
+		parts = append(parts, "rack="+l.Rack)
+	}
+	if l.Chassis != "" {
+		parts = append(parts, "chassis="+l.Chassis)
+	}
+	if l.Slot != "" {
+		parts = append(parts, "slot="+l.Slot)
+	}
+	return strings.Join(parts, ",")
+}
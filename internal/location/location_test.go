@@ -0,0 +1,97 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package location
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		description   string
+		contents      string
+		expected      Map
+		expectedError bool
+	}{
+		{
+			description: "YAML file",
+			contents: `GPU-0:
+  rack: r12
+  chassis: c3
+  slot: 4
+GPU-1:
+  rack: r12
+  slot: "5"
+`,
+			expected: Map{
+				"GPU-0": {Rack: "r12", Chassis: "c3", Slot: "4"},
+				"GPU-1": {Rack: "r12", Slot: "5"},
+			},
+		},
+		{
+			description: "JSON file",
+			contents:    `{"GPU-0": {"rack": "r12"}}`,
+			expected:    Map{"GPU-0": {Rack: "r12"}},
+		},
+		{
+			description:   "unknown field",
+			contents:      `{"GPU-0": {"row": "r12"}}`,
+			expectedError: true,
+		},
+		{
+			description:   "empty location",
+			contents:      `{"GPU-0": {}}`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "locations.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.contents), 0600))
+
+			m, err := Load(path)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, m)
+		})
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	m, err := Load("")
+	require.NoError(t, err)
+	require.Nil(t, m)
+	require.Nil(t, m.Get("GPU-0"))
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestLocationString(t *testing.T) {
+	var unknown *Location
+	require.Equal(t, "", unknown.String())
+	require.Equal(t, "rack=r12,slot=4", (&Location{Rack: "r12", Slot: "4"}).String())
+	require.Equal(t, "rack=r12,chassis=c3,slot=4", Map{"GPU-0": {Rack: "r12", Chassis: "c3", Slot: "4"}}.Get("GPU-0").String())
+}
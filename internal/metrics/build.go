@@ -0,0 +1,76 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BuildInfo exposes the version of the plugin, the enabled feature gates and
+// the hash of the effective config as a Prometheus info metric, so that
+// version skew across nodes is visible in dashboards.
+type BuildInfo struct {
+	version   string
+	gitCommit string
+
+	sync.Mutex
+	featureGates string
+	configHash   string
+
+	info *prometheus.Desc
+}
+
+// NewBuildInfo creates a build info collector for metrics with the specified
+// namespace.
+func NewBuildInfo(namespace string, version string, gitCommit string) *BuildInfo {
+	return &BuildInfo{
+		version:   version,
+		gitCommit: gitCommit,
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "build_info"),
+			"Build and config information of the plugin. The value is always 1.",
+			[]string{"version", "git_commit", "feature_gates", "config_hash"}, nil,
+		),
+	}
+}
+
+// Update sets the enabled feature gates and the hash of the effective config.
+// This is called every time the plugins are (re)started.
+func (b *BuildInfo) Update(featureGates []string, configHash string) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.featureGates = strings.Join(featureGates, ",")
+	b.configHash = configHash
+}
+
+// Describe implements prometheus.Collector.
+func (b *BuildInfo) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.info
+}
+
+// Collect implements prometheus.Collector.
+func (b *BuildInfo) Collect(ch chan<- prometheus.Metric) {
+	b.Lock()
+	defer b.Unlock()
+	ch <- prometheus.MustNewConstMetric(b.info, prometheus.GaugeValue, 1, b.version, b.gitCommit, b.featureGates, b.configHash)
+}
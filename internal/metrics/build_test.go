@@ -0,0 +1,47 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInfo(t *testing.T) {
+	(*BuildInfo)(nil).Update([]string{"ClockBoost"}, "0123456789abcdef")
+
+	info := NewBuildInfo("test", "v0.17.0", "abc123")
+	s := NewServer("localhost:0")
+	require.NoError(t, s.Register(info))
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	require.Contains(t, scrape(), `test_build_info{config_hash="",feature_gates="",git_commit="abc123",version="v0.17.0"} 1`)
+
+	info.Update([]string{"ClockBoost", "Other"}, "0123456789abcdef")
+	body := scrape()
+	require.Contains(t, body, `test_build_info{config_hash="0123456789abcdef",feature_gates="ClockBoost,Other",git_commit="abc123",version="v0.17.0"} 1`)
+	require.NotContains(t, body, `config_hash=""`)
+}
@@ -0,0 +1,70 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// ConfigTracker exposes the sources that the values of the effective config
+// were read from as Prometheus metrics.
+type ConfigTracker struct {
+	sync.Mutex
+	provenance spec.Provenance
+
+	valueSource *prometheus.Desc
+}
+
+// NewConfigTracker creates a config tracker for metrics with the specified namespace.
+func NewConfigTracker(namespace string) *ConfigTracker {
+	return &ConfigTracker{
+		valueSource: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "config_value_source"),
+			"Source of a value of the effective config that is not set to its default.",
+			[]string{"key", "source"}, nil,
+		),
+	}
+}
+
+// Update sets the provenance of the effective config.
+func (t *ConfigTracker) Update(provenance spec.Provenance) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.provenance = provenance
+}
+
+// Describe implements prometheus.Collector.
+func (t *ConfigTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.valueSource
+}
+
+// Collect implements prometheus.Collector.
+func (t *ConfigTracker) Collect(ch chan<- prometheus.Metric) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, key := range sortedKeys(t.provenance) {
+		ch <- prometheus.MustNewConstMetric(t.valueSource, prometheus.GaugeValue, 1, key, t.provenance[key])
+	}
+}
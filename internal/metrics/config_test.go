@@ -0,0 +1,49 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestConfigTracker(t *testing.T) {
+	(*ConfigTracker)(nil).Update(spec.Provenance{"--mig-strategy": spec.SourceEnv})
+
+	tracker := NewConfigTracker("test")
+	tracker.Update(spec.Provenance{"--mig-strategy": spec.SourceFlag})
+	tracker.Update(spec.Provenance{
+		"--mig-strategy": spec.SourceEnv,
+		"sharing.timeSlicing.resources[0].replicas": spec.SourceCRD,
+	})
+
+	s := NewServer("localhost:0")
+	require.NoError(t, s.Register(tracker))
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Contains(t, w.Body.String(), `test_config_value_source{key="--mig-strategy",source="env"} 1`)
+	require.Contains(t, w.Body.String(), `test_config_value_source{key="sharing.timeSlicing.resources[0].replicas",source="crd"} 1`)
+	require.NotContains(t, w.Body.String(), `source="flag"`)
+}
@@ -0,0 +1,234 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HealthTracker tracks the health of the devices advertised to the kubelet
+// and the resulting capacity of each resource, and exposes the transitions
+// between states as Prometheus metrics.
+//
+// The time of each transition is captured using time.Now, which includes both
+// a wall-clock and a monotonic clock reading. Durations are always computed
+// from the monotonic readings so that they are not affected when the clock of
+// the node is stepped (e.g. by NTP). The wall-clock readings are only exposed
+// as the timestamps of the transitions.
+type HealthTracker struct {
+	sync.Mutex
+	now       func() time.Time
+	resources map[string]*resourceHealth
+
+	transitions             *prometheus.Desc
+	unhealthySeconds        *prometheus.Desc
+	stateSeconds            *prometheus.Desc
+	lastTransitionTimestamp *prometheus.Desc
+	devices                 *prometheus.Desc
+	capacity                *prometheus.Desc
+	capacityTransitions     *prometheus.Desc
+	capacityStateSeconds    *prometheus.Desc
+	capacityLastTransition  *prometheus.Desc
+}
+
+type resourceHealth struct {
+	devices     map[string]*deviceHealth
+	capacity    int
+	since       time.Time
+	transitions uint64
+}
+
+type deviceHealth struct {
+	healthy     bool
+	since       time.Time
+	toHealthy   uint64
+	toUnhealthy uint64
+	// unhealthy is the accumulated duration of the completed unhealthy periods.
+	unhealthy time.Duration
+}
+
+// NewHealthTracker creates a health tracker for metrics with the specified namespace.
+func NewHealthTracker(namespace string) *HealthTracker {
+	deviceLabels := []string{"resource", "device"}
+	return &HealthTracker{
+		now:       time.Now,
+		resources: make(map[string]*resourceHealth),
+		transitions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_health_transitions_total"),
+			"Number of transitions of a device to the specified health.",
+			append(deviceLabels, "health"), nil,
+		),
+		unhealthySeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_unhealthy_seconds_total"),
+			"Total time a device was advertised as unhealthy.",
+			deviceLabels, nil,
+		),
+		stateSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_health_state_seconds"),
+			"Time since the last health transition of a device.",
+			append(deviceLabels, "health"), nil,
+		),
+		lastTransitionTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_health_last_transition_timestamp_seconds"),
+			"Wall-clock time of the last health transition of a device.",
+			deviceLabels, nil,
+		),
+		devices: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "resource_devices"),
+			"Number of devices advertised for a resource with the specified health.",
+			[]string{"resource", "health"}, nil,
+		),
+		capacity: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "resource_capacity"),
+			"Number of healthy devices advertised for a resource.",
+			[]string{"resource"}, nil,
+		),
+		capacityTransitions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "resource_capacity_transitions_total"),
+			"Number of changes of the capacity of a resource.",
+			[]string{"resource"}, nil,
+		),
+		capacityStateSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "resource_capacity_state_seconds"),
+			"Time since the last capacity change of a resource.",
+			[]string{"resource"}, nil,
+		),
+		capacityLastTransition: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "resource_capacity_last_transition_timestamp_seconds"),
+			"Wall-clock time of the last capacity change of a resource.",
+			[]string{"resource"}, nil,
+		),
+	}
+}
+
+// RecordHealth records the health of the devices advertised for a resource.
+// Devices that are no longer advertised are no longer tracked.
+func (t *HealthTracker) RecordHealth(resource string, devices map[string]bool) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	now := t.now()
+	r, exists := t.resources[resource]
+	if !exists {
+		r = &resourceHealth{
+			devices: make(map[string]*deviceHealth),
+			since:   now,
+		}
+		t.resources[resource] = r
+	}
+
+	capacity := 0
+	for id, healthy := range devices {
+		if healthy {
+			capacity++
+		}
+		d, exists := r.devices[id]
+		if !exists {
+			r.devices[id] = &deviceHealth{healthy: healthy, since: now}
+			continue
+		}
+		if d.healthy == healthy {
+			continue
+		}
+		if healthy {
+			d.unhealthy += now.Sub(d.since)
+			d.toHealthy++
+		} else {
+			d.toUnhealthy++
+		}
+		d.healthy = healthy
+		d.since = now
+	}
+	for id := range r.devices {
+		if _, exists := devices[id]; !exists {
+			delete(r.devices, id)
+		}
+	}
+
+	if exists && capacity != r.capacity {
+		r.transitions++
+		r.since = now
+	}
+	r.capacity = capacity
+}
+
+// Describe implements prometheus.Collector.
+func (t *HealthTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.transitions
+	ch <- t.unhealthySeconds
+	ch <- t.stateSeconds
+	ch <- t.lastTransitionTimestamp
+	ch <- t.devices
+	ch <- t.capacity
+	ch <- t.capacityTransitions
+	ch <- t.capacityStateSeconds
+	ch <- t.capacityLastTransition
+}
+
+// Collect implements prometheus.Collector.
+func (t *HealthTracker) Collect(ch chan<- prometheus.Metric) {
+	t.Lock()
+	defer t.Unlock()
+
+	now := t.now()
+	for _, resource := range sortedKeys(t.resources) {
+		r := t.resources[resource]
+		ch <- prometheus.MustNewConstMetric(t.devices, prometheus.GaugeValue, float64(r.capacity), resource, "Healthy")
+		ch <- prometheus.MustNewConstMetric(t.devices, prometheus.GaugeValue, float64(len(r.devices)-r.capacity), resource, "Unhealthy")
+		ch <- prometheus.MustNewConstMetric(t.capacity, prometheus.GaugeValue, float64(r.capacity), resource)
+		ch <- prometheus.MustNewConstMetric(t.capacityTransitions, prometheus.CounterValue, float64(r.transitions), resource)
+		ch <- prometheus.MustNewConstMetric(t.capacityStateSeconds, prometheus.GaugeValue, now.Sub(r.since).Seconds(), resource)
+		ch <- prometheus.MustNewConstMetric(t.capacityLastTransition, prometheus.GaugeValue, unixSeconds(r.since), resource)
+
+		for _, id := range sortedKeys(r.devices) {
+			d := r.devices[id]
+			unhealthy := d.unhealthy
+			health := "Healthy"
+			if !d.healthy {
+				unhealthy += now.Sub(d.since)
+				health = "Unhealthy"
+			}
+			ch <- prometheus.MustNewConstMetric(t.transitions, prometheus.CounterValue, float64(d.toHealthy), resource, id, "Healthy")
+			ch <- prometheus.MustNewConstMetric(t.transitions, prometheus.CounterValue, float64(d.toUnhealthy), resource, id, "Unhealthy")
+			ch <- prometheus.MustNewConstMetric(t.unhealthySeconds, prometheus.CounterValue, unhealthy.Seconds(), resource, id)
+			ch <- prometheus.MustNewConstMetric(t.stateSeconds, prometheus.GaugeValue, now.Sub(d.since).Seconds(), resource, id, health)
+			ch <- prometheus.MustNewConstMetric(t.lastTransitionTimestamp, prometheus.GaugeValue, unixSeconds(d.since), resource, id)
+		}
+	}
+}
+
+// unixSeconds returns the wall-clock reading of the specified time as seconds
+// since the epoch.
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,67 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthTracker(t *testing.T) {
+	(*HealthTracker)(nil).RecordHealth("nvidia.com/gpu", map[string]bool{"GPU-0": true})
+
+	start := time.Now()
+	now := start
+	tracker := NewHealthTracker("test")
+	tracker.now = func() time.Time { return now }
+
+	tracker.RecordHealth("nvidia.com/gpu", map[string]bool{"GPU-0": true, "GPU-1": true})
+	now = start.Add(10 * time.Second)
+	tracker.RecordHealth("nvidia.com/gpu", map[string]bool{"GPU-0": false, "GPU-1": true})
+	now = start.Add(40 * time.Second)
+	tracker.RecordHealth("nvidia.com/gpu", map[string]bool{"GPU-0": true, "GPU-1": true})
+	now = start.Add(50 * time.Second)
+	tracker.RecordHealth("nvidia.com/gpu", map[string]bool{"GPU-0": false})
+	now = start.Add(60 * time.Second)
+
+	s := NewServer("localhost:0")
+	require.NoError(t, s.Register(tracker))
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	expected := []string{
+		`test_device_health_transitions_total{device="GPU-0",health="Healthy",resource="nvidia.com/gpu"} 1`,
+		`test_device_health_transitions_total{device="GPU-0",health="Unhealthy",resource="nvidia.com/gpu"} 2`,
+		`test_device_unhealthy_seconds_total{device="GPU-0",resource="nvidia.com/gpu"} 40`,
+		`test_device_health_state_seconds{device="GPU-0",health="Unhealthy",resource="nvidia.com/gpu"} 10`,
+		`test_resource_devices{health="Healthy",resource="nvidia.com/gpu"} 0`,
+		`test_resource_devices{health="Unhealthy",resource="nvidia.com/gpu"} 1`,
+		`test_resource_capacity{resource="nvidia.com/gpu"} 0`,
+		`test_resource_capacity_transitions_total{resource="nvidia.com/gpu"} 3`,
+		`test_resource_capacity_state_seconds{resource="nvidia.com/gpu"} 10`,
+	}
+	for _, e := range expected {
+		require.Contains(t, w.Body.String(), e)
+	}
+	require.NotContains(t, w.Body.String(), `device="GPU-1"`)
+}
@@ -0,0 +1,187 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// allocateBuckets are the upper bounds in seconds of the buckets of the
+// Allocate latency histogram. Allocate calls block the creation of the
+// containers of a pod, so the buckets range from milliseconds to the timeout
+// of the kubelet.
+var allocateBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// PluginTracker tracks the interactions of the plugins with the kubelet and
+// the replicas that they advertise, and exposes them as Prometheus metrics.
+type PluginTracker struct {
+	sync.Mutex
+	allocations   map[string]*allocations
+	registrations map[string]*registrations
+	replicas      map[string]replicas
+
+	allocateSeconds      *prometheus.Desc
+	allocateFailures     *prometheus.Desc
+	registrationsTotal   *prometheus.Desc
+	reregistrationsTotal *prometheus.Desc
+	replicasAdvertised   *prometheus.Desc
+}
+
+type allocations struct {
+	count    uint64
+	sum      float64
+	buckets  map[float64]uint64
+	failures uint64
+}
+
+type registrations struct {
+	registrations   uint64
+	reregistrations uint64
+}
+
+type replicas struct {
+	strategy string
+	replicas int
+}
+
+// NewPluginTracker creates a plugin tracker for metrics with the specified namespace.
+func NewPluginTracker(namespace string) *PluginTracker {
+	return &PluginTracker{
+		allocations:   make(map[string]*allocations),
+		registrations: make(map[string]*registrations),
+		replicas:      make(map[string]replicas),
+		allocateSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "allocate_duration_seconds"),
+			"Latency of the Allocate calls of the kubelet for a resource.",
+			[]string{"resource"}, nil,
+		),
+		allocateFailures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "allocate_failures_total"),
+			"Number of Allocate calls of the kubelet for a resource that failed.",
+			[]string{"resource"}, nil,
+		),
+		registrationsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "kubelet_registrations_total"),
+			"Number of times the plugin for a resource registered with the kubelet when it was started.",
+			[]string{"resource"}, nil,
+		),
+		reregistrationsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "kubelet_reregistrations_total"),
+			"Number of times the plugin for a resource re-registered with the kubelet after its stream was reset.",
+			[]string{"resource"}, nil,
+		),
+		replicasAdvertised: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "resource_replicas"),
+			"Number of replicas advertised for a resource that is shared.",
+			[]string{"resource", "strategy"}, nil,
+		),
+	}
+}
+
+// RecordAllocate records the duration and the result of an Allocate call.
+func (t *PluginTracker) RecordAllocate(resource string, duration time.Duration, err error) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	a, exists := t.allocations[resource]
+	if !exists {
+		a = &allocations{buckets: make(map[float64]uint64, len(allocateBuckets))}
+		for _, upper := range allocateBuckets {
+			a.buckets[upper] = 0
+		}
+		t.allocations[resource] = a
+	}
+	seconds := duration.Seconds()
+	a.count++
+	a.sum += seconds
+	for _, upper := range allocateBuckets {
+		if seconds <= upper {
+			a.buckets[upper]++
+		}
+	}
+	if err != nil {
+		a.failures++
+	}
+}
+
+// RecordRegistration records a registration of the plugin for a resource with
+// the kubelet.
+func (t *PluginTracker) RecordRegistration(resource string, reregistration bool) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	r, exists := t.registrations[resource]
+	if !exists {
+		r = &registrations{}
+		t.registrations[resource] = r
+	}
+	if reregistration {
+		r.reregistrations++
+	} else {
+		r.registrations++
+	}
+}
+
+// RecordReplicas records the number of replicas advertised for a resource that
+// is shared with the specified strategy.
+func (t *PluginTracker) RecordReplicas(resource string, strategy string, count int) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.replicas[resource] = replicas{strategy: strategy, replicas: count}
+}
+
+// Describe implements prometheus.Collector.
+func (t *PluginTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.allocateSeconds
+	ch <- t.allocateFailures
+	ch <- t.registrationsTotal
+	ch <- t.reregistrationsTotal
+	ch <- t.replicasAdvertised
+}
+
+// Collect implements prometheus.Collector.
+func (t *PluginTracker) Collect(ch chan<- prometheus.Metric) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, resource := range sortedKeys(t.allocations) {
+		a := t.allocations[resource]
+		ch <- prometheus.MustNewConstHistogram(t.allocateSeconds, a.count, a.sum, a.buckets, resource)
+		ch <- prometheus.MustNewConstMetric(t.allocateFailures, prometheus.CounterValue, float64(a.failures), resource)
+	}
+	for _, resource := range sortedKeys(t.registrations) {
+		r := t.registrations[resource]
+		ch <- prometheus.MustNewConstMetric(t.registrationsTotal, prometheus.CounterValue, float64(r.registrations), resource)
+		ch <- prometheus.MustNewConstMetric(t.reregistrationsTotal, prometheus.CounterValue, float64(r.reregistrations), resource)
+	}
+	for _, resource := range sortedKeys(t.replicas) {
+		r := t.replicas[resource]
+		ch <- prometheus.MustNewConstMetric(t.replicasAdvertised, prometheus.GaugeValue, float64(r.replicas), resource, r.strategy)
+	}
+}
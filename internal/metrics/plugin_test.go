@@ -0,0 +1,65 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginTracker(t *testing.T) {
+	(*PluginTracker)(nil).RecordAllocate("nvidia.com/gpu", time.Second, nil)
+	(*PluginTracker)(nil).RecordRegistration("nvidia.com/gpu", false)
+	(*PluginTracker)(nil).RecordReplicas("nvidia.com/gpu", "time-slicing", 4)
+
+	tracker := NewPluginTracker("test")
+	tracker.RecordAllocate("nvidia.com/gpu", 20*time.Millisecond, nil)
+	tracker.RecordAllocate("nvidia.com/gpu", 2*time.Second, errors.New("failed"))
+	tracker.RecordRegistration("nvidia.com/gpu", false)
+	tracker.RecordRegistration("nvidia.com/gpu", true)
+	tracker.RecordRegistration("nvidia.com/gpu", true)
+	tracker.RecordReplicas("nvidia.com/gpu", "time-slicing", 4)
+	tracker.RecordReplicas("nvidia.com/gpu", "mps", 8)
+
+	s := NewServer("localhost:0")
+	require.NoError(t, s.Register(tracker))
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	expected := []string{
+		`test_allocate_duration_seconds_bucket{resource="nvidia.com/gpu",le="0.01"} 0`,
+		`test_allocate_duration_seconds_bucket{resource="nvidia.com/gpu",le="0.05"} 1`,
+		`test_allocate_duration_seconds_bucket{resource="nvidia.com/gpu",le="2.5"} 2`,
+		`test_allocate_duration_seconds_bucket{resource="nvidia.com/gpu",le="+Inf"} 2`,
+		`test_allocate_duration_seconds_count{resource="nvidia.com/gpu"} 2`,
+		`test_allocate_failures_total{resource="nvidia.com/gpu"} 1`,
+		`test_kubelet_registrations_total{resource="nvidia.com/gpu"} 1`,
+		`test_kubelet_reregistrations_total{resource="nvidia.com/gpu"} 2`,
+		`test_resource_replicas{resource="nvidia.com/gpu",strategy="mps"} 8`,
+	}
+	for _, e := range expected {
+		require.Contains(t, w.Body.String(), e)
+	}
+	require.NotContains(t, w.Body.String(), `strategy="time-slicing"`)
+}
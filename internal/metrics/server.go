@@ -0,0 +1,92 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+)
+
+// Server serves Prometheus metrics over HTTP.
+type Server struct {
+	address  string
+	registry *prometheus.Registry
+}
+
+// NewServer creates a metrics server that listens on the specified address.
+// A nil server is returned if the address is empty.
+func NewServer(address string) *Server {
+	if address == "" {
+		return nil
+	}
+	return &Server{
+		address:  address,
+		registry: prometheus.NewRegistry(),
+	}
+}
+
+// Register registers the specified collectors with the server.
+func (s *Server) Register(collectors ...prometheus.Collector) error {
+	if s == nil {
+		return nil
+	}
+	for _, c := range collectors {
+		if err := s.registry.Register(c); err != nil {
+			return fmt.Errorf("failed to register collector: %w", err)
+		}
+	}
+	return nil
+}
+
+// Handler returns the handler that serves the registered metrics.
+func (s *Server) Handler() http.Handler {
+	return promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})
+}
+
+// ListenAndServe serves the metrics on /metrics until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", s.address, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", s.Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	klog.Infof("Serving metrics on %v", s.address)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
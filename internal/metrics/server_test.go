@@ -0,0 +1,42 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	require.Nil(t, NewServer(""))
+	require.NoError(t, (*Server)(nil).Register(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"})))
+
+	s := NewServer("localhost:0")
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "test", Name: "value"})
+	gauge.Set(42)
+	require.NoError(t, s.Register(gauge))
+	require.Error(t, s.Register(gauge))
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "test_value 42")
+}
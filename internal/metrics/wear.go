@@ -0,0 +1,147 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// WearSource is a source of the devices whose wear counters are exposed.
+type WearSource interface {
+	Devices() rm.Devices
+}
+
+// WearCollector exposes the counters that indicate the age and wear of the
+// GPUs of the running plugins as Prometheus metrics. The counters are queried
+// through the NVML facade each time the metrics are collected, so that they
+// are queried by the NVML broker if it is used. Counters that are not
+// supported by a GPU are omitted.
+type WearCollector struct {
+	nvcaps nvcaps.Interface
+
+	sync.Mutex
+	uuids []string
+
+	retiredPages *prometheus.Desc
+	remappedRows *prometheus.Desc
+	energy       *prometheus.Desc
+}
+
+// NewWearCollector creates a wear collector for metrics with the specified
+// namespace that queries the wear counters using the specified NVML facade.
+func NewWearCollector(namespace string, nvcapslib nvcaps.Interface) *WearCollector {
+	labels := []string{"device"}
+	return &WearCollector{
+		nvcaps: nvcapslib,
+		retiredPages: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "gpu_retired_pages"),
+			"Number of memory pages of a GPU retired due to single-bit and double-bit ECC errors.",
+			labels, nil,
+		),
+		remappedRows: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "gpu_remapped_rows"),
+			"Number of memory rows of a GPU remapped due to correctable and uncorrectable errors.",
+			labels, nil,
+		),
+		energy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "gpu_energy_consumption_joules_total"),
+			"Total energy consumed by a GPU since the driver was last loaded.",
+			labels, nil,
+		),
+	}
+}
+
+// Update sets the sources of the GPUs whose wear counters are exposed. MIG
+// devices are skipped, and the GPUs of shared resources are only exposed once.
+func (c *WearCollector) Update(sources []WearSource) {
+	if c == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	var uuids []string
+	for _, s := range sources {
+		for _, d := range s.Devices() {
+			if d.IsMigDevice() {
+				continue
+			}
+			uuid := d.GetUUID()
+			if seen[uuid] {
+				continue
+			}
+			seen[uuid] = true
+			uuids = append(uuids, uuid)
+		}
+	}
+	sort.Strings(uuids)
+
+	c.Lock()
+	defer c.Unlock()
+	c.uuids = uuids
+}
+
+// Describe implements prometheus.Collector.
+func (c *WearCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.retiredPages
+	ch <- c.remappedRows
+	ch <- c.energy
+}
+
+// Collect implements prometheus.Collector.
+func (c *WearCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	uuids := c.uuids
+	c.Unlock()
+	if len(uuids) == 0 {
+		return
+	}
+
+	if err := c.nvcaps.Init(); err != nil {
+		klog.V(4).Infof("Skipping GPU wear metrics: %v", err)
+		return
+	}
+	defer func() {
+		_ = c.nvcaps.Shutdown()
+	}()
+
+	for _, uuid := range uuids {
+		wear, err := c.nvcaps.GetWear(uuid)
+		if errors.Is(err, nvcaps.ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			klog.Warningf("Failed to get wear counters of %v: %v", uuid, err)
+			continue
+		}
+		if wear.RetiredPages != nil {
+			ch <- prometheus.MustNewConstMetric(c.retiredPages, prometheus.GaugeValue, float64(*wear.RetiredPages), uuid)
+		}
+		if wear.RemappedRows != nil {
+			ch <- prometheus.MustNewConstMetric(c.remappedRows, prometheus.GaugeValue, float64(*wear.RemappedRows), uuid)
+		}
+		if wear.EnergyMillijoules != nil {
+			ch <- prometheus.MustNewConstMetric(c.energy, prometheus.CounterValue, float64(*wear.EnergyMillijoules)/1000, uuid)
+		}
+	}
+}
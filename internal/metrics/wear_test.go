@@ -0,0 +1,93 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type wearSource rm.Devices
+
+func (s wearSource) Devices() rm.Devices {
+	return rm.Devices(s)
+}
+
+func TestWearCollector(t *testing.T) {
+	retiredPages, remappedRows, energy := uint64(3), uint64(1), uint64(7500)
+	lib := &nvcaps.InterfaceMock{
+		InitFunc:     func() error { return nil },
+		ShutdownFunc: func() error { return nil },
+		GetWearFunc: func(uuid string) (nvcaps.Wear, error) {
+			switch uuid {
+			case "GPU-0":
+				return nvcaps.Wear{RetiredPages: &retiredPages, RemappedRows: &remappedRows, EnergyMillijoules: &energy}, nil
+			case "GPU-1":
+				return nvcaps.Wear{EnergyMillijoules: &energy}, nil
+			}
+			return nvcaps.Wear{}, nvcaps.ErrNotSupported
+		},
+	}
+
+	c := NewWearCollector("test", lib)
+	s := NewServer("localhost:0")
+	require.NoError(t, s.Register(c))
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	// NVML is not used before the devices of the plugins are known.
+	require.NotContains(t, scrape(), "test_gpu_")
+	require.Empty(t, lib.InitCalls())
+
+	c.Update([]WearSource{
+		wearSource{
+			"GPU-0::0": {Device: pluginapi.Device{ID: "GPU-0::0"}},
+			"GPU-0::1": {Device: pluginapi.Device{ID: "GPU-0::1"}},
+			"GPU-1":    {Device: pluginapi.Device{ID: "GPU-1"}},
+		},
+		wearSource{
+			"GPU-2": {Device: pluginapi.Device{ID: "GPU-2"}},
+			"MIG-0": {Device: pluginapi.Device{ID: "MIG-0"}, Index: "0:0"},
+		},
+	})
+
+	body := scrape()
+	expected := []string{
+		`test_gpu_retired_pages{device="GPU-0"} 3`,
+		`test_gpu_remapped_rows{device="GPU-0"} 1`,
+		`test_gpu_energy_consumption_joules_total{device="GPU-0"} 7.5`,
+		`test_gpu_energy_consumption_joules_total{device="GPU-1"} 7.5`,
+	}
+	for _, e := range expected {
+		require.Contains(t, body, e)
+	}
+	require.NotContains(t, body, `test_gpu_retired_pages{device="GPU-1"}`)
+	require.NotContains(t, body, `device="GPU-2"`)
+	require.Len(t, lib.GetWearCalls(), 3)
+}
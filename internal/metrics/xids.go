@@ -0,0 +1,252 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// DefaultXidHistorySize is the number of Xid events that are kept per device
+// if no size is configured.
+const DefaultXidHistorySize = 50
+
+// xidPodLookupTimeout bounds the time spent attributing an Xid event to pods.
+const xidPodLookupTimeout = time.Second
+
+// xidAttributionQueueSize is the number of Xid events that can wait to be
+// attributed to pods. Events are not attributed if the queue is full.
+const xidAttributionQueueSize = 100
+
+// PodDevicesLister defines the API used to attribute Xid events to the pods
+// that the devices are allocated to.
+type PodDevicesLister interface {
+	AllocatedPodDevices(ctx context.Context, resource string) ([]podresources.PodDevices, error)
+}
+
+// XidEvent is an Xid event that was recorded for a device.
+type XidEvent struct {
+	Xid       uint64    `json:"xid"`
+	Timestamp time.Time `json:"timestamp"`
+	Resource  string    `json:"resource"`
+	// Pods are the pods, as <namespace>/<name>, that the device was
+	// allocated to when the event was recorded.
+	Pods []string `json:"pods,omitempty"`
+}
+
+// DeviceXids is the Xid history of a device.
+type DeviceXids struct {
+	// Counts holds the number of events recorded for each Xid. Unlike the
+	// events, the counts are not bounded by the size of the history.
+	Counts map[uint64]uint64 `json:"counts"`
+	// Events holds the most recent events, the oldest first.
+	Events []XidEvent `json:"events"`
+}
+
+// XidHistory keeps a bounded history of the Xid events of each device and
+// exposes the number of events per Xid and the time of the last event as
+// Prometheus metrics. It receives the events as a health event reporter.
+// Events are recorded immediately and attributed to pods by Run, so that the
+// health checks are never blocked by the PodResources API.
+type XidHistory struct {
+	sync.Mutex
+	size    int
+	pods    PodDevicesLister
+	devices map[string]*deviceXids
+	pending chan xidAttribution
+
+	total *prometheus.Desc
+	last  *prometheus.Desc
+}
+
+// deviceXids is the Xid history of a device. The events are referenced so
+// that they can be attributed to pods after they are recorded.
+type deviceXids struct {
+	counts map[uint64]uint64
+	events []*XidEvent
+}
+
+// xidAttribution is an Xid event of a device that waits to be attributed to
+// pods.
+type xidAttribution struct {
+	deviceID string
+	event    *XidEvent
+}
+
+var _ rm.HealthEventReporter = (*XidHistory)(nil)
+
+// NewXidHistory creates an Xid history for metrics with the specified
+// namespace that keeps up to size events per device. Events are attributed to
+// pods using the specified lister, if any. A nil history is returned if the
+// size is not positive.
+func NewXidHistory(namespace string, size int, pods PodDevicesLister) *XidHistory {
+	if size <= 0 {
+		return nil
+	}
+	labels := []string{"resource", "device"}
+	return &XidHistory{
+		size:    size,
+		pods:    pods,
+		devices: make(map[string]*deviceXids),
+		pending: make(chan xidAttribution, xidAttributionQueueSize),
+		total: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_xid_errors_total"),
+			"Number of Xid events recorded for a device.",
+			append(labels, "xid"), nil,
+		),
+		last: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_last_xid_timestamp_seconds"),
+			"Wall-clock time of the last Xid event recorded for a device.",
+			labels, nil,
+		),
+	}
+}
+
+// ReportHealthEvent records the specified health event if it is an Xid event.
+// Xids that do not mark the device as unhealthy, such as application errors,
+// are recorded as well. The event is queued to be attributed to pods.
+func (h *XidHistory) ReportHealthEvent(e rm.HealthEvent) {
+	if h == nil || e.Reason != rm.HealthEventReasonXid || e.Xid == 0 {
+		return
+	}
+	event := &XidEvent{
+		Xid:       e.Xid,
+		Timestamp: e.Timestamp,
+		Resource:  string(e.Resource),
+	}
+
+	h.Lock()
+	d, exists := h.devices[e.DeviceID]
+	if !exists {
+		d = &deviceXids{counts: make(map[uint64]uint64)}
+		h.devices[e.DeviceID] = d
+	}
+	d.counts[e.Xid]++
+	d.events = append(d.events, event)
+	if len(d.events) > h.size {
+		d.events = d.events[len(d.events)-h.size:]
+	}
+	h.Unlock()
+
+	if h.pods == nil {
+		return
+	}
+	select {
+	case h.pending <- xidAttribution{deviceID: e.DeviceID, event: event}:
+	default:
+		klog.Warningf("Not attributing Xid %d on device %v to pods: queue is full", e.Xid, e.DeviceID)
+	}
+}
+
+// Run attributes the recorded Xid events to the pods that their devices are
+// allocated to until the context is cancelled.
+func (h *XidHistory) Run(ctx context.Context) {
+	if h == nil || h.pods == nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-h.pending:
+			pods := h.podsWithDevice(ctx, a.event.Resource, a.deviceID)
+			h.Lock()
+			a.event.Pods = pods
+			h.Unlock()
+		}
+	}
+}
+
+// podsWithDevice returns the pods that the specified device is allocated to.
+// Replicas of the device are attributed to the device. Errors are logged and
+// result in an event without pods.
+func (h *XidHistory) podsWithDevice(ctx context.Context, resource string, id string) []string {
+	ctx, cancel := context.WithTimeout(ctx, xidPodLookupTimeout)
+	defer cancel()
+
+	allocated, err := h.pods.AllocatedPodDevices(ctx, resource)
+	if err != nil {
+		klog.Warningf("Failed to attribute Xid on device %v to pods: %v", id, err)
+		return nil
+	}
+	var pods []string
+	for _, pod := range allocated {
+		for _, allocatedID := range pod.DeviceIDs {
+			if rm.AnnotatedID(allocatedID).GetID() == id {
+				pods = append(pods, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+				break
+			}
+		}
+	}
+	return pods
+}
+
+// Devices returns a copy of the Xid history of each device, keyed by the ID
+// of the device.
+func (h *XidHistory) Devices() map[string]DeviceXids {
+	devices := make(map[string]DeviceXids)
+	if h == nil {
+		return devices
+	}
+	h.Lock()
+	defer h.Unlock()
+	for id, d := range h.devices {
+		counts := make(map[uint64]uint64, len(d.counts))
+		for xid, count := range d.counts {
+			counts[xid] = count
+		}
+		events := make([]XidEvent, 0, len(d.events))
+		for _, e := range d.events {
+			events = append(events, *e)
+		}
+		devices[id] = DeviceXids{
+			Counts: counts,
+			Events: events,
+		}
+	}
+	return devices
+}
+
+// Describe implements prometheus.Collector.
+func (h *XidHistory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.total
+	ch <- h.last
+}
+
+// Collect implements prometheus.Collector.
+func (h *XidHistory) Collect(ch chan<- prometheus.Metric) {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, id := range sortedKeys(h.devices) {
+		d := h.devices[id]
+		latest := d.events[len(d.events)-1]
+		for xid, count := range d.counts {
+			ch <- prometheus.MustNewConstMetric(h.total, prometheus.CounterValue, float64(count), latest.Resource, id, strconv.FormatUint(xid, 10))
+		}
+		ch <- prometheus.MustNewConstMetric(h.last, prometheus.GaugeValue, unixSeconds(latest.Timestamp), latest.Resource, id)
+	}
+}
@@ -0,0 +1,103 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testPodDevicesLister []podresources.PodDevices
+
+func (l testPodDevicesLister) AllocatedPodDevices(context.Context, string) ([]podresources.PodDevices, error) {
+	return l, nil
+}
+
+func TestXidHistory(t *testing.T) {
+	require.Nil(t, NewXidHistory("test", 0, nil))
+	(*XidHistory)(nil).ReportHealthEvent(rm.HealthEvent{Reason: rm.HealthEventReasonXid, Xid: 79})
+	require.Empty(t, (*XidHistory)(nil).Devices())
+
+	pods := testPodDevicesLister{
+		{Namespace: "default", Name: "train", DeviceIDs: []string{"GPU-0::1", "GPU-1::0"}},
+		{Namespace: "default", Name: "infer", DeviceIDs: []string{"GPU-1::1"}},
+	}
+	history := NewXidHistory("test", 2, pods)
+
+	start := time.Unix(1700000000, 0)
+	report := func(device string, xid uint64, offset time.Duration) {
+		history.ReportHealthEvent(rm.HealthEvent{
+			Timestamp: start.Add(offset),
+			Resource:  "nvidia.com/gpu",
+			DeviceID:  device,
+			Reason:    rm.HealthEventReasonXid,
+			Xid:       xid,
+		})
+	}
+	report("GPU-0", 79, 0)
+	report("GPU-0", 48, 10*time.Second)
+	report("GPU-0", 79, 20*time.Second)
+	report("GPU-1", 13, 30*time.Second)
+	history.ReportHealthEvent(rm.HealthEvent{DeviceID: "GPU-1", Reason: rm.HealthEventReasonSingleBitECC})
+
+	// The events are recorded before they are attributed to pods.
+	devices := history.Devices()
+	require.Equal(t, map[uint64]uint64{48: 1, 79: 2}, devices["GPU-0"].Counts)
+	require.Len(t, devices["GPU-0"].Events, 2)
+	require.Nil(t, devices["GPU-0"].Events[0].Pods)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go history.Run(ctx)
+	require.Eventually(t, func() bool {
+		devices = history.Devices()
+		return devices["GPU-1"].Events[0].Pods != nil
+	}, time.Second, time.Millisecond)
+	devices = history.Devices()
+	require.Equal(t, []XidEvent{
+		{Xid: 48, Timestamp: start.Add(10 * time.Second), Resource: "nvidia.com/gpu", Pods: []string{"default/train"}},
+		{Xid: 79, Timestamp: start.Add(20 * time.Second), Resource: "nvidia.com/gpu", Pods: []string{"default/train"}},
+	}, devices["GPU-0"].Events)
+	require.Equal(t, []XidEvent{
+		{Xid: 13, Timestamp: start.Add(30 * time.Second), Resource: "nvidia.com/gpu", Pods: []string{"default/train", "default/infer"}},
+	}, devices["GPU-1"].Events)
+
+	s := NewServer("localhost:0")
+	require.NoError(t, s.Register(history))
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	expected := []string{
+		`test_device_xid_errors_total{device="GPU-0",resource="nvidia.com/gpu",xid="48"} 1`,
+		`test_device_xid_errors_total{device="GPU-0",resource="nvidia.com/gpu",xid="79"} 2`,
+		`test_device_xid_errors_total{device="GPU-1",resource="nvidia.com/gpu",xid="13"} 1`,
+		`test_device_last_xid_timestamp_seconds{device="GPU-0",resource="nvidia.com/gpu"} 1.70000002e+09`,
+	}
+	for _, e := range expected {
+		require.Contains(t, w.Body.String(), e)
+	}
+}
@@ -0,0 +1,406 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mig
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"sigs.k8s.io/yaml"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// PlanProfile is a MIG profile that a GPU can be partitioned into.
+type PlanProfile struct {
+	// Name is the name of the profile, e.g. 1g.5gb.
+	Name string
+	// Slices is the number of compute slices used by an instance of the
+	// profile.
+	Slices int
+	// MemoryGB is the memory used by an instance of the profile.
+	MemoryGB int
+	// MaxInstances is the maximum number of instances of the profile on a
+	// GPU.
+	MaxInstances int
+	// Placements are the possible placements of an instance of the profile
+	// on the memory slices of a GPU. If no placements are known, the
+	// instances are only limited by the compute slices and the memory.
+	Placements []PlanPlacement
+}
+
+// PlanPlacement is a possible placement of a GPU instance, given as a range of
+// memory slices of the GPU.
+type PlanPlacement struct {
+	Start int
+	Size  int
+}
+
+// mask returns the memory slices of the placement as a bit mask.
+func (p PlanPlacement) mask() uint64 {
+	return ((uint64(1) << p.Size) - 1) << p.Start
+}
+
+// PlanGPU is a MIG-capable GPU for which a MIG layout is planned.
+type PlanGPU struct {
+	Index    int
+	Name     string
+	Profiles []PlanProfile
+}
+
+// capacity returns the compute slices and the memory of the GPU, which are
+// those of its largest profile.
+func (g *PlanGPU) capacity() (int, int) {
+	var slices, memoryGB int
+	for _, p := range g.Profiles {
+		slices = max(slices, p.Slices)
+		memoryGB = max(memoryGB, p.MemoryGB)
+	}
+	return slices, memoryGB
+}
+
+func (g *PlanGPU) profile(name string) *PlanProfile {
+	for i := range g.Profiles {
+		if g.Profiles[i].Name == name {
+			return &g.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// PlannedGPU is the planned MIG layout of a GPU. A GPU without MIG devices
+// does not need MIG to be enabled.
+type PlannedGPU struct {
+	Index   int
+	Name    string
+	Devices map[string]int
+}
+
+// Plan is a MIG layout of the GPUs of a node.
+type Plan struct {
+	GPUs []PlannedGPU
+}
+
+// NewPlan computes a MIG layout of the specified GPUs that provides the
+// requested number of MIG devices of each profile. The layout uses as few
+// GPUs as possible so that the remaining GPUs can be advertised as full GPUs.
+// A GPU is considered to fit a set of MIG devices if their compute slices and
+// memory fit into those of the GPU, the maximum number of instances of each
+// profile is not exceeded, and each instance can be assigned one of the
+// possible placements of its profile without overlapping the others.
+func NewPlan(gpus []PlanGPU, requests map[string]int) (*Plan, error) {
+	var instances []string
+	for name, count := range requests {
+		if count < 0 {
+			return nil, fmt.Errorf("invalid number of %v devices: %d", name, count)
+		}
+		supported := false
+		for i := range gpus {
+			supported = supported || gpus[i].profile(name) != nil
+		}
+		if !supported {
+			return nil, fmt.Errorf("profile %v is not supported by any GPU on the node", name)
+		}
+		for i := 0; i < count; i++ {
+			instances = append(instances, name)
+		}
+	}
+	// Placing the largest instances first prunes the search early.
+	size := func(name string) (int, int) {
+		for i := range gpus {
+			if p := gpus[i].profile(name); p != nil {
+				return p.Slices, p.MemoryGB
+			}
+		}
+		return 0, 0
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		si, mi := size(instances[i])
+		sj, mj := size(instances[j])
+		if si != sj {
+			return si > sj
+		}
+		if mi != mj {
+			return mi > mj
+		}
+		return instances[i] < instances[j]
+	})
+
+	for used := 0; used <= len(gpus); used++ {
+		p := newPlanner(gpus[:used])
+		if p.place(instances) {
+			return p.plan(gpus), nil
+		}
+	}
+	return nil, fmt.Errorf("the requested MIG devices do not fit on the %d MIG-capable GPUs of the node", len(gpus))
+}
+
+// planner searches for a placement of MIG devices onto a set of GPUs.
+type planner struct {
+	gpus     []PlanGPU
+	slices   []int
+	memoryGB []int
+	devices  []map[string]int
+	// occupied holds the memory slices of each GPU that are used by the
+	// placed instances.
+	occupied []uint64
+}
+
+func newPlanner(gpus []PlanGPU) *planner {
+	p := &planner{gpus: gpus}
+	for i := range gpus {
+		slices, memoryGB := gpus[i].capacity()
+		p.slices = append(p.slices, slices)
+		p.memoryGB = append(p.memoryGB, memoryGB)
+		p.devices = append(p.devices, make(map[string]int))
+		p.occupied = append(p.occupied, 0)
+	}
+	return p
+}
+
+// place places the specified instances by backtracking. GPUs of the same
+// model that are in the same state are equivalent, so only the first of them
+// is tried for an instance.
+func (p *planner) place(instances []string) bool {
+	if len(instances) == 0 {
+		return true
+	}
+	name := instances[0]
+	tried := make(map[string]bool)
+	for i := range p.gpus {
+		profile := p.gpus[i].profile(name)
+		if profile == nil || profile.Slices > p.slices[i] || profile.MemoryGB > p.memoryGB[i] || p.devices[i][name] >= profile.MaxInstances {
+			continue
+		}
+		key := p.state(i)
+		if tried[key] {
+			continue
+		}
+		tried[key] = true
+
+		for _, mask := range p.freePlacements(i, profile) {
+			p.slices[i] -= profile.Slices
+			p.memoryGB[i] -= profile.MemoryGB
+			p.devices[i][name]++
+			p.occupied[i] |= mask
+			if p.place(instances[1:]) {
+				return true
+			}
+			p.slices[i] += profile.Slices
+			p.memoryGB[i] += profile.MemoryGB
+			p.devices[i][name]--
+			p.occupied[i] &^= mask
+		}
+	}
+	return false
+}
+
+// freePlacements returns the placements of the profile on a GPU that do not
+// overlap the placed instances, as bit masks of memory slices. A single empty
+// placement is returned if the placements of the profile are not known.
+func (p *planner) freePlacements(i int, profile *PlanProfile) []uint64 {
+	if len(profile.Placements) == 0 {
+		return []uint64{0}
+	}
+	var free []uint64
+	for _, placement := range profile.Placements {
+		if mask := placement.mask(); p.occupied[i]&mask == 0 {
+			free = append(free, mask)
+		}
+	}
+	return free
+}
+
+// state returns a key that identifies the model, the placed MIG devices and
+// the occupied memory slices of a GPU.
+func (p *planner) state(i int) string {
+	var names []string
+	for name, count := range p.devices[i] {
+		if count > 0 {
+			names = append(names, fmt.Sprintf("%v=%d", name, count))
+		}
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%v:%v:%x", p.gpus[i].Name, strings.Join(names, ","), p.occupied[i])
+}
+
+// plan returns the planned layout of all GPUs, including the GPUs that were
+// not needed.
+func (p *planner) plan(gpus []PlanGPU) *Plan {
+	plan := &Plan{}
+	for i, gpu := range gpus {
+		planned := PlannedGPU{Index: gpu.Index, Name: gpu.Name}
+		if i < len(p.devices) {
+			for name, count := range p.devices[i] {
+				if count == 0 {
+					continue
+				}
+				if planned.Devices == nil {
+					planned.Devices = make(map[string]int)
+				}
+				planned.Devices[name] = count
+			}
+		}
+		plan.GPUs = append(plan.GPUs, planned)
+	}
+	return plan
+}
+
+// migPartedConfig is a mig-parted config file.
+type migPartedConfig struct {
+	Version    string                        `json:"version"`
+	MigConfigs map[string][]migPartedDevices `json:"mig-configs"`
+}
+
+// migPartedDevices is the MIG config of a set of GPUs in a mig-parted config.
+type migPartedDevices struct {
+	Devices    []int          `json:"devices"`
+	MigEnabled bool           `json:"mig-enabled"`
+	MigDevices map[string]int `json:"mig-devices,omitempty"`
+}
+
+// MigPartedConfig returns the plan as a mig-parted config with the specified
+// name. GPUs with the same layout are grouped.
+func (p *Plan) MigPartedConfig(name string) ([]byte, error) {
+	var groups []migPartedDevices
+	for _, gpu := range p.GPUs {
+		i := slices.IndexFunc(groups, func(g migPartedDevices) bool {
+			return g.MigEnabled == (len(gpu.Devices) > 0) && mapsEqual(g.MigDevices, gpu.Devices)
+		})
+		if i < 0 {
+			groups = append(groups, migPartedDevices{
+				MigEnabled: len(gpu.Devices) > 0,
+				MigDevices: gpu.Devices,
+			})
+			i = len(groups) - 1
+		}
+		groups[i].Devices = append(groups[i].Devices, gpu.Index)
+	}
+	return yaml.Marshal(migPartedConfig{
+		Version:    "v1",
+		MigConfigs: map[string][]migPartedDevices{name: groups},
+	})
+}
+
+// DevicePluginConfig returns the device plugin config that advertises the
+// planned MIG devices. The single MIG strategy is used if all GPUs are
+// partitioned into MIG devices of the same profile; otherwise the mixed
+// strategy is used.
+func (p *Plan) DevicePluginConfig() ([]byte, error) {
+	strategy := spec.MigStrategySingle
+	var profile string
+	for _, gpu := range p.GPUs {
+		if len(gpu.Devices) != 1 {
+			strategy = spec.MigStrategyMixed
+			break
+		}
+		for name := range gpu.Devices {
+			if profile != "" && profile != name {
+				strategy = spec.MigStrategyMixed
+			}
+			profile = name
+		}
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"version": spec.Version,
+		"flags": map[string]interface{}{
+			"migStrategy": strategy,
+		},
+	})
+}
+
+func mapsEqual(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// GetPlanGPUs returns the MIG-capable GPUs of the node with the MIG profiles
+// they support. Only profiles with a single compute instance that spans the
+// whole GPU instance are included, since these are the profiles that are
+// advertised by the device plugin.
+func GetPlanGPUs(nvmllib nvml.Interface, devicelib device.Interface) ([]PlanGPU, error) {
+	if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML: %v", ret)
+	}
+	defer func() {
+		_ = nvmllib.Shutdown()
+	}()
+
+	var gpus []PlanGPU
+	err := devicelib.VisitDevices(func(i int, d device.Device) error {
+		capable, err := d.IsMigCapable()
+		if err != nil {
+			return fmt.Errorf("failed to check whether device %d is MIG capable: %w", i, err)
+		}
+		if !capable {
+			return nil
+		}
+		name, ret := d.GetName()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("failed to get name of device %d: %v", i, ret)
+		}
+		gpu := PlanGPU{Index: i, Name: name}
+		err = d.VisitMigProfiles(func(p device.MigProfile) error {
+			info := p.GetInfo()
+			if info.C != info.G || len(info.Attributes) > 0 || gpu.profile(p.String()) != nil {
+				return nil
+			}
+			giInfo, ret := d.GetGpuInstanceProfileInfo(info.GIProfileID)
+			if ret != nvml.SUCCESS {
+				return fmt.Errorf("failed to get info of profile %v: %v", p, ret)
+			}
+			placements, ret := d.GetGpuInstancePossiblePlacements(&giInfo)
+			if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+				return fmt.Errorf("failed to get possible placements of profile %v: %v", p, ret)
+			}
+			profile := PlanProfile{
+				Name:         p.String(),
+				Slices:       info.G,
+				MemoryGB:     info.GB,
+				MaxInstances: int(giInfo.InstanceCount),
+			}
+			for _, placement := range placements {
+				profile.Placements = append(profile.Placements, PlanPlacement{
+					Start: int(placement.Start),
+					Size:  int(placement.Size),
+				})
+			}
+			gpu.Profiles = append(gpu.Profiles, profile)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get MIG profiles of device %d: %w", i, err)
+		}
+		gpus = append(gpus, gpu)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gpus, nil
+}
@@ -0,0 +1,158 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPlan(t *testing.T) {
+	placements := func(size int, starts ...int) []PlanPlacement {
+		var p []PlanPlacement
+		for _, start := range starts {
+			p = append(p, PlanPlacement{Start: start, Size: size})
+		}
+		return p
+	}
+	a100 := func(index int) PlanGPU {
+		return PlanGPU{
+			Index: index,
+			Name:  "NVIDIA A100-SXM4-40GB",
+			Profiles: []PlanProfile{
+				{Name: "1g.5gb", Slices: 1, MemoryGB: 5, MaxInstances: 7, Placements: placements(1, 0, 1, 2, 3, 4, 5, 6)},
+				{Name: "2g.10gb", Slices: 2, MemoryGB: 10, MaxInstances: 3, Placements: placements(2, 0, 2, 4)},
+				{Name: "3g.20gb", Slices: 3, MemoryGB: 20, MaxInstances: 2, Placements: placements(4, 0, 4)},
+				{Name: "4g.20gb", Slices: 4, MemoryGB: 20, MaxInstances: 1, Placements: placements(4, 0)},
+				{Name: "7g.40gb", Slices: 7, MemoryGB: 40, MaxInstances: 1, Placements: placements(8, 0)},
+			},
+		}
+	}
+	gpus := []PlanGPU{a100(0), a100(1)}
+
+	testCases := []struct {
+		description   string
+		requests      map[string]int
+		expectedPlan  *Plan
+		expectedError string
+	}{
+		{
+			description: "no requests",
+			expectedPlan: &Plan{GPUs: []PlannedGPU{
+				{Index: 0, Name: "NVIDIA A100-SXM4-40GB"},
+				{Index: 1, Name: "NVIDIA A100-SXM4-40GB"},
+			}},
+		},
+		{
+			// The 3g.20gb device is placed on the upper half of the GPU
+			// after the lower half left too few placements of 1g.5gb.
+			description: "requests fit on a single GPU",
+			requests:    map[string]int{"1g.5gb": 4, "3g.20gb": 1},
+			expectedPlan: &Plan{GPUs: []PlannedGPU{
+				{Index: 0, Name: "NVIDIA A100-SXM4-40GB", Devices: map[string]int{"1g.5gb": 4, "3g.20gb": 1}},
+				{Index: 1, Name: "NVIDIA A100-SXM4-40GB"},
+			}},
+		},
+		{
+			description: "requests exceed the instances of a profile on a GPU",
+			requests:    map[string]int{"1g.5gb": 8},
+			expectedPlan: &Plan{GPUs: []PlannedGPU{
+				{Index: 0, Name: "NVIDIA A100-SXM4-40GB", Devices: map[string]int{"1g.5gb": 7}},
+				{Index: 1, Name: "NVIDIA A100-SXM4-40GB", Devices: map[string]int{"1g.5gb": 1}},
+			}},
+		},
+		{
+			description: "requests need backtracking",
+			requests:    map[string]int{"4g.20gb": 1, "3g.20gb": 3},
+			expectedPlan: &Plan{GPUs: []PlannedGPU{
+				{Index: 0, Name: "NVIDIA A100-SXM4-40GB", Devices: map[string]int{"4g.20gb": 1, "3g.20gb": 1}},
+				{Index: 1, Name: "NVIDIA A100-SXM4-40GB", Devices: map[string]int{"3g.20gb": 2}},
+			}},
+		},
+		{
+			description:   "requests do not fit",
+			requests:      map[string]int{"7g.40gb": 3},
+			expectedError: "the requested MIG devices do not fit on the 2 MIG-capable GPUs of the node",
+		},
+		{
+			description:   "unsupported profile",
+			requests:      map[string]int{"1g.10gb": 1},
+			expectedError: "profile 1g.10gb is not supported by any GPU on the node",
+		},
+	}
+
+	// The instances of a profile do not fit if they cannot be placed without
+	// overlapping, even if their compute slices and memory fit.
+	constrained := PlanGPU{
+		Name: "constrained",
+		Profiles: []PlanProfile{
+			{Name: "2g.10gb", Slices: 2, MemoryGB: 10, MaxInstances: 3, Placements: placements(2, 0, 4)},
+			{Name: "7g.40gb", Slices: 7, MemoryGB: 40, MaxInstances: 1, Placements: placements(8, 0)},
+		},
+	}
+	_, err := NewPlan([]PlanGPU{constrained}, map[string]int{"2g.10gb": 3})
+	require.EqualError(t, err, "the requested MIG devices do not fit on the 1 MIG-capable GPUs of the node")
+	plan, err := NewPlan([]PlanGPU{constrained}, map[string]int{"2g.10gb": 2})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"2g.10gb": 2}, plan.GPUs[0].Devices)
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			plan, err := NewPlan(gpus, tc.requests)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPlan, plan)
+		})
+	}
+}
+
+func TestPlanConfigs(t *testing.T) {
+	plan := &Plan{GPUs: []PlannedGPU{
+		{Index: 0, Devices: map[string]int{"3g.20gb": 2}},
+		{Index: 1, Devices: map[string]int{"3g.20gb": 2}},
+		{Index: 2},
+	}}
+
+	migParted, err := plan.MigPartedConfig("planned")
+	require.NoError(t, err)
+	require.Equal(t, `mig-configs:
+  planned:
+  - devices:
+    - 0
+    - 1
+    mig-devices:
+      3g.20gb: 2
+    mig-enabled: true
+  - devices:
+    - 2
+    mig-enabled: false
+version: v1
+`, string(migParted))
+
+	config, err := plan.DevicePluginConfig()
+	require.NoError(t, err)
+	require.Equal(t, "flags:\n  migStrategy: mixed\nversion: v1\n", string(config))
+
+	plan.GPUs = plan.GPUs[:2]
+	config, err = plan.DevicePluginConfig()
+	require.NoError(t, err)
+	require.Equal(t, "flags:\n  migStrategy: single\nversion: v1\n", string(config))
+}
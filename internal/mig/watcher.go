@@ -0,0 +1,168 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mig
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+)
+
+// Layout maps the UUID of each GPU to the sorted UUIDs of its MIG devices.
+// GPUs with MIG disabled map to nil.
+type Layout map[string][]string
+
+// Changed returns the sorted UUIDs of the GPUs whose MIG devices differ
+// between the two layouts, including the GPUs that were added or removed.
+func (l Layout) Changed(other Layout) []string {
+	var changed []string
+	for uuid, migs := range l {
+		if o, ok := other[uuid]; !ok || !slices.Equal(migs, o) || (migs == nil) != (o == nil) {
+			changed = append(changed, uuid)
+		}
+	}
+	for uuid := range other {
+		if _, ok := l[uuid]; !ok {
+			changed = append(changed, uuid)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Watcher detects changes to the MIG layout of the node that are made
+// externally, e.g. by mig-parted, as well as GPUs that are attached or
+// removed at runtime, by periodically querying NVML through the nvcaps facade,
+// and therefore through the NVML broker if it is used. A check can also be
+// triggered immediately, e.g. when a GPU is reported to have fallen off the
+// bus.
+type Watcher struct {
+	interval  time.Duration
+	getLayout func() (Layout, error)
+	changes   chan []string
+	trigger   chan struct{}
+}
+
+// NewWatcher creates a watcher that queries the MIG layout at the specified
+// interval. A nil watcher is returned if the interval is 0.
+func NewWatcher(nvcapslib nvcaps.Interface, interval time.Duration) *Watcher {
+	if interval == 0 {
+		return nil
+	}
+	return &Watcher{
+		interval: interval,
+		getLayout: func() (Layout, error) {
+			return GetLayout(nvcapslib)
+		},
+		changes: make(chan []string),
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// Trigger requests an immediate check of the layout. It does not block, and
+// triggers that arrive while a check is already pending are coalesced.
+func (w *Watcher) Trigger() {
+	if w == nil {
+		return
+	}
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Changes returns a channel on which the UUIDs of the GPUs whose MIG layout
+// changed are sent. A nil channel is returned for a nil watcher.
+func (w *Watcher) Changes() <-chan []string {
+	if w == nil {
+		return nil
+	}
+	return w.changes
+}
+
+// Run queries the MIG layout until the context is cancelled. The watcher is
+// disabled if the initial layout cannot be queried, e.g. on systems without
+// NVML.
+func (w *Watcher) Run(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	last, err := w.getLayout()
+	if err != nil {
+		klog.Warningf("Disabling MIG layout watcher: %v", err)
+		return
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.trigger:
+		}
+		layout, err := w.getLayout()
+		if err != nil {
+			klog.Warningf("Failed to query MIG layout: %v", err)
+			continue
+		}
+		changed := last.Changed(layout)
+		if len(changed) == 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case w.changes <- changed:
+		}
+		last = layout
+	}
+}
+
+// GetLayout queries the current MIG layout of the node. GPUs that have fallen
+// off the bus are omitted from the layout so that they are reported as
+// removed.
+func GetLayout(nvcapslib nvcaps.Interface) (Layout, error) {
+	if err := nvcapslib.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize NVML: %w", err)
+	}
+	defer func() {
+		_ = nvcapslib.Shutdown()
+	}()
+
+	gpus, err := nvcapslib.GetGPUs()
+	if err != nil {
+		return nil, err
+	}
+	layout := make(Layout)
+	for _, gpu := range gpus {
+		if !gpu.MigEnabled {
+			layout[gpu.UUID] = nil
+			continue
+		}
+		migUUIDs := append([]string{}, gpu.MigUUIDs...)
+		sort.Strings(migUUIDs)
+		layout[gpu.UUID] = migUUIDs
+	}
+	return layout, nil
+}
@@ -0,0 +1,173 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+)
+
+func TestLayoutChanged(t *testing.T) {
+	testCases := []struct {
+		description string
+		layout      Layout
+		other       Layout
+		expected    []string
+	}{
+		{
+			description: "unchanged",
+			layout:      Layout{"GPU-0": nil, "GPU-1": {"MIG-0", "MIG-1"}},
+			other:       Layout{"GPU-0": nil, "GPU-1": {"MIG-0", "MIG-1"}},
+		},
+		{
+			description: "MIG devices changed",
+			layout:      Layout{"GPU-0": nil, "GPU-1": {"MIG-0", "MIG-1"}},
+			other:       Layout{"GPU-0": nil, "GPU-1": {"MIG-2"}},
+			expected:    []string{"GPU-1"},
+		},
+		{
+			description: "MIG enabled without MIG devices",
+			layout:      Layout{"GPU-0": nil},
+			other:       Layout{"GPU-0": {}},
+			expected:    []string{"GPU-0"},
+		},
+		{
+			description: "GPUs added and removed",
+			layout:      Layout{"GPU-0": nil, "GPU-1": nil},
+			other:       Layout{"GPU-1": nil, "GPU-2": nil},
+			expected:    []string{"GPU-0", "GPU-2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.layout.Changed(tc.other))
+		})
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	layouts := []Layout{
+		{"GPU-0": {"MIG-0"}},
+		{"GPU-0": {"MIG-0"}},
+		nil,
+		{"GPU-0": {"MIG-1", "MIG-2"}},
+	}
+	w := &Watcher{
+		interval: time.Millisecond,
+		getLayout: func() (Layout, error) {
+			if len(layouts) == 0 {
+				return Layout{"GPU-0": {"MIG-1", "MIG-2"}}, nil
+			}
+			layout := layouts[0]
+			layouts = layouts[1:]
+			if layout == nil {
+				return nil, errors.New("NVML error")
+			}
+			return layout, nil
+		},
+		changes: make(chan []string),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	select {
+	case changed := <-w.Changes():
+		require.Equal(t, []string{"GPU-0"}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for MIG layout change")
+	}
+	select {
+	case changed := <-w.Changes():
+		t.Fatalf("unexpected MIG layout change: %v", changed)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatcherTrigger(t *testing.T) {
+	layouts := []Layout{
+		{"GPU-0": nil, "GPU-1": nil},
+		{"GPU-0": nil},
+	}
+	w := &Watcher{
+		interval: time.Hour,
+		getLayout: func() (Layout, error) {
+			layout := layouts[0]
+			if len(layouts) > 1 {
+				layouts = layouts[1:]
+			}
+			return layout, nil
+		},
+		changes: make(chan []string),
+		trigger: make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	w.Trigger()
+	w.Trigger()
+	select {
+	case changed := <-w.Changes():
+		require.Equal(t, []string{"GPU-1"}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for triggered check")
+	}
+}
+
+func TestNilWatcher(t *testing.T) {
+	w := NewWatcher(nil, 0)
+	require.Nil(t, w)
+	require.Nil(t, w.Changes())
+	w.Trigger()
+	w.Run(context.Background())
+}
+
+func TestGetLayout(t *testing.T) {
+	nvcapslib := &nvcaps.InterfaceMock{
+		InitFunc:     func() error { return nil },
+		ShutdownFunc: func() error { return nil },
+		GetGPUsFunc: func() ([]nvcaps.GPU, error) {
+			return []nvcaps.GPU{
+				{Index: 0, UUID: "GPU-0"},
+				{Index: 1, UUID: "GPU-1", MigEnabled: true, MigUUIDs: []string{"MIG-1", "MIG-0"}},
+				{Index: 2, UUID: "GPU-2", MigEnabled: true},
+			}, nil
+		},
+	}
+
+	layout, err := GetLayout(nvcapslib)
+	require.NoError(t, err)
+	require.Equal(t, Layout{"GPU-0": nil, "GPU-1": {"MIG-0", "MIG-1"}, "GPU-2": {}}, layout)
+	require.Len(t, nvcapslib.ShutdownCalls(), 1)
+
+	nvcapslib.GetGPUsFunc = func() ([]nvcaps.GPU, error) {
+		return nil, errors.New("driver not loaded")
+	}
+	_, err = GetLayout(nvcapslib)
+	require.Error(t, err)
+	require.Len(t, nvcapslib.ShutdownCalls(), 2)
+}
@@ -0,0 +1,122 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	coreclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// The node labels that the build info is written to.
+const (
+	VersionLabel      = "nvidia.com/device-plugin.version"
+	GitCommitLabel    = "nvidia.com/device-plugin.git-commit"
+	FeatureGatesLabel = "nvidia.com/device-plugin.feature-gates"
+	ConfigHashLabel   = "nvidia.com/device-plugin.config-hash"
+)
+
+// BuildInfoPublisher writes the version of the plugin, the enabled feature
+// gates and the hash of the effective config to node labels, so that version
+// skew across nodes can be found with label selectors.
+type BuildInfoPublisher struct {
+	client    coreclientset.Interface
+	nodeName  string
+	version   string
+	gitCommit string
+
+	sync.Mutex
+	published map[string]string
+}
+
+// NewBuildInfoPublisher creates a publisher that writes the build info for the
+// specified node.
+func NewBuildInfoPublisher(client coreclientset.Interface, nodeName string, version string, gitCommit string) *BuildInfoPublisher {
+	return &BuildInfoPublisher{
+		client:    client,
+		nodeName:  nodeName,
+		version:   version,
+		gitCommit: gitCommit,
+	}
+}
+
+// Publish writes the build info with the specified feature gates and config
+// hash to the node labels. The labels are only patched if they changed since
+// they were last published. This is called every time the plugins are
+// (re)started.
+func (p *BuildInfoPublisher) Publish(ctx context.Context, featureGates []string, configHash string) error {
+	if p == nil {
+		return nil
+	}
+	labels := buildInfoLabels(p.version, p.gitCommit, featureGates, configHash)
+
+	p.Lock()
+	defer p.Unlock()
+	if maps.Equal(labels, p.published) {
+		return nil
+	}
+	if err := patchLabels(ctx, p.client, p.nodeName, labels); err != nil {
+		return err
+	}
+	p.published = labels
+	return nil
+}
+
+// buildInfoLabels constructs the build info labels. Since label values cannot
+// contain commas, the feature gates are separated by dots. Values that are not
+// valid label values, e.g. a version with build metadata, are left empty.
+func buildInfoLabels(version string, gitCommit string, featureGates []string, configHash string) map[string]string {
+	labels := map[string]string{
+		VersionLabel:      version,
+		GitCommitLabel:    gitCommit,
+		FeatureGatesLabel: strings.Join(featureGates, "."),
+		ConfigHashLabel:   configHash,
+	}
+	for label, value := range labels {
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			klog.Warningf("Not setting label %v to invalid value %q: %v", label, value, strings.Join(errs, "; "))
+			labels[label] = ""
+		}
+	}
+	return labels
+}
+
+// patchLabels sets the specified labels on a node.
+func patchLabels(ctx context.Context, client coreclientset.Interface, nodeName string, labels map[string]string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": labels,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to construct patch: %w", err)
+	}
+	_, err = client.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch node %q: %w", nodeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPublishBuildInfo(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{"other": "label"}},
+	})
+
+	p := NewBuildInfoPublisher(client, "node-a", "v0.17.0", "abc123")
+	require.NoError(t, p.Publish(context.Background(), []string{"ClockBoost", "Other"}, "0123456789abcdef"))
+
+	node, err := client.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t,
+		map[string]string{
+			"other":           "label",
+			VersionLabel:      "v0.17.0",
+			GitCommitLabel:    "abc123",
+			FeatureGatesLabel: "ClockBoost.Other",
+			ConfigHashLabel:   "0123456789abcdef",
+		},
+		node.Labels,
+	)
+
+	// Unchanged build info is not patched again.
+	actions := len(client.Actions())
+	require.NoError(t, p.Publish(context.Background(), []string{"ClockBoost", "Other"}, "0123456789abcdef"))
+	require.Len(t, client.Actions(), actions)
+
+	require.NoError(t, p.Publish(context.Background(), nil, "fedcba9876543210"))
+	node, err = client.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "", node.Labels[FeatureGatesLabel])
+	require.Equal(t, "fedcba9876543210", node.Labels[ConfigHashLabel])
+}
+
+func TestBuildInfoLabelsWithInvalidValues(t *testing.T) {
+	labels := buildInfoLabels("v0.17.0+dirty", strings.Repeat("a", 64), nil, "")
+	require.Equal(t, "", labels[VersionLabel])
+	require.Equal(t, "", labels[GitCommitLabel])
+}
@@ -0,0 +1,70 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+
+	coreclientset "k8s.io/client-go/kubernetes"
+)
+
+// ReconfigurationAnnotation is the node annotation that the state of a MIG
+// reconfiguration is written to. External controllers can watch it to cordon
+// the node while its devices are drained and uncordon it afterwards.
+const ReconfigurationAnnotation = "nvidia.com/device-plugin.mig-reconfiguration"
+
+// ReconfigurationState is the state of a MIG reconfiguration of the node.
+type ReconfigurationState string
+
+// Constants for the supported reconfiguration states.
+const (
+	// ReconfigurationDraining indicates that the devices of the resources
+	// affected by a change of the MIG layout are being drained.
+	ReconfigurationDraining = ReconfigurationState("draining")
+	// ReconfigurationDone indicates that the plugins of the affected
+	// resources were re-registered with the new MIG layout.
+	ReconfigurationDone = ReconfigurationState("done")
+	// ReconfigurationFailed indicates that the plugins could not be
+	// reconciled with the new MIG layout.
+	ReconfigurationFailed = ReconfigurationState("failed")
+)
+
+// ReconfigurationPublisher writes the state of MIG reconfigurations to a node
+// annotation.
+type ReconfigurationPublisher struct {
+	client     coreclientset.Interface
+	nodeName   string
+	annotation string
+}
+
+// NewReconfigurationPublisher creates a publisher that writes the state of MIG
+// reconfigurations for the specified node.
+func NewReconfigurationPublisher(client coreclientset.Interface, nodeName string) *ReconfigurationPublisher {
+	return &ReconfigurationPublisher{
+		client:     client,
+		nodeName:   nodeName,
+		annotation: ReconfigurationAnnotation,
+	}
+}
+
+// Publish writes the specified state to the node annotation.
+func (p *ReconfigurationPublisher) Publish(ctx context.Context, state ReconfigurationState) error {
+	if p == nil {
+		return nil
+	}
+	return patchAnnotation(ctx, p.client, p.nodeName, p.annotation, string(state))
+}
@@ -0,0 +1,46 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPublishReconfiguration(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+	})
+
+	var nilPublisher *ReconfigurationPublisher
+	require.NoError(t, nilPublisher.Publish(context.Background(), ReconfigurationDraining))
+
+	p := NewReconfigurationPublisher(client, "node-a")
+	for _, state := range []ReconfigurationState{ReconfigurationDraining, ReconfigurationDone} {
+		require.NoError(t, p.Publish(context.Background(), state))
+		node, err := client.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, string(state), node.Annotations[ReconfigurationAnnotation])
+	}
+
+	require.Error(t, NewReconfigurationPublisher(client, "node-b").Publish(context.Background(), ReconfigurationFailed))
+}
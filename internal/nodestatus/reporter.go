@@ -0,0 +1,147 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	coreclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// DefaultAnnotation is the node annotation that the status is written to.
+const DefaultAnnotation = "nvidia.com/device-plugin.status"
+
+// Reporter periodically writes the device plugin status to a node annotation.
+type Reporter struct {
+	client     coreclientset.Interface
+	nodeName   string
+	annotation string
+	interval   time.Duration
+	version    string
+
+	sync.Mutex
+	configHash string
+	sources    []Source
+}
+
+// NewReporter creates a reporter that writes the status for the specified node.
+func NewReporter(client coreclientset.Interface, nodeName string, interval time.Duration, version string) *Reporter {
+	return &Reporter{
+		client:     client,
+		nodeName:   nodeName,
+		annotation: DefaultAnnotation,
+		interval:   interval,
+		version:    version,
+	}
+}
+
+// Update sets the config and the sources of devices that are reported.
+// This is called every time the plugins are (re)started.
+func (r *Reporter) Update(config *spec.Config, sources []Source) error {
+	if r == nil {
+		return nil
+	}
+	hash, err := HashConfig(config)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.configHash = hash
+	r.sources = sources
+	return nil
+}
+
+// Run writes the status to the node annotation until the context is cancelled.
+func (r *Reporter) Run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.report(ctx); err != nil {
+			klog.Warningf("Failed to update node status annotation: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// status returns a snapshot of the current status.
+func (r *Reporter) status() Status {
+	r.Lock()
+	defer r.Unlock()
+
+	s := Status{
+		Version:    r.version,
+		ConfigHash: r.configHash,
+		Timestamp:  time.Now().UTC().Truncate(time.Second),
+	}
+	for _, source := range r.sources {
+		devices := source.Devices()
+		if len(devices) == 0 {
+			continue
+		}
+		if s.Resources == nil {
+			s.Resources = make(map[string]ResourceStatus)
+		}
+		s.Resources[string(source.Resource())] = newResourceStatus(devices)
+	}
+	return s
+}
+
+// report writes the current status to the node annotation.
+func (r *Reporter) report(ctx context.Context) error {
+	value, err := json.Marshal(r.status())
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+	return patchAnnotation(ctx, r.client, r.nodeName, r.annotation, string(value))
+}
+
+// patchAnnotation sets the specified annotation on a node.
+func patchAnnotation(ctx context.Context, client coreclientset.Interface, nodeName string, annotation string, value string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				annotation: value,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to construct patch: %w", err)
+	}
+	_, err = client.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch node %q: %w", nodeName, err)
+	}
+	return nil
+}
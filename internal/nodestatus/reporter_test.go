@@ -0,0 +1,103 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testSource struct {
+	resource spec.ResourceName
+	devices  rm.Devices
+}
+
+func (s testSource) Resource() spec.ResourceName { return s.resource }
+func (s testSource) Devices() rm.Devices         { return s.devices }
+
+func newTestDevices(health ...string) rm.Devices {
+	devices := make(rm.Devices)
+	for i, h := range health {
+		id := string(rune('a' + i))
+		devices[id] = &rm.Device{Device: pluginapi.Device{ID: id, Health: h}}
+	}
+	return devices
+}
+
+func TestReport(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-a",
+			Annotations: map[string]string{
+				"existing": "annotation",
+			},
+		},
+	})
+
+	r := NewReporter(client, "node-a", time.Minute, "v1.2.3")
+	err := r.Update(
+		&spec.Config{Version: spec.Version},
+		[]Source{
+			testSource{"nvidia.com/gpu", newTestDevices(pluginapi.Healthy, pluginapi.Unhealthy)},
+			testSource{"nvidia.com/mig-1g.5gb", nil},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, r.report(context.Background()))
+
+	node, err := client.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "annotation", node.Annotations["existing"])
+
+	var status Status
+	require.NoError(t, json.Unmarshal([]byte(node.Annotations[DefaultAnnotation]), &status))
+	require.Equal(t, "v1.2.3", status.Version)
+	require.Len(t, status.ConfigHash, 16)
+	require.Equal(t,
+		map[string]ResourceStatus{
+			"nvidia.com/gpu": {Healthy: 1, Total: 2},
+		},
+		status.Resources,
+	)
+}
+
+func TestHashConfig(t *testing.T) {
+	a, err := HashConfig(&spec.Config{Version: spec.Version})
+	require.NoError(t, err)
+	b, err := HashConfig(&spec.Config{Version: spec.Version})
+	require.NoError(t, err)
+	require.Equal(t, a, b)
+
+	c, err := HashConfig(&spec.Config{Version: spec.Version, Allocation: &spec.Allocation{MaxConcurrent: 1}})
+	require.NoError(t, err)
+	require.NotEqual(t, a, c)
+
+	empty, err := HashConfig(nil)
+	require.NoError(t, err)
+	require.Empty(t, empty)
+}
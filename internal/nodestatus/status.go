@@ -0,0 +1,76 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// Status is the compact summary of the device plugin state that is written
+// to the node annotation.
+type Status struct {
+	Version    string                    `json:"version"`
+	ConfigHash string                    `json:"configHash,omitempty"`
+	Timestamp  time.Time                 `json:"timestamp"`
+	Resources  map[string]ResourceStatus `json:"resources,omitempty"`
+}
+
+// ResourceStatus summarizes the devices advertised for a single resource.
+type ResourceStatus struct {
+	Healthy int `json:"healthy"`
+	Total   int `json:"total"`
+}
+
+// Source provides the devices advertised for a resource.
+type Source interface {
+	Resource() spec.ResourceName
+	Devices() rm.Devices
+}
+
+// newResourceStatus counts the healthy and total devices in the specified set.
+func newResourceStatus(devices rm.Devices) ResourceStatus {
+	var s ResourceStatus
+	for _, d := range devices {
+		s.Total++
+		if d.Health == pluginapi.Healthy {
+			s.Healthy++
+		}
+	}
+	return s
+}
+
+// HashConfig returns a short, stable hash of the specified config.
+func HashConfig(config *spec.Config) (string, error) {
+	if config == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
@@ -0,0 +1,128 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	coreclientset "k8s.io/client-go/kubernetes"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// TopologyAnnotation is the node annotation that the sharing topology is
+// written to.
+const TopologyAnnotation = "nvidia.com/device-plugin.topology"
+
+// Topology describes which physical GPU backs each device advertised for a
+// resource. It maps each resource to its devices.
+type Topology map[string][]TopologyDevice
+
+// TopologyDevice describes a device advertised for a resource.
+// If the device is replicated, the advertised IDs are <ID>::0 to
+// <ID>::<Replicas-1>.
+type TopologyDevice struct {
+	// GPU is the index of the physical GPU that backs the device.
+	GPU string `json:"gpu"`
+	// ID is the UUID of the full GPU or MIG device.
+	ID       string `json:"id"`
+	Replicas int    `json:"replicas,omitempty"`
+}
+
+// TopologyPublisher writes the sharing topology to a node annotation.
+type TopologyPublisher struct {
+	client     coreclientset.Interface
+	nodeName   string
+	annotation string
+
+	sync.Mutex
+	published string
+}
+
+// NewTopologyPublisher creates a publisher that writes the sharing topology for
+// the specified node.
+func NewTopologyPublisher(client coreclientset.Interface, nodeName string) *TopologyPublisher {
+	return &TopologyPublisher{
+		client:     client,
+		nodeName:   nodeName,
+		annotation: TopologyAnnotation,
+	}
+}
+
+// Publish writes the topology of the specified sources to the node annotation.
+// The annotation is only patched if the topology changed since it was last
+// published. This is called every time the plugins are (re)started.
+func (p *TopologyPublisher) Publish(ctx context.Context, sources []Source) error {
+	if p == nil {
+		return nil
+	}
+	data, err := json.Marshal(newTopology(sources))
+	if err != nil {
+		return fmt.Errorf("failed to marshal topology: %w", err)
+	}
+	value := string(data)
+
+	p.Lock()
+	defer p.Unlock()
+	if value == p.published {
+		return nil
+	}
+	if err := patchAnnotation(ctx, p.client, p.nodeName, p.annotation, value); err != nil {
+		return err
+	}
+	p.published = value
+	return nil
+}
+
+// newTopology constructs the topology of the specified sources. The devices of
+// each resource are ordered by GPU and ID.
+func newTopology(sources []Source) Topology {
+	topology := make(Topology)
+	for _, source := range sources {
+		devices := make(map[string]*TopologyDevice)
+		for _, d := range source.Devices() {
+			id := d.GetUUID()
+			if _, exists := devices[id]; !exists {
+				gpu, _, _ := strings.Cut(d.Index, ":")
+				devices[id] = &TopologyDevice{GPU: gpu, ID: id}
+			}
+			if rm.AnnotatedID(d.ID).HasAnnotations() {
+				devices[id].Replicas++
+			}
+		}
+		if len(devices) == 0 {
+			continue
+		}
+		var list []TopologyDevice
+		for _, d := range devices {
+			list = append(list, *d)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].GPU != list[j].GPU {
+				return list[i].GPU < list[j].GPU
+			}
+			return list[i].ID < list[j].ID
+		})
+		topology[string(source.Resource())] = list
+	}
+	return topology
+}
@@ -0,0 +1,78 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nodestatus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestPublishTopology(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+	})
+	shared := make(rm.Devices)
+	for _, id := range []string{"GPU-1::0", "GPU-1::1", "GPU-0::0", "GPU-0::1"} {
+		index := "0"
+		if rm.AnnotatedID(id).GetID() == "GPU-1" {
+			index = "1"
+		}
+		shared[id] = &rm.Device{Device: pluginapi.Device{ID: id}, Index: index}
+	}
+	mig := rm.Devices{
+		"MIG-0": &rm.Device{Device: pluginapi.Device{ID: "MIG-0"}, Index: "2:0"},
+	}
+	sources := []Source{
+		testSource{"nvidia.com/gpu.shared", shared},
+		testSource{"nvidia.com/mig-1g.5gb", mig},
+		testSource{"nvidia.com/gpu", nil},
+	}
+
+	p := NewTopologyPublisher(client, "node-a")
+	require.NoError(t, p.Publish(context.Background(), sources))
+
+	node, err := client.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	require.NoError(t, err)
+	var topology Topology
+	require.NoError(t, json.Unmarshal([]byte(node.Annotations[TopologyAnnotation]), &topology))
+	require.Equal(t,
+		Topology{
+			"nvidia.com/gpu.shared": {
+				{GPU: "0", ID: "GPU-0", Replicas: 2},
+				{GPU: "1", ID: "GPU-1", Replicas: 2},
+			},
+			"nvidia.com/mig-1g.5gb": {
+				{GPU: "2", ID: "MIG-0"},
+			},
+		},
+		topology,
+	)
+
+	// An unchanged topology is not patched again.
+	actions := len(client.Actions())
+	require.NoError(t, p.Publish(context.Background(), sources))
+	require.Len(t, client.Actions(), actions)
+}
@@ -0,0 +1,249 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package npd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+const (
+	// Source is the source of the status reports sent to node-problem-detector.
+	Source = "nvidia-device-plugin"
+
+	queueSize    = 100
+	writeTimeout = 5 * time.Second
+)
+
+// Severity is the severity of a node-problem-detector event.
+type Severity string
+
+// The severities of node-problem-detector events.
+const (
+	SeverityInfo Severity = "info"
+	SeverityWarn Severity = "warn"
+)
+
+// Status is a status report in the format used by node-problem-detector.
+type Status struct {
+	Source     string      `json:"source"`
+	Events     []Event     `json:"events"`
+	Conditions []Condition `json:"conditions"`
+}
+
+// Event is a temporary problem reported to node-problem-detector.
+type Event struct {
+	Severity  Severity  `json:"severity"`
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+}
+
+// Condition is a permanent problem reported to node-problem-detector.
+type Condition struct {
+	Type       string    `json:"type"`
+	Status     string    `json:"status"`
+	Transition time.Time `json:"transition"`
+	Reason     string    `json:"reason"`
+	Message    string    `json:"message"`
+}
+
+// Forwarder forwards the health events of the devices to node-problem-detector.
+// Each event is sent as a status report encoded as a single line of JSON. The
+// reports are sent to the local socket of node-problem-detector and to each
+// client connected to the socket on which the forwarder listens, e.g. a
+// node-problem-detector plugin.
+type Forwarder struct {
+	socket       string
+	listenSocket string
+	events       chan rm.HealthEvent
+
+	sync.Mutex
+	clients map[net.Conn]bool
+}
+
+// NewForwarder creates a forwarder that sends health events to the specified
+// socket and to the clients of the specified listen socket. A nil forwarder is
+// returned if both sockets are empty.
+func NewForwarder(socket string, listenSocket string) *Forwarder {
+	if socket == "" && listenSocket == "" {
+		return nil
+	}
+	return &Forwarder{
+		socket:       socket,
+		listenSocket: listenSocket,
+		events:       make(chan rm.HealthEvent, queueSize),
+		clients:      make(map[net.Conn]bool),
+	}
+}
+
+// ReportHealthEvent queues a health event to be forwarded. The event is
+// dropped if the queue is full so that health checks are never blocked.
+func (f *Forwarder) ReportHealthEvent(e rm.HealthEvent) {
+	if f == nil {
+		return
+	}
+	select {
+	case f.events <- e:
+	default:
+		klog.Warningf("Dropping %v health event for device %v: node-problem-detector queue is full", e.Reason, e.DeviceID)
+	}
+}
+
+// Run forwards the queued health events until the context is cancelled. The
+// connection to the socket is established lazily and re-established after
+// errors; events that cannot be sent are dropped.
+func (f *Forwarder) Run(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	if f.listenSocket != "" {
+		listener, err := f.listen()
+		if err != nil {
+			klog.Errorf("Failed to listen for node-problem-detector clients: %v", err)
+		} else {
+			go f.accept(listener)
+			defer func() {
+				_ = listener.Close()
+				f.closeClients()
+			}()
+		}
+	}
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-f.events:
+			f.broadcast(e)
+			if f.socket == "" {
+				continue
+			}
+			if conn == nil {
+				var err error
+				conn, err = net.Dial("unix", f.socket)
+				if err != nil {
+					klog.Warningf("Failed to forward %v health event for device %v to node-problem-detector: %v", e.Reason, e.DeviceID, err)
+					continue
+				}
+			}
+			if err := send(conn, newStatus(e)); err != nil {
+				klog.Warningf("Failed to forward %v health event for device %v to node-problem-detector: %v", e.Reason, e.DeviceID, err)
+				_ = conn.Close()
+				conn = nil
+			}
+		}
+	}
+}
+
+// listen creates the socket on which the forwarder listens for clients. A
+// stale socket of a previous run is removed.
+func (f *Forwarder) listen() (net.Listener, error) {
+	if err := os.Remove(f.listenSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket %v: %w", f.listenSocket, err)
+	}
+	listener, err := net.Listen("unix", f.listenSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %v: %w", f.listenSocket, err)
+	}
+	klog.Infof("Serving health events for node-problem-detector on %v", f.listenSocket)
+	return listener, nil
+}
+
+// accept registers the clients that connect to the listener until it is closed.
+func (f *Forwarder) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		f.Lock()
+		f.clients[conn] = true
+		f.Unlock()
+	}
+}
+
+// broadcast sends the health event to each connected client. Clients that
+// cannot receive the event are disconnected.
+func (f *Forwarder) broadcast(e rm.HealthEvent) {
+	f.Lock()
+	defer f.Unlock()
+	status := newStatus(e)
+	for conn := range f.clients {
+		if err := send(conn, status); err != nil {
+			klog.Warningf("Disconnecting node-problem-detector client: %v", err)
+			_ = conn.Close()
+			delete(f.clients, conn)
+		}
+	}
+}
+
+func (f *Forwarder) closeClients() {
+	f.Lock()
+	defer f.Unlock()
+	for conn := range f.clients {
+		_ = conn.Close()
+		delete(f.clients, conn)
+	}
+}
+
+func send(conn net.Conn, status *Status) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return json.NewEncoder(conn).Encode(status)
+}
+
+// newStatus converts a health event to a node-problem-detector status report.
+// Events that mark a device as unhealthy are reported as warnings.
+func newStatus(e rm.HealthEvent) *Status {
+	severity := SeverityInfo
+	if e.Unhealthy {
+		severity = SeverityWarn
+	}
+	device := e.DeviceID
+	if e.Location != nil {
+		device = fmt.Sprintf("%v (%v)", e.DeviceID, e.Location)
+	}
+	return &Status{
+		Source: Source,
+		Events: []Event{
+			{
+				Severity:  severity,
+				Timestamp: e.Timestamp,
+				Reason:    e.Reason,
+				Message:   fmt.Sprintf("%v device %v: %v", e.Resource, device, e.Message),
+			},
+		},
+		Conditions: []Condition{},
+	}
+}
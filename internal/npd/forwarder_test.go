@@ -0,0 +1,144 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package npd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/location"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestForwarder(t *testing.T) {
+	require.Nil(t, NewForwarder("", ""))
+	(*Forwarder)(nil).ReportHealthEvent(rm.HealthEvent{})
+
+	socket := filepath.Join(t.TempDir(), "npd.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := NewForwarder(socket, "")
+	go f.Run(ctx)
+
+	timestamp := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	f.ReportHealthEvent(rm.HealthEvent{
+		Timestamp: timestamp,
+		Resource:  "nvidia.com/gpu",
+		DeviceID:  "GPU-0",
+		Location:  &location.Location{Rack: "r12", Slot: "4"},
+		Reason:    rm.HealthEventReasonXid,
+		Message:   "Xid 79 on GPU GPU-0",
+		Unhealthy: true,
+	})
+	f.ReportHealthEvent(rm.HealthEvent{
+		Timestamp: timestamp,
+		Resource:  "nvidia.com/gpu",
+		DeviceID:  "GPU-1",
+		Reason:    rm.HealthEventReasonSingleBitECC,
+		Message:   "Single-bit ECC error on GPU GPU-1",
+	})
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+
+	scanner := bufio.NewScanner(conn)
+	var statuses []Status
+	for len(statuses) < 2 && scanner.Scan() {
+		var status Status
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &status))
+		statuses = append(statuses, status)
+	}
+	require.NoError(t, scanner.Err())
+
+	expected := []Status{
+		{
+			Source: Source,
+			Events: []Event{
+				{Severity: SeverityWarn, Timestamp: timestamp, Reason: "GPUXidError", Message: "nvidia.com/gpu device GPU-0 (rack=r12,slot=4): Xid 79 on GPU GPU-0"},
+			},
+			Conditions: []Condition{},
+		},
+		{
+			Source: Source,
+			Events: []Event{
+				{Severity: SeverityInfo, Timestamp: timestamp, Reason: "GPUSingleBitECCError", Message: "nvidia.com/gpu device GPU-1: Single-bit ECC error on GPU GPU-1"},
+			},
+			Conditions: []Condition{},
+		},
+	}
+	require.Equal(t, expected, statuses)
+}
+
+func TestForwarderListenSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "npd.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := NewForwarder("", socket)
+	go f.Run(ctx)
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("unix", socket)
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+	defer conn.Close()
+	require.Eventually(t, func() bool {
+		f.Lock()
+		defer f.Unlock()
+		return len(f.clients) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	timestamp := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	f.ReportHealthEvent(rm.HealthEvent{
+		Timestamp: timestamp,
+		Resource:  "nvidia.com/gpu",
+		DeviceID:  "GPU-0",
+		Reason:    rm.HealthEventReasonThermal,
+		Message:   "GPU GPU-0 is at 95°C (unhealthy threshold 90°C)",
+		Unhealthy: true,
+	})
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	scanner := bufio.NewScanner(conn)
+	require.True(t, scanner.Scan())
+	var status Status
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &status))
+
+	expected := Status{
+		Source: Source,
+		Events: []Event{
+			{Severity: SeverityWarn, Timestamp: timestamp, Reason: "GPUThermalThreshold", Message: "nvidia.com/gpu device GPU-0: GPU GPU-0 is at 95°C (unhealthy threshold 90°C)"},
+		},
+		Conditions: []Condition{},
+	}
+	require.Equal(t, expected, status)
+}
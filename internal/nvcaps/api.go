@@ -0,0 +1,139 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package nvcaps provides a facade over the NVML calls made by the device
+// plugin while its plugins are running: health checks, clock boosting, MIG
+// placement lookups, the wear metrics and the enumeration of the GPUs and MIG
+// devices by the MIG layout watcher. All arguments and return values are
+// plain data types so that an implementation need not run in the same process
+// as the NVML library, and the InterfaceMock can be used in unit tests.
+//
+// The resource managers that build the devices of the plugins when they are
+// (re)started, the GFD labelers and the MPS control daemon use go-nvlib, which
+// requires an nvml.Interface. These are mocked through the nvml.Interface and
+// are not covered by this facade.
+package nvcaps
+
+import (
+	"errors"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// Event types that can be registered for.
+const (
+	EventTypeXidCriticalError  = uint64(nvml.EventTypeXidCriticalError)
+	EventTypeDoubleBitEccError = uint64(nvml.EventTypeDoubleBitEccError)
+	EventTypeSingleBitEccError = uint64(nvml.EventTypeSingleBitEccError)
+)
+
+// InvalidInstanceID is used for GPU and compute instance IDs that do not
+// refer to a MIG device.
+const InvalidInstanceID = 0xFFFFFFFF
+
+var (
+	// ErrTimeout is returned if no event was received before the timeout expired.
+	ErrTimeout = errors.New("timeout")
+	// ErrNotSupported is returned if an operation is not supported by the device.
+	ErrNotSupported = errors.New("not supported")
+	// ErrDeviceNotFound is returned if no device handle could be obtained for a UUID.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrUnknownEventSet is returned if an event set is not known to the implementation.
+	ErrUnknownEventSet = errors.New("unknown event set")
+	// ErrUnavailable is returned if an out-of-process implementation cannot be reached.
+	ErrUnavailable = errors.New("nvml broker unavailable")
+)
+
+// Interface defines the NVML operations used by the device plugin.
+//
+//go:generate moq -rm -out api_mock.go . Interface
+type Interface interface {
+	Init() error
+	Shutdown() error
+	GetMigDevicePlacement(uuid string) (Placement, error)
+	EventSetCreate() (EventSetID, error)
+	EventSetFree(EventSetID) error
+	EventSetWait(set EventSetID, timeoutMs uint32) (Event, error)
+	RegisterEvents(set EventSetID, uuid string, eventTypes uint64) error
+	GetName(uuid string) (string, error)
+	GetTemperature(uuid string) (uint32, error)
+	GetUncorrectedECCErrors(uuid string) (uint64, error)
+	GetMemoryInfo(uuid string) (Memory, error)
+	GetWear(uuid string) (Wear, error)
+	GetClocks(uuid string) (Clocks, error)
+	GetMaxClocks(uuid string) (Clocks, error)
+	SetClocks(uuid string, clocks Clocks) error
+	GetGPUs() ([]GPU, error)
+}
+
+// GPU defines a GPU of the node and its MIG devices.
+type GPU struct {
+	Index int
+	UUID  string
+	// MigEnabled indicates whether MIG mode is enabled on the GPU.
+	MigEnabled bool
+	// MigUUIDs are the UUIDs of the MIG devices of a GPU with MIG enabled.
+	MigUUIDs []string
+}
+
+// Clocks defines the application clocks and the power limit of a device.
+type Clocks struct {
+	GraphicsMHz          uint32
+	MemoryMHz            uint32
+	PowerLimitMilliwatts uint32
+}
+
+// Memory defines the total and used framebuffer memory of a device.
+type Memory struct {
+	TotalBytes uint64
+	UsedBytes  uint64
+}
+
+// Wear defines the counters that indicate the age and wear of a device.
+// Counters that are not supported by the device are nil.
+type Wear struct {
+	// RetiredPages is the number of memory pages retired due to single-bit
+	// and double-bit ECC errors.
+	RetiredPages *uint64
+	// RemappedRows is the number of memory rows remapped due to correctable
+	// and uncorrectable errors.
+	RemappedRows *uint64
+	// EnergyMillijoules is the total energy consumed by the device since the
+	// driver was last loaded.
+	EnergyMillijoules *uint64
+}
+
+// EventSetID refers to an event set created by EventSetCreate.
+type EventSetID uint64
+
+// Placement defines the parent GPU and the GPU and compute instances of a MIG device.
+type Placement struct {
+	ParentUUID        string
+	GpuInstanceID     int
+	ComputeInstanceID int
+}
+
+// Event is an NVML event received on an event set.
+//
+// If the UUID of the device associated with the event could not be
+// determined, UUID is empty.
+type Event struct {
+	UUID              string
+	Type              uint64
+	Data              uint64
+	GpuInstanceID     uint32
+	ComputeInstanceID uint32
+}
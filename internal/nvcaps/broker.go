@@ -0,0 +1,147 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nvcaps
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	brokerMinBackoff = 1 * time.Second
+	brokerMaxBackoff = 30 * time.Second
+)
+
+// Broker runs an NVML broker as a subprocess and restarts it whenever it
+// exits. The subprocess is expected to serve an Interface on the broker
+// socket using Serve.
+type Broker struct {
+	socket  string
+	command []string
+
+	sync.Mutex
+	cmd  *exec.Cmd
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBroker creates a broker that runs the specified command. The command
+// must serve the Interface on the specified socket.
+func NewBroker(socket string, command ...string) *Broker {
+	return &Broker{
+		socket:  socket,
+		command: command,
+	}
+}
+
+// Client returns an Interface that forwards calls to the broker.
+func (b *Broker) Client() Interface {
+	return NewClient(b.socket)
+}
+
+// Start starts the broker subprocess and the goroutine that restarts it.
+func (b *Broker) Start() error {
+	if len(b.command) == 0 {
+		return fmt.Errorf("no broker command specified")
+	}
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	go b.run()
+	return nil
+}
+
+// Stop terminates the broker subprocess and waits for it to exit.
+func (b *Broker) Stop() error {
+	if b == nil || b.stop == nil {
+		return nil
+	}
+	close(b.stop)
+
+	b.Lock()
+	if b.cmd != nil && b.cmd.Process != nil {
+		_ = b.cmd.Process.Signal(syscall.SIGTERM)
+	}
+	b.Unlock()
+
+	<-b.done
+	return nil
+}
+
+func (b *Broker) run() {
+	defer close(b.done)
+
+	backoff := brokerMinBackoff
+	for {
+		started := time.Now()
+		err := b.runOnce()
+
+		select {
+		case <-b.stop:
+			return
+		default:
+		}
+
+		// Reset the backoff if the broker was running for a while.
+		if time.Since(started) > brokerMaxBackoff {
+			backoff = brokerMinBackoff
+		}
+		klog.Warningf("NVML broker exited: %v; restarting in %v", err, backoff)
+
+		select {
+		case <-b.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, brokerMaxBackoff)
+	}
+}
+
+func (b *Broker) runOnce() error {
+	cmd := exec.Command(b.command[0], b.command[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	b.Lock()
+	select {
+	case <-b.stop:
+		b.Unlock()
+		return nil
+	default:
+	}
+	err := cmd.Start()
+	if err == nil {
+		b.cmd = cmd
+	}
+	b.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to start broker: %w", err)
+	}
+
+	klog.Infof("Started NVML broker (pid %d) on %v", cmd.Process.Pid, b.socket)
+	err = cmd.Wait()
+
+	b.Lock()
+	b.cmd = nil
+	b.Unlock()
+	return err
+}
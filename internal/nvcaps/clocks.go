@@ -0,0 +1,124 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nvcaps
+
+import (
+	"unsafe"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+/*
+#cgo linux LDFLAGS: -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+
+typedef struct nvmlDevice_st* nvmlDevice_t;
+typedef int nvmlReturn_t;
+
+#define NVML_SUCCESS 0
+#define NVML_ERROR_UNINITIALIZED 1
+#define NVML_ERROR_FUNCTION_NOT_FOUND 13
+
+typedef nvmlReturn_t (*getHandleByUUID_t)(const char *, nvmlDevice_t *);
+typedef nvmlReturn_t (*getSupportedMemoryClocks_t)(nvmlDevice_t, unsigned int *, unsigned int *);
+typedef nvmlReturn_t (*getSupportedGraphicsClocks_t)(nvmlDevice_t, unsigned int, unsigned int *, unsigned int *);
+
+// getSupportedClocks lists the supported memory clocks of a device, or the
+// supported graphics clocks for the specified memory clock if graphics is set.
+// The functions are looked up in the NVML library only if it is already
+// loaded, so that no NVML library is loaded if go-nvml did not load one.
+static nvmlReturn_t getSupportedClocks(const char *uuid, int graphics, unsigned int memoryClockMHz, unsigned int *count, unsigned int *clocksMHz) {
+	void *lib = dlopen("libnvidia-ml.so.1", RTLD_LAZY | RTLD_NOLOAD);
+	if (lib == NULL) {
+		return NVML_ERROR_UNINITIALIZED;
+	}
+	nvmlReturn_t ret = NVML_ERROR_FUNCTION_NOT_FOUND;
+	getHandleByUUID_t getHandle = (getHandleByUUID_t)dlsym(lib, "nvmlDeviceGetHandleByUUID");
+	getSupportedMemoryClocks_t getMemoryClocks = (getSupportedMemoryClocks_t)dlsym(lib, "nvmlDeviceGetSupportedMemoryClocks");
+	getSupportedGraphicsClocks_t getGraphicsClocks = (getSupportedGraphicsClocks_t)dlsym(lib, "nvmlDeviceGetSupportedGraphicsClocks");
+	if (getHandle != NULL && getMemoryClocks != NULL && getGraphicsClocks != NULL) {
+		nvmlDevice_t device;
+		ret = getHandle(uuid, &device);
+		if (ret == NVML_SUCCESS) {
+			if (graphics) {
+				ret = getGraphicsClocks(device, memoryClockMHz, count, clocksMHz);
+			} else {
+				ret = getMemoryClocks(device, count, clocksMHz);
+			}
+		}
+	}
+	dlclose(lib);
+	return ret;
+}
+*/
+import "C"
+
+// SupportedClocksLister lists the application clocks supported by a device.
+// The go-nvml bindings of nvmlDeviceGetSupportedMemoryClocks and
+// nvmlDeviceGetSupportedGraphicsClocks only return a single clock, so the
+// clocks are listed through this interface instead. If the nvml.Interface
+// passed to New implements it, e.g. a mock, it is used. Otherwise the
+// functions are looked up in the NVML library loaded by go-nvml.
+type SupportedClocksLister interface {
+	GetSupportedMemoryClocks(uuid string) ([]uint32, nvml.Return)
+	GetSupportedGraphicsClocks(uuid string, memoryClockMHz uint32) ([]uint32, nvml.Return)
+}
+
+// nvmlSupportedClocks lists the supported application clocks through the
+// NVML library loaded by go-nvml.
+type nvmlSupportedClocks struct{}
+
+// GetSupportedMemoryClocks returns the supported memory clocks of the device.
+func (nvmlSupportedClocks) GetSupportedMemoryClocks(uuid string) ([]uint32, nvml.Return) {
+	return getSupportedClocks(uuid, false, 0)
+}
+
+// GetSupportedGraphicsClocks returns the graphics clocks of the device that
+// are supported with the specified memory clock.
+func (nvmlSupportedClocks) GetSupportedGraphicsClocks(uuid string, memoryClockMHz uint32) ([]uint32, nvml.Return) {
+	return getSupportedClocks(uuid, true, memoryClockMHz)
+}
+
+// getSupportedClocks first gets the number of clocks and then the clocks.
+func getSupportedClocks(uuid string, graphics bool, memoryClockMHz uint32) ([]uint32, nvml.Return) {
+	cUUID := C.CString(uuid)
+	defer C.free(unsafe.Pointer(cUUID))
+	var cGraphics C.int
+	if graphics {
+		cGraphics = 1
+	}
+
+	var count C.uint
+	ret := nvml.Return(C.getSupportedClocks(cUUID, cGraphics, C.uint(memoryClockMHz), &count, nil))
+	if ret != nvml.SUCCESS && ret != nvml.ERROR_INSUFFICIENT_SIZE {
+		return nil, ret
+	}
+	if count == 0 {
+		return nil, nvml.SUCCESS
+	}
+	clocks := make([]C.uint, count)
+	if ret := nvml.Return(C.getSupportedClocks(cUUID, cGraphics, C.uint(memoryClockMHz), &count, &clocks[0])); ret != nvml.SUCCESS {
+		return nil, ret
+	}
+	var result []uint32
+	for _, c := range clocks[:count] {
+		result = append(result, uint32(c))
+	}
+	return result, nvml.SUCCESS
+}
@@ -0,0 +1,431 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nvcaps
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+type nvmllib struct {
+	nvml   nvml.Interface
+	clocks SupportedClocksLister
+
+	sync.Mutex
+	nextEventSetID EventSetID
+	eventSets      map[EventSetID]nvml.EventSet
+}
+
+var _ Interface = (*nvmllib)(nil)
+
+// New creates an Interface that calls the specified NVML library in-process.
+// The supported application clocks are listed by the library if it
+// implements SupportedClocksLister.
+func New(nvmlib nvml.Interface) Interface {
+	clocks, ok := nvmlib.(SupportedClocksLister)
+	if !ok {
+		clocks = nvmlSupportedClocks{}
+	}
+	return &nvmllib{
+		nvml:      nvmlib,
+		clocks:    clocks,
+		eventSets: make(map[EventSetID]nvml.EventSet),
+	}
+}
+
+// Init initializes the underlying NVML library.
+func (l *nvmllib) Init() error {
+	return toError(l.nvml.Init())
+}
+
+// Shutdown shuts down the underlying NVML library.
+func (l *nvmllib) Shutdown() error {
+	return toError(l.nvml.Shutdown())
+}
+
+// GetMigDevicePlacement returns the parent UUID and the GI and CI of the
+// specified MIG device.
+func (l *nvmllib) GetMigDevicePlacement(uuid string) (Placement, error) {
+	mig, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return Placement{}, fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	parent, ret := mig.GetDeviceHandleFromMigDeviceHandle()
+	if ret != nvml.SUCCESS {
+		return Placement{}, fmt.Errorf("failed to get parent device handle: %w", toError(ret))
+	}
+	parentUUID, ret := parent.GetUUID()
+	if ret != nvml.SUCCESS {
+		return Placement{}, fmt.Errorf("failed to get parent uuid: %w", toError(ret))
+	}
+	gi, ret := mig.GetGpuInstanceId()
+	if ret != nvml.SUCCESS {
+		return Placement{}, fmt.Errorf("failed to get GPU Instance ID: %w", toError(ret))
+	}
+	ci, ret := mig.GetComputeInstanceId()
+	if ret != nvml.SUCCESS {
+		return Placement{}, fmt.Errorf("failed to get Compute Instance ID: %w", toError(ret))
+	}
+	return Placement{
+		ParentUUID:        parentUUID,
+		GpuInstanceID:     gi,
+		ComputeInstanceID: ci,
+	}, nil
+}
+
+// EventSetCreate creates a new event set.
+func (l *nvmllib) EventSetCreate() (EventSetID, error) {
+	set, ret := l.nvml.EventSetCreate()
+	if ret != nvml.SUCCESS {
+		return 0, toError(ret)
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.nextEventSetID++
+	l.eventSets[l.nextEventSetID] = set
+	return l.nextEventSetID, nil
+}
+
+// EventSetFree releases the specified event set.
+func (l *nvmllib) EventSetFree(id EventSetID) error {
+	l.Lock()
+	set, exists := l.eventSets[id]
+	delete(l.eventSets, id)
+	l.Unlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrUnknownEventSet, id)
+	}
+	return toError(set.Free())
+}
+
+// EventSetWait waits for an event on the specified event set.
+func (l *nvmllib) EventSetWait(id EventSetID, timeoutMs uint32) (Event, error) {
+	set, err := l.getEventSet(id)
+	if err != nil {
+		return Event{}, err
+	}
+
+	e, ret := set.Wait(timeoutMs)
+	if ret != nvml.SUCCESS {
+		return Event{}, toError(ret)
+	}
+
+	event := Event{
+		Type:              e.EventType,
+		Data:              e.EventData,
+		GpuInstanceID:     e.GpuInstanceId,
+		ComputeInstanceID: e.ComputeInstanceId,
+	}
+	if e.Device != nil {
+		if uuid, ret := e.Device.GetUUID(); ret == nvml.SUCCESS {
+			event.UUID = uuid
+		}
+	}
+	return event, nil
+}
+
+// RegisterEvents registers the specified device for the requested event
+// types on the event set. Event types that are not supported by the device
+// are ignored.
+func (l *nvmllib) RegisterEvents(id EventSetID, uuid string, eventTypes uint64) error {
+	set, err := l.getEventSet(id)
+	if err != nil {
+		return err
+	}
+
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+
+	supportedEvents, ret := gpu.GetSupportedEventTypes()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("unable to determine the supported events: %w", toError(ret))
+	}
+
+	return toError(gpu.RegisterEvents(eventTypes&supportedEvents, set))
+}
+
+// GetName returns the product name of the specified device.
+func (l *nvmllib) GetName(uuid string) (string, error) {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return "", fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	name, ret := gpu.GetName()
+	if ret != nvml.SUCCESS {
+		return "", toError(ret)
+	}
+	return name, nil
+}
+
+// GetTemperature returns the current GPU core temperature of the specified
+// device in degrees Celsius.
+func (l *nvmllib) GetTemperature(uuid string) (uint32, error) {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return 0, fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	temperature, ret := gpu.GetTemperature(nvml.TEMPERATURE_GPU)
+	if ret != nvml.SUCCESS {
+		return 0, toError(ret)
+	}
+	return temperature, nil
+}
+
+// GetUncorrectedECCErrors returns the number of uncorrected ECC errors of the
+// specified device since the driver was last loaded.
+func (l *nvmllib) GetUncorrectedECCErrors(uuid string) (uint64, error) {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return 0, fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	count, ret := gpu.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.VOLATILE_ECC)
+	if ret != nvml.SUCCESS {
+		return 0, toError(ret)
+	}
+	return count, nil
+}
+
+// GetMemoryInfo returns the total and used framebuffer memory of the specified device.
+func (l *nvmllib) GetMemoryInfo(uuid string) (Memory, error) {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return Memory{}, fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	memory, ret := gpu.GetMemoryInfo()
+	if ret != nvml.SUCCESS {
+		return Memory{}, toError(ret)
+	}
+	return Memory{TotalBytes: memory.Total, UsedBytes: memory.Used}, nil
+}
+
+// GetWear returns the retired page, remapped row, and energy counters of the
+// specified device.
+func (l *nvmllib) GetWear(uuid string) (Wear, error) {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return Wear{}, fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	values := []nvml.FieldValue{
+		{FieldId: nvml.FI_DEV_RETIRED_SBE},
+		{FieldId: nvml.FI_DEV_RETIRED_DBE},
+		{FieldId: nvml.FI_DEV_REMAPPED_COR},
+		{FieldId: nvml.FI_DEV_REMAPPED_UNC},
+		{FieldId: nvml.FI_DEV_TOTAL_ENERGY_CONSUMPTION},
+	}
+	if ret := gpu.GetFieldValues(values); ret != nvml.SUCCESS {
+		return Wear{}, toError(ret)
+	}
+	retiredPages, err := sumFieldValues(values[0:2])
+	if err != nil {
+		return Wear{}, fmt.Errorf("error getting retired pages: %w", err)
+	}
+	remappedRows, err := sumFieldValues(values[2:4])
+	if err != nil {
+		return Wear{}, fmt.Errorf("error getting remapped rows: %w", err)
+	}
+	energy, err := sumFieldValues(values[4:5])
+	if err != nil {
+		return Wear{}, fmt.Errorf("error getting total energy consumption: %w", err)
+	}
+	return Wear{
+		RetiredPages:      retiredPages,
+		RemappedRows:      remappedRows,
+		EnergyMillijoules: energy,
+	}, nil
+}
+
+// sumFieldValues returns the sum of the values of unsigned integer fields, or
+// nil if any of the fields is not supported.
+func sumFieldValues(values []nvml.FieldValue) (*uint64, error) {
+	var sum uint64
+	for _, v := range values {
+		switch ret := nvml.Return(v.NvmlReturn); ret {
+		case nvml.SUCCESS:
+		case nvml.ERROR_NOT_SUPPORTED:
+			return nil, nil
+		default:
+			return nil, ret
+		}
+		switch nvml.ValueType(v.ValueType) {
+		case nvml.VALUE_TYPE_UNSIGNED_INT:
+			sum += uint64(binary.NativeEndian.Uint32(v.Value[:4]))
+		case nvml.VALUE_TYPE_UNSIGNED_LONG, nvml.VALUE_TYPE_UNSIGNED_LONG_LONG:
+			sum += binary.NativeEndian.Uint64(v.Value[:])
+		default:
+			return nil, fmt.Errorf("unexpected value type %v for field %v", v.ValueType, v.FieldId)
+		}
+	}
+	return &sum, nil
+}
+
+// GetClocks returns the current application clocks and power limit of the specified device.
+func (l *nvmllib) GetClocks(uuid string) (Clocks, error) {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	graphics, ret := gpu.GetApplicationsClock(nvml.CLOCK_GRAPHICS)
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("failed to get graphics application clock: %w", toError(ret))
+	}
+	memory, ret := gpu.GetApplicationsClock(nvml.CLOCK_MEM)
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("failed to get memory application clock: %w", toError(ret))
+	}
+	power, ret := gpu.GetPowerManagementLimit()
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("failed to get power limit: %w", toError(ret))
+	}
+	return Clocks{GraphicsMHz: graphics, MemoryMHz: memory, PowerLimitMilliwatts: power}, nil
+}
+
+// GetMaxClocks returns the highest supported application clocks and the
+// maximum power limit of the specified device. The maximum clocks reported by
+// the device are not necessarily a supported combination of application
+// clocks.
+func (l *nvmllib) GetMaxClocks(uuid string) (Clocks, error) {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	memoryClocks, ret := l.clocks.GetSupportedMemoryClocks(uuid)
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("failed to get supported memory clocks: %w", toError(ret))
+	}
+	memory := slices.Max(append(memoryClocks, 0))
+	if memory == 0 {
+		return Clocks{}, fmt.Errorf("failed to get supported memory clocks: %w", ErrNotSupported)
+	}
+	graphicsClocks, ret := l.clocks.GetSupportedGraphicsClocks(uuid, memory)
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("failed to get supported graphics clocks: %w", toError(ret))
+	}
+	graphics := slices.Max(append(graphicsClocks, 0))
+	if graphics == 0 {
+		return Clocks{}, fmt.Errorf("failed to get supported graphics clocks: %w", ErrNotSupported)
+	}
+	_, power, ret := gpu.GetPowerManagementLimitConstraints()
+	if ret != nvml.SUCCESS {
+		return Clocks{}, fmt.Errorf("failed to get power limit constraints: %w", toError(ret))
+	}
+	return Clocks{GraphicsMHz: graphics, MemoryMHz: memory, PowerLimitMilliwatts: power}, nil
+}
+
+// SetClocks sets the application clocks and power limit of the specified device.
+func (l *nvmllib) SetClocks(uuid string, clocks Clocks) error {
+	gpu, ret := l.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("%w: %v", ErrDeviceNotFound, ret)
+	}
+	if ret := gpu.SetApplicationsClocks(clocks.MemoryMHz, clocks.GraphicsMHz); ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to set application clocks: %w", toError(ret))
+	}
+	if ret := gpu.SetPowerManagementLimit(clocks.PowerLimitMilliwatts); ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to set power limit: %w", toError(ret))
+	}
+	return nil
+}
+
+func (l *nvmllib) getEventSet(id EventSetID) (nvml.EventSet, error) {
+	l.Lock()
+	defer l.Unlock()
+	set, exists := l.eventSets[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownEventSet, id)
+	}
+	return set, nil
+}
+
+// toError converts an NVML return value to an error.
+// Return values that have an equivalent error defined in this package are
+// wrapped so that they can be checked using errors.Is.
+func toError(ret nvml.Return) error {
+	switch ret {
+	case nvml.SUCCESS:
+		return nil
+	case nvml.ERROR_TIMEOUT:
+		return fmt.Errorf("%w: %v", ErrTimeout, ret)
+	case nvml.ERROR_NOT_SUPPORTED:
+		return fmt.Errorf("%w: %v", ErrNotSupported, ret)
+	default:
+		return ret
+	}
+}
+
+// GetGPUs returns the GPUs of the node and their MIG devices. GPUs that have
+// fallen off the bus are omitted.
+func (l *nvmllib) GetGPUs() ([]GPU, error) {
+	count, ret := l.nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get device count: %w", toError(ret))
+	}
+
+	devicelib := device.New(l.nvml)
+	var gpus []GPU
+	for i := 0; i < count; i++ {
+		handle, ret := l.nvml.DeviceGetHandleByIndex(i)
+		if ret == nvml.ERROR_GPU_IS_LOST {
+			klog.Warningf("Device %d has fallen off the bus", i)
+			continue
+		}
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get handle of device %d: %w", i, toError(ret))
+		}
+		uuid, ret := handle.GetUUID()
+		if ret == nvml.ERROR_GPU_IS_LOST {
+			klog.Warningf("Device %d has fallen off the bus", i)
+			continue
+		}
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get UUID of device %d: %w", i, toError(ret))
+		}
+		d, err := devicelib.NewDevice(handle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct device %d: %w", i, err)
+		}
+		gpu := GPU{Index: i, UUID: uuid}
+		gpu.MigEnabled, err = d.IsMigEnabled()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check MIG mode of device %v: %w", uuid, err)
+		}
+		if gpu.MigEnabled {
+			migs, err := d.GetMigDevices()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get MIG devices of device %v: %w", uuid, err)
+			}
+			for _, mig := range migs {
+				migUUID, ret := mig.GetUUID()
+				if ret != nvml.SUCCESS {
+					return nil, fmt.Errorf("failed to get UUID of MIG device of device %v: %w", uuid, toError(ret))
+				}
+				gpu.MigUUIDs = append(gpu.MigUUIDs, migUUID)
+			}
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
@@ -0,0 +1,85 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nvcaps
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+type testNvml struct {
+	nvml.Interface
+	memoryClocks   []uint32
+	graphicsClocks map[uint32][]uint32
+}
+
+func (l *testNvml) DeviceGetHandleByUUID(uuid string) (nvml.Device, nvml.Return) {
+	if uuid != "GPU-0" {
+		return nil, nvml.ERROR_NOT_FOUND
+	}
+	return testDevice{}, nvml.SUCCESS
+}
+
+func (l *testNvml) GetSupportedMemoryClocks(uuid string) ([]uint32, nvml.Return) {
+	return l.memoryClocks, nvml.SUCCESS
+}
+
+func (l *testNvml) GetSupportedGraphicsClocks(uuid string, memoryClockMHz uint32) ([]uint32, nvml.Return) {
+	clocks, ok := l.graphicsClocks[memoryClockMHz]
+	if !ok {
+		return nil, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return clocks, nvml.SUCCESS
+}
+
+type testDevice struct {
+	nvml.Device
+}
+
+func (testDevice) GetPowerManagementLimitConstraints() (uint32, uint32, nvml.Return) {
+	return 60000, 70000, nvml.SUCCESS
+}
+
+func TestGetMaxClocks(t *testing.T) {
+	lib := &testNvml{
+		memoryClocks: []uint32{405, 5001, 810},
+		graphicsClocks: map[uint32][]uint32{
+			5001: {1590, 585, 1350},
+			810:  {1950},
+		},
+	}
+	nvcapslib := New(lib)
+
+	clocks, err := nvcapslib.GetMaxClocks("GPU-0")
+	require.NoError(t, err)
+	require.Equal(t, Clocks{GraphicsMHz: 1590, MemoryMHz: 5001, PowerLimitMilliwatts: 70000}, clocks)
+
+	_, err = nvcapslib.GetMaxClocks("GPU-1")
+	require.ErrorIs(t, err, ErrDeviceNotFound)
+
+	lib.memoryClocks = nil
+	_, err = nvcapslib.GetMaxClocks("GPU-0")
+	require.ErrorIs(t, err, ErrNotSupported)
+}
+
+func TestSupportedClocksWithoutNVML(t *testing.T) {
+	// No NVML library is loaded by the test, so none is looked up.
+	_, ret := nvmlSupportedClocks{}.GetSupportedMemoryClocks("GPU-0")
+	require.Equal(t, nvml.ERROR_UNINITIALIZED, ret)
+}
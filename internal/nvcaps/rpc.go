@@ -0,0 +1,521 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nvcaps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+const rpcServiceName = "NVCaps"
+
+// errorKind identifies the sentinel errors defined in this package so that
+// they survive the round-trip over RPC.
+type errorKind int
+
+const (
+	errorKindNone errorKind = iota
+	errorKindOther
+	errorKindTimeout
+	errorKindNotSupported
+	errorKindDeviceNotFound
+	errorKindUnknownEventSet
+)
+
+var errorKinds = map[errorKind]error{
+	errorKindTimeout:         ErrTimeout,
+	errorKindNotSupported:    ErrNotSupported,
+	errorKindDeviceNotFound:  ErrDeviceNotFound,
+	errorKindUnknownEventSet: ErrUnknownEventSet,
+}
+
+// RPCStatus is included in each RPC reply and holds the error returned by
+// the underlying implementation.
+type RPCStatus struct {
+	Kind    errorKind
+	Message string
+}
+
+func newRPCStatus(err error) RPCStatus {
+	if err == nil {
+		return RPCStatus{}
+	}
+	for kind, sentinel := range errorKinds {
+		if errors.Is(err, sentinel) {
+			return RPCStatus{Kind: kind, Message: err.Error()}
+		}
+	}
+	return RPCStatus{Kind: errorKindOther, Message: err.Error()}
+}
+
+func (s RPCStatus) err() error {
+	switch s.Kind {
+	case errorKindNone:
+		return nil
+	case errorKindOther:
+		return errors.New(s.Message)
+	}
+	return fmt.Errorf("%w: %s", errorKinds[s.Kind], s.Message)
+}
+
+// RPCEmpty is used for RPC calls without arguments.
+type RPCEmpty struct{}
+
+// RPCPlacementReply is the reply for GetMigDevicePlacement.
+type RPCPlacementReply struct {
+	RPCStatus
+	Placement Placement
+}
+
+// RPCEventSetReply is the reply for EventSetCreate.
+type RPCEventSetReply struct {
+	RPCStatus
+	EventSet EventSetID
+}
+
+// RPCEventSetWaitArgs are the arguments for EventSetWait.
+type RPCEventSetWaitArgs struct {
+	EventSet  EventSetID
+	TimeoutMs uint32
+}
+
+// RPCEventReply is the reply for EventSetWait.
+type RPCEventReply struct {
+	RPCStatus
+	Event Event
+}
+
+// RPCRegisterEventsArgs are the arguments for RegisterEvents.
+type RPCRegisterEventsArgs struct {
+	EventSet   EventSetID
+	UUID       string
+	EventTypes uint64
+}
+
+// RPCNameReply is the reply for GetName.
+type RPCNameReply struct {
+	RPCStatus
+	Name string
+}
+
+// RPCTemperatureReply is the reply for GetTemperature.
+type RPCTemperatureReply struct {
+	RPCStatus
+	Temperature uint32
+}
+
+// RPCECCErrorsReply is the reply for GetUncorrectedECCErrors.
+type RPCECCErrorsReply struct {
+	RPCStatus
+	Count uint64
+}
+
+// RPCMemoryReply is the reply for GetMemoryInfo.
+type RPCMemoryReply struct {
+	RPCStatus
+	Memory Memory
+}
+
+// RPCWearReply is the reply for GetWear.
+type RPCWearReply struct {
+	RPCStatus
+	Wear Wear
+}
+
+// RPCClocksReply is the reply for GetClocks and GetMaxClocks.
+type RPCClocksReply struct {
+	RPCStatus
+	Clocks Clocks
+}
+
+// RPCGPUsReply is the reply for GetGPUs.
+type RPCGPUsReply struct {
+	RPCStatus
+	GPUs []GPU
+}
+
+// RPCSetClocksArgs are the arguments for SetClocks.
+type RPCSetClocksArgs struct {
+	UUID   string
+	Clocks Clocks
+}
+
+// rpcServer exposes an Interface as an RPC service.
+type rpcServer struct {
+	lib     Interface
+	onFatal func(error)
+}
+
+// Serve serves the specified Interface on the listener until the context is
+// cancelled. If the underlying library reports an error indicating that the
+// driver is no longer usable, Serve returns that error so that the caller
+// can exit and be restarted with a fresh NVML session.
+func Serve(ctx context.Context, l net.Listener, lib Interface) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	server := rpc.NewServer()
+	err := server.RegisterName(rpcServiceName, &rpcServer{
+		lib:     lib,
+		onFatal: cancel,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register RPC service: %w", err)
+	}
+
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				if errors.Is(cause, context.Canceled) {
+					return nil
+				}
+				return cause
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		mu.Lock()
+		conns[conn] = true
+		mu.Unlock()
+		go func() {
+			server.ServeConn(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+}
+
+// status converts the error into an RPCStatus and notifies the server if
+// the error is fatal.
+func (s *rpcServer) status(err error) RPCStatus {
+	if isFatal(err) {
+		klog.Errorf("NVML reported a fatal error: %v", err)
+		s.onFatal(err)
+	}
+	return newRPCStatus(err)
+}
+
+func (s *rpcServer) Init(_ RPCEmpty, reply *RPCStatus) error {
+	*reply = s.status(s.lib.Init())
+	return nil
+}
+
+func (s *rpcServer) Shutdown(_ RPCEmpty, reply *RPCStatus) error {
+	*reply = s.status(s.lib.Shutdown())
+	return nil
+}
+
+func (s *rpcServer) GetMigDevicePlacement(uuid string, reply *RPCPlacementReply) error {
+	placement, err := s.lib.GetMigDevicePlacement(uuid)
+	*reply = RPCPlacementReply{RPCStatus: s.status(err), Placement: placement}
+	return nil
+}
+
+func (s *rpcServer) EventSetCreate(_ RPCEmpty, reply *RPCEventSetReply) error {
+	set, err := s.lib.EventSetCreate()
+	*reply = RPCEventSetReply{RPCStatus: s.status(err), EventSet: set}
+	return nil
+}
+
+func (s *rpcServer) EventSetFree(set EventSetID, reply *RPCStatus) error {
+	*reply = s.status(s.lib.EventSetFree(set))
+	return nil
+}
+
+func (s *rpcServer) EventSetWait(args RPCEventSetWaitArgs, reply *RPCEventReply) error {
+	event, err := s.lib.EventSetWait(args.EventSet, args.TimeoutMs)
+	*reply = RPCEventReply{RPCStatus: s.status(err), Event: event}
+	return nil
+}
+
+func (s *rpcServer) RegisterEvents(args RPCRegisterEventsArgs, reply *RPCStatus) error {
+	*reply = s.status(s.lib.RegisterEvents(args.EventSet, args.UUID, args.EventTypes))
+	return nil
+}
+
+func (s *rpcServer) GetName(uuid string, reply *RPCNameReply) error {
+	name, err := s.lib.GetName(uuid)
+	*reply = RPCNameReply{RPCStatus: s.status(err), Name: name}
+	return nil
+}
+
+func (s *rpcServer) GetTemperature(uuid string, reply *RPCTemperatureReply) error {
+	temperature, err := s.lib.GetTemperature(uuid)
+	*reply = RPCTemperatureReply{RPCStatus: s.status(err), Temperature: temperature}
+	return nil
+}
+
+func (s *rpcServer) GetUncorrectedECCErrors(uuid string, reply *RPCECCErrorsReply) error {
+	count, err := s.lib.GetUncorrectedECCErrors(uuid)
+	*reply = RPCECCErrorsReply{RPCStatus: s.status(err), Count: count}
+	return nil
+}
+
+func (s *rpcServer) GetMemoryInfo(uuid string, reply *RPCMemoryReply) error {
+	memory, err := s.lib.GetMemoryInfo(uuid)
+	*reply = RPCMemoryReply{RPCStatus: s.status(err), Memory: memory}
+	return nil
+}
+
+func (s *rpcServer) GetWear(uuid string, reply *RPCWearReply) error {
+	wear, err := s.lib.GetWear(uuid)
+	*reply = RPCWearReply{RPCStatus: s.status(err), Wear: wear}
+	return nil
+}
+
+func (s *rpcServer) GetClocks(uuid string, reply *RPCClocksReply) error {
+	clocks, err := s.lib.GetClocks(uuid)
+	*reply = RPCClocksReply{RPCStatus: s.status(err), Clocks: clocks}
+	return nil
+}
+
+func (s *rpcServer) GetMaxClocks(uuid string, reply *RPCClocksReply) error {
+	clocks, err := s.lib.GetMaxClocks(uuid)
+	*reply = RPCClocksReply{RPCStatus: s.status(err), Clocks: clocks}
+	return nil
+}
+
+func (s *rpcServer) SetClocks(args RPCSetClocksArgs, reply *RPCStatus) error {
+	*reply = s.status(s.lib.SetClocks(args.UUID, args.Clocks))
+	return nil
+}
+
+func (s *rpcServer) GetGPUs(_ RPCEmpty, reply *RPCGPUsReply) error {
+	gpus, err := s.lib.GetGPUs()
+	*reply = RPCGPUsReply{RPCStatus: s.status(err), GPUs: gpus}
+	return nil
+}
+
+// isFatal checks whether an error indicates that the driver has gone away.
+func isFatal(err error) bool {
+	var ret nvml.Return
+	if !errors.As(err, &ret) {
+		return false
+	}
+	switch ret {
+	case nvml.ERROR_DRIVER_NOT_LOADED, nvml.ERROR_GPU_IS_LOST, nvml.ERROR_LIB_RM_VERSION_MISMATCH:
+		return true
+	}
+	return false
+}
+
+// rpcClient implements Interface by forwarding calls to an RPC server.
+type rpcClient struct {
+	socket string
+
+	sync.Mutex
+	client *rpc.Client
+}
+
+var _ Interface = (*rpcClient)(nil)
+
+// NewClient creates an Interface that forwards all calls to the server
+// listening on the specified unix socket. The connection is established
+// lazily and re-established after a failure, allowing the server to be
+// restarted transparently. Errors that occur while the server cannot be
+// reached wrap ErrUnavailable.
+func NewClient(socket string) Interface {
+	return &rpcClient{socket: socket}
+}
+
+func (c *rpcClient) getClient() (*rpc.Client, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.client != nil {
+		return c.client, nil
+	}
+	client, err := rpc.Dial("unix", c.socket)
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	return client, nil
+}
+
+func (c *rpcClient) reset(client *rpc.Client) {
+	c.Lock()
+	defer c.Unlock()
+	if c.client == client {
+		_ = c.client.Close()
+		c.client = nil
+	}
+}
+
+// call invokes the specified method, resetting the connection on transport errors.
+func (c *rpcClient) call(method string, args any, reply any) error {
+	client, err := c.getClient()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	err = client.Call(rpcServiceName+"."+method, args, reply)
+	if err != nil {
+		c.reset(client)
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return nil
+}
+
+func (c *rpcClient) Init() error {
+	var reply RPCStatus
+	if err := c.call("Init", RPCEmpty{}, &reply); err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *rpcClient) Shutdown() error {
+	var reply RPCStatus
+	if err := c.call("Shutdown", RPCEmpty{}, &reply); err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *rpcClient) GetMigDevicePlacement(uuid string) (Placement, error) {
+	var reply RPCPlacementReply
+	if err := c.call("GetMigDevicePlacement", uuid, &reply); err != nil {
+		return Placement{}, err
+	}
+	return reply.Placement, reply.err()
+}
+
+func (c *rpcClient) EventSetCreate() (EventSetID, error) {
+	var reply RPCEventSetReply
+	if err := c.call("EventSetCreate", RPCEmpty{}, &reply); err != nil {
+		return 0, err
+	}
+	return reply.EventSet, reply.err()
+}
+
+func (c *rpcClient) EventSetFree(set EventSetID) error {
+	var reply RPCStatus
+	if err := c.call("EventSetFree", set, &reply); err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *rpcClient) EventSetWait(set EventSetID, timeoutMs uint32) (Event, error) {
+	var reply RPCEventReply
+	args := RPCEventSetWaitArgs{EventSet: set, TimeoutMs: timeoutMs}
+	if err := c.call("EventSetWait", args, &reply); err != nil {
+		return Event{}, err
+	}
+	return reply.Event, reply.err()
+}
+
+func (c *rpcClient) RegisterEvents(set EventSetID, uuid string, eventTypes uint64) error {
+	var reply RPCStatus
+	args := RPCRegisterEventsArgs{EventSet: set, UUID: uuid, EventTypes: eventTypes}
+	if err := c.call("RegisterEvents", args, &reply); err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *rpcClient) GetName(uuid string) (string, error) {
+	var reply RPCNameReply
+	if err := c.call("GetName", uuid, &reply); err != nil {
+		return "", err
+	}
+	return reply.Name, reply.err()
+}
+
+func (c *rpcClient) GetTemperature(uuid string) (uint32, error) {
+	var reply RPCTemperatureReply
+	if err := c.call("GetTemperature", uuid, &reply); err != nil {
+		return 0, err
+	}
+	return reply.Temperature, reply.err()
+}
+
+func (c *rpcClient) GetUncorrectedECCErrors(uuid string) (uint64, error) {
+	var reply RPCECCErrorsReply
+	if err := c.call("GetUncorrectedECCErrors", uuid, &reply); err != nil {
+		return 0, err
+	}
+	return reply.Count, reply.err()
+}
+
+func (c *rpcClient) GetMemoryInfo(uuid string) (Memory, error) {
+	var reply RPCMemoryReply
+	if err := c.call("GetMemoryInfo", uuid, &reply); err != nil {
+		return Memory{}, err
+	}
+	return reply.Memory, reply.err()
+}
+
+func (c *rpcClient) GetWear(uuid string) (Wear, error) {
+	var reply RPCWearReply
+	if err := c.call("GetWear", uuid, &reply); err != nil {
+		return Wear{}, err
+	}
+	return reply.Wear, reply.err()
+}
+
+func (c *rpcClient) GetClocks(uuid string) (Clocks, error) {
+	var reply RPCClocksReply
+	if err := c.call("GetClocks", uuid, &reply); err != nil {
+		return Clocks{}, err
+	}
+	return reply.Clocks, reply.err()
+}
+
+func (c *rpcClient) GetMaxClocks(uuid string) (Clocks, error) {
+	var reply RPCClocksReply
+	if err := c.call("GetMaxClocks", uuid, &reply); err != nil {
+		return Clocks{}, err
+	}
+	return reply.Clocks, reply.err()
+}
+
+func (c *rpcClient) SetClocks(uuid string, clocks Clocks) error {
+	var reply RPCStatus
+	args := RPCSetClocksArgs{UUID: uuid, Clocks: clocks}
+	if err := c.call("SetClocks", args, &reply); err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *rpcClient) GetGPUs() ([]GPU, error) {
+	var reply RPCGPUsReply
+	if err := c.call("GetGPUs", RPCEmpty{}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.GPUs, reply.err()
+}
@@ -0,0 +1,162 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package nvcaps
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T, socket string, lib Interface) func() {
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Serve(ctx, l, lib)
+	}()
+	return func() {
+		cancel()
+		require.NoError(t, <-done)
+	}
+}
+
+func TestRPCRoundTrip(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nvcaps.sock")
+	lib := &InterfaceMock{
+		InitFunc: func() error { return nil },
+		GetMigDevicePlacementFunc: func(uuid string) (Placement, error) {
+			if uuid == "MIG-unknown" {
+				return Placement{}, ErrDeviceNotFound
+			}
+			return Placement{ParentUUID: "GPU-0", GpuInstanceID: 1, ComputeInstanceID: 2}, nil
+		},
+		EventSetCreateFunc: func() (EventSetID, error) { return 7, nil },
+		EventSetWaitFunc: func(set EventSetID, timeoutMs uint32) (Event, error) {
+			if timeoutMs == 0 {
+				return Event{}, ErrTimeout
+			}
+			return Event{UUID: "GPU-0", Type: EventTypeXidCriticalError, Data: uint64(set)}, nil
+		},
+		RegisterEventsFunc: func(EventSetID, string, uint64) error {
+			return errors.New("some error")
+		},
+		GetNameFunc: func(uuid string) (string, error) { return "Tesla T4", nil },
+		GetTemperatureFunc: func(uuid string) (uint32, error) {
+			if uuid == "MIG-0" {
+				return 0, ErrNotSupported
+			}
+			return 83, nil
+		},
+		GetUncorrectedECCErrorsFunc: func(uuid string) (uint64, error) { return 2, nil },
+		GetMemoryInfoFunc: func(uuid string) (Memory, error) {
+			return Memory{TotalBytes: 16 << 30, UsedBytes: 1 << 20}, nil
+		},
+		GetClocksFunc: func(uuid string) (Clocks, error) {
+			return Clocks{GraphicsMHz: 585, MemoryMHz: 5001, PowerLimitMilliwatts: 70000}, nil
+		},
+		SetClocksFunc: func(uuid string, clocks Clocks) error {
+			return ErrNotSupported
+		},
+		GetGPUsFunc: func() ([]GPU, error) {
+			return []GPU{{Index: 0, UUID: "GPU-0", MigEnabled: true, MigUUIDs: []string{"MIG-0"}}}, nil
+		},
+	}
+	stop := startTestServer(t, socket, lib)
+	defer stop()
+
+	client := NewClient(socket)
+	require.NoError(t, client.Init())
+
+	placement, err := client.GetMigDevicePlacement("MIG-0")
+	require.NoError(t, err)
+	require.Equal(t, Placement{ParentUUID: "GPU-0", GpuInstanceID: 1, ComputeInstanceID: 2}, placement)
+
+	_, err = client.GetMigDevicePlacement("MIG-unknown")
+	require.ErrorIs(t, err, ErrDeviceNotFound)
+
+	set, err := client.EventSetCreate()
+	require.NoError(t, err)
+	require.EqualValues(t, 7, set)
+
+	event, err := client.EventSetWait(set, 100)
+	require.NoError(t, err)
+	require.Equal(t, Event{UUID: "GPU-0", Type: EventTypeXidCriticalError, Data: 7}, event)
+
+	_, err = client.EventSetWait(set, 0)
+	require.ErrorIs(t, err, ErrTimeout)
+
+	err = client.RegisterEvents(set, "GPU-0", EventTypeXidCriticalError)
+	require.EqualError(t, err, "some error")
+
+	name, err := client.GetName("GPU-0")
+	require.NoError(t, err)
+	require.Equal(t, "Tesla T4", name)
+
+	temperature, err := client.GetTemperature("GPU-0")
+	require.NoError(t, err)
+	require.EqualValues(t, 83, temperature)
+
+	_, err = client.GetTemperature("MIG-0")
+	require.ErrorIs(t, err, ErrNotSupported)
+
+	eccErrors, err := client.GetUncorrectedECCErrors("GPU-0")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, eccErrors)
+
+	memory, err := client.GetMemoryInfo("GPU-0")
+	require.NoError(t, err)
+	require.Equal(t, Memory{TotalBytes: 16 << 30, UsedBytes: 1 << 20}, memory)
+
+	clocks, err := client.GetClocks("GPU-0")
+	require.NoError(t, err)
+	require.Equal(t, Clocks{GraphicsMHz: 585, MemoryMHz: 5001, PowerLimitMilliwatts: 70000}, clocks)
+
+	err = client.SetClocks("GPU-0", Clocks{GraphicsMHz: 1590, MemoryMHz: 5001, PowerLimitMilliwatts: 70000})
+	require.ErrorIs(t, err, ErrNotSupported)
+	require.Equal(t, "GPU-0", lib.SetClocksCalls()[0].UUID)
+
+	gpus, err := client.GetGPUs()
+	require.NoError(t, err)
+	require.Equal(t, []GPU{{Index: 0, UUID: "GPU-0", MigEnabled: true, MigUUIDs: []string{"MIG-0"}}}, gpus)
+}
+
+func TestRPCClientReconnects(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nvcaps.sock")
+	lib := &InterfaceMock{
+		InitFunc: func() error { return nil },
+	}
+
+	client := NewClient(socket)
+	require.ErrorIs(t, client.Init(), ErrUnavailable)
+
+	stop := startTestServer(t, socket, lib)
+	require.NoError(t, client.Init())
+	stop()
+
+	require.ErrorIs(t, client.Init(), ErrUnavailable)
+
+	stop = startTestServer(t, socket, lib)
+	defer stop()
+	require.NoError(t, client.Init())
+}
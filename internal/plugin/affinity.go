@@ -0,0 +1,104 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+const (
+	// cpuAffinityEnvvar lists the CPUs that are closest to any of the
+	// allocated devices.
+	cpuAffinityEnvvar = "NVIDIA_CPU_AFFINITY"
+	// numaNodesEnvvar lists the NUMA nodes of the allocated devices.
+	numaNodesEnvvar = "NVIDIA_NUMA_NODES"
+	// gpuCPUAffinityEnvvar lists the CPUs that are closest to each of the
+	// allocated devices in the order of the devices in the container.
+	gpuCPUAffinityEnvvar = "NVIDIA_GPU_CPU_AFFINITY"
+)
+
+// updateResponseForCPUAffinity sets the envvars that describe the CPUs and
+// NUMA nodes that are closest to the requested devices. Replicas of the same
+// GPU are only included once.
+func updateResponseForCPUAffinity(response *pluginapi.ContainerAllocateResponse, devices rm.Devices, requestIds []string) {
+	cpus := make(map[int]bool)
+	nodes := make(map[int64]bool)
+	var perDevice []string
+	for _, id := range rm.AnnotatedIDs(requestIds).UniqueByID() {
+		d := devices.GetByID(id)
+		if d == nil {
+			continue
+		}
+		for _, cpu := range d.CPUAffinity {
+			cpus[cpu] = true
+		}
+		perDevice = append(perDevice, formatCPUList(d.CPUAffinity))
+		for _, node := range d.GetTopology().GetNodes() {
+			nodes[node.GetID()] = true
+		}
+	}
+
+	if len(cpus) > 0 {
+		var list []int
+		for cpu := range cpus {
+			list = append(list, cpu)
+		}
+		response.Envs[cpuAffinityEnvvar] = formatCPUList(list)
+		response.Envs[gpuCPUAffinityEnvvar] = strings.Join(perDevice, ";")
+	}
+	if len(nodes) > 0 {
+		var list []int64
+		for node := range nodes {
+			list = append(list, node)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+		var formatted []string
+		for _, node := range list {
+			formatted = append(formatted, strconv.FormatInt(node, 10))
+		}
+		response.Envs[numaNodesEnvvar] = strings.Join(formatted, ",")
+	}
+}
+
+// formatCPUList formats the specified CPUs in the cpuset list format, e.g.
+// 0-3,8,10-11.
+func formatCPUList(cpus []int) string {
+	sorted := append([]int(nil), cpus...)
+	sort.Ints(sorted)
+
+	var ranges []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(sorted[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
@@ -0,0 +1,80 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestUpdateResponseForCPUAffinity(t *testing.T) {
+	device := func(id string, numa int64, cpus ...int) *rm.Device {
+		d := &rm.Device{Device: pluginapi.Device{ID: id}, CPUAffinity: cpus}
+		if numa >= 0 {
+			d.Topology = &pluginapi.TopologyInfo{Nodes: []*pluginapi.NUMANode{{ID: numa}}}
+		}
+		return d
+	}
+	devices := rm.Devices{
+		"GPU-0::0": device("GPU-0::0", 0, 0, 1, 2, 3, 8),
+		"GPU-0::1": device("GPU-0::1", 0, 0, 1, 2, 3, 8),
+		"GPU-1":    device("GPU-1", 1, 4, 5, 6, 7),
+		"GPU-2":    device("GPU-2", -1),
+	}
+
+	testCases := []struct {
+		description  string
+		requestIds   []string
+		expectedEnvs map[string]string
+	}{
+		{
+			description: "replicas of a GPU are included once",
+			requestIds:  []string{"GPU-0::0", "GPU-0::1"},
+			expectedEnvs: map[string]string{
+				"NVIDIA_CPU_AFFINITY":     "0-3,8",
+				"NVIDIA_GPU_CPU_AFFINITY": "0-3,8",
+				"NVIDIA_NUMA_NODES":       "0",
+			},
+		},
+		{
+			description: "CPUs of multiple GPUs are merged",
+			requestIds:  []string{"GPU-1", "GPU-0::0"},
+			expectedEnvs: map[string]string{
+				"NVIDIA_CPU_AFFINITY":     "0-8",
+				"NVIDIA_GPU_CPU_AFFINITY": "4-7;0-3,8",
+				"NVIDIA_NUMA_NODES":       "0,1",
+			},
+		},
+		{
+			description:  "affinity is unknown",
+			requestIds:   []string{"GPU-2"},
+			expectedEnvs: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			response := &pluginapi.ContainerAllocateResponse{Envs: make(map[string]string)}
+			updateResponseForCPUAffinity(response, devices, tc.requestIds)
+			require.Equal(t, tc.expectedEnvs, response.Envs)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"os"
+	"sync"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+)
+
+// allocateCache caches the resolution of CDI devices and device nodes across
+// the container requests of a single Allocate call, which are processed
+// concurrently. Containers that share devices, or that are injected with the
+// same transient CDI spec, only resolve them once, even if they do so at the
+// same time. Errors are cached along with the values so that all containers
+// observe the same result. A nil cache resolves every lookup directly.
+type allocateCache struct {
+	sync.Mutex
+	qualifiedNames    map[string]*cachedValue[string]
+	exists            map[string]*cachedValue[bool]
+	topologyDevices   map[string]*cachedValue[string]
+	cudaCompatDevices map[string]*cachedValue[string]
+}
+
+// cachedValue holds a value that is resolved on first use.
+type cachedValue[T any] struct {
+	once  sync.Once
+	value T
+	err   error
+}
+
+func newAllocateCache() *allocateCache {
+	return &allocateCache{
+		qualifiedNames:    make(map[string]*cachedValue[string]),
+		exists:            make(map[string]*cachedValue[bool]),
+		topologyDevices:   make(map[string]*cachedValue[string]),
+		cudaCompatDevices: make(map[string]*cachedValue[string]),
+	}
+}
+
+// qualifiedName returns the fully-qualified CDI name of the specified device.
+func (c *allocateCache) qualifiedName(handler cdi.Interface, class string, id string) string {
+	resolve := func() (string, error) {
+		return handler.QualifiedName(class, id), nil
+	}
+	if c == nil {
+		name, _ := resolve()
+		return name
+	}
+	name, _ := lookup(c, c.qualifiedNames, class+"="+id, resolve)
+	return name
+}
+
+// pathExists returns whether the specified path exists.
+func (c *allocateCache) pathExists(path string) bool {
+	resolve := func() (bool, error) {
+		_, err := os.Stat(path)
+		return err == nil, nil
+	}
+	if c == nil {
+		exists, _ := resolve()
+		return exists
+	}
+	exists, _ := lookup(c, c.exists, path, resolve)
+	return exists
+}
+
+// topologyDevice returns the CDI device that mounts the topology of the
+// devices identified by the specified key. The spec file is only created by
+// the first container that requests these devices.
+func (c *allocateCache) topologyDevice(key string, create func() (string, error)) (string, error) {
+	if c == nil {
+		return create()
+	}
+	return lookup(c, c.topologyDevices, key, create)
+}
+
+// cudaCompatDevice returns the CDI device that mounts the CUDA forward
+// compatibility libraries from the specified directory. The spec file is only
+// created once per Allocate call.
+func (c *allocateCache) cudaCompatDevice(handler cdi.Interface, hostDir string) (string, error) {
+	resolve := func() (string, error) {
+		return handler.CreateCUDACompatSpecFile(hostDir)
+	}
+	if c == nil {
+		return resolve()
+	}
+	return lookup(c, c.cudaCompatDevices, hostDir, resolve)
+}
+
+// lookup returns the cached value for the specified key, resolving it if it
+// has not been resolved yet. The cache is not locked while resolving, so that
+// different keys are resolved concurrently.
+func lookup[T any](c *allocateCache, values map[string]*cachedValue[T], key string, resolve func() (T, error)) (T, error) {
+	c.Lock()
+	v, ok := values[key]
+	if !ok {
+		v = &cachedValue[T]{}
+		values[key] = v
+	}
+	c.Unlock()
+
+	v.once.Do(func() {
+		v.value, v.err = resolve()
+	})
+	return v.value, v.err
+}
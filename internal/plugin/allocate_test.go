@@ -0,0 +1,210 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testAllocateResourceManager struct {
+	testResourceManager
+	err error
+}
+
+func (r testAllocateResourceManager) ValidateRequest(rm.AnnotatedIDs) error {
+	return r.err
+}
+
+func (r testAllocateResourceManager) GetTopology(ids []string) (*rm.Topology, error) {
+	return &rm.Topology{}, nil
+}
+
+func TestAllocateContainerRequests(t *testing.T) {
+	testCases := []struct {
+		description       string
+		validateErr       error
+		sharing           spec.Sharing
+		requests          [][]string
+		expectedError     bool
+		expectedResponses []*pluginapi.ContainerAllocateResponse
+	}{
+		{
+			description: "responses match the order of the container requests",
+			requests:    [][]string{{"gpu0"}, {"gpu1", "gpu2"}, {"gpu3"}},
+			expectedResponses: []*pluginapi.ContainerAllocateResponse{
+				{
+					Envs:       map[string]string{"NVIDIA_VISIBLE_DEVICES": "gpu0", "NVIDIA_GDS": "enabled"},
+					CDIDevices: []*pluginapi.CDIDevice{{Name: "nvidia.com/gpu=gpu0"}, {Name: "nvidia.com/gds=all"}},
+				},
+				{
+					Envs:       map[string]string{"NVIDIA_VISIBLE_DEVICES": "gpu1,gpu2", "NVIDIA_GDS": "enabled"},
+					CDIDevices: []*pluginapi.CDIDevice{{Name: "nvidia.com/gpu=gpu1"}, {Name: "nvidia.com/gpu=gpu2"}, {Name: "nvidia.com/gds=all"}},
+				},
+				{
+					Envs:       map[string]string{"NVIDIA_VISIBLE_DEVICES": "gpu3", "NVIDIA_GDS": "enabled"},
+					CDIDevices: []*pluginapi.CDIDevice{{Name: "nvidia.com/gpu=gpu3"}, {Name: "nvidia.com/gds=all"}},
+				},
+			},
+		},
+		{
+			description: "envs of the replicated resource are set",
+			sharing: spec.Sharing{
+				TimeSlicing: spec.ReplicatedResources{
+					Resources: []spec.ReplicatedResource{
+						{Name: "nvidia.com/gpu", Replicas: 2, Envs: map[string]string{"CUDA_MODULE_LOADING": "LAZY"}},
+					},
+				},
+			},
+			requests: [][]string{{"gpu0"}},
+			expectedResponses: []*pluginapi.ContainerAllocateResponse{
+				{
+					Envs:       map[string]string{"NVIDIA_VISIBLE_DEVICES": "gpu0", "NVIDIA_GDS": "enabled", "CUDA_MODULE_LOADING": "LAZY"},
+					CDIDevices: []*pluginapi.CDIDevice{{Name: "nvidia.com/gpu=gpu0"}, {Name: "nvidia.com/gds=all"}},
+				},
+			},
+		},
+		{
+			description:   "invalid request fails the allocation",
+			validateErr:   errors.New("invalid"),
+			requests:      [][]string{{"gpu0"}, {"gpu1"}},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			deviceListStrategies, _ := spec.NewDeviceListStrategies([]string{"envvar", "cdi-cri"})
+			cdiHandler := &cdi.InterfaceMock{
+				QualifiedNameFunc: func(c string, s string) string {
+					return "nvidia.com/" + c + "=" + s
+				},
+			}
+			plugin := NvidiaDevicePlugin{
+				rm: testAllocateResourceManager{err: tc.validateErr},
+				config: &spec.Config{
+					Sharing: tc.sharing,
+					Flags: spec.Flags{
+						CommandLineFlags: spec.CommandLineFlags{
+							GDSEnabled:   ptr(true),
+							MOFEDEnabled: ptr(false),
+							Plugin: &spec.PluginCommandLineFlags{
+								PassDeviceSpecs:  ptr(false),
+								DeviceIDStrategy: ptr(spec.DeviceIDStrategyUUID),
+							},
+						},
+					},
+				},
+				cdiHandler:           cdiHandler,
+				cdiEnabled:           true,
+				deviceListStrategies: deviceListStrategies,
+				deviceListEnvvar:     "NVIDIA_VISIBLE_DEVICES",
+			}
+
+			request := &pluginapi.AllocateRequest{}
+			for _, ids := range tc.requests {
+				request.ContainerRequests = append(request.ContainerRequests, &pluginapi.ContainerAllocateRequest{DevicesIDs: ids})
+			}
+
+			response, err := plugin.Allocate(context.Background(), request)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedResponses, response.ContainerResponses)
+		})
+	}
+}
+
+func TestAllocateResolvesSharedDevicesOnce(t *testing.T) {
+	versionFile := filepath.Join(t.TempDir(), "version")
+	require.NoError(t, os.WriteFile(versionFile, []byte("535.104.05\n"), 0600))
+
+	deviceListStrategies, _ := spec.NewDeviceListStrategies([]string{"cdi-cri"})
+	cdiHandler := &cdi.InterfaceMock{
+		QualifiedNameFunc: func(c string, s string) string {
+			return "nvidia.com/" + c + "=" + s
+		},
+		CreateTopologySpecFileFunc: func(contents []byte) (string, error) {
+			return "nvidia.com/topology=topology", nil
+		},
+		CreateCUDACompatSpecFileFunc: func(hostDir string) (string, error) {
+			return "nvidia.com/compat=compat", nil
+		},
+	}
+	plugin := NvidiaDevicePlugin{
+		rm: testAllocateResourceManager{},
+		config: &spec.Config{
+			Flags: spec.Flags{
+				CommandLineFlags: spec.CommandLineFlags{
+					GDSEnabled:   ptr(true),
+					MOFEDEnabled: ptr(false),
+					Plugin: &spec.PluginCommandLineFlags{
+						PassDeviceSpecs:  ptr(false),
+						DeviceIDStrategy: ptr(spec.DeviceIDStrategyUUID),
+					},
+				},
+			},
+		},
+		cdiHandler:           cdiHandler,
+		cdiEnabled:           true,
+		deviceListStrategies: deviceListStrategies,
+		topologyFile:         true,
+		cudaCompat: &cudaCompatDetector{
+			driverVersionFile: versionFile,
+			detected:          true,
+			driverVersion:     "535.104.05",
+			compat:            &cudaCompat{hostDir: "/compat"},
+		},
+	}
+
+	// Each container is allocated a replica of the same GPU.
+	request := &pluginapi.AllocateRequest{}
+	for _, id := range []string{"gpu0::0", "gpu0::1", "gpu0::2"} {
+		request.ContainerRequests = append(request.ContainerRequests, &pluginapi.ContainerAllocateRequest{DevicesIDs: []string{id}})
+	}
+
+	response, err := plugin.Allocate(context.Background(), request)
+	require.NoError(t, err)
+	require.Len(t, response.ContainerResponses, 3)
+	for _, r := range response.ContainerResponses {
+		require.EqualValues(t, []*pluginapi.CDIDevice{
+			{Name: "nvidia.com/gpu=gpu0"},
+			{Name: "nvidia.com/gds=all"},
+			{Name: "nvidia.com/topology=topology"},
+			{Name: "nvidia.com/compat=compat"},
+		}, r.CDIDevices)
+	}
+	require.Len(t, cdiHandler.QualifiedNameCalls(), 2)
+	require.Len(t, cdiHandler.CreateTopologySpecFileCalls(), 1)
+	require.Len(t, cdiHandler.CreateCUDACompatSpecFileCalls(), 1)
+}
+
+func ptr[T any](x T) *T {
+	return &x
+}
@@ -0,0 +1,239 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+const (
+	// allocationSyncInterval is the interval at which the containers that
+	// are allocated devices of a resource are listed.
+	allocationSyncInterval = 10 * time.Second
+	// pendingAllocationTimeout is the time after which an admitted allocation
+	// that is not listed by the PodResources API is forgotten, e.g. because
+	// the pod was deleted before its containers were created.
+	pendingAllocationTimeout = time.Minute
+)
+
+// allocations holds the device IDs of the containers that are allocated
+// devices of a resource.
+type allocations [][]string
+
+// deviceIDs returns the set of allocated device IDs.
+func (a allocations) deviceIDs() map[string]bool {
+	ids := make(map[string]bool)
+	for _, container := range a {
+		for _, id := range container {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// clients returns the number of containers that are allocated devices of
+// each physical GPU.
+func (a allocations) clients() map[string]int {
+	clients := make(map[string]int)
+	for _, container := range a {
+		for _, gpu := range physicalGPUs(container) {
+			clients[gpu]++
+		}
+	}
+	return clients
+}
+
+// allocationObserver is notified of the allocations of a resource.
+type allocationObserver interface {
+	// observe updates the observer with the current allocations. It
+	// returns whether the devices advertised by the plugin changed.
+	observe(allocations) bool
+}
+
+// pendingAllocation is an allocation that was admitted by the plugin but is
+// not listed by the PodResources API yet.
+type pendingAllocation struct {
+	ids []string
+	at  time.Time
+}
+
+// allocationWatcher lists the containers that are allocated devices of a
+// resource through the kubelet's PodResources API and passes them to the
+// observers of a plugin, so that they share a single poller.
+//
+// Since containers are only listed by the PodResources API once their
+// allocation completes, the allocations admitted by the plugin are included
+// until they are listed or time out.
+// A nil allocationWatcher does nothing.
+type allocationWatcher struct {
+	sync.Mutex
+	resource  spec.ResourceName
+	lister    ContainerDevicesLister
+	now       func() time.Time
+	observers []allocationObserver
+
+	// listed holds the allocations listed at the last sync. It is nil until
+	// the first sync.
+	listed  allocations
+	pending []pendingAllocation
+	updates chan struct{}
+}
+
+func newAllocationWatcher(resource spec.ResourceName, lister ContainerDevicesLister) *allocationWatcher {
+	return &allocationWatcher{
+		resource: resource,
+		lister:   lister,
+		now:      time.Now,
+		updates:  make(chan struct{}, 1),
+	}
+}
+
+// watch adds an observer of the allocations.
+func (w *allocationWatcher) watch(o allocationObserver) {
+	w.observers = append(w.observers, o)
+}
+
+// run keeps the observers in sync with the PodResources API until stop is
+// closed. It returns immediately if there are no observers.
+func (w *allocationWatcher) run(stop <-chan interface{}) {
+	if w == nil || len(w.observers) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(allocationSyncInterval)
+	defer ticker.Stop()
+	for {
+		w.sync(ctx)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync lists the allocated containers, forgets the pending allocations that
+// are listed or timed out, and updates the observers.
+func (w *allocationWatcher) sync(ctx context.Context) {
+	containers, err := w.lister.AllocatedContainerDevices(ctx, string(w.resource))
+	if err != nil {
+		klog.Warningf("Failed to get allocated devices for %v: %v", w.resource, err)
+		return
+	}
+
+	listed := make(allocations, 0, len(containers))
+	for _, container := range containers {
+		listed = append(listed, container.DeviceIDs)
+	}
+	ids := listed.deviceIDs()
+
+	w.Lock()
+	defer w.Unlock()
+	now := w.now()
+	w.listed = listed
+	w.pending = slices.DeleteFunc(w.pending, func(p pendingAllocation) bool {
+		return now.Sub(p.at) >= pendingAllocationTimeout || !slices.ContainsFunc(p.ids, func(id string) bool { return !ids[id] })
+	})
+	w.update()
+}
+
+// admit runs the specified check against the current allocations and, if it
+// succeeds, records the devices as allocated until they are listed.
+func (w *allocationWatcher) admit(ids []string, check func(allocations, []string) error) error {
+	if w == nil {
+		return nil
+	}
+	w.Lock()
+	defer w.Unlock()
+	if err := check(w.current(), ids); err != nil {
+		return err
+	}
+	w.pending = append(w.pending, pendingAllocation{ids: ids, at: w.now()})
+	w.update()
+	return nil
+}
+
+// cancel forgets a pending allocation of the specified devices, e.g. because
+// the allocation was rejected after it was admitted.
+func (w *allocationWatcher) cancel(ids []string) {
+	if w == nil {
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+	for i, p := range w.pending {
+		if slices.Equal(p.ids, ids) {
+			w.pending = slices.Delete(w.pending, i, i+1)
+			w.update()
+			return
+		}
+	}
+}
+
+// Updates returns a channel that is notified when the devices advertised by
+// the plugin changed because of the allocations. If the watcher is nil, the
+// channel is never notified.
+func (w *allocationWatcher) Updates() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.updates
+}
+
+// current returns the listed and the pending allocations.
+func (w *allocationWatcher) current() allocations {
+	current := slices.Clone(w.listed)
+	for _, p := range w.pending {
+		current = append(current, p.ids)
+	}
+	return current
+}
+
+// update passes the current allocations to the observers. It must be called
+// with the lock held and only once the allocations were listed.
+func (w *allocationWatcher) update() {
+	if w.listed == nil {
+		return
+	}
+	current := w.current()
+	var changed bool
+	for _, o := range w.observers {
+		if o.observe(current) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	select {
+	case w.updates <- struct{}{}:
+	default:
+	}
+}
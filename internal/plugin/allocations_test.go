@@ -0,0 +1,104 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type recordingObserver []allocations
+
+func (o *recordingObserver) observe(a allocations) bool {
+	*o = append(*o, a)
+	return false
+}
+
+func TestAllocationWatcher(t *testing.T) {
+	var nilWatcher *allocationWatcher
+	require.NoError(t, nilWatcher.admit([]string{"GPU-0::0"}, nil))
+	require.Nil(t, nilWatcher.Updates())
+	nilWatcher.cancel([]string{"GPU-0::0"})
+	nilWatcher.run(nil)
+
+	now := time.Unix(0, 0)
+	lister := fakeContainerDevicesLister{
+		{Namespace: "default", Pod: "a", Container: "main", DeviceIDs: []string{"GPU-0::0"}},
+	}
+	watcher := newAllocationWatcher("nvidia.com/gpu", nil)
+	watcher.lister = lister
+	watcher.now = func() time.Time { return now }
+	observer := &recordingObserver{}
+	watcher.watch(observer)
+	limiter := newClientLimiter("nvidia.com/gpu", 2)
+	watcher.watch(limiter)
+
+	// Admitted allocations are checked against the pending allocations but
+	// are only passed to the observers once the allocations were listed.
+	require.NoError(t, watcher.admit([]string{"GPU-0::1"}, limiter.check))
+	require.NoError(t, watcher.admit([]string{"GPU-0::2"}, limiter.check))
+	require.Error(t, watcher.admit([]string{"GPU-0::3"}, limiter.check))
+	require.Empty(t, *observer)
+
+	watcher.sync(context.Background())
+	require.Equal(t, allocations{{"GPU-0::0"}, {"GPU-0::1"}, {"GPU-0::2"}}, (*observer)[0])
+
+	// Cancelled allocations are forgotten.
+	watcher.cancel([]string{"GPU-0::2"})
+	require.Equal(t, allocations{{"GPU-0::0"}, {"GPU-0::1"}}, (*observer)[1])
+
+	// Allocations are pending until they are listed.
+	watcher.lister = append(lister, podresources.ContainerDevices{Namespace: "default", Pod: "b", Container: "main", DeviceIDs: []string{"GPU-0::1"}})
+	watcher.sync(context.Background())
+	require.Empty(t, watcher.pending)
+	require.Equal(t, allocations{{"GPU-0::0"}, {"GPU-0::1"}}, (*observer)[2])
+
+	// Allocations that are never listed time out.
+	require.NoError(t, watcher.admit([]string{"GPU-1::0"}, limiter.check))
+	watcher.sync(context.Background())
+	require.Len(t, watcher.pending, 1)
+	now = now.Add(pendingAllocationTimeout)
+	watcher.sync(context.Background())
+	require.Empty(t, watcher.pending)
+	require.Equal(t, allocations{{"GPU-0::0"}, {"GPU-0::1"}}, (*observer)[len(*observer)-1])
+}
+
+func TestAllocationWatcherUpdates(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+	}
+	watcher := newAllocationWatcher("nvidia.com/gpu", fakeContainerDevicesLister{
+		{Namespace: "default", Pod: "a", Container: "main", DeviceIDs: []string{"GPU-0::1"}},
+	})
+	watcher.watch(newClientLimiter("nvidia.com/gpu", 2))
+	watcher.watch(newRetainedDevices("nvidia.com/gpu", devices))
+
+	// The watcher is notified if any observer changes the advertised devices.
+	watcher.sync(context.Background())
+	require.Len(t, watcher.Updates(), 1)
+	<-watcher.Updates()
+
+	watcher.sync(context.Background())
+	require.Empty(t, watcher.Updates())
+}
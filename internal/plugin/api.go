@@ -16,11 +16,85 @@
 
 package plugin
 
-import "github.com/NVIDIA/k8s-device-plugin/internal/rm"
+import (
+	"context"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
 
 // Interface defines the API for the plugin package
 type Interface interface {
+	Resource() spec.ResourceName
 	Devices() rm.Devices
 	Start() error
 	Stop() error
+	Terminate()
+	RefreshListAndWatch()
+	ListAndWatchSnapshot() *ListAndWatchSnapshot
+	Inventory() *ListAndWatchSnapshot
+	SubscribeInventory() (<-chan struct{}, func())
+	RecentEvents() []Event
+	MPSDaemonStatus() *MPSDaemonStatus
+	GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error)
+	Allocate(context.Context, *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error)
+}
+
+// MPSDaemonStatus is the status of the MPS daemon that a plugin connects to.
+type MPSDaemonStatus struct {
+	PipeDir string `json:"pipeDir"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Drainer defines the API used by a plugin to query the devices that are being drained.
+type Drainer interface {
+	Draining(resource spec.ResourceName) map[string]bool
+	Subscribe(resource spec.ResourceName) (<-chan struct{}, func())
+}
+
+// PodResourcesLister defines the API used by a plugin to query the devices that are allocated to pods.
+type PodResourcesLister interface {
+	AllocatedDevices(ctx context.Context, resource string) (map[string]bool, error)
+}
+
+// PodDevicesLister defines the API used by a plugin to query the devices that
+// are allocated to each pod.
+type PodDevicesLister interface {
+	AllocatedPodDevices(ctx context.Context, resource string) ([]podresources.PodDevices, error)
+}
+
+// ContainerDevicesLister defines the API used by a plugin to query the devices
+// that are allocated to each container.
+type ContainerDevicesLister interface {
+	AllocatedContainerDevices(ctx context.Context, resource string) ([]podresources.ContainerDevices, error)
+}
+
+// PendingPodLister defines the API used by a plugin to query the pods of the
+// node that are not running yet.
+type PendingPodLister interface {
+	PendingPods(ctx context.Context, resource string) ([]podresources.PendingPod, error)
+}
+
+// PodAnnotationGetter defines the API used by a plugin to query the annotations of a pod.
+type PodAnnotationGetter interface {
+	PodAnnotations(ctx context.Context, namespace, name string) (map[string]string, error)
+}
+
+// HealthRecorder defines the API used by a plugin to record the health of the
+// devices that are advertised to the kubelet.
+type HealthRecorder interface {
+	RecordHealth(resource string, devices map[string]bool)
+}
+
+// MetricsRecorder defines the API used by a plugin to record its interactions
+// with the kubelet and the replicas that it advertises.
+type MetricsRecorder interface {
+	RecordAllocate(resource string, duration time.Duration, err error)
+	RecordRegistration(resource string, reregistration bool)
+	RecordReplicas(resource string, strategy string, replicas int)
 }
@@ -0,0 +1,263 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+const (
+	// boostSyncInterval is the interval at which the boosted devices are
+	// compared with the devices allocated to pods.
+	boostSyncInterval = 10 * time.Second
+	// boostGracePeriod is the time after boosting a device during which it
+	// is not restored, even if it is not yet listed as allocated to a pod.
+	boostGracePeriod = 30 * time.Second
+)
+
+// clockBooster raises the application clocks and power limit of allocated
+// devices to their maximum and restores the original settings once the
+// devices are no longer allocated to any pod, as reported by the kubelet's
+// PodResources API.
+//
+// The lock only protects the bookkeeping of the boosted devices; NVML calls
+// are made without holding it so that allocations are not blocked by slow
+// calls for other devices. Devices whose clocks are being changed are tracked
+// as pending and are skipped by concurrent calls.
+type clockBooster struct {
+	sync.Mutex
+	resource spec.ResourceName
+	devices  rm.Devices
+	nvcaps   nvcaps.Interface
+	lister   PodResourcesLister
+	now      func() time.Time
+
+	initialized bool
+	boosted     map[string]*boostedDevice
+	pending     map[string]bool
+	// inflight tracks the NVML calls of boost so that NVML is not shut down
+	// while they are running.
+	inflight sync.WaitGroup
+}
+
+// boostedDevice holds the original clocks of a boosted device.
+type boostedDevice struct {
+	uuid     string
+	original nvcaps.Clocks
+	// lastSeen is the last time the device was allocated or listed as allocated.
+	lastSeen time.Time
+}
+
+func newClockBooster(resource spec.ResourceName, devices rm.Devices, nvcapslib nvcaps.Interface, lister PodResourcesLister) *clockBooster {
+	return &clockBooster{
+		resource: resource,
+		devices:  devices,
+		nvcaps:   nvcapslib,
+		lister:   lister,
+		now:      time.Now,
+		boosted:  make(map[string]*boostedDevice),
+		pending:  make(map[string]bool),
+	}
+}
+
+// run keeps the boosted devices in sync with the allocated devices until
+// stop is closed. The original clocks of all boosted devices are then
+// restored; devices that are still allocated are boosted again once the
+// plugin is restarted.
+func (b *clockBooster) run(stop <-chan interface{}) {
+	if b == nil {
+		return
+	}
+	if err := b.nvcaps.Init(); err != nil {
+		klog.Warningf("Failed to initialize NVML: %v; continuing with clock boosting disabled for %v", err, b.resource)
+		return
+	}
+	defer func() {
+		if err := b.nvcaps.Shutdown(); err != nil {
+			klog.Infof("Error shutting down NVML: %v", err)
+		}
+	}()
+	b.setInitialized(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(boostSyncInterval)
+	defer ticker.Stop()
+	for {
+		b.sync(ctx)
+		select {
+		case <-stop:
+			b.setInitialized(false)
+			b.inflight.Wait()
+			b.restoreAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// boost boosts the specified devices if clock boosting is running.
+// Failures are logged and do not fail the allocation.
+func (b *clockBooster) boost(ids []string) {
+	if b == nil {
+		return
+	}
+	for _, id := range b.startBoost(ids) {
+		b.boostDevice(id)
+		b.inflight.Done()
+	}
+}
+
+// sync boosts the allocated devices that are not boosted and restores the
+// boosted devices that are no longer allocated.
+func (b *clockBooster) sync(ctx context.Context) {
+	allocated, err := b.lister.AllocatedDevices(ctx, string(b.resource))
+	if err != nil {
+		klog.Warningf("Failed to get allocated devices for %v: %v", b.resource, err)
+		return
+	}
+
+	var ids []string
+	for id := range allocated {
+		ids = append(ids, id)
+	}
+	b.boost(ids)
+
+	for _, id := range b.startRestore(func(id string, d *boostedDevice) bool {
+		return !allocated[id] && b.now().Sub(d.lastSeen) >= boostGracePeriod
+	}) {
+		b.restoreDevice(id)
+	}
+}
+
+func (b *clockBooster) restoreAll() {
+	for _, id := range b.startRestore(func(string, *boostedDevice) bool { return true }) {
+		b.restoreDevice(id)
+	}
+}
+
+// startBoost returns the specified devices that are neither boosted nor
+// pending and marks them as pending. The boosted devices are marked as seen.
+func (b *clockBooster) startBoost(ids []string) []string {
+	b.Lock()
+	defer b.Unlock()
+	if !b.initialized {
+		return nil
+	}
+	var start []string
+	for _, id := range ids {
+		if d, exists := b.boosted[id]; exists {
+			d.lastSeen = b.now()
+			continue
+		}
+		if _, exists := b.devices[id]; !exists || b.pending[id] {
+			continue
+		}
+		b.pending[id] = true
+		b.inflight.Add(1)
+		start = append(start, id)
+	}
+	return start
+}
+
+// startRestore returns the boosted devices that match the specified filter
+// and marks them as pending.
+func (b *clockBooster) startRestore(filter func(string, *boostedDevice) bool) []string {
+	b.Lock()
+	defer b.Unlock()
+	var start []string
+	for id, d := range b.boosted {
+		if b.pending[id] || !filter(id, d) {
+			continue
+		}
+		b.pending[id] = true
+		start = append(start, id)
+	}
+	return start
+}
+
+// boostDevice boosts the specified pending device.
+func (b *clockBooster) boostDevice(id string) {
+	uuid := b.devices[id].GetUUID()
+	var boosted *boostedDevice
+	defer func() {
+		b.Lock()
+		defer b.Unlock()
+		delete(b.pending, id)
+		if boosted != nil {
+			b.boosted[id] = boosted
+		}
+	}()
+
+	original, err := b.nvcaps.GetClocks(uuid)
+	if err != nil {
+		klog.Warningf("Not boosting clocks of device %v: failed to get clocks: %v", id, err)
+		return
+	}
+	maximum, err := b.nvcaps.GetMaxClocks(uuid)
+	if err != nil {
+		klog.Warningf("Not boosting clocks of device %v: failed to get maximum clocks: %v", id, err)
+		return
+	}
+	if err := b.nvcaps.SetClocks(uuid, maximum); err != nil {
+		klog.Warningf("Failed to boost clocks of device %v: %v", id, err)
+		// Restore the original clocks in case only some settings were applied.
+		_ = b.nvcaps.SetClocks(uuid, original)
+		return
+	}
+	klog.Infof("Boosted clocks of device %v from %+v to %+v", id, original, maximum)
+	boosted = &boostedDevice{uuid: uuid, original: original, lastSeen: b.now()}
+}
+
+// restoreDevice restores the original clocks of the specified pending device.
+// The device remains boosted if its clocks cannot be restored.
+func (b *clockBooster) restoreDevice(id string) {
+	b.Lock()
+	d := b.boosted[id]
+	b.Unlock()
+
+	err := b.nvcaps.SetClocks(d.uuid, d.original)
+
+	b.Lock()
+	defer b.Unlock()
+	delete(b.pending, id)
+	if err != nil {
+		klog.Warningf("Failed to restore clocks of device %v: %v", id, err)
+		return
+	}
+	klog.Infof("Restored clocks of device %v to %+v", id, d.original)
+	delete(b.boosted, id)
+}
+
+func (b *clockBooster) setInitialized(initialized bool) {
+	b.Lock()
+	defer b.Unlock()
+	b.initialized = initialized
+}
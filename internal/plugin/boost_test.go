@@ -0,0 +1,201 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testPodResourcesLister struct {
+	allocated map[string]bool
+	err       error
+}
+
+func (l *testPodResourcesLister) AllocatedDevices(_ context.Context, _ string) (map[string]bool, error) {
+	return l.allocated, l.err
+}
+
+func TestClockBoosterSync(t *testing.T) {
+	original := nvcaps.Clocks{GraphicsMHz: 1000, MemoryMHz: 5000, PowerLimitMilliwatts: 200000}
+	maximum := nvcaps.Clocks{GraphicsMHz: 1500, MemoryMHz: 6000, PowerLimitMilliwatts: 300000}
+
+	testCases := []struct {
+		description     string
+		setClocksErr    error
+		allocated       []string
+		released        map[string]bool
+		listErr         error
+		elapsed         time.Duration
+		expectedBoosted []string
+		expectedClocks  map[string]nvcaps.Clocks
+	}{
+		{
+			description:     "allocated devices are boosted",
+			allocated:       []string{"GPU-0"},
+			released:        map[string]bool{"GPU-0": true},
+			elapsed:         time.Minute,
+			expectedBoosted: []string{"GPU-0"},
+			expectedClocks:  map[string]nvcaps.Clocks{"GPU-0": maximum, "GPU-1": original},
+		},
+		{
+			description:     "released devices are restored",
+			allocated:       []string{"GPU-0", "GPU-1"},
+			released:        map[string]bool{"GPU-1": true},
+			elapsed:         time.Minute,
+			expectedBoosted: []string{"GPU-1"},
+			expectedClocks:  map[string]nvcaps.Clocks{"GPU-0": original, "GPU-1": maximum},
+		},
+		{
+			description:     "devices are not restored within the grace period",
+			allocated:       []string{"GPU-0"},
+			released:        map[string]bool{},
+			elapsed:         time.Second,
+			expectedBoosted: []string{"GPU-0"},
+			expectedClocks:  map[string]nvcaps.Clocks{"GPU-0": maximum, "GPU-1": original},
+		},
+		{
+			description:     "devices listed as allocated are boosted",
+			released:        map[string]bool{"GPU-1": true},
+			elapsed:         time.Minute,
+			expectedBoosted: []string{"GPU-1"},
+			expectedClocks:  map[string]nvcaps.Clocks{"GPU-0": original, "GPU-1": maximum},
+		},
+		{
+			description:     "devices are not restored if listing fails",
+			allocated:       []string{"GPU-0"},
+			listErr:         errors.New("kubelet unavailable"),
+			elapsed:         time.Minute,
+			expectedBoosted: []string{"GPU-0"},
+			expectedClocks:  map[string]nvcaps.Clocks{"GPU-0": maximum, "GPU-1": original},
+		},
+		{
+			description:    "failure to boost is ignored",
+			setClocksErr:   errors.New("insufficient permissions"),
+			allocated:      []string{"GPU-0"},
+			released:       map[string]bool{},
+			elapsed:        time.Minute,
+			expectedClocks: map[string]nvcaps.Clocks{"GPU-0": original, "GPU-1": original},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			clocks := map[string]nvcaps.Clocks{"GPU-0": original, "GPU-1": original}
+			nvcapslib := &nvcaps.InterfaceMock{
+				GetClocksFunc: func(uuid string) (nvcaps.Clocks, error) {
+					return clocks[uuid], nil
+				},
+				GetMaxClocksFunc: func(uuid string) (nvcaps.Clocks, error) {
+					return maximum, nil
+				},
+				SetClocksFunc: func(uuid string, c nvcaps.Clocks) error {
+					if tc.setClocksErr != nil && c != original {
+						return tc.setClocksErr
+					}
+					clocks[uuid] = c
+					return nil
+				},
+			}
+			lister := &testPodResourcesLister{}
+			devices := rm.Devices{
+				"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0"}},
+				"GPU-1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1"}},
+			}
+
+			now := time.Now()
+			b := newClockBooster("nvidia.com/gpu", devices, nvcapslib, lister)
+			b.now = func() time.Time { return now }
+			b.initialized = true
+
+			b.boost(tc.allocated)
+
+			now = now.Add(tc.elapsed)
+			lister.allocated = tc.released
+			lister.err = tc.listErr
+			b.sync(context.Background())
+
+			var boosted []string
+			for id := range b.boosted {
+				boosted = append(boosted, id)
+			}
+			require.ElementsMatch(t, tc.expectedBoosted, boosted)
+			require.EqualValues(t, tc.expectedClocks, clocks)
+
+			b.restoreAll()
+			require.Empty(t, b.boosted)
+			require.EqualValues(t, map[string]nvcaps.Clocks{"GPU-0": original, "GPU-1": original}, clocks)
+		})
+	}
+}
+
+func TestClockBoosterNotRunning(t *testing.T) {
+	nvcapslib := &nvcaps.InterfaceMock{}
+	devices := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0"}},
+	}
+	b := newClockBooster("nvidia.com/gpu", devices, nvcapslib, &testPodResourcesLister{})
+	b.boost([]string{"GPU-0"})
+	require.Empty(t, b.boosted)
+	require.Empty(t, nvcapslib.GetClocksCalls())
+
+	var disabled *clockBooster
+	disabled.boost([]string{"GPU-0"})
+}
+
+func TestClockBoosterCallsNVMLWithoutLock(t *testing.T) {
+	var b *clockBooster
+	unlocked := func() error {
+		if !b.TryLock() {
+			return errors.New("lock is held")
+		}
+		b.Unlock()
+		return nil
+	}
+	nvcapslib := &nvcaps.InterfaceMock{
+		GetClocksFunc: func(uuid string) (nvcaps.Clocks, error) {
+			return nvcaps.Clocks{}, unlocked()
+		},
+		GetMaxClocksFunc: func(uuid string) (nvcaps.Clocks, error) {
+			return nvcaps.Clocks{GraphicsMHz: 1500}, unlocked()
+		},
+		SetClocksFunc: func(uuid string, c nvcaps.Clocks) error {
+			return unlocked()
+		},
+	}
+	devices := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0"}},
+	}
+	b = newClockBooster("nvidia.com/gpu", devices, nvcapslib, &testPodResourcesLister{})
+	b.initialized = true
+
+	b.boost([]string{"GPU-0"})
+	require.Contains(t, b.boosted, "GPU-0")
+	require.Empty(t, b.pending)
+
+	b.restoreAll()
+	require.Empty(t, b.boosted)
+	require.Empty(t, b.pending)
+}
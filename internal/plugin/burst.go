@@ -0,0 +1,111 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"maps"
+	"sync"
+
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// burstReplicas withholds the burst replicas of a resource until all of its
+// available base replicas are allocated, so that a GPU is shared
+// conservatively by default and only oversubscribed under pressure. The
+// allocated replicas are passed by the allocationWatcher of the plugin. Base
+// replicas are not available if they are unhealthy or withheld from the
+// kubelet for other reasons, e.g. because they are drained.
+//
+// Burst replicas that are already allocated are never withheld, so that the
+// kubelet keeps the allocations of running pods once base replicas are
+// released again.
+type burstReplicas struct {
+	sync.Mutex
+	resource spec.ResourceName
+	devices  rm.Devices
+
+	// allocated is the set of allocated device IDs. It is nil until the
+	// allocations are known.
+	allocated map[string]bool
+	// pressure records whether the burst replicas were last advertised.
+	pressure bool
+}
+
+func newBurstReplicas(resource spec.ResourceName, devices rm.Devices) *burstReplicas {
+	return &burstReplicas{
+		resource: resource,
+		devices:  devices,
+	}
+}
+
+// Withheld returns the IDs of the burst replicas that are withheld from the
+// kubelet given the base replicas that are unavailable for other reasons.
+// All burst replicas are withheld until the allocated replicas are known.
+func (b *burstReplicas) Withheld(unavailable map[string]bool) map[string]bool {
+	if b == nil {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	pressure := b.allocated != nil
+	for id, d := range b.devices {
+		if !pressure {
+			break
+		}
+		if !d.Burst && d.Health == pluginapi.Healthy && !unavailable[id] && !b.allocated[id] {
+			pressure = false
+		}
+	}
+	if pressure != b.pressure {
+		if pressure {
+			klog.Infof("All available base replicas of %v are allocated; advertising burst replicas", b.resource)
+		} else {
+			klog.Infof("Withholding burst replicas of %v until all available base replicas are allocated", b.resource)
+		}
+		b.pressure = pressure
+	}
+
+	withheld := make(map[string]bool)
+	if pressure {
+		return withheld
+	}
+	for id, d := range b.devices {
+		if d.Burst && !b.allocated[id] {
+			withheld[id] = true
+		}
+	}
+	return withheld
+}
+
+// observe records the allocated replicas. It returns whether they changed,
+// since the withheld burst replicas depend on them.
+func (b *burstReplicas) observe(a allocations) bool {
+	allocated := a.deviceIDs()
+
+	b.Lock()
+	defer b.Unlock()
+	if b.allocated != nil && maps.Equal(allocated, b.allocated) {
+		return false
+	}
+	b.allocated = allocated
+	return true
+}
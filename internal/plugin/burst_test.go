@@ -0,0 +1,71 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestBurstReplicas(t *testing.T) {
+	var nilBurst *burstReplicas
+	require.Nil(t, nilBurst.Withheld(nil))
+
+	replica := func(id string, burst bool) *rm.Device {
+		return &rm.Device{Device: pluginapi.Device{ID: id, Health: pluginapi.Healthy}, Replicas: 3, Burst: burst}
+	}
+	devices := rm.Devices{
+		"GPU-0::0": replica("GPU-0::0", false),
+		"GPU-0::1": replica("GPU-0::1", false),
+		"GPU-0::2": replica("GPU-0::2", true),
+		"GPU-1::0": replica("GPU-1::0", false),
+		"GPU-1::1": replica("GPU-1::1", false),
+		"GPU-1::2": replica("GPU-1::2", true),
+	}
+	burst := newBurstReplicas("nvidia.com/gpu", devices)
+	// The burst replicas are withheld until the allocated replicas are known.
+	require.Equal(t, map[string]bool{"GPU-0::2": true, "GPU-1::2": true}, burst.Withheld(nil))
+	require.Equal(t, map[string]bool{"GPU-0::2": true, "GPU-1::2": true}, burst.Withheld(map[string]bool{"GPU-1::1": true}))
+
+	// A base replica is still available.
+	allocated := allocations{{"GPU-0::0", "GPU-0::1"}, {"GPU-1::0"}}
+	require.True(t, burst.observe(allocated))
+	require.False(t, burst.observe(allocated))
+	require.Equal(t, map[string]bool{"GPU-0::2": true, "GPU-1::2": true}, burst.Withheld(nil))
+
+	// The remaining base replica is withheld for other reasons, e.g.
+	// because it is drained.
+	require.Empty(t, burst.Withheld(map[string]bool{"GPU-1::1": true}))
+
+	// All base replicas are allocated.
+	require.True(t, burst.observe(allocations{{"GPU-0::0", "GPU-0::1"}, {"GPU-1::0"}, {"GPU-1::1"}}))
+	require.Empty(t, burst.Withheld(nil))
+
+	// Pod b is removed after pod d was allocated a burst replica. The
+	// allocated burst replica is not withheld.
+	allocated = allocations{{"GPU-0::0", "GPU-0::1"}, {"GPU-1::1"}, {"GPU-0::2"}}
+	require.True(t, burst.observe(allocated))
+	require.Equal(t, map[string]bool{"GPU-1::2": true}, burst.Withheld(nil))
+
+	// An unhealthy base replica is not considered available.
+	devices["GPU-1::0"].Health = pluginapi.Unhealthy
+	require.Empty(t, burst.Withheld(nil))
+}
@@ -0,0 +1,123 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// clientLimiter limits the number of containers that are allocated replicas
+// of the same physical GPU of a shared resource. The containers are counted
+// from the allocations passed by the allocationWatcher of the plugin, which
+// include the containers admitted since they were last listed.
+type clientLimiter struct {
+	sync.Mutex
+	resource spec.ResourceName
+	max      int
+
+	// clients counts the containers that are allocated replicas of each
+	// physical GPU.
+	clients map[string]int
+}
+
+func newClientLimiter(resource spec.ResourceName, max int) *clientLimiter {
+	return &clientLimiter{
+		resource: resource,
+		max:      max,
+		clients:  make(map[string]int),
+	}
+}
+
+// observe counts the containers that are allocated replicas of each physical
+// GPU. The advertised devices are not affected.
+func (l *clientLimiter) observe(a allocations) bool {
+	clients := a.clients()
+
+	l.Lock()
+	defer l.Unlock()
+	if !maps.Equal(clients, l.clients) {
+		klog.V(4).Infof("Clients of %v devices: %v", l.resource, clients)
+	}
+	l.clients = clients
+	return false
+}
+
+// Available removes the replicas of the physical GPUs that reached the
+// maximum number of clients from the available replicas. The GPUs of the
+// required replicas are kept. If fewer than size replicas remain, the
+// available replicas are returned unchanged and the request is rejected by
+// check instead.
+func (l *clientLimiter) Available(available, required []string, size int) []string {
+	if l == nil {
+		return available
+	}
+	keep := make(map[string]bool)
+	for _, gpu := range physicalGPUs(required) {
+		keep[gpu] = true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	var filtered []string
+	for _, id := range available {
+		gpu := rm.AnnotatedID(id).GetID()
+		if keep[gpu] || l.clients[gpu] < l.max {
+			filtered = append(filtered, id)
+		}
+	}
+	if len(filtered) < size {
+		return available
+	}
+	return filtered
+}
+
+// check checks that none of the physical GPUs of the requested replicas
+// reached the maximum number of clients in the specified allocations.
+func (l *clientLimiter) check(a allocations, ids []string) error {
+	if l == nil {
+		return nil
+	}
+	clients := a.clients()
+	for _, gpu := range physicalGPUs(ids) {
+		if count := clients[gpu]; count >= l.max {
+			return fmt.Errorf("device %v already has %d clients (maxClientsPerDevice: %d)", gpu, count, l.max)
+		}
+	}
+	return nil
+}
+
+// physicalGPUs returns the sorted IDs of the physical GPUs of the specified
+// replicas.
+func physicalGPUs(ids []string) []string {
+	var gpus []string
+	for _, id := range ids {
+		gpu := rm.AnnotatedID(id).GetID()
+		if !slices.Contains(gpus, gpu) {
+			gpus = append(gpus, gpu)
+		}
+	}
+	slices.Sort(gpus)
+	return gpus
+}
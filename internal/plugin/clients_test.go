@@ -0,0 +1,81 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+type fakeContainerDevicesLister []podresources.ContainerDevices
+
+func (l fakeContainerDevicesLister) AllocatedContainerDevices(context.Context, string) ([]podresources.ContainerDevices, error) {
+	return l, nil
+}
+
+func TestClientLimiter(t *testing.T) {
+	var nilLimiter *clientLimiter
+	require.Equal(t, []string{"GPU-0::0"}, nilLimiter.Available([]string{"GPU-0::0"}, nil, 1))
+	require.NoError(t, nilLimiter.check(nil, []string{"GPU-0::0"}))
+
+	allocated := allocations{
+		{"GPU-0::0", "GPU-0::1"},
+		{"GPU-0::2"},
+		{"GPU-1::0"},
+	}
+	available := []string{"GPU-0::3", "GPU-1::1", "GPU-1::2", "GPU-2::0"}
+
+	testCases := []struct {
+		description       string
+		required          []string
+		size              int
+		expectedAvailable []string
+	}{
+		{
+			description:       "replicas of full GPUs are removed",
+			size:              1,
+			expectedAvailable: []string{"GPU-1::1", "GPU-1::2", "GPU-2::0"},
+		},
+		{
+			description:       "GPUs of required replicas are kept",
+			required:          []string{"GPU-0::3"},
+			size:              2,
+			expectedAvailable: available,
+		},
+		{
+			description:       "all replicas are returned if too few remain",
+			size:              4,
+			expectedAvailable: available,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			limiter := newClientLimiter("nvidia.com/gpu", 2)
+			require.False(t, limiter.observe(allocated))
+			require.Equal(t, tc.expectedAvailable, limiter.Available(available, tc.required, tc.size))
+		})
+	}
+
+	limiter := newClientLimiter("nvidia.com/gpu", 2)
+	require.EqualError(t, limiter.check(allocated, []string{"GPU-0::3"}), "device GPU-0 already has 2 clients (maxClientsPerDevice: 2)")
+	require.NoError(t, limiter.check(allocated, []string{"GPU-1::1", "GPU-2::0"}))
+}
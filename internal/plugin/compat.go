@@ -0,0 +1,354 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+const (
+	// driverVersionFile is the file exposing the version of the loaded NVIDIA kernel module.
+	driverVersionFile = "/sys/module/nvidia/version"
+	// cudaCompatContainerPath is the path at which the CUDA forward
+	// compatibility libraries are mounted into containers.
+	cudaCompatContainerPath = cdi.CUDACompatContainerPath
+	// cudaImageLibraryPath is the LD_LIBRARY_PATH set by the CUDA container
+	// images. The forward compatibility libraries are prepended to it.
+	cudaImageLibraryPath = "/usr/local/nvidia/lib:/usr/local/nvidia/lib64"
+)
+
+// cudaCompat holds the CUDA forward compatibility libraries that are mounted
+// into containers to run CUDA versions that are newer than the driver.
+type cudaCompat struct {
+	hostDir string
+	version string
+}
+
+// newCUDACompat detects whether the CUDA forward compatibility libraries in
+// the specified directory are required by the specified driver version. The
+// libraries are only required if they are newer than the driver; otherwise, a
+// nil cudaCompat is returned.
+func newCUDACompat(dir string, driverVersion string) (*cudaCompat, error) {
+	driver, err := parseDriverVersion(driverVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid driver version %q: %w", driverVersion, err)
+	}
+
+	version, compat, err := compatLibraryVersion(dir)
+	if err != nil {
+		return nil, err
+	}
+	if compareVersions(compat, driver) <= 0 {
+		return nil, nil
+	}
+	return &cudaCompat{hostDir: dir, version: version}, nil
+}
+
+// compatLibraryVersion returns the version of the newest CUDA forward
+// compatibility libraries in the specified directory.
+func compatLibraryVersion(dir string) (string, []int, error) {
+	libs, err := filepath.Glob(filepath.Join(dir, "libcuda.so.*.*"))
+	if err != nil {
+		return "", nil, err
+	}
+	var version string
+	var compat []int
+	for _, lib := range libs {
+		v := strings.TrimPrefix(filepath.Base(lib), "libcuda.so.")
+		parsed, err := parseDriverVersion(v)
+		if err != nil {
+			continue
+		}
+		if compat == nil || compareVersions(parsed, compat) > 0 {
+			version, compat = v, parsed
+		}
+	}
+	if compat == nil {
+		return "", nil, fmt.Errorf("no CUDA forward compatibility libraries found in %v", dir)
+	}
+	return version, compat, nil
+}
+
+// cudaCompatDetector detects whether the CUDA forward compatibility libraries
+// in a directory are required for a resource. The detection is repeated when
+// the version of the loaded driver changes, for example when the driver
+// container is upgraded while the plugin is running.
+type cudaCompatDetector struct {
+	sync.Mutex
+	resource          spec.ResourceName
+	dir               string
+	driverVersionFile string
+
+	detected      bool
+	driverVersion string
+	compat        *cudaCompat
+}
+
+// newCUDACompatDetector creates a detector for the libraries in the
+// specified directory.
+func newCUDACompatDetector(resource spec.ResourceName, dir string) *cudaCompatDetector {
+	return &cudaCompatDetector{
+		resource:          resource,
+		dir:               dir,
+		driverVersionFile: driverVersionFile,
+	}
+}
+
+// get returns the CUDA forward compatibility libraries to mount for the
+// loaded driver, or nil if they are not required. Detection errors are logged
+// and disable the mounting of the libraries so that allocations are not
+// affected.
+func (d *cudaCompatDetector) get() *cudaCompat {
+	if d == nil {
+		return nil
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	driverVersion, err := readDriverVersion(d.driverVersionFile)
+	if d.detected && driverVersion == d.driverVersion {
+		return d.compat
+	}
+	d.detected, d.driverVersion, d.compat = true, driverVersion, nil
+	if err != nil {
+		klog.Warningf("Not mounting CUDA forward compatibility libraries for %v: %v", d.resource, err)
+		return nil
+	}
+	compat, err := newCUDACompat(d.dir, driverVersion)
+	if err != nil {
+		klog.Warningf("Not mounting CUDA forward compatibility libraries for %v: %v", d.resource, err)
+		return nil
+	}
+	if compat == nil {
+		klog.Infof("Not mounting CUDA forward compatibility libraries for %v: driver version %v is not older than the libraries", d.resource, driverVersion)
+		return nil
+	}
+	klog.Infof("Mounting CUDA forward compatibility libraries %v (version %v) for %v: driver version %v is older", d.dir, compat.version, d.resource, driverVersion)
+	d.compat = compat
+	return compat
+}
+
+// cudaCompatPackage is a CUDA forward compatibility package installed on the
+// host for a specific CUDA version.
+type cudaCompatPackage struct {
+	cudaVersion spec.CUDAVersion
+	dir         string
+	version     string
+	parsed      []int
+}
+
+// cudaCompatPackages returns the CUDA forward compatibility packages installed
+// in the cuda-<major>.<minor>/compat subdirectories of the specified
+// directory, ordered from the oldest to the newest CUDA version. Directories
+// without compatibility libraries are skipped.
+func cudaCompatPackages(dir string) ([]cudaCompatPackage, error) {
+	dirs, err := filepath.Glob(filepath.Join(dir, "cuda-*", "compat"))
+	if err != nil {
+		return nil, err
+	}
+	var packages []cudaCompatPackage
+	for _, d := range dirs {
+		cudaVersion := spec.CUDAVersion(strings.TrimPrefix(filepath.Base(filepath.Dir(d)), "cuda-"))
+		if _, _, err := cudaVersion.Parse(); err != nil {
+			continue
+		}
+		version, parsed, err := compatLibraryVersion(d)
+		if err != nil {
+			klog.Warningf("Ignoring CUDA forward compatibility package %v: %v", d, err)
+			continue
+		}
+		packages = append(packages, cudaCompatPackage{cudaVersion: cudaVersion, dir: d, version: version, parsed: parsed})
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].cudaVersion.Compare(packages[j].cudaVersion) < 0
+	})
+	return packages, nil
+}
+
+// selectCUDACompat selects the oldest of the specified packages that supports
+// the required CUDA version. If the driver is not older than the libraries of
+// that package, the driver already supports the required CUDA version and a
+// nil cudaCompat is returned.
+func selectCUDACompat(packages []cudaCompatPackage, required spec.CUDAVersion, driver []int) (*cudaCompat, error) {
+	for _, p := range packages {
+		if p.cudaVersion.Compare(required) < 0 {
+			continue
+		}
+		if compareVersions(p.parsed, driver) <= 0 {
+			return nil, nil
+		}
+		return &cudaCompat{hostDir: p.dir, version: p.version}, nil
+	}
+	return nil, fmt.Errorf("no CUDA forward compatibility package supports CUDA %v", required)
+}
+
+// cudaCompatPolicyRequest selects the CUDA forward compatibility package that
+// is mounted for the pod of an Allocate request according to a
+// CUDACompatPolicy.
+//
+// The pod is identified in the same way as for a threadPercentageRequest. The
+// CUDA version required by a pod is read from its CUDAVersionAnnotation or
+// else derived from the images of its containers. Since the libraries change
+// the CUDA driver seen by a container, no libraries are mounted unless exactly
+// one pod may be allocating the resource.
+type cudaCompatPolicyRequest struct {
+	resource          spec.ResourceName
+	policy            *spec.CUDACompatPolicy
+	driverVersionFile string
+}
+
+// newCUDACompatPolicyRequest creates a request for the specified policy. The
+// driver version is read for each request so that the selection follows
+// driver upgrades that do not restart the plugin.
+func newCUDACompatPolicyRequest(resource spec.ResourceName, policy *spec.CUDACompatPolicy) *cudaCompatPolicyRequest {
+	return &cudaCompatPolicyRequest{
+		resource:          resource,
+		policy:            policy,
+		driverVersionFile: driverVersionFile,
+	}
+}
+
+// get returns the CUDA forward compatibility libraries to mount for the pod
+// of an Allocate request, or nil if the pod does not require a newer CUDA
+// version than the driver supports or cannot be identified.
+func (r *cudaCompatPolicyRequest) get(allocating *allocatingPodList) *cudaCompat {
+	if r == nil {
+		return nil
+	}
+	pods, err := allocating.get()
+	if err != nil {
+		klog.Warningf("Failed to identify the pods allocating %v: %v", r.resource, err)
+		return nil
+	}
+	if len(pods) != 1 {
+		if len(pods) > 1 {
+			klog.Warningf("Not mounting CUDA forward compatibility libraries for %v: %d pods are allocating the resource", r.resource, len(pods))
+		}
+		return nil
+	}
+	required := r.requiredCUDAVersion(pods[0])
+	if required == "" {
+		return nil
+	}
+
+	driverVersion, err := readDriverVersion(r.driverVersionFile)
+	if err != nil {
+		klog.Warningf("Not mounting CUDA forward compatibility libraries for %v: %v", r.resource, err)
+		return nil
+	}
+	driver, err := parseDriverVersion(driverVersion)
+	if err != nil {
+		klog.Warningf("Not mounting CUDA forward compatibility libraries for %v: invalid driver version %q: %v", r.resource, driverVersion, err)
+		return nil
+	}
+
+	packages, err := cudaCompatPackages(r.policy.GetPackagesDir())
+	if err != nil {
+		klog.Warningf("Failed to list CUDA forward compatibility packages for %v: %v", r.resource, err)
+		return nil
+	}
+	compat, err := selectCUDACompat(packages, required, driver)
+	if err != nil {
+		klog.Warningf("Not mounting CUDA forward compatibility libraries for %v: %v", r.resource, err)
+		return nil
+	}
+	if compat != nil {
+		klog.Infof("Mounting CUDA forward compatibility libraries %v (version %v) for %v: CUDA %v is required", compat.hostDir, compat.version, r.resource, required)
+	}
+	return compat
+}
+
+// requiredCUDAVersion returns the CUDA version required by the specified pod,
+// or an empty version if the pod does not declare one.
+func (r *cudaCompatPolicyRequest) requiredCUDAVersion(pod podresources.PendingPod) spec.CUDAVersion {
+	if value, ok := pod.Annotations[spec.CUDAVersionAnnotation]; ok {
+		v := spec.CUDAVersion(value)
+		if _, _, err := v.Parse(); err == nil {
+			return v
+		}
+		klog.Warningf("Ignoring invalid %v annotation %q of pod %v/%v", spec.CUDAVersionAnnotation, value, pod.Namespace, pod.Name)
+	}
+	return r.policy.RequiredCUDAVersion(pod.Images)
+}
+
+// readDriverVersion reads the version of the loaded NVIDIA kernel module.
+func readDriverVersion(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read driver version: %w", err)
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// parseDriverVersion parses a driver version such as 550.54.15.
+func parseDriverVersion(version string) ([]int, error) {
+	var parsed []int
+	for _, part := range strings.Split(version, ".") {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, v)
+	}
+	return parsed, nil
+}
+
+// compareVersions compares two parsed versions, returning a negative value if
+// a is older than b, a positive value if a is newer than b, and 0 otherwise.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// updateResponse mounts the CUDA forward compatibility libraries and prepends them to the library search path.
+// Since the environment of the container image is not known to the plugin, they are prepended to the
+// LD_LIBRARY_PATH set by the CUDA container images.
+func (c *cudaCompat) updateResponse(response *pluginapi.ContainerAllocateResponse) {
+	if c == nil {
+		return
+	}
+	response.Mounts = append(response.Mounts, &pluginapi.Mount{
+		ContainerPath: cudaCompatContainerPath,
+		HostPath:      c.hostDir,
+		ReadOnly:      true,
+	})
+	response.Envs["LD_LIBRARY_PATH"] = cudaCompatContainerPath + ":" + cudaImageLibraryPath
+}
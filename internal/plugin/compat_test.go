@@ -0,0 +1,286 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+func TestNewCUDACompat(t *testing.T) {
+	testCases := []struct {
+		description     string
+		libs            []string
+		driverVersion   string
+		expectedVersion string
+		expectedError   bool
+	}{
+		{
+			description:     "driver older than libraries",
+			libs:            []string{"libcuda.so", "libcuda.so.1", "libcuda.so.550.54.15"},
+			driverVersion:   "535.161.08",
+			expectedVersion: "550.54.15",
+		},
+		{
+			description:     "newest libraries are selected",
+			libs:            []string{"libcuda.so.545.23.08", "libcuda.so.550.54.15"},
+			driverVersion:   "535.161.08",
+			expectedVersion: "550.54.15",
+		},
+		{
+			description:   "driver newer than libraries",
+			libs:          []string{"libcuda.so.535.161.08"},
+			driverVersion: "550.54.15",
+		},
+		{
+			description:   "driver matching libraries",
+			libs:          []string{"libcuda.so.550.54.15"},
+			driverVersion: "550.54.15",
+		},
+		{
+			description:   "no libraries is an error",
+			libs:          []string{"libcuda.so.1"},
+			driverVersion: "535.161.08",
+			expectedError: true,
+		},
+		{
+			description:   "invalid driver version is an error",
+			libs:          []string{"libcuda.so.550.54.15"},
+			driverVersion: "unknown",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, lib := range tc.libs {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, lib), nil, 0600))
+			}
+
+			compat, err := newCUDACompat(dir, tc.driverVersion)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expectedVersion == "" {
+				require.Nil(t, compat)
+				return
+			}
+			require.Equal(t, &cudaCompat{hostDir: dir, version: tc.expectedVersion}, compat)
+		})
+	}
+}
+
+func TestCUDACompatUpdateResponse(t *testing.T) {
+	response := &pluginapi.ContainerAllocateResponse{Envs: make(map[string]string)}
+	(*cudaCompat)(nil).updateResponse(response)
+	require.Empty(t, response.Mounts)
+	require.Empty(t, response.Envs)
+
+	compat := &cudaCompat{hostDir: "/usr/local/cuda/compat", version: "550.54.15"}
+	compat.updateResponse(response)
+	require.Equal(t, []*pluginapi.Mount{
+		{ContainerPath: cudaCompatContainerPath, HostPath: "/usr/local/cuda/compat", ReadOnly: true},
+	}, response.Mounts)
+	require.Equal(t, map[string]string{"LD_LIBRARY_PATH": cudaCompatContainerPath + ":/usr/local/nvidia/lib:/usr/local/nvidia/lib64"}, response.Envs)
+}
+
+func TestCUDACompatDetector(t *testing.T) {
+	require.Nil(t, (*cudaCompatDetector)(nil).get())
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "libcuda.so.550.54.15"), nil, 0600))
+	versionFile := filepath.Join(t.TempDir(), "version")
+
+	d := newCUDACompatDetector("nvidia.com/gpu", dir)
+	d.driverVersionFile = versionFile
+	require.Nil(t, d.get(), "missing driver version")
+
+	require.NoError(t, os.WriteFile(versionFile, []byte("535.161.08\n"), 0600))
+	require.Equal(t, &cudaCompat{hostDir: dir, version: "550.54.15"}, d.get())
+
+	// The libraries are no longer mounted once the driver is upgraded.
+	require.NoError(t, os.WriteFile(versionFile, []byte("560.35.03\n"), 0600))
+	require.Nil(t, d.get())
+}
+
+// writeCUDACompatPackages creates a cuda-<version>/compat directory with the
+// specified libcuda version for each CUDA version.
+func writeCUDACompatPackages(t *testing.T, packages map[string]string) string {
+	dir := t.TempDir()
+	for cudaVersion, version := range packages {
+		compat := filepath.Join(dir, "cuda-"+cudaVersion, "compat")
+		require.NoError(t, os.MkdirAll(compat, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(compat, "libcuda.so."+version), nil, 0600))
+	}
+	return dir
+}
+
+func TestSelectCUDACompat(t *testing.T) {
+	dir := writeCUDACompatPackages(t, map[string]string{
+		"12.4":  "550.54.15",
+		"12.10": "575.51.03",
+		"12.6":  "560.35.03",
+	})
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cuda-12.8", "compat"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cuda-latest", "compat"), 0755))
+
+	packages, err := cudaCompatPackages(dir)
+	require.NoError(t, err)
+	var versions []spec.CUDAVersion
+	for _, p := range packages {
+		versions = append(versions, p.cudaVersion)
+	}
+	require.Equal(t, []spec.CUDAVersion{"12.4", "12.6", "12.10"}, versions)
+
+	driver := []int{535, 161, 8}
+	testCases := []struct {
+		description   string
+		required      spec.CUDAVersion
+		driver        []int
+		expectedDir   string
+		expectedError bool
+	}{
+		{
+			description: "oldest package supporting the version is selected",
+			required:    "12.5",
+			driver:      driver,
+			expectedDir: filepath.Join(dir, "cuda-12.6", "compat"),
+		},
+		{
+			description: "exact version is selected",
+			required:    "12.10",
+			driver:      driver,
+			expectedDir: filepath.Join(dir, "cuda-12.10", "compat"),
+		},
+		{
+			description: "driver supporting the version",
+			required:    "12.4",
+			driver:      []int{550, 90, 7},
+		},
+		{
+			description:   "no package supporting the version is an error",
+			required:      "13.0",
+			driver:        driver,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			compat, err := selectCUDACompat(packages, tc.required, tc.driver)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expectedDir == "" {
+				require.Nil(t, compat)
+				return
+			}
+			require.Equal(t, tc.expectedDir, compat.hostDir)
+		})
+	}
+}
+
+func TestCUDACompatPolicyRequest(t *testing.T) {
+	require.Nil(t, (*cudaCompatPolicyRequest)(nil).get(nil))
+
+	versionFile := filepath.Join(t.TempDir(), "version")
+	require.NoError(t, os.WriteFile(versionFile, []byte("535.161.08\n"), 0600))
+
+	dir := writeCUDACompatPackages(t, map[string]string{
+		"12.4": "550.54.15",
+		"12.6": "560.35.03",
+	})
+	policy := &spec.CUDACompatPolicy{
+		PackagesDir: dir,
+		Images: []spec.CUDACompatImage{
+			{Pattern: "nvcr.io/nvidia/pytorch:24.*", CUDAVersion: "12.4"},
+		},
+	}
+	pod := func(name string, annotation string, images ...string) podresources.PendingPod {
+		p := podresources.PendingPod{Namespace: "default", Name: name, Containers: 1, Images: images}
+		if annotation != "" {
+			p.Annotations = map[string]string{spec.CUDAVersionAnnotation: annotation}
+		}
+		return p
+	}
+
+	testCases := []struct {
+		description string
+		pending     fakePendingPodLister
+		expectedDir string
+	}{
+		{
+			description: "no pending pods",
+		},
+		{
+			description: "image without declared CUDA version",
+			pending:     fakePendingPodLister{pod("pod", "", "busybox")},
+		},
+		{
+			description: "CUDA version of the image",
+			pending:     fakePendingPodLister{pod("pod", "", "nvcr.io/nvidia/pytorch:24.03-py3")},
+			expectedDir: filepath.Join(dir, "cuda-12.4", "compat"),
+		},
+		{
+			description: "annotation takes precedence over the image",
+			pending:     fakePendingPodLister{pod("pod", "12.6", "nvcr.io/nvidia/pytorch:24.03-py3")},
+			expectedDir: filepath.Join(dir, "cuda-12.6", "compat"),
+		},
+		{
+			description: "invalid annotation falls back to the image",
+			pending:     fakePendingPodLister{pod("pod", "twelve", "nvcr.io/nvidia/pytorch:24.03-py3")},
+			expectedDir: filepath.Join(dir, "cuda-12.4", "compat"),
+		},
+		{
+			description: "nothing is mounted for several pods",
+			pending:     fakePendingPodLister{pod("a", "", "nvcr.io/nvidia/pytorch:24.03-py3"), pod("b", "12.6")},
+		},
+		{
+			description: "unsupported version is ignored",
+			pending:     fakePendingPodLister{pod("pod", "13.0")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &cudaCompatPolicyRequest{
+				resource:          "nvidia.com/gpu",
+				policy:            policy,
+				driverVersionFile: versionFile,
+			}
+			compat := r.get(newAllocatingPodList("nvidia.com/gpu", tc.pending, fakeContainerDevicesLister{}))
+			if tc.expectedDir == "" {
+				require.Nil(t, compat)
+				return
+			}
+			require.NotNil(t, compat)
+			require.Equal(t, tc.expectedDir, compat.hostDir)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// releaseCooldown avoids allocating the devices of physical GPUs that were
+// recently released by a container. A GPU is considered released once a
+// container that was allocated one of its devices is no longer part of the
+// allocations passed by the allocationWatcher of the plugin.
+type releaseCooldown struct {
+	sync.Mutex
+	resource spec.ResourceName
+	duration time.Duration
+	now      func() time.Time
+
+	// allocated is the set of allocated device IDs at the last observation.
+	// It is nil until the first observation.
+	allocated map[string]bool
+	// released records when each physical GPU was last released.
+	released map[string]time.Time
+}
+
+func newReleaseCooldown(resource spec.ResourceName, duration time.Duration) *releaseCooldown {
+	return &releaseCooldown{
+		resource: resource,
+		duration: duration,
+		now:      time.Now,
+		released: make(map[string]time.Time),
+	}
+}
+
+// observe records the physical GPUs of the devices that were released since
+// the last observation and forgets the GPUs whose cooldown expired. It
+// returns whether the GPUs that are cooling down changed, since burst
+// replicas are advertised in place of the devices of these GPUs.
+func (c *releaseCooldown) observe(a allocations) bool {
+	allocated := a.deviceIDs()
+
+	c.Lock()
+	defer c.Unlock()
+	now := c.now()
+	changed := false
+	for id := range c.allocated {
+		if allocated[id] {
+			continue
+		}
+		gpu := rm.AnnotatedID(id).GetID()
+		klog.V(4).Infof("Device %v of %v released; not preferred for %v", gpu, c.resource, c.duration)
+		c.released[gpu] = now
+		changed = true
+	}
+	for gpu, at := range c.released {
+		if now.Sub(at) >= c.duration {
+			delete(c.released, gpu)
+			changed = true
+		}
+	}
+	c.allocated = allocated
+	return changed
+}
+
+// CoolingDown returns the IDs of the physical GPUs that are cooling down.
+func (c *releaseCooldown) CoolingDown() map[string]bool {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	now := c.now()
+	coolingDown := make(map[string]bool)
+	for gpu, at := range c.released {
+		if now.Sub(at) < c.duration {
+			coolingDown[gpu] = true
+		}
+	}
+	return coolingDown
+}
+
+// Available removes the devices of the physical GPUs that are cooling down
+// from the available devices. The GPUs of the required devices are kept. If
+// fewer than size devices remain, the available devices are returned
+// unchanged; the cooldown only steers allocations to other GPUs and never
+// causes them to fail.
+func (c *releaseCooldown) Available(available, required []string, size int) []string {
+	if c == nil {
+		return available
+	}
+	keep := make(map[string]bool)
+	for _, gpu := range physicalGPUs(required) {
+		keep[gpu] = true
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	now := c.now()
+	var filtered []string
+	for _, id := range available {
+		gpu := rm.AnnotatedID(id).GetID()
+		if at, released := c.released[gpu]; keep[gpu] || !released || now.Sub(at) >= c.duration {
+			filtered = append(filtered, id)
+		}
+	}
+	if len(filtered) < size {
+		return available
+	}
+	return filtered
+}
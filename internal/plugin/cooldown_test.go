@@ -0,0 +1,55 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseCooldown(t *testing.T) {
+	var nilCooldown *releaseCooldown
+	require.Equal(t, []string{"GPU-0::0"}, nilCooldown.Available([]string{"GPU-0::0"}, nil, 1))
+	require.Nil(t, nilCooldown.CoolingDown())
+
+	now := time.Unix(0, 0)
+	allocated := allocations{{"GPU-0::0"}, {"GPU-1::0"}}
+	cooldown := newReleaseCooldown("nvidia.com/gpu", time.Minute)
+	cooldown.now = func() time.Time { return now }
+	require.False(t, cooldown.observe(allocated))
+
+	available := []string{"GPU-0::1", "GPU-1::1", "GPU-2::0"}
+	require.Equal(t, available, cooldown.Available(available, nil, 1))
+	require.Empty(t, cooldown.CoolingDown())
+
+	// The container of pod a is removed, which releases GPU-0.
+	now = now.Add(10 * time.Second)
+	require.True(t, cooldown.observe(allocated[1:]))
+
+	require.Equal(t, []string{"GPU-1::1", "GPU-2::0"}, cooldown.Available(available, nil, 1))
+	require.Equal(t, available, cooldown.Available(available, []string{"GPU-0::2"}, 2), "GPUs of required devices are kept")
+	require.Equal(t, available, cooldown.Available(available, nil, 3), "all devices are returned if too few remain")
+	require.Equal(t, map[string]bool{"GPU-0": true}, cooldown.CoolingDown())
+
+	now = now.Add(time.Minute)
+	require.Equal(t, available, cooldown.Available(available, nil, 1))
+	require.Empty(t, cooldown.CoolingDown())
+	require.True(t, cooldown.observe(allocated[1:]))
+	require.Empty(t, cooldown.released)
+}
@@ -0,0 +1,177 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// DualAdvertiser coordinates the plugins of the shared resources whose GPUs
+// are also advertised as whole devices (advertiseWhole). Once a physical GPU
+// is allocated in one form, the devices of the GPU in the other form are
+// advertised as unhealthy until the GPU is released. It is shared between
+// the plugins of a node.
+//
+// The GPUs in use by each resource are taken from the allocations passed by
+// the allocationWatcher of its plugin, which include the allocations that
+// were admitted but are not listed by the PodResources API yet.
+// A nil DualAdvertiser does nothing.
+type DualAdvertiser struct {
+	sync.Mutex
+	// counterparts maps the whole and the shared resource of each GPU to the
+	// other one.
+	counterparts map[spec.ResourceName]spec.ResourceName
+	// inUse holds the allocated GPUs of each resource.
+	inUse   map[spec.ResourceName]map[string]bool
+	updates map[spec.ResourceName]chan struct{}
+}
+
+// NewDualAdvertiser creates a DualAdvertiser for the resources of the
+// specified sharing config that advertise whole GPUs. If there are no such
+// resources, nil is returned.
+func NewDualAdvertiser(sharing *spec.Sharing) *DualAdvertiser {
+	counterparts := make(map[spec.ResourceName]spec.ResourceName)
+	for _, rrs := range sharing.AllReplicatedResources() {
+		for _, r := range rrs.Resources {
+			if !r.AdvertiseWhole || r.Rename == "" || r.Rename == r.Name {
+				continue
+			}
+			counterparts[r.Name] = r.Rename
+			counterparts[r.Rename] = r.Name
+		}
+	}
+	if len(counterparts) == 0 {
+		return nil
+	}
+
+	d := &DualAdvertiser{
+		counterparts: counterparts,
+		inUse:        make(map[spec.ResourceName]map[string]bool),
+		updates:      make(map[spec.ResourceName]chan struct{}),
+	}
+	for resource := range counterparts {
+		d.updates[resource] = make(chan struct{}, 1)
+	}
+	return d
+}
+
+// Advertises returns whether the GPUs of the specified resource are
+// advertised in both forms.
+func (d *DualAdvertiser) Advertises(resource spec.ResourceName) bool {
+	if d == nil {
+		return false
+	}
+	_, exists := d.counterparts[resource]
+	return exists
+}
+
+// observer returns the observer of the allocations of the specified
+// resource, or nil if the resource is not advertised in both forms.
+func (d *DualAdvertiser) observer(resource spec.ResourceName) allocationObserver {
+	if !d.Advertises(resource) {
+		return nil
+	}
+	return &dualObserver{d, resource}
+}
+
+// dualObserver records the GPUs that are allocated to a resource.
+type dualObserver struct {
+	*DualAdvertiser
+	resource spec.ResourceName
+}
+
+// observe records the allocated GPUs of the resource. The devices of the
+// counterpart of the resource are notified if they changed; the devices of
+// the resource itself are not affected.
+func (o *dualObserver) observe(a allocations) bool {
+	inUse := make(map[string]bool)
+	for gpu := range a.clients() {
+		inUse[gpu] = true
+	}
+
+	o.Lock()
+	defer o.Unlock()
+	if maps.Equal(inUse, o.inUse[o.resource]) {
+		return false
+	}
+	klog.V(4).Infof("GPUs in use by %v: %v", o.resource, inUse)
+	o.inUse[o.resource] = inUse
+	o.notify(o.counterparts[o.resource])
+	return false
+}
+
+// Claim checks that none of the GPUs of the specified devices are in use by
+// the counterpart of the resource. The devices are expected to be admitted
+// through the allocationWatcher of the resource already, so that the
+// counterpart withholds its devices of these GPUs.
+func (d *DualAdvertiser) Claim(resource spec.ResourceName, ids []string) error {
+	if !d.Advertises(resource) {
+		return nil
+	}
+	counterpart := d.counterparts[resource]
+
+	d.Lock()
+	defer d.Unlock()
+	for _, gpu := range physicalGPUs(ids) {
+		if d.inUse[counterpart][gpu] {
+			return fmt.Errorf("device %v is allocated as %v", gpu, counterpart)
+		}
+	}
+	return nil
+}
+
+// Withheld returns the IDs of the specified devices of a resource whose GPUs
+// are in use by the counterpart of the resource.
+func (d *DualAdvertiser) Withheld(resource spec.ResourceName, devices rm.Devices) map[string]bool {
+	if !d.Advertises(resource) {
+		return nil
+	}
+	d.Lock()
+	defer d.Unlock()
+	inUse := d.inUse[d.counterparts[resource]]
+	withheld := make(map[string]bool)
+	for id := range devices {
+		if inUse[rm.AnnotatedID(id).GetID()] {
+			withheld[id] = true
+		}
+	}
+	return withheld
+}
+
+// Updates returns a channel that is notified when the devices withheld from
+// the specified resource may have changed. If the resource is not advertised
+// in both forms, the channel is never notified.
+func (d *DualAdvertiser) Updates(resource spec.ResourceName) <-chan struct{} {
+	if !d.Advertises(resource) {
+		return nil
+	}
+	return d.updates[resource]
+}
+
+func (d *DualAdvertiser) notify(resource spec.ResourceName) {
+	select {
+	case d.updates[resource] <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,81 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestDualAdvertiser(t *testing.T) {
+	require.Nil(t, NewDualAdvertiser(&spec.Sharing{}))
+
+	var nilDual *DualAdvertiser
+	require.False(t, nilDual.Advertises("nvidia.com/gpu"))
+	require.NoError(t, nilDual.Claim("nvidia.com/gpu", []string{"GPU-0"}))
+	require.Nil(t, nilDual.Withheld("nvidia.com/gpu", nil))
+
+	sharing := &spec.Sharing{
+		TimeSlicing: spec.ReplicatedResources{
+			Resources: []spec.ReplicatedResource{
+				{Name: "nvidia.com/gpu", Rename: "nvidia.com/gpu.shared", Replicas: 2, AdvertiseWhole: true},
+			},
+		},
+	}
+	whole := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0"}},
+		"GPU-1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1"}},
+	}
+	shared := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+		"GPU-1::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-1::0"}},
+		"GPU-1::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1::1"}},
+	}
+
+	dual := NewDualAdvertiser(sharing)
+	require.True(t, dual.Advertises("nvidia.com/gpu"))
+	require.True(t, dual.Advertises("nvidia.com/gpu.shared"))
+	require.False(t, dual.Advertises("nvidia.com/mig-1g.10gb"))
+	require.Nil(t, dual.observer("nvidia.com/mig-1g.10gb"))
+
+	wholeObserver := dual.observer("nvidia.com/gpu")
+	sharedObserver := dual.observer("nvidia.com/gpu.shared")
+
+	// Allocating a replica withholds the whole GPU.
+	require.False(t, sharedObserver.observe(allocations{{"GPU-0::1"}}))
+	require.Len(t, dual.Updates("nvidia.com/gpu"), 1)
+	require.Equal(t, map[string]bool{"GPU-0": true}, dual.Withheld("nvidia.com/gpu", whole))
+	require.Empty(t, dual.Withheld("nvidia.com/gpu.shared", shared))
+	require.EqualError(t, dual.Claim("nvidia.com/gpu", []string{"GPU-0"}), "device GPU-0 is allocated as nvidia.com/gpu.shared")
+	require.NoError(t, dual.Claim("nvidia.com/gpu.shared", []string{"GPU-0::0"}))
+
+	// Allocating a whole GPU withholds its replicas.
+	require.NoError(t, dual.Claim("nvidia.com/gpu", []string{"GPU-1"}))
+	wholeObserver.observe(allocations{{"GPU-1"}})
+	require.Equal(t, map[string]bool{"GPU-1::0": true, "GPU-1::1": true}, dual.Withheld("nvidia.com/gpu.shared", shared))
+
+	// The GPUs are released once they are no longer allocated.
+	sharedObserver.observe(allocations{})
+	require.Empty(t, dual.Withheld("nvidia.com/gpu", whole))
+	require.Equal(t, map[string]bool{"GPU-1::0": true, "GPU-1::1": true}, dual.Withheld("nvidia.com/gpu.shared", shared))
+}
@@ -0,0 +1,61 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxEvents is the number of recent events that are kept for each plugin.
+const maxEvents = 50
+
+// Event is a notable change in the state of a plugin.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+// eventRecorder keeps the most recent events of a plugin.
+type eventRecorder struct {
+	sync.Mutex
+	events []Event
+}
+
+// record adds an event with the specified message. If more than maxEvents
+// events are recorded, the oldest event is dropped.
+func (r *eventRecorder) record(format string, args ...interface{}) {
+	event := Event{
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf(format, args...),
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+	if len(r.events) > maxEvents {
+		r.events = r.events[len(r.events)-maxEvents:]
+	}
+}
+
+// get returns a copy of the recorded events, ordered from oldest to newest.
+func (r *eventRecorder) get() []Event {
+	r.Lock()
+	defer r.Unlock()
+	return append([]Event{}, r.events...)
+}
@@ -1,5 +1,5 @@
 /**
-# Copyright 2023 NVIDIA CORPORATION
+# Copyright 2024 NVIDIA CORPORATION
 #
 # Licensed under the Apache License, Version 2.0 (the "License");
 # you may not use this file except in compliance with the License.
@@ -14,25 +14,29 @@
 # limitations under the License.
 **/
 
-package root
+package plugin
 
 import (
-	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/transform"
-	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/transform/noop"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
-type builder struct {
-	transformer
-	relativeTo string
-}
+func TestEventRecorder(t *testing.T) {
+	r := &eventRecorder{}
+	require.Empty(t, r.get())
 
-func (b *builder) build() transform.Transformer {
-	if b.root == b.targetRoot {
-		return noop.New()
+	for i := 0; i < maxEvents+2; i++ {
+		r.record("event %d", i)
 	}
 
-	if b.relativeTo == "container" {
-		return containerRootTransformer(b.transformer)
-	}
-	return hostRootTransformer(b.transformer)
+	events := r.get()
+	require.Len(t, events, maxEvents)
+	require.Equal(t, "event 2", events[0].Message)
+	require.Equal(t, fmt.Sprintf("event %d", maxEvents+1), events[maxEvents-1].Message)
+
+	// The returned events are a copy.
+	events[0].Message = "modified"
+	require.Equal(t, "event 2", r.get()[0].Message)
 }
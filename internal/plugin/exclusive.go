@@ -0,0 +1,218 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// exclusiveSyncInterval is the interval at which the pods that requested
+// exclusive access to their GPUs are detected.
+const exclusiveSyncInterval = 10 * time.Second
+
+// exclusiveTracker withholds the other replicas of the physical GPUs that are
+// allocated to pods with the exclusive annotation, so that the kubelet does
+// not hand them out to other pods while these pods run. The pods are listed
+// through the kubelet's PodResources API and their annotations are read from
+// the API server.
+//
+// Since the kubelet does not identify the pod in an Allocate request, the
+// replicas are only withheld once the pod is listed by the PodResources API.
+// Replicas that were already allocated to other pods are reported as conflicts.
+// Until the kubelet has seen the updated device health, Admit rejects the
+// allocation of withheld replicas.
+type exclusiveTracker struct {
+	sync.Mutex
+	resource    spec.ResourceName
+	devices     rm.Devices
+	lister      PodDevicesLister
+	annotations PodAnnotationGetter
+	events      *eventRecorder
+
+	// syncing serializes the calls to sync, which may overlap when the
+	// plugin is restarted, and guards exclusive and conflicts.
+	syncing sync.Mutex
+	// exclusive caches whether a pod requested exclusive access, keyed by
+	// the namespace and name of the pod.
+	exclusive map[string]bool
+	// conflicts holds the exclusive pods for which a conflict was reported.
+	conflicts map[string]bool
+
+	withheld map[string]bool
+	updates  chan struct{}
+}
+
+func newExclusiveTracker(resource spec.ResourceName, devices rm.Devices, lister PodDevicesLister, annotations PodAnnotationGetter, events *eventRecorder) *exclusiveTracker {
+	return &exclusiveTracker{
+		resource:    resource,
+		devices:     devices,
+		lister:      lister,
+		annotations: annotations,
+		events:      events,
+		exclusive:   make(map[string]bool),
+		conflicts:   make(map[string]bool),
+		withheld:    make(map[string]bool),
+		updates:     make(chan struct{}, 1),
+	}
+}
+
+// run keeps the withheld replicas in sync with the pods that requested
+// exclusive access until stop is closed.
+func (e *exclusiveTracker) run(stop <-chan interface{}) {
+	if e == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(exclusiveSyncInterval)
+	defer ticker.Stop()
+	for {
+		e.sync(ctx)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Withheld returns the IDs of the replicas that are withheld from the kubelet.
+func (e *exclusiveTracker) Withheld() map[string]bool {
+	if e == nil {
+		return nil
+	}
+	e.Lock()
+	defer e.Unlock()
+	return maps.Clone(e.withheld)
+}
+
+// Admit returns an error if any of the requested replicas is withheld for a
+// pod with exclusive access. The check holds the same lock as the update of
+// the withheld replicas, so a replica is never handed out once it is withheld.
+func (e *exclusiveTracker) Admit(ids []string) error {
+	if e == nil {
+		return nil
+	}
+	e.Lock()
+	defer e.Unlock()
+	for _, id := range ids {
+		if e.withheld[id] {
+			return fmt.Errorf("device %v is withheld for a pod with exclusive access", id)
+		}
+	}
+	return nil
+}
+
+// Updates returns a channel that is notified when the withheld replicas
+// change. If the tracker is nil, the channel is never notified.
+func (e *exclusiveTracker) Updates() <-chan struct{} {
+	if e == nil {
+		return nil
+	}
+	return e.updates
+}
+
+// sync withholds the other replicas of the GPUs that are allocated to pods
+// with the exclusive annotation.
+func (e *exclusiveTracker) sync(ctx context.Context) {
+	e.syncing.Lock()
+	defer e.syncing.Unlock()
+
+	pods, err := e.lister.AllocatedPodDevices(ctx, string(e.resource))
+	if err != nil {
+		klog.Warningf("Failed to get allocated devices for %v: %v", e.resource, err)
+		return
+	}
+
+	owners := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		seen[key] = true
+		for _, id := range pod.DeviceIDs {
+			owners[id] = key
+		}
+	}
+	for key := range e.exclusive {
+		if !seen[key] {
+			delete(e.exclusive, key)
+			delete(e.conflicts, key)
+		}
+	}
+
+	withheld := make(map[string]bool)
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		if !e.isExclusive(ctx, key, pod.Namespace, pod.Name) {
+			continue
+		}
+		for id := range e.devices.OtherReplicas(pod.DeviceIDs...) {
+			withheld[id] = true
+			if owner, allocated := owners[id]; allocated && owner != key && !e.conflicts[key] {
+				klog.Warningf("Pod %v requested exclusive access to its %v devices, but device %v is already allocated to pod %v", key, e.resource, id, owner)
+				e.events.record("Exclusive access of pod %v conflicts with pod %v on device %v", key, owner, id)
+				e.conflicts[key] = true
+			}
+		}
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	if maps.Equal(withheld, e.withheld) {
+		return
+	}
+	klog.Infof("Withholding %d %v devices for pods with exclusive access", len(withheld), e.resource)
+	e.withheld = withheld
+	select {
+	case e.updates <- struct{}{}:
+	default:
+	}
+}
+
+// isExclusive returns whether the specified pod requested exclusive access.
+// The result is cached for as long as the pod is listed.
+func (e *exclusiveTracker) isExclusive(ctx context.Context, key string, namespace string, name string) bool {
+	if exclusive, cached := e.exclusive[key]; cached {
+		return exclusive
+	}
+	annotations, err := e.annotations.PodAnnotations(ctx, namespace, name)
+	if err != nil {
+		klog.Warningf("Failed to get annotations of pod %v: %v", key, err)
+		return false
+	}
+	exclusive := annotations[spec.ExclusiveAnnotation] == "true"
+	if exclusive {
+		klog.Infof("Pod %v requested exclusive access to its %v devices", key, e.resource)
+		e.events.record("Pod %v requested exclusive access", key)
+	}
+	e.exclusive[key] = exclusive
+	return exclusive
+}
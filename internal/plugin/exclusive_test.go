@@ -0,0 +1,217 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type fakePodDevicesLister []podresources.PodDevices
+
+func (l fakePodDevicesLister) AllocatedDevices(context.Context, string) (map[string]bool, error) {
+	return nil, nil
+}
+
+func (l fakePodDevicesLister) AllocatedPodDevices(context.Context, string) ([]podresources.PodDevices, error) {
+	return l, nil
+}
+
+type fakePodAnnotationGetter map[string]map[string]string
+
+func (g fakePodAnnotationGetter) PodAnnotations(_ context.Context, namespace, name string) (map[string]string, error) {
+	annotations, ok := g[namespace+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("pod %v/%v not found", namespace, name)
+	}
+	return annotations, nil
+}
+
+func TestExclusiveTrackerSync(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+		"GPU-0::2": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::2"}},
+		"GPU-1::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-1::0"}},
+		"GPU-1::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1::1"}},
+	}
+	exclusive := map[string]string{spec.ExclusiveAnnotation: "true"}
+
+	testCases := []struct {
+		description       string
+		pods              fakePodDevicesLister
+		annotations       fakePodAnnotationGetter
+		expectedWithheld  map[string]bool
+		expectedConflicts int
+	}{
+		{
+			description: "no exclusive pods",
+			pods: fakePodDevicesLister{
+				{Namespace: "default", Name: "shared", DeviceIDs: []string{"GPU-0::0"}},
+			},
+			annotations: fakePodAnnotationGetter{
+				"default/shared": nil,
+			},
+			expectedWithheld: map[string]bool{},
+		},
+		{
+			description: "other replicas of exclusive pod are withheld",
+			pods: fakePodDevicesLister{
+				{Namespace: "default", Name: "exclusive", DeviceIDs: []string{"GPU-0::1"}},
+				{Namespace: "default", Name: "shared", DeviceIDs: []string{"GPU-1::0"}},
+			},
+			annotations: fakePodAnnotationGetter{
+				"default/exclusive": exclusive,
+				"default/shared":    nil,
+			},
+			expectedWithheld: map[string]bool{"GPU-0::0": true, "GPU-0::2": true},
+		},
+		{
+			description: "replicas allocated to other pods are reported once",
+			pods: fakePodDevicesLister{
+				{Namespace: "default", Name: "exclusive", DeviceIDs: []string{"GPU-0::1"}},
+				{Namespace: "default", Name: "shared", DeviceIDs: []string{"GPU-0::0", "GPU-0::2"}},
+			},
+			annotations: fakePodAnnotationGetter{
+				"default/exclusive": exclusive,
+				"default/shared":    nil,
+			},
+			expectedWithheld:  map[string]bool{"GPU-0::0": true, "GPU-0::2": true},
+			expectedConflicts: 1,
+		},
+		{
+			description: "annotation other than true is ignored",
+			pods: fakePodDevicesLister{
+				{Namespace: "default", Name: "exclusive", DeviceIDs: []string{"GPU-0::1"}},
+			},
+			annotations: fakePodAnnotationGetter{
+				"default/exclusive": {spec.ExclusiveAnnotation: "yes"},
+			},
+			expectedWithheld: map[string]bool{},
+		},
+		{
+			description: "pods that cannot be found are not exclusive",
+			pods: fakePodDevicesLister{
+				{Namespace: "default", Name: "missing", DeviceIDs: []string{"GPU-0::1"}},
+			},
+			annotations:      fakePodAnnotationGetter{},
+			expectedWithheld: map[string]bool{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			events := &eventRecorder{}
+			tracker := newExclusiveTracker("nvidia.com/gpu", devices, tc.pods, tc.annotations, events)
+
+			tracker.sync(context.Background())
+			tracker.sync(context.Background())
+
+			require.Equal(t, tc.expectedWithheld, tracker.Withheld())
+			require.Len(t, tracker.conflicts, tc.expectedConflicts)
+		})
+	}
+}
+
+func TestExclusiveTrackerReleasesReplicas(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+	}
+	pods := fakePodDevicesLister{
+		{Namespace: "default", Name: "exclusive", DeviceIDs: []string{"GPU-0::1"}},
+	}
+	annotations := fakePodAnnotationGetter{
+		"default/exclusive": {spec.ExclusiveAnnotation: "true"},
+	}
+
+	tracker := newExclusiveTracker("nvidia.com/gpu", devices, pods, annotations, &eventRecorder{})
+	tracker.sync(context.Background())
+	require.Equal(t, map[string]bool{"GPU-0::0": true}, tracker.Withheld())
+	<-tracker.Updates()
+
+	tracker.lister = fakePodDevicesLister{}
+	tracker.sync(context.Background())
+	require.Empty(t, tracker.Withheld())
+	require.Empty(t, tracker.exclusive)
+	<-tracker.Updates()
+}
+
+func TestExclusiveTrackerAdmit(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+		"GPU-1::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-1::0"}},
+	}
+	pods := fakePodDevicesLister{
+		{Namespace: "default", Name: "exclusive", DeviceIDs: []string{"GPU-0::1"}},
+	}
+	annotations := fakePodAnnotationGetter{
+		"default/exclusive": {spec.ExclusiveAnnotation: "true"},
+	}
+
+	tracker := newExclusiveTracker("nvidia.com/gpu", devices, pods, annotations, &eventRecorder{})
+	require.NoError(t, tracker.Admit([]string{"GPU-0::0"}))
+
+	tracker.sync(context.Background())
+	require.Error(t, tracker.Admit([]string{"GPU-0::0"}))
+	require.Error(t, tracker.Admit([]string{"GPU-1::0", "GPU-0::0"}))
+	require.NoError(t, tracker.Admit([]string{"GPU-1::0"}))
+}
+
+func TestExclusiveTrackerConcurrentSync(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+	}
+	pods := fakePodDevicesLister{
+		{Namespace: "default", Name: "exclusive", DeviceIDs: []string{"GPU-0::1"}},
+	}
+	annotations := fakePodAnnotationGetter{
+		"default/exclusive": {spec.ExclusiveAnnotation: "true"},
+	}
+
+	tracker := newExclusiveTracker("nvidia.com/gpu", devices, pods, annotations, &eventRecorder{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.sync(context.Background())
+			_ = tracker.Admit([]string{"GPU-0::0"})
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, map[string]bool{"GPU-0::0": true}, tracker.Withheld())
+}
+
+func TestExclusiveTrackerNil(t *testing.T) {
+	var tracker *exclusiveTracker
+	require.Nil(t, tracker.Withheld())
+	require.NoError(t, tracker.Admit([]string{"GPU-0::0"}))
+	require.Nil(t, tracker.Updates())
+	tracker.run(make(chan interface{}))
+}
@@ -0,0 +1,55 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+)
+
+// Limiter limits the number of concurrent operations.
+// A nil Limiter imposes no limit.
+type Limiter chan struct{}
+
+// NewLimiter creates a limiter that allows up to max concurrent operations.
+// If max is less than or equal to 0, a nil (unlimited) limiter is returned.
+func NewLimiter(max int) Limiter {
+	if max <= 0 {
+		return nil
+	}
+	return make(Limiter, max)
+}
+
+// Acquire blocks until a slot is available or the context is done.
+func (l Limiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release releases a slot previously obtained through Acquire.
+func (l Limiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l
+}
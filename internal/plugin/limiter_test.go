@@ -0,0 +1,67 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("nil limiter never blocks", func(t *testing.T) {
+		l := NewLimiter(0)
+		require.Nil(t, l)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, l.Acquire(context.Background()))
+		}
+		l.Release()
+	})
+
+	t.Run("limiter blocks when exhausted", func(t *testing.T) {
+		l := NewLimiter(1)
+		require.NoError(t, l.Acquire(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, l.Acquire(ctx), context.DeadlineExceeded)
+
+		l.Release()
+		require.NoError(t, l.Acquire(context.Background()))
+	})
+}
+
+func TestAcquireAllocateSlot(t *testing.T) {
+	plugin := NvidiaDevicePlugin{
+		allocateLimiter:       NewLimiter(1),
+		globalAllocateLimiter: NewLimiter(1),
+	}
+
+	release, err := plugin.acquireAllocateSlot(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = plugin.acquireAllocateSlot(ctx)
+	require.Error(t, err)
+
+	release()
+	require.Len(t, plugin.allocateLimiter, 0)
+	require.Len(t, plugin.globalAllocateLimiter, 0)
+}
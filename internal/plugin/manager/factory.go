@@ -26,18 +26,33 @@ import (
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/featuregates"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
 type manager struct {
 	infolib   info.Interface
 	nvmllib   nvml.Interface
 	devicelib device.Interface
+	nvcaps    nvcaps.Interface
 
 	migStrategy     string
 	failOnInitError bool
 
 	cdiHandler cdi.Interface
 	config     *spec.Config
+	drainer    plugin.Drainer
+
+	healthRecorder  plugin.HealthRecorder
+	metricsRecorder plugin.MetricsRecorder
+	healthEvents    rm.HealthEventReporter
+	validations     *rm.ValidationCache
+	podResources    plugin.PodResourcesLister
+	podAnnotations  plugin.PodAnnotationGetter
+	retainDevices   bool
+	featureGates    *featuregates.Gates
 }
 
 // New creates a new plugin manager with the supplied options.
@@ -122,7 +137,9 @@ func (m *manager) resolveMode() (string, error) {
 
 	// The NVIDIA container stack does not yet support the use of integrated AND discrete GPUs on the same node.
 	if isTegra {
-		if hasNVML {
+		// In csv mode, the integrated GPU is used even if NVML is available.
+		// This is the case for IGX systems.
+		if hasNVML && m.config.Flags.Plugin.GetContainerRuntimeMode() != spec.ContainerRuntimeModeCSV {
 			klog.Warning("Disabling Tegra-based resources on NVML system")
 			return "nvml", nil
 		}
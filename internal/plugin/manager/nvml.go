@@ -19,6 +19,7 @@ package manager
 import (
 	"fmt"
 
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
 	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
@@ -27,14 +28,41 @@ type nvmlmanager manager
 
 // GetPlugins returns the plugins associated with the NVML resources available on the node
 func (m *nvmlmanager) GetPlugins() ([]plugin.Interface, error) {
-	rms, err := rm.NewNVMLResourceManagers(m.infolib, m.nvmllib, m.devicelib, m.config)
+	nvcapslib := m.nvcaps
+	if nvcapslib == nil {
+		nvcapslib = nvcaps.New(m.nvmllib)
+	}
+	opts := []rm.NVMLResourceManagerOption{
+		rm.WithNVCaps(nvcapslib),
+	}
+	if m.healthEvents != nil {
+		opts = append(opts, rm.WithHealthEventReporter(m.healthEvents))
+	}
+	if m.validations != nil {
+		opts = append(opts, rm.WithValidationCache(m.validations))
+	}
+	rms, err := rm.NewNVMLResourceManagers(m.infolib, m.nvmllib, m.devicelib, m.config, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct NVML resource managers: %v", err)
 	}
 
+	globalAllocateLimiter := plugin.NewLimiter(m.config.Allocation.GetMaxConcurrent())
+	dual := plugin.NewDualAdvertiser(&m.config.Sharing)
+
 	var plugins []plugin.Interface
 	for _, r := range rms {
-		plugin, err := plugin.NewNvidiaDevicePlugin(m.config, r, m.cdiHandler)
+		plugin, err := plugin.NewNvidiaDevicePlugin(m.config, r, m.cdiHandler,
+			plugin.WithGlobalAllocateLimiter(globalAllocateLimiter),
+			plugin.WithDualAdvertiser(dual),
+			plugin.WithDrainer(m.drainer),
+			plugin.WithHealthRecorder(m.healthRecorder),
+			plugin.WithMetricsRecorder(m.metricsRecorder),
+			plugin.WithNVCaps(nvcapslib),
+			plugin.WithPodResources(m.podResources),
+			plugin.WithPodAnnotations(m.podAnnotations),
+			plugin.WithRetainedDevices(m.retainDevices),
+			plugin.WithFeatureGates(m.featureGates),
+		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create plugin: %w", err)
 		}
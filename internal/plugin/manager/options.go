@@ -22,6 +22,10 @@ import (
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/featuregates"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
 // Option is a function that configures a manager
@@ -41,6 +45,13 @@ func WithNVML(nvmllib nvml.Interface) Option {
 	}
 }
 
+// WithNVCaps sets the NVML facade used for health checks by the manager.
+func WithNVCaps(nvcapslib nvcaps.Interface) Option {
+	return func(m *manager) {
+		m.nvcaps = nvcapslib
+	}
+}
+
 // WithInfoLib sets the info lib for the manager.
 func WithInfoLib(infolib info.Interface) Option {
 	return func(m *manager) {
@@ -68,3 +79,70 @@ func WithConfig(config *spec.Config) Option {
 		m.config = config
 	}
 }
+
+// WithDrainer sets the drainer that is passed to the plugins created by the manager.
+func WithDrainer(drainer plugin.Drainer) Option {
+	return func(m *manager) {
+		m.drainer = drainer
+	}
+}
+
+// WithHealthRecorder sets the health recorder that is passed to the plugins created by the manager.
+func WithHealthRecorder(recorder plugin.HealthRecorder) Option {
+	return func(m *manager) {
+		m.healthRecorder = recorder
+	}
+}
+
+// WithMetricsRecorder sets the metrics recorder that is passed to the plugins created by the manager.
+func WithMetricsRecorder(recorder plugin.MetricsRecorder) Option {
+	return func(m *manager) {
+		m.metricsRecorder = recorder
+	}
+}
+
+// WithFeatureGates sets the feature gates that are passed to the plugins created by the manager.
+func WithFeatureGates(gates *featuregates.Gates) Option {
+	return func(m *manager) {
+		m.featureGates = gates
+	}
+}
+
+// WithPodResources sets the PodResources lister that is passed to the plugins created by the manager.
+func WithPodResources(lister plugin.PodResourcesLister) Option {
+	return func(m *manager) {
+		m.podResources = lister
+	}
+}
+
+// WithRetainedDevices sets whether the plugins created by the manager keep
+// advertising the devices that are no longer configured but are still
+// allocated to running pods.
+func WithRetainedDevices(retain bool) Option {
+	return func(m *manager) {
+		m.retainDevices = retain
+	}
+}
+
+// WithPodAnnotations sets the getter for pod annotations that is passed to the plugins created by the manager.
+func WithPodAnnotations(getter plugin.PodAnnotationGetter) Option {
+	return func(m *manager) {
+		m.podAnnotations = getter
+	}
+}
+
+// WithValidationCache sets the cache for the results of the startup validation
+// of the devices, so that the devices are not validated again whenever the
+// plugins are restarted.
+func WithValidationCache(cache *rm.ValidationCache) Option {
+	return func(m *manager) {
+		m.validations = cache
+	}
+}
+
+// WithHealthEventReporter sets the reporter that receives the health events detected by the resource managers.
+func WithHealthEventReporter(reporter rm.HealthEventReporter) Option {
+	return func(m *manager) {
+		m.healthEvents = reporter
+	}
+}
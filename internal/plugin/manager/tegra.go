@@ -32,9 +32,18 @@ func (m *tegramanager) GetPlugins() ([]plugin.Interface, error) {
 		return nil, fmt.Errorf("failed to construct Tegra resource managers: %v", err)
 	}
 
+	globalAllocateLimiter := plugin.NewLimiter(m.config.Allocation.GetMaxConcurrent())
+	dual := plugin.NewDualAdvertiser(&m.config.Sharing)
+
 	var plugins []plugin.Interface
 	for _, r := range rms {
-		plugin, err := plugin.NewNvidiaDevicePlugin(m.config, r, m.cdiHandler)
+		plugin, err := plugin.NewNvidiaDevicePlugin(m.config, r, m.cdiHandler,
+			plugin.WithGlobalAllocateLimiter(globalAllocateLimiter),
+			plugin.WithDualAdvertiser(dual),
+			plugin.WithDrainer(m.drainer),
+			plugin.WithHealthRecorder(m.healthRecorder),
+			plugin.WithMetricsRecorder(m.metricsRecorder),
+		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create plugin: %w", err)
 		}
@@ -43,7 +52,8 @@ func (m *tegramanager) GetPlugins() ([]plugin.Interface, error) {
 	return plugins, nil
 }
 
-// CreateCDISpecFile creates the spec is a no-op for the tegra plugin
+// CreateCDISpecFile forwards the request to the CDI handler.
+// A CDI spec is only generated in the csv mode of the NVIDIA Container Runtime.
 func (m *tegramanager) CreateCDISpecFile() error {
-	return nil
+	return m.cdiHandler.CreateSpecFile()
 }
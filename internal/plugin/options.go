@@ -0,0 +1,103 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"github.com/NVIDIA/k8s-device-plugin/internal/featuregates"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+)
+
+// Option defines a functional option for configuring a device plugin.
+type Option func(*NvidiaDevicePlugin)
+
+// WithGlobalAllocateLimiter sets the limiter that is shared by all plugins to
+// bound the number of concurrent Allocate calls across all resources.
+func WithGlobalAllocateLimiter(limiter Limiter) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.globalAllocateLimiter = limiter
+	}
+}
+
+// WithDualAdvertiser sets the DualAdvertiser that coordinates the resources
+// whose GPUs are advertised both as whole devices and as shared replicas.
+func WithDualAdvertiser(dual *DualAdvertiser) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.dual = dual
+	}
+}
+
+// WithDrainer sets the drainer that reports the devices that are advertised
+// as unavailable to the kubelet while they are drained.
+func WithDrainer(drainer Drainer) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.drainer = drainer
+	}
+}
+
+// WithNVCaps sets the NVML interface used to change the clocks of the devices.
+func WithNVCaps(nvcapslib nvcaps.Interface) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.nvcaps = nvcapslib
+	}
+}
+
+// WithPodResources sets the lister used to detect when allocated devices are released.
+func WithPodResources(lister PodResourcesLister) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.podResources = lister
+	}
+}
+
+// WithRetainedDevices sets whether the plugin keeps advertising the devices
+// that are no longer configured but are still allocated to running pods. This
+// is only required if the config is reloaded while pods are running.
+func WithRetainedDevices(retain bool) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.retainDevices = retain
+	}
+}
+
+// WithPodAnnotations sets the getter used to read the annotations of the pods
+// that are allocated devices.
+func WithPodAnnotations(getter PodAnnotationGetter) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.podAnnotations = getter
+	}
+}
+
+// WithHealthRecorder sets the recorder that tracks the health of the devices
+// advertised to the kubelet.
+func WithHealthRecorder(recorder HealthRecorder) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.healthRecorder = recorder
+	}
+}
+
+// WithMetricsRecorder sets the recorder that tracks the Allocate calls, the
+// registrations with the kubelet and the advertised replicas.
+func WithMetricsRecorder(recorder MetricsRecorder) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.metricsRecorder = recorder
+	}
+}
+
+// WithFeatureGates sets the feature gates that control the experimental features of the plugin.
+func WithFeatureGates(gates *featuregates.Gates) Option {
+	return func(p *NvidiaDevicePlugin) {
+		p.featureGates = gates
+	}
+}
@@ -0,0 +1,163 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// retainedDevices keeps advertising the devices that are allocated to running
+// pods but are no longer advertised by the resource manager, e.g. because the
+// config was reloaded with fewer replicas. Without them, the kubelet would
+// consider the allocations of these pods orphaned. The retained devices are
+// advertised as unhealthy so that they are not allocated to new pods and are
+// forgotten once they are released.
+type retainedDevices struct {
+	sync.Mutex
+	resource spec.ResourceName
+	devices  rm.Devices
+	retained map[string]bool
+}
+
+func newRetainedDevices(resource spec.ResourceName, devices rm.Devices) *retainedDevices {
+	return &retainedDevices{
+		resource: resource,
+		devices:  devices,
+	}
+}
+
+// Devices returns the retained devices as unhealthy devices, ordered by ID.
+func (r *retainedDevices) Devices() []*pluginapi.Device {
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	var ids []string
+	for id := range r.retained {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var devices []*pluginapi.Device
+	for _, id := range ids {
+		devices = append(devices, &pluginapi.Device{ID: id, Health: pluginapi.Unhealthy})
+	}
+	return devices
+}
+
+// observe retains the allocated devices that are not advertised by the
+// resource manager. It returns whether the retained devices changed.
+func (r *retainedDevices) observe(a allocations) bool {
+	retained := make(map[string]bool)
+	for id := range a.deviceIDs() {
+		if _, exists := r.devices[id]; !exists {
+			retained[id] = true
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if maps.Equal(retained, r.retained) {
+		return false
+	}
+	if len(retained) > 0 {
+		klog.Infof("Retaining %d %v devices that are no longer advertised until they are released by running pods", len(retained), r.resource)
+	} else {
+		klog.Infof("All retained %v devices were released", r.resource)
+	}
+	r.retained = retained
+	return true
+}
+
+// Retain creates a plugin that keeps advertising the devices of the resource
+// of the specified plugin that are still allocated to running pods, e.g.
+// because a reloaded config renamed or removed the resource. The devices are
+// advertised as unhealthy and cannot be allocated. nil is returned if the
+// plugin does not retain devices or none of its devices are allocated.
+func Retain(p Interface) Interface {
+	old, ok := p.(*NvidiaDevicePlugin)
+	if !ok || old.retained == nil {
+		return nil
+	}
+	resource := old.rm.Resource()
+	plugin := &NvidiaDevicePlugin{
+		rm:                   retainingResourceManager(resource),
+		config:               old.config,
+		deviceListEnvvar:     old.deviceListEnvvar,
+		deviceListStrategies: old.deviceListStrategies,
+		socket:               old.socket,
+		terminate:            make(chan struct{}, 1),
+		refresh:              make(chan struct{}, 1),
+		healthUpdated:        make(chan struct{}, 1),
+		podResources:         old.podResources,
+		allocations:          newAllocationWatcher(resource, old.allocations.lister),
+		retained:             newRetainedDevices(resource, nil),
+		snapshots:            &snapshotRecorder{},
+		events:               &eventRecorder{},
+	}
+	plugin.allocations.watch(plugin.retained)
+
+	ctx, cancel := context.WithTimeout(context.Background(), allocationSyncInterval)
+	defer cancel()
+	plugin.allocations.sync(ctx)
+	if len(plugin.retained.Devices()) == 0 {
+		return nil
+	}
+	return plugin
+}
+
+// retainingResourceManager is the resource manager of a plugin created by
+// Retain. It has no devices and rejects all requests.
+type retainingResourceManager spec.ResourceName
+
+func (r retainingResourceManager) Resource() spec.ResourceName {
+	return spec.ResourceName(r)
+}
+
+func (r retainingResourceManager) Devices() rm.Devices {
+	return nil
+}
+
+func (r retainingResourceManager) GetDevicePaths([]string) []string {
+	return nil
+}
+
+func (r retainingResourceManager) GetPreferredAllocation(available, required []string, size int) ([]string, error) {
+	return nil, fmt.Errorf("resource %v is no longer configured", r)
+}
+
+func (r retainingResourceManager) GetTopology([]string) (*rm.Topology, error) {
+	return nil, fmt.Errorf("resource %v is no longer configured", r)
+}
+
+func (r retainingResourceManager) CheckHealth(<-chan interface{}, chan<- *rm.Device) error {
+	return nil
+}
+
+func (r retainingResourceManager) ValidateRequest(rm.AnnotatedIDs) error {
+	return fmt.Errorf("resource %v is no longer configured", r)
+}
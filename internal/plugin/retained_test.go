@@ -0,0 +1,88 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestRetainedDevices(t *testing.T) {
+	var nilRetained *retainedDevices
+	require.Empty(t, nilRetained.Devices())
+
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+	}
+	// The config was reloaded with fewer replicas, so the replicas GPU-0::2
+	// and GPU-0::3 that are allocated to running pods are no longer advertised.
+	allocated := allocations{{"GPU-0::0", "GPU-0::3"}, {"GPU-0::2"}}
+	retained := newRetainedDevices("nvidia.com/gpu", devices)
+	require.True(t, retained.observe(allocated))
+
+	require.Equal(t, []*pluginapi.Device{
+		{ID: "GPU-0::2", Health: pluginapi.Unhealthy},
+		{ID: "GPU-0::3", Health: pluginapi.Unhealthy},
+	}, retained.Devices())
+
+	// The container of pod b is removed, which releases GPU-0::2.
+	require.True(t, retained.observe(allocated[:1]))
+	require.Equal(t, []*pluginapi.Device{
+		{ID: "GPU-0::3", Health: pluginapi.Unhealthy},
+	}, retained.Devices())
+
+	// Observing the same allocations does not change the devices.
+	require.False(t, retained.observe(allocated[:1]))
+
+	require.True(t, retained.observe(allocations{}))
+	require.Empty(t, retained.Devices())
+}
+
+func TestRetain(t *testing.T) {
+	lister := fakeContainerDevicesLister{
+		{Namespace: "default", Pod: "a", DeviceIDs: []string{"GPU-0", "GPU-1"}},
+	}
+	old := &NvidiaDevicePlugin{
+		rm:          testHealthyResourceManager{devices: rm.Devices{}},
+		allocations: newAllocationWatcher("nvidia.com/gpu", lister),
+		retained:    newRetainedDevices("nvidia.com/gpu", nil),
+	}
+
+	// The resource was removed from the config, so all of its allocated
+	// devices are advertised as unhealthy and cannot be allocated.
+	retaining, ok := Retain(old).(*NvidiaDevicePlugin)
+	require.True(t, ok)
+	require.Empty(t, retaining.Devices())
+	require.Equal(t, []*pluginapi.Device{
+		{ID: "GPU-0", Health: pluginapi.Unhealthy},
+		{ID: "GPU-1", Health: pluginapi.Unhealthy},
+	}, retaining.apiDevices())
+	require.Error(t, retaining.rm.ValidateRequest(rm.AnnotatedIDs{"GPU-0"}))
+
+	// No plugin is needed if none of the devices are allocated.
+	old.allocations = newAllocationWatcher("nvidia.com/gpu", fakeContainerDevicesLister(nil))
+	require.Nil(t, Retain(old))
+
+	// Devices are only retained if the config can be reloaded.
+	old.retained = nil
+	require.Nil(t, Retain(old))
+}
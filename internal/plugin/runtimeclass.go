@@ -0,0 +1,109 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// runtimeClassCheck checks that the runtime class of the pod of an Allocate
+// request supports the device list strategies of the plugin. For example, CDI
+// device names are ignored by a runtime without CDI support, and the
+// containers of the pod would start without GPUs.
+//
+// The pod is identified in the same way as for a threadPercentageRequest. Pods
+// that cannot be identified or whose runtime class is not configured are not
+// checked. If several pods may be allocating the resource, the request is
+// only rejected if none of them can use the devices, since a compatible pod
+// must not fail because of another misconfigured pod of the node.
+type runtimeClassCheck struct {
+	resource   spec.ResourceName
+	classes    map[string]spec.RuntimeClass
+	strategies spec.DeviceListStrategies
+}
+
+func newRuntimeClassCheck(resource spec.ResourceName, classes []spec.RuntimeClass, strategies spec.DeviceListStrategies) *runtimeClassCheck {
+	c := &runtimeClassCheck{
+		resource:   resource,
+		classes:    make(map[string]spec.RuntimeClass),
+		strategies: strategies,
+	}
+	for _, class := range classes {
+		c.classes[class.Name] = class
+	}
+	return c
+}
+
+// check returns an error if every pod that may be allocating the resource
+// uses a runtime class that supports none of the device list strategies.
+func (c *runtimeClassCheck) check(allocating *allocatingPodList) error {
+	if c == nil {
+		return nil
+	}
+	pods, err := allocating.get()
+	if err != nil {
+		klog.Warningf("Failed to identify the pods allocating %v: %v", c.resource, err)
+		return nil
+	}
+
+	var incompatible []string
+	for _, pod := range pods {
+		class, exists := c.classes[pod.RuntimeClassName]
+		if !exists || class.Supports(c.strategies) {
+			continue
+		}
+		incompatible = append(incompatible, fmt.Sprintf("%v of pod %v/%v supports the %v device list strategies",
+			runtimeClassDescription(class.Name), pod.Namespace, pod.Name, strings.Join(class.DeviceListStrategies, ",")))
+	}
+	if len(incompatible) == 0 {
+		return nil
+	}
+	if len(incompatible) < len(pods) {
+		klog.Warningf("Not rejecting the allocation of %v, since %d of %d pods allocating the resource are compatible: %v",
+			c.resource, len(pods)-len(incompatible), len(pods), strings.Join(incompatible, "; "))
+		return nil
+	}
+	return fmt.Errorf("%v, but %v devices are passed with %v", strings.Join(incompatible, "; "), c.resource, strings.Join(c.enabledStrategies(), ","))
+}
+
+// runtimeClassDescription describes a runtime class in messages. An empty name
+// refers to the default runtime of the node.
+func runtimeClassDescription(name string) string {
+	if name == "" {
+		return "the default runtime"
+	}
+	return fmt.Sprintf("runtime class %q", name)
+}
+
+// enabledStrategies returns the enabled device list strategies in a stable
+// order.
+func (c *runtimeClassCheck) enabledStrategies() []string {
+	var enabled []string
+	for s, ok := range c.strategies {
+		if ok {
+			enabled = append(enabled, s)
+		}
+	}
+	sort.Strings(enabled)
+	return enabled
+}
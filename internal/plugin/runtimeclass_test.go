@@ -0,0 +1,121 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+func TestRuntimeClassCheck(t *testing.T) {
+	require.NoError(t, (*runtimeClassCheck)(nil).check(nil))
+
+	pod := func(name string, runtimeClass string) podresources.PendingPod {
+		return podresources.PendingPod{
+			Namespace:        "default",
+			Name:             name,
+			RuntimeClassName: runtimeClass,
+			Containers:       1,
+		}
+	}
+	classes := []spec.RuntimeClass{
+		{Name: "nvidia", DeviceListStrategies: []string{spec.DeviceListStrategyEnvvar, spec.DeviceListStrategyVolumeMounts}},
+		{Name: "nvidia-cdi", DeviceListStrategies: []string{spec.DeviceListStrategyCDIAnnotations, spec.DeviceListStrategyCDICRI}},
+		{Name: "", DeviceListStrategies: []string{spec.DeviceListStrategyEnvvar}},
+	}
+	allocated := fakeContainerDevicesLister{
+		{Namespace: "default", Pod: "started", Container: "main", DeviceIDs: []string{"GPU-0"}},
+	}
+
+	testCases := []struct {
+		description   string
+		strategies    []string
+		pending       fakePendingPodLister
+		expectedError string
+	}{
+		{
+			description: "no pending pods",
+			strategies:  []string{spec.DeviceListStrategyCDIAnnotations},
+		},
+		{
+			description: "runtime class supports CDI",
+			strategies:  []string{spec.DeviceListStrategyCDIAnnotations},
+			pending:     fakePendingPodLister{pod("cdi", "nvidia-cdi")},
+		},
+		{
+			description:   "runtime class without CDI support",
+			strategies:    []string{spec.DeviceListStrategyCDIAnnotations, spec.DeviceListStrategyCDICRI},
+			pending:       fakePendingPodLister{pod("legacy", "nvidia")},
+			expectedError: `runtime class "nvidia" of pod default/legacy supports the envvar,volume-mounts device list strategies, but nvidia.com/gpu devices are passed with cdi-annotations,cdi-cri`,
+		},
+		{
+			description: "any supported strategy is sufficient",
+			strategies:  []string{spec.DeviceListStrategyEnvvar, spec.DeviceListStrategyCDIAnnotations},
+			pending:     fakePendingPodLister{pod("legacy", "nvidia")},
+		},
+		{
+			description: "unknown runtime classes are not checked",
+			strategies:  []string{spec.DeviceListStrategyCDIAnnotations},
+			pending:     fakePendingPodLister{pod("kata", "kata")},
+		},
+		{
+			description:   "default runtime without CDI support",
+			strategies:    []string{spec.DeviceListStrategyCDIAnnotations},
+			pending:       fakePendingPodLister{pod("default", "")},
+			expectedError: `the default runtime of pod default/default supports the envvar device list strategies, but nvidia.com/gpu devices are passed with cdi-annotations`,
+		},
+		{
+			description: "a compatible pod is not rejected because of another pod",
+			strategies:  []string{spec.DeviceListStrategyCDIAnnotations},
+			pending:     fakePendingPodLister{pod("legacy", "nvidia"), pod("cdi", "nvidia-cdi")},
+		},
+		{
+			description: "an unchecked pod is not rejected because of another pod",
+			strategies:  []string{spec.DeviceListStrategyCDIAnnotations},
+			pending:     fakePendingPodLister{pod("legacy", "nvidia"), pod("kata", "kata")},
+		},
+		{
+			description:   "all pods are incompatible",
+			strategies:    []string{spec.DeviceListStrategyCDIAnnotations},
+			pending:       fakePendingPodLister{pod("legacy", "nvidia"), pod("default", "")},
+			expectedError: `runtime class "nvidia" of pod default/legacy supports the envvar,volume-mounts device list strategies; the default runtime of pod default/default supports the envvar device list strategies, but nvidia.com/gpu devices are passed with cdi-annotations`,
+		},
+		{
+			description: "allocated pods are not checked",
+			strategies:  []string{spec.DeviceListStrategyCDIAnnotations},
+			pending:     fakePendingPodLister{pod("started", "nvidia")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			strategies, err := spec.NewDeviceListStrategies(tc.strategies)
+			require.NoError(t, err)
+			c := newRuntimeClassCheck("nvidia.com/gpu", classes, strategies)
+			err = c.check(newAllocatingPodList("nvidia.com/gpu", tc.pending, allocated))
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// ScrubMemoryCommand is the command of the plugin executable that clears the
+// memory of a device through CUDA. It is run if no scrub command is
+// configured.
+const ScrubMemoryCommand = "scrub-memory"
+
+// memoryScrubber scrubs the memory of a device before it is handed to a new container.
+type memoryScrubber interface {
+	Scrub(ctx context.Context, uuid string) error
+}
+
+// commandScrubber scrubs device memory by running a command with the UUID of
+// the device appended as the last argument.
+type commandScrubber []string
+
+// Scrub runs the scrub command for the specified device.
+func (c commandScrubber) Scrub(ctx context.Context, uuid string) error {
+	if len(c) == 0 {
+		return fmt.Errorf("no scrub command specified")
+	}
+	args := append(append([]string{}, c[1:]...), uuid)
+	output, err := exec.CommandContext(ctx, c[0], args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v failed: %w: %s", strings.Join(c, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// defaultScrubCommand returns the command that runs the ScrubMemoryCommand of
+// the plugin executable.
+func defaultScrubCommand() ([]string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the plugin executable: %w", err)
+	}
+	return []string{executable, ScrubMemoryCommand}, nil
+}
+
+// deviceTenants tracks the tenant that each device was last handed to, so
+// that its memory is only scrubbed when it transitions between tenants. The
+// tenant of a device is the namespace of the pod that it is allocated to, as
+// reported by the PodResources API. Devices whose tenant cannot be determined
+// are always scrubbed.
+type deviceTenants struct {
+	resource spec.ResourceName
+	lister   ContainerDevicesLister
+
+	mutex sync.Mutex
+	last  map[string]string
+}
+
+func newDeviceTenants(resource spec.ResourceName, lister ContainerDevicesLister) *deviceTenants {
+	return &deviceTenants{
+		resource: resource,
+		lister:   lister,
+		last:     make(map[string]string),
+	}
+}
+
+// current returns the tenants of the allocated devices by device ID.
+func (t *deviceTenants) current(ctx context.Context) map[string]string {
+	if t == nil {
+		return nil
+	}
+	containers, err := t.lister.AllocatedContainerDevices(ctx, string(t.resource))
+	if err != nil {
+		klog.Warningf("Failed to get the tenants of %v devices: %v", t.resource, err)
+		return nil
+	}
+	tenants := make(map[string]string)
+	for _, c := range containers {
+		for _, id := range c.DeviceIDs {
+			tenants[id] = c.Namespace
+		}
+	}
+	return tenants
+}
+
+// changed checks whether the device is handed to a tenant other than the one
+// it was last handed to.
+func (t *deviceTenants) changed(id string, tenant string) bool {
+	if t == nil || tenant == "" {
+		return true
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.last[id] != tenant
+}
+
+// set records the tenant that the device is handed to. An empty tenant
+// forgets the last tenant of the device.
+func (t *deviceTenants) set(id string, tenant string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if tenant == "" {
+		delete(t.last, id)
+		return
+	}
+	t.last[id] = tenant
+}
@@ -0,0 +1,141 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+type testScrubber struct {
+	scrubbed []string
+	err      error
+}
+
+func (s *testScrubber) Scrub(_ context.Context, uuid string) error {
+	s.scrubbed = append(s.scrubbed, uuid)
+	return s.err
+}
+
+type testResourceManager struct {
+	rm.ResourceManager
+}
+
+func (testResourceManager) Resource() spec.ResourceName {
+	return "nvidia.com/gpu"
+}
+
+func TestPreStartContainerScrubsMemory(t *testing.T) {
+	testCases := []struct {
+		description      string
+		scrubber         *testScrubber
+		deviceIDs        []string
+		expectedScrubbed []string
+		expectedError    bool
+	}{
+		{
+			description: "scrubbing disabled",
+			deviceIDs:   []string{"GPU-0"},
+		},
+		{
+			description:      "all devices are scrubbed",
+			scrubber:         &testScrubber{},
+			deviceIDs:        []string{"GPU-0", "GPU-1"},
+			expectedScrubbed: []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description:      "scrub failure is returned",
+			scrubber:         &testScrubber{err: errors.New("reset failed")},
+			deviceIDs:        []string{"GPU-0", "GPU-1"},
+			expectedScrubbed: []string{"GPU-0"},
+			expectedError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			plugin := NvidiaDevicePlugin{
+				rm: testResourceManager{},
+			}
+			if tc.scrubber != nil {
+				plugin.scrubber = tc.scrubber
+			}
+
+			options, err := plugin.GetDevicePluginOptions(context.Background(), &pluginapi.Empty{})
+			require.NoError(t, err)
+			require.Equal(t, tc.scrubber != nil, options.PreStartRequired)
+
+			_, err = plugin.PreStartContainer(context.Background(), &pluginapi.PreStartContainerRequest{DevicesIDs: tc.deviceIDs})
+			if tc.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			if tc.scrubber != nil {
+				require.EqualValues(t, tc.expectedScrubbed, tc.scrubber.scrubbed)
+			}
+		})
+	}
+}
+
+func TestCommandScrubber(t *testing.T) {
+	require.NoError(t, commandScrubber{"true"}.Scrub(context.Background(), "GPU-0"))
+	require.Error(t, commandScrubber{"false"}.Scrub(context.Background(), "GPU-0"))
+	require.Error(t, commandScrubber{}.Scrub(context.Background(), "GPU-0"))
+}
+
+func TestPreStartContainerScrubsOnTenantChange(t *testing.T) {
+	scrubber := &testScrubber{}
+	allocated := fakeContainerDevicesLister{
+		{Namespace: "team-a", Pod: "job", Container: "main", DeviceIDs: []string{"GPU-0"}},
+	}
+	plugin := NvidiaDevicePlugin{
+		rm:       testResourceManager{},
+		scrubber: scrubber,
+	}
+	prestart := func(lister fakeContainerDevicesLister, ids ...string) {
+		plugin.tenants.lister = lister
+		_, err := plugin.PreStartContainer(context.Background(), &pluginapi.PreStartContainerRequest{DevicesIDs: ids})
+		require.NoError(t, err)
+	}
+	plugin.tenants = newDeviceTenants("nvidia.com/gpu", allocated)
+
+	prestart(allocated, "GPU-0")
+	require.Equal(t, []string{"GPU-0"}, scrubber.scrubbed, "first tenant")
+
+	prestart(allocated, "GPU-0")
+	require.Equal(t, []string{"GPU-0"}, scrubber.scrubbed, "restart of the same tenant")
+
+	other := fakeContainerDevicesLister{
+		{Namespace: "team-b", Pod: "job", Container: "main", DeviceIDs: []string{"GPU-0"}},
+	}
+	prestart(other, "GPU-0")
+	require.Equal(t, []string{"GPU-0", "GPU-0"}, scrubber.scrubbed, "tenant changed")
+
+	prestart(fakeContainerDevicesLister{}, "GPU-0")
+	require.Equal(t, []string{"GPU-0", "GPU-0", "GPU-0"}, scrubber.scrubbed, "unknown tenant")
+
+	prestart(other, "GPU-0")
+	require.Len(t, scrubber.scrubbed, 4, "tenant after an unknown tenant")
+}
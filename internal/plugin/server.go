@@ -17,13 +17,18 @@
 package plugin
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
@@ -31,17 +36,24 @@ import (
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
 	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/featuregates"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 
 	"github.com/google/uuid"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 
 	"k8s.io/klog/v2"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
+// mpsFailureCheckInterval is the interval at which the plugin checks whether
+// its MPS daemons were marked as failed.
+var mpsFailureCheckInterval = 10 * time.Second
+
 // Constants for use by the 'volume-mounts' device list strategy
 const (
 	deviceListAsVolumeMountsHostPath          = "/dev/null"
@@ -64,43 +76,179 @@ type NvidiaDevicePlugin struct {
 	health chan *rm.Device
 	stop   chan interface{}
 
-	mpsDaemon   *mps.Daemon
-	mpsHostRoot mps.Root
+	mpsDaemon *mps.Daemon
+	// mpsMigDaemons maps the UUID of each MIG device of the resource to its
+	// dedicated MPS daemon if the resource is shared using per-MIG-device
+	// daemons.
+	mpsMigDaemons map[string]*mps.Daemon
+	mpsHostRoot   mps.Root
+
+	allocateLimiter       Limiter
+	globalAllocateLimiter Limiter
+
+	scrubber         memoryScrubber
+	tenants          *deviceTenants
+	cudaCompat       *cudaCompatDetector
+	cudaCompatPolicy *cudaCompatPolicyRequest
+	topologyFile     bool
+
+	drainer         Drainer
+	healthRecorder  HealthRecorder
+	metricsRecorder MetricsRecorder
+	nvcaps          nvcaps.Interface
+	podResources    PodResourcesLister
+	podAnnotations  PodAnnotationGetter
+	booster         *clockBooster
+	exclusive       *exclusiveTracker
+	allocations     *allocationWatcher
+	clients         *clientLimiter
+	cooldown        *releaseCooldown
+	retained        *retainedDevices
+	retainDevices   bool
+	burst           *burstReplicas
+	dual            *DualAdvertiser
+	threads         *threadPercentageRequest
+	trusted         *trustedWorkloadRequest
+	runtimeClasses  *runtimeClassCheck
+	featureGates    *featuregates.Gates
+
+	snapshots *snapshotRecorder
+	events    *eventRecorder
+
+	reregistering atomic.Bool
+	terminating   atomic.Bool
+	terminate     chan struct{}
+	refresh       chan struct{}
+	// healthUpdated is notified whenever the health of a device changed.
+	healthUpdated chan struct{}
 }
 
 // NewNvidiaDevicePlugin returns an initialized NvidiaDevicePlugin
-func NewNvidiaDevicePlugin(config *spec.Config, resourceManager rm.ResourceManager, cdiHandler cdi.Interface) (*NvidiaDevicePlugin, error) {
+func NewNvidiaDevicePlugin(config *spec.Config, resourceManager rm.ResourceManager, cdiHandler cdi.Interface, opts ...Option) (*NvidiaDevicePlugin, error) {
 	_, name := resourceManager.Resource().Split()
 
 	deviceListStrategies, _ := spec.NewDeviceListStrategies(*config.Flags.Plugin.DeviceListStrategy)
+	// In csv mode, the NVIDIA Container Runtime only considers the device
+	// list envvar and ignores the device list volume mounts.
+	if config.Flags.Plugin.GetContainerRuntimeMode() == spec.ContainerRuntimeModeCSV && deviceListStrategies.Includes(spec.DeviceListStrategyVolumeMounts) {
+		klog.Warningf("The %q device list strategy is not supported in csv mode; using %q instead", spec.DeviceListStrategyVolumeMounts, spec.DeviceListStrategyEnvvar)
+		deviceListStrategies[spec.DeviceListStrategyVolumeMounts] = false
+		deviceListStrategies[spec.DeviceListStrategyEnvvar] = true
+	}
 
 	pluginName := "nvidia-" + name
 	pluginPath := filepath.Join(pluginapi.DevicePluginPath, pluginName)
 
 	var mpsDaemon *mps.Daemon
+	var mpsMigDaemons map[string]*mps.Daemon
 	var mpsHostRoot mps.Root
-	if config.Sharing.SharingStrategy() == spec.SharingStrategyMPS {
+	if config.Sharing.StrategyForResource(resourceManager.Resource()) == spec.SharingStrategyMPS {
+		r := config.Sharing.MPS.ForResource(resourceManager.Resource())
 		// TODO: It might make sense to pull this logic into a resource manager.
+		var hasMigDevices bool
 		for _, device := range resourceManager.Devices() {
 			if device.IsMigDevice() {
-				return nil, errors.New("sharing using MPS is not supported for MIG devices")
+				hasMigDevices = true
+			}
+		}
+		if hasMigDevices && (r == nil || !r.PerMigDevice) {
+			return nil, errors.New("sharing MIG devices using MPS requires perMigDevice")
+		}
+		daemonOpts := []mps.DaemonOption{
+			mps.WithServerMemoryOverhead(r.GetServerMemoryOverheadMB()),
+		}
+		if r != nil {
+			daemonOpts = append(daemonOpts,
+				mps.WithPipeDirectory(r.PipeDirectory),
+				mps.WithClientAffinity(r.ClientAffinity),
+				mps.WithReplicaMemoryLimits(r.ReplicaMemoryLimitsMB),
+				mps.WithMemoryChunk(config.Sharing.MPS.GetMemoryChunkMB()),
+			)
+		}
+		mpsRoot := mps.Root(config.Flags.GetMpsContainerRoot())
+		if hasMigDevices {
+			mpsMigDaemons = make(map[string]*mps.Daemon)
+			for _, d := range mps.NewMigDaemons(resourceManager, mpsRoot, daemonOpts...) {
+				mpsMigDaemons[d.MigDevice()] = d
 			}
+		} else {
+			mpsDaemon = mps.NewDaemon(resourceManager, mpsRoot, daemonOpts...)
 		}
-		mpsDaemon = mps.NewDaemon(resourceManager, mps.ContainerRoot)
 		mpsHostRoot = mps.Root(*config.Flags.CommandLineFlags.MpsRoot)
 	}
 
+	allocationOptions := config.Allocation.ForResource(resourceManager.Resource())
+
+	var scrubber memoryScrubber
+	if allocationOptions.ScrubMemory {
+		for _, device := range resourceManager.Devices() {
+			if device.IsMigDevice() || device.Replicas > 0 {
+				return nil, fmt.Errorf("memory scrubbing is not supported for MIG devices or shared resources: %v", resourceManager.Resource())
+			}
+		}
+		command := config.Allocation.GetScrubCommand()
+		if len(command) == 0 {
+			var err error
+			command, err = defaultScrubCommand()
+			if err != nil {
+				return nil, err
+			}
+		}
+		scrubber = commandScrubber(command)
+	}
+
+	if allocationOptions.BoostClocks {
+		for _, device := range resourceManager.Devices() {
+			if device.IsMigDevice() || device.Replicas > 0 {
+				return nil, fmt.Errorf("clock boosting is not supported for MIG devices or shared resources: %v", resourceManager.Resource())
+			}
+		}
+	}
+
+	var compat *cudaCompatDetector
+	if allocationOptions.CUDACompat && config.Allocation.GetCUDACompatPolicy() == nil {
+		compat = newCUDACompatDetector(resourceManager.Resource(), config.Allocation.GetCUDACompatDir())
+		compat.get()
+	}
+	if allocationOptions.CUDACompat && deviceListStrategies.IsCDIEnabled() {
+		if err := cdiHandler.RemoveStaleCUDACompatSpecFiles(); err != nil {
+			klog.Warningf("Failed to remove stale CUDA compat CDI specs: %v", err)
+		}
+	}
+
+	if allocationOptions.TopologyFile {
+		if !deviceListStrategies.IsCDIEnabled() {
+			return nil, fmt.Errorf("topology files require a CDI device list strategy: %v", resourceManager.Resource())
+		}
+		for _, device := range resourceManager.Devices() {
+			if device.IsMigDevice() {
+				return nil, fmt.Errorf("topology files are not supported for MIG devices: %v", resourceManager.Resource())
+			}
+		}
+	}
+
 	plugin := NvidiaDevicePlugin{
 		rm:                   resourceManager,
 		config:               config,
 		deviceListEnvvar:     "NVIDIA_VISIBLE_DEVICES",
 		deviceListStrategies: deviceListStrategies,
 		socket:               pluginPath + ".sock",
+		terminate:            make(chan struct{}, 1),
+		refresh:              make(chan struct{}, 1),
+		healthUpdated:        make(chan struct{}, 1),
 		cdiHandler:           cdiHandler,
 		cdiAnnotationPrefix:  *config.Flags.Plugin.CDIAnnotationPrefix,
 
-		mpsDaemon:   mpsDaemon,
-		mpsHostRoot: mpsHostRoot,
+		mpsDaemon:     mpsDaemon,
+		mpsMigDaemons: mpsMigDaemons,
+		mpsHostRoot:   mpsHostRoot,
+
+		allocateLimiter: NewLimiter(allocationOptions.MaxConcurrent),
+		scrubber:        scrubber,
+		cudaCompat:      compat,
+		topologyFile:    allocationOptions.TopologyFile,
+		snapshots:       &snapshotRecorder{},
+		events:          &eventRecorder{},
 
 		// These will be reinitialized every
 		// time the plugin server is restarted.
@@ -108,15 +256,153 @@ func NewNvidiaDevicePlugin(config *spec.Config, resourceManager rm.ResourceManag
 		health: nil,
 		stop:   nil,
 	}
+	for _, opt := range opts {
+		opt(&plugin)
+	}
+	if allocationOptions.BoostClocks {
+		if !plugin.featureGates.Enabled(featuregates.ClockBoost) {
+			return nil, fmt.Errorf("clock boosting requires the %v feature gate: %v", featuregates.ClockBoost, resourceManager.Resource())
+		}
+		if plugin.nvcaps == nil || plugin.podResources == nil {
+			return nil, fmt.Errorf("clock boosting requires NVML and the PodResources API: %v", resourceManager.Resource())
+		}
+		plugin.booster = newClockBooster(resourceManager.Resource(), resourceManager.Devices(), plugin.nvcaps, plugin.podResources)
+	}
+	if allocationOptions.AllowExclusive {
+		for _, device := range resourceManager.Devices() {
+			if device.Replicas == 0 {
+				return nil, fmt.Errorf("exclusive access is only supported for shared resources: %v", resourceManager.Resource())
+			}
+		}
+		lister, ok := plugin.podResources.(PodDevicesLister)
+		if !ok || plugin.podAnnotations == nil {
+			return nil, fmt.Errorf("exclusive access requires the PodResources API and access to the API server: %v", resourceManager.Resource())
+		}
+		plugin.exclusive = newExclusiveTracker(resourceManager.Resource(), resourceManager.Devices(), lister, plugin.podAnnotations, plugin.events)
+	}
+	if scrubber != nil {
+		if lister, ok := plugin.podResources.(ContainerDevicesLister); ok {
+			plugin.tenants = newDeviceTenants(resourceManager.Resource(), lister)
+		} else {
+			klog.Warningf("Memory of %v devices is scrubbed for every container without the PodResources API", resourceManager.Resource())
+		}
+	}
+	// The trackers that follow the allocations of the resource share a
+	// single watcher of the PodResources API.
+	if lister, ok := plugin.podResources.(ContainerDevicesLister); ok {
+		plugin.allocations = newAllocationWatcher(resourceManager.Resource(), lister)
+	}
+	if allocationOptions.MaxClientsPerDevice > 0 {
+		for _, device := range resourceManager.Devices() {
+			if device.Replicas == 0 {
+				return nil, fmt.Errorf("maxClientsPerDevice is only supported for shared resources: %v", resourceManager.Resource())
+			}
+		}
+		if plugin.allocations == nil {
+			return nil, fmt.Errorf("maxClientsPerDevice requires the PodResources API: %v", resourceManager.Resource())
+		}
+		plugin.clients = newClientLimiter(resourceManager.Resource(), allocationOptions.MaxClientsPerDevice)
+		plugin.allocations.watch(plugin.clients)
+	}
+	if plugin.allocations != nil && plugin.retainDevices {
+		plugin.retained = newRetainedDevices(resourceManager.Resource(), resourceManager.Devices())
+		plugin.allocations.watch(plugin.retained)
+	}
+	for _, device := range resourceManager.Devices() {
+		if !device.Burst {
+			continue
+		}
+		if plugin.allocations == nil {
+			return nil, fmt.Errorf("burstReplicas requires the PodResources API: %v", resourceManager.Resource())
+		}
+		plugin.burst = newBurstReplicas(resourceManager.Resource(), resourceManager.Devices())
+		plugin.allocations.watch(plugin.burst)
+		break
+	}
+	if plugin.dual.Advertises(resourceManager.Resource()) {
+		if plugin.allocations == nil {
+			return nil, fmt.Errorf("advertiseWhole requires the PodResources API: %v", resourceManager.Resource())
+		}
+		plugin.allocations.watch(plugin.dual.observer(resourceManager.Resource()))
+	}
+	if allocationOptions.ReleaseCooldown > 0 {
+		if plugin.allocations == nil {
+			return nil, fmt.Errorf("releaseCooldown requires the PodResources API: %v", resourceManager.Resource())
+		}
+		plugin.cooldown = newReleaseCooldown(resourceManager.Resource(), time.Duration(allocationOptions.ReleaseCooldown))
+		plugin.allocations.watch(plugin.cooldown)
+	}
+	if r := config.Sharing.MPS.ForResource(resourceManager.Resource()); r != nil && r.MaxThreadPercentage != nil {
+		if !plugin.canListAllocatingPods() {
+			return nil, fmt.Errorf("maxThreadPercentage requires the PodResources API and access to the API server: %v", resourceManager.Resource())
+		}
+		plugin.threads = &threadPercentageRequest{
+			resource: resourceManager.Resource(),
+			max:      *r.MaxThreadPercentage,
+		}
+	}
+	if r := config.Sharing.MPS.ForResource(resourceManager.Resource()); r != nil && r.TrustedWorkloads != nil {
+		if !plugin.canListAllocatingPods() {
+			return nil, fmt.Errorf("trustedWorkloads requires the PodResources API and access to the API server: %v", resourceManager.Resource())
+		}
+		plugin.trusted = &trustedWorkloadRequest{
+			resource: resourceManager.Resource(),
+			trusted:  r.TrustedWorkloads,
+		}
+	}
+	if classes := config.Allocation.GetRuntimeClasses(); len(classes) > 0 {
+		if plugin.canListAllocatingPods() {
+			plugin.runtimeClasses = newRuntimeClassCheck(resourceManager.Resource(), classes, plugin.deviceListStrategies)
+		} else {
+			klog.Warningf("Runtime classes are not checked without the PodResources API and access to the API server: %v", resourceManager.Resource())
+		}
+	}
+	if policy := config.Allocation.GetCUDACompatPolicy(); allocationOptions.CUDACompat && policy != nil {
+		if !plugin.canListAllocatingPods() {
+			return nil, fmt.Errorf("cudaCompatPolicy requires the PodResources API and access to the API server: %v", resourceManager.Resource())
+		}
+		plugin.cudaCompatPolicy = newCUDACompatPolicyRequest(resourceManager.Resource(), policy)
+	}
 	return &plugin, nil
 }
 
+// canListAllocatingPods checks whether the pods that may be allocating the
+// resource in an Allocate request can be identified.
+func (plugin *NvidiaDevicePlugin) canListAllocatingPods() bool {
+	_, ok := plugin.podResources.(ContainerDevicesLister)
+	_, hasPending := plugin.podAnnotations.(PendingPodLister)
+	return ok && hasPending
+}
+
+// allocatingPods returns the lazily listed pods that may be allocating the
+// resource in an Allocate request.
+func (plugin *NvidiaDevicePlugin) allocatingPods() *allocatingPodList {
+	lister, _ := plugin.podResources.(ContainerDevicesLister)
+	pending, _ := plugin.podAnnotations.(PendingPodLister)
+	return newAllocatingPodList(plugin.rm.Resource(), pending, lister)
+}
+
 func (plugin *NvidiaDevicePlugin) initialize() {
-	plugin.server = grpc.NewServer([]grpc.ServerOption{}...)
+	plugin.server = grpc.NewServer(plugin.serverOptions()...)
 	plugin.health = make(chan *rm.Device)
 	plugin.stop = make(chan interface{})
 }
 
+// serverOptions returns the options of the gRPC server of the plugin. With
+// keepalive pings enabled, kubelet connections that are no longer acknowledged
+// are closed, which also ends the ListAndWatch streams served over them.
+func (plugin *NvidiaDevicePlugin) serverOptions() []grpc.ServerOption {
+	flags := plugin.config.Flags.Plugin
+	if flags.GetGRPCKeepaliveTime() == 0 {
+		return nil
+	}
+	params := keepalive.ServerParameters{
+		Time:    flags.GetGRPCKeepaliveTime(),
+		Timeout: flags.GetGRPCKeepaliveTimeout(),
+	}
+	return []grpc.ServerOption{grpc.KeepaliveParams(params)}
+}
+
 func (plugin *NvidiaDevicePlugin) cleanup() {
 	close(plugin.stop)
 	plugin.server = nil
@@ -124,6 +410,11 @@ func (plugin *NvidiaDevicePlugin) cleanup() {
 	plugin.stop = nil
 }
 
+// Resource returns the name of the resource advertised by the plugin.
+func (plugin *NvidiaDevicePlugin) Resource() spec.ResourceName {
+	return plugin.rm.Resource()
+}
+
 // Devices returns the full set of devices associated with the plugin.
 func (plugin *NvidiaDevicePlugin) Devices() rm.Devices {
 	return plugin.rm.Devices()
@@ -152,31 +443,151 @@ func (plugin *NvidiaDevicePlugin) Start() error {
 		return errors.Join(err, plugin.Stop())
 	}
 	klog.Infof("Registered device plugin for '%s' with Kubelet", plugin.rm.Resource())
+	plugin.events.record("Registered with the kubelet")
+	plugin.recordRegistration(false)
+	plugin.recordReplicas()
+	for _, d := range plugin.rm.Devices() {
+		if d.ValidationError != nil {
+			plugin.events.record("Device %s held back: startup validation failed: %v", d.ID, d.ValidationError)
+		}
+	}
 
+	mpsFailed := make(chan *rm.Device)
+	go plugin.watchHealth(plugin.stop, plugin.health, mpsFailed)
+	go plugin.checkMPSDaemons(plugin.stop, mpsFailed)
 	go func() {
-		// TODO: add MPS health check
 		err := plugin.rm.CheckHealth(plugin.stop, plugin.health)
 		if err != nil {
 			klog.Infof("Failed to start health check: %v; continuing with health checks disabled", err)
 		}
 	}()
+	go plugin.booster.run(plugin.stop)
+	go plugin.exclusive.run(plugin.stop)
+	go plugin.allocations.run(plugin.stop)
 
 	return nil
 }
 
 func (plugin *NvidiaDevicePlugin) waitForMPSDaemon() error {
-	if plugin.config.Sharing.SharingStrategy() != spec.SharingStrategyMPS {
+	if plugin.config.Sharing.StrategyForResource(plugin.rm.Resource()) != spec.SharingStrategyMPS {
+		return nil
+	}
+	if plugin.mpsDaemon == nil && plugin.mpsMigDaemons == nil {
 		return nil
 	}
 	// TODO: Check the .ready file here.
 	// TODO: Have some retry strategy here.
-	if err := plugin.mpsDaemon.AssertHealthy(); err != nil {
-		return fmt.Errorf("error checking MPS daemon health: %w", err)
+	for _, d := range plugin.mpsDaemons() {
+		if err := d.AssertHealthy(); err != nil {
+			return fmt.Errorf("error checking MPS daemon health: %w", err)
+		}
 	}
 	klog.InfoS("MPS daemon is healthy", "resource", plugin.rm.Resource())
 	return nil
 }
 
+// checkMPSDaemons periodically checks whether the MPS daemons of the resource
+// were marked as failed by the MPS control daemon after exceeding the maximum
+// number of restarts, and writes the devices of failed daemons to the
+// 'unhealthy' channel until the stop channel is closed.
+func (plugin *NvidiaDevicePlugin) checkMPSDaemons(stop <-chan interface{}, unhealthy chan<- *rm.Device) {
+	if plugin.mpsDaemon == nil && plugin.mpsMigDaemons == nil {
+		return
+	}
+	reported := make(map[string]bool)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(mpsFailureCheckInterval):
+		}
+		for _, d := range plugin.mpsDaemons() {
+			err := d.Failed()
+			if err == nil {
+				continue
+			}
+			for _, device := range d.Devices() {
+				if reported[device.ID] {
+					continue
+				}
+				reported[device.ID] = true
+				klog.Infof("MPS daemon for '%s' failed: %v; marking Device=%s as unhealthy.", plugin.rm.Resource(), err, device.ID)
+				select {
+				case unhealthy <- device:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
+
+// mpsDaemons returns the MPS daemons that the plugin connects to. Resources
+// shared using per-MIG-device daemons have a daemon for each MIG device.
+func (plugin *NvidiaDevicePlugin) mpsDaemons() []*mps.Daemon {
+	if plugin.mpsMigDaemons == nil {
+		return []*mps.Daemon{plugin.mpsDaemon}
+	}
+	var daemons []*mps.Daemon
+	for _, d := range plugin.mpsMigDaemons {
+		daemons = append(daemons, d)
+	}
+	slices.SortFunc(daemons, func(a, b *mps.Daemon) int {
+		return strings.Compare(a.MigDevice(), b.MigDevice())
+	})
+	return daemons
+}
+
+// mpsDaemonFor returns the MPS daemon that the clients of the specified
+// replicas connect to. Since a client can only connect to a single daemon, the
+// replicas must belong to the same MIG device if the resource is shared using
+// per-MIG-device daemons.
+func (plugin *NvidiaDevicePlugin) mpsDaemonFor(ids []string) (*mps.Daemon, error) {
+	if plugin.mpsMigDaemons == nil {
+		return plugin.mpsDaemon, nil
+	}
+	var daemon *mps.Daemon
+	for _, id := range ids {
+		uuid := rm.AnnotatedID(id).GetID()
+		d, exists := plugin.mpsMigDaemons[uuid]
+		if !exists {
+			return nil, fmt.Errorf("no MPS daemon for MIG device %v", uuid)
+		}
+		if daemon != nil && d != daemon {
+			return nil, fmt.Errorf("replicas of more than one MIG device requested: %v", ids)
+		}
+		daemon = d
+	}
+	return daemon, nil
+}
+
+// Terminate advertises all devices of the plugin as unhealthy ahead of a
+// shutdown of the plugin, so that the kubelet stops allocating them before
+// the plugin is stopped.
+func (plugin *NvidiaDevicePlugin) Terminate() {
+	if !plugin.terminating.CompareAndSwap(false, true) {
+		return
+	}
+	klog.Infof("Marking all '%s' devices unhealthy for shutdown", plugin.rm.Resource())
+	plugin.events.record("All devices marked unhealthy for shutdown")
+	select {
+	case plugin.terminate <- struct{}{}:
+	default:
+	}
+}
+
+// RefreshListAndWatch resends the full device list of the plugin to the
+// kubelet on the open ListAndWatch stream. Requests made while a resend is
+// pending are coalesced.
+func (plugin *NvidiaDevicePlugin) RefreshListAndWatch() {
+	klog.Infof("Resending the '%s' device list to the kubelet on request", plugin.rm.Resource())
+	plugin.events.record("Device list resend requested")
+	select {
+	case plugin.refresh <- struct{}{}:
+	default:
+	}
+}
+
 // Stop stops the gRPC server.
 func (plugin *NvidiaDevicePlugin) Stop() error {
 	if plugin == nil || plugin.server == nil {
@@ -184,6 +595,7 @@ func (plugin *NvidiaDevicePlugin) Stop() error {
 	}
 	klog.Infof("Stopping to serve '%s' on %s", plugin.rm.Resource(), plugin.socket)
 	plugin.server.Stop()
+	plugin.events.record("Stopped")
 	if err := os.Remove(plugin.socket); err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -272,36 +684,263 @@ func (plugin *NvidiaDevicePlugin) Register() error {
 func (plugin *NvidiaDevicePlugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
 	options := &pluginapi.DevicePluginOptions{
 		GetPreferredAllocationAvailable: true,
+		PreStartRequired:                plugin.scrubber != nil,
 	}
 	return options, nil
 }
 
 // ListAndWatch lists devices and update that list according to the health status
 func (plugin *NvidiaDevicePlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.DevicePlugin_ListAndWatchServer) error {
-	if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: plugin.apiDevices()}); err != nil {
-		return err
+	drains, unsubscribe := plugin.subscribeDrains()
+	defer unsubscribe()
+
+	stop := plugin.stop
+	var liveness <-chan time.Time
+	if interval := plugin.config.Flags.Plugin.GetListAndWatchLivenessInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		liveness = ticker.C
 	}
 
+	// The device list is sent initially and whenever it may have changed. A
+	// failed send means that the stream is no longer alive, so it is reset
+	// regardless of what triggered the send.
 	for {
+		if err := plugin.send(s); err != nil {
+			return plugin.resetStream(stop, err)
+		}
 		select {
-		case <-plugin.stop:
+		case <-stop:
 			return nil
-		case d := <-plugin.health:
-			// FIXME: there is no way to recover from the Unhealthy state.
+		case <-s.Context().Done():
+			return plugin.resetStream(stop, s.Context().Err())
+		case <-liveness:
+		case <-plugin.refresh:
+		case <-plugin.healthUpdated:
+		case <-drains:
+			klog.Infof("'%s' drained devices updated", plugin.rm.Resource())
+			plugin.events.record("Drained devices updated")
+		case <-plugin.exclusive.Updates():
+			klog.Infof("'%s' devices withheld for exclusive access updated", plugin.rm.Resource())
+		case <-plugin.dual.Updates(plugin.rm.Resource()):
+			klog.Infof("'%s' devices withheld for allocations of whole or shared devices updated", plugin.rm.Resource())
+		case <-plugin.allocations.Updates():
+			klog.Infof("'%s' burst replicas or devices retained for running pods updated", plugin.rm.Resource())
+		case <-plugin.terminate:
+		}
+	}
+}
+
+// watchHealth updates the health of the devices reported as unhealthy by the
+// health checks, or as recovered by the resource manager, until the stop
+// channel is closed. Devices of failed MPS daemons are reported on the failed
+// channel and never recover, since the resource manager does not know about
+// these failures. The health is updated whether or not the kubelet has a
+// ListAndWatch stream open, so that the inventory always reflects it.
+func (plugin *NvidiaDevicePlugin) watchHealth(stop <-chan interface{}, unhealthy <-chan *rm.Device, failed <-chan *rm.Device) {
+	var recovered <-chan *rm.Device
+	if r, ok := plugin.rm.(rm.HealthRecoverer); ok {
+		recovered = r.Recovered()
+	}
+	permanent := make(map[string]bool)
+	for {
+		select {
+		case <-stop:
+			return
+		case d := <-unhealthy:
+			// Devices only recover from the Unhealthy state if the resource
+			// manager reports them as recovered.
 			d.Health = pluginapi.Unhealthy
 			klog.Infof("'%s' device marked unhealthy: %s", plugin.rm.Resource(), d.ID)
-			if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: plugin.apiDevices()}); err != nil {
-				return nil
+			plugin.events.record("Device %s marked unhealthy", d.ID)
+		case d := <-failed:
+			permanent[d.ID] = true
+			d.Health = pluginapi.Unhealthy
+			klog.Infof("'%s' device marked unhealthy: %s", plugin.rm.Resource(), d.ID)
+			plugin.events.record("Device %s marked unhealthy", d.ID)
+		case d := <-recovered:
+			if permanent[d.ID] {
+				klog.Infof("'%s' device recovered from health checks but its MPS daemon failed: %s", plugin.rm.Resource(), d.ID)
+				continue
+			}
+			d.Health = pluginapi.Healthy
+			klog.Infof("'%s' device recovered: %s", plugin.rm.Resource(), d.ID)
+			plugin.events.record("Device %s recovered", d.ID)
+		}
+		select {
+		case plugin.healthUpdated <- struct{}{}:
+		default:
+		}
+		plugin.snapshots.notify()
+	}
+}
+
+// resetStream handles a ListAndWatch stream that ended while the plugin is
+// still running, e.g. because the kubelet died without closing it. Since the
+// kubelet only opens a new stream when the plugin registers, the plugin is
+// re-registered in the background; otherwise its devices would remain
+// unallocatable once the kubelet is back.
+func (plugin *NvidiaDevicePlugin) resetStream(stop <-chan interface{}, err error) error {
+	select {
+	case <-stop:
+		return nil
+	default:
+	}
+	klog.Warningf("ListAndWatch stream for '%s' is no longer alive: %v", plugin.rm.Resource(), err)
+	plugin.events.record("ListAndWatch stream reset: %v", err)
+	if plugin.reregistering.CompareAndSwap(false, true) {
+		go func() {
+			defer plugin.reregistering.Store(false)
+			plugin.reregister(stop)
+		}()
+	}
+	return err
+}
+
+// reregister registers the plugin with the kubelet again, retrying with an
+// increasing delay until the registration succeeds or the plugin is stopped.
+func (plugin *NvidiaDevicePlugin) reregister(stop <-chan interface{}) {
+	delay := time.Second
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		err := plugin.Register()
+		if err == nil {
+			klog.Infof("Re-registered device plugin for '%s' with Kubelet", plugin.rm.Resource())
+			plugin.events.record("Re-registered with the kubelet")
+			plugin.recordRegistration(true)
+			return
+		}
+		klog.Warningf("Could not re-register device plugin for '%s': %v", plugin.rm.Resource(), err)
+		delay = min(2*delay, 30*time.Second)
+	}
+}
+
+// send sends the current device list to the kubelet and records it.
+func (plugin *NvidiaDevicePlugin) send(s pluginapi.DevicePlugin_ListAndWatchServer) error {
+	devices := plugin.apiDevices()
+	if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: devices}); err != nil {
+		return err
+	}
+	plugin.snapshots.record(devices)
+	plugin.recordHealth(devices)
+	return nil
+}
+
+// recordHealth records the health of the devices as they were advertised to
+// the kubelet. Drained and withheld devices are thus recorded as unhealthy.
+func (plugin *NvidiaDevicePlugin) recordHealth(devices []*pluginapi.Device) {
+	if plugin.healthRecorder == nil {
+		return
+	}
+	health := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		health[d.ID] = d.Health == pluginapi.Healthy
+	}
+	plugin.healthRecorder.RecordHealth(string(plugin.rm.Resource()), health)
+}
+
+// recordRegistration records a registration of the plugin with the kubelet.
+func (plugin *NvidiaDevicePlugin) recordRegistration(reregistration bool) {
+	if plugin.metricsRecorder == nil {
+		return
+	}
+	plugin.metricsRecorder.RecordRegistration(string(plugin.rm.Resource()), reregistration)
+}
+
+// recordReplicas records the number of replicas advertised for the resource
+// if it is shared.
+func (plugin *NvidiaDevicePlugin) recordReplicas() {
+	if plugin.metricsRecorder == nil {
+		return
+	}
+	strategy := plugin.config.Sharing.StrategyForResource(plugin.rm.Resource())
+	if strategy == spec.SharingStrategyNone {
+		return
+	}
+	replicas := 0
+	for _, d := range plugin.rm.Devices() {
+		if d.Replicas > 0 {
+			replicas++
+		}
+	}
+	plugin.metricsRecorder.RecordReplicas(string(plugin.rm.Resource()), string(strategy), replicas)
+}
+
+// ListAndWatchSnapshot returns the device list that was last sent to the
+// kubelet, or nil if no list was sent yet.
+func (plugin *NvidiaDevicePlugin) ListAndWatchSnapshot() *ListAndWatchSnapshot {
+	return plugin.snapshots.get()
+}
+
+// Inventory returns the devices that are currently advertised by the plugin.
+// Unlike the ListAndWatch snapshot, the list is built from the resource
+// manager and is thus available even if the kubelet never opened a stream.
+func (plugin *NvidiaDevicePlugin) Inventory() *ListAndWatchSnapshot {
+	return newSnapshot(plugin.apiDevices())
+}
+
+// SubscribeInventory returns a channel that receives a notification whenever
+// the health of a device changed or a device list is sent to the kubelet. The
+// returned function must be called to release the subscription.
+func (plugin *NvidiaDevicePlugin) SubscribeInventory() (<-chan struct{}, func()) {
+	return plugin.snapshots.subscribe()
+}
+
+// RecentEvents returns the most recent events of the plugin, ordered from
+// oldest to newest.
+func (plugin *NvidiaDevicePlugin) RecentEvents() []Event {
+	return plugin.events.get()
+}
+
+// MPSDaemonStatus returns the status of the MPS daemon for the resource, or nil
+// if the resource is not shared using MPS.
+func (plugin *NvidiaDevicePlugin) MPSDaemonStatus() *MPSDaemonStatus {
+	if plugin.mpsDaemon == nil && plugin.mpsMigDaemons == nil {
+		return nil
+	}
+	daemons := plugin.mpsDaemons()
+	status := &MPSDaemonStatus{
+		Healthy: true,
+	}
+	// The pipe dirs of per-MIG-device daemons share a common directory.
+	if len(daemons) > 0 {
+		status.PipeDir = daemons[0].HostPipeRoot(plugin.mpsHostRoot)
+	}
+	for _, d := range daemons {
+		err := d.Failed()
+		if err == nil {
+			err = d.AssertHealthy()
+		}
+		if err != nil {
+			status.Healthy = false
+			status.Error = err.Error()
+			if uuid := d.MigDevice(); uuid != "" {
+				status.Error = fmt.Sprintf("%v: %v", uuid, err)
 			}
+			break
 		}
 	}
+	return status
+}
+
+// subscribeDrains returns a channel that is notified when the set of drained
+// devices changes. If no drainer is configured, the channel is never notified.
+func (plugin *NvidiaDevicePlugin) subscribeDrains() (<-chan struct{}, func()) {
+	if plugin.drainer == nil {
+		return nil, func() {}
+	}
+	return plugin.drainer.Subscribe(plugin.rm.Resource())
 }
 
 // GetPreferredAllocation returns the preferred allocation from the set of devices specified in the request
 func (plugin *NvidiaDevicePlugin) GetPreferredAllocation(ctx context.Context, r *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
 	response := &pluginapi.PreferredAllocationResponse{}
 	for _, req := range r.ContainerRequests {
-		devices, err := plugin.rm.GetPreferredAllocation(req.AvailableDeviceIDs, req.MustIncludeDeviceIDs, int(req.AllocationSize))
+		devices, err := plugin.getPreferredAllocation(req.AvailableDeviceIDs, req.MustIncludeDeviceIDs, int(req.AllocationSize))
 		if err != nil {
 			return nil, fmt.Errorf("error getting list of preferred allocation devices: %v", err)
 		}
@@ -315,24 +954,246 @@ func (plugin *NvidiaDevicePlugin) GetPreferredAllocation(ctx context.Context, r
 	return response, nil
 }
 
+// getPreferredAllocation returns the preferred allocation for a single
+// container. If the MPS daemon for the resource assigns clients to GPUs, the
+// allocation follows its client affinity policy. Devices that became unhealthy
+// or were drained since they were last advertised to the kubelet, and replicas
+// of GPUs that reached the maximum number of clients or that were recently
+// released are avoided.
+func (plugin *NvidiaDevicePlugin) getPreferredAllocation(available, required []string, size int) ([]string, error) {
+	available = plugin.healthy(available, required, size)
+	available = plugin.clients.Available(available, required, size)
+	available = plugin.cooldown.Available(available, required, size)
+	if plugin.mpsMigDaemons != nil {
+		return plugin.getPreferredMigAllocation(available, required, size)
+	}
+	if plugin.mpsDaemon.HasClientAffinity() {
+		return plugin.mpsDaemon.GetPreferredAllocation(available, required, size)
+	}
+	return plugin.rm.GetPreferredAllocation(available, required, size)
+}
+
+// healthy removes the devices that are currently advertised as unhealthy from
+// the available devices, since the kubelet may not have processed the latest
+// device list yet. The required devices are kept. If fewer than size devices
+// remain, the available devices are returned unchanged.
+func (plugin *NvidiaDevicePlugin) healthy(available, required []string, size int) []string {
+	unhealthy := make(map[string]bool)
+	for _, d := range plugin.advertisedDevices() {
+		if d.Health != pluginapi.Healthy {
+			unhealthy[d.ID] = true
+		}
+	}
+	var filtered []string
+	for _, id := range available {
+		if !unhealthy[id] || slices.Contains(required, id) {
+			filtered = append(filtered, id)
+		}
+	}
+	if len(filtered) < size {
+		return available
+	}
+	return filtered
+}
+
+// getPreferredMigAllocation returns the preferred allocation for a resource
+// shared using per-MIG-device MPS daemons. The replicas are taken from a
+// single MIG device, preferring the MIG device of the required replicas and
+// otherwise the MIG device with the most available replicas. Since the replicas
+// of a container must be served by a single MPS daemon, an error is returned if
+// no single MIG device can satisfy the request.
+func (plugin *NvidiaDevicePlugin) getPreferredMigAllocation(available, required []string, size int) ([]string, error) {
+	replicas := make(map[string][]string)
+	var candidates []string
+	for _, id := range available {
+		uuid := rm.AnnotatedID(id).GetID()
+		if _, exists := replicas[uuid]; !exists {
+			candidates = append(candidates, uuid)
+		}
+		replicas[uuid] = append(replicas[uuid], id)
+	}
+	slices.SortFunc(candidates, func(a, b string) int {
+		if n := len(replicas[b]) - len(replicas[a]); n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+	if len(required) > 0 {
+		uuid := rm.AnnotatedID(required[0]).GetID()
+		for _, id := range required[1:] {
+			if rm.AnnotatedID(id).GetID() != uuid {
+				return nil, fmt.Errorf("required devices span multiple MIG devices: %v", required)
+			}
+		}
+		candidates = []string{uuid}
+	}
+	for _, uuid := range candidates {
+		if len(replicas[uuid]) < size {
+			continue
+		}
+		allocated := slices.Clone(required)
+		for _, id := range replicas[uuid] {
+			if len(allocated) == size {
+				break
+			}
+			if !slices.Contains(required, id) {
+				allocated = append(allocated, id)
+			}
+		}
+		return allocated, nil
+	}
+	return nil, fmt.Errorf("no MIG device has %d available replicas", size)
+}
+
 // Allocate which return list of devices.
 func (plugin *NvidiaDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
-	responses := pluginapi.AllocateResponse{}
+	start := time.Now()
+	response, err := plugin.allocate(ctx, reqs)
+	if plugin.metricsRecorder != nil {
+		plugin.metricsRecorder.RecordAllocate(string(plugin.rm.Resource()), time.Since(start), err)
+	}
+	return response, err
+}
+
+func (plugin *NvidiaDevicePlugin) allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	release, err := plugin.acquireAllocateSlot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for allocation slot for %q: %w", plugin.rm.Resource(), err)
+	}
+	defer release()
+
 	for _, req := range reqs.ContainerRequests {
 		if err := plugin.rm.ValidateRequest(req.DevicesIDs); err != nil {
 			return nil, fmt.Errorf("invalid allocation request for %q: %w", plugin.rm.Resource(), err)
 		}
-		response, err := plugin.getAllocateResponse(req.DevicesIDs)
+	}
+	// The requested devices are counted as allocated until they are listed
+	// by the PodResources API, unless the allocation fails.
+	var admitted [][]string
+	var allocated bool
+	defer func() {
+		if allocated {
+			return
+		}
+		for _, ids := range admitted {
+			plugin.allocations.cancel(ids)
+		}
+	}()
+	for _, req := range reqs.ContainerRequests {
+		if err := plugin.allocations.admit(req.DevicesIDs, plugin.clients.check); err != nil {
+			return nil, fmt.Errorf("allocation request for %q exceeds the client limit: %w", plugin.rm.Resource(), err)
+		}
+		admitted = append(admitted, req.DevicesIDs)
+	}
+	for _, req := range reqs.ContainerRequests {
+		if err := plugin.exclusive.Admit(req.DevicesIDs); err != nil {
+			return nil, fmt.Errorf("allocation request for %q conflicts with exclusive access: %w", plugin.rm.Resource(), err)
+		}
+	}
+	for _, req := range reqs.ContainerRequests {
+		if err := plugin.dual.Claim(plugin.rm.Resource(), req.DevicesIDs); err != nil {
+			return nil, fmt.Errorf("allocation request for %q conflicts with another resource: %w", plugin.rm.Resource(), err)
+		}
+	}
+	// The pods that may be allocating the resource are shared by the checks
+	// below, so that they are listed at most once per request.
+	allocating := plugin.allocatingPods()
+	if err := plugin.runtimeClasses.check(allocating); err != nil {
+		plugin.events.record("Rejected allocation of %v: %v", plugin.rm.Resource(), err)
+		return nil, fmt.Errorf("allocation request for %q is incompatible with the runtime class of the pod: %w", plugin.rm.Resource(), err)
+	}
+
+	// The thread percentage requested by the pod applies to all of its
+	// containers. Trusted pods are not limited at all.
+	trusted := plugin.trusted.get(allocating)
+	var threadPercentage string
+	if !trusted {
+		threadPercentage = plugin.threads.get(allocating)
+	}
+	compat := plugin.cudaCompat.get()
+	if plugin.cudaCompatPolicy != nil {
+		compat = plugin.cudaCompatPolicy.get(allocating)
+	}
+
+	// The container requests of a pod are processed concurrently and share
+	// the resolution of CDI devices and device nodes.
+	cache := newAllocateCache()
+	responses := make([]*pluginapi.ContainerAllocateResponse, len(reqs.ContainerRequests))
+	errs := make([]error, len(reqs.ContainerRequests))
+	var wg sync.WaitGroup
+	for i, req := range reqs.ContainerRequests {
+		wg.Add(1)
+		go func(i int, requestIds []string) {
+			defer wg.Done()
+			responses[i], errs[i] = plugin.getAllocateResponse(cache, compat, requestIds)
+		}(i, req.DevicesIDs)
+	}
+	wg.Wait()
+	for _, err := range errs {
 		if err != nil {
 			return nil, fmt.Errorf("failed to get allocate response: %v", err)
 		}
-		responses.ContainerResponses = append(responses.ContainerResponses, response)
 	}
 
-	return &responses, nil
+	if threadPercentage != "" {
+		for _, response := range responses {
+			response.Envs["CUDA_MPS_ACTIVE_THREAD_PERCENTAGE"] = threadPercentage
+		}
+	}
+	resource := plugin.rm.Resource()
+	if r := plugin.config.Sharing.ReplicatedResourcesFor(resource).ForResource(resource); r != nil {
+		for _, response := range responses {
+			maps.Copy(response.Envs, r.Envs)
+		}
+	}
+	if trusted {
+		for i, req := range reqs.ContainerRequests {
+			daemon, err := plugin.mpsDaemonFor(req.DevicesIDs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get allocate response for trusted workload: %v", err)
+			}
+			for k, v := range daemon.UnconstrainedClientEnvvars(req.DevicesIDs) {
+				responses[i].Envs[k] = v
+			}
+		}
+	}
+
+	for _, req := range reqs.ContainerRequests {
+		plugin.booster.boost(req.DevicesIDs)
+	}
+	allocated = true
+	return &pluginapi.AllocateResponse{ContainerResponses: responses}, nil
 }
 
-func (plugin *NvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*pluginapi.ContainerAllocateResponse, error) {
+// acquireAllocateSlot blocks until both the per-resource and global
+// allocation limiters allow an Allocate call to proceed. The per-resource
+// limiter is acquired first so that a resource that is at its limit does not
+// hold a slot that could be used by other resources.
+// The returned function must be called to release the acquired slots.
+func (plugin *NvidiaDevicePlugin) acquireAllocateSlot(ctx context.Context) (func(), error) {
+	if err := plugin.allocateLimiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	if err := plugin.globalAllocateLimiter.Acquire(ctx); err != nil {
+		plugin.allocateLimiter.Release()
+		return nil, err
+	}
+	release := func() {
+		plugin.globalAllocateLimiter.Release()
+		plugin.allocateLimiter.Release()
+	}
+	return release, nil
+}
+
+func (plugin *NvidiaDevicePlugin) getAllocateResponse(cache *allocateCache, compat *cudaCompat, requestIds []string) (*pluginapi.ContainerAllocateResponse, error) {
+	// With milli-GPU units or memory chunks, a request consists of many
+	// replicas of the same GPU. Each GPU is only bound to the container once.
+	// The memory limits of an MPS client are still derived from all of the
+	// chunks that it is allocated.
+	mpsRequestIds := requestIds
+	if rrs := plugin.config.Sharing.ReplicatedResourcesFor(plugin.rm.Resource()); rrs.IsMilli() || rrs.IsMemory() {
+		requestIds = rm.AnnotatedIDs(requestIds).UniqueByID()
+	}
 	deviceIDs := plugin.deviceIDsFromAnnotatedDeviceIDs(requestIds)
 
 	// Create an empty response that will be updated as required below.
@@ -340,8 +1201,23 @@ func (plugin *NvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 		Envs: make(map[string]string),
 	}
 	if plugin.deviceListStrategies.IsCDIEnabled() {
+		var extraDevices []string
+		topologyDevice, err := plugin.topologyDevice(cache, requestIds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get topology file: %v", err)
+		}
+		if topologyDevice != "" {
+			extraDevices = append(extraDevices, topologyDevice)
+		}
+		if compat != nil {
+			compatDevice, err := cache.cudaCompatDevice(plugin.cdiHandler, compat.hostDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get CUDA compat CDI device: %v", err)
+			}
+			extraDevices = append(extraDevices, compatDevice)
+		}
 		responseID := uuid.New().String()
-		if err := plugin.updateResponseForCDI(response, responseID, deviceIDs...); err != nil {
+		if err := plugin.updateResponseForCDI(response, cache, responseID, extraDevices, deviceIDs...); err != nil {
 			return nil, fmt.Errorf("failed to get allocate response for CDI: %v", err)
 		}
 	}
@@ -351,11 +1227,16 @@ func (plugin *NvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 	if plugin.deviceListStrategies.Includes(spec.DeviceListStrategyVolumeMounts) {
 		plugin.updateResponseForDeviceMounts(response, deviceIDs...)
 	}
-	if plugin.config.Sharing.SharingStrategy() == spec.SharingStrategyMPS {
-		plugin.updateResponseForMPS(response)
+	if plugin.config.Sharing.StrategyForResource(plugin.rm.Resource()) == spec.SharingStrategyMPS {
+		if err := plugin.updateResponseForMPS(response, mpsRequestIds); err != nil {
+			return nil, fmt.Errorf("failed to get allocate response for MPS: %v", err)
+		}
 	}
 	if *plugin.config.Flags.Plugin.PassDeviceSpecs {
-		response.Devices = append(response.Devices, plugin.apiDeviceSpecs(*plugin.config.Flags.NvidiaDriverRoot, requestIds)...)
+		response.Devices = append(response.Devices, plugin.apiDeviceSpecs(cache, *plugin.config.Flags.NvidiaDriverRoot, requestIds)...)
+	}
+	if plugin.config.Flags.Plugin.GetCPUAffinityEnvvars() {
+		updateResponseForCPUAffinity(response, plugin.rm.Devices(), requestIds)
 	}
 	if *plugin.config.Flags.GDSEnabled {
 		response.Envs["NVIDIA_GDS"] = "enabled"
@@ -363,42 +1244,80 @@ func (plugin *NvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 	if *plugin.config.Flags.MOFEDEnabled {
 		response.Envs["NVIDIA_MOFED"] = "enabled"
 	}
+	// With CDI, the CUDA forward compatibility libraries are injected through
+	// their CDI device above.
+	if !plugin.deviceListStrategies.IsCDIEnabled() {
+		compat.updateResponse(response)
+	}
 	return response, nil
 }
 
 // updateResponseForMPS ensures that the ContainerAllocate response contains the information required to use MPS.
 // This includes per-resource pipe and log directories as well as a global daemon-specific shm
 // and assumes that an MPS control daemon has already been started.
-func (plugin NvidiaDevicePlugin) updateResponseForMPS(response *pluginapi.ContainerAllocateResponse) {
+func (plugin *NvidiaDevicePlugin) updateResponseForMPS(response *pluginapi.ContainerAllocateResponse, requestIds []string) error {
 	// TODO: We should check that the deviceIDs are shared using MPS.
-	response.Envs["CUDA_MPS_PIPE_DIRECTORY"] = plugin.mpsDaemon.PipeDir()
+	daemon, err := plugin.mpsDaemonFor(requestIds)
+	if err != nil {
+		return err
+	}
+	response.Envs["CUDA_MPS_PIPE_DIRECTORY"] = daemon.PipeDir()
+	for k, v := range daemon.ClientEnvvars(requestIds) {
+		response.Envs[k] = v
+	}
 
 	resourceName := plugin.rm.Resource()
 	response.Mounts = append(response.Mounts,
 		&pluginapi.Mount{
-			ContainerPath: plugin.mpsDaemon.PipeDir(),
-			HostPath:      plugin.mpsHostRoot.PipeDir(resourceName),
+			ContainerPath: daemon.PipeDir(),
+			HostPath:      daemon.HostPipeDir(plugin.mpsHostRoot),
 		},
 		&pluginapi.Mount{
-			ContainerPath: plugin.mpsDaemon.ShmDir(),
+			ContainerPath: daemon.ShmDir(),
 			HostPath:      plugin.mpsHostRoot.ShmDir(resourceName),
 		},
 	)
+	return nil
+}
+
+// topologyDevice writes the topology file of the requested devices and returns
+// the qualified name of the CDI device that mounts it. If topology files are
+// not enabled for the resource, an empty name is returned.
+func (plugin *NvidiaDevicePlugin) topologyDevice(cache *allocateCache, requestIds []string) (string, error) {
+	if !plugin.topologyFile {
+		return "", nil
+	}
+	// Replicas of the same GPU are only included once. The order of the
+	// devices matches their order in the container.
+	ids := rm.AnnotatedIDs(requestIds).UniqueByID().GetIDs()
+	return cache.topologyDevice(strings.Join(ids, ","), func() (string, error) {
+		topology, err := plugin.rm.GetTopology(ids)
+		if err != nil {
+			return "", err
+		}
+		contents, err := json.MarshalIndent(topology, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal topology: %w", err)
+		}
+		return plugin.cdiHandler.CreateTopologySpecFile(contents)
+	})
 }
 
 // updateResponseForCDI updates the specified response for the given device IDs.
 // This response contains the annotations required to trigger CDI injection in the container engine or nvidia-container-runtime.
-func (plugin *NvidiaDevicePlugin) updateResponseForCDI(response *pluginapi.ContainerAllocateResponse, responseID string, deviceIDs ...string) error {
+// The extra devices, such as the topology device, are injected along with the devices.
+func (plugin *NvidiaDevicePlugin) updateResponseForCDI(response *pluginapi.ContainerAllocateResponse, cache *allocateCache, responseID string, extraDevices []string, deviceIDs ...string) error {
 	var devices []string
 	for _, id := range deviceIDs {
-		devices = append(devices, plugin.cdiHandler.QualifiedName("gpu", id))
+		devices = append(devices, cache.qualifiedName(plugin.cdiHandler, "gpu", id))
 	}
 	if *plugin.config.Flags.GDSEnabled {
-		devices = append(devices, plugin.cdiHandler.QualifiedName("gds", "all"))
+		devices = append(devices, cache.qualifiedName(plugin.cdiHandler, "gds", "all"))
 	}
 	if *plugin.config.Flags.MOFEDEnabled {
-		devices = append(devices, plugin.cdiHandler.QualifiedName("mofed", "all"))
+		devices = append(devices, cache.qualifiedName(plugin.cdiHandler, "mofed", "all"))
 	}
+	devices = append(devices, extraDevices...)
 
 	if len(devices) == 0 {
 		return nil
@@ -443,8 +1362,28 @@ func (plugin *NvidiaDevicePlugin) getCDIDeviceAnnotations(id string, devices ...
 	return updatedAnnotations, nil
 }
 
-// PreStartContainer is unimplemented for this plugin
-func (plugin *NvidiaDevicePlugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+// PreStartContainer scrubs the memory of the devices if this is enabled for
+// the resource. The memory of a device is only scrubbed if it is handed to a
+// different tenant than before.
+func (plugin *NvidiaDevicePlugin) PreStartContainer(ctx context.Context, r *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	if plugin.scrubber == nil {
+		return &pluginapi.PreStartContainerResponse{}, nil
+	}
+	tenants := plugin.tenants.current(ctx)
+	for _, id := range r.DevicesIDs {
+		uuid := rm.AnnotatedID(id).GetID()
+		tenant := tenants[id]
+		if !plugin.tenants.changed(uuid, tenant) {
+			klog.Infof("Not scrubbing memory of device %v for '%s': tenant %v is unchanged", uuid, plugin.rm.Resource(), tenant)
+			continue
+		}
+		klog.Infof("Scrubbing memory of device %v for '%s'", uuid, plugin.rm.Resource())
+		if err := plugin.scrubber.Scrub(ctx, uuid); err != nil {
+			plugin.tenants.set(uuid, "")
+			return nil, fmt.Errorf("failed to scrub memory of device %v: %w", uuid, err)
+		}
+		plugin.tenants.set(uuid, tenant)
+	}
 	return &pluginapi.PreStartContainerResponse{}, nil
 }
 
@@ -480,7 +1419,74 @@ func (plugin *NvidiaDevicePlugin) deviceIDsFromAnnotatedDeviceIDs(ids []string)
 }
 
 func (plugin *NvidiaDevicePlugin) apiDevices() []*pluginapi.Device {
-	return plugin.rm.Devices().GetPluginDevices()
+	// The devices retained for running pods are always unhealthy.
+	return append(plugin.advertisedDevices(), plugin.retained.Devices()...)
+}
+
+func (plugin *NvidiaDevicePlugin) advertisedDevices() []*pluginapi.Device {
+	devices := plugin.rm.Devices().GetPluginDevices()
+	if plugin.terminating.Load() {
+		for i, d := range devices {
+			terminated := *d
+			terminated.Health = pluginapi.Unhealthy
+			devices[i] = &terminated
+		}
+		return devices
+	}
+	unavailable := plugin.exclusive.Withheld()
+	if withheld := plugin.dual.Withheld(plugin.rm.Resource(), plugin.rm.Devices()); len(withheld) > 0 {
+		if unavailable == nil {
+			unavailable = make(map[string]bool)
+		}
+		maps.Copy(unavailable, withheld)
+	}
+	if plugin.drainer != nil {
+		if unavailable == nil {
+			unavailable = make(map[string]bool)
+		}
+		for id, draining := range plugin.drainer.Draining(plugin.rm.Resource()) {
+			if draining {
+				unavailable[id] = true
+			}
+		}
+	}
+	if plugin.burst != nil {
+		// Base replicas that are withheld or whose GPUs are cooling down are
+		// not available, so the burst replicas are needed instead.
+		busy := maps.Clone(unavailable)
+		if busy == nil {
+			busy = make(map[string]bool)
+		}
+		coolingDown := plugin.cooldown.CoolingDown()
+		for id := range plugin.rm.Devices() {
+			if coolingDown[rm.AnnotatedID(id).GetID()] {
+				busy[id] = true
+			}
+		}
+		if withheld := plugin.burst.Withheld(busy); len(withheld) > 0 {
+			if unavailable == nil {
+				unavailable = make(map[string]bool)
+			}
+			maps.Copy(unavailable, withheld)
+		}
+	}
+	if len(unavailable) == 0 {
+		return devices
+	}
+	// Drained devices, the replicas withheld for pods with exclusive access,
+	// the burst replicas that are not needed yet and the devices of GPUs
+	// allocated in their other form are advertised as unhealthy so that the
+	// kubelet does not allocate them to new pods. The devices are copied so
+	// that their actual health is preserved once they are available again.
+	for i, d := range devices {
+		if !unavailable[d.ID] {
+			continue
+		}
+		withheld := *d
+		withheld.Health = pluginapi.Unhealthy
+		devices[i] = &withheld
+	}
+	return devices
 }
 
 // updateResponseForDeviceListEnvvar sets the environment variable for the requested devices.
@@ -500,7 +1506,7 @@ func (plugin *NvidiaDevicePlugin) updateResponseForDeviceMounts(response *plugin
 	}
 }
 
-func (plugin *NvidiaDevicePlugin) apiDeviceSpecs(driverRoot string, ids []string) []*pluginapi.DeviceSpec {
+func (plugin *NvidiaDevicePlugin) apiDeviceSpecs(cache *allocateCache, driverRoot string, ids []string) []*pluginapi.DeviceSpec {
 	optional := map[string]bool{
 		"/dev/nvidiactl":        true,
 		"/dev/nvidia-uvm":       true,
@@ -512,10 +1518,8 @@ func (plugin *NvidiaDevicePlugin) apiDeviceSpecs(driverRoot string, ids []string
 
 	var specs []*pluginapi.DeviceSpec
 	for _, p := range paths {
-		if optional[p] {
-			if _, err := os.Stat(p); err != nil {
-				continue
-			}
+		if optional[p] && !cache.pathExists(p) {
+			continue
 		}
 		spec := &pluginapi.DeviceSpec{
 			ContainerPath: p,
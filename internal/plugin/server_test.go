@@ -17,13 +17,20 @@
 package plugin
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 
 	v1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
 	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
 func TestCDIAllocateResponse(t *testing.T) {
@@ -35,6 +42,7 @@ func TestCDIAllocateResponse(t *testing.T) {
 		CDIEnabled           bool
 		GDSEnabled           bool
 		MOFEDEnabled         bool
+		extraDevices         []string
 		expectedResponse     pluginapi.ContainerAllocateResponse
 	}{
 		{
@@ -129,6 +137,24 @@ func TestCDIAllocateResponse(t *testing.T) {
 				},
 			},
 		},
+		{
+			description:          "topology device is included with device ids",
+			deviceIds:            []string{"gpu0", "gpu1"},
+			deviceListStrategies: []string{"cdi-annotations", "cdi-cri"},
+			CDIPrefix:            "cdi.k8s.io/",
+			CDIEnabled:           true,
+			extraDevices:         []string{"nvidia.com/topology=0123"},
+			expectedResponse: pluginapi.ContainerAllocateResponse{
+				Annotations: map[string]string{
+					"cdi.k8s.io/nvidia-device-plugin_uuid": "nvidia.com/gpu=gpu0,nvidia.com/gpu=gpu1,nvidia.com/topology=0123",
+				},
+				CDIDevices: []*pluginapi.CDIDevice{
+					{Name: "nvidia.com/gpu=gpu0"},
+					{Name: "nvidia.com/gpu=gpu1"},
+					{Name: "nvidia.com/topology=0123"},
+				},
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -155,10 +181,388 @@ func TestCDIAllocateResponse(t *testing.T) {
 			}
 
 			response := pluginapi.ContainerAllocateResponse{}
-			err := plugin.updateResponseForCDI(&response, "uuid", tc.deviceIds...)
+			err := plugin.updateResponseForCDI(&response, nil, "uuid", tc.extraDevices, tc.deviceIds...)
 
 			require.Nil(t, err)
 			require.EqualValues(t, &tc.expectedResponse, &response)
 		})
 	}
 }
+
+type testListAndWatchServer struct {
+	pluginapi.DevicePlugin_ListAndWatchServer
+	ctx     context.Context
+	sendErr error
+	// failInitial fails the initial send as well as the later ones.
+	failInitial bool
+	sent        int
+}
+
+func (s *testListAndWatchServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testListAndWatchServer) Send(*pluginapi.ListAndWatchResponse) error {
+	s.sent++
+	if s.sent > 1 || s.failInitial {
+		return s.sendErr
+	}
+	return nil
+}
+
+type testDevicesResourceManager struct {
+	testResourceManager
+}
+
+func (testDevicesResourceManager) Devices() rm.Devices {
+	return rm.Devices{}
+}
+
+func TestListAndWatchResetsStream(t *testing.T) {
+	testCases := []struct {
+		description      string
+		livenessInterval time.Duration
+		sendErr          error
+		failInitial      bool
+		refreshed        bool
+		healthUpdated    bool
+		cancelled        bool
+		stopped          bool
+		expectedError    bool
+	}{
+		{
+			description:   "stream closed by the kubelet is reset",
+			cancelled:     true,
+			expectedError: true,
+		},
+		{
+			description:      "failed liveness send resets the stream",
+			livenessInterval: time.Millisecond,
+			sendErr:          errors.New("broken pipe"),
+			expectedError:    true,
+		},
+		{
+			description:   "failed refresh send resets the stream",
+			sendErr:       errors.New("broken pipe"),
+			refreshed:     true,
+			expectedError: true,
+		},
+		{
+			description:   "failed health update send resets the stream",
+			sendErr:       errors.New("broken pipe"),
+			healthUpdated: true,
+			expectedError: true,
+		},
+		{
+			description:   "failed initial send resets the stream",
+			sendErr:       errors.New("broken pipe"),
+			failInitial:   true,
+			expectedError: true,
+		},
+		{
+			description: "stream closed while stopping is not reset",
+			cancelled:   true,
+			stopped:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			interval := v1.Duration(tc.livenessInterval)
+			plugin := NvidiaDevicePlugin{
+				rm: testDevicesResourceManager{},
+				config: &v1.Config{
+					Flags: v1.Flags{
+						CommandLineFlags: v1.CommandLineFlags{
+							Plugin: &v1.PluginCommandLineFlags{
+								ListAndWatchLivenessInterval: &interval,
+							},
+						},
+					},
+				},
+				stop:          make(chan interface{}),
+				refresh:       make(chan struct{}, 1),
+				healthUpdated: make(chan struct{}, 1),
+				snapshots:     &snapshotRecorder{},
+				events:        &eventRecorder{},
+			}
+			if tc.refreshed {
+				plugin.RefreshListAndWatch()
+			}
+			if tc.healthUpdated {
+				plugin.healthUpdated <- struct{}{}
+			}
+			// Closing the stop channel ends the background re-registration
+			// before it dials the kubelet.
+			defer func() {
+				if !tc.stopped {
+					close(plugin.stop)
+				}
+			}()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelled {
+				cancel()
+			}
+			if tc.stopped {
+				close(plugin.stop)
+			}
+
+			s := &testListAndWatchServer{ctx: ctx, sendErr: tc.sendErr, failInitial: tc.failInitial}
+			err := plugin.ListAndWatch(&pluginapi.Empty{}, s)
+			if tc.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expectedError, plugin.reregistering.Load())
+		})
+	}
+}
+
+type testHealthyResourceManager struct {
+	testResourceManager
+	devices rm.Devices
+}
+
+func (r testHealthyResourceManager) Devices() rm.Devices {
+	return r.devices
+}
+
+func TestTerminateMarksDevicesUnhealthy(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}},
+		"GPU-1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy}},
+	}
+	plugin := NvidiaDevicePlugin{
+		rm:        testHealthyResourceManager{devices: devices},
+		events:    &eventRecorder{},
+		terminate: make(chan struct{}, 1),
+	}
+
+	for _, d := range plugin.apiDevices() {
+		require.Equal(t, pluginapi.Healthy, d.Health)
+	}
+
+	plugin.Terminate()
+	plugin.Terminate()
+	require.Len(t, plugin.terminate, 1)
+	require.Len(t, plugin.RecentEvents(), 1)
+
+	for _, d := range plugin.apiDevices() {
+		require.Equal(t, pluginapi.Unhealthy, d.Health)
+	}
+	for _, d := range devices {
+		require.Equal(t, pluginapi.Healthy, d.Health)
+	}
+}
+
+func TestWatchHealthUpdatesInventory(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}},
+	}
+	plugin := NvidiaDevicePlugin{
+		rm:            testHealthyResourceManager{devices: devices},
+		healthUpdated: make(chan struct{}, 1),
+		snapshots:     &snapshotRecorder{},
+		events:        &eventRecorder{},
+	}
+	require.Equal(t, pluginapi.Healthy, plugin.Inventory().Devices[0].Health)
+
+	notifications, unsubscribe := plugin.SubscribeInventory()
+	defer unsubscribe()
+
+	// The health is updated although the kubelet has no stream open.
+	stop := make(chan interface{})
+	defer close(stop)
+	unhealthy := make(chan *rm.Device)
+	go plugin.watchHealth(stop, unhealthy, nil)
+	unhealthy <- devices["GPU-0"]
+
+	<-notifications
+	require.Equal(t, pluginapi.Unhealthy, plugin.Inventory().Devices[0].Health)
+	require.Len(t, plugin.healthUpdated, 1)
+	require.Nil(t, plugin.ListAndWatchSnapshot())
+}
+
+type testDrainer map[string]bool
+
+func (d testDrainer) Draining(v1.ResourceName) map[string]bool {
+	return d
+}
+
+func (d testDrainer) Subscribe(v1.ResourceName) (<-chan struct{}, func()) {
+	return nil, func() {}
+}
+
+func TestPreferredAllocationAvoidsUnhealthyAndDrainedDevices(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Unhealthy}},
+		"GPU-1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy}},
+		"GPU-2": &rm.Device{Device: pluginapi.Device{ID: "GPU-2", Health: pluginapi.Healthy}},
+		"GPU-3": &rm.Device{Device: pluginapi.Device{ID: "GPU-3", Health: pluginapi.Healthy}},
+	}
+	plugin := NvidiaDevicePlugin{
+		rm:      testHealthyResourceManager{devices: devices},
+		drainer: testDrainer{"GPU-1": true},
+	}
+	available := []string{"GPU-0", "GPU-1", "GPU-2", "GPU-3"}
+
+	require.Equal(t, []string{"GPU-2", "GPU-3"}, plugin.healthy(available, nil, 2))
+	require.Equal(t, []string{"GPU-0", "GPU-2", "GPU-3"}, plugin.healthy(available, []string{"GPU-0"}, 2))
+	require.Equal(t, available, plugin.healthy(available, nil, 3))
+}
+
+func TestBurstReplicasForDrainedBaseReplicas(t *testing.T) {
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0", Health: pluginapi.Healthy}, Replicas: 2},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1", Health: pluginapi.Healthy}, Replicas: 2, Burst: true},
+		"GPU-1::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-1::0", Health: pluginapi.Healthy}, Replicas: 2},
+		"GPU-1::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-1::1", Health: pluginapi.Healthy}, Replicas: 2, Burst: true},
+	}
+	plugin := NvidiaDevicePlugin{
+		rm:      testHealthyResourceManager{devices: devices},
+		drainer: testDrainer{"GPU-1::0": true},
+		burst:   newBurstReplicas("nvidia.com/gpu", devices),
+	}
+	plugin.burst.observe(allocations{{"GPU-0::0"}})
+
+	// The only base replica that is not allocated is drained, so the burst
+	// replicas are advertised.
+	health := make(map[string]string)
+	for _, d := range plugin.apiDevices() {
+		health[d.ID] = d.Health
+	}
+	require.Equal(t, map[string]string{
+		"GPU-0::0": pluginapi.Healthy,
+		"GPU-0::1": pluginapi.Healthy,
+		"GPU-1::0": pluginapi.Unhealthy,
+		"GPU-1::1": pluginapi.Healthy,
+	}, health)
+}
+
+func TestPerMigDeviceMPSDaemons(t *testing.T) {
+	devices := make(rm.Devices)
+	for _, id := range []string{"MIG-a::0", "MIG-a::1", "MIG-b::0", "MIG-b::1", "MIG-b::2"} {
+		devices[id] = &rm.Device{Device: pluginapi.Device{ID: id}, Index: "0:0"}
+	}
+	resourceManager := testHealthyResourceManager{devices: devices}
+	plugin := NvidiaDevicePlugin{
+		rm:            resourceManager,
+		mpsMigDaemons: make(map[string]*mps.Daemon),
+		mpsHostRoot:   mps.Root("/run/nvidia/mps"),
+	}
+	for _, d := range mps.NewMigDaemons(resourceManager, mps.ContainerRoot) {
+		plugin.mpsMigDaemons[d.MigDevice()] = d
+	}
+
+	t.Run("preferred allocation", func(t *testing.T) {
+		testCases := []struct {
+			description string
+			available   []string
+			required    []string
+			size        int
+			expected    []string
+			expectedErr bool
+		}{
+			{
+				description: "MIG device with most available replicas",
+				available:   []string{"MIG-a::0", "MIG-a::1", "MIG-b::0", "MIG-b::1", "MIG-b::2"},
+				size:        2,
+				expected:    []string{"MIG-b::0", "MIG-b::1"},
+			},
+			{
+				description: "MIG device of required replicas",
+				available:   []string{"MIG-a::0", "MIG-a::1", "MIG-b::0", "MIG-b::1", "MIG-b::2"},
+				required:    []string{"MIG-a::1"},
+				size:        2,
+				expected:    []string{"MIG-a::1", "MIG-a::0"},
+			},
+			{
+				description: "no MIG device has enough replicas",
+				available:   []string{"MIG-a::0", "MIG-a::1", "MIG-b::0", "MIG-b::1", "MIG-b::2"},
+				size:        4,
+				expectedErr: true,
+			},
+			{
+				description: "MIG device of required replicas has too few replicas",
+				available:   []string{"MIG-a::0", "MIG-a::1", "MIG-b::0", "MIG-b::1", "MIG-b::2"},
+				required:    []string{"MIG-a::1"},
+				size:        3,
+				expectedErr: true,
+			},
+			{
+				description: "required replicas span MIG devices",
+				available:   []string{"MIG-a::0", "MIG-a::1", "MIG-b::0", "MIG-b::1", "MIG-b::2"},
+				required:    []string{"MIG-a::1", "MIG-b::0"},
+				size:        2,
+				expectedErr: true,
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.description, func(t *testing.T) {
+				allocated, err := plugin.getPreferredAllocation(tc.available, tc.required, tc.size)
+				if tc.expectedErr {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, allocated)
+			})
+		}
+	})
+
+	t.Run("allocate response", func(t *testing.T) {
+		response := &pluginapi.ContainerAllocateResponse{Envs: make(map[string]string)}
+		require.NoError(t, plugin.updateResponseForMPS(response, []string{"MIG-b::0", "MIG-b::2"}))
+		require.Equal(t, "/mps/nvidia.com/gpu/MIG-b/pipe", response.Envs["CUDA_MPS_PIPE_DIRECTORY"])
+		require.Equal(t, "/run/nvidia/mps/nvidia.com/gpu/MIG-b/pipe", response.Mounts[0].HostPath)
+
+		response = &pluginapi.ContainerAllocateResponse{Envs: make(map[string]string)}
+		require.Error(t, plugin.updateResponseForMPS(response, []string{"MIG-a::0", "MIG-b::0"}))
+	})
+}
+
+func TestCheckMPSDaemonsMarksDevicesOfFailedDaemonsUnhealthy(t *testing.T) {
+	interval := mpsFailureCheckInterval
+	mpsFailureCheckInterval = time.Millisecond
+	defer func() { mpsFailureCheckInterval = interval }()
+
+	devices := rm.Devices{
+		"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+	}
+	resourceManager := testHealthyResourceManager{devices: devices}
+	root := mps.Root(t.TempDir())
+	plugin := NvidiaDevicePlugin{
+		rm:        resourceManager,
+		mpsDaemon: mps.NewDaemon(resourceManager, root),
+	}
+
+	stop := make(chan interface{})
+	defer close(stop)
+	unhealthy := make(chan *rm.Device)
+	go plugin.checkMPSDaemons(stop, unhealthy)
+
+	select {
+	case d := <-unhealthy:
+		t.Fatalf("unexpected unhealthy device %v", d.ID)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	failedFile := root.Path(string(resourceManager.Resource()), ".failed")
+	require.NoError(t, os.MkdirAll(filepath.Dir(failedFile), 0755))
+	require.NoError(t, os.WriteFile(failedFile, []byte("no pipe"), 0644))
+
+	var ids []string
+	for range devices {
+		select {
+		case d := <-unhealthy:
+			ids = append(ids, d.ID)
+		case <-time.After(10 * time.Second):
+			t.Fatal("devices of failed daemon were not marked unhealthy")
+		}
+	}
+	require.ElementsMatch(t, []string{"GPU-0::0", "GPU-0::1"}, ids)
+}
@@ -0,0 +1,118 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"sync"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// ListAndWatchSnapshot is the device list that was last sent to the kubelet.
+type ListAndWatchSnapshot struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Devices   []SnapshotDevice `json:"devices"`
+}
+
+// SnapshotDevice is a device as it was advertised to the kubelet.
+type SnapshotDevice struct {
+	ID        string  `json:"id"`
+	Health    string  `json:"health"`
+	NUMANodes []int64 `json:"numaNodes,omitempty"`
+}
+
+// snapshotRecorder records the device lists sent in ListAndWatch responses and
+// notifies subscribers whenever the advertised devices may have changed.
+type snapshotRecorder struct {
+	sync.Mutex
+	last        *ListAndWatchSnapshot
+	subscribers map[chan struct{}]bool
+}
+
+// record stores a copy of the specified devices and notifies the subscribers.
+func (r *snapshotRecorder) record(devices []*pluginapi.Device) {
+	snapshot := newSnapshot(devices)
+
+	r.Lock()
+	defer r.Unlock()
+	r.last = snapshot
+	r.notifyLocked()
+}
+
+// notify notifies the subscribers without recording a snapshot, e.g. when the
+// health of a device changed while no ListAndWatch stream is open.
+func (r *snapshotRecorder) notify() {
+	r.Lock()
+	defer r.Unlock()
+	r.notifyLocked()
+}
+
+func (r *snapshotRecorder) notifyLocked() {
+	for ch := range r.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// newSnapshot creates a snapshot of the specified devices. The devices are
+// copied as their health is updated in place by the health checks.
+func newSnapshot(devices []*pluginapi.Device) *ListAndWatchSnapshot {
+	snapshot := &ListAndWatchSnapshot{
+		Timestamp: time.Now(),
+		Devices:   make([]SnapshotDevice, 0, len(devices)),
+	}
+	for _, d := range devices {
+		device := SnapshotDevice{
+			ID:     d.ID,
+			Health: d.Health,
+		}
+		for _, node := range d.GetTopology().GetNodes() {
+			device.NUMANodes = append(device.NUMANodes, node.GetID())
+		}
+		snapshot.Devices = append(snapshot.Devices, device)
+	}
+	return snapshot
+}
+
+// subscribe returns a channel that receives a notification whenever a new
+// snapshot is recorded or the subscribers are notified otherwise. The returned function must be called to release the
+// subscription.
+func (r *snapshotRecorder) subscribe() (<-chan struct{}, func()) {
+	r.Lock()
+	defer r.Unlock()
+	ch := make(chan struct{}, 1)
+	if r.subscribers == nil {
+		r.subscribers = make(map[chan struct{}]bool)
+	}
+	r.subscribers[ch] = true
+	unsubscribe := func() {
+		r.Lock()
+		defer r.Unlock()
+		delete(r.subscribers, ch)
+	}
+	return ch, unsubscribe
+}
+
+// get returns the last recorded snapshot or nil if no devices were sent yet.
+func (r *snapshotRecorder) get() *ListAndWatchSnapshot {
+	r.Lock()
+	defer r.Unlock()
+	return r.last
+}
@@ -0,0 +1,70 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestSnapshotRecorder(t *testing.T) {
+	r := &snapshotRecorder{}
+	require.Nil(t, r.get())
+
+	devices := []*pluginapi.Device{
+		{
+			ID:     "GPU-0",
+			Health: pluginapi.Healthy,
+			Topology: &pluginapi.TopologyInfo{
+				Nodes: []*pluginapi.NUMANode{{ID: 1}},
+			},
+		},
+		{
+			ID:     "GPU-1",
+			Health: pluginapi.Healthy,
+		},
+	}
+	r.record(devices)
+
+	// Updating the health after the devices were sent must not change the snapshot.
+	devices[1].Health = pluginapi.Unhealthy
+
+	snapshot := r.get()
+	require.NotNil(t, snapshot)
+	require.False(t, snapshot.Timestamp.IsZero())
+	require.Equal(t, []SnapshotDevice{
+		{ID: "GPU-0", Health: pluginapi.Healthy, NUMANodes: []int64{1}},
+		{ID: "GPU-1", Health: pluginapi.Healthy},
+	}, snapshot.Devices)
+}
+
+func TestSnapshotRecorderSubscribe(t *testing.T) {
+	r := &snapshotRecorder{}
+	notifications, unsubscribe := r.subscribe()
+
+	// Notifications are coalesced until the subscriber reads them.
+	r.record([]*pluginapi.Device{{ID: "GPU-0", Health: pluginapi.Healthy}})
+	r.record([]*pluginapi.Device{{ID: "GPU-0", Health: pluginapi.Unhealthy}})
+	require.Len(t, notifications, 1)
+	<-notifications
+
+	unsubscribe()
+	r.record([]*pluginapi.Device{{ID: "GPU-0", Health: pluginapi.Healthy}})
+	require.Empty(t, notifications)
+}
@@ -0,0 +1,162 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+// threadPercentageTimeout bounds the time spent identifying the pod of an
+// Allocate request.
+const threadPercentageTimeout = 5 * time.Second
+
+// threadPercentageRequest reads the MPS thread percentage requested by the pod
+// of an Allocate request through the MPSThreadPercentageAnnotation.
+//
+// Since the kubelet does not identify the pod in an Allocate request, the pod
+// is taken to be a pending pod of the node that has containers requesting the
+// resource which are not listed by the PodResources API yet. If several such
+// pods do not agree on the annotation, it is ignored.
+type threadPercentageRequest struct {
+	resource spec.ResourceName
+	max      int
+}
+
+// get returns the requested thread percentage bounded by the maximum
+// configured for the resource, or an empty string if no percentage is
+// requested or the pod cannot be identified.
+func (r *threadPercentageRequest) get(allocating *allocatingPodList) string {
+	if r == nil {
+		return ""
+	}
+	pods, err := allocating.get()
+	if err != nil {
+		klog.Warningf("Failed to identify the pods allocating %v: %v", r.resource, err)
+		return ""
+	}
+	value, ok := allocatingPodAnnotation(pods, spec.MPSThreadPercentageAnnotation)
+	if !ok {
+		klog.Warningf("Pods pending allocation of %v disagree on the %v annotation; ignoring it", r.resource, spec.MPSThreadPercentageAnnotation)
+		return ""
+	}
+	if value == "" {
+		return ""
+	}
+	percentage, err := strconv.Atoi(value)
+	if err != nil || percentage < 1 {
+		klog.Warningf("Ignoring invalid %v annotation %q for %v", spec.MPSThreadPercentageAnnotation, value, r.resource)
+		return ""
+	}
+	if percentage > r.max {
+		klog.Warningf("Limiting the requested MPS thread percentage %d for %v to %d", percentage, r.resource, r.max)
+		percentage = r.max
+	}
+	return strconv.Itoa(percentage)
+}
+
+// allocatingPodAnnotation returns the value of an annotation of the specified
+// allocating pods. The annotation is missing if none of the pods sets it. If
+// the pods do not agree on the value, false is returned.
+func allocatingPodAnnotation(pods []podresources.PendingPod, annotation string) (string, bool) {
+	var value string
+	var found bool
+	for _, pod := range pods {
+		v := pod.Annotations[annotation]
+		if found && v != value {
+			return "", false
+		}
+		value, found = v, true
+	}
+	return value, true
+}
+
+// allocatingPodList lists the pods that may be allocating the resource in an
+// Allocate request. The pods are listed at most once, so that all checks of a
+// request share a single query of the API server and the PodResources API.
+type allocatingPodList struct {
+	resource spec.ResourceName
+	pending  PendingPodLister
+	lister   ContainerDevicesLister
+
+	once sync.Once
+	pods []podresources.PendingPod
+	err  error
+}
+
+func newAllocatingPodList(resource spec.ResourceName, pending PendingPodLister, lister ContainerDevicesLister) *allocatingPodList {
+	return &allocatingPodList{
+		resource: resource,
+		pending:  pending,
+		lister:   lister,
+	}
+}
+
+// get returns the pods that may be allocating the resource, as identified by
+// allocatingPods.
+func (l *allocatingPodList) get() ([]podresources.PendingPod, error) {
+	l.once.Do(func() {
+		l.pods, l.err = listAllocatingPods(l.resource, l.pending, l.lister)
+	})
+	return l.pods, l.err
+}
+
+// listAllocatingPods returns the pods that may be allocating the resource in
+// an Allocate request, as identified by allocatingPods.
+func listAllocatingPods(resource spec.ResourceName, pending PendingPodLister, lister ContainerDevicesLister) ([]podresources.PendingPod, error) {
+	if pending == nil || lister == nil {
+		return nil, fmt.Errorf("the PodResources API and access to the API server are required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), threadPercentageTimeout)
+	defer cancel()
+
+	pods, err := pending.PendingPods(ctx, string(resource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending pods: %w", err)
+	}
+	allocated, err := lister.AllocatedContainerDevices(ctx, string(resource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allocated devices: %w", err)
+	}
+	return allocatingPods(pods, allocated), nil
+}
+
+// allocatingPods returns the pending pods that have containers requesting the
+// resource which are not allocated devices yet.
+func allocatingPods(pending []podresources.PendingPod, allocated []podresources.ContainerDevices) []podresources.PendingPod {
+	containers := make(map[string]int)
+	for _, c := range allocated {
+		containers[c.Namespace+"/"+c.Pod]++
+	}
+
+	var pods []podresources.PendingPod
+	for _, pod := range pending {
+		if containers[pod.Namespace+"/"+pod.Name] >= pod.Containers {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods
+}
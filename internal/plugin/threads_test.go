@@ -0,0 +1,132 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+type fakePendingPodLister []podresources.PendingPod
+
+func (l fakePendingPodLister) PendingPods(context.Context, string) ([]podresources.PendingPod, error) {
+	return l, nil
+}
+
+func TestThreadPercentageRequest(t *testing.T) {
+	require.Equal(t, "", (*threadPercentageRequest)(nil).get(nil))
+
+	annotated := func(name string, value string, containers int) podresources.PendingPod {
+		return podresources.PendingPod{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: map[string]string{spec.MPSThreadPercentageAnnotation: value},
+			Containers:  containers,
+		}
+	}
+	allocated := fakeContainerDevicesLister{
+		{Namespace: "default", Pod: "started", Container: "main", DeviceIDs: []string{"GPU-0::0"}},
+	}
+
+	testCases := []struct {
+		description string
+		pending     fakePendingPodLister
+		expected    string
+	}{
+		{
+			description: "no pending pods",
+		},
+		{
+			description: "pod without annotation",
+			pending:     fakePendingPodLister{{Namespace: "default", Name: "plain", Containers: 1}},
+		},
+		{
+			description: "annotation of pending pod is used",
+			pending:     fakePendingPodLister{annotated("pod", "30", 1)},
+			expected:    "30",
+		},
+		{
+			description: "percentage is bounded by the maximum",
+			pending:     fakePendingPodLister{annotated("pod", "90", 1)},
+			expected:    "50",
+		},
+		{
+			description: "invalid percentage is ignored",
+			pending:     fakePendingPodLister{annotated("pod", "half", 1)},
+		},
+		{
+			description: "pods with allocated containers are skipped",
+			pending:     fakePendingPodLister{annotated("started", "10", 1), annotated("pod", "20", 1)},
+			expected:    "20",
+		},
+		{
+			description: "pods with containers left to allocate are kept",
+			pending:     fakePendingPodLister{annotated("started", "10", 2)},
+			expected:    "10",
+		},
+		{
+			description: "pods that agree on the annotation",
+			pending:     fakePendingPodLister{annotated("a", "25", 1), annotated("b", "25", 1)},
+			expected:    "25",
+		},
+		{
+			description: "pods that disagree on the annotation",
+			pending:     fakePendingPodLister{annotated("a", "25", 1), {Namespace: "default", Name: "b", Containers: 1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &threadPercentageRequest{
+				resource: "nvidia.com/gpu",
+				max:      50,
+			}
+			require.Equal(t, tc.expected, r.get(newAllocatingPodList("nvidia.com/gpu", tc.pending, allocated)))
+		})
+	}
+}
+
+type countingPendingPodLister struct {
+	fakePendingPodLister
+	calls int
+}
+
+func (l *countingPendingPodLister) PendingPods(ctx context.Context, resource string) ([]podresources.PendingPod, error) {
+	l.calls++
+	return l.fakePendingPodLister.PendingPods(ctx, resource)
+}
+
+func TestAllocatingPodList(t *testing.T) {
+	pending := &countingPendingPodLister{
+		fakePendingPodLister: fakePendingPodLister{{Namespace: "default", Name: "pod", Containers: 1}},
+	}
+	allocating := newAllocatingPodList("nvidia.com/gpu", pending, fakeContainerDevicesLister{})
+	for i := 0; i < 3; i++ {
+		pods, err := allocating.get()
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+	}
+	require.Equal(t, 1, pending.calls)
+
+	_, err := newAllocatingPodList("nvidia.com/gpu", nil, nil).get()
+	require.Error(t, err)
+}
@@ -0,0 +1,60 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// trustedWorkloadRequest checks whether the pod of an Allocate request is a
+// trusted workload that bypasses the MPS thread and memory limits of its
+// replicas.
+//
+// The pod is identified in the same way as for a threadPercentageRequest. If
+// several pods may be allocating the resource, the request cannot be attributed
+// to any of them and is not trusted.
+type trustedWorkloadRequest struct {
+	resource spec.ResourceName
+	trusted  *spec.TrustedWorkloads
+}
+
+// get returns whether the pod of an Allocate request is trusted. Unless
+// exactly one pod is allocating the resource, the request is not trusted.
+func (r *trustedWorkloadRequest) get(allocating *allocatingPodList) bool {
+	if r == nil {
+		return false
+	}
+	pods, err := allocating.get()
+	if err != nil {
+		klog.Warningf("Failed to identify the pods allocating %v: %v", r.resource, err)
+		return false
+	}
+	if len(pods) != 1 {
+		if len(pods) > 1 {
+			klog.Infof("Not lifting the MPS limits of %v: %d pods are allocating the resource", r.resource, len(pods))
+		}
+		return false
+	}
+	pod := pods[0]
+	if !r.trusted.Trusts(pod.Namespace, pod.ServiceAccount) {
+		return false
+	}
+	klog.Infof("Allocating %v to trusted workload %v/%v without MPS limits", r.resource, pod.Namespace, pod.Name)
+	return true
+}
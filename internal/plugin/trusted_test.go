@@ -0,0 +1,92 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/podresources"
+)
+
+func TestTrustedWorkloadRequest(t *testing.T) {
+	require.False(t, (*trustedWorkloadRequest)(nil).get(nil))
+
+	pod := func(namespace string, name string, serviceAccount string) podresources.PendingPod {
+		return podresources.PendingPod{
+			Namespace:      namespace,
+			Name:           name,
+			ServiceAccount: serviceAccount,
+			Containers:     1,
+		}
+	}
+	allocated := fakeContainerDevicesLister{
+		{Namespace: "default", Pod: "started", Container: "main", DeviceIDs: []string{"GPU-0::0"}},
+	}
+
+	testCases := []struct {
+		description string
+		pending     fakePendingPodLister
+		expected    bool
+	}{
+		{
+			description: "no pending pods",
+		},
+		{
+			description: "pod in a trusted namespace",
+			pending:     fakePendingPodLister{pod("monitoring", "agent", "default")},
+			expected:    true,
+		},
+		{
+			description: "pod with a trusted service account",
+			pending:     fakePendingPodLister{pod("profiling", "nsight", "nsight")},
+			expected:    true,
+		},
+		{
+			description: "service account of another namespace",
+			pending:     fakePendingPodLister{pod("default", "nsight", "nsight")},
+		},
+		{
+			description: "allocated pods are skipped",
+			pending:     fakePendingPodLister{pod("default", "started", "default"), pod("monitoring", "agent", "default")},
+			expected:    true,
+		},
+		{
+			description: "pods that are not all trusted",
+			pending:     fakePendingPodLister{pod("default", "job", "default"), pod("monitoring", "agent", "default")},
+		},
+		{
+			description: "several trusted pods",
+			pending:     fakePendingPodLister{pod("monitoring", "agent", "default"), pod("profiling", "nsight", "nsight")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &trustedWorkloadRequest{
+				resource: "nvidia.com/gpu",
+				trusted: &spec.TrustedWorkloads{
+					Namespaces:      []string{"monitoring"},
+					ServiceAccounts: []string{"profiling/nsight"},
+				},
+			}
+			require.Equal(t, tc.expected, r.get(newAllocatingPodList("nvidia.com/gpu", tc.pending, allocated)))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package podresources
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// AnnotationGetter gets the annotations of the pods listed by the PodResources
+// API from the API server.
+//
+// The pods of the node are watched through an informer that is started on
+// first use, so that looking up a pod does not require a request to the API
+// server.
+type AnnotationGetter struct {
+	client   kubernetes.Interface
+	nodeName string
+
+	startOnce sync.Once
+	pods      corelisters.PodLister
+	synced    cache.InformerSynced
+}
+
+// NewAnnotationGetter creates an annotation getter that uses the specified
+// client. The node name is used to list the pending pods of the node.
+func NewAnnotationGetter(client kubernetes.Interface, nodeName string) *AnnotationGetter {
+	return &AnnotationGetter{client: client, nodeName: nodeName}
+}
+
+// PodAnnotations returns the annotations of the specified pod. Pods that are
+// not known to the informer yet, or any pod if the node name is not set, are
+// read from the API server.
+func (g *AnnotationGetter) PodAnnotations(ctx context.Context, namespace, name string) (map[string]string, error) {
+	if g.nodeName != "" {
+		pods, err := g.podLister(ctx)
+		if err != nil {
+			return nil, err
+		}
+		pod, err := pods.Pods(namespace).Get(name)
+		if err == nil {
+			return pod.Annotations, nil
+		}
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get pod %v/%v: %w", namespace, name, err)
+		}
+	}
+	pod, err := g.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %v/%v: %w", namespace, name, err)
+	}
+	return pod.Annotations, nil
+}
+
+// PendingPod holds a pending pod of the node that requests a resource.
+type PendingPod struct {
+	Namespace      string
+	Name           string
+	ServiceAccount string
+	Annotations    map[string]string
+	// RuntimeClassName is the runtime class of the pod, or empty if the pod
+	// uses the default runtime of the node.
+	RuntimeClassName string
+	// Containers is the number of containers of the pod that request the
+	// resource.
+	Containers int
+	// Images are the images of the containers of the pod that request the
+	// resource.
+	Images []string
+}
+
+// PendingPods returns the pods that are scheduled to the node but not
+// running yet and that have containers requesting the specified resource.
+func (g *AnnotationGetter) PendingPods(ctx context.Context, resource string) ([]PendingPod, error) {
+	if g.nodeName == "" {
+		return nil, fmt.Errorf("node name is not set")
+	}
+	lister, err := g.podLister(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := lister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending pods of node %v: %w", g.nodeName, err)
+	}
+	return pendingPods(pods, resource), nil
+}
+
+// podLister starts the informer of the pods of the node if it is not started
+// yet and waits until it is synced.
+func (g *AnnotationGetter) podLister(ctx context.Context) (corelisters.PodLister, error) {
+	g.startOnce.Do(func() {
+		factory := informers.NewSharedInformerFactoryWithOptions(g.client, 0,
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", g.nodeName).String()
+			}),
+		)
+		informer := factory.Core().V1().Pods()
+		g.pods = informer.Lister()
+		g.synced = informer.Informer().HasSynced
+		factory.Start(wait.NeverStop)
+	})
+	if !cache.WaitForCacheSync(ctx.Done(), g.synced) {
+		return nil, fmt.Errorf("failed to sync the pods of node %v", g.nodeName)
+	}
+	return g.pods, nil
+}
+
+func pendingPods(pods []*corev1.Pod, resource string) []PendingPod {
+	var pending []PendingPod
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		var containers int
+		var images []string
+		for _, c := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			if limit, ok := c.Resources.Limits[corev1.ResourceName(resource)]; ok && !limit.IsZero() {
+				containers++
+				images = append(images, c.Image)
+			}
+		}
+		if containers == 0 {
+			continue
+		}
+		var runtimeClassName string
+		if pod.Spec.RuntimeClassName != nil {
+			runtimeClassName = *pod.Spec.RuntimeClassName
+		}
+		pending = append(pending, PendingPod{
+			Namespace:        pod.Namespace,
+			Name:             pod.Name,
+			ServiceAccount:   pod.Spec.ServiceAccountName,
+			Annotations:      pod.Annotations,
+			RuntimeClassName: runtimeClassName,
+			Containers:       containers,
+			Images:           images,
+		})
+	}
+	return pending
+}
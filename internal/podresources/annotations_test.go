@@ -0,0 +1,98 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package podresources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPendingPods(t *testing.T) {
+	requests := func(name string, count string) corev1.Container {
+		c := corev1.Container{Name: name, Image: "registry.example.com/" + name}
+		if count != "" {
+			c.Resources.Limits = corev1.ResourceList{"nvidia.com/gpu": resource.MustParse(count)}
+		}
+		return c
+	}
+	runtimeClass := "nvidia"
+	pods := []*corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gpu", Annotations: map[string]string{"a": "b"}},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "runner",
+				RuntimeClassName:   &runtimeClass,
+				InitContainers:     []corev1.Container{requests("init", "1")},
+				Containers:         []corev1.Container{requests("main", "1"), requests("sidecar", "")},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodPending},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "running"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{requests("main", "1")}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "zero"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{requests("main", "0")}},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cpu"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{requests("main", "")}},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		},
+	}
+
+	require.Equal(t, []PendingPod{
+		{Namespace: "default", Name: "gpu", ServiceAccount: "runner", Annotations: map[string]string{"a": "b"}, RuntimeClassName: "nvidia", Containers: 2, Images: []string{"registry.example.com/init", "registry.example.com/main"}},
+	}, pendingPods(pods, "nvidia.com/gpu"))
+	require.Empty(t, pendingPods(pods, "nvidia.com/mig-1g.5gb"))
+}
+
+func TestAnnotationGetter(t *testing.T) {
+	gpus := corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pending", Annotations: map[string]string{"a": "b"}},
+			Spec:       corev1.PodSpec{NodeName: "node", Containers: []corev1.Container{{Name: "main", Resources: corev1.ResourceRequirements{Limits: gpus}}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		},
+	)
+	getter := NewAnnotationGetter(client, "node")
+
+	pending, err := getter.PendingPods(context.Background(), "nvidia.com/gpu")
+	require.NoError(t, err)
+	require.Equal(t, []PendingPod{{Namespace: "default", Name: "pending", Annotations: map[string]string{"a": "b"}, Containers: 1, Images: []string{""}}}, pending)
+
+	annotations, err := getter.PodAnnotations(context.Background(), "default", "pending")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "b"}, annotations)
+
+	// Pods that are not known to the informer are read from the API server.
+	_, err = getter.PodAnnotations(context.Background(), "default", "missing")
+	require.Error(t, err)
+
+	_, err = NewAnnotationGetter(client, "").PendingPods(context.Background(), "nvidia.com/gpu")
+	require.EqualError(t, err, "node name is not set")
+}
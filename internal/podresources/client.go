@@ -0,0 +1,183 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package podresources
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+// DefaultSocket is the path of the kubelet PodResources socket.
+const DefaultSocket = "/var/lib/kubelet/pod-resources/kubelet.sock"
+
+// Client queries the kubelet PodResources API for the devices allocated to pods.
+type Client struct {
+	conn    *grpc.ClientConn
+	client  podresourcesapi.PodResourcesListerClient
+	timeout time.Duration
+}
+
+// NewClient creates a client for the PodResources API served on the specified socket.
+// The connection is established lazily on the first request.
+func NewClient(socket string) (*Client, error) {
+	conn, err := grpc.Dial(socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection to %v: %w", socket, err)
+	}
+	c := &Client{
+		conn:    conn,
+		client:  podresourcesapi.NewPodResourcesListerClient(conn),
+		timeout: 10 * time.Second,
+	}
+	return c, nil
+}
+
+// AllocatedDevices returns the set of device IDs of the specified resource
+// that are allocated to containers of pods known to the kubelet.
+func (c *Client) AllocatedDevices(ctx context.Context, resource string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod resources: %w", err)
+	}
+	return allocatedDevices(resp, resource), nil
+}
+
+// PodDevices holds the IDs of the devices of a resource that are allocated to
+// the containers of a pod.
+type PodDevices struct {
+	Namespace string
+	Name      string
+	DeviceIDs []string
+}
+
+// AllocatedPodDevices returns the device IDs of the specified resource that
+// are allocated to each pod known to the kubelet. Pods without devices of the
+// resource are omitted.
+func (c *Client) AllocatedPodDevices(ctx context.Context, resource string) ([]PodDevices, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod resources: %w", err)
+	}
+	return allocatedPodDevices(resp, resource), nil
+}
+
+// ContainerDevices holds the IDs of the devices of a resource that are
+// allocated to a container.
+type ContainerDevices struct {
+	Namespace string
+	Pod       string
+	Container string
+	DeviceIDs []string
+}
+
+// AllocatedContainerDevices returns the device IDs of the specified resource
+// that are allocated to each container known to the kubelet. Containers
+// without devices of the resource are omitted.
+func (c *Client) AllocatedContainerDevices(ctx context.Context, resource string) ([]ContainerDevices, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod resources: %w", err)
+	}
+	return allocatedContainerDevices(resp, resource), nil
+}
+
+// Close closes the connection to the kubelet.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func allocatedDevices(resp *podresourcesapi.ListPodResourcesResponse, resource string) map[string]bool {
+	allocated := make(map[string]bool)
+	for _, pod := range resp.GetPodResources() {
+		for _, container := range pod.GetContainers() {
+			for _, devices := range container.GetDevices() {
+				if devices.GetResourceName() != resource {
+					continue
+				}
+				for _, id := range devices.GetDeviceIds() {
+					allocated[id] = true
+				}
+			}
+		}
+	}
+	return allocated
+}
+
+func allocatedPodDevices(resp *podresourcesapi.ListPodResourcesResponse, resource string) []PodDevices {
+	var pods []PodDevices
+	for _, pod := range resp.GetPodResources() {
+		var ids []string
+		for _, container := range pod.GetContainers() {
+			for _, devices := range container.GetDevices() {
+				if devices.GetResourceName() != resource {
+					continue
+				}
+				ids = append(ids, devices.GetDeviceIds()...)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		pods = append(pods, PodDevices{Namespace: pod.GetNamespace(), Name: pod.GetName(), DeviceIDs: ids})
+	}
+	return pods
+}
+
+func allocatedContainerDevices(resp *podresourcesapi.ListPodResourcesResponse, resource string) []ContainerDevices {
+	var containers []ContainerDevices
+	for _, pod := range resp.GetPodResources() {
+		for _, container := range pod.GetContainers() {
+			var ids []string
+			for _, devices := range container.GetDevices() {
+				if devices.GetResourceName() != resource {
+					continue
+				}
+				ids = append(ids, devices.GetDeviceIds()...)
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			containers = append(containers, ContainerDevices{
+				Namespace: pod.GetNamespace(),
+				Pod:       pod.GetName(),
+				Container: container.GetName(),
+				DeviceIDs: ids,
+			})
+		}
+	}
+	return containers
+}
@@ -0,0 +1,91 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package podresources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+func TestAllocatedDevices(t *testing.T) {
+	resp := &podresourcesapi.ListPodResourcesResponse{
+		PodResources: []*podresourcesapi.PodResources{
+			{
+				Name: "pod-a",
+				Containers: []*podresourcesapi.ContainerResources{
+					{
+						Name: "ctr-1",
+						Devices: []*podresourcesapi.ContainerDevices{
+							{ResourceName: "nvidia.com/gpu", DeviceIds: []string{"GPU-0::0", "GPU-0::1"}},
+							{ResourceName: "example.com/nic", DeviceIds: []string{"nic-0"}},
+						},
+					},
+					{
+						Name: "ctr-2",
+						Devices: []*podresourcesapi.ContainerDevices{
+							{ResourceName: "nvidia.com/gpu", DeviceIds: []string{"GPU-1::0"}},
+						},
+					},
+				},
+			},
+			{
+				Name: "pod-b",
+			},
+		},
+	}
+
+	testCases := []struct {
+		description string
+		resource    string
+		expected    map[string]bool
+	}{
+		{
+			description: "devices of all containers are returned",
+			resource:    "nvidia.com/gpu",
+			expected:    map[string]bool{"GPU-0::0": true, "GPU-0::1": true, "GPU-1::0": true},
+		},
+		{
+			description: "other resources are ignored",
+			resource:    "example.com/nic",
+			expected:    map[string]bool{"nic-0": true},
+		},
+		{
+			description: "unknown resource is empty",
+			resource:    "nvidia.com/mig-1g.5gb",
+			expected:    map[string]bool{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.EqualValues(t, tc.expected, allocatedDevices(resp, tc.resource))
+		})
+	}
+
+	require.Equal(t, []PodDevices{
+		{Name: "pod-a", DeviceIDs: []string{"GPU-0::0", "GPU-0::1", "GPU-1::0"}},
+	}, allocatedPodDevices(resp, "nvidia.com/gpu"))
+	require.Empty(t, allocatedPodDevices(resp, "nvidia.com/mig-1g.5gb"))
+
+	require.Equal(t, []ContainerDevices{
+		{Pod: "pod-a", Container: "ctr-1", DeviceIDs: []string{"GPU-0::0", "GPU-0::1"}},
+		{Pod: "pod-a", Container: "ctr-2", DeviceIDs: []string{"GPU-1::0"}},
+	}, allocatedContainerDevices(resp, "nvidia.com/gpu"))
+	require.Empty(t, allocatedContainerDevices(resp, "nvidia.com/mig-1g.5gb"))
+}
@@ -37,6 +37,46 @@ func (d *cudaDevice) GetAttributes() (map[string]interface{}, error) {
 	return nil, fmt.Errorf("GetAttributes is not supported for CUDA devices")
 }
 
+// GetTemperature is unsupported for CUDA devices
+func (d *cudaDevice) GetTemperature() (int, error) {
+	return 0, fmt.Errorf("GetTemperature is %w for CUDA devices", ErrNotSupported)
+}
+
+// GetLinkCounters is unsupported for CUDA devices
+func (d *cudaDevice) GetLinkCounters() (*LinkCounters, error) {
+	return nil, fmt.Errorf("GetLinkCounters is %w for CUDA devices", ErrNotSupported)
+}
+
+// GetWear is unsupported for CUDA devices.
+func (d *cudaDevice) GetWear() (*Wear, error) {
+	return nil, fmt.Errorf("GetWear is %w for CUDA devices", ErrNotSupported)
+}
+
+// GetEccMode is unsupported for CUDA devices.
+func (d *cudaDevice) GetEccMode() (*EccMode, error) {
+	return nil, fmt.Errorf("GetEccMode is %w for CUDA devices", ErrNotSupported)
+}
+
+// GetPCIBusID is unsupported for CUDA devices
+func (d *cudaDevice) GetPCIBusID() (string, error) {
+	return "", fmt.Errorf("GetPCIBusID is %w for CUDA devices", ErrNotSupported)
+}
+
+// GetPerformanceState is unsupported for CUDA devices
+func (d *cudaDevice) GetPerformanceState() (*PerformanceState, error) {
+	return nil, fmt.Errorf("GetPerformanceState is %w for CUDA devices", ErrNotSupported)
+}
+
+// GetBAR1MemoryMB is unsupported for CUDA devices
+func (d *cudaDevice) GetBAR1MemoryMB() (uint64, error) {
+	return 0, fmt.Errorf("GetBAR1MemoryMB is %w for CUDA devices", ErrNotSupported)
+}
+
+// GetNumFans is unsupported for CUDA devices
+func (d *cudaDevice) GetNumFans() (int, error) {
+	return 0, fmt.Errorf("GetNumFans is %w for CUDA devices", ErrNotSupported)
+}
+
 // GetCudaComputeCapability returns the CUDA Compute Capability major and minor versions.
 // If the device is a MIG device (i.e. a compute instance) these are 0
 func (d *cudaDevice) GetCudaComputeCapability() (int, int, error) {
@@ -20,21 +20,48 @@ var _ Device = &DeviceMock{}
 //			GetAttributesFunc: func() (map[string]interface{}, error) {
 //				panic("mock out the GetAttributes method")
 //			},
+//			GetBAR1MemoryMBFunc: func() (uint64, error) {
+//				panic("mock out the GetBAR1MemoryMB method")
+//			},
 //			GetCudaComputeCapabilityFunc: func() (int, int, error) {
 //				panic("mock out the GetCudaComputeCapability method")
 //			},
 //			GetDeviceHandleFromMigDeviceHandleFunc: func() (Device, error) {
 //				panic("mock out the GetDeviceHandleFromMigDeviceHandle method")
 //			},
+//			GetEccModeFunc: func() (*EccMode, error) {
+//				panic("mock out the GetEccMode method")
+//			},
+//			GetLinkCountersFunc: func() (*LinkCounters, error) {
+//				panic("mock out the GetLinkCounters method")
+//			},
 //			GetMigDevicesFunc: func() ([]Device, error) {
 //				panic("mock out the GetMigDevices method")
 //			},
 //			GetNameFunc: func() (string, error) {
 //				panic("mock out the GetName method")
 //			},
+//			GetNumFansFunc: func() (int, error) {
+//				panic("mock out the GetNumFans method")
+//			},
+//			GetPCIBusIDFunc: func() (string, error) {
+//				panic("mock out the GetPCIBusID method")
+//			},
+//			GetPerformanceStateFunc: func() (*PerformanceState, error) {
+//				panic("mock out the GetPerformanceState method")
+//			},
+//			GetTemperatureFunc: func() (int, error) {
+//				panic("mock out the GetTemperature method")
+//			},
 //			GetTotalMemoryMBFunc: func() (uint64, error) {
 //				panic("mock out the GetTotalMemoryMB method")
 //			},
+//			GetUUIDFunc: func() (string, error) {
+//				panic("mock out the GetUUID method")
+//			},
+//			GetWearFunc: func() (*Wear, error) {
+//				panic("mock out the GetWear method")
+//			},
 //			IsMigCapableFunc: func() (bool, error) {
 //				panic("mock out the IsMigCapable method")
 //			},
@@ -51,21 +78,48 @@ type DeviceMock struct {
 	// GetAttributesFunc mocks the GetAttributes method.
 	GetAttributesFunc func() (map[string]interface{}, error)
 
+	// GetBAR1MemoryMBFunc mocks the GetBAR1MemoryMB method.
+	GetBAR1MemoryMBFunc func() (uint64, error)
+
 	// GetCudaComputeCapabilityFunc mocks the GetCudaComputeCapability method.
 	GetCudaComputeCapabilityFunc func() (int, int, error)
 
 	// GetDeviceHandleFromMigDeviceHandleFunc mocks the GetDeviceHandleFromMigDeviceHandle method.
 	GetDeviceHandleFromMigDeviceHandleFunc func() (Device, error)
 
+	// GetEccModeFunc mocks the GetEccMode method.
+	GetEccModeFunc func() (*EccMode, error)
+
+	// GetLinkCountersFunc mocks the GetLinkCounters method.
+	GetLinkCountersFunc func() (*LinkCounters, error)
+
 	// GetMigDevicesFunc mocks the GetMigDevices method.
 	GetMigDevicesFunc func() ([]Device, error)
 
 	// GetNameFunc mocks the GetName method.
 	GetNameFunc func() (string, error)
 
+	// GetNumFansFunc mocks the GetNumFans method.
+	GetNumFansFunc func() (int, error)
+
+	// GetPCIBusIDFunc mocks the GetPCIBusID method.
+	GetPCIBusIDFunc func() (string, error)
+
+	// GetPerformanceStateFunc mocks the GetPerformanceState method.
+	GetPerformanceStateFunc func() (*PerformanceState, error)
+
+	// GetTemperatureFunc mocks the GetTemperature method.
+	GetTemperatureFunc func() (int, error)
+
 	// GetTotalMemoryMBFunc mocks the GetTotalMemoryMB method.
 	GetTotalMemoryMBFunc func() (uint64, error)
 
+	// GetUUIDFunc mocks the GetUUID method.
+	GetUUIDFunc func() (string, error)
+
+	// GetWearFunc mocks the GetWear method.
+	GetWearFunc func() (*Wear, error)
+
 	// IsMigCapableFunc mocks the IsMigCapable method.
 	IsMigCapableFunc func() (bool, error)
 
@@ -77,21 +131,48 @@ type DeviceMock struct {
 		// GetAttributes holds details about calls to the GetAttributes method.
 		GetAttributes []struct {
 		}
+		// GetBAR1MemoryMB holds details about calls to the GetBAR1MemoryMB method.
+		GetBAR1MemoryMB []struct {
+		}
 		// GetCudaComputeCapability holds details about calls to the GetCudaComputeCapability method.
 		GetCudaComputeCapability []struct {
 		}
 		// GetDeviceHandleFromMigDeviceHandle holds details about calls to the GetDeviceHandleFromMigDeviceHandle method.
 		GetDeviceHandleFromMigDeviceHandle []struct {
 		}
+		// GetEccMode holds details about calls to the GetEccMode method.
+		GetEccMode []struct {
+		}
+		// GetLinkCounters holds details about calls to the GetLinkCounters method.
+		GetLinkCounters []struct {
+		}
 		// GetMigDevices holds details about calls to the GetMigDevices method.
 		GetMigDevices []struct {
 		}
 		// GetName holds details about calls to the GetName method.
 		GetName []struct {
 		}
+		// GetNumFans holds details about calls to the GetNumFans method.
+		GetNumFans []struct {
+		}
+		// GetPCIBusID holds details about calls to the GetPCIBusID method.
+		GetPCIBusID []struct {
+		}
+		// GetPerformanceState holds details about calls to the GetPerformanceState method.
+		GetPerformanceState []struct {
+		}
+		// GetTemperature holds details about calls to the GetTemperature method.
+		GetTemperature []struct {
+		}
 		// GetTotalMemoryMB holds details about calls to the GetTotalMemoryMB method.
 		GetTotalMemoryMB []struct {
 		}
+		// GetUUID holds details about calls to the GetUUID method.
+		GetUUID []struct {
+		}
+		// GetWear holds details about calls to the GetWear method.
+		GetWear []struct {
+		}
 		// IsMigCapable holds details about calls to the IsMigCapable method.
 		IsMigCapable []struct {
 		}
@@ -100,11 +181,20 @@ type DeviceMock struct {
 		}
 	}
 	lockGetAttributes                      sync.RWMutex
+	lockGetBAR1MemoryMB                    sync.RWMutex
 	lockGetCudaComputeCapability           sync.RWMutex
 	lockGetDeviceHandleFromMigDeviceHandle sync.RWMutex
+	lockGetEccMode                         sync.RWMutex
+	lockGetLinkCounters                    sync.RWMutex
 	lockGetMigDevices                      sync.RWMutex
 	lockGetName                            sync.RWMutex
+	lockGetNumFans                         sync.RWMutex
+	lockGetPCIBusID                        sync.RWMutex
+	lockGetPerformanceState                sync.RWMutex
+	lockGetTemperature                     sync.RWMutex
 	lockGetTotalMemoryMB                   sync.RWMutex
+	lockGetUUID                            sync.RWMutex
+	lockGetWear                            sync.RWMutex
 	lockIsMigCapable                       sync.RWMutex
 	lockIsMigEnabled                       sync.RWMutex
 }
@@ -136,6 +226,33 @@ func (mock *DeviceMock) GetAttributesCalls() []struct {
 	return calls
 }
 
+// GetBAR1MemoryMB calls GetBAR1MemoryMBFunc.
+func (mock *DeviceMock) GetBAR1MemoryMB() (uint64, error) {
+	if mock.GetBAR1MemoryMBFunc == nil {
+		panic("DeviceMock.GetBAR1MemoryMBFunc: method is nil but Device.GetBAR1MemoryMB was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetBAR1MemoryMB.Lock()
+	mock.calls.GetBAR1MemoryMB = append(mock.calls.GetBAR1MemoryMB, callInfo)
+	mock.lockGetBAR1MemoryMB.Unlock()
+	return mock.GetBAR1MemoryMBFunc()
+}
+
+// GetBAR1MemoryMBCalls gets all the calls that were made to GetBAR1MemoryMB.
+// Check the length with:
+//
+//	len(mockedDevice.GetBAR1MemoryMBCalls())
+func (mock *DeviceMock) GetBAR1MemoryMBCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetBAR1MemoryMB.RLock()
+	calls = mock.calls.GetBAR1MemoryMB
+	mock.lockGetBAR1MemoryMB.RUnlock()
+	return calls
+}
+
 // GetCudaComputeCapability calls GetCudaComputeCapabilityFunc.
 func (mock *DeviceMock) GetCudaComputeCapability() (int, int, error) {
 	if mock.GetCudaComputeCapabilityFunc == nil {
@@ -190,6 +307,60 @@ func (mock *DeviceMock) GetDeviceHandleFromMigDeviceHandleCalls() []struct {
 	return calls
 }
 
+// GetEccMode calls GetEccModeFunc.
+func (mock *DeviceMock) GetEccMode() (*EccMode, error) {
+	if mock.GetEccModeFunc == nil {
+		panic("DeviceMock.GetEccModeFunc: method is nil but Device.GetEccMode was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetEccMode.Lock()
+	mock.calls.GetEccMode = append(mock.calls.GetEccMode, callInfo)
+	mock.lockGetEccMode.Unlock()
+	return mock.GetEccModeFunc()
+}
+
+// GetEccModeCalls gets all the calls that were made to GetEccMode.
+// Check the length with:
+//
+//	len(mockedDevice.GetEccModeCalls())
+func (mock *DeviceMock) GetEccModeCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetEccMode.RLock()
+	calls = mock.calls.GetEccMode
+	mock.lockGetEccMode.RUnlock()
+	return calls
+}
+
+// GetLinkCounters calls GetLinkCountersFunc.
+func (mock *DeviceMock) GetLinkCounters() (*LinkCounters, error) {
+	if mock.GetLinkCountersFunc == nil {
+		panic("DeviceMock.GetLinkCountersFunc: method is nil but Device.GetLinkCounters was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetLinkCounters.Lock()
+	mock.calls.GetLinkCounters = append(mock.calls.GetLinkCounters, callInfo)
+	mock.lockGetLinkCounters.Unlock()
+	return mock.GetLinkCountersFunc()
+}
+
+// GetLinkCountersCalls gets all the calls that were made to GetLinkCounters.
+// Check the length with:
+//
+//	len(mockedDevice.GetLinkCountersCalls())
+func (mock *DeviceMock) GetLinkCountersCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetLinkCounters.RLock()
+	calls = mock.calls.GetLinkCounters
+	mock.lockGetLinkCounters.RUnlock()
+	return calls
+}
+
 // GetMigDevices calls GetMigDevicesFunc.
 func (mock *DeviceMock) GetMigDevices() ([]Device, error) {
 	if mock.GetMigDevicesFunc == nil {
@@ -244,6 +415,114 @@ func (mock *DeviceMock) GetNameCalls() []struct {
 	return calls
 }
 
+// GetNumFans calls GetNumFansFunc.
+func (mock *DeviceMock) GetNumFans() (int, error) {
+	if mock.GetNumFansFunc == nil {
+		panic("DeviceMock.GetNumFansFunc: method is nil but Device.GetNumFans was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetNumFans.Lock()
+	mock.calls.GetNumFans = append(mock.calls.GetNumFans, callInfo)
+	mock.lockGetNumFans.Unlock()
+	return mock.GetNumFansFunc()
+}
+
+// GetNumFansCalls gets all the calls that were made to GetNumFans.
+// Check the length with:
+//
+//	len(mockedDevice.GetNumFansCalls())
+func (mock *DeviceMock) GetNumFansCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetNumFans.RLock()
+	calls = mock.calls.GetNumFans
+	mock.lockGetNumFans.RUnlock()
+	return calls
+}
+
+// GetPCIBusID calls GetPCIBusIDFunc.
+func (mock *DeviceMock) GetPCIBusID() (string, error) {
+	if mock.GetPCIBusIDFunc == nil {
+		panic("DeviceMock.GetPCIBusIDFunc: method is nil but Device.GetPCIBusID was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetPCIBusID.Lock()
+	mock.calls.GetPCIBusID = append(mock.calls.GetPCIBusID, callInfo)
+	mock.lockGetPCIBusID.Unlock()
+	return mock.GetPCIBusIDFunc()
+}
+
+// GetPCIBusIDCalls gets all the calls that were made to GetPCIBusID.
+// Check the length with:
+//
+//	len(mockedDevice.GetPCIBusIDCalls())
+func (mock *DeviceMock) GetPCIBusIDCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetPCIBusID.RLock()
+	calls = mock.calls.GetPCIBusID
+	mock.lockGetPCIBusID.RUnlock()
+	return calls
+}
+
+// GetPerformanceState calls GetPerformanceStateFunc.
+func (mock *DeviceMock) GetPerformanceState() (*PerformanceState, error) {
+	if mock.GetPerformanceStateFunc == nil {
+		panic("DeviceMock.GetPerformanceStateFunc: method is nil but Device.GetPerformanceState was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetPerformanceState.Lock()
+	mock.calls.GetPerformanceState = append(mock.calls.GetPerformanceState, callInfo)
+	mock.lockGetPerformanceState.Unlock()
+	return mock.GetPerformanceStateFunc()
+}
+
+// GetPerformanceStateCalls gets all the calls that were made to GetPerformanceState.
+// Check the length with:
+//
+//	len(mockedDevice.GetPerformanceStateCalls())
+func (mock *DeviceMock) GetPerformanceStateCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetPerformanceState.RLock()
+	calls = mock.calls.GetPerformanceState
+	mock.lockGetPerformanceState.RUnlock()
+	return calls
+}
+
+// GetTemperature calls GetTemperatureFunc.
+func (mock *DeviceMock) GetTemperature() (int, error) {
+	if mock.GetTemperatureFunc == nil {
+		panic("DeviceMock.GetTemperatureFunc: method is nil but Device.GetTemperature was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetTemperature.Lock()
+	mock.calls.GetTemperature = append(mock.calls.GetTemperature, callInfo)
+	mock.lockGetTemperature.Unlock()
+	return mock.GetTemperatureFunc()
+}
+
+// GetTemperatureCalls gets all the calls that were made to GetTemperature.
+// Check the length with:
+//
+//	len(mockedDevice.GetTemperatureCalls())
+func (mock *DeviceMock) GetTemperatureCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetTemperature.RLock()
+	calls = mock.calls.GetTemperature
+	mock.lockGetTemperature.RUnlock()
+	return calls
+}
+
 // GetTotalMemoryMB calls GetTotalMemoryMBFunc.
 func (mock *DeviceMock) GetTotalMemoryMB() (uint64, error) {
 	if mock.GetTotalMemoryMBFunc == nil {
@@ -271,6 +550,60 @@ func (mock *DeviceMock) GetTotalMemoryMBCalls() []struct {
 	return calls
 }
 
+// GetUUID calls GetUUIDFunc.
+func (mock *DeviceMock) GetUUID() (string, error) {
+	if mock.GetUUIDFunc == nil {
+		panic("DeviceMock.GetUUIDFunc: method is nil but Device.GetUUID was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetUUID.Lock()
+	mock.calls.GetUUID = append(mock.calls.GetUUID, callInfo)
+	mock.lockGetUUID.Unlock()
+	return mock.GetUUIDFunc()
+}
+
+// GetUUIDCalls gets all the calls that were made to GetUUID.
+// Check the length with:
+//
+//	len(mockedDevice.GetUUIDCalls())
+func (mock *DeviceMock) GetUUIDCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetUUID.RLock()
+	calls = mock.calls.GetUUID
+	mock.lockGetUUID.RUnlock()
+	return calls
+}
+
+// GetWear calls GetWearFunc.
+func (mock *DeviceMock) GetWear() (*Wear, error) {
+	if mock.GetWearFunc == nil {
+		panic("DeviceMock.GetWearFunc: method is nil but Device.GetWear was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetWear.Lock()
+	mock.calls.GetWear = append(mock.calls.GetWear, callInfo)
+	mock.lockGetWear.Unlock()
+	return mock.GetWearFunc()
+}
+
+// GetWearCalls gets all the calls that were made to GetWear.
+// Check the length with:
+//
+//	len(mockedDevice.GetWearCalls())
+func (mock *DeviceMock) GetWearCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetWear.RLock()
+	calls = mock.calls.GetWear
+	mock.lockGetWear.RUnlock()
+	return calls
+}
+
 // IsMigCapable calls IsMigCapableFunc.
 func (mock *DeviceMock) IsMigCapable() (bool, error) {
 	if mock.IsMigCapableFunc == nil {
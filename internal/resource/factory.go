@@ -31,8 +31,13 @@ func NewManager(infolib info.Interface, nvmllib nvml.Interface, devicelib device
 }
 
 // WithConfig modifies a manager depending on the specified config.
+// If devices are filtered by the config, the manager is wrapped to only return the selected devices.
 // If failure on a call to init is allowed, the manager is wrapped to allow fallback to a Null manager.
 func WithConfig(manager Manager, config *spec.Config) Manager {
+	if config.Devices.HasFilters() {
+		manager = NewDeviceFilter(manager, config.Devices)
+	}
+
 	if *config.Flags.FailOnInitError {
 		return manager
 	}
@@ -0,0 +1,83 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package resource
+
+import (
+	"errors"
+	"fmt"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+type withDeviceFilter struct {
+	Manager
+	filter *spec.Devices
+}
+
+// NewDeviceFilter creates a manager that only returns the devices that pass
+// the include and exclude filters of the specified config. The MIG devices of
+// a GPU are returned together with it.
+func NewDeviceFilter(m Manager, filter *spec.Devices) Manager {
+	return &withDeviceFilter{
+		Manager: m,
+		filter:  filter,
+	}
+}
+
+// GetDevices returns the devices of the wrapped manager that are selected by
+// the filters.
+func (m *withDeviceFilter) GetDevices() ([]Device, error) {
+	devices, err := m.Manager.GetDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []Device
+	for i, d := range devices {
+		id, err := getDeviceIdentifiers(i, d)
+		if err != nil {
+			return nil, err
+		}
+		if m.filter.Selects(id) {
+			selected = append(selected, d)
+		}
+	}
+	return selected, nil
+}
+
+// getDeviceIdentifiers returns the identifiers of the device at the specified
+// index. Identifiers that are not supported by the device are left empty.
+func getDeviceIdentifiers(i int, d Device) (spec.DeviceIdentifiers, error) {
+	name, err := d.GetName()
+	if err != nil {
+		return spec.DeviceIdentifiers{}, fmt.Errorf("error getting name of device %d: %w", i, err)
+	}
+	uuid, err := d.GetUUID()
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		return spec.DeviceIdentifiers{}, fmt.Errorf("error getting UUID of device %d: %w", i, err)
+	}
+	busID, err := d.GetPCIBusID()
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		return spec.DeviceIdentifiers{}, fmt.Errorf("error getting PCI bus ID of device %d: %w", i, err)
+	}
+	return spec.DeviceIdentifiers{
+		Index:    i,
+		UUID:     uuid,
+		PCIBusID: busID,
+		Name:     name,
+	}, nil
+}
@@ -0,0 +1,85 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestDeviceFilter(t *testing.T) {
+	newDevice := func(name, uuid, busID string) *DeviceMock {
+		return &DeviceMock{
+			GetNameFunc:     func() (string, error) { return name, nil },
+			GetUUIDFunc:     func() (string, error) { return uuid, nil },
+			GetPCIBusIDFunc: func() (string, error) { return busID, nil },
+		}
+	}
+	a100 := newDevice("NVIDIA A100-SXM4-40GB", "GPU-0", "00000000:07:00.0")
+	t4 := newDevice("Tesla T4", "GPU-1", "00000000:3B:00.0")
+	h100 := newDevice("NVIDIA H100 80GB HBM3", "GPU-2", "00000000:86:00.0")
+	vfio := &DeviceMock{
+		GetNameFunc:     func() (string, error) { return "GA100", nil },
+		GetUUIDFunc:     func() (string, error) { return "", ErrNotSupported },
+		GetPCIBusIDFunc: func() (string, error) { return "0000:3b:00.0", nil },
+	}
+
+	testCases := []struct {
+		description string
+		devices     []Device
+		filter      *spec.Devices
+		expected    []Device
+	}{
+		{
+			description: "include by model name",
+			devices:     []Device{a100, t4, h100},
+			filter:      &spec.Devices{Include: []spec.DeviceFilter{"NVIDIA*"}},
+			expected:    []Device{a100, h100},
+		},
+		{
+			description: "exclude by index and PCI bus ID",
+			devices:     []Device{a100, t4, h100},
+			filter:      &spec.Devices{Exclude: []spec.DeviceFilter{"0", "86:00.0"}},
+			expected:    []Device{t4},
+		},
+		{
+			description: "include by UUID",
+			devices:     []Device{a100, t4, h100},
+			filter:      &spec.Devices{Include: []spec.DeviceFilter{"GPU-1"}},
+			expected:    []Device{t4},
+		},
+		{
+			description: "devices without UUIDs are matched by PCI bus ID",
+			devices:     []Device{vfio},
+			filter:      &spec.Devices{Exclude: []spec.DeviceFilter{"3b:00.0"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			m := &ManagerMock{
+				GetDevicesFunc: func() ([]Device, error) { return tc.devices, nil },
+			}
+			devices, err := NewDeviceFilter(m, tc.filter).GetDevices()
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, devices)
+		})
+	}
+}
@@ -17,7 +17,10 @@
 package resource
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
@@ -59,6 +62,248 @@ func (d nvmlDevice) GetCudaComputeCapability() (int, int, error) {
 	return major, minor, nil
 }
 
+// GetTemperature returns the current GPU core temperature in degrees Celsius.
+func (d nvmlDevice) GetTemperature() (int, error) {
+	temperature, ret := d.Device.GetTemperature(nvml.TEMPERATURE_GPU)
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return 0, fmt.Errorf("%w: %v", ErrNotSupported, ret)
+	}
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return int(temperature), nil
+}
+
+// GetNumFans returns the number of fans on the device.
+// Passively cooled devices that do not support fan queries have no fans.
+func (d nvmlDevice) GetNumFans() (int, error) {
+	fans, ret := d.Device.GetNumFans()
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return 0, nil
+	}
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return fans, nil
+}
+
+// GetLinkCounters returns the error counters of the PCIe and NVLink
+// interconnects of the device.
+func (d nvmlDevice) GetLinkCounters() (*LinkCounters, error) {
+	values := []nvml.FieldValue{
+		{FieldId: nvml.FI_DEV_PCIE_REPLAY_COUNTER},
+		{FieldId: nvml.FI_DEV_NVLINK_CRC_DATA_ERROR_COUNT_TOTAL},
+		{FieldId: nvml.FI_DEV_NVLINK_REPLAY_ERROR_COUNT_TOTAL},
+		{FieldId: nvml.FI_DEV_NVLINK_RECOVERY_ERROR_COUNT_TOTAL},
+		// A scope of all ones sums up the throughput of all links.
+		{FieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, ScopeId: math.MaxUint32},
+		{FieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX, ScopeId: math.MaxUint32},
+	}
+	ret := d.Device.GetFieldValues(values)
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return nil, fmt.Errorf("%w: %v", ErrNotSupported, ret)
+	}
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+
+	pcieReplays, err := fieldValueUint64(values[0])
+	if err != nil {
+		return nil, fmt.Errorf("error getting PCIe replay counter: %w", err)
+	}
+	counters := &LinkCounters{
+		PCIeReplays: pcieReplays,
+	}
+
+	var nvlinkErrors uint64
+	for _, v := range values[1:4] {
+		count, err := fieldValueUint64(v)
+		if errors.Is(err, ErrNotSupported) {
+			return counters, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting NVLink error counter: %w", err)
+		}
+		nvlinkErrors += count
+	}
+	counters.NVLinkErrors = &nvlinkErrors
+
+	var throughput uint64
+	for _, v := range values[4:] {
+		kib, err := fieldValueUint64(v)
+		if errors.Is(err, ErrNotSupported) {
+			return counters, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting NVLink throughput counter: %w", err)
+		}
+		throughput += kib
+	}
+	counters.NVLinkThroughputKiB = &throughput
+	return counters, nil
+}
+
+// GetWear returns the retired page, remapped row, and energy counters of the
+// device. Counters that are not supported by the device are omitted.
+func (d nvmlDevice) GetWear() (*Wear, error) {
+	values := []nvml.FieldValue{
+		{FieldId: nvml.FI_DEV_RETIRED_SBE},
+		{FieldId: nvml.FI_DEV_RETIRED_DBE},
+		{FieldId: nvml.FI_DEV_REMAPPED_COR},
+		{FieldId: nvml.FI_DEV_REMAPPED_UNC},
+		{FieldId: nvml.FI_DEV_TOTAL_ENERGY_CONSUMPTION},
+	}
+	ret := d.Device.GetFieldValues(values)
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return nil, fmt.Errorf("%w: %v", ErrNotSupported, ret)
+	}
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+
+	retiredPages, err := sumFieldValues(values[0:2])
+	if err != nil {
+		return nil, fmt.Errorf("error getting retired pages: %w", err)
+	}
+	remappedRows, err := sumFieldValues(values[2:4])
+	if err != nil {
+		return nil, fmt.Errorf("error getting remapped rows: %w", err)
+	}
+	energy, err := sumFieldValues(values[4:5])
+	if err != nil {
+		return nil, fmt.Errorf("error getting total energy consumption: %w", err)
+	}
+	return &Wear{
+		RetiredPages:      retiredPages,
+		RemappedRows:      remappedRows,
+		EnergyMillijoules: energy,
+	}, nil
+}
+
+// GetEccMode returns the current and pending ECC mode of the device.
+func (d nvmlDevice) GetEccMode() (*EccMode, error) {
+	current, pending, ret := d.Device.GetEccMode()
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return nil, fmt.Errorf("%w: %v", ErrNotSupported, ret)
+	}
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+	return &EccMode{
+		Current: current == nvml.FEATURE_ENABLED,
+		Pending: pending == nvml.FEATURE_ENABLED,
+	}, nil
+}
+
+// sumFieldValues returns the sum of the values of unsigned integer fields, or
+// nil if any of the fields is not supported.
+func sumFieldValues(values []nvml.FieldValue) (*uint64, error) {
+	var sum uint64
+	for _, v := range values {
+		value, err := fieldValueUint64(v)
+		if errors.Is(err, ErrNotSupported) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sum += value
+	}
+	return &sum, nil
+}
+
+// fieldValueUint64 returns the value of an unsigned integer field.
+func fieldValueUint64(v nvml.FieldValue) (uint64, error) {
+	switch ret := nvml.Return(v.NvmlReturn); ret {
+	case nvml.SUCCESS:
+	case nvml.ERROR_NOT_SUPPORTED:
+		return 0, fmt.Errorf("%w: %v", ErrNotSupported, ret)
+	default:
+		return 0, ret
+	}
+	switch nvml.ValueType(v.ValueType) {
+	case nvml.VALUE_TYPE_UNSIGNED_INT:
+		return uint64(binary.NativeEndian.Uint32(v.Value[:4])), nil
+	case nvml.VALUE_TYPE_UNSIGNED_LONG, nvml.VALUE_TYPE_UNSIGNED_LONG_LONG:
+		return binary.NativeEndian.Uint64(v.Value[:]), nil
+	default:
+		return 0, fmt.Errorf("unexpected value type %v for field %v", v.ValueType, v.FieldId)
+	}
+}
+
+// clocksThrottleReasons maps the reasons for reduced clocks reported by NVML
+// to the names used in the performance state of a device. The GPU idle reason
+// is omitted, since an idle GPU is not expected to run at maximum clocks.
+var clocksThrottleReasons = []struct {
+	mask uint64
+	name string
+}{
+	{nvml.ClocksThrottleReasonApplicationsClocksSetting, "applications-clocks-setting"},
+	{nvml.ClocksThrottleReasonSwPowerCap, "sw-power-cap"},
+	{nvml.ClocksThrottleReasonHwSlowdown, "hw-slowdown"},
+	{nvml.ClocksThrottleReasonSyncBoost, "sync-boost"},
+	{nvml.ClocksThrottleReasonSwThermalSlowdown, "sw-thermal-slowdown"},
+	{nvml.ClocksThrottleReasonHwThermalSlowdown, "hw-thermal-slowdown"},
+	{nvml.ClocksThrottleReasonHwPowerBrakeSlowdown, "power-brake"},
+	{nvml.ClocksThrottleReasonDisplayClockSetting, "display-clock-setting"},
+}
+
+// GetPerformanceState returns the current performance state of the device,
+// its utilization, and the reasons for which its clocks are reduced. A PCIe
+// link that runs below its maximum generation or width is reported as the
+// "pcie-downtrained" reason.
+func (d nvmlDevice) GetPerformanceState() (*PerformanceState, error) {
+	pstate, ret := d.Device.GetPerformanceState()
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return nil, fmt.Errorf("%w: %v", ErrNotSupported, ret)
+	}
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+	utilization, ret := d.Device.GetUtilizationRates()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting utilization: %v", ret)
+	}
+	state := &PerformanceState{
+		PState:      int(pstate),
+		Utilization: int(utilization.Gpu),
+	}
+
+	reasons, ret := d.Device.GetCurrentClocksThrottleReasons()
+	if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+		return nil, fmt.Errorf("error getting clock throttle reasons: %v", ret)
+	}
+	for _, r := range clocksThrottleReasons {
+		if reasons&r.mask != 0 {
+			state.Reasons = append(state.Reasons, r.name)
+		}
+	}
+	if d.isPCIeDowntrained() {
+		state.Reasons = append(state.Reasons, "pcie-downtrained")
+	}
+	return state, nil
+}
+
+// isPCIeDowntrained returns whether the PCIe link of the device runs below its
+// maximum generation or width. Links whose state cannot be queried are not
+// considered downtrained.
+func (d nvmlDevice) isPCIeDowntrained() bool {
+	current, ret := d.Device.GetCurrPcieLinkGeneration()
+	if ret != nvml.SUCCESS {
+		return false
+	}
+	maximum, ret := d.Device.GetMaxPcieLinkGeneration()
+	if ret == nvml.SUCCESS && current < maximum {
+		return true
+	}
+	current, ret = d.Device.GetCurrPcieLinkWidth()
+	if ret != nvml.SUCCESS {
+		return false
+	}
+	maximum, ret = d.Device.GetMaxPcieLinkWidth()
+	return ret == nvml.SUCCESS && current < maximum
+}
+
 // GetAttributes is only supported for MIG devices.
 func (d nvmlDevice) GetAttributes() (map[string]interface{}, error) {
 	return nil, fmt.Errorf("GetAttributes is not supported for non-MIG devices")
@@ -78,6 +323,31 @@ func (d nvmlDevice) GetName() (string, error) {
 	return name, nil
 }
 
+// GetUUID returns the UUID of the device.
+func (d nvmlDevice) GetUUID() (string, error) {
+	uuid, ret := d.Device.GetUUID()
+	if ret != nvml.SUCCESS {
+		return "", ret
+	}
+	return uuid, nil
+}
+
+// GetPCIBusID returns the PCI bus ID of the device.
+func (d nvmlDevice) GetPCIBusID() (string, error) {
+	info, ret := d.Device.GetPciInfo()
+	if ret != nvml.SUCCESS {
+		return "", ret
+	}
+	var busID []byte
+	for _, c := range info.BusId {
+		if c == 0 {
+			break
+		}
+		busID = append(busID, byte(c))
+	}
+	return string(busID), nil
+}
+
 // GetTotalMemoryMB returns the total memory on a device in MB
 func (d nvmlDevice) GetTotalMemoryMB() (uint64, error) {
 	info, ret := d.Device.GetMemoryInfo()
@@ -86,3 +356,15 @@ func (d nvmlDevice) GetTotalMemoryMB() (uint64, error) {
 	}
 	return info.Total / (1024 * 1024), nil
 }
+
+// GetBAR1MemoryMB returns the size of the BAR1 memory of a device in MB
+func (d nvmlDevice) GetBAR1MemoryMB() (uint64, error) {
+	info, ret := d.Device.GetBAR1MemoryInfo()
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return 0, fmt.Errorf("GetBAR1MemoryInfo is %w for device", ErrNotSupported)
+	}
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return info.Bar1Total / (1024 * 1024), nil
+}
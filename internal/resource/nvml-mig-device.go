@@ -91,6 +91,46 @@ func (d nvmlMigDevice) GetCudaComputeCapability() (int, int, error) {
 	return 0, 0, fmt.Errorf("GetCudaComputeCapability is not supported for MIG devices")
 }
 
+// GetTemperature is not supported for MIG devices.
+func (d nvmlMigDevice) GetTemperature() (int, error) {
+	return 0, fmt.Errorf("GetTemperature is %w for MIG devices", ErrNotSupported)
+}
+
+// GetLinkCounters is not supported for MIG devices.
+func (d nvmlMigDevice) GetLinkCounters() (*LinkCounters, error) {
+	return nil, fmt.Errorf("GetLinkCounters is %w for MIG devices", ErrNotSupported)
+}
+
+// GetWear is not supported for MIG devices.
+func (d nvmlMigDevice) GetWear() (*Wear, error) {
+	return nil, fmt.Errorf("GetWear is %w for MIG devices", ErrNotSupported)
+}
+
+// GetEccMode is not supported for MIG devices.
+func (d nvmlMigDevice) GetEccMode() (*EccMode, error) {
+	return nil, fmt.Errorf("GetEccMode is %w for MIG devices", ErrNotSupported)
+}
+
+// GetPCIBusID is not supported for MIG devices.
+func (d nvmlMigDevice) GetPCIBusID() (string, error) {
+	return "", fmt.Errorf("GetPCIBusID is %w for MIG devices", ErrNotSupported)
+}
+
+// GetPerformanceState is not supported for MIG devices.
+func (d nvmlMigDevice) GetPerformanceState() (*PerformanceState, error) {
+	return nil, fmt.Errorf("GetPerformanceState is %w for MIG devices", ErrNotSupported)
+}
+
+// GetBAR1MemoryMB is not supported for MIG devices.
+func (d nvmlMigDevice) GetBAR1MemoryMB() (uint64, error) {
+	return 0, fmt.Errorf("GetBAR1MemoryMB is %w for MIG devices", ErrNotSupported)
+}
+
+// GetNumFans is not supported for MIG devices.
+func (d nvmlMigDevice) GetNumFans() (int, error) {
+	return 0, fmt.Errorf("GetNumFans is %w for MIG devices", ErrNotSupported)
+}
+
 // GetName returns the name of the nvmlMigDevice.
 // This is equal to the mig profile.
 func (d nvmlMigDevice) GetName() (string, error) {
@@ -103,6 +143,15 @@ func (d nvmlMigDevice) GetName() (string, error) {
 	return resourceName, nil
 }
 
+// GetUUID returns the UUID of the MIG device.
+func (d nvmlMigDevice) GetUUID() (string, error) {
+	uuid, ret := d.MigDevice.GetUUID()
+	if ret != nvml.SUCCESS {
+		return "", ret
+	}
+	return uuid, nil
+}
+
 // GetTotalMemoryMB returns the total memory on a device in MB
 func (d nvmlMigDevice) GetTotalMemoryMB() (uint64, error) {
 	attr, err := d.GetAttributes()
@@ -36,6 +36,36 @@ func (d vfioDevice) GetCudaComputeCapability() (int, int, error) {
 	return -1, -1, nil
 }
 
+// GetTemperature is not supported for GPU devices with vfio pci driver.
+func (d vfioDevice) GetTemperature() (int, error) {
+	return 0, fmt.Errorf("GetTemperature is %w for vfio devices", ErrNotSupported)
+}
+
+// GetLinkCounters is not supported for GPU devices with vfio pci driver.
+func (d vfioDevice) GetLinkCounters() (*LinkCounters, error) {
+	return nil, fmt.Errorf("GetLinkCounters is %w for vfio devices", ErrNotSupported)
+}
+
+// GetWear is not supported for GPU devices with vfio pci driver.
+func (d vfioDevice) GetWear() (*Wear, error) {
+	return nil, fmt.Errorf("GetWear is %w for vfio devices", ErrNotSupported)
+}
+
+// GetEccMode is not supported for GPU devices with vfio pci driver.
+func (d vfioDevice) GetEccMode() (*EccMode, error) {
+	return nil, fmt.Errorf("GetEccMode is %w for vfio devices", ErrNotSupported)
+}
+
+// GetPerformanceState is not supported for GPU devices with vfio pci driver.
+func (d vfioDevice) GetPerformanceState() (*PerformanceState, error) {
+	return nil, fmt.Errorf("GetPerformanceState is %w for vfio devices", ErrNotSupported)
+}
+
+// GetNumFans is not supported for GPU devices with vfio pci driver.
+func (d vfioDevice) GetNumFans() (int, error) {
+	return 0, fmt.Errorf("GetNumFans is %w for vfio devices", ErrNotSupported)
+}
+
 // GetAttributes is only supported for MIG devices.
 func (d vfioDevice) GetAttributes() (map[string]interface{}, error) {
 	return nil, fmt.Errorf("GetAttributes is not supported for non-MIG devices")
@@ -51,12 +81,31 @@ func (d vfioDevice) GetName() (string, error) {
 	return d.nvidiaPCIDevice.DeviceName, nil
 }
 
+// GetUUID is unsupported for vfio devices.
+func (d vfioDevice) GetUUID() (string, error) {
+	return "", ErrNotSupported
+}
+
+// GetPCIBusID returns the PCI address of the device.
+func (d vfioDevice) GetPCIBusID() (string, error) {
+	return d.nvidiaPCIDevice.Address, nil
+}
+
 // GetTotalMemoryMB returns the total memory on a device in MB
 func (d vfioDevice) GetTotalMemoryMB() (uint64, error) {
 	_, val := d.nvidiaPCIDevice.Resources.GetTotalAddressableMemory(true)
 	return val, nil
 }
 
+// GetBAR1MemoryMB returns the size of the BAR1 region of a device in MB
+func (d vfioDevice) GetBAR1MemoryMB() (uint64, error) {
+	bar1, exists := d.nvidiaPCIDevice.Resources[1]
+	if !exists {
+		return 0, fmt.Errorf("BAR1 is %w for vfio device", ErrNotSupported)
+	}
+	return uint64(bar1.End-bar1.Start+1) / (1024 * 1024), nil
+}
+
 func (d vfioDevice) IsMigEnabled() (bool, error) {
 	return false, nil
 }
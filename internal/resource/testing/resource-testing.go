@@ -48,9 +48,20 @@ func NewDeviceMock(migEnabled bool) *DeviceMock {
 			return 8, 0, nil
 		},
 		GetTotalMemoryMBFunc: func() (uint64, error) { return uint64(300), nil },
+		GetBAR1MemoryMBFunc:  func() (uint64, error) { return 0, resource.ErrNotSupported },
 		IsMigEnabledFunc:     func() (bool, error) { return migEnabled, nil },
 		IsMigCapableFunc:     func() (bool, error) { return migEnabled, nil },
 		GetMigDevicesFunc:    func() ([]resource.Device, error) { return nil, nil },
+		GetTemperatureFunc:   func() (int, error) { return 40, nil },
+		GetNumFansFunc:       func() (int, error) { return 1, nil },
+		GetLinkCountersFunc:  func() (*resource.LinkCounters, error) { return &resource.LinkCounters{}, nil },
+		GetPerformanceStateFunc: func() (*resource.PerformanceState, error) {
+			return &resource.PerformanceState{}, nil
+		},
+		GetUUIDFunc:     func() (string, error) { return "GPU-MOCK", nil },
+		GetPCIBusIDFunc: func() (string, error) { return "00000000:00:00.0", nil },
+		GetWearFunc:     func() (*resource.Wear, error) { return &resource.Wear{}, nil },
+		GetEccModeFunc:  func() (*resource.EccMode, error) { return nil, resource.ErrNotSupported },
 	}}
 	return &d
 }
@@ -16,6 +16,11 @@
 
 package resource
 
+import "errors"
+
+// ErrNotSupported is returned if a device does not support a query.
+var ErrNotSupported = errors.New("not supported")
+
 // Manager defines an interface for managing devices
 //
 //go:generate moq -out manager_mock.go . Manager
@@ -36,7 +41,66 @@ type Device interface {
 	GetMigDevices() ([]Device, error)
 	GetAttributes() (map[string]interface{}, error)
 	GetName() (string, error)
+	GetUUID() (string, error)
+	GetPCIBusID() (string, error)
 	GetTotalMemoryMB() (uint64, error)
+	GetBAR1MemoryMB() (uint64, error)
 	GetDeviceHandleFromMigDeviceHandle() (Device, error)
 	GetCudaComputeCapability() (int, int, error)
+	GetTemperature() (int, error)
+	GetNumFans() (int, error)
+	GetLinkCounters() (*LinkCounters, error)
+	GetPerformanceState() (*PerformanceState, error)
+	GetWear() (*Wear, error)
+	GetEccMode() (*EccMode, error)
+}
+
+// LinkCounters holds the cumulative error counters of the interconnects of a device.
+type LinkCounters struct {
+	// PCIeReplays is the number of PCIe replays.
+	PCIeReplays uint64
+	// NVLinkErrors is the total number of NVLink CRC, replay, and recovery
+	// errors across all links, or nil if the device has no NVLinks.
+	NVLinkErrors *uint64
+	// NVLinkThroughputKiB is the total amount of data in KiB transmitted and
+	// received across all links, or nil if the device has no NVLinks or does
+	// not support the throughput counters.
+	NVLinkThroughputKiB *uint64
+}
+
+// PerformanceState holds a sample of the performance state of a device.
+type PerformanceState struct {
+	// PState is the current performance state, from 0 (maximum performance)
+	// to 15 (minimum performance).
+	PState int
+	// Utilization is the percentage of time during the last sample period in
+	// which one or more kernels were executing on the device.
+	Utilization int
+	// Reasons lists the reasons for which the clocks of the device are
+	// currently reduced, such as "power-brake" or "hw-thermal-slowdown".
+	Reasons []string
+}
+
+// EccMode holds the ECC mode of a device. A change of the ECC mode only takes
+// effect after the next reboot of the device, until which the pending mode
+// differs from the current mode.
+type EccMode struct {
+	// Current indicates whether ECC is currently enabled.
+	Current bool
+	// Pending indicates whether ECC is enabled after the next reboot.
+	Pending bool
+}
+
+// Wear holds the counters of a device that indicate its age and wear. A
+// counter is nil if it is not supported by the device.
+type Wear struct {
+	// RetiredPages is the number of memory pages retired due to single-bit
+	// and double-bit ECC errors.
+	RetiredPages *uint64
+	// RemappedRows is the number of memory rows remapped due to correctable
+	// and uncorrectable errors.
+	RemappedRows *uint64
+	// EnergyMillijoules is the total energy consumed by the device since the
+	// driver was last loaded.
+	EnergyMillijoules *uint64
 }
@@ -18,13 +18,53 @@ package rm
 
 import (
 	"fmt"
+	"slices"
 	"sort"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 )
 
+// replicatedAlloc returns a list of devices that are selected according to
+// the allocation policy of the resource. If the link scores of the GPUs are
+// known, replicas of GPUs that are well connected to the GPUs already selected
+// are preferred among equally balanced candidates.
+func (r *resourceManager) replicatedAlloc(available, required []string, size int, scores linkScores) ([]string, error) {
+	switch r.config.Sharing.GetAllocationPolicy(r.resource) {
+	case spec.AllocationPolicyPacked:
+		return r.packedAlloc(available, required, size)
+	case spec.AllocationPolicyWeighted:
+		return r.weightedAlloc(available, required, size, scores)
+	default:
+		return r.distributedAlloc(available, required, size, scores)
+	}
+}
+
 // distributedAlloc returns a list of devices such that any replicated
 // devices are distributed across all replicated GPUs equally. It takes into
 // account already allocated replicas to ensure a proper balance across them.
-func (r *resourceManager) distributedAlloc(available, required []string, size int) ([]string, error) {
+func (r *resourceManager) distributedAlloc(available, required []string, size int, scores linkScores) ([]string, error) {
+	return r.balancedAlloc(available, required, size, scores, func(*Device) uint64 { return 1 })
+}
+
+// weightedAlloc returns a list of devices such that any replicated devices
+// are distributed across all replicated GPUs in proportion to the total
+// memory of the GPUs, so that GPUs with more memory are allocated more
+// replicas. If the memory of any of the GPUs is unknown, the replicas are
+// distributed equally.
+func (r *resourceManager) weightedAlloc(available, required []string, size int, scores linkScores) ([]string, error) {
+	for _, d := range r.devices {
+		if d.TotalMemory == 0 {
+			return r.distributedAlloc(available, required, size, scores)
+		}
+	}
+	return r.balancedAlloc(available, required, size, scores, func(d *Device) uint64 { return d.TotalMemory })
+}
+
+// balancedAlloc returns a list of devices such that the number of allocated
+// replicas of each GPU relative to the weight of the GPU is balanced. Ties are
+// broken in favor of the GPUs with the highest link score to the GPUs that are
+// already selected.
+func (r *resourceManager) balancedAlloc(available, required []string, size int, scores linkScores, weight func(*Device) uint64) ([]string, error) {
 	// Get the set of candidate devices as the difference between available and required.
 	candidates := r.devices.Subset(available).Difference(r.devices.Subset(required)).GetIDs()
 	needed := size - len(required)
@@ -34,12 +74,16 @@ func (r *resourceManager) distributedAlloc(available, required []string, size in
 	}
 
 	// For each candidate device, build a mapping of (stripped) device ID to
-	// total / available replicas for that device.
-	replicas := make(map[string]*struct{ total, available int })
+	// total / available replicas and the weight of that device.
+	type replicaCount struct {
+		total, available int
+		weight           uint64
+	}
+	replicas := make(map[string]*replicaCount)
 	for _, c := range candidates {
 		id := AnnotatedID(c).GetID()
 		if _, exists := replicas[id]; !exists {
-			replicas[id] = &struct{ total, available int }{}
+			replicas[id] = &replicaCount{weight: weight(r.devices[c])}
 		}
 		replicas[id].available++
 	}
@@ -55,20 +99,38 @@ func (r *resourceManager) distributedAlloc(available, required []string, size in
 	// Before selecting each candidate, first sort the candidate list using the
 	// replicas map above. After sorting, the first element in the list will
 	// contain the device with the least difference between total and available
-	// replications (based on what's already been allocated). Add this device
-	// to the list of devices to allocate, remove it from the candidate list,
-	// down its available count in the replicas map, and repeat.
+	// replications (based on what's already been allocated) relative to its
+	// weight. Add this device to the list of devices to allocate, remove it
+	// from the candidate list, down its available count in the replicas map,
+	// and repeat.
+	selected := make(map[string]bool)
+	for _, id := range required {
+		selected[AnnotatedID(id).GetID()] = true
+	}
 	var devices []string
 	for i := 0; i < needed; i++ {
+		affinity := make(map[string]int)
+		for id := range replicas {
+			affinity[id] = scores.to(id, selected)
+		}
 		sort.Slice(candidates, func(i, j int) bool {
-			iid := AnnotatedID(candidates[i]).GetID()
-			jid := AnnotatedID(candidates[j]).GetID()
-			idiff := replicas[iid].total - replicas[iid].available
-			jdiff := replicas[jid].total - replicas[jid].available
-			return idiff < jdiff
+			idi := AnnotatedID(candidates[i]).GetID()
+			idj := AnnotatedID(candidates[j]).GetID()
+			ri := replicas[idi]
+			rj := replicas[idj]
+			idiff := uint64(ri.total - ri.available)
+			jdiff := uint64(rj.total - rj.available)
+			if idiff*rj.weight != jdiff*ri.weight {
+				return idiff*rj.weight < jdiff*ri.weight
+			}
+			if affinity[idi] != affinity[idj] {
+				return affinity[idi] > affinity[idj]
+			}
+			return candidates[i] < candidates[j]
 		})
 		id := AnnotatedID(candidates[0]).GetID()
 		replicas[id].available--
+		selected[id] = true
 		devices = append(devices, candidates[0])
 		candidates = candidates[1:]
 	}
@@ -78,3 +140,154 @@ func (r *resourceManager) distributedAlloc(available, required []string, size in
 
 	return devices, nil
 }
+
+// maxScoredSets is the maximum number of sets of GPUs that are compared by
+// scoredAlloc before it falls back to selecting one GPU at a time.
+const maxScoredSets = 100000
+
+// scoredAlloc returns a list of full GPUs such that the sum of the link scores
+// of each pair of selected GPUs, including the required GPUs, is maximized.
+// Ties are broken in favor of the GPUs with the lowest IDs. If there are too
+// many sets of GPUs to compare, the GPU with the highest link score to the
+// GPUs that are already selected is selected one at a time instead.
+func (r *resourceManager) scoredAlloc(available, required []string, size int, scores linkScores) ([]string, error) {
+	// Get the set of candidate devices as the difference between available and required.
+	candidates := r.devices.Subset(available).Difference(r.devices.Subset(required)).GetIDs()
+	needed := size - len(required)
+
+	if len(candidates) < needed {
+		return nil, fmt.Errorf("not enough available devices to satisfy allocation")
+	}
+	sort.Strings(candidates)
+
+	selected := make(map[string]bool)
+	for _, id := range required {
+		selected[id] = true
+	}
+
+	var devices []string
+	if combinations(len(candidates), needed, maxScoredSets) > maxScoredSets {
+		for i := 0; i < needed; i++ {
+			best, bestScore := "", -1
+			for _, c := range candidates {
+				if selected[c] {
+					continue
+				}
+				if score := scores.to(c, selected); score > bestScore {
+					best, bestScore = c, score
+				}
+			}
+			selected[best] = true
+			devices = append(devices, best)
+		}
+		return slices.Concat(required, devices), nil
+	}
+
+	// Visit the sets of candidates in lexicographic order and keep the first
+	// set with the highest score.
+	bestScore := -1
+	var set []string
+	var visit func(start int, score int)
+	visit = func(start int, score int) {
+		if len(set) == needed {
+			if score > bestScore {
+				bestScore = score
+				devices = append([]string{}, set...)
+			}
+			return
+		}
+		for i := start; i <= len(candidates)-(needed-len(set)); i++ {
+			c := candidates[i]
+			added := scores.to(c, selected)
+			selected[c] = true
+			set = append(set, c)
+			visit(i+1, score+added)
+			set = set[:len(set)-1]
+			delete(selected, c)
+		}
+	}
+	visit(0, 0)
+
+	return slices.Concat(required, devices), nil
+}
+
+// combinations returns the number of ways to choose k of n items. Counting
+// stops as soon as the specified limit is exceeded.
+func combinations(n, k, limit int) int {
+	count := 1
+	for i := 0; i < k; i++ {
+		count = count * (n - i) / (i + 1)
+		if count > limit {
+			break
+		}
+	}
+	return count
+}
+
+// packedAlloc returns a list of devices such that the replicas are packed
+// onto as few GPUs as possible. This is used if the capacity of a shared
+// resource is expressed in milli-GPU units, where a request for 250 units
+// should be bound to a quarter of a single GPU instead of being spread across
+// multiple GPUs.
+func (r *resourceManager) packedAlloc(available, required []string, size int) ([]string, error) {
+	// Get the set of candidate devices as the difference between available and required.
+	candidates := r.devices.Subset(available).Difference(r.devices.Subset(required)).GetIDs()
+	needed := size - len(required)
+
+	if len(candidates) < needed {
+		return nil, fmt.Errorf("not enough available devices to satisfy allocation")
+	}
+
+	// Group the candidate replicas by their (stripped) device ID.
+	replicas := make(map[string][]string)
+	for _, c := range candidates {
+		id := AnnotatedID(c).GetID()
+		replicas[id] = append(replicas[id], c)
+	}
+	var ids []string
+	for id := range replicas {
+		sort.Strings(replicas[id])
+		ids = append(ids, id)
+	}
+
+	// Devices that already hold required replicas are used first. The
+	// remaining devices are ordered so that the device with the fewest
+	// available replicas that still satisfies the remaining request is
+	// selected (best fit). If no single device satisfies the remaining
+	// request, the device with the most available replicas is used.
+	isRequired := make(map[string]bool)
+	for _, id := range required {
+		isRequired[AnnotatedID(id).GetID()] = true
+	}
+
+	devices := append([]string{}, required...)
+	for needed > 0 {
+		remaining := needed
+		sort.Slice(ids, func(i, j int) bool {
+			ii, ij := ids[i], ids[j]
+			if isRequired[ii] != isRequired[ij] {
+				return isRequired[ii]
+			}
+			ni, nj := len(replicas[ii]), len(replicas[ij])
+			fitsi, fitsj := ni >= remaining, nj >= remaining
+			if fitsi != fitsj {
+				return fitsi
+			}
+			if ni != nj {
+				if fitsi {
+					return ni < nj
+				}
+				return ni > nj
+			}
+			return ii < ij
+		})
+
+		id := ids[0]
+		n := min(needed, len(replicas[id]))
+		devices = append(devices, replicas[id][:n]...)
+		needed -= n
+		ids = ids[1:]
+	}
+
+	return devices, nil
+}
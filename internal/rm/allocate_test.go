@@ -0,0 +1,316 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// newReplicatedDevices creates the specified number of replicas for each of the specified devices.
+func newReplicatedDevices(replicas int, ids ...string) Devices {
+	devices := make(Devices)
+	for _, id := range ids {
+		for i := 0; i < replicas; i++ {
+			annotated := fmt.Sprintf("%s::%d", id, i)
+			devices[annotated] = &Device{Device: pluginapi.Device{ID: annotated}, Replicas: replicas}
+		}
+	}
+	return devices
+}
+
+func TestPackedAlloc(t *testing.T) {
+	devices := newReplicatedDevices(4, "GPU-0", "GPU-1", "GPU-2")
+
+	testCases := []struct {
+		description   string
+		available     []string
+		required      []string
+		size          int
+		expected      []string
+		expectedError bool
+	}{
+		{
+			description: "request fits on the fullest device",
+			available:   []string{"GPU-0::0", "GPU-0::1", "GPU-0::2", "GPU-0::3", "GPU-1::0", "GPU-1::1", "GPU-2::3"},
+			size:        2,
+			expected:    []string{"GPU-1::0", "GPU-1::1"},
+		},
+		{
+			description: "request spans devices with the most available replicas",
+			available:   []string{"GPU-0::0", "GPU-0::1", "GPU-0::2", "GPU-0::3", "GPU-1::0", "GPU-1::1", "GPU-2::3"},
+			size:        6,
+			expected:    []string{"GPU-0::0", "GPU-0::1", "GPU-0::2", "GPU-0::3", "GPU-1::0", "GPU-1::1"},
+		},
+		{
+			description: "required replicas determine the device",
+			available:   []string{"GPU-0::0", "GPU-0::1", "GPU-0::2", "GPU-1::0", "GPU-1::1"},
+			required:    []string{"GPU-0::2"},
+			size:        3,
+			expected:    []string{"GPU-0::2", "GPU-0::0", "GPU-0::1"},
+		},
+		{
+			description:   "not enough available replicas",
+			available:     []string{"GPU-0::0", "GPU-1::0"},
+			size:          3,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &resourceManager{devices: devices}
+			allocated, err := r.packedAlloc(tc.available, tc.required, tc.size)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expected, allocated)
+		})
+	}
+}
+
+func TestReplicatedAlloc(t *testing.T) {
+	devices := newReplicatedDevices(8, "GPU-0", "GPU-1")
+	for id, d := range devices {
+		d.TotalMemory = 48 << 30
+		if AnnotatedID(id).GetID() == "GPU-0" {
+			d.TotalMemory = 80 << 30
+		}
+	}
+	// Three replicas of GPU-0 and two replicas of GPU-1 are allocated.
+	available := []string{
+		"GPU-0::3", "GPU-0::4", "GPU-0::5", "GPU-0::6", "GPU-0::7",
+		"GPU-1::2", "GPU-1::3", "GPU-1::4", "GPU-1::5", "GPU-1::6", "GPU-1::7",
+	}
+
+	testCases := []struct {
+		policy      spec.AllocationPolicy
+		size        int
+		expectedIDs []string
+	}{
+		{
+			policy:      spec.AllocationPolicySpread,
+			size:        1,
+			expectedIDs: []string{"GPU-1"},
+		},
+		{
+			policy:      spec.AllocationPolicyPacked,
+			size:        2,
+			expectedIDs: []string{"GPU-0", "GPU-0"},
+		},
+		{
+			policy:      spec.AllocationPolicyWeighted,
+			size:        1,
+			expectedIDs: []string{"GPU-0"},
+		},
+		{
+			policy:      spec.AllocationPolicyWeighted,
+			size:        3,
+			expectedIDs: []string{"GPU-0", "GPU-1", "GPU-0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v-%d", tc.policy, tc.size), func(t *testing.T) {
+			r := &resourceManager{
+				config:   &spec.Config{Sharing: spec.Sharing{AllocationPolicy: tc.policy}},
+				resource: "nvidia.com/gpu",
+				devices:  devices,
+			}
+			allocated, err := r.replicatedAlloc(available, nil, tc.size, nil)
+			require.NoError(t, err)
+			var ids []string
+			for _, id := range allocated {
+				ids = append(ids, AnnotatedID(id).GetID())
+			}
+			require.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
+
+func TestUniqueByID(t *testing.T) {
+	ids := AnnotatedIDs{"GPU-1::3", "GPU-0::1", "GPU-1::0", "GPU-0::2", "GPU-2"}
+	require.EqualValues(t, AnnotatedIDs{"GPU-1::3", "GPU-0::1", "GPU-2"}, ids.UniqueByID())
+}
+
+func TestDistributedAllocPrefersLinkedGPUs(t *testing.T) {
+	devices := newReplicatedDevices(2, "GPU-0", "GPU-1", "GPU-2", "GPU-3")
+	// GPU-0 and GPU-3 as well as GPU-1 and GPU-2 are connected by NVLink.
+	scores := linkScores{
+		"GPU-0": {"GPU-1": 20, "GPU-2": 20, "GPU-3": 400},
+		"GPU-1": {"GPU-0": 20, "GPU-2": 400, "GPU-3": 20},
+		"GPU-2": {"GPU-0": 20, "GPU-1": 400, "GPU-3": 20},
+		"GPU-3": {"GPU-0": 400, "GPU-1": 20, "GPU-2": 20},
+	}
+
+	testCases := []struct {
+		description string
+		required    []string
+		size        int
+		scores      linkScores
+		expectedIDs []string
+	}{
+		{
+			description: "without link scores",
+			size:        2,
+			expectedIDs: []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description: "linked GPUs are preferred",
+			size:        2,
+			scores:      scores,
+			expectedIDs: []string{"GPU-0", "GPU-3"},
+		},
+		{
+			description: "GPUs linked to required replicas are preferred",
+			required:    []string{"GPU-1::0"},
+			size:        2,
+			scores:      scores,
+			expectedIDs: []string{"GPU-1", "GPU-2"},
+		},
+		{
+			description: "balance takes precedence over links",
+			size:        4,
+			scores:      scores,
+			expectedIDs: []string{"GPU-0", "GPU-3", "GPU-1", "GPU-2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &resourceManager{
+				config:   &spec.Config{},
+				resource: "nvidia.com/gpu",
+				devices:  devices,
+			}
+			allocated, err := r.distributedAlloc(devices.GetIDs(), tc.required, tc.size, tc.scores)
+			require.NoError(t, err)
+			var ids []string
+			for _, id := range allocated {
+				ids = append(ids, AnnotatedID(id).GetID())
+			}
+			require.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
+
+func TestScoredAlloc(t *testing.T) {
+	devices := make(Devices)
+	for _, id := range []string{"GPU-0", "GPU-1", "GPU-2", "GPU-3"} {
+		devices[id] = &Device{Device: pluginapi.Device{ID: id}}
+	}
+	// GPU-0 and GPU-3 are connected by NVLink and GPU-1 and GPU-2 are
+	// attached to the same NUMA node.
+	scores := linkScores{
+		"GPU-0": {"GPU-1": 10, "GPU-2": 10, "GPU-3": 400},
+		"GPU-1": {"GPU-0": 10, "GPU-2": 20, "GPU-3": 10},
+		"GPU-2": {"GPU-0": 10, "GPU-1": 20, "GPU-3": 10},
+		"GPU-3": {"GPU-0": 400, "GPU-1": 10, "GPU-2": 10},
+	}
+
+	testCases := []struct {
+		description string
+		available   []string
+		required    []string
+		size        int
+		scores      linkScores
+		expected    []string
+	}{
+		{
+			description: "without link scores",
+			available:   devices.GetIDs(),
+			size:        2,
+			expected:    []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description: "linked GPUs are preferred",
+			available:   devices.GetIDs(),
+			size:        2,
+			scores:      scores,
+			expected:    []string{"GPU-0", "GPU-3"},
+		},
+		{
+			description: "GPUs on the same NUMA node are preferred",
+			available:   []string{"GPU-1", "GPU-2", "GPU-3"},
+			size:        2,
+			scores:      scores,
+			expected:    []string{"GPU-1", "GPU-2"},
+		},
+		{
+			description: "GPUs linked to required GPUs are preferred",
+			available:   devices.GetIDs(),
+			required:    []string{"GPU-2"},
+			size:        2,
+			scores:      scores,
+			expected:    []string{"GPU-2", "GPU-1"},
+		},
+		{
+			description: "the best set is selected as a whole",
+			available:   devices.GetIDs(),
+			size:        3,
+			scores:      scores,
+			expected:    []string{"GPU-0", "GPU-1", "GPU-3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &resourceManager{
+				config:   &spec.Config{},
+				resource: "nvidia.com/gpu",
+				devices:  devices,
+			}
+			allocated, err := r.scoredAlloc(tc.available, tc.required, tc.size, tc.scores)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, allocated)
+		})
+	}
+}
+
+func TestScoredAllocDoesNotModifyRequired(t *testing.T) {
+	devices := make(Devices)
+	for _, id := range []string{"GPU-0", "GPU-1", "GPU-2"} {
+		devices[id] = &Device{Device: pluginapi.Device{ID: id}}
+	}
+	r := &resourceManager{
+		config:   &spec.Config{},
+		resource: "nvidia.com/gpu",
+		devices:  devices,
+	}
+
+	// The spare capacity of the required devices belongs to the caller.
+	backing := []string{"GPU-0", "unused"}
+	required := backing[:1]
+	allocated, err := r.scoredAlloc(devices.GetIDs(), required, 2, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"GPU-0", "GPU-1"}, allocated)
+	require.Equal(t, []string{"GPU-0", "unused"}, backing)
+}
+
+func TestCombinations(t *testing.T) {
+	require.Equal(t, 1, combinations(4, 0, 100))
+	require.Equal(t, 6, combinations(4, 2, 100))
+	require.Equal(t, 70, combinations(8, 4, 100))
+	require.Greater(t, combinations(64, 32, maxScoredSets), maxScoredSets)
+}
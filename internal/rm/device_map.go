@@ -22,30 +22,55 @@ import (
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/location"
 )
 
 type deviceMapBuilder struct {
 	device.Interface
 	migStrategy         *string
+	migAllowPartial     bool
 	resources           *spec.Resources
-	replicatedResources *spec.ReplicatedResources
-
-	newGPUDevice func(i int, gpu nvml.Device) (string, deviceInfo)
+	replicatedResources []*spec.ReplicatedResources
+	computeCapability   []spec.ComputeCapabilityGate
+	bar1Memory          []spec.BAR1MemoryGate
+	driverVersion       []spec.DriverVersionGate
+	filter              *spec.Devices
+	locations           location.Map
+	cpuAffinity         bool
+
+	newGPUDevice   func(i int, gpu nvml.Device) (string, deviceInfo)
+	driverVersions func() (DriverVersions, error)
 }
 
 // DeviceMap stores a set of devices per resource name.
 type DeviceMap map[spec.ResourceName]Devices
 
 // NewDeviceMap creates a device map for the specified NVML library and config.
-func NewDeviceMap(infolib info.Interface, devicelib device.Interface, config *spec.Config) (DeviceMap, error) {
+func NewDeviceMap(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interface, config *spec.Config) (DeviceMap, error) {
+	locations, err := location.Load(config.Flags.GetDeviceLocationFile())
+	if err != nil {
+		return nil, err
+	}
+
 	b := deviceMapBuilder{
 		Interface:           devicelib,
 		migStrategy:         config.Flags.MigStrategy,
+		migAllowPartial:     config.Flags.Plugin.GetMigSingleAllowPartial(),
 		resources:           &config.Resources,
-		replicatedResources: config.Sharing.ReplicatedResources(),
+		replicatedResources: config.Sharing.AllReplicatedResources(),
+		computeCapability:   config.Devices.ComputeCapabilityGates(),
+		bar1Memory:          config.Devices.BAR1MemoryGates(),
+		driverVersion:       config.Devices.DriverVersionGates(),
+		filter:              config.Devices,
+		locations:           locations,
+		cpuAffinity:         config.Flags.Plugin.GetCPUAffinityEnvvars(),
 		newGPUDevice:        newNvmlGPUDevice,
+		driverVersions: func() (DriverVersions, error) {
+			return GetDriverVersions(nvmllib)
+		},
 	}
 
 	if infolib.ResolvePlatform() == info.PlatformWSL {
@@ -61,7 +86,26 @@ func (b *deviceMapBuilder) build() (DeviceMap, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error building device map from config.resources: %v", err)
 	}
-	devices, err = updateDeviceMapWithReplicas(b.replicatedResources, devices)
+	devices, err = devices.applyComputeCapabilityGates(b.computeCapability)
+	if err != nil {
+		return nil, fmt.Errorf("error applying compute capability gates from config.devices: %v", err)
+	}
+	devices = devices.applyBAR1MemoryGates(b.bar1Memory)
+	if len(b.driverVersion) > 0 {
+		versions, err := b.driverVersions()
+		if err != nil {
+			return nil, fmt.Errorf("error applying driver version gates from config.devices: %v", err)
+		}
+		unmet, err := UnmetDriverVersionGates(b.driverVersion, versions)
+		if err != nil {
+			return nil, fmt.Errorf("error applying driver version gates from config.devices: %v", err)
+		}
+		devices = devices.applyDriverVersionGates(b.driverVersion, unmet)
+	}
+	if err := b.applyLocations(devices); err != nil {
+		return nil, fmt.Errorf("error applying device locations: %v", err)
+	}
+	devices, err = updateDeviceMapWithAllReplicas(b.replicatedResources, devices)
 	if err != nil {
 		return nil, fmt.Errorf("error updating device map with replicas from replicatedResources config: %v", err)
 	}
@@ -95,7 +139,10 @@ func (b *deviceMapBuilder) buildDeviceMapFromConfigResources() (DeviceMap, error
 	}
 
 	if requireUniformMIGDevices && !deviceMap.isEmpty() && !migDeviceMap.isEmpty() {
-		return nil, fmt.Errorf("all devices on the node must be configured with the same migEnabled value")
+		if !b.migAllowPartial {
+			return nil, fmt.Errorf("all devices on the node must be configured with the same migEnabled value")
+		}
+		deviceMap = deviceMap.renameConflicting(migDeviceMap, spec.DefaultFullGPUResourceNameSuffix)
 	}
 
 	deviceMap.merge(migDeviceMap)
@@ -119,10 +166,17 @@ func (b *deviceMapBuilder) buildGPUDeviceMap() (DeviceMap, error) {
 		if migEnabled && *b.migStrategy != spec.MigStrategyNone {
 			return nil
 		}
+		selected, err := b.isSelected(i, gpu)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			return nil
+		}
 		for _, resource := range b.resources.GPUs {
 			if resource.Pattern.Matches(name) {
 				index, info := b.newGPUDevice(i, gpu)
-				return devices.setEntry(resource.Name, index, info)
+				return devices.setEntry(resource.Name, index, info, b.cpuAffinity)
 			}
 		}
 		return fmt.Errorf("GPU name '%v' does not match any resource patterns", name)
@@ -134,6 +188,13 @@ func (b *deviceMapBuilder) buildGPUDeviceMap() (DeviceMap, error) {
 func (b *deviceMapBuilder) buildMigDeviceMap() (DeviceMap, error) {
 	devices := make(DeviceMap)
 	err := b.VisitMigDevices(func(i int, d device.Device, j int, mig device.MigDevice) error {
+		selected, err := b.isSelected(i, d)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			return nil
+		}
 		migProfile, err := mig.GetProfile()
 		if err != nil {
 			return fmt.Errorf("error getting MIG profile for MIG device at index '(%v, %v)': %v", i, j, err)
@@ -141,7 +202,7 @@ func (b *deviceMapBuilder) buildMigDeviceMap() (DeviceMap, error) {
 		for _, resource := range b.resources.MIGs {
 			if resource.Pattern.Matches(migProfile.String()) {
 				index, info := newMigDevice(i, j, mig)
-				return devices.setEntry(resource.Name, index, info)
+				return devices.setEntry(resource.Name, index, info, b.cpuAffinity)
 			}
 		}
 		return fmt.Errorf("MIG profile '%v' does not match any resource patterns", migProfile)
@@ -149,6 +210,33 @@ func (b *deviceMapBuilder) buildMigDeviceMap() (DeviceMap, error) {
 	return devices, err
 }
 
+// isSelected checks whether the GPU at the specified index passes the include
+// and exclude filters from spec.Config.Devices. The MIG devices of a GPU are
+// selected together with it.
+func (b *deviceMapBuilder) isSelected(i int, gpu device.Device) (bool, error) {
+	if !b.filter.HasFilters() {
+		return true, nil
+	}
+	uuid, ret := gpu.GetUUID()
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("error getting UUID of GPU %d: %v", i, ret)
+	}
+	name, ret := gpu.GetName()
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("error getting name of GPU %d: %v", i, ret)
+	}
+	pciInfo, ret := gpu.GetPciInfo()
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("error getting PCI info of GPU %d: %v", i, ret)
+	}
+	return b.filter.Selects(spec.DeviceIdentifiers{
+		Index:    i,
+		UUID:     uuid,
+		PCIBusID: int8Slice(pciInfo.BusId[:]).String(),
+		Name:     name,
+	}), nil
+}
+
 // assertAllMigDevicesAreValid ensures that each MIG-enabled device has at least one MIG device
 // associated with it.
 func (b *deviceMapBuilder) assertAllMigDevicesAreValid(uniform bool) error {
@@ -160,6 +248,13 @@ func (b *deviceMapBuilder) assertAllMigDevicesAreValid(uniform bool) error {
 		if !isMigEnabled {
 			return nil
 		}
+		selected, err := b.isSelected(i, d)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			return nil
+		}
 		migDevices, err := d.GetMigDevices()
 		if err != nil {
 			return err
@@ -194,9 +289,45 @@ func (b *deviceMapBuilder) assertAllMigDevicesAreValid(uniform bool) error {
 	})
 }
 
-// setEntry sets the DeviceMap entry for the specified resource
-func (d DeviceMap) setEntry(name spec.ResourceName, index string, device deviceInfo) error {
-	dev, err := BuildDevice(index, device)
+// applyLocations sets the physical location of the devices from the device
+// location file. MIG devices without an entry of their own inherit the
+// location of their parent GPU.
+func (b *deviceMapBuilder) applyLocations(devices DeviceMap) error {
+	if len(b.locations) == 0 {
+		return nil
+	}
+	parents := make(map[string]string)
+	err := b.VisitMigDevices(func(i int, d device.Device, j int, mig device.MigDevice) error {
+		parent, ret := d.GetUUID()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting UUID of GPU %v: %v", i, ret)
+		}
+		uuid, ret := mig.GetUUID()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting UUID of MIG device %v:%v: %v", i, j, ret)
+		}
+		parents[uuid] = parent
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, ds := range devices {
+		for _, d := range ds {
+			uuid := d.GetUUID()
+			d.Location = b.locations.Get(uuid)
+			if d.Location == nil {
+				d.Location = b.locations.Get(parents[uuid])
+			}
+		}
+	}
+	return nil
+}
+
+// setEntry sets the DeviceMap entry for the specified resource. The CPU
+// affinity of the device is only queried if requested.
+func (d DeviceMap) setEntry(name spec.ResourceName, index string, device deviceInfo, cpuAffinity bool) error {
+	dev, err := BuildDevice(index, device, cpuAffinity)
 	if err != nil {
 		return fmt.Errorf("error building Device: %v", err)
 	}
@@ -231,6 +362,92 @@ func (d DeviceMap) isEmpty() bool {
 	return true
 }
 
+// renameConflicting returns an updated device map in which the resources that
+// are also present in the other device map have the specified suffix appended
+// to their name. This allows the full GPUs on a partially MIG-enabled node to
+// be advertised alongside the MIG devices of the single MIG strategy.
+func (d DeviceMap) renameConflicting(o DeviceMap, suffix string) DeviceMap {
+	devices := make(DeviceMap)
+	for name, ds := range d {
+		if _, exists := o[name]; exists {
+			klog.Infof("Advertising the full GPUs of resource %v as %v%v", name, name, suffix)
+			name = spec.ResourceName(string(name) + suffix)
+		}
+		devices[name] = ds
+	}
+	return devices
+}
+
+// applyComputeCapabilityGates returns an updated device map in which devices
+// below the minimum compute capability of their resource are either moved to
+// the renamed resource or removed.
+func (d DeviceMap) applyComputeCapabilityGates(gates []spec.ComputeCapabilityGate) (DeviceMap, error) {
+	if len(gates) == 0 {
+		return d, nil
+	}
+	byResource := make(map[spec.ResourceName]spec.ComputeCapabilityGate)
+	for _, g := range gates {
+		byResource[g.Resource] = g
+	}
+
+	devices := make(DeviceMap)
+	for name, ds := range d {
+		g, exists := byResource[name]
+		for _, dev := range ds {
+			if !exists {
+				devices.insert(name, dev)
+				continue
+			}
+			satisfied, err := g.Min.IsSatisfiedBy(dev.ComputeCapability)
+			if err != nil {
+				return nil, fmt.Errorf("error checking compute capability of device %v: %w", dev.ID, err)
+			}
+			switch {
+			case satisfied:
+				devices.insert(name, dev)
+			case g.Rename != "":
+				klog.Infof("Device %v has compute capability %v < %v; advertising it as %v instead of %v", dev.ID, dev.ComputeCapability, g.Min, g.Rename, name)
+				devices.insert(g.Rename, dev)
+			default:
+				klog.Warningf("Device %v has compute capability %v < %v; not advertising it as %v", dev.ID, dev.ComputeCapability, g.Min, name)
+			}
+		}
+	}
+	return devices, nil
+}
+
+// applyBAR1MemoryGates returns an updated device map in which devices below
+// the minimum BAR1 memory of their resource are either moved to the renamed
+// resource or removed. Devices whose BAR1 memory is unknown are treated as
+// being below the minimum.
+func (d DeviceMap) applyBAR1MemoryGates(gates []spec.BAR1MemoryGate) DeviceMap {
+	if len(gates) == 0 {
+		return d
+	}
+	byResource := make(map[spec.ResourceName]spec.BAR1MemoryGate)
+	for _, g := range gates {
+		byResource[g.Resource] = g
+	}
+
+	devices := make(DeviceMap)
+	for name, ds := range d {
+		g, exists := byResource[name]
+		for _, dev := range ds {
+			bar1MemoryMB := dev.BAR1Memory / (1024 * 1024)
+			switch {
+			case !exists || bar1MemoryMB >= g.MinMB:
+				devices.insert(name, dev)
+			case g.Rename != "":
+				klog.Infof("Device %v has %v MB of BAR1 memory < %v MB; advertising it as %v instead of %v", dev.ID, bar1MemoryMB, g.MinMB, g.Rename, name)
+				devices.insert(g.Rename, dev)
+			default:
+				klog.Warningf("Device %v has %v MB of BAR1 memory < %v MB; not advertising it as %v", dev.ID, bar1MemoryMB, g.MinMB, name)
+			}
+		}
+	}
+	return devices
+}
+
 // getIDsOfDevicesToReplicate returns a list of dervice IDs that we want to replicate.
 func (d DeviceMap) getIDsOfDevicesToReplicate(r *spec.ReplicatedResource) ([]string, error) {
 	devices, exists := d[r.Name]
@@ -276,6 +493,21 @@ func (d DeviceMap) getIDsOfDevicesToReplicate(r *spec.ReplicatedResource) ([]str
 	return nil, fmt.Errorf("unexpected error")
 }
 
+// updateDeviceMapWithAllReplicas applies the replicas of each sharing strategy
+// in turn. A resource that is shared using both strategies has its devices
+// split between them: the devices that are not replicated by time-slicing are
+// left under the original name and are available to MPS.
+func updateDeviceMapWithAllReplicas(all []*spec.ReplicatedResources, devices DeviceMap) (DeviceMap, error) {
+	for _, replicatedResources := range all {
+		var err error
+		devices, err = updateDeviceMapWithReplicas(replicatedResources, devices)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return devices, nil
+}
+
 // updateDeviceMapWithReplicas returns an updated map of resource names to devices with replica
 // information from the active replicated resources config.
 func updateDeviceMapWithReplicas(replicatedResources *spec.ReplicatedResources, oDevices DeviceMap) (DeviceMap, error) {
@@ -312,6 +544,16 @@ func updateDeviceMapWithReplicas(replicatedResources *spec.ReplicatedResources,
 			devices.insert(r.Name, d)
 		}
 
+		// Keep advertising the replicated devices as whole devices if requested.
+		if r.AdvertiseWhole {
+			if r.Rename == "" || r.Rename == r.Name {
+				return nil, fmt.Errorf("advertiseWhole requires the shared devices to be renamed: %v", r.Name)
+			}
+			for _, d := range oDevices[r.Name].Subset(ids) {
+				devices.insert(r.Name, d)
+			}
+		}
+
 		// Create replicated devices add them to the device map.
 		// Rename the resource for replicated devices as requested.
 		name := r.Name
@@ -319,11 +561,18 @@ func updateDeviceMapWithReplicas(replicatedResources *spec.ReplicatedResources,
 			name = r.Rename
 		}
 		for _, id := range ids {
-			for i := 0; i < r.Replicas; i++ {
+			// With the memory unit, each device is replicated once per chunk
+			// of its memory.
+			replicas := r.Replicas
+			if replicatedResources.IsMemory() {
+				replicas = replicatedResources.MemoryChunks(&r, oDevices[r.Name][id].TotalMemory)
+			}
+			for i := 0; i < replicas+r.BurstReplicas; i++ {
 				annotatedID := string(NewAnnotatedID(id, i))
 				replicatedDevice := *(oDevices[r.Name][id])
 				replicatedDevice.ID = annotatedID
-				replicatedDevice.Replicas = r.Replicas
+				replicatedDevice.Replicas = replicas + r.BurstReplicas
+				replicatedDevice.Burst = i >= replicas
 				devices.insert(name, &replicatedDevice)
 			}
 		}
@@ -17,6 +17,7 @@
 package rm
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -107,3 +108,336 @@ func TestDeviceMapInsert(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyComputeCapabilityGates(t *testing.T) {
+	a100 := &Device{Device: pluginapi.Device{ID: "GPU-a100"}, ComputeCapability: "8.0"}
+	h100 := &Device{Device: pluginapi.Device{ID: "GPU-h100"}, ComputeCapability: "9.0"}
+	p100 := &Device{Device: pluginapi.Device{ID: "GPU-p100"}, ComputeCapability: "6.0"}
+	mig := &Device{Device: pluginapi.Device{ID: "MIG-0"}, ComputeCapability: "8.0"}
+
+	deviceMap := DeviceMap{
+		"nvidia.com/gpu":            Devices{a100.ID: a100, h100.ID: h100, p100.ID: p100},
+		"nvidia.com/mig-1g.5gb":     Devices{mig.ID: mig},
+		"nvidia.com/gpu-unaffected": Devices{p100.ID: p100},
+	}
+
+	testCases := []struct {
+		description       string
+		gates             []spec.ComputeCapabilityGate
+		expectedDeviceMap DeviceMap
+	}{
+		{
+			description:       "no gates",
+			expectedDeviceMap: deviceMap,
+		},
+		{
+			description: "devices below the minimum are not advertised",
+			gates: []spec.ComputeCapabilityGate{
+				{Resource: "nvidia.com/gpu", Min: "8.0"},
+			},
+			expectedDeviceMap: DeviceMap{
+				"nvidia.com/gpu":            Devices{a100.ID: a100, h100.ID: h100},
+				"nvidia.com/mig-1g.5gb":     Devices{mig.ID: mig},
+				"nvidia.com/gpu-unaffected": Devices{p100.ID: p100},
+			},
+		},
+		{
+			description: "devices below the minimum are renamed",
+			gates: []spec.ComputeCapabilityGate{
+				{Resource: "nvidia.com/gpu", Min: "8.6", Rename: "nvidia.com/gpu-legacy"},
+			},
+			expectedDeviceMap: DeviceMap{
+				"nvidia.com/gpu":            Devices{h100.ID: h100},
+				"nvidia.com/gpu-legacy":     Devices{a100.ID: a100, p100.ID: p100},
+				"nvidia.com/mig-1g.5gb":     Devices{mig.ID: mig},
+				"nvidia.com/gpu-unaffected": Devices{p100.ID: p100},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices, err := deviceMap.applyComputeCapabilityGates(tc.gates)
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedDeviceMap, devices)
+		})
+	}
+
+	_, err := DeviceMap{"nvidia.com/gpu": Devices{"tegra": &Device{}}}.applyComputeCapabilityGates(
+		[]spec.ComputeCapabilityGate{{Resource: "nvidia.com/gpu", Min: "8.0"}},
+	)
+	require.Error(t, err)
+}
+
+func TestApplyBAR1MemoryGates(t *testing.T) {
+	a100 := &Device{Device: pluginapi.Device{ID: "GPU-a100"}, BAR1Memory: 128 * 1024 * 1024 * 1024}
+	l4 := &Device{Device: pluginapi.Device{ID: "GPU-l4"}, BAR1Memory: 32 * 1024 * 1024 * 1024}
+	unknown := &Device{Device: pluginapi.Device{ID: "GPU-unknown"}}
+
+	deviceMap := DeviceMap{
+		"nvidia.com/gpu":            Devices{a100.ID: a100, l4.ID: l4, unknown.ID: unknown},
+		"nvidia.com/gpu-unaffected": Devices{l4.ID: l4},
+	}
+
+	testCases := []struct {
+		description       string
+		gates             []spec.BAR1MemoryGate
+		expectedDeviceMap DeviceMap
+	}{
+		{
+			description:       "no gates",
+			expectedDeviceMap: deviceMap,
+		},
+		{
+			description: "devices below the minimum are not advertised",
+			gates: []spec.BAR1MemoryGate{
+				{Resource: "nvidia.com/gpu", MinMB: 65536},
+			},
+			expectedDeviceMap: DeviceMap{
+				"nvidia.com/gpu":            Devices{a100.ID: a100},
+				"nvidia.com/gpu-unaffected": Devices{l4.ID: l4},
+			},
+		},
+		{
+			description: "devices below the minimum are renamed",
+			gates: []spec.BAR1MemoryGate{
+				{Resource: "nvidia.com/gpu", MinMB: 32768, Rename: "nvidia.com/gpu-small-bar1"},
+			},
+			expectedDeviceMap: DeviceMap{
+				"nvidia.com/gpu":            Devices{a100.ID: a100, l4.ID: l4},
+				"nvidia.com/gpu-small-bar1": Devices{unknown.ID: unknown},
+				"nvidia.com/gpu-unaffected": Devices{l4.ID: l4},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.EqualValues(t, tc.expectedDeviceMap, deviceMap.applyBAR1MemoryGates(tc.gates))
+		})
+	}
+}
+
+func TestRenameConflicting(t *testing.T) {
+	gpu := &Device{Device: pluginapi.Device{ID: "GPU-0"}}
+	other := &Device{Device: pluginapi.Device{ID: "GPU-1"}}
+	mig := &Device{Device: pluginapi.Device{ID: "MIG-0"}}
+
+	testCases := []struct {
+		description       string
+		deviceMap         DeviceMap
+		migDeviceMap      DeviceMap
+		expectedDeviceMap DeviceMap
+	}{
+		{
+			description:       "no conflicts",
+			deviceMap:         DeviceMap{"nvidia.com/gpu": Devices{gpu.ID: gpu}},
+			migDeviceMap:      DeviceMap{"nvidia.com/mig-1g.5gb": Devices{mig.ID: mig}},
+			expectedDeviceMap: DeviceMap{"nvidia.com/gpu": Devices{gpu.ID: gpu}},
+		},
+		{
+			description: "conflicting resources are renamed",
+			deviceMap: DeviceMap{
+				"nvidia.com/gpu":   Devices{gpu.ID: gpu},
+				"nvidia.com/other": Devices{other.ID: other},
+			},
+			migDeviceMap: DeviceMap{"nvidia.com/gpu": Devices{mig.ID: mig}},
+			expectedDeviceMap: DeviceMap{
+				"nvidia.com/gpu.full": Devices{gpu.ID: gpu},
+				"nvidia.com/other":    Devices{other.ID: other},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices := tc.deviceMap.renameConflicting(tc.migDeviceMap, spec.DefaultFullGPUResourceNameSuffix)
+			require.EqualValues(t, tc.expectedDeviceMap, devices)
+		})
+	}
+}
+
+func TestUpdateDeviceMapWithReplicasAdvertiseWhole(t *testing.T) {
+	gpu := &Device{Device: pluginapi.Device{ID: "GPU-0"}}
+	replica := func(i int) *Device {
+		d := *gpu
+		d.ID = string(NewAnnotatedID(gpu.ID, i))
+		d.Replicas = 2
+		return &d
+	}
+	devices := DeviceMap{"nvidia.com/gpu": Devices{gpu.ID: gpu}}
+
+	replicated := &spec.ReplicatedResources{
+		Resources: []spec.ReplicatedResource{
+			{
+				Name:           "nvidia.com/gpu",
+				Rename:         "nvidia.com/gpu.shared",
+				Devices:        spec.ReplicatedDevices{All: true},
+				Replicas:       2,
+				AdvertiseWhole: true,
+			},
+		},
+	}
+	updated, err := updateDeviceMapWithReplicas(replicated, devices)
+	require.NoError(t, err)
+	require.EqualValues(t, DeviceMap{
+		"nvidia.com/gpu":        Devices{gpu.ID: gpu},
+		"nvidia.com/gpu.shared": Devices{"GPU-0::0": replica(0), "GPU-0::1": replica(1)},
+	}, updated)
+
+	replicated.Resources[0].Rename = ""
+	_, err = updateDeviceMapWithReplicas(replicated, devices)
+	require.EqualError(t, err, "advertiseWhole requires the shared devices to be renamed: nvidia.com/gpu")
+}
+
+func TestUpdateDeviceMapWithBurstReplicas(t *testing.T) {
+	gpu := &Device{Device: pluginapi.Device{ID: "GPU-0"}}
+	replica := func(i int, burst bool) *Device {
+		d := *gpu
+		d.ID = string(NewAnnotatedID(gpu.ID, i))
+		d.Replicas = 3
+		d.Burst = burst
+		return &d
+	}
+	devices := DeviceMap{"nvidia.com/gpu": Devices{gpu.ID: gpu}}
+
+	replicated := &spec.ReplicatedResources{
+		Resources: []spec.ReplicatedResource{
+			{
+				Name:          "nvidia.com/gpu",
+				Devices:       spec.ReplicatedDevices{All: true},
+				Replicas:      2,
+				BurstReplicas: 1,
+			},
+		},
+	}
+	updated, err := updateDeviceMapWithReplicas(replicated, devices)
+	require.NoError(t, err)
+	require.EqualValues(t, DeviceMap{
+		"nvidia.com/gpu": Devices{
+			"GPU-0::0": replica(0, false),
+			"GPU-0::1": replica(1, false),
+			"GPU-0::2": replica(2, true),
+		},
+	}, updated)
+}
+
+func TestUpdateDeviceMapWithSplitReplicas(t *testing.T) {
+	devices := DeviceMap{
+		"nvidia.com/gpu": Devices{
+			"GPU-0": &Device{Device: pluginapi.Device{ID: "GPU-0"}, Index: "0"},
+			"GPU-1": &Device{Device: pluginapi.Device{ID: "GPU-1"}, Index: "1"},
+			"GPU-2": &Device{Device: pluginapi.Device{ID: "GPU-2"}, Index: "2"},
+		},
+	}
+
+	timeSlicing := &spec.ReplicatedResources{
+		Resources: []spec.ReplicatedResource{
+			{
+				Name:     "nvidia.com/gpu",
+				Rename:   "nvidia.com/gpu.shared",
+				Devices:  spec.ReplicatedDevices{List: []spec.ReplicatedDeviceRef{"0"}},
+				Replicas: 2,
+			},
+		},
+	}
+	mps := &spec.ReplicatedResources{
+		Resources: []spec.ReplicatedResource{
+			{
+				Name:     "nvidia.com/gpu",
+				Rename:   "nvidia.com/gpu.mps",
+				Devices:  spec.ReplicatedDevices{List: []spec.ReplicatedDeviceRef{"2"}},
+				Replicas: 2,
+			},
+		},
+	}
+	updated, err := updateDeviceMapWithAllReplicas([]*spec.ReplicatedResources{timeSlicing, mps}, devices)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"GPU-0::0", "GPU-0::1"}, updated["nvidia.com/gpu.shared"].GetIDs())
+	require.ElementsMatch(t, []string{"GPU-2::0", "GPU-2::1"}, updated["nvidia.com/gpu.mps"].GetIDs())
+	require.ElementsMatch(t, []string{"GPU-1"}, updated["nvidia.com/gpu"].GetIDs())
+}
+
+func TestUpdateDeviceMapWithMemoryChunks(t *testing.T) {
+	small := &Device{Device: pluginapi.Device{ID: "GPU-0"}, TotalMemory: 4096 << 20}
+	large := &Device{Device: pluginapi.Device{ID: "GPU-1"}, TotalMemory: 8192 << 20}
+	chunk := func(gpu *Device, i int, replicas int) *Device {
+		d := *gpu
+		d.ID = string(NewAnnotatedID(gpu.ID, i))
+		d.Replicas = replicas
+		return &d
+	}
+	devices := DeviceMap{"nvidia.com/gpu": Devices{small.ID: small, large.ID: large}}
+
+	chunkMB := uint64(2048)
+	overheadMB := uint64(0)
+	replicated := &spec.ReplicatedResources{
+		Unit:          spec.ReplicaUnitMemory,
+		MemoryChunkMB: &chunkMB,
+		Resources: []spec.ReplicatedResource{
+			{
+				Name:                   "nvidia.com/gpu",
+				Rename:                 "nvidia.com/gpu-memory",
+				Devices:                spec.ReplicatedDevices{All: true},
+				ServerMemoryOverheadMB: &overheadMB,
+			},
+		},
+	}
+	updated, err := updateDeviceMapWithReplicas(replicated, devices)
+	require.NoError(t, err)
+	require.EqualValues(t, DeviceMap{
+		"nvidia.com/gpu-memory": Devices{
+			"GPU-0::0": chunk(small, 0, 2),
+			"GPU-0::1": chunk(small, 1, 2),
+			"GPU-1::0": chunk(large, 0, 4),
+			"GPU-1::1": chunk(large, 1, 4),
+			"GPU-1::2": chunk(large, 2, 4),
+			"GPU-1::3": chunk(large, 3, 4),
+		},
+	}, updated)
+}
+
+// affinityDevice is a device with the specified CPU affinity.
+type affinityDevice struct {
+	*tegraDevice
+	cpus []int
+	err  error
+}
+
+func (d affinityDevice) GetCPUAffinity() ([]int, error) {
+	return d.cpus, d.err
+}
+
+func TestBuildDeviceCPUAffinity(t *testing.T) {
+	testCases := []struct {
+		description         string
+		device              affinityDevice
+		cpuAffinity         bool
+		expectedCPUAffinity []int
+	}{
+		{
+			description: "affinity is not queried if not requested",
+			device:      affinityDevice{cpus: []int{0, 1}, err: errors.New("unexpected query")},
+		},
+		{
+			description:         "affinity is queried if requested",
+			device:              affinityDevice{cpus: []int{0, 1}},
+			cpuAffinity:         true,
+			expectedCPUAffinity: []int{0, 1},
+		},
+		{
+			description: "errors leave the affinity unknown",
+			device:      affinityDevice{err: errors.New("failed")},
+			cpuAffinity: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			tc.device.tegraDevice = &tegraDevice{}
+			dev, err := BuildDevice("0", tc.device, tc.cpuAffinity)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedCPUAffinity, dev.CPUAffinity)
+		})
+	}
+}
@@ -18,10 +18,14 @@ package rm
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
+	"k8s.io/klog/v2"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/location"
 )
 
 // Device wraps pluginapi.Device with extra metadata and functions.
@@ -30,10 +34,23 @@ type Device struct {
 	Paths             []string
 	Index             string
 	TotalMemory       uint64
+	BAR1Memory        uint64
 	ComputeCapability string
+	// CPUAffinity lists the CPUs that are closest to the device, or is nil
+	// if the affinity of the device is unknown.
+	CPUAffinity []int
 	// Replicas stores the total number of times this device is replicated.
 	// If this is 0 or 1 then the device is not shared.
 	Replicas int
+	// Burst indicates that this replica is a burst replica that is only
+	// advertised once all other replicas of its resource are allocated.
+	Burst bool
+	// Location is the physical location of the device from the device
+	// location file, or nil if its location is unknown.
+	Location *location.Location
+	// ValidationError is the reason why the device failed the startup
+	// validation, or nil if it passed or was not validated.
+	ValidationError error
 }
 
 // deviceInfo defines the information the required to construct a Device
@@ -42,7 +59,9 @@ type deviceInfo interface {
 	GetPaths() ([]string, error)
 	GetNumaNode() (bool, int, error)
 	GetTotalMemory() (uint64, error)
+	GetBAR1Memory() (uint64, error)
 	GetComputeCapability() (string, error)
+	GetCPUAffinity() ([]int, error)
 }
 
 // Devices wraps a map[string]*Device with some functions.
@@ -54,8 +73,11 @@ type AnnotatedID string
 // AnnotatedIDs can be used to treat a []string as a []AnnotatedID.
 type AnnotatedIDs []string
 
-// BuildDevice builds an rm.Device with the specified index and deviceInfo
-func BuildDevice(index string, d deviceInfo) (*Device, error) {
+// BuildDevice builds an rm.Device with the specified index and deviceInfo.
+// The CPU affinity of the device is only queried if requested. Since it only
+// describes the recommended placement, a failure to query it is logged and
+// the affinity is left unknown.
+func BuildDevice(index string, d deviceInfo, cpuAffinity bool) (*Device, error) {
 	uuid, err := d.GetUUID()
 	if err != nil {
 		return nil, fmt.Errorf("error getting UUID device: %v", err)
@@ -76,6 +98,11 @@ func BuildDevice(index string, d deviceInfo) (*Device, error) {
 		return nil, fmt.Errorf("error getting device memory: %w", err)
 	}
 
+	bar1Memory, err := d.GetBAR1Memory()
+	if err != nil {
+		return nil, fmt.Errorf("error getting device BAR1 memory: %w", err)
+	}
+
 	computeCapability, err := d.GetComputeCapability()
 	if err != nil {
 		return nil, fmt.Errorf("error getting device compute capability: %w", err)
@@ -83,8 +110,17 @@ func BuildDevice(index string, d deviceInfo) (*Device, error) {
 
 	dev := Device{
 		TotalMemory:       totalMemory,
+		BAR1Memory:        bar1Memory,
 		ComputeCapability: computeCapability,
 	}
+	if cpuAffinity {
+		cpus, err := d.GetCPUAffinity()
+		if err != nil {
+			klog.Warningf("Failed to get CPU affinity of device %v: %v", uuid, err)
+		} else {
+			dev.CPUAffinity = cpus
+		}
+	}
 	dev.ID = uuid
 	dev.Index = index
 	dev.Paths = paths
@@ -127,6 +163,18 @@ func (ds Devices) GetByIndex(index string) *Device {
 	return nil
 }
 
+// GetByUUID returns a reference to a device whose underlying GPU or MIG device
+// has the specified UUID (nil otherwise). For replicated resources, any one of
+// the replicas of the device is returned.
+func (ds Devices) GetByUUID(uuid string) *Device {
+	for _, d := range ds {
+		if d.GetUUID() == uuid {
+			return d
+		}
+	}
+	return nil
+}
+
 // Subset returns the subset of devices in Devices matching the provided ids.
 // If any id in ids is not in Devices, then the subset that did match will be returned.
 func (ds Devices) Subset(ids []string) Devices {
@@ -150,6 +198,23 @@ func (ds Devices) Difference(ods Devices) Devices {
 	return res
 }
 
+// OtherReplicas returns the devices in Devices that are replicas of the same
+// physical devices as the devices matching the provided ids, excluding the
+// devices matching the ids themselves.
+func (ds Devices) OtherReplicas(ids ...string) Devices {
+	physical := make(map[string]bool)
+	for _, id := range ids {
+		physical[AnnotatedID(id).GetID()] = true
+	}
+	res := make(Devices)
+	for id, d := range ds {
+		if physical[d.GetUUID()] && !slices.Contains(ids, id) {
+			res[id] = d
+		}
+	}
+	return res
+}
+
 // GetIDs returns the ids from all devices in the Devices
 func (ds Devices) GetIDs() []string {
 	var res []string
@@ -274,6 +339,21 @@ func (rs AnnotatedIDs) AnyHasAnnotations() bool {
 	return false
 }
 
+// UniqueByID returns the first annotated ID for each distinct ID part, preserving their order.
+func (rs AnnotatedIDs) UniqueByID() AnnotatedIDs {
+	seen := make(map[string]bool)
+	var res AnnotatedIDs
+	for _, r := range rs {
+		id := AnnotatedID(r).GetID()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		res = append(res, r)
+	}
+	return res
+}
+
 // GetIDs returns just the ID parts of the annotated IDs as a []string
 func (rs AnnotatedIDs) GetIDs() []string {
 	res := make([]string, len(rs))
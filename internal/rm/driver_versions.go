@@ -0,0 +1,116 @@
+/**
+# Copyright (c) 2022, NVIDIA CORPORATION.  All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"fmt"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// DriverVersions holds the versions of the driver installed on the node.
+type DriverVersions struct {
+	// Driver is the version of the driver, e.g. 550.54.15.
+	Driver string
+	// CUDA is the CUDA version supported by the driver, e.g. 12.4.
+	CUDA string
+}
+
+// GetDriverVersions queries the versions of the driver through NVML, which
+// must be initialized.
+func GetDriverVersions(nvmllib nvml.Interface) (DriverVersions, error) {
+	driver, ret := nvmllib.SystemGetDriverVersion()
+	if ret != nvml.SUCCESS {
+		return DriverVersions{}, fmt.Errorf("error getting driver version: %v", ret)
+	}
+	cuda, ret := nvmllib.SystemGetCudaDriverVersion()
+	if ret != nvml.SUCCESS {
+		return DriverVersions{}, fmt.Errorf("error getting CUDA driver version: %v", ret)
+	}
+	return DriverVersions{
+		Driver: driver,
+		CUDA:   fmt.Sprintf("%d.%d", cuda/1000, cuda%1000/10),
+	}, nil
+}
+
+// UnmetDriverVersionGates returns the reasons why the driver versions do not
+// satisfy the specified gates by the resource of each gate. Satisfied gates
+// are omitted.
+func UnmetDriverVersionGates(gates []spec.DriverVersionGate, versions DriverVersions) (map[spec.ResourceName]string, error) {
+	unmet := make(map[spec.ResourceName]string)
+	for _, g := range gates {
+		if g.MinDriver != "" {
+			satisfied, err := g.MinDriver.IsSatisfiedBy(versions.Driver)
+			if err != nil {
+				return nil, fmt.Errorf("error checking driver version: %w", err)
+			}
+			if !satisfied {
+				unmet[g.Resource] = fmt.Sprintf("driver version %v < %v", versions.Driver, g.MinDriver)
+				continue
+			}
+		}
+		if g.MinCUDA != "" {
+			satisfied, err := g.MinCUDA.IsSatisfiedBy(versions.CUDA)
+			if err != nil {
+				return nil, fmt.Errorf("error checking CUDA version: %w", err)
+			}
+			if !satisfied {
+				unmet[g.Resource] = fmt.Sprintf("CUDA version %v < %v", versions.CUDA, g.MinCUDA)
+			}
+		}
+	}
+	return unmet, nil
+}
+
+// applyDriverVersionGates returns an updated device map in which the devices
+// of the resources whose driver version gates are unmet are either moved to
+// the renamed resource or removed.
+func (d DeviceMap) applyDriverVersionGates(gates []spec.DriverVersionGate, unmet map[spec.ResourceName]string) DeviceMap {
+	if len(unmet) == 0 {
+		return d
+	}
+	byResource := make(map[spec.ResourceName]spec.DriverVersionGate)
+	for _, g := range gates {
+		byResource[g.Resource] = g
+	}
+
+	devices := make(DeviceMap)
+	for name, ds := range d {
+		reason, exists := unmet[name]
+		g := byResource[name]
+		switch {
+		case !exists:
+			for _, dev := range ds {
+				devices.insert(name, dev)
+			}
+			continue
+		case g.Rename != "":
+			klog.Infof("The %v; advertising the devices of %v as %v instead", reason, name, g.Rename)
+			name = g.Rename
+		default:
+			klog.Warningf("The %v; not advertising the devices of %v", reason, name)
+			continue
+		}
+		for _, dev := range ds {
+			devices.insert(name, dev)
+		}
+	}
+	return devices
+}
@@ -0,0 +1,92 @@
+/**
+# Copyright (c) 2022, NVIDIA CORPORATION.  All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestApplyDriverVersionGates(t *testing.T) {
+	gpu0 := &Device{Device: pluginapi.Device{ID: "GPU-0"}}
+	gpu1 := &Device{Device: pluginapi.Device{ID: "GPU-1"}}
+
+	deviceMap := DeviceMap{
+		"nvidia.com/gpu":            Devices{gpu0.ID: gpu0},
+		"nvidia.com/gpu-unaffected": Devices{gpu1.ID: gpu1},
+	}
+	versions := DriverVersions{Driver: "535.104.05", CUDA: "12.2"}
+
+	testCases := []struct {
+		description       string
+		gates             []spec.DriverVersionGate
+		expectedUnmet     map[spec.ResourceName]string
+		expectedDeviceMap DeviceMap
+	}{
+		{
+			description:       "no gates",
+			expectedUnmet:     map[spec.ResourceName]string{},
+			expectedDeviceMap: deviceMap,
+		},
+		{
+			description: "satisfied gates leave the devices",
+			gates: []spec.DriverVersionGate{
+				{Resource: "nvidia.com/gpu", MinDriver: "535", MinCUDA: "12.2"},
+			},
+			expectedUnmet:     map[spec.ResourceName]string{},
+			expectedDeviceMap: deviceMap,
+		},
+		{
+			description: "devices below the minimum driver are not advertised",
+			gates: []spec.DriverVersionGate{
+				{Resource: "nvidia.com/gpu", MinDriver: "550.54.15"},
+			},
+			expectedUnmet: map[spec.ResourceName]string{
+				"nvidia.com/gpu": "driver version 535.104.05 < 550.54.15",
+			},
+			expectedDeviceMap: DeviceMap{
+				"nvidia.com/gpu-unaffected": Devices{gpu1.ID: gpu1},
+			},
+		},
+		{
+			description: "devices below the minimum CUDA version are renamed",
+			gates: []spec.DriverVersionGate{
+				{Resource: "nvidia.com/gpu", MinCUDA: "12.4", Rename: "nvidia.com/gpu-old-driver"},
+			},
+			expectedUnmet: map[spec.ResourceName]string{
+				"nvidia.com/gpu": "CUDA version 12.2 < 12.4",
+			},
+			expectedDeviceMap: DeviceMap{
+				"nvidia.com/gpu-old-driver": Devices{gpu0.ID: gpu0},
+				"nvidia.com/gpu-unaffected": Devices{gpu1.ID: gpu1},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			unmet, err := UnmetDriverVersionGates(tc.gates, versions)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedUnmet, unmet)
+			require.EqualValues(t, tc.expectedDeviceMap, deviceMap.applyDriverVersionGates(tc.gates, unmet))
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// PhysicalDevice identifies a GPU or MIG device on the node, regardless of
+// whether it is advertised by the plugin.
+type PhysicalDevice struct {
+	Index      string `json:"index"`
+	UUID       string `json:"uuid"`
+	Name       string `json:"name"`
+	PCIBusID   string `json:"pciBusID,omitempty"`
+	MigEnabled bool   `json:"migEnabled,omitempty"`
+	// Parent is the UUID of the parent GPU of a MIG device.
+	Parent string `json:"parent,omitempty"`
+}
+
+// Explanation describes how a physical device is advertised by the plugin.
+// If the device is not advertised, the reason is set instead.
+type Explanation struct {
+	PhysicalDevice
+	Resources []ResourceExplanation `json:"resources,omitempty"`
+	Reason    string                `json:"reason,omitempty"`
+}
+
+// ResourceExplanation describes the devices advertised for a physical device
+// under a single resource name.
+type ResourceExplanation struct {
+	Name        spec.ResourceName    `json:"name"`
+	IDs         []string             `json:"ids"`
+	Sharing     spec.SharingStrategy `json:"sharing,omitempty"`
+	RenamedFrom spec.ResourceName    `json:"renamedFrom,omitempty"`
+}
+
+// GetPhysicalDevices returns the GPUs and MIG devices on the node.
+// NVML must be initialized by the caller.
+func GetPhysicalDevices(devicelib device.Interface) ([]PhysicalDevice, error) {
+	var devices []PhysicalDevice
+	err := devicelib.VisitDevices(func(i int, gpu device.Device) error {
+		uuid, ret := gpu.GetUUID()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting UUID of GPU %d: %v", i, ret)
+		}
+		name, ret := gpu.GetName()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting name of GPU %d: %v", i, ret)
+		}
+		pciInfo, ret := gpu.GetPciInfo()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting PCI info of GPU %d: %v", i, ret)
+		}
+		migEnabled, err := gpu.IsMigEnabled()
+		if err != nil {
+			return fmt.Errorf("error checking if MIG is enabled on GPU %d: %v", i, err)
+		}
+		devices = append(devices, PhysicalDevice{
+			Index:      fmt.Sprintf("%d", i),
+			UUID:       uuid,
+			Name:       name,
+			PCIBusID:   int8Slice(pciInfo.BusId[:]).String(),
+			MigEnabled: migEnabled,
+		})
+		if !migEnabled {
+			return nil
+		}
+		return gpu.VisitMigDevices(func(j int, mig device.MigDevice) error {
+			migUUID, ret := mig.GetUUID()
+			if ret != nvml.SUCCESS {
+				return fmt.Errorf("error getting UUID of MIG device %d:%d: %v", i, j, ret)
+			}
+			profile, err := mig.GetProfile()
+			if err != nil {
+				return fmt.Errorf("error getting profile of MIG device %d:%d: %v", i, j, err)
+			}
+			devices = append(devices, PhysicalDevice{
+				Index:  fmt.Sprintf("%d:%d", i, j),
+				UUID:   migUUID,
+				Name:   profile.String(),
+				Parent: uuid,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// Explain maps each of the specified physical devices to the resources that
+// it is advertised under in the device map built from the specified config.
+func Explain(physical []PhysicalDevice, deviceMap DeviceMap, config *spec.Config) []Explanation {
+	renamedFrom := make(map[spec.ResourceName]spec.ResourceName)
+	for _, rrs := range config.Sharing.AllReplicatedResources() {
+		for _, r := range rrs.Resources {
+			if r.Rename != "" {
+				renamedFrom[r.Rename] = r.Name
+			}
+		}
+	}
+
+	gpus := make(map[string]PhysicalDevice)
+	for _, p := range physical {
+		if p.Parent == "" {
+			gpus[p.UUID] = p
+		}
+	}
+
+	var names []spec.ResourceName
+	for name := range deviceMap {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	var explanations []Explanation
+	for _, p := range physical {
+		e := Explanation{PhysicalDevice: p}
+		for _, name := range names {
+			var ids []string
+			var replicated bool
+			for _, d := range deviceMap[name] {
+				if d.GetUUID() != p.UUID {
+					continue
+				}
+				ids = append(ids, d.ID)
+				replicated = replicated || d.Replicas > 0
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			sort.Strings(ids)
+			r := ResourceExplanation{Name: name, IDs: ids}
+			if replicated {
+				r.Sharing = config.Sharing.StrategyForResource(name)
+				r.RenamedFrom = renamedFrom[name]
+			}
+			e.Resources = append(e.Resources, r)
+		}
+		if len(e.Resources) == 0 {
+			e.Reason = notAdvertisedReason(p, gpus, config)
+		}
+		explanations = append(explanations, e)
+	}
+	return explanations
+}
+
+// notAdvertisedReason returns the reason for a physical device not being
+// present in the device map. Devices that do not match any resource pattern
+// cause the device map to fail to build and are not considered here.
+func notAdvertisedReason(p PhysicalDevice, gpus map[string]PhysicalDevice, config *spec.Config) string {
+	migStrategy := spec.MigStrategyNone
+	if config.Flags.MigStrategy != nil {
+		migStrategy = *config.Flags.MigStrategy
+	}
+	gpu := p
+	if p.Parent != "" {
+		gpu = gpus[p.Parent]
+	}
+	index, _ := strconv.Atoi(gpu.Index)
+	selected := config.Devices.Selects(spec.DeviceIdentifiers{
+		Index:    index,
+		UUID:     gpu.UUID,
+		PCIBusID: gpu.PCIBusID,
+		Name:     gpu.Name,
+	})
+	switch {
+	case !selected && p.Parent != "":
+		return "the parent GPU is excluded by the include and exclude filters of the config"
+	case !selected:
+		return "the GPU is excluded by the include and exclude filters of the config"
+	case p.Parent != "" && migStrategy == spec.MigStrategyNone:
+		return "MIG devices are not advertised with the none MIG strategy"
+	case p.MigEnabled && migStrategy != spec.MigStrategyNone:
+		return fmt.Sprintf("MIG is enabled; the GPU is advertised through its MIG devices with the %v MIG strategy", migStrategy)
+	default:
+		return "the device is excluded by the compute capability, BAR1 memory or driver version gates of the config"
+	}
+}
@@ -0,0 +1,97 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestExplain(t *testing.T) {
+	physical := []PhysicalDevice{
+		{Index: "0", UUID: "GPU-0", Name: "NVIDIA A100"},
+		{Index: "1", UUID: "GPU-1", Name: "NVIDIA A100", MigEnabled: true},
+		{Index: "1:0", UUID: "MIG-0", Name: "3g.20gb", Parent: "GPU-1"},
+		{Index: "2", UUID: "GPU-2", Name: "NVIDIA T4"},
+		{Index: "3", UUID: "GPU-3", Name: "NVIDIA A100", PCIBusID: "00000000:3B:00.0"},
+	}
+
+	replica := func(id string, replicas int) *Device {
+		return &Device{Device: pluginapi.Device{ID: id}, Replicas: replicas}
+	}
+	deviceMap := DeviceMap{
+		"nvidia.com/gpu.shared": Devices{
+			"GPU-0::1": replica("GPU-0::1", 2),
+			"GPU-0::0": replica("GPU-0::0", 2),
+		},
+		"nvidia.com/mig-3g.20gb": Devices{
+			"MIG-0": replica("MIG-0", 0),
+		},
+	}
+
+	migStrategy := spec.MigStrategyMixed
+	config := &spec.Config{
+		Flags: spec.Flags{CommandLineFlags: spec.CommandLineFlags{MigStrategy: &migStrategy}},
+		Sharing: spec.Sharing{
+			TimeSlicing: spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{Name: "nvidia.com/gpu", Rename: "nvidia.com/gpu.shared", Replicas: 2},
+				},
+			},
+		},
+		Devices: &spec.Devices{
+			Exclude: []spec.DeviceFilter{"3b:00.0"},
+		},
+	}
+
+	expected := []Explanation{
+		{
+			PhysicalDevice: physical[0],
+			Resources: []ResourceExplanation{
+				{
+					Name:        "nvidia.com/gpu.shared",
+					IDs:         []string{"GPU-0::0", "GPU-0::1"},
+					Sharing:     spec.SharingStrategyTimeSlicing,
+					RenamedFrom: "nvidia.com/gpu",
+				},
+			},
+		},
+		{
+			PhysicalDevice: physical[1],
+			Reason:         "MIG is enabled; the GPU is advertised through its MIG devices with the mixed MIG strategy",
+		},
+		{
+			PhysicalDevice: physical[2],
+			Resources: []ResourceExplanation{
+				{Name: "nvidia.com/mig-3g.20gb", IDs: []string{"MIG-0"}},
+			},
+		},
+		{
+			PhysicalDevice: physical[3],
+			Reason:         "the device is excluded by the compute capability, BAR1 memory or driver version gates of the config",
+		},
+		{
+			PhysicalDevice: physical[4],
+			Reason:         "the GPU is excluded by the include and exclude filters of the config",
+		},
+	}
+	require.Equal(t, expected, Explain(physical, deviceMap, config))
+}
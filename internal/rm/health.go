@@ -17,13 +17,18 @@
 package rm
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
 )
 
 const (
@@ -35,6 +40,9 @@ const (
 	allHealthChecks        = "xids"
 )
 
+// healthReattachInterval is the interval at which a lost connection to NVML is retried.
+var healthReattachInterval = 5 * time.Second
+
 // CheckHealth performs health checks on a set of devices, writing to the 'unhealthy' channel with any unhealthy devices
 func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devices, unhealthy chan<- *Device) error {
 	disableHealthChecks := strings.ToLower(os.Getenv(envDisableHealthChecks))
@@ -45,20 +53,49 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 		return nil
 	}
 
-	ret := r.nvml.Init()
-	if ret != nvml.SUCCESS {
-		if *r.config.Flags.FailOnInitError {
-			return fmt.Errorf("failed to initialize NVML: %v", ret)
-		}
+	resourceHealth := r.config.Health.ForResource(r.resource)
+	if !resourceHealth.IsEnabled(spec.HealthCheckAll) {
+		klog.Infof("Health checks are disabled for resource %v", r.resource)
 		return nil
 	}
-	defer func() {
-		ret := r.nvml.Shutdown()
-		if ret != nvml.SUCCESS {
-			klog.Infof("Error shutting down NVML: %v", ret)
+
+	if r.dcgm != nil && resourceHealth.IsEnabled(spec.HealthCheckDCGM) {
+		go r.checkDCGMHealth(stop, devices, unhealthy)
+	}
+	if r.config.Health.GetThermal() != nil && resourceHealth.IsEnabled(spec.HealthCheckThermal) {
+		go r.checkThermalHealth(stop, devices, unhealthy)
+	}
+	if r.config.Health.GetECC() != nil && resourceHealth.IsEnabled(spec.HealthCheckECC) {
+		go r.checkECCHealth(stop, devices, unhealthy)
+	}
+	if !resourceHealth.IsEnabled(spec.HealthCheckXids) {
+		klog.Infof("Xid health checks are disabled for resource %v", r.resource)
+		return nil
+	}
+
+	skippedXids := getSkippedXids(disableHealthChecks, r.config.Health, resourceHealth)
+	for {
+		err := r.watchHealthEvents(stop, devices, unhealthy, skippedXids)
+		if !errors.Is(err, nvcaps.ErrUnavailable) {
+			return err
 		}
-	}()
+		// If NVML is provided by an out-of-process broker, the broker may be
+		// restarted (e.g. after it lost the driver). We reattach instead of
+		// marking all devices as unhealthy.
+		klog.Warningf("Lost connection to NVML: %v; reattaching in %v", err, healthReattachInterval)
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(healthReattachInterval):
+		}
+	}
+}
 
+// getSkippedXids returns the Xids that do not mark devices of a resource as
+// unhealthy. The fatal Xids of the config take precedence over the application
+// errors, the Xids skipped through the envvar, and the ignored Xids, while the
+// Xids skipped for the resource take precedence over all others.
+func getSkippedXids(disableHealthChecks string, health *spec.Health, resourceHealth spec.HealthResource) map[uint64]bool {
 	// FIXME: formalize the full list and document it.
 	// http://docs.nvidia.com/deploy/xid-errors/index.html#topic_4
 	// Application errors: the GPU should still be healthy
@@ -78,51 +115,96 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 	for _, additionalXid := range getAdditionalXids(disableHealthChecks) {
 		skippedXids[additionalXid] = true
 	}
+	for _, xid := range health.GetIgnoredXids() {
+		skippedXids[xid] = true
+	}
+	for _, xid := range health.GetFatalXids() {
+		delete(skippedXids, xid)
+	}
+	for _, xid := range resourceHealth.SkippedXids {
+		skippedXids[xid] = true
+	}
+	return skippedXids
+}
+
+// nextHealthCheck returns a channel that fires once the specified interval of
+// a periodic health check, extended by a random jitter of up to the configured
+// fraction of the interval, has elapsed.
+func (r *nvmlResourceManager) nextHealthCheck(interval time.Duration) <-chan time.Time {
+	jitter := time.Duration(rand.Float64() * r.config.Health.GetIntervalJitter() * float64(interval))
+	return time.After(interval + jitter)
+}
+
+// watchHealthEvents registers the devices for health events and processes events until the stop channel is closed.
+// An error wrapping nvcaps.ErrUnavailable is returned if the connection to NVML is lost.
+func (r *nvmlResourceManager) watchHealthEvents(stop <-chan interface{}, devices Devices, unhealthy chan<- *Device, skippedXids map[uint64]bool) error {
+	if err := r.nvcaps.Init(); err != nil {
+		if errors.Is(err, nvcaps.ErrUnavailable) {
+			return err
+		}
+		if *r.config.Flags.FailOnInitError {
+			return fmt.Errorf("failed to initialize NVML: %w", err)
+		}
+		return nil
+	}
+	defer func() {
+		if err := r.nvcaps.Shutdown(); err != nil {
+			klog.Infof("Error shutting down NVML: %v", err)
+		}
+	}()
 
-	eventSet, ret := r.nvml.EventSetCreate()
-	if ret != nvml.SUCCESS {
-		return fmt.Errorf("failed to create event set: %v", ret)
+	eventSet, err := r.nvcaps.EventSetCreate()
+	if err != nil {
+		return fmt.Errorf("failed to create event set: %w", err)
 	}
 	defer func() {
-		_ = eventSet.Free()
+		_ = r.nvcaps.EventSetFree(eventSet)
 	}()
 
 	parentToDeviceMap := make(map[string]*Device)
+	parentToDevicesMap := make(map[string][]*Device)
 	deviceIDToGiMap := make(map[string]int)
 	deviceIDToCiMap := make(map[string]int)
 
-	eventMask := uint64(nvml.EventTypeXidCriticalError | nvml.EventTypeDoubleBitEccError | nvml.EventTypeSingleBitEccError)
+	// The time of the last Xid of each device that was marked unhealthy by an
+	// Xid is kept on the resource manager, so that the device recovers once
+	// the configured cool-down has passed without further Xids, even if NVML
+	// is reattached in the meantime.
+	cooldown := r.config.Health.GetXidRecoveryCooldown()
+	markXidUnhealthy := func(d *Device) {
+		if cooldown > 0 {
+			r.xidCooldowns.mark(d)
+		}
+		r.unhealthy.add(d, HealthEventReasonXid)
+		unhealthy <- d
+	}
+
+	eventMask := nvcaps.EventTypeXidCriticalError | nvcaps.EventTypeDoubleBitEccError | nvcaps.EventTypeSingleBitEccError
 	for _, d := range devices {
 		uuid, gi, ci, err := r.getDevicePlacement(d)
 		if err != nil {
 			klog.Warningf("Could not determine device placement for %v: %v; Marking it unhealthy.", d.ID, err)
+			r.reportHealthEvent(d, HealthEventReasonHealthCheckFail, true, "Could not determine device placement: %v", err)
+			r.unhealthy.add(d, HealthEventReasonHealthCheckFail)
 			unhealthy <- d
 			continue
 		}
 		deviceIDToGiMap[d.ID] = gi
 		deviceIDToCiMap[d.ID] = ci
 		parentToDeviceMap[uuid] = d
+		parentToDevicesMap[uuid] = append(parentToDevicesMap[uuid], d)
 
-		gpu, ret := r.nvml.DeviceGetHandleByUUID(uuid)
-		if ret != nvml.SUCCESS {
-			klog.Infof("unable to get device handle from UUID: %v; marking it as unhealthy", ret)
-			unhealthy <- d
-			continue
-		}
-
-		supportedEvents, ret := gpu.GetSupportedEventTypes()
-		if ret != nvml.SUCCESS {
-			klog.Infof("Unable to determine the supported events for %v: %v; marking it as unhealthy", d.ID, ret)
-			unhealthy <- d
-			continue
+		err = r.nvcaps.RegisterEvents(eventSet, uuid, eventMask)
+		if errors.Is(err, nvcaps.ErrUnavailable) {
+			return err
 		}
-
-		ret = gpu.RegisterEvents(eventMask&supportedEvents, eventSet)
-		if ret == nvml.ERROR_NOT_SUPPORTED {
+		if errors.Is(err, nvcaps.ErrNotSupported) {
 			klog.Warningf("Device %v is too old to support healthchecking.", d.ID)
 		}
-		if ret != nvml.SUCCESS {
-			klog.Infof("Marking device %v as unhealthy: %v", d.ID, ret)
+		if err != nil {
+			klog.Infof("Marking device %v as unhealthy: %v", d.ID, err)
+			r.reportHealthEvent(d, HealthEventReasonHealthCheckFail, true, "Failed to register for health events: %v", err)
+			r.unhealthy.add(d, HealthEventReasonHealthCheckFail)
 			unhealthy <- d
 		}
 	}
@@ -134,56 +216,95 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 		default:
 		}
 
-		e, ret := eventSet.Wait(5000)
-		if ret == nvml.ERROR_TIMEOUT {
+		for _, d := range r.xidCooldowns.expired(cooldown) {
+			if !r.unhealthy.clear(d, HealthEventReasonXid) {
+				klog.Infof("No Xids on Device=%s for %v; device remains unhealthy for other reasons.", d.ID, cooldown)
+				continue
+			}
+			klog.Infof("No Xids on Device=%s for %v; marking device as healthy.", d.ID, cooldown)
+			select {
+			case r.recovered <- d:
+			case <-stop:
+				return nil
+			}
+		}
+
+		e, err := r.nvcaps.EventSetWait(eventSet, 5000)
+		if errors.Is(err, nvcaps.ErrTimeout) {
 			continue
 		}
-		if ret != nvml.SUCCESS {
-			klog.Infof("Error waiting for event: %v; Marking all devices as unhealthy", ret)
+		if errors.Is(err, nvcaps.ErrUnavailable) {
+			return err
+		}
+		if errors.Is(err, nvcaps.ErrUnknownEventSet) {
+			return fmt.Errorf("%w: %v", nvcaps.ErrUnavailable, err)
+		}
+		if err != nil {
+			klog.Infof("Error waiting for event: %v; Marking all devices as unhealthy", err)
 			for _, d := range devices {
+				r.reportHealthEvent(d, HealthEventReasonHealthCheckFail, true, "Failed to wait for health events: %v", err)
+				r.unhealthy.add(d, HealthEventReasonHealthCheckFail)
 				unhealthy <- d
 			}
 			continue
 		}
 
-		if e.EventType != nvml.EventTypeXidCriticalError {
+		// Record all events for known devices, including those that do not
+		// mark the device as unhealthy, so that the device can be
+		// deprioritized in preferred allocations. Events on a parent GPU are
+		// recorded for all its MIG devices.
+		for _, d := range parentToDevicesMap[e.UUID] {
+			r.history.record(d.GetUUID())
+			switch e.Type {
+			case nvcaps.EventTypeDoubleBitEccError:
+				r.reportHealthEvent(d, HealthEventReasonDoubleBitECC, false, "Double-bit ECC error on GPU %v", e.UUID)
+			case nvcaps.EventTypeSingleBitEccError:
+				r.reportHealthEvent(d, HealthEventReasonSingleBitECC, false, "Single-bit ECC error on GPU %v", e.UUID)
+			}
+		}
+
+		if e.Type != nvcaps.EventTypeXidCriticalError {
 			klog.Infof("Skipping non-nvmlEventTypeXidCriticalError event: %+v", e)
 			continue
 		}
 
-		if skippedXids[e.EventData] {
+		if skippedXids[e.Data] {
 			klog.Infof("Skipping event %+v", e)
+			for _, d := range parentToDevicesMap[e.UUID] {
+				r.reportXidEvent(d, e.Data, false, "Xid %d on GPU %v", e.Data, e.UUID)
+			}
 			continue
 		}
 
 		klog.Infof("Processing event %+v", e)
-		eventUUID, ret := e.Device.GetUUID()
-		if ret != nvml.SUCCESS {
+		if e.UUID == "" {
 			// If we cannot reliably determine the device UUID, we mark all devices as unhealthy.
-			klog.Infof("Failed to determine uuid for event %v: %v; Marking all devices as unhealthy.", e, ret)
+			klog.Infof("Failed to determine uuid for event %v; Marking all devices as unhealthy.", e)
 			for _, d := range devices {
-				unhealthy <- d
+				r.reportXidEvent(d, e.Data, true, "Xid %d on an unknown GPU", e.Data)
+				markXidUnhealthy(d)
 			}
 			continue
 		}
 
-		d, exists := parentToDeviceMap[eventUUID]
+		d, exists := parentToDeviceMap[e.UUID]
 		if !exists {
-			klog.Infof("Ignoring event for unexpected device: %v", eventUUID)
+			klog.Infof("Ignoring event for unexpected device: %v", e.UUID)
 			continue
 		}
 
-		if d.IsMigDevice() && e.GpuInstanceId != 0xFFFFFFFF && e.ComputeInstanceId != 0xFFFFFFFF {
+		if d.IsMigDevice() && e.GpuInstanceID != nvcaps.InvalidInstanceID && e.ComputeInstanceID != nvcaps.InvalidInstanceID {
 			gi := deviceIDToGiMap[d.ID]
 			ci := deviceIDToCiMap[d.ID]
-			if !(uint32(gi) == e.GpuInstanceId && uint32(ci) == e.ComputeInstanceId) {
+			if !(uint32(gi) == e.GpuInstanceID && uint32(ci) == e.ComputeInstanceID) {
 				continue
 			}
 			klog.Infof("Event for mig device %v (gi=%v, ci=%v)", d.ID, gi, ci)
 		}
 
-		klog.Infof("XidCriticalError: Xid=%d on Device=%s; marking device as unhealthy.", e.EventData, d.ID)
-		unhealthy <- d
+		klog.Infof("XidCriticalError: Xid=%d on Device=%s; marking device as unhealthy.", e.Data, d.ID)
+		r.reportXidEvent(d, e.Data, true, "Xid %d on GPU %v", e.Data, e.UUID)
+		markXidUnhealthy(d)
 	}
 }
 
@@ -217,7 +338,7 @@ func getAdditionalXids(input string) []uint64 {
 // For a full device the returned 3-tuple is the device's uuid and 0xFFFFFFFF for the other two elements.
 func (r *nvmlResourceManager) getDevicePlacement(d *Device) (string, int, int, error) {
 	if !d.IsMigDevice() {
-		return d.GetUUID(), 0xFFFFFFFF, 0xFFFFFFFF, nil
+		return d.GetUUID(), nvcaps.InvalidInstanceID, nvcaps.InvalidInstanceID, nil
 	}
 	return r.getMigDeviceParts(d)
 }
@@ -229,30 +350,15 @@ func (r *nvmlResourceManager) getMigDeviceParts(d *Device) (string, int, int, er
 	}
 
 	uuid := d.GetUUID()
-	// For older driver versions, the call to DeviceGetHandleByUUID will fail for MIG devices.
-	mig, ret := r.nvml.DeviceGetHandleByUUID(uuid)
-	if ret == nvml.SUCCESS {
-		parentHandle, ret := mig.GetDeviceHandleFromMigDeviceHandle()
-		if ret != nvml.SUCCESS {
-			return "", 0, 0, fmt.Errorf("failed to get parent device handle: %v", ret)
-		}
-
-		parentUUID, ret := parentHandle.GetUUID()
-		if ret != nvml.SUCCESS {
-			return "", 0, 0, fmt.Errorf("failed to get parent uuid: %v", ret)
-		}
-		gi, ret := mig.GetGpuInstanceId()
-		if ret != nvml.SUCCESS {
-			return "", 0, 0, fmt.Errorf("failed to get GPU Instance ID: %v", ret)
-		}
-
-		ci, ret := mig.GetComputeInstanceId()
-		if ret != nvml.SUCCESS {
-			return "", 0, 0, fmt.Errorf("failed to get Compute Instance ID: %v", ret)
-		}
-		return parentUUID, gi, ci, nil
+	// For older driver versions, the lookup of the device handle will fail for MIG devices.
+	placement, err := r.nvcaps.GetMigDevicePlacement(uuid)
+	if errors.Is(err, nvcaps.ErrDeviceNotFound) {
+		return parseMigDeviceUUID(uuid)
+	}
+	if err != nil {
+		return "", 0, 0, err
 	}
-	return parseMigDeviceUUID(uuid)
+	return placement.ParentUUID, placement.GpuInstanceID, placement.ComputeInstanceID, nil
 }
 
 // parseMigDeviceUUID splits the MIG device UUID into the parent device UUID and ci and gi
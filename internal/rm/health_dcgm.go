@@ -0,0 +1,93 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/dcgm"
+)
+
+// checkDCGMHealth periodically checks the DCGM health watches for the specified devices until the stop channel
+// is closed, writing to the 'unhealthy' channel with any devices that have incidents of the configured severity.
+// All incidents are recorded as health events for the devices.
+func (r *nvmlResourceManager) checkDCGMHealth(stop <-chan interface{}, devices Devices, unhealthy chan<- *Device) {
+	config := r.config.Health.GetDCGM()
+
+	minSeverity := make(map[dcgm.System]dcgm.Severity)
+	var systems []dcgm.System
+	for _, c := range config.GetChecks() {
+		system := dcgm.System(c.System)
+		systems = append(systems, system)
+		minSeverity[system] = dcgm.SeverityFailure
+		if c.Severity == spec.DCGMHealthSeverityWarning {
+			minSeverity[system] = dcgm.SeverityWarning
+		}
+	}
+
+	if err := r.dcgm.SetWatches(systems); err != nil {
+		klog.Warningf("Failed to set DCGM health watches: %v; continuing with DCGM health checks disabled", err)
+		return
+	}
+
+	// DCGM reports incidents against the parent GPU of a MIG device.
+	devicesByGPU := make(map[int][]*Device)
+	for _, d := range devices {
+		index, err := strconv.Atoi(strings.SplitN(d.Index, ":", 2)[0])
+		if err != nil {
+			klog.Warningf("Ignoring device %v with unexpected index %q for DCGM health checks", d.ID, d.Index)
+			continue
+		}
+		devicesByGPU[index] = append(devicesByGPU[index], d)
+	}
+
+	reported := make(map[string]bool)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-r.nextHealthCheck(config.GetInterval()):
+		}
+
+		incidents, err := r.dcgm.Check()
+		if err != nil {
+			klog.Warningf("Failed to check DCGM health: %v", err)
+			continue
+		}
+		for _, incident := range incidents {
+			for _, d := range devicesByGPU[incident.GPU] {
+				r.history.record(d.GetUUID())
+				if incident.Severity < minSeverity[incident.System] || reported[d.ID] {
+					continue
+				}
+				klog.Infof("DCGM %v health incident on Device=%s: %v; marking device as unhealthy.", incident.System, d.ID, incident.Message)
+				reported[d.ID] = true
+				r.reportHealthEvent(d, dcgmHealthEventReason(incident.System), true, "DCGM %v health incident: %v", incident.System, incident.Message)
+				r.unhealthy.add(d, dcgmHealthEventReason(incident.System))
+				select {
+				case unhealthy <- d:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
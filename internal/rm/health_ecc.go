@@ -0,0 +1,154 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
+)
+
+// checkECCHealth periodically checks the uncorrected ECC error counters of the GPUs backing the specified devices
+// until the stop channel is closed. Devices on GPUs whose counter increased since the previous check are written to
+// the 'unhealthy' channel. This catches uncorrected errors for which no NVML event was delivered. The devices recover
+// once the volatile counter of their GPU is reset, e.g. by a GPU reset, or once the configured recovery cool-down has
+// passed without new errors.
+func (r *nvmlResourceManager) checkECCHealth(stop <-chan interface{}, devices Devices, unhealthy chan<- *Device) {
+	config := r.config.Health.GetECC()
+
+	if err := r.nvcaps.Init(); err != nil {
+		klog.Warningf("Failed to initialize NVML: %v; continuing with ECC health checks disabled", err)
+		return
+	}
+	defer func() {
+		if err := r.nvcaps.Shutdown(); err != nil {
+			klog.Infof("Error shutting down NVML: %v", err)
+		}
+	}()
+
+	// ECC errors are counted for the parent GPU of a MIG device.
+	gpus := make(map[string][]*Device)
+	for _, d := range devices {
+		uuid, _, _, err := r.getDevicePlacement(d)
+		if err != nil {
+			klog.Warningf("Could not determine parent GPU of %v: %v; skipping ECC health checks", d.ID, err)
+			continue
+		}
+		gpus[uuid] = append(gpus[uuid], d)
+	}
+
+	// The first successful read of the counter of a GPU is its baseline, so
+	// that errors that occurred before the plugin was started are ignored.
+	counts := make(map[string]uint64)
+	for uuid := range gpus {
+		count, err := r.nvcaps.GetUncorrectedECCErrors(uuid)
+		if errors.Is(err, nvcaps.ErrNotSupported) {
+			klog.Infof("ECC error counters are not supported on GPU %v; skipping ECC health checks", uuid)
+			delete(gpus, uuid)
+			continue
+		}
+		if err != nil {
+			klog.Warningf("Failed to get ECC error counters of GPU %v: %v", uuid, err)
+			continue
+		}
+		counts[uuid] = count
+	}
+	if len(gpus) == 0 {
+		return
+	}
+
+	// lastErrors holds the time of the last new errors of each GPU whose
+	// devices were marked unhealthy.
+	cooldown := config.GetRecoveryCooldown()
+	lastErrors := make(map[string]time.Time)
+	reported := make(map[string]bool)
+	recoverGPU := func(uuid string, reason string) bool {
+		delete(lastErrors, uuid)
+		for _, d := range gpus[uuid] {
+			if !reported[d.ID] {
+				continue
+			}
+			delete(reported, d.ID)
+			if !r.unhealthy.clear(d, HealthEventReasonDoubleBitECC) {
+				klog.Infof("%v; Device=%s remains unhealthy for other reasons.", reason, d.ID)
+				continue
+			}
+			klog.Infof("%v; marking Device=%s as healthy.", reason, d.ID)
+			select {
+			case r.recovered <- d:
+			case <-stop:
+				return false
+			}
+		}
+		return true
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-r.nextHealthCheck(config.GetInterval()):
+		}
+
+		for uuid, gpuDevices := range gpus {
+			count, err := r.nvcaps.GetUncorrectedECCErrors(uuid)
+			if err != nil {
+				klog.Warningf("Failed to get ECC error counters of GPU %v: %v", uuid, err)
+				continue
+			}
+			previous, exists := counts[uuid]
+			counts[uuid] = count
+			if !exists {
+				continue
+			}
+			if count < previous {
+				if !recoverGPU(uuid, fmt.Sprintf("ECC error counters of GPU %v were reset", uuid)) {
+					return
+				}
+				continue
+			}
+			if count == previous {
+				last, unhealthy := lastErrors[uuid]
+				if unhealthy && cooldown > 0 && time.Since(last) >= cooldown {
+					if !recoverGPU(uuid, fmt.Sprintf("No new ECC errors on GPU %v for %v", uuid, cooldown)) {
+						return
+					}
+				}
+				continue
+			}
+			lastErrors[uuid] = time.Now()
+			for _, d := range gpuDevices {
+				r.history.record(d.GetUUID())
+				if reported[d.ID] {
+					continue
+				}
+				klog.Infof("GPU %v has %d new uncorrected ECC errors; marking Device=%s as unhealthy.", uuid, count-previous, d.ID)
+				reported[d.ID] = true
+				r.reportHealthEvent(d, HealthEventReasonDoubleBitECC, true, "%d new uncorrected ECC errors on GPU %v", count-previous, uuid)
+				r.unhealthy.add(d, HealthEventReasonDoubleBitECC)
+				select {
+				case unhealthy <- d:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
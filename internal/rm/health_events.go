@@ -0,0 +1,116 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"fmt"
+	"time"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/dcgm"
+	"github.com/NVIDIA/k8s-device-plugin/internal/location"
+)
+
+// The reasons of the health events reported for devices.
+const (
+	HealthEventReasonXid             = "GPUXidError"
+	HealthEventReasonDoubleBitECC    = "GPUDoubleBitECCError"
+	HealthEventReasonSingleBitECC    = "GPUSingleBitECCError"
+	HealthEventReasonFabric          = "GPUFabricError"
+	HealthEventReasonHealthIncident  = "GPUHealthIncident"
+	HealthEventReasonHealthCheckFail = "GPUHealthCheckFailed"
+	HealthEventReasonValidation      = "GPUStartupValidationFailed"
+	HealthEventReasonThermal         = "GPUThermalThreshold"
+)
+
+// HealthEvent is a health event that was detected for a device.
+type HealthEvent struct {
+	Timestamp time.Time
+	Resource  spec.ResourceName
+	DeviceID  string
+	// Location is the physical location of the device, or nil if unknown.
+	Location *location.Location
+	Reason   string
+	Message  string
+	// Xid is the Xid of an event with the GPUXidError reason, or 0 for other events.
+	Xid uint64
+	// Unhealthy indicates whether the device was marked unhealthy as a result of the event.
+	Unhealthy bool
+}
+
+// HealthEventReporter defines the API used to report the health events detected by a resource manager.
+type HealthEventReporter interface {
+	ReportHealthEvent(HealthEvent)
+}
+
+// HealthEventReporters reports health events to multiple reporters.
+type HealthEventReporters []HealthEventReporter
+
+// ReportHealthEvent reports the health event to each of the reporters.
+func (r HealthEventReporters) ReportHealthEvent(e HealthEvent) {
+	for _, reporter := range r {
+		reporter.ReportHealthEvent(e)
+	}
+}
+
+// WithHealthEventReporter sets the reporter that receives the health events of the devices.
+func WithHealthEventReporter(reporter HealthEventReporter) NVMLResourceManagerOption {
+	return func(r *nvmlResourceManager) {
+		r.healthEvents = reporter
+	}
+}
+
+// reportHealthEvent reports a health event for the specified device if a reporter is configured.
+func (r *nvmlResourceManager) reportHealthEvent(d *Device, reason string, unhealthy bool, format string, args ...interface{}) {
+	if r.healthEvents == nil {
+		return
+	}
+	r.healthEvents.ReportHealthEvent(r.newHealthEvent(d, reason, unhealthy, format, args...))
+}
+
+// reportXidEvent reports an Xid on the specified device if a reporter is
+// configured. Skipped Xids, such as application errors, are reported without
+// marking the device as unhealthy.
+func (r *nvmlResourceManager) reportXidEvent(d *Device, xid uint64, unhealthy bool, format string, args ...interface{}) {
+	if r.healthEvents == nil {
+		return
+	}
+	e := r.newHealthEvent(d, HealthEventReasonXid, unhealthy, format, args...)
+	e.Xid = xid
+	r.healthEvents.ReportHealthEvent(e)
+}
+
+func (r *nvmlResourceManager) newHealthEvent(d *Device, reason string, unhealthy bool, format string, args ...interface{}) HealthEvent {
+	return HealthEvent{
+		Timestamp: time.Now(),
+		Resource:  r.resource,
+		DeviceID:  d.ID,
+		Location:  d.Location,
+		Reason:    reason,
+		Message:   fmt.Sprintf(format, args...),
+		Unhealthy: unhealthy,
+	}
+}
+
+// dcgmHealthEventReason returns the reason of the health event for a DCGM incident.
+// NVLink incidents are reported as fabric errors.
+func dcgmHealthEventReason(system dcgm.System) string {
+	if system == dcgm.SystemNVLink {
+		return HealthEventReasonFabric
+	}
+	return HealthEventReasonHealthIncident
+}
@@ -0,0 +1,99 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"sync"
+	"time"
+)
+
+// healthHistory records the time of the most recent health event for a set of devices.
+// It is used to deprioritize devices that were recently flagged, even if they
+// are still advertised as healthy.
+type healthHistory struct {
+	sync.Mutex
+	window    time.Duration
+	lastEvent map[string]time.Time
+	now       func() time.Time
+}
+
+// newHealthHistory creates a health history with the specified decay window.
+// A nil history is returned if the window is not positive.
+func newHealthHistory(window time.Duration) *healthHistory {
+	if window <= 0 {
+		return nil
+	}
+	return &healthHistory{
+		window:    window,
+		lastEvent: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// record notes a health event for the device with the specified UUID.
+func (h *healthHistory) record(uuid string) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	h.lastEvent[uuid] = h.now()
+}
+
+// isRecent checks whether the device with the specified UUID had a health event within the decay window.
+func (h *healthHistory) isRecent(uuid string) bool {
+	if h == nil {
+		return false
+	}
+	h.Lock()
+	defer h.Unlock()
+	last, exists := h.lastEvent[uuid]
+	if !exists {
+		return false
+	}
+	if h.now().Sub(last) >= h.window {
+		delete(h.lastEvent, uuid)
+		return false
+	}
+	return true
+}
+
+// deprioritize removes the devices with recent health events from the set of
+// available devices. Required devices are always retained. If removing the
+// flagged devices would leave too few devices to satisfy the request, the
+// available devices are returned unchanged.
+func (h *healthHistory) deprioritize(available, required []string, size int) []string {
+	if h == nil {
+		return available
+	}
+	isRequired := make(map[string]bool)
+	for _, id := range required {
+		isRequired[id] = true
+	}
+
+	var filtered []string
+	for _, id := range available {
+		if !isRequired[id] && h.isRecent(AnnotatedID(id).GetID()) {
+			continue
+		}
+		filtered = append(filtered, id)
+	}
+	if len(filtered) < size {
+		return available
+	}
+	return filtered
+}
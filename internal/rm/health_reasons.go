@@ -0,0 +1,94 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// unhealthyReasons tracks the reasons for which each device was marked
+// unhealthy. A device only recovers once all of its reasons are cleared, so
+// that a check that recovers, such as the Xid cool-down, does not heal a
+// device that another check marked unhealthy.
+type unhealthyReasons struct {
+	sync.Mutex
+	reasons map[string]map[string]bool
+}
+
+// add records that the device was marked unhealthy for the specified reason.
+func (u *unhealthyReasons) add(d *Device, reason string) {
+	u.Lock()
+	defer u.Unlock()
+	if u.reasons == nil {
+		u.reasons = make(map[string]map[string]bool)
+	}
+	if u.reasons[d.ID] == nil {
+		u.reasons[d.ID] = make(map[string]bool)
+	}
+	u.reasons[d.ID][reason] = true
+}
+
+// clear removes the specified reason of the device and returns whether the
+// device recovered, i.e. whether no other reasons remain.
+func (u *unhealthyReasons) clear(d *Device, reason string) bool {
+	u.Lock()
+	defer u.Unlock()
+	delete(u.reasons[d.ID], reason)
+	if len(u.reasons[d.ID]) > 0 {
+		return false
+	}
+	delete(u.reasons, d.ID)
+	return true
+}
+
+// xidCooldowns holds the time of the last Xid of each device that was marked
+// unhealthy by an Xid.
+type xidCooldowns struct {
+	sync.Mutex
+	last map[*Device]time.Time
+}
+
+// mark records an Xid of the device.
+func (c *xidCooldowns) mark(d *Device) {
+	c.Lock()
+	defer c.Unlock()
+	if c.last == nil {
+		c.last = make(map[*Device]time.Time)
+	}
+	c.last[d] = time.Now()
+}
+
+// expired removes and returns the devices, sorted by ID, whose last Xid is
+// longer ago than the specified cool-down.
+func (c *xidCooldowns) expired(cooldown time.Duration) []*Device {
+	c.Lock()
+	defer c.Unlock()
+	var expired []*Device
+	for d, last := range c.last {
+		if time.Since(last) < cooldown {
+			continue
+		}
+		delete(c.last, d)
+		expired = append(expired, d)
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].ID < expired[j].ID
+	})
+	return expired
+}
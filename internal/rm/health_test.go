@@ -17,10 +17,19 @@
 package rm
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/dcgm"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
 )
 
 func TestGetAdditionalXids(t *testing.T) {
@@ -72,3 +81,892 @@ func TestGetAdditionalXids(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckHealth(t *testing.T) {
+	cooldown := spec.Duration(time.Nanosecond)
+	testCases := []struct {
+		description       string
+		health            *spec.Health
+		notSupported      map[string]bool
+		events            []nvcaps.Event
+		expectedDisabled  bool
+		expectedUnhealthy []string
+		expectedRecovered []string
+		expectedRecent    []string
+		expectedEvents    []string
+	}{
+		{
+			description: "no events",
+		},
+		{
+			description: "critical xid marks device unhealthy",
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79, GpuInstanceID: nvcaps.InvalidInstanceID, ComputeInstanceID: nvcaps.InvalidInstanceID},
+			},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecent:    []string{"GPU-1"},
+			expectedEvents:    []string{"GPU-1/GPUXidError/true"},
+		},
+		{
+			description: "application xid is skipped but reported",
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 43},
+			},
+			expectedRecent: []string{"GPU-1"},
+			expectedEvents: []string{"GPU-1/GPUXidError/false"},
+		},
+		{
+			description: "non-xid event is skipped",
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeSingleBitEccError},
+			},
+			expectedRecent: []string{"GPU-1"},
+			expectedEvents: []string{"GPU-1/GPUSingleBitECCError/false"},
+		},
+		{
+			description: "event for unknown device is ignored",
+			events: []nvcaps.Event{
+				{UUID: "GPU-2", Type: nvcaps.EventTypeXidCriticalError, Data: 79},
+			},
+		},
+		{
+			description: "event without uuid marks all devices unhealthy",
+			events: []nvcaps.Event{
+				{Type: nvcaps.EventTypeXidCriticalError, Data: 79},
+			},
+			expectedUnhealthy: []string{"GPU-0", "GPU-1"},
+			expectedEvents:    []string{"GPU-0/GPUXidError/true", "GPU-1/GPUXidError/true"},
+		},
+		{
+			description: "xid skipped for the resource",
+			health: &spec.Health{
+				Resources: []spec.HealthResource{
+					{Name: "nvidia.com/gpu", SkippedXids: []uint64{79}},
+				},
+			},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79},
+			},
+			expectedRecent: []string{"GPU-1"},
+			expectedEvents: []string{"GPU-1/GPUXidError/false"},
+		},
+		{
+			description: "ignored xid is skipped",
+			health:      &spec.Health{IgnoredXids: []uint64{79}},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79},
+			},
+			expectedRecent: []string{"GPU-1"},
+			expectedEvents: []string{"GPU-1/GPUXidError/false"},
+		},
+		{
+			description: "fatal application xid marks device unhealthy",
+			health:      &spec.Health{FatalXids: []uint64{43}},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 43, GpuInstanceID: nvcaps.InvalidInstanceID, ComputeInstanceID: nvcaps.InvalidInstanceID},
+			},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecent:    []string{"GPU-1"},
+			expectedEvents:    []string{"GPU-1/GPUXidError/true"},
+		},
+		{
+			description: "device recovers after the cool-down",
+			health:      &spec.Health{XidRecoveryCooldown: &cooldown},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79, GpuInstanceID: nvcaps.InvalidInstanceID, ComputeInstanceID: nvcaps.InvalidInstanceID},
+			},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecovered: []string{"GPU-1"},
+			expectedRecent:    []string{"GPU-1"},
+			expectedEvents:    []string{"GPU-1/GPUXidError/true"},
+		},
+		{
+			description:  "device unhealthy for another reason does not recover after the cool-down",
+			health:       &spec.Health{XidRecoveryCooldown: &cooldown},
+			notSupported: map[string]bool{"GPU-1": true},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79, GpuInstanceID: nvcaps.InvalidInstanceID, ComputeInstanceID: nvcaps.InvalidInstanceID},
+			},
+			expectedUnhealthy: []string{"GPU-1", "GPU-1"},
+			expectedRecent:    []string{"GPU-1"},
+			expectedEvents:    []string{"GPU-1/GPUHealthCheckFailed/true", "GPU-1/GPUXidError/true"},
+		},
+		{
+			description: "options of other resources are ignored",
+			health: &spec.Health{
+				Resources: []spec.HealthResource{
+					{Name: "nvidia.com/gpu.shared", DisabledChecks: []spec.HealthCheck{spec.HealthCheckAll}},
+				},
+			},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79, GpuInstanceID: nvcaps.InvalidInstanceID, ComputeInstanceID: nvcaps.InvalidInstanceID},
+			},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecent:    []string{"GPU-1"},
+			expectedEvents:    []string{"GPU-1/GPUXidError/true"},
+		},
+		{
+			description: "xid checks disabled for the resource",
+			health: &spec.Health{
+				Resources: []spec.HealthResource{
+					{Name: "nvidia.com/gpu", DisabledChecks: []spec.HealthCheck{spec.HealthCheckXids}},
+				},
+			},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79},
+			},
+			expectedDisabled: true,
+		},
+		{
+			description: "all checks disabled for the resource",
+			health: &spec.Health{
+				Resources: []spec.HealthResource{
+					{Name: "nvidia.com/gpu", DisabledChecks: []spec.HealthCheck{spec.HealthCheckAll}},
+				},
+			},
+			events: []nvcaps.Event{
+				{UUID: "GPU-1", Type: nvcaps.EventTypeXidCriticalError, Data: 79},
+			},
+			expectedDisabled: true,
+		},
+		{
+			description:       "unsupported device is marked unhealthy",
+			notSupported:      map[string]bool{"GPU-0": true},
+			expectedUnhealthy: []string{"GPU-0"},
+			expectedEvents:    []string{"GPU-0/GPUHealthCheckFailed/true"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			stop := make(chan interface{})
+			events := tc.events
+			nvcapsMock := &nvcaps.InterfaceMock{
+				InitFunc:     func() error { return nil },
+				ShutdownFunc: func() error { return nil },
+				EventSetCreateFunc: func() (nvcaps.EventSetID, error) {
+					return 1, nil
+				},
+				EventSetFreeFunc: func(nvcaps.EventSetID) error { return nil },
+				RegisterEventsFunc: func(_ nvcaps.EventSetID, uuid string, _ uint64) error {
+					if tc.notSupported[uuid] {
+						return nvcaps.ErrNotSupported
+					}
+					return nil
+				},
+				EventSetWaitFunc: func(nvcaps.EventSetID, uint32) (nvcaps.Event, error) {
+					if len(events) == 0 {
+						close(stop)
+						return nvcaps.Event{}, nvcaps.ErrTimeout
+					}
+					e := events[0]
+					events = events[1:]
+					return e, nil
+				},
+			}
+
+			r := &nvmlResourceManager{
+				resourceManager: resourceManager{
+					config:   &spec.Config{Health: tc.health},
+					resource: "nvidia.com/gpu",
+				},
+				nvcaps:       nvcapsMock,
+				history:      newHealthHistory(time.Hour),
+				healthEvents: &healthEventRecorder{},
+				recovered:    make(chan *Device, 2),
+			}
+			devices := Devices{
+				"GPU-0": {Device: pluginapi.Device{ID: "GPU-0"}, Index: "0"},
+				"GPU-1": {Device: pluginapi.Device{ID: "GPU-1"}, Index: "1"},
+			}
+
+			unhealthy := make(chan *Device, len(devices)*(len(tc.events)+1))
+			err := r.checkHealth(stop, devices, unhealthy)
+			require.NoError(t, err)
+			close(unhealthy)
+
+			var unhealthyIDs []string
+			for d := range unhealthy {
+				unhealthyIDs = append(unhealthyIDs, d.ID)
+			}
+			sort.Strings(unhealthyIDs)
+			require.EqualValues(t, tc.expectedUnhealthy, unhealthyIDs)
+
+			close(r.recovered)
+			var recoveredIDs []string
+			for d := range r.recovered {
+				recoveredIDs = append(recoveredIDs, d.ID)
+			}
+			require.EqualValues(t, tc.expectedRecovered, recoveredIDs)
+			if tc.expectedDisabled {
+				require.Empty(t, nvcapsMock.EventSetCreateCalls())
+			} else {
+				require.Len(t, nvcapsMock.EventSetFreeCalls(), 1)
+			}
+
+			var recentIDs []string
+			for _, id := range devices.GetIDs() {
+				if r.history.isRecent(id) {
+					recentIDs = append(recentIDs, id)
+				}
+			}
+			sort.Strings(recentIDs)
+			require.EqualValues(t, tc.expectedRecent, recentIDs)
+
+			var healthEvents []string
+			for _, e := range r.healthEvents.(*healthEventRecorder).events {
+				healthEvents = append(healthEvents, fmt.Sprintf("%s/%s/%v", e.DeviceID, e.Reason, e.Unhealthy))
+			}
+			sort.Strings(healthEvents)
+			require.EqualValues(t, tc.expectedEvents, healthEvents)
+		})
+	}
+}
+
+func TestCheckHealthReattachDuringCooldown(t *testing.T) {
+	reattachInterval := healthReattachInterval
+	healthReattachInterval = time.Millisecond
+	defer func() {
+		healthReattachInterval = reattachInterval
+	}()
+
+	cooldown := spec.Duration(50 * time.Millisecond)
+	stop := make(chan interface{})
+	r := &nvmlResourceManager{
+		resourceManager: resourceManager{
+			config:   &spec.Config{Health: &spec.Health{XidRecoveryCooldown: &cooldown}},
+			resource: "nvidia.com/gpu",
+		},
+		history:      newHealthHistory(time.Hour),
+		healthEvents: &healthEventRecorder{},
+		recovered:    make(chan *Device, 1),
+	}
+
+	var waits int
+	r.nvcaps = &nvcaps.InterfaceMock{
+		InitFunc:     func() error { return nil },
+		ShutdownFunc: func() error { return nil },
+		EventSetCreateFunc: func() (nvcaps.EventSetID, error) {
+			return 1, nil
+		},
+		EventSetFreeFunc:   func(nvcaps.EventSetID) error { return nil },
+		RegisterEventsFunc: func(nvcaps.EventSetID, string, uint64) error { return nil },
+		EventSetWaitFunc: func(nvcaps.EventSetID, uint32) (nvcaps.Event, error) {
+			waits++
+			switch {
+			case waits == 1:
+				return nvcaps.Event{UUID: "GPU-0", Type: nvcaps.EventTypeXidCriticalError, Data: 79, GpuInstanceID: nvcaps.InvalidInstanceID, ComputeInstanceID: nvcaps.InvalidInstanceID}, nil
+			case waits == 2:
+				// The broker is restarted while the device is cooling down.
+				return nvcaps.Event{}, nvcaps.ErrUnavailable
+			case len(r.recovered) > 0 || waits > 100:
+				close(stop)
+			default:
+				time.Sleep(5 * time.Millisecond)
+			}
+			return nvcaps.Event{}, nvcaps.ErrTimeout
+		},
+	}
+	devices := Devices{
+		"GPU-0": {Device: pluginapi.Device{ID: "GPU-0"}, Index: "0"},
+	}
+
+	unhealthy := make(chan *Device, 1)
+	require.NoError(t, r.checkHealth(stop, devices, unhealthy))
+	require.Equal(t, "GPU-0", (<-unhealthy).ID)
+	require.Len(t, r.nvcaps.(*nvcaps.InterfaceMock).EventSetCreateCalls(), 2)
+	require.Len(t, r.recovered, 1, "the device recovers after reattaching")
+	require.Equal(t, "GPU-0", (<-r.recovered).ID)
+}
+
+type healthEventRecorder struct {
+	events []HealthEvent
+}
+
+func (r *healthEventRecorder) ReportHealthEvent(e HealthEvent) {
+	r.events = append(r.events, e)
+}
+
+func TestHealthHistoryDeprioritize(t *testing.T) {
+	now := time.Now()
+	history := newHealthHistory(10 * time.Minute)
+	history.now = func() time.Time { return now }
+	history.record("GPU-1")
+	history.record("GPU-2")
+
+	testCases := []struct {
+		description string
+		elapsed     time.Duration
+		available   []string
+		required    []string
+		size        int
+		expected    []string
+	}{
+		{
+			description: "recently flagged devices are excluded",
+			available:   []string{"GPU-0", "GPU-1", "GPU-2", "GPU-3"},
+			size:        2,
+			expected:    []string{"GPU-0", "GPU-3"},
+		},
+		{
+			description: "replicas of recently flagged devices are excluded",
+			available:   []string{"GPU-0::0", "GPU-1::0", "GPU-1::1"},
+			size:        1,
+			expected:    []string{"GPU-0::0"},
+		},
+		{
+			description: "required devices are retained",
+			available:   []string{"GPU-0", "GPU-1", "GPU-3"},
+			required:    []string{"GPU-1"},
+			size:        2,
+			expected:    []string{"GPU-0", "GPU-1", "GPU-3"},
+		},
+		{
+			description: "flagged devices are used if no others are available",
+			available:   []string{"GPU-0", "GPU-1", "GPU-2"},
+			size:        2,
+			expected:    []string{"GPU-0", "GPU-1", "GPU-2"},
+		},
+		{
+			description: "events outside the window are ignored",
+			elapsed:     10 * time.Minute,
+			available:   []string{"GPU-0", "GPU-1", "GPU-2"},
+			size:        3,
+			expected:    []string{"GPU-0", "GPU-1", "GPU-2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			history.now = func() time.Time { return now.Add(tc.elapsed) }
+			require.EqualValues(t, tc.expected, history.deprioritize(tc.available, tc.required, tc.size))
+		})
+	}
+
+	var disabled *healthHistory
+	require.EqualValues(t, []string{"GPU-1"}, disabled.deprioritize([]string{"GPU-1"}, nil, 1))
+}
+
+func TestCheckDCGMHealth(t *testing.T) {
+	interval := spec.Duration(time.Millisecond)
+	testCases := []struct {
+		description       string
+		checks            []spec.DCGMHealthCheck
+		incidents         []dcgm.Incident
+		expectedSystems   []dcgm.System
+		expectedUnhealthy []string
+		expectedRecent    []string
+	}{
+		{
+			description:     "default checks",
+			expectedSystems: []dcgm.System{dcgm.SystemPCIe, dcgm.SystemNVLink, dcgm.SystemSM},
+		},
+		{
+			description: "failure marks device unhealthy",
+			incidents: []dcgm.Incident{
+				{GPU: 1, System: dcgm.SystemPCIe, Severity: dcgm.SeverityFailure},
+			},
+			expectedSystems:   []dcgm.System{dcgm.SystemPCIe, dcgm.SystemNVLink, dcgm.SystemSM},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecent:    []string{"GPU-1"},
+		},
+		{
+			description: "warning below severity is only recorded",
+			incidents: []dcgm.Incident{
+				{GPU: 0, System: dcgm.SystemNVLink, Severity: dcgm.SeverityWarning},
+			},
+			expectedSystems: []dcgm.System{dcgm.SystemPCIe, dcgm.SystemNVLink, dcgm.SystemSM},
+			expectedRecent:  []string{"GPU-0"},
+		},
+		{
+			description: "warning severity is configurable per check",
+			checks: []spec.DCGMHealthCheck{
+				{System: spec.DCGMHealthSystemNVLink, Severity: spec.DCGMHealthSeverityWarning},
+				{System: spec.DCGMHealthSystemMemory, Severity: spec.DCGMHealthSeverityFailure},
+			},
+			incidents: []dcgm.Incident{
+				{GPU: 0, System: dcgm.SystemNVLink, Severity: dcgm.SeverityWarning},
+				{GPU: 1, System: dcgm.SystemMemory, Severity: dcgm.SeverityWarning},
+			},
+			expectedSystems:   []dcgm.System{dcgm.SystemNVLink, dcgm.SystemMemory},
+			expectedUnhealthy: []string{"GPU-0"},
+			expectedRecent:    []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description: "incident on parent gpu marks mig devices unhealthy",
+			incidents: []dcgm.Incident{
+				{GPU: 2, System: dcgm.SystemSM, Severity: dcgm.SeverityFailure},
+			},
+			expectedSystems:   []dcgm.System{dcgm.SystemPCIe, dcgm.SystemNVLink, dcgm.SystemSM},
+			expectedUnhealthy: []string{"MIG-2-0", "MIG-2-1"},
+			expectedRecent:    []string{"MIG-2-0", "MIG-2-1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			stop := make(chan interface{})
+			checks := 0
+			dcgmMock := &dcgm.InterfaceMock{
+				SetWatchesFunc: func([]dcgm.System) error { return nil },
+				CheckFunc: func() ([]dcgm.Incident, error) {
+					checks++
+					if checks > 2 {
+						close(stop)
+						return nil, nil
+					}
+					return tc.incidents, nil
+				},
+			}
+
+			r := &nvmlResourceManager{
+				resourceManager: resourceManager{
+					config: &spec.Config{
+						Health: &spec.Health{
+							DCGM: &spec.DCGMHealth{
+								Interval: &interval,
+								Checks:   tc.checks,
+							},
+						},
+					},
+				},
+				dcgm:    dcgmMock,
+				history: newHealthHistory(time.Hour),
+			}
+			devices := Devices{
+				"GPU-0":   {Device: pluginapi.Device{ID: "GPU-0"}, Index: "0"},
+				"GPU-1":   {Device: pluginapi.Device{ID: "GPU-1"}, Index: "1"},
+				"MIG-2-0": {Device: pluginapi.Device{ID: "MIG-2-0"}, Index: "2:0"},
+				"MIG-2-1": {Device: pluginapi.Device{ID: "MIG-2-1"}, Index: "2:1"},
+			}
+
+			unhealthy := make(chan *Device, len(devices))
+			r.checkDCGMHealth(stop, devices, unhealthy)
+			close(unhealthy)
+
+			var unhealthyIDs []string
+			for d := range unhealthy {
+				unhealthyIDs = append(unhealthyIDs, d.ID)
+			}
+			sort.Strings(unhealthyIDs)
+			require.EqualValues(t, tc.expectedUnhealthy, unhealthyIDs)
+
+			require.Len(t, dcgmMock.SetWatchesCalls(), 1)
+			require.EqualValues(t, tc.expectedSystems, dcgmMock.SetWatchesCalls()[0].Systems)
+
+			var recentIDs []string
+			for _, id := range devices.GetIDs() {
+				if r.history.isRecent(id) {
+					recentIDs = append(recentIDs, id)
+				}
+			}
+			sort.Strings(recentIDs)
+			require.EqualValues(t, tc.expectedRecent, recentIDs)
+		})
+	}
+}
+
+func TestCheckThermalHealth(t *testing.T) {
+	interval := spec.Duration(time.Millisecond)
+	names := map[string]string{
+		"GPU-0": "Tesla T4",
+		"GPU-1": "Tesla T4",
+		"GPU-2": "Tesla V100-SXM2-16GB",
+		"GPU-3": "NVIDIA A100-SXM4-40GB",
+	}
+	testCases := []struct {
+		description       string
+		temperatures      map[string]uint32
+		expectedUnhealthy []string
+		expectedRecent    []string
+		expectedEvents    []string
+	}{
+		{
+			description:  "normal temperatures",
+			temperatures: map[string]uint32{"GPU-0": 70, "GPU-1": 70, "GPU-2": 70, "GPU-3": 70},
+		},
+		{
+			description:    "degraded gpu is only recorded",
+			temperatures:   map[string]uint32{"GPU-0": 85, "GPU-1": 70, "GPU-2": 70, "GPU-3": 70},
+			expectedRecent: []string{"GPU-0"},
+			expectedEvents: []string{"GPU-0/GPUThermalThreshold/false"},
+		},
+		{
+			description:       "unhealthy gpu is marked unhealthy",
+			temperatures:      map[string]uint32{"GPU-0": 70, "GPU-1": 95, "GPU-2": 70, "GPU-3": 70},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecent:    []string{"GPU-1"},
+			expectedEvents:    []string{"GPU-1/GPUThermalThreshold/true"},
+		},
+		{
+			description:  "gpus without matching thresholds are ignored",
+			temperatures: map[string]uint32{"GPU-0": 70, "GPU-1": 70, "GPU-2": 100, "GPU-3": 70},
+		},
+		{
+			description:       "unhealthy parent gpu marks mig devices unhealthy",
+			temperatures:      map[string]uint32{"GPU-0": 70, "GPU-1": 70, "GPU-2": 70, "GPU-3": 80},
+			expectedUnhealthy: []string{"MIG-3-0", "MIG-3-1"},
+			expectedRecent:    []string{"MIG-3-0", "MIG-3-1"},
+			expectedEvents:    []string{"MIG-3-0/GPUThermalThreshold/true", "MIG-3-1/GPUThermalThreshold/true"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			stop := make(chan interface{})
+			reads := 0
+			nvcapsMock := &nvcaps.InterfaceMock{
+				InitFunc:     func() error { return nil },
+				ShutdownFunc: func() error { return nil },
+				GetMigDevicePlacementFunc: func(uuid string) (nvcaps.Placement, error) {
+					return nvcaps.Placement{ParentUUID: "GPU-3"}, nil
+				},
+				GetNameFunc: func(uuid string) (string, error) {
+					return names[uuid], nil
+				},
+				GetTemperatureFunc: func(uuid string) (uint32, error) {
+					reads++
+					if reads == 10 {
+						close(stop)
+					}
+					return tc.temperatures[uuid], nil
+				},
+			}
+
+			r := &nvmlResourceManager{
+				resourceManager: resourceManager{
+					config: &spec.Config{
+						Health: &spec.Health{
+							Thermal: &spec.ThermalHealth{
+								Interval: &interval,
+								Thresholds: []spec.ThermalThreshold{
+									{Pattern: "*T4*", Degraded: 80, Unhealthy: 90},
+									{Pattern: "*A100*", Unhealthy: 80},
+								},
+							},
+						},
+					},
+				},
+				nvcaps:       nvcapsMock,
+				history:      newHealthHistory(time.Hour),
+				healthEvents: &healthEventRecorder{},
+			}
+			devices := Devices{
+				"GPU-0":   {Device: pluginapi.Device{ID: "GPU-0"}, Index: "0"},
+				"GPU-1":   {Device: pluginapi.Device{ID: "GPU-1"}, Index: "1"},
+				"GPU-2":   {Device: pluginapi.Device{ID: "GPU-2"}, Index: "2"},
+				"MIG-3-0": {Device: pluginapi.Device{ID: "MIG-3-0"}, Index: "3:0"},
+				"MIG-3-1": {Device: pluginapi.Device{ID: "MIG-3-1"}, Index: "3:1"},
+			}
+
+			unhealthy := make(chan *Device, len(devices))
+			r.checkThermalHealth(stop, devices, unhealthy)
+			close(unhealthy)
+
+			var unhealthyIDs []string
+			for d := range unhealthy {
+				unhealthyIDs = append(unhealthyIDs, d.ID)
+			}
+			sort.Strings(unhealthyIDs)
+			require.EqualValues(t, tc.expectedUnhealthy, unhealthyIDs)
+
+			var recentIDs []string
+			for _, id := range devices.GetIDs() {
+				if r.history.isRecent(id) {
+					recentIDs = append(recentIDs, id)
+				}
+			}
+			sort.Strings(recentIDs)
+			require.EqualValues(t, tc.expectedRecent, recentIDs)
+
+			var healthEvents []string
+			for _, e := range r.healthEvents.(*healthEventRecorder).events {
+				healthEvents = append(healthEvents, fmt.Sprintf("%s/%s/%v", e.DeviceID, e.Reason, e.Unhealthy))
+			}
+			sort.Strings(healthEvents)
+			require.EqualValues(t, tc.expectedEvents, healthEvents)
+		})
+	}
+}
+
+func TestCheckECCHealth(t *testing.T) {
+	interval := spec.Duration(time.Millisecond)
+	errFailed := errors.New("failed")
+	// reading is a read of the ECC error counter of a GPU.
+	type reading struct {
+		count uint64
+		err   error
+	}
+	testCases := []struct {
+		description       string
+		recoveryCooldown  spec.Duration
+		readings          map[string][]reading
+		expectedUnhealthy []string
+		expectedRecovered []string
+	}{
+		{
+			description: "no new errors",
+		},
+		{
+			description:       "new errors mark gpu unhealthy",
+			readings:          map[string][]reading{"GPU-1": {{count: 3}, {count: 4}}},
+			expectedUnhealthy: []string{"GPU-1"},
+		},
+		{
+			description:       "new errors on parent gpu mark mig devices unhealthy",
+			readings:          map[string][]reading{"GPU-3": {{count: 3}, {count: 5}}},
+			expectedUnhealthy: []string{"MIG-3-0", "MIG-3-1"},
+		},
+		{
+			description: "errors before the first successful read are ignored",
+			readings:    map[string][]reading{"GPU-1": {{err: errFailed}, {count: 5}}},
+		},
+		{
+			description:       "reset counters recover the gpu",
+			readings:          map[string][]reading{"GPU-1": {{count: 3}, {count: 4}, {count: 0}}},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecovered: []string{"GPU-1"},
+		},
+		{
+			description:       "gpu recovers after the cool-down",
+			recoveryCooldown:  spec.Duration(time.Nanosecond),
+			readings:          map[string][]reading{"GPU-1": {{count: 3}, {count: 4}, {count: 4}}},
+			expectedUnhealthy: []string{"GPU-1"},
+			expectedRecovered: []string{"GPU-1"},
+		},
+		{
+			description:       "gpu does not recover without a cool-down",
+			readings:          map[string][]reading{"GPU-1": {{count: 3}, {count: 4}, {count: 4}}},
+			expectedUnhealthy: []string{"GPU-1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			stop := make(chan interface{})
+			reads := make(map[string]int)
+			var total int
+			nvcapsMock := &nvcaps.InterfaceMock{
+				InitFunc:     func() error { return nil },
+				ShutdownFunc: func() error { return nil },
+				GetMigDevicePlacementFunc: func(uuid string) (nvcaps.Placement, error) {
+					return nvcaps.Placement{ParentUUID: "GPU-3"}, nil
+				},
+				GetUncorrectedECCErrorsFunc: func(uuid string) (uint64, error) {
+					if uuid == "GPU-2" {
+						return 0, nvcaps.ErrNotSupported
+					}
+					reads[uuid]++
+					total++
+					if total == 12 {
+						close(stop)
+					}
+					// The counters stay at their last reading.
+					readings := tc.readings[uuid]
+					if len(readings) == 0 {
+						return 3, nil
+					}
+					next := readings[min(reads[uuid], len(readings))-1]
+					return next.count, next.err
+				},
+			}
+
+			r := &nvmlResourceManager{
+				resourceManager: resourceManager{
+					config: &spec.Config{
+						Health: &spec.Health{
+							ECC: &spec.ECCHealth{Interval: &interval, RecoveryCooldown: &tc.recoveryCooldown},
+						},
+					},
+				},
+				nvcaps:    nvcapsMock,
+				history:   newHealthHistory(time.Hour),
+				recovered: make(chan *Device, 5),
+			}
+			devices := Devices{
+				"GPU-0":   {Device: pluginapi.Device{ID: "GPU-0"}, Index: "0"},
+				"GPU-1":   {Device: pluginapi.Device{ID: "GPU-1"}, Index: "1"},
+				"GPU-2":   {Device: pluginapi.Device{ID: "GPU-2"}, Index: "2"},
+				"MIG-3-0": {Device: pluginapi.Device{ID: "MIG-3-0"}, Index: "3:0"},
+				"MIG-3-1": {Device: pluginapi.Device{ID: "MIG-3-1"}, Index: "3:1"},
+			}
+
+			unhealthy := make(chan *Device, len(devices))
+			r.checkECCHealth(stop, devices, unhealthy)
+			close(unhealthy)
+			close(r.recovered)
+
+			var unhealthyIDs []string
+			for d := range unhealthy {
+				unhealthyIDs = append(unhealthyIDs, d.ID)
+			}
+			sort.Strings(unhealthyIDs)
+			require.EqualValues(t, tc.expectedUnhealthy, unhealthyIDs)
+
+			var recoveredIDs []string
+			for d := range r.recovered {
+				recoveredIDs = append(recoveredIDs, d.ID)
+			}
+			sort.Strings(recoveredIDs)
+			require.EqualValues(t, tc.expectedRecovered, recoveredIDs)
+		})
+	}
+}
+
+type testHealthEventReporter struct {
+	sync.Mutex
+	events []HealthEvent
+}
+
+func (r *testHealthEventReporter) ReportHealthEvent(e HealthEvent) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e)
+}
+
+func TestValidateDevices(t *testing.T) {
+	testCases := []struct {
+		description       string
+		validation        *spec.StartupValidation
+		disabled          bool
+		expectedUnhealthy []string
+	}{
+		{
+			description: "validation disabled",
+		},
+		{
+			description:       "memory query fails",
+			validation:        &spec.StartupValidation{},
+			expectedUnhealthy: []string{"GPU-1::0", "GPU-1::1"},
+		},
+		{
+			description:       "command fails",
+			validation:        &spec.StartupValidation{Command: []string{"sh", "-c", `[ "$0" != GPU-0 ]`}},
+			expectedUnhealthy: []string{"GPU-0", "GPU-1::0", "GPU-1::1"},
+		},
+		{
+			description: "validation disabled for resource",
+			validation:  &spec.StartupValidation{},
+			disabled:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			nvcapsMock := &nvcaps.InterfaceMock{
+				InitFunc:     func() error { return nil },
+				ShutdownFunc: func() error { return nil },
+				GetNameFunc: func(uuid string) (string, error) {
+					return "Tesla T4", nil
+				},
+				GetMemoryInfoFunc: func(uuid string) (nvcaps.Memory, error) {
+					if uuid == "GPU-1" {
+						return nvcaps.Memory{}, fmt.Errorf("unknown error")
+					}
+					return nvcaps.Memory{TotalBytes: 16 << 30, UsedBytes: 1 << 20}, nil
+				},
+			}
+			health := &spec.Health{StartupValidation: tc.validation}
+			if tc.disabled {
+				health.Resources = []spec.HealthResource{
+					{Name: "nvidia.com/gpu", DisabledChecks: []spec.HealthCheck{spec.HealthCheckStartupValidation}},
+				}
+			}
+			reporter := &testHealthEventReporter{}
+			r := &nvmlResourceManager{
+				resourceManager: resourceManager{
+					config:   &spec.Config{Health: health},
+					resource: "nvidia.com/gpu",
+				},
+				nvcaps:       nvcapsMock,
+				healthEvents: reporter,
+			}
+			devices := Devices{
+				"GPU-0":    {Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}},
+				"GPU-1::0": {Device: pluginapi.Device{ID: "GPU-1::0", Health: pluginapi.Healthy}},
+				"GPU-1::1": {Device: pluginapi.Device{ID: "GPU-1::1", Health: pluginapi.Healthy}},
+			}
+
+			r.validateDevices(devices)
+
+			var unhealthy []string
+			for id, d := range devices {
+				if d.Health == pluginapi.Unhealthy {
+					require.Error(t, d.ValidationError)
+					unhealthy = append(unhealthy, id)
+				}
+			}
+			sort.Strings(unhealthy)
+			require.EqualValues(t, tc.expectedUnhealthy, unhealthy)
+			require.Len(t, reporter.events, len(tc.expectedUnhealthy))
+			if tc.validation == nil || tc.disabled {
+				require.Empty(t, nvcapsMock.GetNameCalls())
+			} else {
+				require.Len(t, nvcapsMock.GetMemoryInfoCalls(), 2)
+			}
+		})
+	}
+}
+
+func TestValidateDevicesCached(t *testing.T) {
+	nvcapsMock := &nvcaps.InterfaceMock{
+		InitFunc:     func() error { return nil },
+		ShutdownFunc: func() error { return nil },
+		GetNameFunc: func(uuid string) (string, error) {
+			return "Tesla T4", nil
+		},
+		GetMemoryInfoFunc: func(uuid string) (nvcaps.Memory, error) {
+			if uuid == "GPU-1" {
+				return nvcaps.Memory{}, fmt.Errorf("unknown error")
+			}
+			return nvcaps.Memory{TotalBytes: 16 << 30, UsedBytes: 1 << 20}, nil
+		},
+	}
+	cache := NewValidationCache()
+	reporter := &testHealthEventReporter{}
+	validate := func(validation *spec.StartupValidation, ids ...string) []string {
+		r := &nvmlResourceManager{
+			resourceManager: resourceManager{
+				config:   &spec.Config{Health: &spec.Health{StartupValidation: validation}},
+				resource: "nvidia.com/gpu",
+			},
+			nvcaps:       nvcapsMock,
+			healthEvents: reporter,
+			validations:  cache,
+		}
+		devices := make(Devices)
+		for _, id := range ids {
+			devices[id] = &Device{Device: pluginapi.Device{ID: id, Health: pluginapi.Healthy}}
+		}
+		r.validateDevices(devices)
+
+		var unhealthy []string
+		for id, d := range devices {
+			if d.Health == pluginapi.Unhealthy {
+				require.Error(t, d.ValidationError)
+				unhealthy = append(unhealthy, id)
+			}
+		}
+		sort.Strings(unhealthy)
+		return unhealthy
+	}
+
+	validation := &spec.StartupValidation{}
+	require.Equal(t, []string{"GPU-1"}, validate(validation, "GPU-0", "GPU-1"))
+	require.Len(t, nvcapsMock.GetMemoryInfoCalls(), 2)
+	require.Len(t, reporter.events, 1)
+
+	// The results are reused for the same config and devices, e.g. after the
+	// plugins are restarted, and no events are reported again.
+	require.Equal(t, []string{"GPU-1"}, validate(&spec.StartupValidation{}, "GPU-1", "GPU-0"))
+	require.Len(t, nvcapsMock.GetMemoryInfoCalls(), 2)
+	require.Len(t, reporter.events, 1)
+
+	// The devices are validated again if the devices change.
+	require.Equal(t, []string{"GPU-1"}, validate(validation, "GPU-0", "GPU-1", "GPU-2"))
+	require.Len(t, nvcapsMock.GetMemoryInfoCalls(), 5)
+
+	// The devices are validated again if the config changes.
+	require.Equal(t, []string{"GPU-0", "GPU-1", "GPU-2"}, validate(&spec.StartupValidation{Command: []string{"false"}}, "GPU-0", "GPU-1", "GPU-2"))
+	require.Len(t, nvcapsMock.GetMemoryInfoCalls(), 8)
+}
@@ -0,0 +1,122 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// thermalGPU groups the devices that share a physical GPU with the
+// thresholds that apply to that GPU.
+type thermalGPU struct {
+	threshold *spec.ThermalThreshold
+	devices   []*Device
+}
+
+// checkThermalHealth periodically checks the temperature of the GPUs backing the specified devices until the stop
+// channel is closed. Devices on GPUs at or above the unhealthy threshold are written to the 'unhealthy' channel and
+// devices on GPUs at or above the degraded threshold are recorded as health events. A health event is reported for
+// the devices of a GPU whenever the GPU crosses into the degraded or unhealthy class.
+func (r *nvmlResourceManager) checkThermalHealth(stop <-chan interface{}, devices Devices, unhealthy chan<- *Device) {
+	config := r.config.Health.GetThermal()
+
+	if err := r.nvcaps.Init(); err != nil {
+		klog.Warningf("Failed to initialize NVML: %v; continuing with thermal health checks disabled", err)
+		return
+	}
+	defer func() {
+		if err := r.nvcaps.Shutdown(); err != nil {
+			klog.Infof("Error shutting down NVML: %v", err)
+		}
+	}()
+
+	gpus := r.getThermalGPUs(config, devices)
+	if len(gpus) == 0 {
+		return
+	}
+
+	reported := make(map[string]bool)
+	classes := make(map[string]spec.ThermalClass)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-r.nextHealthCheck(config.GetInterval()):
+		}
+
+		for uuid, gpu := range gpus {
+			temperature, err := r.nvcaps.GetTemperature(uuid)
+			if err != nil {
+				klog.Warningf("Failed to get temperature of GPU %v: %v", uuid, err)
+				continue
+			}
+			class := gpu.threshold.Classify(int(temperature))
+			previous := classes[uuid]
+			classes[uuid] = class
+			if class == spec.ThermalClassNormal {
+				continue
+			}
+			for _, d := range gpu.devices {
+				r.history.record(d.GetUUID())
+				if class != previous {
+					r.reportHealthEvent(d, HealthEventReasonThermal, class == spec.ThermalClassUnhealthy, "GPU %v is %v at %d°C (degraded threshold %d°C, unhealthy threshold %d°C)", uuid, class, temperature, gpu.threshold.Degraded, gpu.threshold.Unhealthy)
+				}
+				if class != spec.ThermalClassUnhealthy || reported[d.ID] {
+					continue
+				}
+				klog.Infof("GPU %v is at %d°C (unhealthy threshold %d°C); marking Device=%s as unhealthy.", uuid, temperature, gpu.threshold.Unhealthy, d.ID)
+				reported[d.ID] = true
+				r.unhealthy.add(d, HealthEventReasonThermal)
+				select {
+				case unhealthy <- d:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
+
+// getThermalGPUs returns the GPUs backing the specified devices that have
+// thermal thresholds configured, keyed by the UUID of the GPU.
+func (r *nvmlResourceManager) getThermalGPUs(config *spec.ThermalHealth, devices Devices) map[string]*thermalGPU {
+	gpus := make(map[string]*thermalGPU)
+	for _, d := range devices {
+		uuid, _, _, err := r.getDevicePlacement(d)
+		if err != nil {
+			klog.Warningf("Could not determine parent GPU of %v: %v; skipping thermal health checks", d.ID, err)
+			continue
+		}
+		if gpu, exists := gpus[uuid]; exists {
+			gpu.devices = append(gpu.devices, d)
+			continue
+		}
+		name, err := r.nvcaps.GetName(uuid)
+		if err != nil {
+			klog.Warningf("Could not get product name of GPU %v: %v; skipping thermal health checks", uuid, err)
+			continue
+		}
+		threshold := config.GetThreshold(name)
+		if threshold == nil {
+			continue
+		}
+		gpus[uuid] = &thermalGPU{threshold: threshold, devices: []*Device{d}}
+	}
+	return gpus
+}
@@ -0,0 +1,204 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// ValidationCache holds the results of the startup validation of each resource
+// across the resource managers that are created whenever the plugins are
+// restarted. The devices of a resource are only validated again if the
+// validation config or the set of GPUs of the resource changes.
+type ValidationCache struct {
+	sync.Mutex
+	results map[spec.ResourceName]validationResult
+}
+
+// validationResult holds the GPUs of a resource that failed the validation,
+// along with the key of the config and GPUs that they were validated with.
+type validationResult struct {
+	key    string
+	failed map[string]error
+}
+
+// NewValidationCache creates an empty validation cache.
+func NewValidationCache() *ValidationCache {
+	return &ValidationCache{
+		results: make(map[spec.ResourceName]validationResult),
+	}
+}
+
+// WithValidationCache sets the cache for the results of the startup
+// validation. Without a cache, the devices are validated by every resource
+// manager.
+func WithValidationCache(cache *ValidationCache) NVMLResourceManagerOption {
+	return func(r *nvmlResourceManager) {
+		r.validations = cache
+	}
+}
+
+// get returns the failed GPUs of the resource if they were validated with
+// the specified key.
+func (c *ValidationCache) get(resource spec.ResourceName, key string) (map[string]error, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.Lock()
+	defer c.Unlock()
+	result, ok := c.results[resource]
+	if !ok || result.key != key {
+		return nil, false
+	}
+	return result.failed, true
+}
+
+func (c *ValidationCache) set(resource spec.ResourceName, key string, failed map[string]error) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.results[resource] = validationResult{key: key, failed: failed}
+}
+
+// validationKey identifies the validation config and the GPUs that are
+// validated.
+func validationKey(config *spec.StartupValidation, uuids []string) string {
+	sort.Strings(uuids)
+	return fmt.Sprintf("%q/%v/%v", config.Command, config.GetTimeout(), strings.Join(uuids, ","))
+}
+
+// validateDevices runs the startup validation for the specified devices if it
+// is enabled. Devices that fail the validation are marked unhealthy before
+// they are first advertised, so that the kubelet never allocates them.
+// Devices that share a GPU, e.g. replicas, are validated once and the GPUs are
+// validated concurrently. The results are reused from the validation cache
+// until the config or the GPUs of the resource change.
+func (r *nvmlResourceManager) validateDevices(devices Devices) {
+	config := r.config.Health.GetStartupValidation()
+	if config == nil || !r.config.Health.ForResource(r.resource).IsEnabled(spec.HealthCheckStartupValidation) {
+		return
+	}
+
+	byUUID := make(map[string][]*Device)
+	var uuids []string
+	for _, d := range devices {
+		if _, exists := byUUID[d.GetUUID()]; !exists {
+			uuids = append(uuids, d.GetUUID())
+		}
+		byUUID[d.GetUUID()] = append(byUUID[d.GetUUID()], d)
+	}
+
+	key := validationKey(config, uuids)
+	failed, cached := r.validations.get(r.resource, key)
+	if !cached {
+		var ok bool
+		failed, ok = r.runValidation(config, byUUID)
+		if !ok {
+			return
+		}
+		r.validations.set(r.resource, key, failed)
+	}
+
+	for uuid, err := range failed {
+		for _, d := range byUUID[uuid] {
+			d.Health = pluginapi.Unhealthy
+			d.ValidationError = err
+			r.unhealthy.add(d, HealthEventReasonValidation)
+			if cached {
+				continue
+			}
+			klog.Warningf("Device %v failed startup validation: %v; marking it unhealthy", d.ID, err)
+			r.reportHealthEvent(d, HealthEventReasonValidation, true, "Startup validation failed: %v", err)
+		}
+	}
+	if cached {
+		klog.Infof("Reusing the startup validation of %d devices for resource %v; %d failed", len(byUUID), r.resource, len(failed))
+		return
+	}
+	klog.Infof("Validated %d devices for resource %v; %d failed", len(byUUID), r.resource, len(failed))
+}
+
+// runValidation validates the specified GPUs concurrently and returns the
+// GPUs that failed. If NVML cannot be initialized, the GPUs are not validated
+// and false is returned.
+func (r *nvmlResourceManager) runValidation(config *spec.StartupValidation, byUUID map[string][]*Device) (map[string]error, bool) {
+	if err := r.nvcaps.Init(); err != nil {
+		klog.Warningf("Failed to initialize NVML: %v; skipping startup validation", err)
+		return nil, false
+	}
+	defer func() {
+		if err := r.nvcaps.Shutdown(); err != nil {
+			klog.Infof("Error shutting down NVML: %v", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := make(map[string]error)
+	for uuid := range byUUID {
+		wg.Add(1)
+		go func(uuid string) {
+			defer wg.Done()
+			if err := r.validateDevice(config, uuid); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				failed[uuid] = err
+			}
+		}(uuid)
+	}
+	wg.Wait()
+	return failed, true
+}
+
+// validateDevice queries the specified device and its memory through NVML
+// and runs the validation command, if any, for the device.
+func (r *nvmlResourceManager) validateDevice(config *spec.StartupValidation, uuid string) error {
+	if _, err := r.nvcaps.GetName(uuid); err != nil {
+		return fmt.Errorf("failed to query device: %w", err)
+	}
+	memory, err := r.nvcaps.GetMemoryInfo(uuid)
+	if err != nil {
+		return fmt.Errorf("failed to query memory: %w", err)
+	}
+	if memory.TotalBytes == 0 || memory.UsedBytes > memory.TotalBytes {
+		return fmt.Errorf("invalid memory info: %d of %d bytes used", memory.UsedBytes, memory.TotalBytes)
+	}
+
+	if len(config.Command) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetTimeout())
+	defer cancel()
+	args := append(append([]string{}, config.Command[1:]...), uuid)
+	output, err := exec.CommandContext(ctx, config.Command[0], args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v failed: %w: %s", strings.Join(config.Command, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
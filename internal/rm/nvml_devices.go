@@ -19,6 +19,7 @@ package rm
 import (
 	"bytes"
 	"fmt"
+	"math/bits"
 	"os"
 	"strconv"
 	"strings"
@@ -33,6 +34,10 @@ const (
 	nvidiaCapabilitiesPath = nvidiaProcDriverPath + "/capabilities"
 )
 
+// maxCPUs is the number of CPUs for which the CPU affinity of a device is
+// queried.
+const maxCPUs = 4096
+
 // nvmlDevice wraps an nvml.Device with more functions.
 type nvmlDevice struct {
 	nvml.Device
@@ -201,3 +206,60 @@ func (d nvmlMigDevice) GetTotalMemory() (uint64, error) {
 	}
 	return info.Total, nil
 }
+
+// GetBAR1Memory returns the size of the BAR1 memory of the device.
+// If the size cannot be queried on the device, 0 is returned.
+func (d nvmlDevice) GetBAR1Memory() (uint64, error) {
+	info, ret := d.Device.GetBAR1MemoryInfo()
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return 0, nil
+	}
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return info.Bar1Total, nil
+}
+
+// GetCPUAffinity returns the CPUs that are closest to the device. If the
+// affinity cannot be queried on the device, nil is returned.
+func (d nvmlDevice) GetCPUAffinity() ([]int, error) {
+	mask, ret := d.Device.GetCpuAffinity(maxCPUs)
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		return nil, nil
+	}
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+	return cpusFromMask(mask), nil
+}
+
+// GetCPUAffinity for a MIG device is the CPU affinity of the parent device.
+func (d nvmlMigDevice) GetCPUAffinity() ([]int, error) {
+	parent, ret := d.Device.GetDeviceHandleFromMigDeviceHandle()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get parent device: %w", ret)
+	}
+	return nvmlDevice{parent}.GetCPUAffinity()
+}
+
+// cpusFromMask returns the CPUs that are set in the specified CPU mask.
+func cpusFromMask(mask []uint) []int {
+	var cpus []int
+	for i, word := range mask {
+		for word != 0 {
+			bit := bits.TrailingZeros(word)
+			cpus = append(cpus, i*bits.UintSize+bit)
+			word &^= 1 << bit
+		}
+	}
+	return cpus
+}
+
+// GetBAR1Memory for a MIG device is the BAR1 memory of the parent device.
+func (d nvmlMigDevice) GetBAR1Memory() (uint64, error) {
+	parent, ret := d.Device.GetDeviceHandleFromMigDeviceHandle()
+	if ret != nvml.SUCCESS {
+		return 0, fmt.Errorf("failed to get parent device: %w", ret)
+	}
+	return nvmlDevice{parent}.GetBAR1Memory()
+}
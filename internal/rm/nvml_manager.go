@@ -18,6 +18,7 @@ package rm
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/NVIDIA/go-gpuallocator/gpuallocator"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
@@ -26,17 +27,53 @@ import (
 	"k8s.io/klog/v2"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/dcgm"
+	"github.com/NVIDIA/k8s-device-plugin/internal/nvcaps"
 )
 
 type nvmlResourceManager struct {
 	resourceManager
-	nvml nvml.Interface
+	nvml   nvml.Interface
+	nvcaps nvcaps.Interface
+	// dcgm is used for additional health checks if a DCGM host engine is available.
+	dcgm dcgm.Interface
+	// history tracks recent health events so that flaky devices can be
+	// deprioritized in preferred allocations.
+	history *healthHistory
+	// healthEvents receives the health events detected for the devices.
+	healthEvents HealthEventReporter
+	// recovered receives the devices that recover from an Xid.
+	recovered chan *Device
+	// unhealthy tracks why the devices were marked unhealthy, so that a
+	// device only recovers once all checks that failed for it recovered.
+	unhealthy unhealthyReasons
+	// xidCooldowns tracks the devices that recover from an Xid once the
+	// cool-down has passed.
+	xidCooldowns xidCooldowns
+	// validations caches the results of the startup validation.
+	validations *ValidationCache
+
+	linkedLock sync.Mutex
+	// linked caches the GPUs of the node including the links between them.
+	linked gpuallocator.DeviceList
 }
 
 var _ ResourceManager = (*nvmlResourceManager)(nil)
+var _ HealthRecoverer = (*nvmlResourceManager)(nil)
+
+// NVMLResourceManagerOption configures the NVML-based resource managers.
+type NVMLResourceManagerOption func(*nvmlResourceManager)
+
+// WithNVCaps sets the interface used for NVML health checks.
+// If this is not specified, NVML is called in-process using the supplied NVML library.
+func WithNVCaps(nvcapslib nvcaps.Interface) NVMLResourceManagerOption {
+	return func(r *nvmlResourceManager) {
+		r.nvcaps = nvcapslib
+	}
+}
 
 // NewNVMLResourceManagers returns a set of ResourceManagers, one for each NVML resource in 'config'.
-func NewNVMLResourceManagers(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interface, config *spec.Config) ([]ResourceManager, error) {
+func NewNVMLResourceManagers(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interface, config *spec.Config, opts ...NVMLResourceManagerOption) ([]ResourceManager, error) {
 	ret := nvmllib.Init()
 	if ret != nvml.SUCCESS {
 		return nil, fmt.Errorf("failed to initialize NVML: %v", ret)
@@ -48,11 +85,19 @@ func NewNVMLResourceManagers(infolib info.Interface, nvmllib nvml.Interface, dev
 		}
 	}()
 
-	deviceMap, err := NewDeviceMap(infolib, devicelib, config)
+	deviceMap, err := NewDeviceMap(infolib, nvmllib, devicelib, config)
 	if err != nil {
 		return nil, fmt.Errorf("error building device map: %v", err)
 	}
 
+	var dcgmlib dcgm.Interface
+	if dcgmConfig := config.Health.GetDCGM(); dcgmConfig != nil {
+		dcgmlib, err = dcgm.New(dcgmConfig.Address)
+		if err != nil {
+			klog.Warningf("DCGM health checks are disabled: %v", err)
+		}
+	}
+
 	var rms []ResourceManager
 	for resourceName, devices := range deviceMap {
 		if len(devices) == 0 {
@@ -64,14 +109,29 @@ func NewNVMLResourceManagers(infolib info.Interface, nvmllib nvml.Interface, dev
 				resource: resourceName,
 				devices:  devices,
 			},
-			nvml: nvmllib,
+			nvml:      nvmllib,
+			nvcaps:    nvcaps.New(nvmllib),
+			dcgm:      dcgmlib,
+			history:   newHealthHistory(config.Health.GetEventDecayWindow()),
+			recovered: make(chan *Device),
+		}
+		for _, opt := range opts {
+			opt(r)
 		}
+		r.validateDevices(devices)
 		rms = append(rms, r)
 	}
 
 	return rms, nil
 }
 
+// Recovered returns the channel on which the devices that recovered from an
+// Xid after the configured cool-down, or from ECC errors, are sent. Devices
+// that are still unhealthy for other reasons are not sent.
+func (r *nvmlResourceManager) Recovered() <-chan *Device {
+	return r.recovered
+}
+
 // GetPreferredAllocation runs an allocation algorithm over the inputs.
 // The algorithm chosen is based both on the incoming set of available devices and various config settings.
 func (r *nvmlResourceManager) GetPreferredAllocation(available, required []string, size int) ([]string, error) {
@@ -98,42 +158,37 @@ func (r *nvmlResourceManager) CheckHealth(stop <-chan interface{}, unhealthy cha
 // getPreferredAllocation runs an allocation algorithm over the inputs.
 // The algorithm chosen is based both on the incoming set of available devices and various config settings.
 func (r *nvmlResourceManager) getPreferredAllocation(available, required []string, size int) ([]string, error) {
+	// Devices that recently reported health events are only considered if
+	// there are not enough other devices to satisfy the request.
+	available = r.history.deprioritize(available, required, size)
+
 	// If all of the available devices are full GPUs without replicas, then
-	// calculate an aligned allocation across those devices.
+	// select the best connected set of those devices.
 	if r.Devices().AlignedAllocationSupported() && !AnnotatedIDs(available).AnyHasAnnotations() {
 		return r.alignedAlloc(available, required, size)
 	}
 
-	// Otherwise, distribute them evenly across all replicated GPUs
-	return r.distributedAlloc(available, required, size)
+	// Otherwise, place the replicas according to the allocation policy of
+	// the resource. Milli-GPU units are packed onto as few GPUs as possible
+	// by default. Replicas of well connected GPUs are preferred if a request
+	// may span several GPUs.
+	var scores linkScores
+	if size > 1 {
+		var err error
+		scores, err = r.getLinkScores()
+		if err != nil {
+			klog.Warningf("Ignoring the topology of the GPUs for the preferred allocation: %v", err)
+		}
+	}
+	return r.replicatedAlloc(available, required, size, scores)
 }
 
-// alignedAlloc shells out to the alignedAllocationPolicy that is set in
-// order to calculate the preferred allocation.
+// alignedAlloc selects the full GPUs that are best connected to each other
+// and to the required GPUs according to their link scores.
 func (r *nvmlResourceManager) alignedAlloc(available, required []string, size int) ([]string, error) {
-	var devices []string
-
-	linkedDevices, err := gpuallocator.NewDevices(
-		gpuallocator.WithNvmlLib(r.nvml),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get device link information: %w", err)
-	}
-
-	availableDevices, err := linkedDevices.Filter(available)
+	scores, err := r.getLinkScores()
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve list of available devices: %v", err)
+		return nil, err
 	}
-
-	requiredDevices, err := linkedDevices.Filter(required)
-	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve list of required devices: %v", err)
-	}
-
-	allocatedDevices := gpuallocator.NewBestEffortPolicy().Allocate(availableDevices, requiredDevices, size)
-	for _, device := range allocatedDevices {
-		devices = append(devices, device.UUID)
-	}
-
-	return devices, nil
+	return r.scoredAlloc(available, required, size, scores)
 }
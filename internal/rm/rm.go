@@ -42,10 +42,18 @@ type ResourceManager interface {
 	Devices() Devices
 	GetDevicePaths([]string) []string
 	GetPreferredAllocation(available, required []string, size int) ([]string, error)
+	GetTopology([]string) (*Topology, error)
 	CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error
 	ValidateRequest(AnnotatedIDs) error
 }
 
+// HealthRecoverer is implemented by resource managers whose devices can
+// recover after they were marked unhealthy.
+type HealthRecoverer interface {
+	// Recovered returns the channel on which the devices that recovered are sent.
+	Recovered() <-chan *Device
+}
+
 // Resource gets the resource name associated with the ResourceManager
 func (r *resourceManager) Resource() spec.ResourceName {
 	return r.resource
@@ -73,9 +81,9 @@ func (r *resourceManager) ValidateRequest(ids AnnotatedIDs) error {
 	// error out if more than one resource is being allocated.
 	includesReplicas := ids.AnyHasAnnotations()
 	numRequestedDevices := len(ids)
-	switch r.config.Sharing.SharingStrategy() {
+	switch r.config.Sharing.StrategyForResource(r.resource) {
 	case spec.SharingStrategyTimeSlicing:
-		if includesReplicas && numRequestedDevices > 1 && r.config.Sharing.ReplicatedResources().FailRequestsGreaterThanOne {
+		if includesReplicas && numRequestedDevices > 1 && r.config.Sharing.TimeSlicing.FailRequestsGreaterThanOne {
 			return fmt.Errorf("%w: maximum request size for shared resources is 1; found %d", errInvalidRequest, numRequestedDevices)
 		}
 	case spec.SharingStrategyMPS:
@@ -86,6 +94,14 @@ func (r *resourceManager) ValidateRequest(ids AnnotatedIDs) error {
 		// deployments. If we do extend MPS to allow multiple devices to be
 		// requested, the MPS API will be extended separately from the
 		// time-slicing API.
+		// With the memory unit, multiple chunks of memory can be requested as
+		// long as they are all allocated from the same device.
+		if includesReplicas && r.config.Sharing.MPS.IsMemory() {
+			if n := len(ids.UniqueByID()); n > 1 {
+				return fmt.Errorf("%w: memory chunks must be allocated from a single device; found %d devices", errInvalidRequest, n)
+			}
+			return nil
+		}
 		if includesReplicas && numRequestedDevices > 1 {
 			return fmt.Errorf("%w: maximum request size for shared resources is 1; found %d", errInvalidRequest, numRequestedDevices)
 		}
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 )
@@ -175,6 +176,47 @@ func TestValidateRequest(t *testing.T) {
 			requestDevicesIDs: []string{"device0::1", "device1::0"},
 			expectedError:     errInvalidRequest,
 		},
+		{
+			description: "MPS memory chunks of a single device",
+			sharing: spec.Sharing{
+				MPS: &spec.ReplicatedResources{
+					Unit: spec.ReplicaUnitMemory,
+					Resources: []spec.ReplicatedResource{
+						{
+							Name: "nvidia.com/gpu",
+						},
+					},
+				},
+			},
+			devices: Devices{
+				"device0::0": nil,
+				"device0::1": nil,
+				"device1::0": nil,
+				"device1::1": nil,
+			},
+			requestDevicesIDs: []string{"device0::0", "device0::1"},
+		},
+		{
+			description: "MPS memory chunks of two devices",
+			sharing: spec.Sharing{
+				MPS: &spec.ReplicatedResources{
+					Unit: spec.ReplicaUnitMemory,
+					Resources: []spec.ReplicatedResource{
+						{
+							Name: "nvidia.com/gpu",
+						},
+					},
+				},
+			},
+			devices: Devices{
+				"device0::0": nil,
+				"device0::1": nil,
+				"device1::0": nil,
+				"device1::1": nil,
+			},
+			requestDevicesIDs: []string{"device0::1", "device1::0"},
+			expectedError:     errInvalidRequest,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -190,3 +232,17 @@ func TestValidateRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestOtherReplicas(t *testing.T) {
+	devices := Devices{
+		"GPU-0::0": &Device{Device: pluginapi.Device{ID: "GPU-0::0"}},
+		"GPU-0::1": &Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+		"GPU-0::2": &Device{Device: pluginapi.Device{ID: "GPU-0::2"}},
+		"GPU-1::0": &Device{Device: pluginapi.Device{ID: "GPU-1::0"}},
+		"GPU-1::1": &Device{Device: pluginapi.Device{ID: "GPU-1::1"}},
+	}
+
+	require.ElementsMatch(t, []string{"GPU-0::0", "GPU-0::2"}, devices.OtherReplicas("GPU-0::1").GetIDs())
+	require.ElementsMatch(t, []string{"GPU-0::2", "GPU-1::1"}, devices.OtherReplicas("GPU-0::0", "GPU-0::1", "GPU-1::0").GetIDs())
+	require.Empty(t, devices.OtherReplicas("GPU-2::0"))
+}
@@ -0,0 +1,94 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// tegraCSVDir is the directory containing the CSV files that list the
+	// files injected by the NVIDIA Container Runtime in csv mode.
+	tegraCSVDir = "/etc/nvidia-container-runtime/host-files-for-container.d"
+	// tegraCSVDeviceType is the type of the CSV entries for device nodes.
+	tegraCSVDeviceType = "dev"
+)
+
+// getTegraCSVDevicePaths returns the device nodes listed in the CSV files
+// under the specified driver root. Device nodes that do not exist on the host
+// are skipped, as is done by the NVIDIA Container Runtime.
+func getTegraCSVDevicePaths(driverRoot string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(driverRoot, tegraCSVDir, "*.csv"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CSV files: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, file := range files {
+		devices, err := parseTegraCSVDevices(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range devices {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if _, err := os.Stat(filepath.Join(driverRoot, path)); err != nil {
+				continue
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// parseTegraCSVDevices returns the paths of the device node entries in the
+// specified CSV file. Each line of the file has the form 'type, path'.
+func parseTegraCSVDevices(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer f.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ",", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid line in CSV file %v: %q", file, line)
+		}
+		if strings.TrimSpace(parts[0]) != tegraCSVDeviceType {
+			continue
+		}
+		paths = append(paths, strings.TrimSpace(parts[1]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read CSV file %v: %w", file, err)
+	}
+	return paths, nil
+}
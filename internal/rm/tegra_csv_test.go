@@ -0,0 +1,87 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTegraCSVDevicePaths(t *testing.T) {
+	testCases := []struct {
+		description   string
+		files         map[string]string
+		devices       []string
+		expectedPaths []string
+		expectedError bool
+	}{
+		{
+			description: "no CSV files",
+		},
+		{
+			description: "only existing device nodes are returned",
+			files: map[string]string{
+				"devices.csv": "dev, /dev/nvhost-ctrl\ndev, /dev/nvmap\ndev, /dev/nvhost-nvdec1\n",
+				"drivers.csv": "# comment\n\nlib, /usr/lib/aarch64-linux-gnu/tegra/libcuda.so\nsym, /usr/lib/aarch64-linux-gnu/libcuda.so\n",
+			},
+			devices:       []string{"/dev/nvhost-ctrl", "/dev/nvmap"},
+			expectedPaths: []string{"/dev/nvhost-ctrl", "/dev/nvmap"},
+		},
+		{
+			description: "duplicate device nodes are returned once",
+			files: map[string]string{
+				"devices.csv": "dev, /dev/nvmap\n",
+				"l4t.csv":     "dev,/dev/nvmap\n",
+			},
+			devices:       []string{"/dev/nvmap"},
+			expectedPaths: []string{"/dev/nvmap"},
+		},
+		{
+			description: "invalid line",
+			files: map[string]string{
+				"devices.csv": "/dev/nvmap\n",
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			root := t.TempDir()
+			csvDir := filepath.Join(root, tegraCSVDir)
+			require.NoError(t, os.MkdirAll(csvDir, 0755))
+			for name, contents := range tc.files {
+				require.NoError(t, os.WriteFile(filepath.Join(csvDir, name), []byte(contents), 0600))
+			}
+			require.NoError(t, os.MkdirAll(filepath.Join(root, "dev"), 0755))
+			for _, device := range tc.devices {
+				require.NoError(t, os.WriteFile(filepath.Join(root, device), nil, 0600))
+			}
+
+			paths, err := getTegraCSVDevicePaths(root)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedPaths, paths)
+		})
+	}
+}
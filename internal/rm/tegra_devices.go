@@ -36,7 +36,7 @@ func buildTegraDeviceMap(config *spec.Config) (DeviceMap, error) {
 	for _, resource := range config.Resources.GPUs {
 		if resource.Pattern.Matches(name) {
 			index := fmt.Sprintf("%d", i)
-			err := devices.setEntry(resource.Name, index, &tegraDevice{})
+			err := devices.setEntry(resource.Name, index, &tegraDevice{}, false)
 			if err != nil {
 				return nil, err
 			}
@@ -73,6 +73,16 @@ func (d *tegraDevice) GetTotalMemory() (uint64, error) {
 	return 0, nil
 }
 
+// GetBAR1Memory is unsupported for a Tegra device.
+func (d *tegraDevice) GetBAR1Memory() (uint64, error) {
+	return 0, nil
+}
+
+// GetCPUAffinity is unsupported for a Tegra device.
+func (d *tegraDevice) GetCPUAffinity() ([]int, error) {
+	return nil, nil
+}
+
 // GetComputeCapability is unimplemented for a Tegra device.
 func (d *tegraDevice) GetComputeCapability() (string, error) {
 	return "0.0", nil
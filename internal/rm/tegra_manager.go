@@ -24,6 +24,7 @@ import (
 
 type tegraResourceManager struct {
 	resourceManager
+	devicePaths []string
 }
 
 var _ ResourceManager = (*tegraResourceManager)(nil)
@@ -35,11 +36,22 @@ func NewTegraResourceManagers(config *spec.Config) ([]ResourceManager, error) {
 		return nil, fmt.Errorf("error building Tegra device map: %v", err)
 	}
 
-	deviceMap, err = updateDeviceMapWithReplicas(config.Sharing.ReplicatedResources(), deviceMap)
+	deviceMap, err = updateDeviceMapWithAllReplicas(config.Sharing.AllReplicatedResources(), deviceMap)
 	if err != nil {
 		return nil, fmt.Errorf("error updating device map with replicas from sharing resources: %v", err)
 	}
 
+	// In csv mode, the device nodes are not injected by the NVIDIA Container
+	// Runtime if device specs are passed. These are read from the same CSV
+	// files used by the runtime instead.
+	var devicePaths []string
+	if config.Flags.Plugin.GetContainerRuntimeMode() == spec.ContainerRuntimeModeCSV {
+		devicePaths, err = getTegraCSVDevicePaths(*config.Flags.Plugin.ContainerDriverRoot)
+		if err != nil {
+			return nil, fmt.Errorf("error getting Tegra device nodes: %v", err)
+		}
+	}
+
 	var rms []ResourceManager
 	for resourceName, devices := range deviceMap {
 		if len(devices) == 0 {
@@ -51,6 +63,7 @@ func NewTegraResourceManagers(config *spec.Config) ([]ResourceManager, error) {
 				resource: resourceName,
 				devices:  devices,
 			},
+			devicePaths: devicePaths,
 		}
 		if len(devices) != 0 {
 			rms = append(rms, r)
@@ -62,12 +75,18 @@ func NewTegraResourceManagers(config *spec.Config) ([]ResourceManager, error) {
 
 // GetPreferredAllocation returns a standard allocation for the Tegra resource manager.
 func (r *tegraResourceManager) GetPreferredAllocation(available, required []string, size int) ([]string, error) {
-	return r.distributedAlloc(available, required, size)
+	return r.replicatedAlloc(available, required, size, nil)
 }
 
-// GetDevicePaths returns an empty slice for the tegraResourceManager
+// GetDevicePaths returns the device nodes listed in the CSV files for the tegraResourceManager.
+// This is empty unless the csv mode of the NVIDIA Container Runtime is used.
 func (r *tegraResourceManager) GetDevicePaths(ids []string) []string {
-	return nil
+	return r.devicePaths
+}
+
+// GetTopology is not supported for the tegraResourceManager since Tegra systems have a single GPU.
+func (r *tegraResourceManager) GetTopology(ids []string) (*Topology, error) {
+	return nil, fmt.Errorf("topology information is not supported for Tegra devices")
 }
 
 // CheckHealth is disabled for the tegraResourceManager
@@ -0,0 +1,222 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"fmt"
+
+	"github.com/NVIDIA/go-gpuallocator/gpuallocator"
+)
+
+// Topology describes how a set of allocated devices are connected to each
+// other. Only the allocated devices are included so that workloads can tune
+// their communication libraries without seeing the topology of the node.
+type Topology struct {
+	Devices []TopologyDevice `json:"devices"`
+}
+
+// TopologyDevice describes a single device of a Topology. The index is the
+// position of the device in the allocation and matches the index of the
+// device in the container.
+type TopologyDevice struct {
+	Index    int            `json:"index"`
+	UUID     string         `json:"uuid"`
+	BusID    string         `json:"busID,omitempty"`
+	NUMANode *int64         `json:"numaNode,omitempty"`
+	Links    []TopologyLink `json:"links,omitempty"`
+}
+
+// TopologyLink describes the links between a device and another allocated
+// device, e.g. P2PLinkSameCPU or FourNVLINKLinks.
+type TopologyLink struct {
+	UUID  string   `json:"uuid"`
+	Types []string `json:"types"`
+}
+
+// GetTopology returns the topology of the specified devices.
+func (r *nvmlResourceManager) GetTopology(ids []string) (*Topology, error) {
+	linkedDevices, err := r.linkedDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := linkedDevices.Filter(ids)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve list of allocated devices: %w", err)
+	}
+
+	return newTopology(devices, r.devices), nil
+}
+
+// linkedDevices returns the GPUs of the node including the links between them.
+// Querying the links of each pair of GPUs is expensive and the links do not
+// change, so the list is only queried once.
+func (r *nvmlResourceManager) linkedDevices() (gpuallocator.DeviceList, error) {
+	r.linkedLock.Lock()
+	defer r.linkedLock.Unlock()
+	if r.linked != nil {
+		return r.linked, nil
+	}
+	linked, err := gpuallocator.NewDevices(
+		gpuallocator.WithNvmlLib(r.nvml),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get device link information: %w", err)
+	}
+	r.linked = linked
+	return linked, nil
+}
+
+// newTopology constructs the topology of the specified linked devices. The
+// NUMA nodes of the devices are taken from the devices of the resource, which
+// are looked up by their underlying UUID since the devices of shared resources
+// are replicas. No NUMA node is set for devices that are not found.
+func newTopology(linked gpuallocator.DeviceList, devices Devices) *Topology {
+	topology := &Topology{
+		Devices: make([]TopologyDevice, 0, len(linked)),
+	}
+	for i, d := range linked {
+		device := TopologyDevice{
+			Index: i,
+			UUID:  d.UUID,
+			BusID: d.PCI.BusID,
+		}
+		if resourceDevice := devices.GetByUUID(d.UUID); resourceDevice != nil {
+			if nodes := resourceDevice.GetTopology().GetNodes(); len(nodes) > 0 {
+				node := nodes[0].GetID()
+				device.NUMANode = &node
+			}
+		}
+		for _, other := range linked {
+			if other == d {
+				continue
+			}
+			var types []string
+			for _, link := range d.Links[other.Index] {
+				types = append(types, link.Type.String())
+			}
+			if len(types) == 0 {
+				continue
+			}
+			device.Links = append(device.Links, TopologyLink{UUID: other.UUID, Types: types})
+		}
+		topology.Devices = append(topology.Devices, device)
+	}
+	return topology
+}
+
+// linkTypeScores holds the scores of the links between two GPUs, indexed by
+// the names of the link types of go-gpuallocator. They match the scores used
+// by its best-effort policy: each NVLink scores 100 and PCIe links score
+// higher the closer the GPUs are to each other in the PCIe hierarchy.
+var linkTypeScores = func() map[string]int {
+	scores := map[string]int{
+		"P2PLinkCrossCPU":     10,
+		"P2PLinkSameCPU":      20,
+		"P2PLinkHostBridge":   30,
+		"P2PLinkMultiSwitch":  40,
+		"P2PLinkSingleSwitch": 50,
+		"P2PLinkSameBoard":    60,
+	}
+	nvlinks := []string{
+		"SingleNVLINKLink", "TwoNVLINKLinks", "ThreeNVLINKLinks", "FourNVLINKLinks",
+		"FiveNVLINKLinks", "SixNVLINKLinks", "SevenNVLINKLinks", "EightNVLINKLinks",
+		"NineNVLINKLinks", "TenNVLINKLinks", "ElevenNVLINKLinks", "TwelveNVLINKLinks",
+		"ThirteenNVLINKLinks", "FourteenNVLINKLinks", "FifteenNVLINKLinks", "SixteenNVLINKLinks",
+		"SeventeenNVLINKLinks", "EighteenNVLINKLinks",
+	}
+	for i, name := range nvlinks {
+		scores[name] = (i + 1) * nvlinkScore
+	}
+	return scores
+}()
+
+const (
+	nvlinkScore = 100
+	// sameNUMANodeScore and crossNUMANodeScore are used for GPUs with unknown
+	// links. They match the scores of P2PLinkSameCPU and P2PLinkCrossCPU.
+	sameNUMANodeScore  = 20
+	crossNUMANodeScore = 10
+)
+
+// linkScores holds the link score of each pair of GPUs, indexed by their UUIDs.
+type linkScores map[string]map[string]int
+
+// getLinkScores returns the link scores of the GPUs of the node.
+func (r *nvmlResourceManager) getLinkScores() (linkScores, error) {
+	linkedDevices, err := r.linkedDevices()
+	if err != nil {
+		return nil, err
+	}
+	return newLinkScores(linkedDevices, r.devices), nil
+}
+
+// newLinkScores calculates the link scores of the specified linked devices.
+// If the links between two GPUs are unknown, they are scored by whether the
+// GPUs are attached to the same NUMA node according to the devices of the
+// resource.
+func newLinkScores(linked gpuallocator.DeviceList, devices Devices) linkScores {
+	numaNodes := make(map[string]int64)
+	for _, d := range devices {
+		if nodes := d.GetTopology().GetNodes(); len(nodes) > 0 {
+			numaNodes[d.GetUUID()] = nodes[0].GetID()
+		}
+	}
+
+	scores := make(linkScores)
+	for _, d := range linked {
+		scores[d.UUID] = make(map[string]int)
+		for _, other := range linked {
+			if other == d {
+				continue
+			}
+			score := 0
+			for _, link := range d.Links[other.Index] {
+				score += linkTypeScores[link.Type.String()]
+			}
+			if score == 0 {
+				score = numaScore(numaNodes, d.UUID, other.UUID)
+			}
+			scores[d.UUID][other.UUID] = score
+		}
+	}
+	return scores
+}
+
+func numaScore(numaNodes map[string]int64, uuid, other string) int {
+	node, exists := numaNodes[uuid]
+	if !exists {
+		return 0
+	}
+	otherNode, exists := numaNodes[other]
+	if !exists {
+		return 0
+	}
+	if node == otherNode {
+		return sameNUMANodeScore
+	}
+	return crossNUMANodeScore
+}
+
+// to returns the sum of the link scores of a GPU to the selected GPUs.
+func (s linkScores) to(uuid string, selected map[string]bool) int {
+	score := 0
+	for other := range selected {
+		score += s[uuid][other]
+	}
+	return score
+}
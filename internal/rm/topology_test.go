@@ -0,0 +1,136 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rm
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-gpuallocator/gpuallocator"
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// newLinkedDevice creates a linked device with the specified index and UUID.
+func newLinkedDevice(index int, uuid string) *gpuallocator.Device {
+	d := &gpuallocator.Device{Index: index, Links: make(map[int][]gpuallocator.P2PLink)}
+	d.UUID = uuid
+	d.PCI.BusID = "0000:0" + uuid[len(uuid)-1:] + ":00.0"
+	return d
+}
+
+func TestNewTopology(t *testing.T) {
+	gpu0 := newLinkedDevice(0, "GPU-0")
+	gpu1 := newLinkedDevice(1, "GPU-1")
+	gpu3 := newLinkedDevice(3, "GPU-3")
+	// The links types are defined in an internal package of go-gpuallocator.
+	// 2 is P2PLinkSameCPU and 10 is FourNVLINKLinks.
+	gpu0.Links[1] = []gpuallocator.P2PLink{{GPU: gpu1, Type: 2}, {GPU: gpu1, Type: 10}}
+	gpu0.Links[3] = []gpuallocator.P2PLink{{GPU: gpu3, Type: 2}}
+	gpu1.Links[0] = []gpuallocator.P2PLink{{GPU: gpu0, Type: 2}, {GPU: gpu0, Type: 10}}
+
+	devices := Devices{
+		"GPU-0": &Device{Device: pluginapi.Device{
+			ID:       "GPU-0",
+			Topology: &pluginapi.TopologyInfo{Nodes: []*pluginapi.NUMANode{{ID: 1}}},
+		}},
+		"GPU-1": &Device{Device: pluginapi.Device{ID: "GPU-1"}},
+	}
+
+	topology := newTopology(gpuallocator.DeviceList{gpu1, gpu0}, devices)
+
+	numaNode := int64(1)
+	expected := &Topology{
+		Devices: []TopologyDevice{
+			{
+				Index: 0,
+				UUID:  "GPU-1",
+				BusID: "0000:01:00.0",
+				Links: []TopologyLink{
+					{UUID: "GPU-0", Types: []string{"P2PLinkSameCPU", "FourNVLINKLinks"}},
+				},
+			},
+			{
+				Index:    1,
+				UUID:     "GPU-0",
+				BusID:    "0000:00:00.0",
+				NUMANode: &numaNode,
+				Links: []TopologyLink{
+					{UUID: "GPU-1", Types: []string{"P2PLinkSameCPU", "FourNVLINKLinks"}},
+				},
+			},
+		},
+	}
+	require.Equal(t, expected, topology)
+}
+
+func TestNewTopologyReplicated(t *testing.T) {
+	gpu0 := newLinkedDevice(0, "GPU-0")
+	gpu1 := newLinkedDevice(1, "GPU-1")
+	gpu2 := newLinkedDevice(2, "GPU-2")
+
+	devices := make(Devices)
+	for _, id := range []string{"GPU-0::0", "GPU-0::1", "GPU-1::0", "GPU-1::1"} {
+		devices[id] = &Device{Device: pluginapi.Device{
+			ID:       id,
+			Topology: &pluginapi.TopologyInfo{Nodes: []*pluginapi.NUMANode{{ID: 1}}},
+		}}
+	}
+
+	// GPU-2 is not a device of the resource and therefore has no NUMA node.
+	topology := newTopology(gpuallocator.DeviceList{gpu0, gpu1, gpu2}, devices)
+
+	numaNode := int64(1)
+	expected := &Topology{
+		Devices: []TopologyDevice{
+			{Index: 0, UUID: "GPU-0", BusID: "0000:00:00.0", NUMANode: &numaNode},
+			{Index: 1, UUID: "GPU-1", BusID: "0000:01:00.0", NUMANode: &numaNode},
+			{Index: 2, UUID: "GPU-2", BusID: "0000:02:00.0"},
+		},
+	}
+	require.Equal(t, expected, topology)
+}
+
+func TestNewLinkScores(t *testing.T) {
+	gpu0 := newLinkedDevice(0, "GPU-0")
+	gpu1 := newLinkedDevice(1, "GPU-1")
+	gpu2 := newLinkedDevice(2, "GPU-2")
+	gpu3 := newLinkedDevice(3, "GPU-3")
+	// 2 is P2PLinkSameCPU and 10 is FourNVLINKLinks.
+	gpu0.Links[1] = []gpuallocator.P2PLink{{GPU: gpu1, Type: 2}, {GPU: gpu1, Type: 10}}
+	gpu1.Links[0] = []gpuallocator.P2PLink{{GPU: gpu0, Type: 2}, {GPU: gpu0, Type: 10}}
+
+	numaNode := func(id string, node int64) *Device {
+		return &Device{Device: pluginapi.Device{
+			ID:       id,
+			Topology: &pluginapi.TopologyInfo{Nodes: []*pluginapi.NUMANode{{ID: node}}},
+		}}
+	}
+	devices := Devices{
+		"GPU-0::0": numaNode("GPU-0::0", 0),
+		"GPU-1::0": numaNode("GPU-1::0", 0),
+		"GPU-2::0": numaNode("GPU-2::0", 0),
+		"GPU-3::0": numaNode("GPU-3::0", 1),
+	}
+
+	scores := newLinkScores(gpuallocator.DeviceList{gpu0, gpu1, gpu2, gpu3}, devices)
+
+	require.Equal(t, 420, scores["GPU-0"]["GPU-1"])
+	require.Equal(t, 420, scores["GPU-1"]["GPU-0"])
+	require.Equal(t, 20, scores["GPU-0"]["GPU-2"])
+	require.Equal(t, 10, scores["GPU-0"]["GPU-3"])
+	require.Equal(t, 440, scores.to("GPU-0", map[string]bool{"GPU-1": true, "GPU-2": true}))
+}
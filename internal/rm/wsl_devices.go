@@ -40,6 +40,16 @@ func (d wslDevice) GetTotalMemory() (uint64, error) {
 	return nvmlDevice(d).GetTotalMemory()
 }
 
+// GetBAR1Memory returns the size of the BAR1 memory of the device.
+func (d wslDevice) GetBAR1Memory() (uint64, error) {
+	return nvmlDevice(d).GetBAR1Memory()
+}
+
+// GetCPUAffinity returns the CPUs that are closest to the device.
+func (d wslDevice) GetCPUAffinity() ([]int, error) {
+	return nvmlDevice(d).GetCPUAffinity()
+}
+
 // GetComputeCapability returns the CUDA compute capability for the device.
 func (d wslDevice) GetComputeCapability() (string, error) {
 	return nvmlDevice(d).GetComputeCapability()
@@ -0,0 +1,82 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rollback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	coreclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// EventRecorder records events for config rollbacks.
+type EventRecorder interface {
+	Event(eventType string, reason string, message string)
+}
+
+// nodeEventRecorder records events for the node that the plugin is running on.
+type nodeEventRecorder struct {
+	client    coreclientset.Interface
+	nodeName  string
+	component string
+}
+
+// NewNodeEventRecorder creates a recorder that emits events for the specified node.
+func NewNodeEventRecorder(client coreclientset.Interface, nodeName string, component string) EventRecorder {
+	return &nodeEventRecorder{
+		client:    client,
+		nodeName:  nodeName,
+		component: component,
+	}
+}
+
+// Event creates an event for the node. Failures are logged since events are
+// informational only.
+func (r *nodeEventRecorder) Event(eventType string, reason string, message string) {
+	now := metav1.NewTime(time.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%v.", r.nodeName),
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Node",
+			Name: r.nodeName,
+			// Node events use the node name as the UID so that they are
+			// shown by 'kubectl describe node'.
+			UID: types.UID(r.nodeName),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: r.component, Host: r.nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := r.client.CoreV1().Events(event.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		klog.Warningf("Failed to create %v event for node %v: %v", reason, r.nodeName, err)
+	}
+}
@@ -0,0 +1,169 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rollback
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// ReasonConfigRolledBack is the reason of the event emitted when a config is rolled back.
+const ReasonConfigRolledBack = "ConfigRolledBack"
+
+// Manager evaluates the configs applied to the plugins and reverts to the
+// last-known-good config if an applied config fails. A config is considered
+// good once the plugins have run with it for the evaluation window without
+// failing. The last-known-good config is persisted so that it is available
+// across restarts of the plugin.
+type Manager struct {
+	configFile        string
+	lastKnownGoodFile string
+	window            time.Duration
+	recorder          EventRecorder
+
+	// candidate holds the contents of the config that is being evaluated.
+	candidate []byte
+	appliedAt time.Time
+	// rejected holds the contents of the config that was last rolled back.
+	rejected []byte
+}
+
+// NewManager creates a manager for the specified config file. A nil manager
+// is returned if the evaluation window is not positive.
+func NewManager(configFile string, lastKnownGoodFile string, window time.Duration, recorder EventRecorder) *Manager {
+	if window <= 0 || configFile == "" {
+		return nil
+	}
+	return &Manager{
+		configFile:        configFile,
+		lastKnownGoodFile: lastKnownGoodFile,
+		window:            window,
+		recorder:          recorder,
+	}
+}
+
+// ConfigFile returns the config file that the plugins are started with. This
+// is the last-known-good config if the contents of the config file were
+// rolled back, and the config file itself otherwise. A config file with new
+// contents starts a new evaluation window.
+func (m *Manager) ConfigFile() (string, error) {
+	// A config file that cannot be read is treated as empty so that the
+	// failure to load it is evaluated like any other failure.
+	contents, _ := os.ReadFile(m.configFile)
+
+	lastKnownGood, err := os.ReadFile(m.lastKnownGoodFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read last-known-good config: %w", err)
+	}
+	hasLastKnownGood := err == nil
+
+	if m.rejected != nil && bytes.Equal(contents, m.rejected) && hasLastKnownGood {
+		m.candidate = nil
+		return m.lastKnownGoodFile, nil
+	}
+	m.rejected = nil
+
+	if hasLastKnownGood && bytes.Equal(contents, lastKnownGood) {
+		m.candidate = nil
+		return m.configFile, nil
+	}
+	if m.candidate == nil || !bytes.Equal(contents, m.candidate) {
+		klog.Infof("Evaluating config %v for %v", m.configFile, m.window)
+		m.candidate = nonNil(contents)
+		m.appliedAt = time.Now()
+	}
+	return m.configFile, nil
+}
+
+// Deadline returns a channel that receives the time at which the evaluation
+// window of the applied config ends. A nil channel is returned if no config
+// is being evaluated.
+func (m *Manager) Deadline() <-chan time.Time {
+	if m == nil || m.candidate == nil {
+		return nil
+	}
+	return time.After(time.Until(m.appliedAt.Add(m.window)))
+}
+
+// Confirm persists the config that is being evaluated as the last-known-good config.
+func (m *Manager) Confirm() error {
+	if m == nil || m.candidate == nil {
+		return nil
+	}
+	defer func() {
+		m.candidate = nil
+	}()
+
+	dir := filepath.Dir(m.lastKnownGoodFile)
+	tmp, err := os.CreateTemp(dir, filepath.Base(m.lastKnownGoodFile)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(m.candidate); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write last-known-good config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write last-known-good config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.lastKnownGoodFile); err != nil {
+		return fmt.Errorf("failed to persist last-known-good config: %w", err)
+	}
+	klog.Infof("Persisted config %v as last-known-good config", m.configFile)
+	return nil
+}
+
+// Reject marks the config that is being evaluated as failed. If a
+// last-known-good config exists, an event is emitted and true is returned to
+// indicate that the plugins must be restarted with the last-known-good config.
+func (m *Manager) Reject(reason error) bool {
+	if m == nil || m.candidate == nil {
+		return false
+	}
+	candidate := m.candidate
+	m.candidate = nil
+
+	if _, err := os.Stat(m.lastKnownGoodFile); err != nil {
+		klog.Warningf("Config %v failed, but no last-known-good config is available: %v", m.configFile, reason)
+		return false
+	}
+	m.rejected = candidate
+
+	message := fmt.Sprintf("Config %v failed within %v and was rolled back to the last-known-good config: %v", m.configFile, m.window, reason)
+	klog.Warning(message)
+	if m.recorder != nil {
+		m.recorder.Event(corev1.EventTypeWarning, ReasonConfigRolledBack, message)
+	}
+	return true
+}
+
+// nonNil ensures that an empty config file is distinguished from no config being evaluated.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
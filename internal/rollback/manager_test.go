@@ -0,0 +1,101 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rollback
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	eventType string
+	reason    string
+}
+
+type recorder []event
+
+func (r *recorder) Event(eventType string, reason string, message string) {
+	*r = append(*r, event{eventType, reason})
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	lastKnownGoodFile := filepath.Join(dir, "last-known-good.yaml")
+	events := &recorder{}
+
+	m := NewManager(configFile, lastKnownGoodFile, time.Minute, events)
+	require.NotNil(t, m)
+
+	// Without a last-known-good config, a failed config is not rolled back.
+	require.NoError(t, os.WriteFile(configFile, []byte("version: v1\n"), 0600))
+	file, err := m.ConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, configFile, file)
+	require.NotNil(t, m.Deadline())
+	require.False(t, m.Reject(errors.New("failed")))
+	require.Nil(t, m.Deadline())
+	require.Empty(t, *events)
+
+	// A confirmed config is persisted and is not evaluated again.
+	_, err = m.ConfigFile()
+	require.NoError(t, err)
+	require.NoError(t, m.Confirm())
+	contents, err := os.ReadFile(lastKnownGoodFile)
+	require.NoError(t, err)
+	require.Equal(t, "version: v1\n", string(contents))
+	file, err = m.ConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, configFile, file)
+	require.Nil(t, m.Deadline())
+
+	// A failed config is rolled back to the last-known-good config.
+	require.NoError(t, os.WriteFile(configFile, []byte("version: v2\n"), 0600))
+	_, err = m.ConfigFile()
+	require.NoError(t, err)
+	require.NotNil(t, m.Deadline())
+	require.True(t, m.Reject(errors.New("failed")))
+	require.Equal(t, recorder{{"Warning", ReasonConfigRolledBack}}, *events)
+
+	// The last-known-good config is used until the config file changes.
+	file, err = m.ConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, lastKnownGoodFile, file)
+	require.Nil(t, m.Deadline())
+	require.False(t, m.Reject(errors.New("failed")))
+
+	require.NoError(t, os.WriteFile(configFile, []byte("version: v1\nflags: {}\n"), 0600))
+	file, err = m.ConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, configFile, file)
+	require.NotNil(t, m.Deadline())
+}
+
+func TestNewManagerDisabled(t *testing.T) {
+	require.Nil(t, NewManager("config.yaml", "last-known-good.yaml", 0, nil))
+	require.Nil(t, NewManager("", "last-known-good.yaml", time.Minute, nil))
+
+	var m *Manager
+	require.Nil(t, m.Deadline())
+	require.NoError(t, m.Confirm())
+	require.False(t, m.Reject(errors.New("failed")))
+}
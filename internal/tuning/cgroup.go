@@ -0,0 +1,141 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package tuning
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultCgroupRoot is the path at which the cgroup filesystem is mounted.
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+// cgroupV1UnlimitedMemory is the threshold above which a cgroup v1 memory
+// limit is considered unlimited. The kernel reports the maximum value rounded
+// down to the page size.
+const cgroupV1UnlimitedMemory = math.MaxInt64 / 2
+
+// Limits holds the CPU and memory limits of the cgroup of the process.
+type Limits struct {
+	// CPU is the CPU limit in cores. A value of 0 means unlimited.
+	CPU float64
+	// Memory is the memory limit in bytes. A value of 0 means unlimited.
+	Memory int64
+}
+
+// ReadLimits reads the limits of the cgroup mounted at the specified root.
+// Both cgroup v2 and the cpu and memory controllers of cgroup v1 are supported.
+// Limits that cannot be determined are reported as unlimited.
+func ReadLimits(root string) (*Limits, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return readV2Limits(root)
+	}
+	return readV1Limits(root)
+}
+
+// readV2Limits reads the limits from the cpu.max and memory.max files of a cgroup v2 hierarchy.
+func readV2Limits(root string) (*Limits, error) {
+	limits := &Limits{}
+
+	cpu, err := readFields(filepath.Join(root, "cpu.max"))
+	if err != nil {
+		return nil, err
+	}
+	if len(cpu) == 2 && cpu[0] != "max" {
+		quota, err := strconv.ParseFloat(cpu[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU quota %q: %w", cpu[0], err)
+		}
+		period, err := strconv.ParseFloat(cpu[1], 64)
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("invalid CPU period %q", cpu[1])
+		}
+		limits.CPU = quota / period
+	}
+
+	memory, err := readFields(filepath.Join(root, "memory.max"))
+	if err != nil {
+		return nil, err
+	}
+	if len(memory) == 1 && memory[0] != "max" {
+		limits.Memory, err = strconv.ParseInt(memory[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory limit %q: %w", memory[0], err)
+		}
+	}
+
+	return limits, nil
+}
+
+// readV1Limits reads the limits from the cpu and memory controllers of a cgroup v1 hierarchy.
+func readV1Limits(root string) (*Limits, error) {
+	limits := &Limits{}
+
+	quota, err := readInt(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return nil, err
+	}
+	period, err := readInt(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return nil, err
+	}
+	if quota > 0 && period > 0 {
+		limits.CPU = float64(quota) / float64(period)
+	}
+
+	memory, err := readInt(filepath.Join(root, "memory", "memory.limit_in_bytes"))
+	if err != nil {
+		return nil, err
+	}
+	if memory > 0 && memory < cgroupV1UnlimitedMemory {
+		limits.Memory = memory
+	}
+
+	return limits, nil
+}
+
+// readFields reads the whitespace-separated fields of the specified file.
+// No fields are returned if the file does not exist.
+func readFields(path string) ([]string, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", path, err)
+	}
+	return strings.Fields(string(contents)), nil
+}
+
+// readInt reads a single integer from the specified file.
+// A value of 0 is returned if the file does not exist.
+func readInt(path string) (int64, error) {
+	fields, err := readFields(path)
+	if err != nil || len(fields) == 0 {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %v: %w", path, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,102 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package tuning
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLimits(t *testing.T) {
+	testCases := []struct {
+		description    string
+		files          map[string]string
+		expectedLimits *Limits
+		expectedError  bool
+	}{
+		{
+			description:    "no cgroup files",
+			expectedLimits: &Limits{},
+		},
+		{
+			description: "cgroup v2 with limits",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "250000 100000\n",
+				"memory.max":         "536870912\n",
+			},
+			expectedLimits: &Limits{CPU: 2.5, Memory: 536870912},
+		},
+		{
+			description: "cgroup v2 without limits",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "max 100000\n",
+				"memory.max":         "max\n",
+			},
+			expectedLimits: &Limits{},
+		},
+		{
+			description: "cgroup v2 with invalid CPU quota",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "invalid 100000\n",
+			},
+			expectedError: true,
+		},
+		{
+			description: "cgroup v1 with limits",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "50000\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "268435456\n",
+			},
+			expectedLimits: &Limits{CPU: 0.5, Memory: 268435456},
+		},
+		{
+			description: "cgroup v1 without limits",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "-1\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "9223372036854771712\n",
+			},
+			expectedLimits: &Limits{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			root := t.TempDir()
+			for name, contents := range tc.files {
+				path := filepath.Join(root, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+			}
+
+			limits, err := ReadLimits(root)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedLimits, limits)
+		})
+	}
+}
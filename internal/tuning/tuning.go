@@ -0,0 +1,121 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package tuning
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+)
+
+// memoryLimitRatio is the fraction of the cgroup memory limit that is used as
+// the soft memory limit of the Go runtime. The remainder is left for memory
+// that is not managed by the runtime, such as the NVML library.
+const memoryLimitRatio = 0.9
+
+// Settings holds the runtime settings applied based on the cgroup limits.
+type Settings struct {
+	Limits
+	// GOMAXPROCS is the number of CPUs used by the Go runtime.
+	GOMAXPROCS int
+	// MemoryLimit is the soft memory limit of the Go runtime in bytes.
+	// A value of 0 means that no limit is set.
+	MemoryLimit int64
+}
+
+// Tune sets GOMAXPROCS and the soft memory limit of the Go runtime based on
+// the limits of the cgroup of the process. This prevents the runtime from
+// scheduling goroutines on all cores of a node, which causes CPU throttling
+// if the container has a lower CPU limit. Settings that are explicitly
+// configured through the GOMAXPROCS and GOMEMLIMIT envvars are not changed.
+func Tune(root string) *Settings {
+	limits, err := ReadLimits(root)
+	if err != nil {
+		klog.Warningf("Failed to read cgroup limits; using default runtime settings: %v", err)
+		limits = &Limits{}
+	}
+
+	if _, set := os.LookupEnv("GOMAXPROCS"); !set {
+		if procs := maxProcs(limits.CPU, runtime.NumCPU()); procs > 0 {
+			runtime.GOMAXPROCS(procs)
+		}
+	}
+	if _, set := os.LookupEnv("GOMEMLIMIT"); !set {
+		if limit := memoryLimit(limits.Memory); limit > 0 {
+			debug.SetMemoryLimit(limit)
+		}
+	}
+
+	settings := &Settings{
+		Limits:     *limits,
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+	// A negative input returns the current limit without changing it.
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		settings.MemoryLimit = limit
+	}
+	klog.Infof("Tuned Go runtime for cgroup limits (cpu=%v, memory=%v): GOMAXPROCS=%v, memory limit=%v", limits.CPU, limits.Memory, settings.GOMAXPROCS, settings.MemoryLimit)
+	return settings
+}
+
+// maxProcs returns the GOMAXPROCS value for the specified CPU limit. The
+// limit is rounded down, since using more threads than the quota allows is
+// what causes throttling. A value of 0 is returned for an unlimited CPU.
+func maxProcs(cpu float64, numCPU int) int {
+	if cpu <= 0 {
+		return 0
+	}
+	procs := int(math.Floor(cpu))
+	if procs < 1 {
+		procs = 1
+	}
+	if procs > numCPU {
+		procs = numCPU
+	}
+	return procs
+}
+
+// memoryLimit returns the soft memory limit for the specified cgroup memory
+// limit. A value of 0 is returned for an unlimited memory.
+func memoryLimit(memory int64) int64 {
+	if memory <= 0 {
+		return 0
+	}
+	return int64(float64(memory) * memoryLimitRatio)
+}
+
+// Collectors returns the collectors that expose the settings as metrics with
+// the specified namespace.
+func (s *Settings) Collectors(namespace string) []prometheus.Collector {
+	gauge := func(name string, help string, value float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value })
+	}
+	return []prometheus.Collector{
+		gauge("gomaxprocs", "The number of CPUs used by the Go runtime.", float64(s.GOMAXPROCS)),
+		gauge("go_memory_limit_bytes", "The soft memory limit of the Go runtime in bytes; 0 if unlimited.", float64(s.MemoryLimit)),
+		gauge("cgroup_cpu_limit_cores", "The CPU limit of the cgroup in cores; 0 if unlimited.", s.CPU),
+		gauge("cgroup_memory_limit_bytes", "The memory limit of the cgroup in bytes; 0 if unlimited.", float64(s.Memory)),
+	}
+}
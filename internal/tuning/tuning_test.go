@@ -0,0 +1,45 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package tuning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxProcs(t *testing.T) {
+	testCases := []struct {
+		cpu      float64
+		numCPU   int
+		expected int
+	}{
+		{cpu: 0, numCPU: 128, expected: 0},
+		{cpu: 0.5, numCPU: 128, expected: 1},
+		{cpu: 2.5, numCPU: 128, expected: 2},
+		{cpu: 4, numCPU: 128, expected: 4},
+		{cpu: 256, numCPU: 128, expected: 128},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, maxProcs(tc.cpu, tc.numCPU), "cpu=%v", tc.cpu)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	require.Equal(t, int64(0), memoryLimit(0))
+	require.Equal(t, int64(900), memoryLimit(1000))
+}
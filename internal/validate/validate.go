@@ -0,0 +1,312 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package validate checks whether a config can be used by the device plugin
+// and the MPS control daemon without starting them.
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	nvinfo "github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/logger"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// These constants represent the supported output formats of a report.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// CommandName is the name of the validate subcommand.
+const CommandName = "validate"
+
+// ConfigLoader loads the config of an application from the specified config
+// file and the flags and environment variables of the application.
+type ConfigLoader func(c *cli.Context, configFile string) (*spec.Config, error)
+
+// NewCommand constructs the validate command of an application.
+// The command loads the config in the same way as the application and runs
+// the specified checks against it without starting the application. If
+// requested, the config is also checked against the devices on the node. The
+// command fails if the config is invalid so that it can be used in CI
+// pipelines.
+func NewCommand(load ConfigLoader, checks ...Check) *cli.Command {
+	var configFile string
+	var output string
+	var useNVML bool
+	return &cli.Command{
+		Name:  CommandName,
+		Usage: "Validate a config without starting the application",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "config",
+				Usage:       "the path to the config file to validate; defaults to the config file of the application",
+				Destination: &configFile,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       OutputText,
+				Usage:       "the output format:\n\t\t[text | json]",
+				Destination: &output,
+			},
+			&cli.BoolFlag{
+				Name:        "nvml",
+				Usage:       "also check whether the config is satisfiable with the devices on the node, which are enumerated through NVML",
+				Destination: &useNVML,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if output != OutputText && output != OutputJSON {
+				return fmt.Errorf("unsupported output format: %v", output)
+			}
+			if configFile == "" {
+				configFile = c.String("config-file")
+			}
+			var report *Report
+			config, err := load(c, configFile)
+			if err != nil {
+				report = ParseError(configFile, err)
+			} else {
+				var all []Check
+				for _, check := range checks {
+					if useNVML && check.RunOnNode != nil {
+						check.Run = check.RunOnNode
+					}
+					all = append(all, check)
+				}
+				if useNVML {
+					all = append(all, Devices(nvml.New()))
+				}
+				report = Run(configFile, config, all...)
+			}
+			if err := report.Write(c.App.Writer, output); err != nil {
+				return err
+			}
+			return report.Err()
+		},
+	}
+}
+
+// Issue is a problem found by a check of a config.
+type Issue struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// Report is the result of the validation of a config.
+type Report struct {
+	ConfigFile string  `json:"configFile,omitempty"`
+	Valid      bool    `json:"valid"`
+	Issues     []Issue `json:"issues,omitempty"`
+}
+
+// Check is a named check of a config. It returns all problems that it finds.
+type Check struct {
+	Name string
+	Run  func(*spec.Config) []error
+	// RunOnNode, if set, is run instead of Run when the config is also
+	// checked against the node with --nvml. Run must then not depend on the
+	// host that the command runs on.
+	RunOnNode func(*spec.Config) []error
+}
+
+// Run runs the specified checks against the config in order.
+func Run(configFile string, config *spec.Config, checks ...Check) *Report {
+	report := &Report{ConfigFile: configFile}
+	for _, check := range checks {
+		for _, err := range check.Run(config) {
+			report.Issues = append(report.Issues, Issue{Check: check.Name, Message: err.Error()})
+		}
+	}
+	report.Valid = len(report.Issues) == 0
+	return report
+}
+
+// ParseError returns the report for a config that could not be parsed.
+func ParseError(configFile string, err error) *Report {
+	return &Report{
+		ConfigFile: configFile,
+		Issues:     []Issue{{Check: "parse", Message: err.Error()}},
+	}
+}
+
+// Write writes the report in the specified output format.
+func (r *Report) Write(w io.Writer, output string) error {
+	switch output {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case OutputText:
+		if r.Valid {
+			fmt.Fprintln(w, "The config is valid")
+			return nil
+		}
+		for _, issue := range r.Issues {
+			fmt.Fprintf(w, "%v: %v\n", issue.Check, issue.Message)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported output format: %v", output)
+}
+
+// Err returns an error if the config is invalid.
+func (r *Report) Err() error {
+	if r.Valid {
+		return nil
+	}
+	return fmt.Errorf("the config failed validation with %d issue(s)", len(r.Issues))
+}
+
+// ResourceNames checks that the shared resources are not advertised under the
+// same name as other resources.
+var ResourceNames = Check{
+	Name: "resource-names",
+	Run:  resourceNames,
+}
+
+func resourceNames(config *spec.Config) []error {
+	configured := make(map[spec.ResourceName]bool)
+	for _, r := range config.Resources.GPUs {
+		configured[r.Name] = true
+	}
+	for _, r := range config.Resources.MIGs {
+		configured[r.Name] = true
+	}
+
+	var errs []error
+	advertised := make(map[spec.ResourceName]spec.ResourceName)
+	for _, rrs := range []*spec.ReplicatedResources{&config.Sharing.TimeSlicing, config.Sharing.MPS} {
+		if rrs == nil {
+			continue
+		}
+		for _, r := range rrs.Resources {
+			name := r.Name
+			if r.Rename != "" {
+				name = r.Rename
+			}
+			if other, exists := advertised[name]; exists {
+				errs = append(errs, fmt.Errorf("shared resources %v and %v are both advertised as %v", other, r.Name, name))
+				continue
+			}
+			advertised[name] = r.Name
+			if name != r.Name && configured[name] {
+				errs = append(errs, fmt.Errorf("shared resource %v is renamed to %v, which is already advertised for other devices", r.Name, name))
+			}
+		}
+	}
+	return errs
+}
+
+// MPSReplicas checks that the number of replicas of the resources shared
+// using MPS is supported by an MPS server.
+var MPSReplicas = Check{
+	Name: "mps",
+	Run:  mpsReplicas,
+}
+
+func mpsReplicas(config *spec.Config) []error {
+	if config.Sharing.MPS == nil {
+		return nil
+	}
+	var errs []error
+	for _, r := range config.Sharing.MPS.Resources {
+		if r.Replicas > mps.MaxClients {
+			errs = append(errs, fmt.Errorf("maximum allowed replicas exceeded for %v: %d > %d", r.Name, r.Replicas, mps.MaxClients))
+		}
+	}
+	return errs
+}
+
+// Devices checks that the config can be applied to the devices on the node,
+// which are enumerated through NVML. Each shared resource must match devices
+// on the node, and the MPS configuration of a resource must be supported by
+// its devices.
+func Devices(nvmllib nvml.Interface) Check {
+	return Check{
+		Name: "devices",
+		Run: func(config *spec.Config) []error {
+			return devices(nvmllib, config)
+		},
+	}
+}
+
+func devices(nvmllib nvml.Interface, config *spec.Config) []error {
+	devicelib := device.New(nvmllib)
+	infolib := nvinfo.New(
+		nvinfo.WithNvmlLib(nvmllib),
+		nvinfo.WithDeviceLib(devicelib),
+	)
+
+	spec.DisableResourceNamingInConfig(logger.ToKlog, config)
+	if err := rm.AddDefaultResourcesToConfig(infolib, nvmllib, devicelib, config); err != nil {
+		return []error{fmt.Errorf("unable to add default resources to config: %v", err)}
+	}
+
+	if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+		return []error{fmt.Errorf("failed to initialize NVML: %v", ret)}
+	}
+	defer func() {
+		_ = nvmllib.Shutdown()
+	}()
+
+	deviceMap, err := rm.NewDeviceMap(infolib, nvmllib, devicelib, config)
+	if err != nil {
+		return []error{fmt.Errorf("unable to build device map: %v", err)}
+	}
+	return checkDeviceMap(config, deviceMap)
+}
+
+// checkDeviceMap checks that each shared resource has devices in the device
+// map and that the MPS configuration of a resource is supported by its
+// devices.
+func checkDeviceMap(config *spec.Config, deviceMap rm.DeviceMap) []error {
+	var errs []error
+	for _, rrs := range []*spec.ReplicatedResources{&config.Sharing.TimeSlicing, config.Sharing.MPS} {
+		if rrs == nil {
+			continue
+		}
+		for i := range rrs.Resources {
+			r := &rrs.Resources[i]
+			name := r.Name
+			if r.Rename != "" {
+				name = r.Rename
+			}
+			if len(deviceMap[name]) == 0 {
+				errs = append(errs, fmt.Errorf("no devices on the node are advertised as shared resource %v", name))
+				continue
+			}
+			if rrs != config.Sharing.MPS {
+				continue
+			}
+			if err := mps.ValidateDevices(rrs, r, deviceMap[name]); err != nil {
+				errs = append(errs, fmt.Errorf("invalid MPS configuration for %v: %w", name, err))
+			}
+		}
+	}
+	return errs
+}
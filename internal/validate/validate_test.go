@@ -0,0 +1,138 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package validate
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestResourceNames(t *testing.T) {
+	config := &spec.Config{
+		Resources: spec.Resources{
+			GPUs: []spec.Resource{{Pattern: "*", Name: "nvidia.com/gpu"}},
+			MIGs: []spec.Resource{{Pattern: "1g.5gb", Name: "nvidia.com/mig-1g.5gb"}},
+		},
+		Sharing: spec.Sharing{
+			TimeSlicing: spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{Name: "nvidia.com/gpu", Rename: "nvidia.com/gpu.shared", Replicas: 2},
+					{Name: "nvidia.com/mig-1g.5gb", Rename: "nvidia.com/gpu", Replicas: 2},
+				},
+			},
+			MPS: &spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{Name: "nvidia.com/mig-2g.10gb", Rename: "nvidia.com/gpu.shared", Replicas: 2},
+				},
+			},
+		},
+	}
+	require.Equal(t, []error{
+		errors.New("shared resource nvidia.com/mig-1g.5gb is renamed to nvidia.com/gpu, which is already advertised for other devices"),
+		errors.New("shared resources nvidia.com/gpu and nvidia.com/mig-2g.10gb are both advertised as nvidia.com/gpu.shared"),
+	}, resourceNames(config))
+}
+
+func TestMPSReplicas(t *testing.T) {
+	config := &spec.Config{
+		Sharing: spec.Sharing{
+			MPS: &spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{Name: "nvidia.com/gpu", Replicas: 64},
+				},
+			},
+		},
+	}
+	require.Equal(t, []error{
+		errors.New("maximum allowed replicas exceeded for nvidia.com/gpu: 64 > 48"),
+	}, mpsReplicas(config))
+}
+
+func TestCheckDeviceMap(t *testing.T) {
+	replica := func(id string, replicas int) *rm.Device {
+		return &rm.Device{Device: pluginapi.Device{ID: id}, Replicas: replicas, ComputeCapability: "6.0"}
+	}
+	config := &spec.Config{
+		Sharing: spec.Sharing{
+			TimeSlicing: spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{Name: "nvidia.com/mig-1g.5gb", Replicas: 2},
+				},
+			},
+			MPS: &spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{Name: "nvidia.com/gpu", Rename: "nvidia.com/gpu.shared", Replicas: 32},
+				},
+			},
+		},
+	}
+	deviceMap := rm.DeviceMap{
+		"nvidia.com/gpu.shared": rm.Devices{"GPU-0::0": replica("GPU-0::0", 32)},
+	}
+	errs := checkDeviceMap(config, deviceMap)
+	require.Len(t, errs, 2)
+	require.EqualError(t, errs[0], "no devices on the node are advertised as shared resource nvidia.com/mig-1g.5gb")
+	require.EqualError(t, errs[1], "invalid MPS configuration for nvidia.com/gpu.shared: device GPU-0::0: invalid device maximum allowed replicas exceeded: 32 > 16")
+}
+
+func TestReport(t *testing.T) {
+	failing := Check{
+		Name: "failing",
+		Run: func(*spec.Config) []error {
+			return []error{errors.New("first"), errors.New("second")}
+		},
+	}
+	passing := Check{
+		Name: "passing",
+		Run:  func(*spec.Config) []error { return nil },
+	}
+
+	report := Run("config.yaml", &spec.Config{}, passing)
+	require.True(t, report.Valid)
+	require.NoError(t, report.Err())
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out, OutputText))
+	require.Equal(t, "The config is valid\n", out.String())
+
+	report = Run("config.yaml", &spec.Config{}, passing, failing)
+	require.False(t, report.Valid)
+	require.EqualError(t, report.Err(), "the config failed validation with 2 issue(s)")
+	out.Reset()
+	require.NoError(t, report.Write(&out, OutputText))
+	require.Equal(t, "failing: first\nfailing: second\n", out.String())
+	out.Reset()
+	require.NoError(t, report.Write(&out, OutputJSON))
+	require.JSONEq(t, `{
+		"configFile": "config.yaml",
+		"valid": false,
+		"issues": [
+			{"check": "failing", "message": "first"},
+			{"check": "failing", "message": "second"}
+		]
+	}`, out.String())
+
+	report = ParseError("config.yaml", errors.New("unknown version: v2"))
+	require.False(t, report.Valid)
+	require.Equal(t, []Issue{{Check: "parse", Message: "unknown version: v2"}}, report.Issues)
+}
@@ -0,0 +1,83 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package writable checks that the paths a component writes to are writable.
+// This allows a component that is run with a read-only root filesystem to
+// fail at startup with an explicit message instead of failing on its first
+// write.
+package writable
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Path is a path that a component writes to.
+type Path struct {
+	// Description describes what is written to the path, e.g. "plugin sockets".
+	Description string
+	// Dir is the directory in which files are created.
+	Dir string
+}
+
+// Dir returns a path for the specified directory.
+func Dir(description string, dir string) Path {
+	return Path{Description: description, Dir: dir}
+}
+
+// File returns a path for the directory of the specified file. An empty file
+// returns a path that is not checked.
+func File(description string, file string) Path {
+	if file == "" {
+		return Path{Description: description}
+	}
+	return Path{Description: description, Dir: filepath.Dir(file)}
+}
+
+// Check returns an error if files cannot be created in the directory of the
+// path. The directory is created if it does not exist, as the component would
+// do on its first write. Paths without a directory are skipped.
+func (p Path) Check() error {
+	if p.Dir == "" {
+		return nil
+	}
+	f, err := createTemp(p.Dir)
+	if err != nil {
+		return fmt.Errorf("%v cannot be written to %v: %w; mount a writable volume at this path (e.g. an emptyDir or hostPath) or configure a different path", p.Description, p.Dir, err)
+	}
+	name := f.Name()
+	_ = f.Close()
+	_ = os.Remove(name)
+	return nil
+}
+
+// Check returns the errors of all specified paths that are not writable.
+func Check(paths ...Path) error {
+	var errs []error
+	for _, p := range paths {
+		errs = append(errs, p.Check())
+	}
+	return errors.Join(errs...)
+}
+
+func createTemp(dir string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, ".writable-*")
+}
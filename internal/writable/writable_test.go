@@ -0,0 +1,51 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package writable
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	// A directory cannot be created below a regular file.
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	notADir := filepath.Join(file, "specs")
+
+	require.NoError(t, Check(
+		Dir("plugin sockets", dir),
+		File("the admin socket", filepath.Join(dir, "admin", "admin.sock")),
+		File("the drain socket", ""),
+	))
+	// Missing directories are created, but no files are left behind.
+	entries, err := os.ReadDir(filepath.Join(dir, "admin"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	err = Check(
+		Dir("plugin sockets", dir),
+		Dir("CDI specs", notADir),
+	)
+	require.ErrorContains(t, err, "CDI specs cannot be written to "+notADir)
+	require.ErrorContains(t, err, "mount a writable volume at this path")
+	require.NotContains(t, err.Error(), "plugin sockets")
+}
@@ -0,0 +1,141 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mpsclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout is the default timeout of a request to the admin API.
+const DefaultTimeout = 10 * time.Second
+
+// Client is a client for the admin API that the MPS control daemon serves on
+// a unix socket.
+type Client struct {
+	client *http.Client
+}
+
+// StatusError is returned if the admin API responds with an unexpected status.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error returns the error message of the admin API.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Message)
+}
+
+// New creates a client for the admin API served on the specified socket.
+func New(socket string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   DefaultTimeout,
+		},
+	}
+}
+
+// Health returns the health of the MPS control daemons.
+// An unhealthy daemon is not considered an error.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.do(ctx, http.MethodGet, "/v1/health", nil, &health, http.StatusOK, http.StatusServiceUnavailable); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Stats returns the state of each MPS control daemon.
+func (c *Client) Stats(ctx context.Context) ([]DaemonStats, error) {
+	var stats []DaemonStats
+	if err := c.do(ctx, http.MethodGet, "/v1/stats", nil, &stats, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Clients returns the MPS clients running on each GPU managed by the daemons.
+func (c *Client) Clients(ctx context.Context) ([]DeviceClients, error) {
+	var clients []DeviceClients
+	if err := c.do(ctx, http.MethodGet, "/v1/clients", nil, &clients, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// Evict terminates the specified MPS client.
+func (c *Client) Evict(ctx context.Context, eviction Eviction) error {
+	return c.do(ctx, http.MethodPost, "/v1/evictions", eviction, nil, http.StatusNoContent)
+}
+
+// do sends a request to the admin API and decodes the response into out if
+// the response has one of the expected statuses.
+func (c *Client) do(ctx context.Context, method string, path string, in interface{}, out interface{}, expected ...int) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://mps"+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, status := range expected {
+		if resp.StatusCode != status {
+			continue
+		}
+		if out == nil {
+			return nil
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	}
+
+	var e struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&e)
+	return &StatusError{StatusCode: resp.StatusCode, Message: e.Error}
+}
@@ -0,0 +1,97 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mpsclient
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+	return New(socket)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestClient(t *testing.T) {
+	var evicted []Eviction
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, Health{
+			Daemons: []DaemonHealth{{Resource: "nvidia.com/gpu", Error: "pipe closed"}},
+		})
+	})
+	mux.HandleFunc("GET /v1/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []DaemonStats{
+			{Resource: "nvidia.com/gpu", Devices: []string{"GPU-0"}, Replicas: 2, Servers: []Server{{PID: 10, Clients: []int{11}}}},
+		})
+	})
+	mux.HandleFunc("GET /v1/clients", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []DeviceClients{
+			{Resource: "nvidia.com/gpu", Device: "GPU-0", Clients: []ClientProcess{{PID: 11, ServerPID: 10, UsedMemoryBytes: 1024, PodUID: "pod-0"}}},
+		})
+	})
+	mux.HandleFunc("POST /v1/evictions", func(w http.ResponseWriter, r *http.Request) {
+		var e Eviction
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil || e.ClientPID != 11 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		evicted = append(evicted, e)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	health, err := c.Health(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &Health{Daemons: []DaemonHealth{{Resource: "nvidia.com/gpu", Error: "pipe closed"}}}, health)
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []DaemonStats{
+		{Resource: "nvidia.com/gpu", Devices: []string{"GPU-0"}, Replicas: 2, Servers: []Server{{PID: 10, Clients: []int{11}}}},
+	}, stats)
+
+	clients, err := c.Clients(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []DeviceClients{
+		{Resource: "nvidia.com/gpu", Device: "GPU-0", Clients: []ClientProcess{{PID: 11, ServerPID: 10, UsedMemoryBytes: 1024, PodUID: "pod-0"}}},
+	}, clients)
+
+	require.NoError(t, c.Evict(ctx, Eviction{Resource: "nvidia.com/gpu", ServerPID: 10, ClientPID: 11}))
+	require.Equal(t, []Eviction{{Resource: "nvidia.com/gpu", ServerPID: 10, ClientPID: 11}}, evicted)
+
+	err = c.Evict(ctx, Eviction{Resource: "nvidia.com/gpu", ServerPID: 10, ClientPID: 12})
+	require.Equal(t, &StatusError{StatusCode: http.StatusNotFound, Message: "not found"}, err)
+}
@@ -0,0 +1,99 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package mpsclient provides a client for the admin API of the MPS control
+// daemon.
+package mpsclient
+
+// Health represents the health of the MPS control daemons on a node.
+// The daemons are healthy if each individual daemon is healthy.
+type Health struct {
+	Healthy bool           `json:"healthy"`
+	Daemons []DaemonHealth `json:"daemons"`
+}
+
+// DaemonHealth represents the health of the MPS control daemon for a resource.
+type DaemonHealth struct {
+	Resource string `json:"resource"`
+	// MigDevice is the UUID of the MIG device that the daemon is dedicated
+	// to, if any.
+	MigDevice string `json:"migDevice,omitempty"`
+	Healthy   bool   `json:"healthy"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DaemonStats represents the state of the MPS control daemon for a resource.
+type DaemonStats struct {
+	Resource string `json:"resource"`
+	// MigDevice is the UUID of the MIG device that the daemon is dedicated
+	// to, if any.
+	MigDevice string `json:"migDevice,omitempty"`
+	// Devices are the UUIDs of the GPUs managed by the daemon.
+	Devices []string `json:"devices"`
+	// Replicas is the total number of replicas of the managed GPUs.
+	Replicas int `json:"replicas"`
+	// ActiveThreadPercentage is the default active thread percentage of
+	// the clients of the daemon.
+	ActiveThreadPercentage string `json:"activeThreadPercentage,omitempty"`
+	// PinnedDeviceMemoryLimits maps the index of each managed GPU to the
+	// default pinned device memory limit of the clients of the daemon.
+	PinnedDeviceMemoryLimits map[string]string `json:"pinnedDeviceMemoryLimits,omitempty"`
+	// Servers are the MPS servers started by the daemon.
+	Servers []Server `json:"servers"`
+	// Error is set if the servers could not be queried from the daemon.
+	Error string `json:"error,omitempty"`
+}
+
+// Server represents an MPS server and its connected clients.
+type Server struct {
+	PID     int   `json:"pid"`
+	Clients []int `json:"clients"`
+}
+
+// Eviction requests the termination of an MPS client.
+type Eviction struct {
+	Resource  string `json:"resource"`
+	ServerPID int    `json:"serverPID"`
+	ClientPID int    `json:"clientPID"`
+}
+
+// DeviceClients represents the MPS clients that run on a GPU managed by the
+// MPS control daemon for a resource.
+type DeviceClients struct {
+	Resource string `json:"resource"`
+	// MigDevice is the UUID of the MIG device that the daemon is dedicated
+	// to, if any.
+	MigDevice string `json:"migDevice,omitempty"`
+	// Device is the UUID of the GPU.
+	Device  string          `json:"device"`
+	Clients []ClientProcess `json:"clients"`
+	// Error is set if the clients of the GPU could not be determined.
+	Error string `json:"error,omitempty"`
+}
+
+// ClientProcess represents an MPS client process running on a GPU.
+type ClientProcess struct {
+	PID int `json:"pid"`
+	// ServerPID is the PID of the MPS server that the client is connected to.
+	ServerPID int `json:"serverPID"`
+	// UsedMemoryBytes is the GPU memory used by the client on the GPU.
+	UsedMemoryBytes uint64 `json:"usedMemoryBytes"`
+	// PodUID and ContainerID identify the container of the client. They are
+	// empty if the container cannot be determined from the cgroup of the
+	// process.
+	PodUID      string `json:"podUID,omitempty"`
+	ContainerID string `json:"containerID,omitempty"`
+}
@@ -60,6 +60,7 @@ var _ = NVDescribe("GPU Feature Discovery", func() {
 		"nvidia.com/gpu.compute.major":    "[0-9]+",
 		"nvidia.com/gpu.compute.minor":    "[0-9]+",
 		"nvidia.com/mps.capable":          "[true|false]",
+		"nvidia.com/gpu.cooling":          "(active|passive)",
 	}
 
 	defaultCollectorObjects := []string{